@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// compileCheck is the answer to a compile check
+type compileCheck struct {
+	Compiled bool   `json:"compiled"`
+	Output   string `json:"output"`
+}
+
+// errNotCompiled is returned once the compiler's errors were printed
+var errNotCompiled = errors.New("code does not compile")
+
+// runCheck compiles one source file, or the files of a Go module, on the
+// server without submitting them and prints what the compiler said
+func runCheck(args []string) error {
+	checkCmd := flag.NewFlagSet("check", flag.ExitOnError)
+	language := checkCmd.String("l", "", "Language of the code, by default the extension of the first file")
+	checkCmd.Usage = func() {
+		fmt.Fprintln(checkCmd.Output(), "Usage: goera check [options] <file>...")
+		fmt.Fprintln(checkCmd.Output(), "Several files are compiled as one Go module, with their paths as given.")
+		checkCmd.PrintDefaults()
+	}
+	checkCmd.Parse(args)
+
+	paths := checkCmd.Args()
+	if len(paths) == 0 {
+		checkCmd.Usage()
+		os.Exit(1)
+	}
+
+	creds, err := loadCredentials()
+	if err != nil {
+		return err
+	}
+	req, err := sourceRequest(paths, *language)
+	if err != nil {
+		return err
+	}
+
+	c := newClient(creds.Server, creds.Token)
+	var result compileCheck
+	if _, err := c.do(http.MethodPost, "/api/compile-check", req, &result); err != nil {
+		return fmt.Errorf("compile check failed: %w", err)
+	}
+	if output := strings.TrimRight(result.Output, "\n"); output != "" {
+		fmt.Println(output)
+	}
+	if !result.Compiled {
+		return errNotCompiled
+	}
+	fmt.Println("Compiles")
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// client calls the public API of a Goera server
+type client struct {
+	server string
+	token  string // Sent as a bearer token, either a personal access token or a session
+	http   *http.Client
+}
+
+func newClient(server, token string) *client {
+	return &client{
+		server: strings.TrimSuffix(server, "/"),
+		token:  token,
+		http: &http.Client{
+			Timeout: 30 * time.Second,
+			// API routes answer with JSON; a redirect means the request was
+			// not taken as an API request
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// apiError is the body of an error response from /api routes
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// do sends body as JSON and decodes a successful response into out, when it
+// is not nil. The response is returned so callers can read its cookies.
+func (c *client) do(method, path string, body, out any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.server+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var apiErr apiError
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Message != "" {
+			return resp, fmt.Errorf("%s (%s)", apiErr.Message, resp.Status)
+		}
+		return resp, fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp, fmt.Errorf("invalid response from %s: %w", path, err)
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// defaultServer is used until goera login is given another server
+const defaultServer = "http://localhost:5000"
+
+// credentials are what goera login stores for the other commands
+type credentials struct {
+	Server   string `json:"server"`
+	Username string `json:"username,omitempty"`
+	Token    string `json:"token"` // Personal access token with the read and submit scopes
+}
+
+// credentialsPath is where the credentials are stored, readable by the user only
+func credentialsPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "goera", "credentials.json"), nil
+}
+
+// loadCredentials reads the stored credentials. GOERA_SERVER and GOERA_TOKEN
+// override them, so scripts and CI can run without goera login.
+func loadCredentials() (credentials, error) {
+	var creds credentials
+	path, err := credentialsPath()
+	if err != nil {
+		return creds, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return creds, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &creds); err != nil {
+			return creds, fmt.Errorf("invalid credentials in %s: %w", path, err)
+		}
+	}
+
+	if server := os.Getenv("GOERA_SERVER"); server != "" {
+		creds.Server = server
+	}
+	if token := os.Getenv("GOERA_TOKEN"); token != "" {
+		creds.Token = token
+	}
+	if creds.Server == "" {
+		creds.Server = defaultServer
+	}
+	if creds.Token == "" {
+		return creds, errors.New("not logged in, run goera login first")
+	}
+	return creds, nil
+}
+
+func saveCredentials(creds credentials) error {
+	path, err := credentialsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
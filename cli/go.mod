@@ -0,0 +1,7 @@
+module goera/cli
+
+go 1.23.4
+
+require golang.org/x/term v0.30.0
+
+require golang.org/x/sys v0.31.0 // indirect
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// runLogin signs in with a username and password and stores a new personal
+// access token. The session used to create the token is ended right after, so
+// only the token stays valid.
+func runLogin(args []string) error {
+	loginCmd := flag.NewFlagSet("login", flag.ExitOnError)
+	server := loginCmd.String("server", defaultServer, "URL of the Goera server")
+	username := loginCmd.String("u", "", "Username, asked for when not given")
+	days := loginCmd.Int("days", 90, "Days until the token expires, 0 for a token that does not expire")
+	withToken := loginCmd.Bool("with-token", false, "Read an existing personal access token from stdin instead of signing in")
+	loginCmd.Parse(args)
+
+	stdin := bufio.NewReader(os.Stdin)
+	creds := credentials{Server: strings.TrimSuffix(*server, "/")}
+
+	if *withToken {
+		token, err := stdin.ReadString('\n')
+		if err != nil && token == "" {
+			return fmt.Errorf("failed to read token: %w", err)
+		}
+		creds.Token = strings.TrimSpace(token)
+		if _, err := newClient(creds.Server, creds.Token).do(http.MethodGet, "/api/submissions?page_size=1", nil, nil); err != nil {
+			return fmt.Errorf("token was not accepted: %w", err)
+		}
+		if err := saveCredentials(creds); err != nil {
+			return err
+		}
+		fmt.Printf("Logged in to %s\n", creds.Server)
+		return nil
+	}
+
+	if *username == "" {
+		fmt.Print("Username: ")
+		line, err := stdin.ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("failed to read username: %w", err)
+		}
+		*username = strings.TrimSpace(line)
+	}
+	password, err := readPassword(stdin)
+	if err != nil {
+		return err
+	}
+
+	session := newClient(creds.Server, "")
+	resp, err := session.do(http.MethodPost, "/api/login", map[string]string{"username": *username, "password": password}, nil)
+	if err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == "token" {
+			session.token = cookie.Value
+		}
+	}
+	if session.token == "" {
+		return errors.New("login failed: the server did not start a session")
+	}
+
+	hostname, _ := os.Hostname()
+	tokenReq := map[string]any{
+		"name":          strings.TrimSpace("goera cli " + hostname),
+		"scopes":        []string{"read", "submit"},
+		"expiresInDays": *days,
+	}
+	var created struct {
+		Token string `json:"token"`
+	}
+	_, err = session.do(http.MethodPost, "/api/tokens", tokenReq, &created)
+	if err != nil {
+		return fmt.Errorf("failed to create a token: %w", err)
+	}
+	if _, err := session.do(http.MethodPost, "/api/logout", struct{}{}, nil); err != nil {
+		fmt.Fprintf(os.Stderr, "goera: failed to end the login session: %v\n", err)
+	}
+
+	creds.Username = *username
+	creds.Token = created.Token
+	if err := saveCredentials(creds); err != nil {
+		return err
+	}
+	fmt.Printf("Logged in to %s as %s\n", creds.Server, creds.Username)
+	return nil
+}
+
+// readPassword asks for the password without echoing it, or reads a line of
+// stdin when it is not a terminal
+func readPassword(stdin *bufio.Reader) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := stdin.ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("failed to read password: %w", err)
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	fmt.Print("Password: ")
+	password, err := term.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	return string(password), nil
+}
+
+// runLogout forgets the stored token. It stays valid on the server until it
+// expires or is revoked, which takes a signed in session.
+func runLogout(args []string) error {
+	logoutCmd := flag.NewFlagSet("logout", flag.ExitOnError)
+	logoutCmd.Parse(args)
+
+	path, err := credentialsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return errors.New("not logged in")
+		}
+		return err
+	}
+	fmt.Println("Logged out. The token stays valid until it expires or is revoked with DELETE /api/tokens/{id}.")
+	return nil
+}
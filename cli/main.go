@@ -0,0 +1,59 @@
+// Command goera submits code to a Goera server and follows its verdict from
+// the terminal. It signs in once with goera login and keeps a personal access
+// token for the other commands.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+func usage() {
+	fmt.Println("Usage: goera <command> [options]")
+	fmt.Println("Commands:")
+	fmt.Println("  login     Sign in and store a personal access token")
+	fmt.Println("  logout    Forget the stored token")
+	fmt.Println("  check     Check that code compiles without submitting it, e.g. goera check main.go")
+	fmt.Println("  submit    Submit code to a question, e.g. goera submit -q 42 main.go")
+	fmt.Println("  status    Show the verdict of a submission, e.g. goera status 17")
+	fmt.Println("Run goera <command> -h for the options of a command.")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "login":
+		err = runLogin(os.Args[2:])
+	case "logout":
+		err = runLogout(os.Args[2:])
+	case "check":
+		err = runCheck(os.Args[2:])
+	case "submit":
+		err = runSubmit(os.Args[2:])
+	case "status":
+		err = runStatus(os.Args[2:])
+	case "help", "-h", "--help":
+		usage()
+	default:
+		fmt.Printf("Unknown command: %s\n", os.Args[1])
+		usage()
+		os.Exit(1)
+	}
+
+	// A verdict other than accepted or the compiler's errors were already
+	// printed, they only set the exit status so scripts can tell them apart
+	// from failing to get one
+	if errors.Is(err, errNotAccepted) || errors.Is(err, errNotCompiled) {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "goera:", err)
+		os.Exit(1)
+	}
+}
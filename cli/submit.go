@@ -0,0 +1,204 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// submission is the part of a submission the commands show
+type submission struct {
+	ID            uint   `json:"ID"`
+	QuestionID    uint   `json:"questionId"`
+	QuestionName  string `json:"questionName"`
+	Language      string `json:"language"`
+	JudgeStatus   string `json:"judgeStatus"`
+	Error         string `json:"error"`
+	ExecutionTime int    `json:"executionTime"`
+	MemoryUsage   int    `json:"memoryUsage"`
+	TestsDone     int    `json:"testsDone"`
+	TestsTotal    int    `json:"testsTotal"`
+}
+
+// finished reports whether the submission has its verdict
+func (s *submission) finished() bool {
+	return s.JudgeStatus != "pending" && s.JudgeStatus != "judging"
+}
+
+// errNotAccepted is returned once a verdict other than accepted was printed
+var errNotAccepted = errors.New("submission was not accepted")
+
+// runSubmit submits one source file, or the files of a Go module, and by
+// default waits for the verdict
+func runSubmit(args []string) error {
+	submitCmd := flag.NewFlagSet("submit", flag.ExitOnError)
+	questionID := submitCmd.Uint("q", 0, "ID of the question to submit to (required)")
+	language := submitCmd.String("l", "", "Language of the code, by default the extension of the first file")
+	contestID := submitCmd.Uint("contest", 0, "ID of the running contest to submit in")
+	wait := submitCmd.Bool("wait", true, "Wait for the verdict")
+	timeout := submitCmd.Duration("timeout", 10*time.Minute, "How long to wait for the verdict")
+	submitCmd.Usage = func() {
+		fmt.Fprintln(submitCmd.Output(), "Usage: goera submit -q <question> [options] <file>...")
+		fmt.Fprintln(submitCmd.Output(), "Several files are submitted as one Go module, with their paths as given.")
+		submitCmd.PrintDefaults()
+	}
+	submitCmd.Parse(args)
+
+	paths := submitCmd.Args()
+	if *questionID == 0 || len(paths) == 0 {
+		submitCmd.Usage()
+		os.Exit(1)
+	}
+
+	creds, err := loadCredentials()
+	if err != nil {
+		return err
+	}
+
+	req, err := sourceRequest(paths, *language)
+	if err != nil {
+		return err
+	}
+	req["questionId"] = *questionID
+	if *contestID != 0 {
+		req["contestId"] = *contestID
+	}
+
+	c := newClient(creds.Server, creds.Token)
+	var sub submission
+	if _, err := c.do(http.MethodPost, "/api/submissions", req, &sub); err != nil {
+		return fmt.Errorf("submission failed: %w", err)
+	}
+	fmt.Printf("Submitted %d to question %d\n", sub.ID, sub.QuestionID)
+
+	if !*wait {
+		return nil
+	}
+	return followSubmission(c, sub.ID, *timeout)
+}
+
+// sourceRequest reads the code of a submission or compile check: one source
+// file, or the files of a Go module. The language defaults to the extension
+// of the first file.
+func sourceRequest(paths []string, language string) (map[string]any, error) {
+	if language == "" {
+		language = strings.TrimPrefix(filepath.Ext(paths[0]), ".")
+	}
+	req := map[string]any{"language": language}
+	if len(paths) == 1 {
+		code, err := os.ReadFile(paths[0])
+		if err != nil {
+			return nil, err
+		}
+		req["code"] = string(code)
+		return req, nil
+	}
+
+	files := map[string]string{}
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		files[filepath.ToSlash(filepath.Clean(path))] = string(content)
+	}
+	req["files"] = files
+	return req, nil
+}
+
+// runStatus shows a submission, optionally waiting for its verdict
+func runStatus(args []string) error {
+	statusCmd := flag.NewFlagSet("status", flag.ExitOnError)
+	wait := statusCmd.Bool("wait", false, "Wait for the verdict if the submission is still being judged")
+	timeout := statusCmd.Duration("timeout", 10*time.Minute, "How long to wait for the verdict")
+	statusCmd.Usage = func() {
+		fmt.Fprintln(statusCmd.Output(), "Usage: goera status [options] <submission>")
+		statusCmd.PrintDefaults()
+	}
+	statusCmd.Parse(args)
+
+	var id uint
+	if statusCmd.NArg() != 1 {
+		statusCmd.Usage()
+		os.Exit(1)
+	}
+	if _, err := fmt.Sscan(statusCmd.Arg(0), &id); err != nil {
+		return fmt.Errorf("invalid submission ID %q", statusCmd.Arg(0))
+	}
+
+	creds, err := loadCredentials()
+	if err != nil {
+		return err
+	}
+	c := newClient(creds.Server, creds.Token)
+	if *wait {
+		return followSubmission(c, id, *timeout)
+	}
+
+	var sub submission
+	if _, err := c.do(http.MethodGet, fmt.Sprintf("/api/submissions/%d", id), nil, &sub); err != nil {
+		return err
+	}
+	return printSubmission(&sub)
+}
+
+// followSubmission polls a submission, printing its progress, until it has
+// its verdict
+func followSubmission(c *client, id uint, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	last := ""
+	for {
+		var sub submission
+		if _, err := c.do(http.MethodGet, fmt.Sprintf("/api/submissions/%d", id), nil, &sub); err != nil {
+			return err
+		}
+		if sub.finished() {
+			return printSubmission(&sub)
+		}
+
+		progress := sub.JudgeStatus
+		if sub.JudgeStatus == "judging" && sub.TestsTotal > 0 {
+			progress = fmt.Sprintf("judging, running test %d/%d", min(sub.TestsDone+1, sub.TestsTotal), sub.TestsTotal)
+		}
+		if progress != last {
+			fmt.Println(progress)
+			last = progress
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("no verdict after %s, check again with goera status %d", timeout, id)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// printSubmission prints a submission, returning errNotAccepted when it has
+// a verdict other than accepted
+func printSubmission(sub *submission) error {
+	name := sub.QuestionName
+	if name == "" {
+		name = fmt.Sprintf("question %d", sub.QuestionID)
+	}
+	fmt.Printf("Submission %d to %s (%s)\n", sub.ID, name, sub.Language)
+	fmt.Printf("Status: %s\n", sub.JudgeStatus)
+	if !sub.finished() {
+		if sub.TestsTotal > 0 {
+			fmt.Printf("Tests:  %d/%d judged\n", sub.TestsDone, sub.TestsTotal)
+		}
+		return nil
+	}
+	fmt.Printf("Time:   %d ms\n", sub.ExecutionTime)
+	fmt.Printf("Memory: %d MB\n", sub.MemoryUsage)
+	if sub.Error != "" {
+		fmt.Printf("\n%s\n", strings.TrimRight(sub.Error, "\n"))
+	}
+	if sub.JudgeStatus != "accepted" {
+		return errNotAccepted
+	}
+	return nil
+}
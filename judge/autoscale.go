@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// Autoscaling settings, configurable through the config file and environment.
+// A maxRunners of 0 disables autoscaling.
+var (
+	// autoscaleMaxRunners caps the running code-runners, counting the ones
+	// started by hand, that autoscaling adds to
+	autoscaleMaxRunners = 0
+	// autoscaleQueueThreshold is how many submissions may wait before another
+	// code-runner is started
+	autoscaleQueueThreshold = 5
+	// autoscaleIdleCooldown is how long a code-runner started by autoscaling
+	// has to be idle before it is retired
+	autoscaleIdleCooldown = 5 * time.Minute
+)
+
+const (
+	// autoscaleInterval is how often the queue depth is checked
+	autoscaleInterval = 10 * time.Second
+	// runnerStartTimeout is how long a new code-runner gets to answer
+	runnerStartTimeout = 30 * time.Second
+)
+
+var (
+	// Ports of code-runners that are being stopped and take no more jobs, and
+	// when each code-runner last finished a job. Guarded by mu.
+	retiring   = map[int]bool{}
+	lastActive = map[int]time.Time{}
+)
+
+// startAutoscaler checks the queue every autoscaleInterval, starting a
+// code-runner while more than autoscaleQueueThreshold submissions wait and
+// fewer than autoscaleMaxRunners are running, and retiring the code-runners
+// it started once they have been idle for autoscaleIdleCooldown. Code-runners
+// are started one at a time, so each gets to take on the queue first.
+func startAutoscaler() {
+	if autoscaleMaxRunners == 0 {
+		return
+	}
+	log.Printf("Autoscaling up to %d code-runners when more than %d submissions wait", autoscaleMaxRunners, autoscaleQueueThreshold)
+
+	for range time.Tick(autoscaleInterval) {
+		state := loadRunnerState()
+		mu.Lock()
+		depth := len(queue.normal) + len(queue.high)
+		running := 0
+		for _, runner := range state.Runners {
+			if runner.State == "running" && !retiring[runner.Port] {
+				running++
+			}
+		}
+		mu.Unlock()
+
+		if depth > autoscaleQueueThreshold && running < autoscaleMaxRunners {
+			scaleUp(depth)
+			continue
+		}
+		if depth == 0 {
+			retireIdleRunners(state)
+		}
+	}
+}
+
+// scaleUp starts a code-runner on the next free port and hands it queued
+// submissions once it answers
+func scaleUp(depth int) {
+	port := getNextPort()
+	log.Printf("%d submissions waiting, starting code-runner on port %d", depth, port)
+	runner, err := launchCodeRunner(port)
+	if err != nil {
+		log.Printf("Autoscaling failed to start code-runner on port %d: %v", port, err)
+		return
+	}
+	if err := waitForRunner(port); err != nil {
+		log.Printf("Code-runner on port %d started by autoscaling %v, stopping it", port, err)
+		stopRunner(newLauncher(), runner)
+		return
+	}
+
+	runner.Autoscaled = true
+	addRunnerToState(runner)
+	addPort(port)
+	log.Printf("Code-runner started on port %d with %s by autoscaling", port, runner.describe())
+
+	mu.Lock()
+	lastActive[port] = time.Now()
+	mu.Unlock()
+	// Recording its worker slots hands it queued submissions
+	if !refreshRunnerStatus(port) {
+		mu.Lock()
+		fillRunner(port)
+		mu.Unlock()
+	}
+}
+
+// waitForRunner waits up to runnerStartTimeout for a new code-runner to answer
+func waitForRunner(port int) error {
+	deadline := time.Now().Add(runnerStartTimeout)
+	for {
+		_, err := fetchRunnerStatus(port)
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("did not answer within %v: %w", runnerStartTimeout, err)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// retireIdleRunners stops the code-runners started by autoscaling that have
+// not had a job for autoscaleIdleCooldown. Those started by hand are kept.
+func retireIdleRunners(state RunnerState) {
+	for _, runner := range state.Runners {
+		if !runner.Autoscaled {
+			continue
+		}
+
+		mu.Lock()
+		active, ok := lastActive[runner.Port]
+		if !ok {
+			// Adopted after a restart, the cooldown starts now
+			active = time.Now()
+			lastActive[runner.Port] = active
+		}
+		idle := inFlight[runner.Port] == 0 && time.Since(active) >= autoscaleIdleCooldown
+		if idle {
+			// No job can be sent to it from here on
+			retiring[runner.Port] = true
+		}
+		mu.Unlock()
+		if !idle {
+			continue
+		}
+
+		log.Printf("Code-runner on port %d idle for %v, retiring it", runner.Port, autoscaleIdleCooldown)
+		if err := killCodeRunner(runner.Port); err != nil {
+			log.Printf("Failed to retire code-runner on port %d: %v", runner.Port, err)
+		}
+		mu.Lock()
+		delete(retiring, runner.Port)
+		delete(lastActive, runner.Port)
+		delete(inFlight, runner.Port)
+		delete(runnerWorkers, runner.Port)
+		mu.Unlock()
+	}
+}
+
+// isRetiring reports whether the code-runner on port is being stopped
+func isRetiring(port int) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return retiring[port]
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Artifact cache settings, configurable through serve flags and the config
+// file. A maxArtifactCacheBytes of 0 disables the cache.
+var (
+	artifactCacheDir              = "" // Empty uses goera-artifacts in the temp directory
+	maxArtifactCacheBytes   int64 = 512 * 1024 * 1024
+	maxArtifactCacheEntries       = 1000
+)
+
+// artifactCache keeps the executables of recent compilations, addressed by a
+// hash of what they were built from, so rejudging a submission or judging the
+// same code again skips the compiler. The least recently used executables are
+// evicted once the cache holds more than maxEntries or maxBytes.
+type artifactCache struct {
+	mu         sync.Mutex
+	dir        string
+	maxBytes   int64
+	maxEntries int
+	size       int64
+	lru        *list.List               // Of *artifact, most recently used first
+	entries    map[string]*list.Element // By key
+}
+
+// artifact is an executable in the cache
+type artifact struct {
+	key  string
+	size int64
+}
+
+var (
+	defaultArtifactCache *artifactCache
+	toolchainOnce        sync.Once
+	toolchainID          string
+)
+
+// newArtifactCache opens the cache in dir, creating it if needed. Executables
+// a previous run left there are kept, the most recently used first.
+func newArtifactCache(dir string, maxBytes int64, maxEntries int) (*artifactCache, error) {
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "goera-artifacts")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create artifact cache directory: %w", err)
+	}
+	c := &artifactCache{
+		dir:        dir,
+		maxBytes:   maxBytes,
+		maxEntries: maxEntries,
+		lru:        list.New(),
+		entries:    map[string]*list.Element{},
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read artifact cache directory: %w", err)
+	}
+	var found []fs.FileInfo
+	for _, file := range files {
+		info, err := file.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		// Copies interrupted half way are left behind under a temp name
+		if strings.HasPrefix(info.Name(), ".") {
+			os.Remove(filepath.Join(dir, info.Name()))
+			continue
+		}
+		found = append(found, info)
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].ModTime().After(found[j].ModTime()) })
+	for _, info := range found {
+		c.entries[info.Name()] = c.lru.PushBack(&artifact{key: info.Name(), size: info.Size()})
+		c.size += info.Size()
+	}
+	c.mu.Lock()
+	c.evict()
+	c.mu.Unlock()
+	return c, nil
+}
+
+// artifactKey identifies what compiling sourceFile, a file or a module
+// directory, would produce: its contents, laid out as compileProgram builds
+// them, the go build arguments and the Go toolchain
+func artifactKey(sourceFile string, buildArgs []string) (string, error) {
+	toolchainOnce.Do(func() {
+		out, err := exec.Command("go", "env", "GOVERSION", "GOOS", "GOARCH").Output()
+		if err != nil {
+			fmt.Printf("Failed to read the Go toolchain version, compiled executables are not cached: %v\n", err)
+			return
+		}
+		toolchainID = strings.Join(strings.Fields(string(out)), " ")
+	})
+	if toolchainID == "" {
+		return "", fmt.Errorf("unknown Go toolchain")
+	}
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "toolchain %s\n", toolchainID)
+	fmt.Fprintf(hash, "args %q\n", buildArgs)
+	info, err := os.Stat(sourceFile)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		fmt.Fprintf(hash, "file\n")
+		if err := hashFile(hash, sourceFile); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(hash.Sum(nil)), nil
+	}
+
+	fmt.Fprintf(hash, "module\n")
+	err = filepath.WalkDir(sourceFile, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(sourceFile, path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(hash, "%q\n", filepath.ToSlash(rel))
+		return hashFile(hash, path)
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// hashFile writes the length and contents of the file at path to w, so the
+// boundaries between files are part of the hash
+func hashFile(w io.Writer, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%d\n", len(data))
+	_, err = w.Write(data)
+	return err
+}
+
+// get places a copy of the executable cached under key at executablePath,
+// which the caller removes as usual, and reports whether there was one
+func (c *artifactCache) get(key, executablePath string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return false
+	}
+	path := filepath.Join(c.dir, key)
+	if err := linkOrCopy(path, executablePath); err != nil {
+		fmt.Printf("Artifact cache: failed to use %s: %v\n", key, err)
+		c.remove(element)
+		return false
+	}
+	c.lru.MoveToFront(element)
+	now := time.Now()
+	os.Chtimes(path, now, now) // Keeps the order of use across restarts
+	return true
+}
+
+// put stores a copy of the executable at executablePath under key, evicting
+// the least recently used executables if the cache gets too large
+func (c *artifactCache) put(key, executablePath string) {
+	info, err := os.Stat(executablePath)
+	if err != nil || info.Size() > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(element)
+		return
+	}
+	if err := linkOrCopy(executablePath, filepath.Join(c.dir, key)); err != nil {
+		fmt.Printf("Artifact cache: failed to store %s: %v\n", key, err)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&artifact{key: key, size: info.Size()})
+	c.size += info.Size()
+	c.evict()
+}
+
+// evict removes the least recently used executables until the cache is
+// within its limits. c.mu must be held.
+func (c *artifactCache) evict() {
+	for c.lru.Len() > 0 && (c.size > c.maxBytes || c.lru.Len() > c.maxEntries) {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove drops an executable from the cache. Copies handed out by get stay
+// usable. c.mu must be held.
+func (c *artifactCache) remove(element *list.Element) {
+	a := c.lru.Remove(element).(*artifact)
+	delete(c.entries, a.key)
+	c.size -= a.size
+	if err := os.Remove(filepath.Join(c.dir, a.key)); err != nil && !os.IsNotExist(err) {
+		fmt.Printf("Artifact cache: failed to remove %s: %v\n", a.key, err)
+	}
+}
+
+// linkOrCopy makes dst a hard link to src, or a copy when they are on
+// different file systems. A copy is written under a temp name and renamed,
+// so dst never holds a partial executable.
+func linkOrCopy(src, dst string) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+"-*")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(out.Name(), 0o755)
+	}
+	if err == nil {
+		err = os.Rename(out.Name(), dst)
+	}
+	if err != nil {
+		os.Remove(out.Name())
+	}
+	return err
+}
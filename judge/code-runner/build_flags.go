@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Values allowed for the flags that go build passes on to the compiler and
+// the linker. Anything that could run other programs, read files or change
+// where the build looks for code, like -toolexec, -overlay or -modfile, is
+// left out.
+var (
+	allowedGCFlags = map[string]bool{
+		"-N": true, // Disable optimizations
+		"-l": true, // Disable inlining
+		"-B": true, // Disable bounds checking
+	}
+	allowedLDFlags = map[string]bool{
+		"-s": true, // Omit the symbol table
+		"-w": true, // Omit DWARF debugging information
+	}
+)
+
+// buildTagPattern matches the build tags -tags may set
+var buildTagPattern = regexp.MustCompile(`^[A-Za-z0-9_.]+$`)
+
+// buildArgs checks a job's build flags against the allowlist and turns them
+// into arguments for go build. Each flag is -tags=a,b, -gcflags=-N, -l or -B,
+// -ldflags=-s or -w, or -trimpath. Repeated -gcflags and -ldflags are merged,
+// as go build only keeps the last of each.
+func buildArgs(flags []string) ([]string, error) {
+	var tags, gcflags, ldflags []string
+	var args []string
+	for _, flag := range flags {
+		name, value, hasValue := strings.Cut(flag, "=")
+		switch name {
+		case "-tags":
+			for _, tag := range strings.Split(value, ",") {
+				if !buildTagPattern.MatchString(tag) {
+					return nil, fmt.Errorf("invalid build tag %q", tag)
+				}
+				tags = append(tags, tag)
+			}
+		case "-gcflags":
+			if !allowedGCFlags[value] {
+				return nil, fmt.Errorf("compiler flag %q is not allowed", value)
+			}
+			gcflags = append(gcflags, value)
+		case "-ldflags":
+			if !allowedLDFlags[value] {
+				return nil, fmt.Errorf("linker flag %q is not allowed", value)
+			}
+			ldflags = append(ldflags, value)
+		case "-trimpath":
+			if hasValue {
+				return nil, fmt.Errorf("build flag %q takes no value", name)
+			}
+			args = append(args, name)
+		default:
+			return nil, fmt.Errorf("build flag %q is not allowed", flag)
+		}
+	}
+
+	if len(tags) > 0 {
+		args = append(args, "-tags="+strings.Join(tags, ","))
+	}
+	if len(gcflags) > 0 {
+		args = append(args, "-gcflags="+strings.Join(gcflags, " "))
+	}
+	if len(ldflags) > 0 {
+		args = append(args, "-ldflags="+strings.Join(ldflags, " "))
+	}
+	return args, nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// compileOnly builds a job's program without preparing the sandbox or running
+// any test case, so users can check that their code compiles before they
+// submit it. The compiler's output is returned with the temporary name of a
+// single source file replaced by main.go, which is what users know it as.
+func compileOnly(ctx context.Context, sourcePath string, buildArgs []string, events *EventLog) (Result, string) {
+	_, span := startSpan(ctx, "judge.compile")
+	executablePath, compileLog, err := compileProgram(sourcePath, buildArgs)
+	endSpan(span, err)
+
+	if info, statErr := os.Stat(sourcePath); statErr == nil && !info.IsDir() {
+		tempName := regexp.MustCompile(`[^\s:]*` + regexp.QuoteMeta(filepath.Base(sourcePath)))
+		compileLog = tempName.ReplaceAllString(compileLog, "main.go")
+	}
+
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			compileLog += "Compilation timed out\n"
+		}
+		events.Add("compile_finished", "Compilation failed")
+		return CompileError, compileLog
+	}
+	os.Remove(executablePath)
+	events.Add("compile_finished", "Compilation succeeded")
+	return Accepted, compileLog
+}
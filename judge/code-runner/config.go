@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"goera/proto/signing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultConfigFile is read when GOERA_CONFIG is not set and the file exists
+const defaultConfigFile = "goera.yaml"
+
+// listenAddr is the default for the --listen flag. The judge passes --listen
+// to every code-runner it starts, so this only matters when run by hand.
+var listenAddr = "8081"
+
+// fileConfig is the layout of the shared config file. The code-runner only
+// reads the code_runner section; the other sections belong to serve and the judge.
+type fileConfig struct {
+	Serve      yaml.Node            `yaml:"serve"`
+	Judge      yaml.Node            `yaml:"judge"`
+	CodeRunner codeRunnerFileConfig `yaml:"code_runner"`
+}
+
+type codeRunnerFileConfig struct {
+	Listen     string `yaml:"listen"`
+	Sandbox    string `yaml:"sandbox"`
+	NsjailPath string `yaml:"nsjail_path"`
+	Workers    int    `yaml:"workers"`
+
+	// Pinned runtime image per language, addressed by digest
+	RuntimeImages map[string]string `yaml:"runtime_images"`
+	Registry      struct {
+		Username string `yaml:"username"`
+		Password string `yaml:"password"`
+	} `yaml:"registry"`
+
+	// External modules multi-file submissions may depend on
+	AllowedModules []string `yaml:"allowed_modules"`
+
+	// Executables of recent compilations, reused for the same source
+	ArtifactCache struct {
+		Dir        string `yaml:"dir"`
+		MaxBytes   int64  `yaml:"max_bytes"`
+		MaxEntries int    `yaml:"max_entries"`
+	} `yaml:"artifact_cache"`
+
+	// Cleanup of what failed judgments leave behind
+	Maintenance struct {
+		CleanupInterval time.Duration `yaml:"cleanup_interval"`
+		PruneImages     bool          `yaml:"prune_images"`
+	} `yaml:"maintenance"`
+
+	Limits struct {
+		MaxRequestBytes  int64 `yaml:"max_request_bytes"`
+		MaxSourceBytes   int   `yaml:"max_source_bytes"`
+		MaxTestCaseBytes int   `yaml:"max_test_case_bytes"`
+		MaxOutputBytes   int   `yaml:"max_output_bytes"`
+		MaxStderrBytes   int   `yaml:"max_stderr_bytes"`
+
+		// Caps on the resources reserved by all judgments running at once
+		MaxTotalMemoryMB uint64  `yaml:"max_total_memory_mb"`
+		MaxTotalCPUs     float64 `yaml:"max_total_cpus"`
+
+		// Cores judgments are pinned to
+		CPUSet              string `yaml:"cpuset"`
+		DeterministicTiming bool   `yaml:"deterministic_timing"`
+	} `yaml:"limits"`
+
+	Internal struct {
+		HMACKeys         string        `yaml:"hmac_keys"` // Comma separated id:secret pairs, as INTERNAL_HMAC_KEYS
+		SignatureMaxSkew time.Duration `yaml:"signature_max_skew"`
+	} `yaml:"internal"`
+
+	Tracing struct {
+		Endpoint    string  `yaml:"endpoint"`
+		SampleRatio float64 `yaml:"sample_ratio"`
+	} `yaml:"tracing"`
+}
+
+// loadConfig applies the config file, if there is one, and then the
+// environment variables, which take precedence over the file. The serve flags
+// default to the result and so override both.
+func loadConfig() error {
+	path := os.Getenv("GOERA_CONFIG")
+	if path == "" {
+		if _, err := os.Stat(defaultConfigFile); err == nil {
+			path = defaultConfigFile
+		}
+	}
+	if path != "" {
+		if err := loadConfigFile(path); err != nil {
+			return err
+		}
+	}
+
+	if value := os.Getenv("CODE_RUNNER_SANDBOX"); value != "" {
+		sandboxBackend = value
+	}
+	if value := os.Getenv("NSJAIL_PATH"); value != "" {
+		nsjailPath = value
+	}
+	if value := os.Getenv("CODE_RUNNER_MAX_OUTPUT_BYTES"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid CODE_RUNNER_MAX_OUTPUT_BYTES %q: %w", value, err)
+		}
+		maxOutputBytes = limit
+	}
+	if value := os.Getenv("CODE_RUNNER_MAX_STDERR_BYTES"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid CODE_RUNNER_MAX_STDERR_BYTES %q: %w", value, err)
+		}
+		maxStderrBytes = limit
+	}
+	if value := os.Getenv("CODE_RUNNER_RUNTIME_IMAGES"); value != "" {
+		images, err := parseRuntimeImages(value)
+		if err != nil {
+			return fmt.Errorf("invalid CODE_RUNNER_RUNTIME_IMAGES: %w", err)
+		}
+		runtimeImages = images
+	}
+	if value := os.Getenv("CODE_RUNNER_REGISTRY_USERNAME"); value != "" {
+		registryUsername = value
+	}
+	if value := os.Getenv("CODE_RUNNER_REGISTRY_PASSWORD"); value != "" {
+		registryPassword = value
+	}
+	if value := os.Getenv("CODE_RUNNER_ALLOWED_MODULES"); value != "" {
+		allowedModules = nil
+		for _, module := range strings.Split(value, ",") {
+			if module = strings.TrimSpace(module); module != "" {
+				allowedModules = append(allowedModules, module)
+			}
+		}
+	}
+	if value := os.Getenv("CODE_RUNNER_ARTIFACT_CACHE_DIR"); value != "" {
+		artifactCacheDir = value
+	}
+	if value := os.Getenv("CODE_RUNNER_ARTIFACT_CACHE_MAX_BYTES"); value != "" {
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid CODE_RUNNER_ARTIFACT_CACHE_MAX_BYTES %q: %w", value, err)
+		}
+		maxArtifactCacheBytes = limit
+	}
+	if value := os.Getenv("CODE_RUNNER_ARTIFACT_CACHE_MAX_ENTRIES"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid CODE_RUNNER_ARTIFACT_CACHE_MAX_ENTRIES %q: %w", value, err)
+		}
+		maxArtifactCacheEntries = n
+	}
+	if value := os.Getenv("CODE_RUNNER_CLEANUP_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid CODE_RUNNER_CLEANUP_INTERVAL %q: %w", value, err)
+		}
+		cleanupInterval = interval
+	}
+	if value := os.Getenv("CODE_RUNNER_PRUNE_IMAGES"); value != "" {
+		prune, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid CODE_RUNNER_PRUNE_IMAGES %q: %w", value, err)
+		}
+		pruneImages = prune
+	}
+	if value := os.Getenv("CODE_RUNNER_WORKERS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid CODE_RUNNER_WORKERS %q: %w", value, err)
+		}
+		workers = n
+	}
+	if value := os.Getenv("CODE_RUNNER_CPUSET"); value != "" {
+		judgeCPUSet = value
+	}
+	if value := os.Getenv("CODE_RUNNER_DETERMINISTIC_TIMING"); value != "" {
+		deterministic, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid CODE_RUNNER_DETERMINISTIC_TIMING %q: %w", value, err)
+		}
+		deterministicTiming = deterministic
+	}
+	if value := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); value != "" {
+		tracingEndpoint = value
+	}
+	if value := os.Getenv("TRACING_SAMPLE_RATIO"); value != "" {
+		ratio, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid TRACING_SAMPLE_RATIO %q: %w", value, err)
+		}
+		tracingSampleRatio = ratio
+	}
+	loadInternalKeys()
+
+	if err := validateConfig(); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return nil
+}
+
+// loadConfigFile applies the code_runner section of the config file at path.
+// Keys missing from the file keep their default, unknown keys are an error.
+func loadConfigFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var file fileConfig
+	c := &file.CodeRunner
+	c.Listen = listenAddr
+	c.Sandbox = sandboxBackend
+	c.NsjailPath = nsjailPath
+	c.Workers = workers
+	c.RuntimeImages = runtimeImages
+	c.Registry.Username = registryUsername
+	c.Registry.Password = registryPassword
+	c.AllowedModules = allowedModules
+	c.ArtifactCache.Dir = artifactCacheDir
+	c.ArtifactCache.MaxBytes = maxArtifactCacheBytes
+	c.ArtifactCache.MaxEntries = maxArtifactCacheEntries
+	c.Maintenance.CleanupInterval = cleanupInterval
+	c.Maintenance.PruneImages = pruneImages
+	c.Limits.MaxRequestBytes = maxRequestBytes
+	c.Limits.MaxSourceBytes = maxSourceBytes
+	c.Limits.MaxTestCaseBytes = maxTestCaseBytes
+	c.Limits.MaxOutputBytes = maxOutputBytes
+	c.Limits.MaxStderrBytes = maxStderrBytes
+	c.Limits.MaxTotalMemoryMB = maxTotalMemoryMB
+	c.Limits.MaxTotalCPUs = maxTotalCPUs
+	c.Limits.CPUSet = judgeCPUSet
+	c.Limits.DeterministicTiming = deterministicTiming
+	c.Internal.SignatureMaxSkew = maxClockSkew
+	c.Tracing.Endpoint = tracingEndpoint
+	c.Tracing.SampleRatio = tracingSampleRatio
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	listenAddr = c.Listen
+	sandboxBackend = c.Sandbox
+	nsjailPath = c.NsjailPath
+	workers = c.Workers
+	runtimeImages = c.RuntimeImages
+	registryUsername = c.Registry.Username
+	registryPassword = c.Registry.Password
+	allowedModules = c.AllowedModules
+	artifactCacheDir = c.ArtifactCache.Dir
+	maxArtifactCacheBytes = c.ArtifactCache.MaxBytes
+	maxArtifactCacheEntries = c.ArtifactCache.MaxEntries
+	cleanupInterval = c.Maintenance.CleanupInterval
+	pruneImages = c.Maintenance.PruneImages
+	maxRequestBytes = c.Limits.MaxRequestBytes
+	maxSourceBytes = c.Limits.MaxSourceBytes
+	maxTestCaseBytes = c.Limits.MaxTestCaseBytes
+	maxOutputBytes = c.Limits.MaxOutputBytes
+	maxStderrBytes = c.Limits.MaxStderrBytes
+	maxTotalMemoryMB = c.Limits.MaxTotalMemoryMB
+	maxTotalCPUs = c.Limits.MaxTotalCPUs
+	judgeCPUSet = c.Limits.CPUSet
+	deterministicTiming = c.Limits.DeterministicTiming
+	if c.Internal.HMACKeys != "" {
+		internalKeys, _ = signing.ParseKeys(c.Internal.HMACKeys)
+	}
+	maxClockSkew = c.Internal.SignatureMaxSkew
+	tracingEndpoint = c.Tracing.Endpoint
+	tracingSampleRatio = c.Tracing.SampleRatio
+	return nil
+}
+
+// validateConfig checks the final settings and reports every problem at once
+func validateConfig() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	switch sandboxBackend {
+	case SandboxDocker, SandboxGVisor, SandboxNsjail:
+	default:
+		check(false, "sandbox must be docker, gvisor or nsjail, got %q", sandboxBackend)
+	}
+	check(sandboxBackend != SandboxNsjail || nsjailPath != "", "nsjail path is required for the nsjail sandbox")
+	check(maxRequestBytes > 0, "max request size must be positive")
+	check(maxSourceBytes > 0, "max source size must be positive")
+	check(maxTestCaseBytes > 0, "max test case size must be positive")
+	check(maxOutputBytes >= 0, "max output size cannot be negative")
+	check(maxStderrBytes >= 0, "max stderr size cannot be negative")
+	check(workers > 0, "workers must be positive")
+	for language, ref := range runtimeImages {
+		check(isPinnedImage(ref), "runtime image for %s must be pinned by digest (name@sha256:...), got %q", language, ref)
+	}
+	for _, module := range allowedModules {
+		check(module != "" && !isStandardImport(module) && !strings.HasSuffix(module, "/"),
+			"allowed module %q must be a module path such as golang.org/x/exp", module)
+	}
+	check(maxTotalCPUs >= 0, "max total CPUs cannot be negative")
+	if judgeCPUSet != "" {
+		_, err := parseCPUSet(judgeCPUSet)
+		check(err == nil, "invalid cpuset: %v", err)
+	}
+	check(!deterministicTiming || judgeCPUSet != "", "deterministic timing needs a cpuset")
+	check(cleanupInterval >= 0, "cleanup interval cannot be negative")
+	check(maxArtifactCacheBytes >= 0, "artifact cache size cannot be negative")
+	check(maxArtifactCacheEntries >= 0, "artifact cache entries cannot be negative")
+	check(maxClockSkew > 0, "internal signature max skew must be positive")
+	if tracingEndpoint != "" {
+		u, err := url.Parse(tracingEndpoint)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "tracing endpoint %q is not an http(s) URL", tracingEndpoint)
+	}
+	check(tracingSampleRatio >= 0 && tracingSampleRatio <= 1, "tracing sample ratio %v must be between 0 and 1", tracingSampleRatio)
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// CPU pinning, configurable through serve flags and the config file. With a
+// cpuset every judgment runs on cores of its own from the set, so programs
+// judged at the same time do not slow each other down. Deterministic timing
+// also gives each judgment whole cores without a CFS quota, which otherwise
+// lets a program run in bursts and then sit throttled for the rest of each
+// period, so tight time limits give the same verdict run after run.
+var (
+	judgeCPUSet         string // Cores judgments are pinned to, e.g. "2-7"; not pinned when empty
+	deterministicTiming bool
+)
+
+// parseCPUSet reads a cpuset list such as "0-3,8" into its cores, in order
+func parseCPUSet(list string) ([]int, error) {
+	var cores []int
+	for _, part := range strings.Split(list, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		first, last, isRange := strings.Cut(part, "-")
+		from, err := strconv.Atoi(first)
+		if err != nil || from < 0 {
+			return nil, fmt.Errorf("invalid core %q", part)
+		}
+		to := from
+		if isRange {
+			if to, err = strconv.Atoi(last); err != nil || to < from {
+				return nil, fmt.Errorf("invalid core range %q", part)
+			}
+		}
+		for core := from; core <= to; core++ {
+			cores = append(cores, core)
+		}
+	}
+	slices.Sort(cores)
+	cores = slices.Compact(cores)
+	if len(cores) == 0 {
+		return nil, fmt.Errorf("cpuset %q has no cores", list)
+	}
+	return cores, nil
+}
+
+// formatCPUSet writes cores as a cpuset list, as Docker and taskset take it
+func formatCPUSet(cores []int) string {
+	parts := make([]string, len(cores))
+	for i, core := range cores {
+		parts[i] = strconv.Itoa(core)
+	}
+	return strings.Join(parts, ",")
+}
+
+// coresFor returns how many cores a judgment with a CPU limit of cpus is
+// pinned to
+func coresFor(cpus float64) int {
+	return max(1, int(math.Ceil(cpus)))
+}
+
+// pinJudgment applies the cores a judgment was given to its configuration
+func pinJudgment(config *JudgeConfig, cores []int) {
+	if len(cores) == 0 {
+		return
+	}
+	config.CPUSet = formatCPUSet(cores)
+	if deterministicTiming {
+		config.CPUCount = float64(len(cores))
+		config.NoCPUQuota = true
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// generateResult holds what a generator printed for each input. Status is
+// Accepted only when every run exited normally; otherwise Outputs stops at
+// the run that failed.
+type generateResult struct {
+	Status  Result
+	Output  string // Log of the compilation and the runs
+	Outputs []string
+}
+
+// runGenerator compiles the generator and runs it on each input in turn,
+// stopping at the first run that fails. Like runJudge, it only returns an
+// error for failures of the runner itself.
+func runGenerator(ctx context.Context, config JudgeConfig, inputs []string) (*generateResult, error) {
+	var outputBuf bytes.Buffer
+	logWriter := io.MultiWriter(os.Stdout, &outputBuf)
+	resp := &generateResult{Outputs: []string{}}
+
+	sandbox, err := newSandbox(sandboxBackend)
+	if err != nil {
+		fmt.Fprintf(logWriter, "FATAL: Failed to create %s sandbox: %v\n", sandboxBackend, err)
+		resp.Output = outputBuf.String()
+		return resp, fmt.Errorf("failed to create sandbox: %w", err)
+	}
+	defer sandbox.Close()
+
+	if err := sandbox.Prepare(ctx, config, logWriter); err != nil {
+		fmt.Fprintf(logWriter, "Sandbox Preparation Failed: %v\n", err)
+		resp.Status = CompileError
+		resp.Output = outputBuf.String()
+		return resp, nil
+	}
+
+	executablePath, compileLog, err := compileProgram(config.SourceFilePath, config.BuildArgs)
+	if compileLog != "" {
+		fmt.Fprintf(logWriter, "--- Compilation Log ---\n%s\n--- End Compilation Log ---\n", compileLog)
+	}
+	if err != nil {
+		fmt.Fprintf(logWriter, "Generator Compilation Failed: %v\n", err)
+		resp.Status = CompileError
+		resp.Output = outputBuf.String()
+		return resp, nil
+	}
+	defer os.Remove(executablePath)
+
+	resp.Status = Accepted
+	for i, input := range inputs {
+		fmt.Fprintf(logWriter, "\n--- Running Generator %d / %d ---\n", i+1, len(inputs))
+		execResult := sandbox.Run(ctx, executablePath, input, config, logWriter)
+		if result, errMsg := executionFailure(config, execResult); result != Accepted {
+			fmt.Fprintf(logWriter, "Generator Run %d Failed: %s\n%s\n", i+1, result, errMsg)
+			resp.Status = result
+			break
+		}
+		resp.Outputs = append(resp.Outputs, execResult.Stdout)
+	}
+
+	fmt.Fprintf(logWriter, "\n--- Generator Finished ---\nResult: %s\n", resp.Status)
+	resp.Output = outputBuf.String()
+	return resp, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// defaultLanguage is assumed for jobs that do not name their language
+const defaultLanguage = "go"
+
+// Pinned runtime images per language, pulled from a registry instead of being
+// built from the embedded Dockerfile. Every image must be addressed by digest
+// so all runners judge with exactly the same image.
+var (
+	runtimeImages    = map[string]string{}
+	registryUsername string // Credentials for pulling runtime images, if the registry needs them
+	registryPassword string
+)
+
+// pinnedImagePattern matches image references addressed by a sha256 digest,
+// e.g. registry.example.com/goera/go@sha256:<64 hex digits>
+var pinnedImagePattern = regexp.MustCompile(`^[^@\s]+@sha256:[0-9a-f]{64}$`)
+
+// isPinnedImage reports whether ref names an image by digest
+func isPinnedImage(ref string) bool {
+	return pinnedImagePattern.MatchString(ref)
+}
+
+// parseRuntimeImages reads comma separated language=image pairs, as in
+// CODE_RUNNER_RUNTIME_IMAGES
+func parseRuntimeImages(value string) (map[string]string, error) {
+	images := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		language, ref, ok := strings.Cut(pair, "=")
+		if !ok || language == "" || ref == "" {
+			return nil, fmt.Errorf("runtime image %q is not a language=image pair", pair)
+		}
+		images[strings.TrimSpace(language)] = strings.TrimSpace(ref)
+	}
+	return images, nil
+}
+
+// runtimeImage returns the pinned image configured for language, if any
+func runtimeImage(language string) (string, bool) {
+	if language == "" {
+		language = defaultLanguage
+	}
+	ref, ok := runtimeImages[language]
+	return ref, ok
+}
+
+// syncImages readies the judging images at startup so the first submission
+// does not pay for them: it pulls every pinned runtime image that is missing,
+// and builds the image from the embedded Dockerfile when the default language
+// has no pinned image. With force the Dockerfile image is rebuilt even if it exists.
+func syncImages(force bool) error {
+	if sandboxBackend != SandboxDocker && sandboxBackend != SandboxGVisor && sandboxBackend != "" {
+		return nil
+	}
+
+	sandbox, err := newSandbox(sandboxBackend)
+	if err != nil {
+		return err
+	}
+	defer sandbox.Close()
+	docker := sandbox.(*dockerSandbox)
+
+	languages := make([]string, 0, len(runtimeImages))
+	for language := range runtimeImages {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+
+	var failed []string
+	for _, language := range languages {
+		ref := runtimeImages[language]
+		if err := docker.ensureImage(context.Background(), JudgeConfig{DockerImageName: ref}, os.Stdout, false); err != nil {
+			fmt.Printf("Failed to pull runtime image for %s: %v\n", language, err)
+			failed = append(failed, language)
+		}
+	}
+
+	if _, ok := runtimeImage(defaultLanguage); !ok || force {
+		if err := docker.ensureImage(context.Background(), JudgeConfig{DockerImageName: DEFAULT_DOCKER_IMAGE}, os.Stdout, force); err != nil {
+			return err
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("runtime images for %s are not available", strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+)
+
+// judgeLabel marks the containers and images the code-runner creates, so the
+// cleanup can tell them apart from everything else on the Docker host
+const judgeLabel = "goera.judge"
+
+// staleAfter is how old a temp file or container has to be before the cleanup
+// takes it for a leftover. Judgments finish long before, and the age keeps the
+// cleanup away from the work of other code-runners sharing the host.
+const staleAfter = time.Hour
+
+// tempPatterns match the temp files and directories judgments create: the
+// sources written by writeSource and writeModule, and the executables
+// compileProgram builds from them
+var tempPatterns = []string{"source-*", "generator-*", "module-*"}
+
+// Maintenance settings, configurable through serve flags and the config file
+var (
+	cleanupInterval = 24 * time.Hour // 0 disables the cleanup
+	pruneImages     = false
+)
+
+// startMaintenance runs the cleanup at startup and then every cleanupInterval
+func startMaintenance() {
+	if cleanupInterval <= 0 {
+		return
+	}
+	fmt.Printf("Cleaning up leftovers every %s\n", cleanupInterval)
+
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+	for {
+		runMaintenance()
+		<-ticker.C
+	}
+}
+
+// runMaintenance removes what crashed or interrupted judgments left behind:
+// temp files, containers and, with pruneImages, judging images that are no
+// longer configured
+func runMaintenance() {
+	if removed, err := removeStaleTempFiles(time.Now().Add(-staleAfter)); err != nil {
+		fmt.Printf("Cleanup: failed to remove temp files: %v\n", err)
+	} else if removed > 0 {
+		fmt.Printf("Cleanup: removed %d stale temp files\n", removed)
+	}
+
+	if sandboxBackend != SandboxDocker && sandboxBackend != SandboxGVisor {
+		return
+	}
+	sandbox, err := newDockerSandbox("")
+	if err != nil {
+		fmt.Printf("Cleanup: %v\n", err)
+		return
+	}
+	defer sandbox.Close()
+
+	if removed, err := sandbox.removeOrphanContainers(time.Now().Add(-staleAfter)); err != nil {
+		fmt.Printf("Cleanup: failed to remove orphaned containers: %v\n", err)
+	} else if removed > 0 {
+		fmt.Printf("Cleanup: removed %d orphaned containers\n", removed)
+	}
+
+	if pruneImages {
+		if removed, err := sandbox.removeUnusedImages(); err != nil {
+			fmt.Printf("Cleanup: failed to prune judging images: %v\n", err)
+		} else if removed > 0 {
+			fmt.Printf("Cleanup: pruned %d unused judging images\n", removed)
+		}
+	}
+}
+
+// removeStaleTempFiles removes the judgment temp files and directories last
+// modified before cutoff and returns how many it removed
+func removeStaleTempFiles(cutoff time.Time) (int, error) {
+	removed := 0
+	for _, pattern := range tempPatterns {
+		paths, err := filepath.Glob(filepath.Join(os.TempDir(), pattern))
+		if err != nil {
+			return removed, err
+		}
+		for _, path := range paths {
+			info, err := os.Lstat(path)
+			if err != nil || info.ModTime().After(cutoff) {
+				continue
+			}
+			if err := os.RemoveAll(path); err != nil {
+				fmt.Printf("Cleanup: failed to remove %s: %v\n", path, err)
+				continue
+			}
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// removeOrphanContainers force removes the judging containers created before
+// cutoff, which Run failed to remove, and returns how many it removed
+func (s *dockerSandbox) removeOrphanContainers(cutoff time.Time) (int, error) {
+	ctx := context.Background()
+	containers, err := s.cli.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", judgeLabel)),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	removed := 0
+	for _, c := range containers {
+		if time.Unix(c.Created, 0).After(cutoff) {
+			continue
+		}
+		if err := s.cli.ContainerRemove(ctx, c.ID, container.RemoveOptions{Force: true}); err != nil {
+			fmt.Printf("Cleanup: failed to remove container %s: %v\n", c.ID, err)
+			continue
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// removeUnusedImages removes judging images the configuration no longer
+// uses: images built from the embedded Dockerfile other than the default one,
+// including those left untagged by rebuilds, and runtime images replaced by
+// another digest. Images still used by a container are kept.
+func (s *dockerSandbox) removeUnusedImages() (int, error) {
+	ctx := context.Background()
+	images, err := s.cli.ImageList(ctx, image.ListOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list images: %w", err)
+	}
+
+	// Repositories of the configured runtime images, whose other digests are stale
+	runtimeRepos := map[string]bool{}
+	for _, ref := range runtimeImages {
+		repo, _, _ := strings.Cut(ref, "@")
+		runtimeRepos[repo] = true
+	}
+
+	readyImagesMu.Lock()
+	defer readyImagesMu.Unlock()
+
+	removed := 0
+	for _, img := range images {
+		if !isUnusedImage(img, runtimeRepos) {
+			continue
+		}
+		if _, err := s.cli.ImageRemove(ctx, img.ID, image.RemoveOptions{PruneChildren: true}); err != nil {
+			// Images in use by a container cannot be removed without force
+			fmt.Printf("Cleanup: failed to remove image %s: %v\n", img.ID, err)
+			continue
+		}
+		for _, ref := range slices.Concat(img.RepoTags, img.RepoDigests) {
+			delete(readyImages, ref)
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// isUnusedImage reports whether img is a judging image that is not configured
+func isUnusedImage(img image.Summary, runtimeRepos map[string]bool) bool {
+	if slices.Contains(img.RepoTags, DEFAULT_DOCKER_IMAGE) {
+		return false
+	}
+	for _, ref := range runtimeImages {
+		if slices.Contains(img.RepoDigests, ref) {
+			return false
+		}
+	}
+
+	if _, ok := img.Labels[judgeLabel]; ok {
+		return true
+	}
+	for _, digest := range img.RepoDigests {
+		repo, _, _ := strings.Cut(digest, "@")
+		if runtimeRepos[repo] {
+			return true
+		}
+	}
+	return false
+}
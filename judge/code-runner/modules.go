@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// allowedModules are the external modules multi-file submissions may import
+// and require, e.g. golang.org/x/exp. A module also allows its packages.
+// Everything else outside the standard library is refused before building.
+var allowedModules []string
+
+// moduleAllowed reports whether path is one of the allowed modules or a package in one
+func moduleAllowed(path string) bool {
+	for _, module := range allowedModules {
+		if path == module || strings.HasPrefix(path, module+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// isStandardImport reports whether path names a standard library package.
+// Like the go command, it takes paths whose first element has no dot as standard.
+func isStandardImport(path string) bool {
+	first, _, _ := strings.Cut(path, "/")
+	return !strings.Contains(first, ".")
+}
+
+// checkDependencies refuses a module whose go.mod or imports name external
+// modules that are not allowed. Replace directives are refused as well, since
+// they could swap in code from anywhere.
+func checkDependencies(dir string) error {
+	modulePath, requires, err := readGoMod(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return err
+	}
+
+	disallowed := map[string]bool{}
+	for _, module := range requires {
+		if !moduleAllowed(module) {
+			disallowed[module] = true
+		}
+	}
+
+	fset := token.NewFileSet()
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".go" {
+			return err
+		}
+		file, err := parser.ParseFile(fset, path, nil, parser.ImportsOnly)
+		if err != nil {
+			// Syntax errors are left for the compiler to report
+			return nil
+		}
+		for _, spec := range file.Imports {
+			importPath := strings.Trim(spec.Path.Value, "\"`")
+			if isStandardImport(importPath) || importPath == modulePath || strings.HasPrefix(importPath, modulePath+"/") {
+				continue
+			}
+			if !moduleAllowed(importPath) {
+				disallowed[importPath] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read source files: %w", err)
+	}
+
+	if len(disallowed) > 0 {
+		names := make([]string, 0, len(disallowed))
+		for name := range disallowed {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("dependencies not on the allowlist: %s", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// readGoMod returns the module path and required modules of a go.mod file.
+// A missing file stands for the go.mod compileProgram creates.
+func readGoMod(path string) (modulePath string, requires []string, err error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return "submission", nil, nil
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read go.mod: %w", err)
+	}
+	defer file.Close()
+
+	block := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "//")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		directive := block
+		switch {
+		case block != "" && fields[0] == ")":
+			block = ""
+			continue
+		case block == "" && len(fields) == 2 && fields[1] == "(":
+			block = fields[0]
+			continue
+		case block == "":
+			directive, fields = fields[0], fields[1:]
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch directive {
+		case "module":
+			modulePath = strings.Trim(fields[0], "\"")
+		case "require":
+			requires = append(requires, strings.Trim(fields[0], "\""))
+		case "replace":
+			return "", nil, fmt.Errorf("go.mod may not replace modules")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", nil, fmt.Errorf("failed to read go.mod: %w", err)
+	}
+	if modulePath == "" {
+		return "", nil, fmt.Errorf("go.mod has no module directive")
+	}
+	return modulePath, requires, nil
+}
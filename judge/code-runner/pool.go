@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// Worker pool limits, configurable through serve flags and the config file.
+var (
+	workers           = 1
+	maxTotalMemoryMB  uint64 // 0 for no cap
+	maxTotalCPUs      float64
+	defaultWorkerPool *workerPool
+)
+
+// workerPool bounds how many submissions a code-runner judges at once. Besides
+// the number of slots it caps the memory and CPUs reserved by the judgments
+// running together, so a few large submissions cannot overcommit the host.
+// With CPU pinning it also hands each judgment cores of its own.
+type workerPool struct {
+	mu       sync.Mutex
+	workers  int
+	maxMemMB uint64
+	maxCPUs  float64
+
+	busy    int
+	memMB   uint64
+	cpus    float64
+	cores   []int // Free cores to pin judgments to, nil without pinning
+	pinned  int   // Cores pinning started with
+	waiting int
+	changed chan struct{} // Closed and replaced whenever a slot is released
+}
+
+// PoolStatus is the worker pool's state as reported to the judge by RunnerStatus
+type PoolStatus struct {
+	Workers          int     `json:"workers"`
+	Busy             int     `json:"busy"`
+	Available        int     `json:"available"`
+	Waiting          int     `json:"waiting"`
+	MemoryMB         uint64  `json:"memoryMb"`
+	MaxTotalMemoryMB uint64  `json:"maxTotalMemoryMb"`
+	CPUs             float64 `json:"cpus"`
+	MaxTotalCPUs     float64 `json:"maxTotalCpus"`
+}
+
+func newWorkerPool(workers int, maxMemMB uint64, maxCPUs float64, cores []int) *workerPool {
+	return &workerPool{
+		workers:  workers,
+		maxMemMB: maxMemMB,
+		maxCPUs:  maxCPUs,
+		cores:    cores,
+		pinned:   len(cores),
+		changed:  make(chan struct{}),
+	}
+}
+
+// coresNeeded returns how many cores a judgment with a CPU limit of cpus is
+// pinned to, at most all of them
+func (p *workerPool) coresNeeded(cpus float64) int {
+	if p.pinned == 0 {
+		return 0
+	}
+	return min(coresFor(cpus), p.pinned)
+}
+
+// fits reports whether a judgment with the given limits can start now. One
+// that is larger than the caps on its own is let through once the pool is
+// idle, so it runs alone instead of waiting forever.
+func (p *workerPool) fits(memMB uint64, cpus float64) bool {
+	if p.busy >= p.workers || len(p.cores) < p.coresNeeded(cpus) {
+		return false
+	}
+	if p.busy == 0 {
+		return true
+	}
+	if p.maxMemMB > 0 && p.memMB+memMB > p.maxMemMB {
+		return false
+	}
+	if p.maxCPUs > 0 && p.cpus+cpus > p.maxCPUs {
+		return false
+	}
+	return true
+}
+
+// acquire waits for a free slot with room for the given limits and reserves
+// it, together with the cores the judgment is pinned to, if any. The returned
+// function gives the slot back.
+func (p *workerPool) acquire(ctx context.Context, memMB uint64, cpus float64) (func(), []int, error) {
+	p.mu.Lock()
+	p.waiting++
+	for !p.fits(memMB, cpus) {
+		changed := p.changed
+		p.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			p.mu.Lock()
+			p.waiting--
+			p.mu.Unlock()
+			return nil, nil, ctx.Err()
+		}
+		p.mu.Lock()
+	}
+	p.waiting--
+	p.busy++
+	p.memMB += memMB
+	p.cpus += cpus
+	cores := slices.Clone(p.cores[:p.coresNeeded(cpus)])
+	p.cores = p.cores[len(cores):]
+	p.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.mu.Lock()
+			p.busy--
+			p.memMB -= memMB
+			p.cpus -= cpus
+			p.cores = append(p.cores, cores...)
+			slices.Sort(p.cores)
+			close(p.changed)
+			p.changed = make(chan struct{})
+			p.mu.Unlock()
+		})
+	}, cores, nil
+}
+
+// status returns a snapshot of the pool
+func (p *workerPool) status() PoolStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return PoolStatus{
+		Workers:          p.workers,
+		Busy:             p.busy,
+		Available:        p.workers - p.busy,
+		Waiting:          p.waiting,
+		MemoryMB:         p.memMB,
+		MaxTotalMemoryMB: p.maxMemMB,
+		CPUs:             p.cpus,
+		MaxTotalCPUs:     p.maxCPUs,
+	}
+}
@@ -0,0 +1,20 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// processUsage reads the resources used by an exited process and the
+// children it waited for. The peak memory is that of the largest of them.
+func processUsage(state *os.ProcessState) ResourceUsage {
+	usage := ResourceUsage{CPUTime: state.UserTime() + state.SystemTime()}
+	if rusage, ok := state.SysUsage().(*syscall.Rusage); ok {
+		usage.PeakMemoryBytes = uint64(rusage.Maxrss) * 1024 // Maxrss is in kilobytes
+		usage.IOReadBytes = uint64(rusage.Inblock) * 512
+		usage.IOWriteBytes = uint64(rusage.Oublock) * 512
+	}
+	return usage
+}
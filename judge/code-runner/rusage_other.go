@@ -0,0 +1,11 @@
+//go:build !linux
+
+package main
+
+import "os"
+
+// processUsage reads the CPU time of an exited process; other figures are
+// only read on Linux
+func processUsage(state *os.ProcessState) ResourceUsage {
+	return ResourceUsage{CPUTime: state.UserTime() + state.SystemTime()}
+}
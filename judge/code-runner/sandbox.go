@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Sandbox is an isolated environment that runs a compiled submission against a
+// single test case. Backends only report how the program ran; turning that into
+// a verdict is shared by all of them in evaluateExecution.
+type Sandbox interface {
+	// Name identifies the backend in logs
+	Name() string
+	// Prepare readies the backend for a submission, e.g. by building an image
+	Prepare(ctx context.Context, config JudgeConfig, logWriter io.Writer) error
+	// Run executes the program at executablePath with input on stdin,
+	// enforcing the limits in config
+	Run(ctx context.Context, executablePath string, input string, config JudgeConfig, logWriter io.Writer) ExecResult
+	// Close releases resources held by the backend
+	Close() error
+}
+
+// ExecResult describes how a program ran inside a sandbox
+type ExecResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	TimedOut bool
+	// OutputLimitExceeded is set when the program wrote more than
+	// config.OutputLimitBytes to stdout or stderr and was stopped early
+	OutputLimitExceeded bool
+	Err                 error // Set when the sandbox itself failed, not the program
+	Warning             string
+	Usage               ResourceUsage
+}
+
+// ResourceUsage is what a program used while it ran. Backends fill in what
+// they can measure and leave the rest at zero.
+type ResourceUsage struct {
+	WallTime        time.Duration // From start to exit, including start-up of the sandbox
+	CPUTime         time.Duration // User and system time
+	PeakMemoryBytes uint64
+	IOReadBytes     uint64
+	IOWriteBytes    uint64
+}
+
+// String formats the usage for the judge log
+func (u ResourceUsage) String() string {
+	return fmt.Sprintf("wall %s, CPU %s, peak memory %.1f MB, read %d bytes, written %d bytes",
+		u.WallTime.Round(time.Millisecond), u.CPUTime.Round(time.Millisecond),
+		float64(u.PeakMemoryBytes)/(1024*1024), u.IOReadBytes, u.IOWriteBytes)
+}
+
+// limitedBuffer keeps the first limit bytes written to it and discards the
+// rest, so a program flooding its output cannot exhaust the runner's memory.
+// Writes never fail: the stream keeps draining until the sandbox stops the
+// program, which onExceed is there to trigger.
+type limitedBuffer struct {
+	buf      bytes.Buffer
+	limit    int // 0 disables the limit
+	exceeded atomic.Bool
+	onExceed func()
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.limit <= 0 {
+		return b.buf.Write(p)
+	}
+	if remaining := b.limit - b.buf.Len(); len(p) > remaining {
+		if remaining > 0 {
+			b.buf.Write(p[:remaining])
+		}
+		if !b.exceeded.Swap(true) && b.onExceed != nil {
+			b.onExceed()
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// String returns the output kept so far
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
+
+// Exceeded reports whether any output was discarded
+func (b *limitedBuffer) Exceeded() bool {
+	return b.exceeded.Load()
+}
+
+// newOutputBuffers returns stdout and stderr buffers capped at limit bytes
+// each. onExceed is called once, when the first of them overflows.
+func newOutputBuffers(limit int, onExceed func()) (stdout, stderr *limitedBuffer) {
+	var once sync.Once
+	notify := func() { once.Do(onExceed) }
+	return &limitedBuffer{limit: limit, onExceed: notify}, &limitedBuffer{limit: limit, onExceed: notify}
+}
+
+// Supported sandbox backends, selected with the --sandbox flag
+const (
+	SandboxDocker = "docker"
+	SandboxGVisor = "gvisor"
+	SandboxNsjail = "nsjail"
+)
+
+var (
+	sandboxBackend = SandboxDocker
+	nsjailPath     = "nsjail"
+)
+
+// newSandbox creates the sandbox backend with the given name
+func newSandbox(name string) (Sandbox, error) {
+	switch name {
+	case SandboxDocker, "":
+		return newDockerSandbox("")
+	case SandboxGVisor:
+		// gVisor plugs into Docker as the runsc runtime
+		return newDockerSandbox("runsc")
+	case SandboxNsjail:
+		return newNsjailSandbox(nsjailPath)
+	default:
+		return nil, fmt.Errorf("unknown sandbox backend %q", name)
+	}
+}
+
+// evaluateExecution turns the outcome of running a test case into a verdict,
+// returning the program output and an error message for the log
+func evaluateExecution(tc TestCase, config JudgeConfig, res ExecResult) (result Result, output string, errMsg string) {
+	output = strings.TrimSpace(res.Stdout)
+
+	result, errMsg = executionFailure(config, res)
+	if result != Accepted {
+		return result, output, errMsg
+	}
+
+	// Exit code 0, check against expected output
+	expectedOutputTrimmed := strings.TrimSpace(tc.Expected)
+	// Normalize line endings for comparison (replace \r\n with \n)
+	actualOutputNormalized := strings.ReplaceAll(output, "\r\n", "\n")
+	expectedOutputNormalized := strings.ReplaceAll(expectedOutputTrimmed, "\r\n", "\n")
+
+	if actualOutputNormalized != expectedOutputNormalized {
+		return WrongAnswer, output, "Output does not match expected output."
+	}
+	return Accepted, output, errMsg
+}
+
+// executionFailure reports how a program failed to run to completion, or
+// Accepted when it exited normally, together with a message for the log
+func executionFailure(config JudgeConfig, res ExecResult) (result Result, errMsg string) {
+	stderrOutput := strings.TrimSpace(res.Stderr)
+
+	if res.OutputLimitExceeded {
+		return OutputLimitExceeded, fmt.Sprintf("Output Limit Exceeded (> %d bytes)", config.OutputLimitBytes)
+	}
+
+	if res.TimedOut {
+		errMsg = fmt.Sprintf("Time Limit Exceeded (> %s)", config.TimeLimitPerCase)
+		if stderrOutput != "" {
+			errMsg += fmt.Sprintf("\nPartial Stderr:\n%s", stderrOutput)
+		}
+		return TimeLimit, errMsg
+	}
+
+	if res.Err != nil {
+		return RuntimeError, res.Err.Error()
+	}
+
+	if res.ExitCode != 0 {
+		// OOM Killer typically results in 137. Check if memory limit was set.
+		if res.ExitCode == 137 && config.MemoryLimitMB > 0 {
+			result = MemoryLimit
+			errMsg = fmt.Sprintf("Memory Limit Exceeded (%d MB, exit code %d)", config.MemoryLimitMB, res.ExitCode)
+		} else if res.ExitCode == 139 { // Segmentation fault
+			result = RuntimeError
+			errMsg = fmt.Sprintf("Runtime Error: Segmentation Fault (exit code %d)", res.ExitCode)
+		} else {
+			result = RuntimeError
+			errMsg = fmt.Sprintf("Runtime Error: Program exited with non-zero status code %d.", res.ExitCode)
+		}
+		if stderrOutput != "" {
+			errMsg += fmt.Sprintf("\nStderr:\n%s", stderrOutput)
+		}
+		return result, errMsg
+	}
+
+	return Accepted, res.Warning
+}
+
+// withTrailingNewline makes sure input ends with a newline, as most solutions
+// read line by line
+func withTrailingNewline(input string) string {
+	if !strings.HasSuffix(input, "\n") {
+		return input + "\n"
+	}
+	return input
+}
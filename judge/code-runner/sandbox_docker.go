@@ -0,0 +1,569 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// containerExecutablePath is where the compiled program is mounted inside the container
+const containerExecutablePath = "/app/program_to_run"
+
+// dockerSandbox runs programs in throwaway Docker containers. With the runsc
+// runtime the containers run under gVisor for stronger isolation.
+type dockerSandbox struct {
+	cli     *client.Client
+	runtime string // Empty for Docker's default runtime
+}
+
+func newDockerSandbox(runtime string) (*dockerSandbox, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Docker client: %w", err)
+	}
+	return &dockerSandbox{cli: cli, runtime: runtime}, nil
+}
+
+func (s *dockerSandbox) Name() string {
+	if s.runtime != "" {
+		return "docker (" + s.runtime + ")"
+	}
+	return "docker"
+}
+
+func (s *dockerSandbox) Close() error {
+	return s.cli.Close()
+}
+
+// Images known to exist, so submissions after the first skip the image lookup.
+// The mutex also keeps concurrent submissions from building the same image twice.
+var (
+	readyImages   = map[string]bool{}
+	readyImagesMu sync.Mutex
+)
+
+// Prepare makes sure the judging image exists, pulling a pinned runtime image
+// or building the embedded Dockerfile only when it is missing
+func (s *dockerSandbox) Prepare(ctx context.Context, config JudgeConfig, logWriter io.Writer) error {
+	return s.ensureImage(ctx, config, logWriter, false)
+}
+
+// ensureImage pulls or builds the judging image if it is missing. With force
+// an image from the embedded Dockerfile is rebuilt even if it exists; pinned
+// images never change, so they are only pulled once.
+func (s *dockerSandbox) ensureImage(ctx context.Context, config JudgeConfig, logWriter io.Writer, force bool) error {
+	readyImagesMu.Lock()
+	defer readyImagesMu.Unlock()
+
+	pinned := isPinnedImage(config.DockerImageName)
+	if !force || pinned {
+		if readyImages[config.DockerImageName] {
+			fmt.Fprintf(logWriter, "Using cached Docker image '%s'\n", config.DockerImageName)
+			return nil
+		}
+
+		exists, err := s.imageExists(ctx, config.DockerImageName)
+		if err != nil {
+			return err
+		}
+		if exists {
+			fmt.Fprintf(logWriter, "Docker image '%s' already exists\n", config.DockerImageName)
+			readyImages[config.DockerImageName] = true
+			return nil
+		}
+	}
+
+	if pinned {
+		fmt.Fprintf(logWriter, "Pulling runtime image '%s'...\n", config.DockerImageName)
+		if err := pullDockerImage(ctx, s.cli, config.DockerImageName, logWriter); err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprintf(logWriter, "Building Docker image '%s' from embedded Dockerfile string...\n", config.DockerImageName)
+		if err := buildDockerImageFromString(ctx, s.cli, config, logWriter); err != nil {
+			return err
+		}
+	}
+	readyImages[config.DockerImageName] = true
+	return nil
+}
+
+// imageExists reports whether the Docker daemon has the image ref
+func (s *dockerSandbox) imageExists(ctx context.Context, ref string) (bool, error) {
+	if isPinnedImage(ref) {
+		// The reference filter does not match digests, so look the image up directly
+		_, err := s.cli.ImageInspect(ctx, ref)
+		if err == nil {
+			return true, nil
+		}
+		if errdefs.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to inspect docker image: %w", err)
+	}
+
+	images, err := s.cli.ImageList(ctx, image.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("reference", ref)),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to list docker images: %w", err)
+	}
+	return len(images) > 0, nil
+}
+
+// pullDockerImage pulls a pinned runtime image. Docker checks the content
+// against the digest in ref, so a tampered or changed image fails the pull.
+func pullDockerImage(ctx context.Context, cli *client.Client, ref string, logWriter io.Writer) error {
+	options := image.PullOptions{}
+	if registryUsername != "" {
+		auth, err := registry.EncodeAuthConfig(registry.AuthConfig{Username: registryUsername, Password: registryPassword})
+		if err != nil {
+			return fmt.Errorf("failed to encode registry credentials: %w", err)
+		}
+		options.RegistryAuth = auth
+	}
+
+	resp, err := cli.ImagePull(ctx, ref, options)
+	if err != nil {
+		return fmt.Errorf("failed to initiate image pull request: %w", err)
+	}
+	defer resp.Close()
+
+	fmt.Fprintln(logWriter, "--- Docker Pull Output ---")
+	pullLog, err := readDockerStream(resp, logWriter)
+	fmt.Fprintln(logWriter, "--- End Docker Pull Output ---")
+	if err != nil {
+		return fmt.Errorf("docker pull failed: %w. Full log:\n%s", err, pullLog)
+	}
+	return nil
+}
+
+// buildDockerImageFromString builds a Docker image from the Dockerfile string.
+// Added io.Writer for logging build output.
+func buildDockerImageFromString(ctx context.Context, cli *client.Client, config JudgeConfig, logWriter io.Writer) error {
+	tarBuf := new(bytes.Buffer)
+	tw := tar.NewWriter(tarBuf)
+	// No need to defer tw.Close() here, it's closed explicitly before reading
+
+	header := &tar.Header{
+		Name:    "Dockerfile",
+		Size:    int64(len(dockerfileContent)),
+		Mode:    0644,
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write tar header for Dockerfile: %w", err)
+	}
+	if _, err := tw.Write([]byte(dockerfileContent)); err != nil {
+		// If write fails, still try to close to release resources, then return write error
+		tw.Close()
+		return fmt.Errorf("failed to write Dockerfile content to tar: %w", err)
+	}
+	// Close the tar writer *before* using the buffer
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to close tar writer: %w", err)
+	}
+
+	dockerBuildContext := bytes.NewReader(tarBuf.Bytes())
+	options := types.ImageBuildOptions{
+		Tags:        []string{config.DockerImageName},
+		Dockerfile:  "Dockerfile", // Refers to the Dockerfile within the tar context
+		Remove:      true,         // Attempt to remove intermediate containers
+		ForceRemove: true,         // Force removal of intermediate containers
+		Labels:      map[string]string{judgeLabel: "true"},
+		// Consider adding NoCache: true if needed during development
+	}
+	resp, err := cli.ImageBuild(ctx, dockerBuildContext, options)
+	if err != nil {
+		return fmt.Errorf("failed to initiate image build request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Stream build output to the provided logWriter
+	fmt.Fprintln(logWriter, "--- Docker Build Output ---")
+	buildLog, err := readDockerStream(resp.Body, logWriter)
+	fmt.Fprintln(logWriter, "--- End Docker Build Output ---")
+	if err != nil {
+		return fmt.Errorf("docker build failed: %w. Full log:\n%s", err, buildLog)
+	}
+
+	// If no errors were detected, return nil
+	return nil
+}
+
+// readDockerStream copies the JSON progress messages of a build or pull to
+// logWriter and returns them along with the last error Docker reported
+func readDockerStream(body io.Reader, logWriter io.Writer) (string, error) {
+	outputBuf := new(bytes.Buffer) // Capture the output separately for error reporting
+	logAndCaptureWriter := io.MultiWriter(logWriter, outputBuf)
+
+	scanner := bufio.NewScanner(body)
+	var streamErr error // Variable to store potential JSON error message from Docker daemon
+	for scanner.Scan() {
+		line := scanner.Text()
+		fmt.Fprintln(logAndCaptureWriter, line) // Write line to main log and capture buffer
+
+		// Try to detect errors reported in the JSON stream from Docker
+		var msg struct {
+			Error       string `json:"error"`
+			ErrorDetail struct {
+				Message string `json:"message"`
+			} `json:"errorDetail"`
+		}
+		if json.Unmarshal([]byte(line), &msg) == nil {
+			if msg.Error != "" {
+				streamErr = errors.New(msg.Error)
+				// Don't break, continue reading the full log
+			} else if msg.ErrorDetail.Message != "" {
+				streamErr = errors.New(msg.ErrorDetail.Message)
+				// Don't break, continue reading the full log
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return outputBuf.String(), fmt.Errorf("error reading docker output stream: %w", err)
+	}
+	return outputBuf.String(), streamErr
+}
+
+// Run runs a single test case in a Docker container.
+func (s *dockerSandbox) Run(ctx context.Context, hostExecutablePath string, input string, config JudgeConfig, logWriter io.Writer) ExecResult {
+	// Increase parent context timeout slightly to allow for cleanup
+	ctx, cancel := context.WithTimeout(ctx, config.TimeLimitPerCase+10*time.Second)
+	defer cancel()
+
+	// Use a specific logger for this function's internal steps
+	logf := func(format string, args ...interface{}) {
+		fmt.Fprintf(logWriter, " [ContainerRunner] "+format+"\n", args...)
+	}
+
+	absExecutablePath, err := filepath.Abs(hostExecutablePath)
+	if err != nil {
+		return ExecResult{Err: fmt.Errorf("error getting absolute path for executable: %w", err)}
+	}
+
+	containerConfig := &container.Config{
+		Image:       config.DockerImageName,
+		Cmd:         []string{containerExecutablePath}, // Command to run inside
+		Labels:      map[string]string{judgeLabel: "true"},
+		AttachStdin: true, AttachStdout: true, AttachStderr: true,
+		Tty:        false,     // Important for non-interactive execution
+		OpenStdin:  true,      // Keep stdin open to write input
+		StdinOnce:  true,      // Close stdin after first write (standard for competitive programming)
+		User:       "appuser", // Run as non-root user specified in Dockerfile
+		WorkingDir: "/app",    // Working directory inside container
+	}
+	hostConfig := &container.HostConfig{
+		Runtime: s.runtime,
+		Mounts: []mount.Mount{
+			{
+				Type:     mount.TypeBind,          // Bind mount the executable
+				Source:   absExecutablePath,       // Path on the host
+				Target:   containerExecutablePath, // Path inside the container
+				ReadOnly: true,                    // Mount read-only for security
+			},
+		},
+		NetworkMode: "none",                        // Disable networking for security
+		SecurityOpt: []string{"no-new-privileges"}, // Prevent privilege escalation
+		Resources: container.Resources{
+			// Memory limit in bytes. MemorySwap = Memory enforces no swap usage.
+			Memory: int64(config.MemoryLimitMB) * 1024 * 1024,
+			// Setting MemorySwap to the same value as Memory disables swap usage effectively.
+			// Set to -1 to allow unlimited swap (not recommended for judging).
+			MemorySwap: int64(config.MemoryLimitMB) * 1024 * 1024,
+			// CPU limit in units of 1e9 nanoCPUs (e.g., 1.0 * 1e9 = 1 full core)
+			NanoCPUs: int64(config.CPUCount * 1e9),
+			// Cores the container may run on, any when empty
+			CpusetCpus: config.CPUSet,
+			// Consider adding PidsLimit if needed
+		},
+	}
+
+	if config.NoCPUQuota {
+		// The pinned cores are the limit; a quota would only throttle the program
+		hostConfig.Resources.NanoCPUs = 0
+	}
+
+	logf("Creating container with image '%s'...", config.DockerImageName)
+	resp, err := s.cli.ContainerCreate(ctx, containerConfig, hostConfig, nil, nil, "") // Auto-generates container name
+	if err != nil {
+		return ExecResult{Err: fmt.Errorf("Failed to create container: %v", err)}
+	}
+	containerID := resp.ID
+	logf("Container created: %s", containerID)
+
+	// Defer container stop and removal
+	defer func() {
+		// Cleanup outlives ctx, but stays in its trace
+		stopCtx, stopCancel := context.WithTimeout(context.WithoutCancel(ctx), 15*time.Second) // Generous timeout for cleanup
+		defer stopCancel()
+
+		logf("Stopping container %s...", containerID)
+		// Use a short timeout for stop, otherwise force remove later
+		stopTimeoutSecs := 2
+		stopErr := s.cli.ContainerStop(stopCtx, containerID, container.StopOptions{Timeout: &stopTimeoutSecs})
+		if stopErr != nil && !client.IsErrNotFound(stopErr) && !strings.Contains(stopErr.Error(), "is already stopped") {
+			logf("Warning: Failed to stop container %s gracefully: %v. Will force remove.", containerID, stopErr)
+		} else if stopErr == nil {
+			logf("Container %s stopped.", containerID)
+		}
+
+		logf("Removing container %s...", containerID)
+		removeOpts := container.RemoveOptions{
+			Force:         true,  // Force removal if stop failed or it's stuck
+			RemoveVolumes: false, // We didn't create volumes, but good practice
+		}
+		if removeErr := s.cli.ContainerRemove(stopCtx, containerID, removeOpts); removeErr != nil && !client.IsErrNotFound(removeErr) {
+			// Log error but don't fail the entire judge process just for cleanup failure
+			logf("Warning: Failed to remove container %s: %v", containerID, removeErr)
+		} else if removeErr == nil {
+			logf("Container %s removed.", containerID)
+		}
+	}()
+
+	// Attach to container streams before starting
+	attachOptions := container.AttachOptions{Stream: true, Stdin: true, Stdout: true, Stderr: true}
+	logf("Attaching to container %s streams...", containerID)
+	hijackedResp, err := s.cli.ContainerAttach(ctx, containerID, attachOptions)
+	if err != nil {
+		return ExecResult{Err: fmt.Errorf("Failed to attach to container %s: %v", containerID, err)}
+	}
+	defer hijackedResp.Close() // Close the connection when done
+
+	// Start the container
+	logf("Starting container %s...", containerID)
+	startCtx, startCancel := context.WithTimeout(ctx, 5*time.Second) // Timeout for start itself
+	err = s.cli.ContainerStart(startCtx, containerID, container.StartOptions{})
+	startCancel() // Release start context resources
+	if err != nil {
+		// Check if the error is context deadline exceeded from the *parent* context
+		if ctx.Err() == context.DeadlineExceeded {
+			return ExecResult{TimedOut: true}
+		}
+		// Check specifically if the start timed out
+		if err == context.DeadlineExceeded { // This checks startCtx timeout
+			return ExecResult{Err: fmt.Errorf("Timed out starting container %s: %v", containerID, err)}
+		}
+		if client.IsErrNotFound(err) {
+			return ExecResult{Err: fmt.Errorf("Failed to start container %s: container not found (possible premature removal?)", containerID)}
+		}
+		return ExecResult{Err: fmt.Errorf("Failed to start container %s: %v", containerID, err)}
+	}
+	logf("Container %s started and attached.", containerID)
+	started := time.Now()
+	sampleCtx, stopSampling := context.WithCancel(ctx)
+	defer stopSampling()
+	sampler := s.sampleUsage(sampleCtx, containerID)
+	// usage stops sampling once the program is done and adds its wall time
+	usage := func() ResourceUsage {
+		wallTime := time.Since(started)
+		stopSampling()
+		usage := sampler.wait()
+		usage.WallTime = wallTime
+		return usage
+	}
+
+	// Goroutine to write input to container's stdin
+	go func() {
+		defer func() {
+			// Close the write half of the connection to signal EOF to the container process
+			if err := hijackedResp.CloseWrite(); err != nil {
+				// Ignore "use of closed network connection" as it's expected if context cancels early
+				if !strings.Contains(err.Error(), "use of closed network connection") && !strings.Contains(err.Error(), "file already closed") {
+					logf("Warning: Error closing write stream for container %s: %v", containerID, err)
+				}
+			}
+			logf("Input goroutine finished for %s.", containerID)
+		}()
+
+		logf("Writing input to container %s stdin...", containerID)
+		written, err := io.WriteString(hijackedResp.Conn, withTrailingNewline(input))
+		if err != nil {
+			// Ignore ErrClosedPipe which can happen if container exits before reading all input
+			if err != io.ErrClosedPipe && !strings.Contains(err.Error(), "use of closed network connection") {
+				logf("Warning: Failed to write input to container %s (%d bytes written): %v", containerID, written, err)
+			} else {
+				logf("Input stream closed while writing to %s (container likely exited). Bytes written: %d", containerID, written)
+			}
+		} else {
+			logf("Successfully wrote %d bytes of input to %s.", written, containerID)
+		}
+	}()
+
+	// Goroutine to copy stdout/stderr from container
+	outputLimitCh := make(chan struct{})
+	stdoutBuf, stderrBuf := newOutputBuffers(config.OutputLimitBytes, func() { close(outputLimitCh) })
+	outputErrChan := make(chan error, 1)
+	go func() {
+		logf("Starting output stream copy for %s...", containerID)
+		// stdcopy.StdCopy demultiplexes the stream into separate stdout/stderr buffers
+		_, err := stdcopy.StdCopy(stdoutBuf, stderrBuf, hijackedResp.Reader)
+		outputErrChan <- err // Send error (or nil) when copying finishes
+		logf("Output stream copy finished for %s. Error (if any): %v", containerID, err)
+	}()
+
+	// waitForOutput waits a short while for the output copy to finish
+	waitForOutput := func() string {
+		select {
+		case copyErr := <-outputErrChan:
+			if copyErr != nil && copyErr != io.EOF {
+				// Log error but proceed, output might be incomplete
+				logf("Warning: Error reading container output streams for %s: %v", containerID, copyErr)
+				return fmt.Sprintf("\nWarning: Error reading container output: %v", copyErr)
+			}
+			logf("Output streams copied successfully for %s.", containerID)
+		case <-time.After(5 * time.Second):
+			logf("Warning: Timed out waiting for output stream copy to finish for container %s. Output might be incomplete.", containerID)
+			return "\nWarning: Timed out reading full container output."
+		}
+		return ""
+	}
+
+	// Wait for container to exit or timeout
+	// Use a specific timeout context based on the *test case time limit*
+	waitCtx, waitCancel := context.WithTimeout(ctx, config.TimeLimitPerCase)
+	defer waitCancel() // Ensure wait context is cancelled
+
+	statusCh, waitErrCh := s.cli.ContainerWait(waitCtx, containerID, container.WaitConditionNotRunning)
+
+	logf("Waiting for container %s to exit (Timeout: %s)...", containerID, config.TimeLimitPerCase)
+
+	select {
+	case <-outputLimitCh:
+		logf("Container %s exceeded the output limit (%d bytes).", containerID, config.OutputLimitBytes)
+		runUsage := usage()
+		// Stop the container so the output stream closes instead of draining until the time limit
+		stopTimeoutSecs := 0
+		s.cli.ContainerStop(context.WithoutCancel(ctx), containerID, container.StopOptions{Timeout: &stopTimeoutSecs})
+		waitForOutput()
+		return ExecResult{Stdout: stdoutBuf.String(), Stderr: stderrBuf.String(), OutputLimitExceeded: true, Usage: runUsage}
+
+	case err := <-waitErrCh:
+		// Error occurred while waiting (could be context cancelled, Docker daemon issue)
+		if waitCtx.Err() == context.DeadlineExceeded || ctx.Err() == context.DeadlineExceeded {
+			logf("Container %s hit time limit (%s).", containerID, config.TimeLimitPerCase)
+			runUsage := usage()
+			// Stop the container so the output stream closes and partial output can be read
+			stopTimeoutSecs := 0
+			s.cli.ContainerStop(context.WithoutCancel(ctx), containerID, container.StopOptions{Timeout: &stopTimeoutSecs})
+			waitForOutput()
+			return ExecResult{Stdout: stdoutBuf.String(), Stderr: stderrBuf.String(), TimedOut: true, Usage: runUsage}
+		}
+		logf("Error waiting for container %s: %v", containerID, err)
+		waitForOutput()
+		return ExecResult{Stdout: stdoutBuf.String(), Err: fmt.Errorf("Error waiting for container: %v", err)}
+
+	case status := <-statusCh:
+		// Container exited normally (status code might be non-zero)
+		logf("Container %s exited with status code: %d. Docker Error Msg: '%s'", containerID, status.StatusCode, status.Error)
+		runUsage := usage()
+
+		// Wait for the output streaming goroutine to finish copying *after* container exits.
+		warning := waitForOutput()
+		return ExecResult{
+			Stdout:   stdoutBuf.String(),
+			Stderr:   stderrBuf.String(),
+			ExitCode: int(status.StatusCode),
+			// The program may have exited before the limit was noticed
+			OutputLimitExceeded: stdoutBuf.Exceeded() || stderrBuf.Exceeded(),
+			Warning:             strings.TrimSpace(warning),
+			Usage:               runUsage,
+		}
+	}
+}
+
+// usageSampler follows the stats of a running container. Docker sends a
+// sample about once a second, so a program that exits sooner may be seen
+// once or not at all; the wall time is always measured.
+type usageSampler struct {
+	mu    sync.Mutex
+	usage ResourceUsage
+	done  chan struct{}
+}
+
+// sampleUsage follows the stats of a container until ctx is cancelled or the
+// container stops
+func (s *dockerSandbox) sampleUsage(ctx context.Context, containerID string) *usageSampler {
+	sampler := &usageSampler{done: make(chan struct{})}
+	go func() {
+		defer close(sampler.done)
+		stats, err := s.cli.ContainerStats(ctx, containerID, true)
+		if err != nil {
+			return
+		}
+		defer stats.Body.Close()
+		decoder := json.NewDecoder(stats.Body)
+		for {
+			var sample container.StatsResponse
+			if err := decoder.Decode(&sample); err != nil {
+				return
+			}
+			sampler.add(&sample)
+		}
+	}()
+	return sampler
+}
+
+// add merges a sample into the usage. Every figure only grows while the
+// program runs, and a stopped container reports zeros, so the highest value
+// seen is kept for each.
+func (u *usageSampler) add(sample *container.StatsResponse) {
+	// Like docker stats, leave out page cache the kernel can reclaim
+	memory := sample.MemoryStats.Usage
+	inactive := sample.MemoryStats.Stats["inactive_file"] // cgroup v2
+	if v1, ok := sample.MemoryStats.Stats["total_inactive_file"]; ok {
+		inactive = v1
+	}
+	if inactive < memory {
+		memory -= inactive
+	}
+
+	var read, write uint64
+	for _, entry := range sample.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			read += entry.Value
+		case "write":
+			write += entry.Value
+		}
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.usage.CPUTime = max(u.usage.CPUTime, time.Duration(sample.CPUStats.CPUUsage.TotalUsage))
+	u.usage.PeakMemoryBytes = max(u.usage.PeakMemoryBytes, memory, sample.MemoryStats.MaxUsage)
+	u.usage.IOReadBytes = max(u.usage.IOReadBytes, read)
+	u.usage.IOWriteBytes = max(u.usage.IOWriteBytes, write)
+}
+
+// wait returns the usage once sampling has stopped, or what was seen so far
+// if the stats stream does not close promptly
+func (u *usageSampler) wait() ResourceUsage {
+	select {
+	case <-u.done:
+	case <-time.After(2 * time.Second):
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.usage
+}
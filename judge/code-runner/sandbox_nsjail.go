@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nsjailSandbox runs programs directly on the host inside nsjail namespaces,
+// with memory and process limits enforced through cgroups. It avoids the
+// container start-up cost of Docker but requires nsjail on the runner host.
+type nsjailSandbox struct {
+	binary string
+}
+
+func newNsjailSandbox(binary string) (*nsjailSandbox, error) {
+	path, err := exec.LookPath(binary)
+	if err != nil {
+		return nil, fmt.Errorf("nsjail binary %q not found: %w", binary, err)
+	}
+	return &nsjailSandbox{binary: path}, nil
+}
+
+func (s *nsjailSandbox) Name() string { return "nsjail" }
+
+func (s *nsjailSandbox) Close() error { return nil }
+
+// Prepare has nothing to build, the program runs on a read-only view of the host
+func (s *nsjailSandbox) Prepare(ctx context.Context, config JudgeConfig, logWriter io.Writer) error {
+	return nil
+}
+
+// Run runs a single test case under nsjail
+func (s *nsjailSandbox) Run(ctx context.Context, hostExecutablePath string, input string, config JudgeConfig, logWriter io.Writer) ExecResult {
+	logf := func(format string, args ...interface{}) {
+		fmt.Fprintf(logWriter, " [NsjailRunner] "+format+"\n", args...)
+	}
+
+	absExecutablePath, err := filepath.Abs(hostExecutablePath)
+	if err != nil {
+		return ExecResult{Err: fmt.Errorf("error getting absolute path for executable: %w", err)}
+	}
+
+	// nsjail only accepts whole seconds, so our own deadline enforces the exact limit
+	jailSeconds := int(math.Ceil(config.TimeLimitPerCase.Seconds())) + 1
+	args := []string{
+		"--mode", "o",
+		"--quiet",
+		"--chroot", "/",
+		"--user", "65534",
+		"--group", "65534",
+		"--cwd", "/tmp",
+		"--bindmount_ro", absExecutablePath + ":" + containerExecutablePath,
+		"--time_limit", strconv.Itoa(jailSeconds),
+		"--cgroup_pids_max", "64",
+		"--disable_proc",
+	}
+	if config.MemoryLimitMB > 0 {
+		args = append(args, "--cgroup_mem_max", strconv.FormatUint(config.MemoryLimitMB*1024*1024, 10))
+	}
+	if config.CPUCount > 0 && !config.NoCPUQuota {
+		// cgroup_cpu_ms_per_sec is the CPU time allowed per wall second
+		args = append(args, "--cgroup_cpu_ms_per_sec", strconv.Itoa(int(config.CPUCount*1000)))
+	}
+	args = append(args, "--", containerExecutablePath)
+
+	runCtx, cancel := context.WithTimeout(ctx, config.TimeLimitPerCase)
+	defer cancel()
+
+	// Flooding the output kills the program right away instead of waiting for the time limit
+	stdoutBuf, stderrBuf := newOutputBuffers(config.OutputLimitBytes, cancel)
+	cmd := exec.CommandContext(runCtx, s.binary, args...)
+	if config.CPUSet != "" {
+		// The jailed program inherits the affinity taskset gives nsjail
+		cmd = exec.CommandContext(runCtx, "taskset", append([]string{"--cpu-list", config.CPUSet, s.binary}, args...)...)
+	}
+	cmd.Stdin = strings.NewReader(withTrailingNewline(input))
+	cmd.Stdout = stdoutBuf
+	cmd.Stderr = stderrBuf
+	cmd.WaitDelay = 5 * time.Second
+
+	logf("Running %s", cmd.String())
+	started := time.Now()
+	err = cmd.Run()
+	var usage ResourceUsage
+	if cmd.ProcessState != nil {
+		// nsjail waits for the program, so its usage includes the program's
+		usage = processUsage(cmd.ProcessState)
+	}
+	usage.WallTime = time.Since(started)
+
+	if stdoutBuf.Exceeded() || stderrBuf.Exceeded() {
+		logf("Program exceeded the output limit (%d bytes).", config.OutputLimitBytes)
+		return ExecResult{Stdout: stdoutBuf.String(), Stderr: stderrBuf.String(), OutputLimitExceeded: true, Usage: usage}
+	}
+
+	if runCtx.Err() == context.DeadlineExceeded {
+		logf("Program hit time limit (%s).", config.TimeLimitPerCase)
+		return ExecResult{Stdout: stdoutBuf.String(), Stderr: stderrBuf.String(), TimedOut: true, Usage: usage}
+	}
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return ExecResult{Err: fmt.Errorf("Failed to run nsjail: %v", err)}
+	}
+
+	exitCode := 0
+	if exitErr != nil {
+		exitCode = exitErr.ExitCode()
+	}
+	logf("Program exited with status code: %d.", exitCode)
+
+	return ExecResult{
+		Stdout:   stdoutBuf.String(),
+		Stderr:   stderrBuf.String(),
+		ExitCode: exitCode,
+		Usage:    usage,
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	pb "goera/code-runner/internalpb"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// verdicts maps results to their wire form
+var verdicts = map[Result]pb.Verdict{
+	Accepted:            pb.Verdict_VERDICT_ACCEPTED,
+	CompileError:        pb.Verdict_VERDICT_COMPILE_ERROR,
+	WrongAnswer:         pb.Verdict_VERDICT_WRONG_ANSWER,
+	MemoryLimit:         pb.Verdict_VERDICT_MEMORY_LIMIT,
+	TimeLimit:           pb.Verdict_VERDICT_TIME_LIMIT,
+	RuntimeError:        pb.Verdict_VERDICT_RUNTIME_ERROR,
+	OutputLimitExceeded: pb.Verdict_VERDICT_OUTPUT_LIMIT_EXCEEDED,
+}
+
+func (e Event) proto() *pb.Event {
+	return &pb.Event{
+		Source:  e.Source,
+		Type:    e.Type,
+		Message: e.Message,
+		Time:    timestamppb.New(e.Time),
+	}
+}
+
+// runnerServer is the code-runner's gRPC service, called by the judge
+type runnerServer struct {
+	pb.UnimplementedRunnerServiceServer
+}
+
+// writeSource writes source code to a temporary file and returns its path.
+// The caller removes it.
+func writeSource(pattern, sourceCode string) (string, error) {
+	tmpSrc, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", status.Error(codes.Internal, "Failed to create temp file for source")
+	}
+	defer tmpSrc.Close()
+	if _, err := tmpSrc.WriteString(sourceCode); err != nil {
+		os.Remove(tmpSrc.Name())
+		return "", status.Error(codes.Internal, "Failed to write source code")
+	}
+	return tmpSrc.Name(), nil
+}
+
+// writeModule writes the files of a multi-file job to a temporary directory
+// and returns its path. The caller removes it.
+func writeModule(files map[string]string) (string, error) {
+	dir, err := os.MkdirTemp("", "module-*")
+	if err != nil {
+		return "", status.Error(codes.Internal, "Failed to create temp directory for source")
+	}
+	for name, content := range files {
+		path := filepath.FromSlash(name)
+		if !filepath.IsLocal(path) {
+			os.RemoveAll(dir)
+			return "", status.Errorf(codes.InvalidArgument, "Invalid source file path %q", name)
+		}
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			os.RemoveAll(dir)
+			return "", status.Error(codes.Internal, "Failed to create source directory")
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			os.RemoveAll(dir)
+			return "", status.Error(codes.Internal, "Failed to write source code")
+		}
+	}
+	return dir, nil
+}
+
+// sourceSize is the size of a job's source code, over all of its files
+func sourceSize(job *pb.Job) int {
+	size := len(job.GetSourceCode())
+	for _, content := range job.GetFiles() {
+		size += len(content)
+	}
+	return size
+}
+
+// RunJob judges a job, sending each event of its timeline as it happens and
+// the result last
+func (s *runnerServer) RunJob(req *pb.RunJobRequest, stream pb.RunnerService_RunJobServer) error {
+	job := req.GetJob()
+	if sourceSize(job) > maxSourceBytes {
+		return status.Errorf(codes.ResourceExhausted, "Source code exceeds the maximum size of %d bytes", maxSourceBytes)
+	}
+	testCases := make([]TestCase, 0, len(job.GetTestCases()))
+	for i, tc := range job.GetTestCases() {
+		if len(tc.GetInput())+len(tc.GetExpectedOutput()) > maxTestCaseBytes {
+			return status.Errorf(codes.ResourceExhausted, "Test case %d exceeds the maximum size of %d bytes", i+1, maxTestCaseBytes)
+		}
+		testCases = append(testCases, TestCase{
+			Input:       tc.GetInput(),
+			Expected:    tc.GetExpectedOutput(),
+			InputURL:    tc.GetInputUrl(),
+			ExpectedURL: tc.GetExpectedOutputUrl(),
+		})
+	}
+
+	config, err := limitsConfig(job.GetLimits())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if config.BuildArgs, err = buildArgs(job.GetBuildFlags()); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var sourcePath string
+	if len(job.GetFiles()) > 0 {
+		sourcePath, err = writeModule(job.GetFiles())
+	} else {
+		sourcePath, err = writeSource("source-*.go", job.GetSourceCode())
+	}
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(sourcePath)
+	config.SourceFilePath = sourcePath
+	config.TestCases = testCases
+	config.OutputLimitBytes = maxOutputBytes
+
+	// Wait for a worker slot; the judge stops waiting by cancelling the call
+	ctx := stream.Context()
+	release, cores, err := defaultWorkerPool.acquire(ctx, config.MemoryLimitMB, config.CPUCount)
+	if err != nil {
+		return status.FromContextError(err).Err()
+	}
+	defer release()
+	pinJudgment(&config, cores)
+
+	// Events are streamed as they happen. A failed send means the judge went
+	// away, which also cancels ctx and so stops the judging.
+	events := EventLog{OnAdd: func(event Event) {
+		stream.Send(&pb.RunJobUpdate{Update: &pb.RunJobUpdate_Event{Event: event.proto()}})
+	}}
+	if job.GetCompileOnly() {
+		result, output := compileOnly(ctx, sourcePath, config.BuildArgs, &events)
+		return stream.Send(&pb.RunJobUpdate{Update: &pb.RunJobUpdate_Result{Result: &pb.JobResult{Verdict: verdicts[result], Output: output}}})
+	}
+
+	var stderrs []TestCaseStderr
+	var usages []TestCaseUsage
+	result, output, err := runJudge(ctx, config, &events, &stderrs, &usages)
+	if err != nil {
+		return status.Errorf(codes.Internal, "Internal judge error: %v\nOutput Log:\n%s", err, output)
+	}
+
+	jobResult := &pb.JobResult{Verdict: verdicts[result], Output: output}
+	for _, stderr := range stderrs {
+		jobResult.Stderr = append(jobResult.Stderr, &pb.TestCaseStderr{
+			TestCase:  int32(stderr.TestCase),
+			Output:    stderr.Output,
+			Truncated: stderr.Truncated,
+		})
+	}
+	for _, usage := range usages {
+		jobResult.Usage = append(jobResult.Usage, &pb.TestCaseUsage{
+			TestCase:        int32(usage.TestCase),
+			WallTimeMs:      usage.WallTime.Milliseconds(),
+			CpuTimeMs:       usage.CPUTime.Milliseconds(),
+			PeakMemoryBytes: usage.PeakMemoryBytes,
+			IoReadBytes:     usage.IOReadBytes,
+			IoWriteBytes:    usage.IOWriteBytes,
+		})
+	}
+	return stream.Send(&pb.RunJobUpdate{Update: &pb.RunJobUpdate_Result{Result: jobResult}})
+}
+
+// Generate compiles a generator and runs it in the sandbox on each of the
+// inputs, returning the raw stdout of every run
+func (s *runnerServer) Generate(ctx context.Context, req *pb.GenerateRequest) (*pb.GenerateResponse, error) {
+	if len(req.GetSourceCode()) > maxSourceBytes {
+		return nil, status.Errorf(codes.ResourceExhausted, "Source code exceeds the maximum size of %d bytes", maxSourceBytes)
+	}
+
+	config, err := limitsConfig(req.GetLimits())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	// A generated test case can never be larger than a test case is allowed to be
+	config.OutputLimitBytes = maxTestCaseBytes
+
+	sourcePath, err := writeSource("generator-*.go", req.GetSourceCode())
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(sourcePath)
+	config.SourceFilePath = sourcePath
+
+	release, cores, err := defaultWorkerPool.acquire(ctx, config.MemoryLimitMB, config.CPUCount)
+	if err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	defer release()
+	pinJudgment(&config, cores)
+
+	result, err := runGenerator(ctx, config, req.GetInputs())
+	if err != nil {
+		return nil, status.Error(codes.Internal, fmt.Sprintf("Internal judge error: %v\nOutput Log:\n%s", err, result.Output))
+	}
+	return &pb.GenerateResponse{
+		Verdict: verdicts[result.Status],
+		Output:  result.Output,
+		Outputs: result.Outputs,
+	}, nil
+}
+
+// RunnerStatus reports the free worker slots so the judge knows how many
+// submissions it can send to this code-runner
+func (s *runnerServer) RunnerStatus(ctx context.Context, req *pb.RunnerStatusRequest) (*pb.RunnerStatusResponse, error) {
+	pool := defaultWorkerPool.status()
+	return &pb.RunnerStatusResponse{
+		Workers:          int32(pool.Workers),
+		Busy:             int32(pool.Busy),
+		Available:        int32(pool.Available),
+		Waiting:          int32(pool.Waiting),
+		MemoryMb:         pool.MemoryMB,
+		MaxTotalMemoryMb: pool.MaxTotalMemoryMB,
+		Cpus:             pool.CPUs,
+		MaxTotalCpus:     pool.MaxTotalCPUs,
+	}, nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"goera/proto/signing"
+)
+
+// Keys accepted on requests from the judge
+var (
+	internalKeys = map[string]string{}
+	maxClockSkew = 5 * time.Minute
+)
+
+// signer verifies the calls from the judge, unary ones and streams by their
+// first message
+var signer = signing.New(func() signing.Keys {
+	return signing.Keys{Secrets: internalKeys, MaxSkew: maxClockSkew}
+})
+
+// loadInternalKeys reads the verification keys from INTERNAL_HMAC_KEYS (id:secret
+// pairs separated by commas), falling back to INTERNAL_API_KEY. Keys from the
+// environment replace those from the config file.
+func loadInternalKeys() {
+	if value := os.Getenv("INTERNAL_HMAC_KEYS"); value != "" {
+		internalKeys, _ = signing.ParseKeys(value)
+	}
+
+	if len(internalKeys) == 0 {
+		if legacyKey := os.Getenv("INTERNAL_API_KEY"); legacyKey != "" {
+			internalKeys["default"] = legacyKey
+		}
+	}
+
+	if seconds, err := strconv.Atoi(os.Getenv("INTERNAL_SIGNATURE_MAX_SKEW_SECONDS")); err == nil && seconds > 0 {
+		maxClockSkew = time.Duration(seconds) * time.Second
+	}
+
+	if len(internalKeys) == 0 {
+		log.Println("Warning: no internal signing key configured, all requests will be rejected")
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// testDataClient downloads test data serve keeps in file storage. The links
+// are signed and expire, so they carry no credentials of the code-runner.
+var testDataClient = &http.Client{Timeout: time.Minute}
+
+// fetchTestData downloads the test data behind a link, refusing anything
+// larger than a test case is allowed to be
+func fetchTestData(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := testDataClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download failed with %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(maxTestCaseBytes)+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxTestCaseBytes {
+		return "", fmt.Errorf("test data exceeds the maximum size of %d bytes", maxTestCaseBytes)
+	}
+	return string(data), nil
+}
+
+// load downloads the input and expected output of a test case kept in file
+// storage. It is done right before the test case runs, so only one of them
+// is held in memory at a time.
+func (tc *TestCase) load(ctx context.Context) error {
+	if tc.InputURL != "" {
+		input, err := fetchTestData(ctx, tc.InputURL)
+		if err != nil {
+			return fmt.Errorf("failed to download input: %w", err)
+		}
+		tc.Input = input
+	}
+	if tc.ExpectedURL != "" {
+		expected, err := fetchTestData(ctx, tc.ExpectedURL)
+		if err != nil {
+			return fmt.Errorf("failed to download expected output: %w", err)
+		}
+		tc.Expected = expected
+	}
+	if len(tc.Input)+len(tc.Expected) > maxTestCaseBytes {
+		return fmt.Errorf("test case exceeds the maximum size of %d bytes", maxTestCaseBytes)
+	}
+	return nil
+}
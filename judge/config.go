@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"goera/proto/signing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultConfigFile is read when GOERA_CONFIG is not set and the file exists
+const defaultConfigFile = "goera.yaml"
+
+// Settings read from the config file and environment variables
+var (
+	listenAddr = "8080"
+	ServeAddr  = "serve:5001" // serve's gRPC listener
+	runnerPath = "./code-runner/code-runner"
+
+	runnerMode       = launchProcess
+	runnerImage      = ""
+	runnerDockerArgs = "" // Extra docker run arguments, split on spaces
+)
+
+// fileConfig is the layout of the shared config file. The judge only reads
+// the judge section; the other sections belong to serve and the code-runner.
+type fileConfig struct {
+	Serve      yaml.Node       `yaml:"serve"`
+	Judge      judgeFileConfig `yaml:"judge"`
+	CodeRunner yaml.Node       `yaml:"code_runner"`
+}
+
+type judgeFileConfig struct {
+	Listen    string `yaml:"listen"`
+	ServeAddr string `yaml:"serve_addr"`
+
+	Runner struct {
+		Mode       string `yaml:"mode"`
+		Path       string `yaml:"path"`
+		Image      string `yaml:"image"`
+		DockerArgs string `yaml:"docker_args"`
+		BasePort   int    `yaml:"base_port"`
+	} `yaml:"runner"`
+
+	Queue struct {
+		PriorityWeight int           `yaml:"priority_weight"`
+		MaxNormalWait  time.Duration `yaml:"max_normal_wait"`
+	} `yaml:"queue"`
+
+	Autoscale struct {
+		MaxRunners     int           `yaml:"max_runners"`
+		QueueThreshold int           `yaml:"queue_threshold"`
+		IdleCooldown   time.Duration `yaml:"idle_cooldown"`
+	} `yaml:"autoscale"`
+
+	Internal struct {
+		HMACKeys         string        `yaml:"hmac_keys"` // Comma separated id:secret pairs, as INTERNAL_HMAC_KEYS
+		HMACKeyID        string        `yaml:"hmac_key_id"`
+		SignatureMaxSkew time.Duration `yaml:"signature_max_skew"`
+	} `yaml:"internal"`
+
+	Tracing struct {
+		Endpoint    string  `yaml:"endpoint"`
+		SampleRatio float64 `yaml:"sample_ratio"`
+	} `yaml:"tracing"`
+}
+
+// loadConfig applies the config file, if there is one, and then the
+// environment variables, which take precedence over the file. Command line
+// flags default to the result and so override both.
+func loadConfig() error {
+	path := os.Getenv("GOERA_CONFIG")
+	if path == "" {
+		if _, err := os.Stat(defaultConfigFile); err == nil {
+			path = defaultConfigFile
+		}
+	}
+	if path != "" {
+		if err := loadConfigFile(path); err != nil {
+			return err
+		}
+	}
+
+	if value := os.Getenv("JUDGE_LISTEN"); value != "" {
+		listenAddr = value
+	}
+	if value := os.Getenv("SERVE_ADDR"); value != "" {
+		ServeAddr = value
+	}
+	if value := os.Getenv("CODE_RUNNER_MODE"); value != "" {
+		runnerMode = value
+	}
+	if value := os.Getenv("CODE_RUNNER_PATH"); value != "" {
+		runnerPath = value
+	}
+	if value := os.Getenv("CODE_RUNNER_IMAGE"); value != "" {
+		runnerImage = value
+	}
+	if value := os.Getenv("CODE_RUNNER_DOCKER_ARGS"); value != "" {
+		runnerDockerArgs = value
+	}
+	if value := os.Getenv("CODE_RUNNER_BASE_PORT"); value != "" {
+		port, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid CODE_RUNNER_BASE_PORT %q: %w", value, err)
+		}
+		DefaultPort = port
+	}
+	if value := os.Getenv("JUDGE_PRIORITY_WEIGHT"); value != "" {
+		weight, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid JUDGE_PRIORITY_WEIGHT %q: %w", value, err)
+		}
+		priorityWeight = weight
+	}
+	if value := os.Getenv("JUDGE_MAX_NORMAL_WAIT_SECONDS"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid JUDGE_MAX_NORMAL_WAIT_SECONDS %q: %w", value, err)
+		}
+		maxNormalWait = time.Duration(seconds) * time.Second
+	}
+	if value := os.Getenv("JUDGE_AUTOSCALE_MAX_RUNNERS"); value != "" {
+		runners, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid JUDGE_AUTOSCALE_MAX_RUNNERS %q: %w", value, err)
+		}
+		autoscaleMaxRunners = runners
+	}
+	if value := os.Getenv("JUDGE_AUTOSCALE_QUEUE_THRESHOLD"); value != "" {
+		threshold, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid JUDGE_AUTOSCALE_QUEUE_THRESHOLD %q: %w", value, err)
+		}
+		autoscaleQueueThreshold = threshold
+	}
+	if value := os.Getenv("JUDGE_AUTOSCALE_IDLE_COOLDOWN_SECONDS"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid JUDGE_AUTOSCALE_IDLE_COOLDOWN_SECONDS %q: %w", value, err)
+		}
+		autoscaleIdleCooldown = time.Duration(seconds) * time.Second
+	}
+	if value := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); value != "" {
+		tracingEndpoint = value
+	}
+	if value := os.Getenv("TRACING_SAMPLE_RATIO"); value != "" {
+		ratio, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid TRACING_SAMPLE_RATIO %q: %w", value, err)
+		}
+		tracingSampleRatio = ratio
+	}
+	loadInternalKeys()
+
+	if err := validateConfig(); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return nil
+}
+
+// loadConfigFile applies the judge section of the config file at path. Keys
+// missing from the file keep their default, unknown keys are an error.
+func loadConfigFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var file fileConfig
+	file.Judge.Listen = listenAddr
+	file.Judge.ServeAddr = ServeAddr
+	file.Judge.Runner.Mode = runnerMode
+	file.Judge.Runner.Path = runnerPath
+	file.Judge.Runner.Image = runnerImage
+	file.Judge.Runner.DockerArgs = runnerDockerArgs
+	file.Judge.Runner.BasePort = DefaultPort
+	file.Judge.Queue.PriorityWeight = priorityWeight
+	file.Judge.Queue.MaxNormalWait = maxNormalWait
+	file.Judge.Autoscale.MaxRunners = autoscaleMaxRunners
+	file.Judge.Autoscale.QueueThreshold = autoscaleQueueThreshold
+	file.Judge.Autoscale.IdleCooldown = autoscaleIdleCooldown
+	file.Judge.Internal.SignatureMaxSkew = maxClockSkew
+	file.Judge.Tracing.Endpoint = tracingEndpoint
+	file.Judge.Tracing.SampleRatio = tracingSampleRatio
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	j := file.Judge
+	listenAddr = j.Listen
+	ServeAddr = j.ServeAddr
+	runnerMode = j.Runner.Mode
+	runnerPath = j.Runner.Path
+	runnerImage = j.Runner.Image
+	runnerDockerArgs = j.Runner.DockerArgs
+	DefaultPort = j.Runner.BasePort
+	priorityWeight = j.Queue.PriorityWeight
+	maxNormalWait = j.Queue.MaxNormalWait
+	autoscaleMaxRunners = j.Autoscale.MaxRunners
+	autoscaleQueueThreshold = j.Autoscale.QueueThreshold
+	autoscaleIdleCooldown = j.Autoscale.IdleCooldown
+	if j.Internal.HMACKeys != "" {
+		internalKeys, signingKeyID = signing.ParseKeys(j.Internal.HMACKeys)
+	}
+	if j.Internal.HMACKeyID != "" {
+		signingKeyID = j.Internal.HMACKeyID
+	}
+	maxClockSkew = j.Internal.SignatureMaxSkew
+	tracingEndpoint = j.Tracing.Endpoint
+	tracingSampleRatio = j.Tracing.SampleRatio
+	return nil
+}
+
+// validateConfig checks the final settings and reports every problem at once
+func validateConfig() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	host, port, err := net.SplitHostPort(ServeAddr)
+	check(err == nil && host != "" && port != "", "serve address %q is not a host:port address", ServeAddr)
+	check(strings.TrimLeft(listenAddr, ":") != "", "listen address is required")
+	switch runnerMode {
+	case launchProcess:
+		check(runnerPath != "", "code-runner path is required")
+	case launchDocker:
+		check(runnerImage != "", "code-runner image is required in docker mode")
+	default:
+		check(false, "code-runner mode %q must be %s or %s", runnerMode, launchProcess, launchDocker)
+	}
+	check(DefaultPort > 0 && DefaultPort < 65535, "code-runner base port %d is out of range", DefaultPort)
+	check(priorityWeight > 0, "queue priority weight must be positive")
+	check(maxNormalWait > 0, "queue max normal wait must be positive")
+	check(autoscaleMaxRunners >= 0, "autoscale max runners must not be negative")
+	check(autoscaleQueueThreshold >= 0, "autoscale queue threshold must not be negative")
+	check(autoscaleIdleCooldown > 0, "autoscale idle cooldown must be positive")
+	check(maxClockSkew > 0, "internal signature max skew must be positive")
+	if tracingEndpoint != "" {
+		u, err := url.Parse(tracingEndpoint)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "tracing endpoint %q is not an http(s) URL", tracingEndpoint)
+	}
+	check(tracingSampleRatio >= 0 && tracingSampleRatio <= 1, "tracing sample ratio %v must be between 0 and 1", tracingSampleRatio)
+	if len(internalKeys) > 0 {
+		_, ok := internalKeys[signingKeyID]
+		check(ok, "internal HMAC key ID %q is not one of the configured keys", signingKeyID)
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	pb "goera/judge/internalpb"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DeadLetter is a judge result that could not be delivered to serve
+type DeadLetter struct {
+	ID             string      `json:"id"`
+	SubmissionID   uint        `json:"submissionId"`
+	IdempotencyKey string      `json:"idempotencyKey"` // Sent with every delivery of this result
+	Result         RunResponse `json:"result"`
+	Attempts       int         `json:"attempts"`
+	LastError      string      `json:"lastError"`
+	FailedAt       time.Time   `json:"failedAt"`
+}
+
+// DeadLetterFile keeps undeliverable results across restarts. Unlike the
+// runner state it is not removed on shutdown.
+const DeadLetterFile = "dead_letters.json"
+
+// Callback retry schedule: the delay doubles after each failed attempt
+const (
+	callbackMaxAttempts = 6
+	callbackBaseDelay   = time.Second
+	callbackMaxDelay    = 30 * time.Second
+)
+
+var deadLetterMu sync.Mutex
+
+// permanentError marks a callback failure that retrying will not fix
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+
+// loadDeadLetters reads the dead-letter store. Callers must hold deadLetterMu.
+func loadDeadLetters() []DeadLetter {
+	letters := make([]DeadLetter, 0)
+
+	data, err := os.ReadFile(DeadLetterFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error reading dead letter file: %v", err)
+		}
+		return letters
+	}
+
+	if err := json.Unmarshal(data, &letters); err != nil {
+		log.Printf("Error parsing dead letter file: %v", err)
+	}
+	return letters
+}
+
+// saveDeadLetters writes the dead-letter store. Callers must hold deadLetterMu.
+func saveDeadLetters(letters []DeadLetter) {
+	data, err := json.MarshalIndent(letters, "", "  ")
+	if err != nil {
+		log.Printf("Error encoding dead letters: %v", err)
+		return
+	}
+
+	if err := os.WriteFile(DeadLetterFile, data, 0644); err != nil {
+		log.Printf("Error writing dead letter file: %v", err)
+	}
+}
+
+func addDeadLetter(letter DeadLetter) {
+	deadLetterMu.Lock()
+	defer deadLetterMu.Unlock()
+
+	letters := loadDeadLetters()
+	letters = append(letters, letter)
+	saveDeadLetters(letters)
+}
+
+func removeDeadLetter(id string) (DeadLetter, bool) {
+	deadLetterMu.Lock()
+	defer deadLetterMu.Unlock()
+
+	letters := loadDeadLetters()
+	for i, letter := range letters {
+		if letter.ID == id {
+			saveDeadLetters(append(letters[:i], letters[i+1:]...))
+			return letter, true
+		}
+	}
+	return DeadLetter{}, false
+}
+
+// newRandomID returns a random hex identifier
+func newRandomID() string {
+	idBytes := make([]byte, 8)
+	rand.Read(idBytes)
+	return hex.EncodeToString(idBytes)
+}
+
+// postResult makes a single attempt at delivering a result to serve. The
+// idempotency key is the same for every delivery of a result, so serve can
+// tell a retry whose response got lost from a new verdict.
+func postResult(ctx context.Context, submissionID uint, idempotencyKey string, result *RunResponse) error {
+	client, err := serveClient()
+	if err != nil {
+		return permanentError{fmt.Errorf("error creating client: %w", err)}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	_, err = client.ReportResult(ctx, &pb.ReportResultRequest{
+		SubmissionId:   uint64(submissionID),
+		IdempotencyKey: idempotencyKey,
+		Result:         result.proto(),
+	})
+
+	switch status.Code(err) {
+	case codes.OK:
+		return nil
+	case codes.FailedPrecondition:
+		// The submission already has a final verdict, this result is stale
+		log.Printf("Serve discarded result for submission %d: %s\n", submissionID, status.Convert(err).Message())
+		return nil
+	case codes.InvalidArgument, codes.NotFound, codes.PermissionDenied, codes.AlreadyExists, codes.OutOfRange, codes.Unimplemented:
+		// Serve will refuse the result the same way again
+		return permanentError{err}
+	default:
+		return err
+	}
+}
+
+// deliverResult posts a result to serve, retrying with exponential backoff.
+// Results that still cannot be delivered are moved to the dead-letter store.
+func deliverResult(ctx context.Context, submissionID uint, result *RunResponse) {
+	idempotencyKey := newRandomID()
+	delay := callbackBaseDelay
+	var err error
+	attempt := 1
+	for ; attempt <= callbackMaxAttempts; attempt++ {
+		if err = postResult(ctx, submissionID, idempotencyKey, result); err == nil {
+			log.Println("Successfully reported result to serve")
+			return
+		}
+		if _, permanent := err.(permanentError); permanent {
+			break
+		}
+		if attempt == callbackMaxAttempts {
+			break
+		}
+
+		log.Printf("Callback for submission %d failed (attempt %d/%d), retrying in %s: %v\n",
+			submissionID, attempt, callbackMaxAttempts, delay, err)
+		time.Sleep(delay)
+		delay *= 2
+		if delay > callbackMaxDelay {
+			delay = callbackMaxDelay
+		}
+	}
+
+	log.Printf("Giving up on callback for submission %d after %d attempts: %v\n", submissionID, attempt, err)
+
+	addDeadLetter(DeadLetter{
+		ID:             newRandomID(),
+		SubmissionID:   submissionID,
+		IdempotencyKey: idempotencyKey,
+		Result:         *result,
+		Attempts:       attempt,
+		LastError:      err.Error(),
+		FailedAt:       time.Now(),
+	})
+}
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	pb "goera/judge/internalpb"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// maxMessageBytes bounds the messages exchanged with serve and the
+// code-runners, which carry source code and test cases
+const maxMessageBytes = 64 * 1024 * 1024
+
+// retryServiceConfig has gRPC retry idempotent calls that failed because the
+// other side was briefly unavailable, e.g. while it restarts
+const retryServiceConfig = `{
+	"methodConfig": [{
+		"name": [
+			{"service": "goera.internal.v1.RunnerService", "method": "RunnerStatus"},
+			{"service": "goera.internal.v1.ResultService", "method": "ReportEvents"}
+		],
+		"retryPolicy": {
+			"maxAttempts": 3,
+			"initialBackoff": "0.2s",
+			"maxBackoff": "2s",
+			"backoffMultiplier": 2,
+			"retryableStatusCodes": ["UNAVAILABLE"]
+		}
+	}]
+}`
+
+var (
+	connsMu     sync.Mutex
+	runnerConns = map[int]*grpc.ClientConn{}
+	serveConn   *grpc.ClientConn
+)
+
+// dial opens a client connection that signs its unary calls. Connections are
+// established lazily, so dial only fails on a malformed target.
+func dial(target string) (*grpc.ClientConn, error) {
+	return grpc.NewClient(target,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(signer.SignUnary),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithDefaultServiceConfig(retryServiceConfig),
+		grpc.WithDefaultCallOptions(
+			grpc.MaxCallRecvMsgSize(maxMessageBytes),
+			grpc.MaxCallSendMsgSize(maxMessageBytes),
+		),
+	)
+}
+
+// runnerClient returns a client for the code-runner on port, reusing its connection
+func runnerClient(port int) (pb.RunnerServiceClient, error) {
+	connsMu.Lock()
+	defer connsMu.Unlock()
+
+	conn, ok := runnerConns[port]
+	if !ok {
+		var err error
+		conn, err = dial(fmt.Sprintf("localhost:%d", port))
+		if err != nil {
+			return nil, err
+		}
+		runnerConns[port] = conn
+	}
+	return pb.NewRunnerServiceClient(conn), nil
+}
+
+// serveClient returns a client for serve's result service
+func serveClient() (pb.ResultServiceClient, error) {
+	connsMu.Lock()
+	defer connsMu.Unlock()
+
+	if serveConn == nil {
+		conn, err := dial(ServeAddr)
+		if err != nil {
+			return nil, err
+		}
+		serveConn = conn
+	}
+	return pb.NewResultServiceClient(serveConn), nil
+}
+
+// verdicts maps results to their wire form
+var verdicts = map[Result]pb.Verdict{
+	Accepted:            pb.Verdict_VERDICT_ACCEPTED,
+	CompileError:        pb.Verdict_VERDICT_COMPILE_ERROR,
+	WrongAnswer:         pb.Verdict_VERDICT_WRONG_ANSWER,
+	MemoryLimit:         pb.Verdict_VERDICT_MEMORY_LIMIT,
+	TimeLimit:           pb.Verdict_VERDICT_TIME_LIMIT,
+	RuntimeError:        pb.Verdict_VERDICT_RUNTIME_ERROR,
+	OutputLimitExceeded: pb.Verdict_VERDICT_OUTPUT_LIMIT_EXCEEDED,
+}
+
+// resultOf maps a verdict back to its result, or "" for an unknown verdict
+func resultOf(verdict pb.Verdict) Result {
+	for result, v := range verdicts {
+		if v == verdict {
+			return result
+		}
+	}
+	return ""
+}
+
+func (e Event) proto() *pb.Event {
+	return &pb.Event{
+		Source:  e.Source,
+		Type:    e.Type,
+		Message: e.Message,
+		Time:    timestamppb.New(e.Time),
+	}
+}
+
+func eventFromProto(e *pb.Event) Event {
+	return Event{
+		Source:  e.GetSource(),
+		Type:    e.GetType(),
+		Message: e.GetMessage(),
+		Time:    e.GetTime().AsTime(),
+	}
+}
+
+func eventsProto(events []Event) []*pb.Event {
+	out := make([]*pb.Event, 0, len(events))
+	for _, e := range events {
+		out = append(out, e.proto())
+	}
+	return out
+}
+
+func (r *RunResponse) proto() *pb.JobResult {
+	result := &pb.JobResult{
+		Verdict: verdicts[r.Status],
+		Output:  r.Output,
+		Events:  eventsProto(r.Events),
+	}
+	for _, stderr := range r.Stderr {
+		result.Stderr = append(result.Stderr, &pb.TestCaseStderr{
+			TestCase:  int32(stderr.TestCase),
+			Output:    stderr.Output,
+			Truncated: stderr.Truncated,
+		})
+	}
+	for _, usage := range r.Usage {
+		result.Usage = append(result.Usage, &pb.TestCaseUsage{
+			TestCase:        int32(usage.TestCase),
+			WallTimeMs:      usage.WallTimeMs,
+			CpuTimeMs:       usage.CPUTimeMs,
+			PeakMemoryBytes: usage.PeakMemoryBytes,
+			IoReadBytes:     usage.IOReadBytes,
+			IoWriteBytes:    usage.IOWriteBytes,
+		})
+	}
+	return result
+}
+
+func runResponseFromProto(submissionID uint64, r *pb.JobResult) *RunResponse {
+	result := &RunResponse{
+		SubmissionID: uint(submissionID),
+		Status:       resultOf(r.GetVerdict()),
+		Output:       r.GetOutput(),
+	}
+	for _, e := range r.GetEvents() {
+		result.Events = append(result.Events, eventFromProto(e))
+	}
+	for _, stderr := range r.GetStderr() {
+		result.Stderr = append(result.Stderr, TestCaseStderr{
+			TestCase:  int(stderr.GetTestCase()),
+			Output:    stderr.GetOutput(),
+			Truncated: stderr.GetTruncated(),
+		})
+	}
+	for _, usage := range r.GetUsage() {
+		result.Usage = append(result.Usage, TestCaseUsage{
+			TestCase:        int(usage.GetTestCase()),
+			WallTimeMs:      usage.GetWallTimeMs(),
+			CPUTimeMs:       usage.GetCpuTimeMs(),
+			PeakMemoryBytes: usage.GetPeakMemoryBytes(),
+			IOReadBytes:     usage.GetIoReadBytes(),
+			IOWriteBytes:    usage.GetIoWriteBytes(),
+		})
+	}
+	return result
+}
+
+// runOnCodeRunner judges a job on the code-runner on port. onEvent, when set,
+// is called with each event as the code-runner streams it; the returned
+// result carries all of them.
+func runOnCodeRunner(ctx context.Context, job *pb.Job, port int, onEvent func(Event)) (*RunResponse, error) {
+	client, err := runnerClient(port)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &pb.RunJobRequest{Job: job}
+	ctx, err = signer.Sign(ctx, pb.RunnerService_RunJob_FullMethodName, req)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := client.RunJob(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	for {
+		update, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("code-runner ended the stream without a result")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if event := update.GetEvent(); event != nil {
+			e := eventFromProto(event)
+			events = append(events, e)
+			if onEvent != nil {
+				onEvent(e)
+			}
+			continue
+		}
+		if result := update.GetResult(); result != nil {
+			response := runResponseFromProto(job.GetSubmissionId(), result)
+			response.Events = events
+			return response, nil
+		}
+	}
+}
+
+// fetchRunnerStatus asks a code-runner for the state of its worker pool
+func fetchRunnerStatus(port int) (*pb.RunnerStatusResponse, error) {
+	client, err := runnerClient(port)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return client.RunnerStatus(ctx, &pb.RunnerStatusRequest{})
+}
+
+// reportEvents pushes timeline events for a submission to serve
+func reportEvents(ctx context.Context, submissionID uint64, events []Event) error {
+	client, err := serveClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err = client.ReportEvents(ctx, &pb.ReportEventsRequest{
+		SubmissionId: submissionID,
+		Events:       eventsProto(events),
+	})
+	return err
+}
+
+// runnerError passes on the code-runner's verdict on a bad job and reports
+// anything else as the code-runner having failed
+func runnerError(err error) error {
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.ResourceExhausted:
+		return err
+	default:
+		return status.Error(codes.Unavailable, "Code-runner failed")
+	}
+}
+
+// judgeServer is the judge's gRPC service, called by serve
+type judgeServer struct {
+	pb.UnimplementedJudgeServiceServer
+}
+
+// SubmitJob sends a submission to a code-runner with a free worker slot, or
+// queues it when all of them are busy. The verdict is reported to serve
+// once the submission is judged.
+func (s *judgeServer) SubmitJob(ctx context.Context, req *pb.SubmitJobRequest) (*pb.SubmitJobResponse, error) {
+	job := req.GetJob()
+	if job == nil {
+		return nil, status.Error(codes.InvalidArgument, "Job is required")
+	}
+
+	log.Printf("ID=%v", job.GetSubmissionId())
+
+	trackJob(job)
+	queued := dispatchJob(context.WithoutCancel(ctx), job)
+	return &pb.SubmitJobResponse{Queued: queued}, nil
+}
+
+// dispatchJob sends a job to a code-runner with a free worker slot, or queues
+// it when all of them are busy, reporting whether it was queued. ctx carries
+// the trace the job is judged in.
+func dispatchJob(ctx context.Context, job *pb.Job) bool {
+	state := loadRunnerState()
+	mu.Lock()
+	defer mu.Unlock()
+
+	// Check if any code-runner is available
+	for _, runner := range state.Runners {
+		// Skip non-running, retiring or already busy runners
+		if runner.State != "running" || retiring[runner.Port] {
+			continue
+		}
+
+		// Try to find an available runner
+		if !isRunnerBusy(runner.Port) {
+			log.Printf("Code-runner on port %d is free. Sending submission immediately.", runner.Port)
+			reportEvent(job.GetSubmissionId(), "dispatched", fmt.Sprintf("Dispatched to code-runner on port %d", runner.Port))
+			inFlight[runner.Port]++
+			go processSubmission(ctx, job, runner.Port)
+			return false
+		}
+	}
+
+	// All code-runners are busy, queue the submission
+	log.Println("All code-runners busy. Queuing submission.")
+	position := queue.push(queuedJob{job: job, ctx: ctx, queuedAt: time.Now()})
+	reportEvent(job.GetSubmissionId(), "queued", fmt.Sprintf("All code-runners busy, position %d in the %s queue", position, laneName(job)))
+	return true
+}
+
+// claimRunner takes a free worker slot on a running code-runner for a
+// practice or generator run, reporting false when there is none. Queued
+// submissions go first, so no slot is taken while any are waiting. The slot
+// is given back with runnerDoneHandler.
+func claimRunner() (int, bool) {
+	state := loadRunnerState()
+	mu.Lock()
+	defer mu.Unlock()
+
+	if queue.len() > 0 {
+		return 0, false
+	}
+	for _, runner := range state.Runners {
+		if runner.State != "running" || retiring[runner.Port] || isRunnerBusy(runner.Port) {
+			continue
+		}
+		inFlight[runner.Port]++
+		return runner.Port, true
+	}
+	return 0, false
+}
+
+// Run executes a practice run synchronously and returns the verdict. Practice
+// runs are never queued and have no callback to serve, so they fail fast with
+// UNAVAILABLE when no code-runner has a free worker slot.
+func (s *judgeServer) Run(ctx context.Context, req *pb.RunRequest) (*pb.JobResult, error) {
+	port, ok := claimRunner()
+	if !ok {
+		return nil, status.Error(codes.Unavailable, "No code-runner available")
+	}
+	defer runnerDoneHandler(port)
+
+	result, err := runOnCodeRunner(ctx, req.GetJob(), port, nil)
+	if err != nil {
+		log.Printf("Practice run failed on code-runner port %d: %v\n", port, err)
+		return nil, runnerError(err)
+	}
+	return result.proto(), nil
+}
+
+// Generate runs a question's test case generator on a code-runner. Like
+// practice runs, generation is answered synchronously and never queued.
+func (s *judgeServer) Generate(ctx context.Context, req *pb.GenerateRequest) (*pb.GenerateResponse, error) {
+	port, ok := claimRunner()
+	if !ok {
+		return nil, status.Error(codes.Unavailable, "No code-runner available")
+	}
+	defer runnerDoneHandler(port)
+
+	client, err := runnerClient(port)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp, err := client.Generate(ctx, req)
+	if err != nil {
+		log.Printf("Generator run failed on code-runner port %d: %v\n", port, err)
+		return nil, runnerError(err)
+	}
+	return resp, nil
+}
+
+// ListDeadLetters lists undelivered results
+func (s *judgeServer) ListDeadLetters(ctx context.Context, req *pb.ListDeadLettersRequest) (*pb.ListDeadLettersResponse, error) {
+	deadLetterMu.Lock()
+	letters := loadDeadLetters()
+	deadLetterMu.Unlock()
+
+	resp := &pb.ListDeadLettersResponse{}
+	for _, letter := range letters {
+		resp.DeadLetters = append(resp.DeadLetters, &pb.DeadLetter{
+			Id:             letter.ID,
+			SubmissionId:   uint64(letter.SubmissionID),
+			IdempotencyKey: letter.IdempotencyKey,
+			Result:         letter.Result.proto(),
+			Attempts:       int32(letter.Attempts),
+			LastError:      letter.LastError,
+			FailedAt:       timestamppb.New(letter.FailedAt),
+		})
+	}
+	return resp, nil
+}
+
+// ReplayDeadLetter makes one more delivery attempt for a dead letter. On
+// success it is removed from the store, otherwise it is kept with the new error.
+func (s *judgeServer) ReplayDeadLetter(ctx context.Context, req *pb.ReplayDeadLetterRequest) (*pb.ReplayDeadLetterResponse, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "Dead letter ID is required")
+	}
+
+	letter, ok := removeDeadLetter(req.GetId())
+	if !ok {
+		return nil, status.Error(codes.NotFound, "Dead letter not found")
+	}
+
+	if err := postResult(ctx, letter.SubmissionID, letter.IdempotencyKey, &letter.Result); err != nil {
+		letter.Attempts++
+		letter.LastError = err.Error()
+		letter.FailedAt = time.Now()
+		addDeadLetter(letter)
+		return nil, status.Errorf(codes.Aborted, "Replay failed: %v", err)
+	}
+
+	log.Printf("Replayed dead letter %s for submission %d\n", letter.ID, letter.SubmissionID)
+	return &pb.ReplayDeadLetterResponse{}, nil
+}
@@ -0,0 +1,640 @@
+// Internal API between serve, the judge and the code-runners. Every call is
+// signed with the internal HMAC keys, see README.md.
+//
+// Regenerate the Go code of all three modules with proto/generate.sh.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: goera/internal/v1/internal.proto
+
+package internalpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	JudgeService_SubmitJob_FullMethodName        = "/goera.internal.v1.JudgeService/SubmitJob"
+	JudgeService_Run_FullMethodName              = "/goera.internal.v1.JudgeService/Run"
+	JudgeService_Generate_FullMethodName         = "/goera.internal.v1.JudgeService/Generate"
+	JudgeService_ListDeadLetters_FullMethodName  = "/goera.internal.v1.JudgeService/ListDeadLetters"
+	JudgeService_ReplayDeadLetter_FullMethodName = "/goera.internal.v1.JudgeService/ReplayDeadLetter"
+)
+
+// JudgeServiceClient is the client API for JudgeService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// JudgeService is served by the judge and called by serve
+type JudgeServiceClient interface {
+	// SubmitJob queues a submission for judging. The verdict is reported back
+	// to serve with ResultService.ReportResult.
+	SubmitJob(ctx context.Context, in *SubmitJobRequest, opts ...grpc.CallOption) (*SubmitJobResponse, error)
+	// Run judges code synchronously without storing anything, for practice
+	// runs and reference solution checks. It is never queued.
+	Run(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (*JobResult, error)
+	// Generate runs a test case generator once per input
+	Generate(ctx context.Context, in *GenerateRequest, opts ...grpc.CallOption) (*GenerateResponse, error)
+	// ListDeadLetters lists results that could not be delivered to serve
+	ListDeadLetters(ctx context.Context, in *ListDeadLettersRequest, opts ...grpc.CallOption) (*ListDeadLettersResponse, error)
+	// ReplayDeadLetter makes one more delivery attempt for a dead letter
+	ReplayDeadLetter(ctx context.Context, in *ReplayDeadLetterRequest, opts ...grpc.CallOption) (*ReplayDeadLetterResponse, error)
+}
+
+type judgeServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewJudgeServiceClient(cc grpc.ClientConnInterface) JudgeServiceClient {
+	return &judgeServiceClient{cc}
+}
+
+func (c *judgeServiceClient) SubmitJob(ctx context.Context, in *SubmitJobRequest, opts ...grpc.CallOption) (*SubmitJobResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SubmitJobResponse)
+	err := c.cc.Invoke(ctx, JudgeService_SubmitJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *judgeServiceClient) Run(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (*JobResult, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(JobResult)
+	err := c.cc.Invoke(ctx, JudgeService_Run_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *judgeServiceClient) Generate(ctx context.Context, in *GenerateRequest, opts ...grpc.CallOption) (*GenerateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GenerateResponse)
+	err := c.cc.Invoke(ctx, JudgeService_Generate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *judgeServiceClient) ListDeadLetters(ctx context.Context, in *ListDeadLettersRequest, opts ...grpc.CallOption) (*ListDeadLettersResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListDeadLettersResponse)
+	err := c.cc.Invoke(ctx, JudgeService_ListDeadLetters_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *judgeServiceClient) ReplayDeadLetter(ctx context.Context, in *ReplayDeadLetterRequest, opts ...grpc.CallOption) (*ReplayDeadLetterResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReplayDeadLetterResponse)
+	err := c.cc.Invoke(ctx, JudgeService_ReplayDeadLetter_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// JudgeServiceServer is the server API for JudgeService service.
+// All implementations must embed UnimplementedJudgeServiceServer
+// for forward compatibility.
+//
+// JudgeService is served by the judge and called by serve
+type JudgeServiceServer interface {
+	// SubmitJob queues a submission for judging. The verdict is reported back
+	// to serve with ResultService.ReportResult.
+	SubmitJob(context.Context, *SubmitJobRequest) (*SubmitJobResponse, error)
+	// Run judges code synchronously without storing anything, for practice
+	// runs and reference solution checks. It is never queued.
+	Run(context.Context, *RunRequest) (*JobResult, error)
+	// Generate runs a test case generator once per input
+	Generate(context.Context, *GenerateRequest) (*GenerateResponse, error)
+	// ListDeadLetters lists results that could not be delivered to serve
+	ListDeadLetters(context.Context, *ListDeadLettersRequest) (*ListDeadLettersResponse, error)
+	// ReplayDeadLetter makes one more delivery attempt for a dead letter
+	ReplayDeadLetter(context.Context, *ReplayDeadLetterRequest) (*ReplayDeadLetterResponse, error)
+	mustEmbedUnimplementedJudgeServiceServer()
+}
+
+// UnimplementedJudgeServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedJudgeServiceServer struct{}
+
+func (UnimplementedJudgeServiceServer) SubmitJob(context.Context, *SubmitJobRequest) (*SubmitJobResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SubmitJob not implemented")
+}
+func (UnimplementedJudgeServiceServer) Run(context.Context, *RunRequest) (*JobResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Run not implemented")
+}
+func (UnimplementedJudgeServiceServer) Generate(context.Context, *GenerateRequest) (*GenerateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Generate not implemented")
+}
+func (UnimplementedJudgeServiceServer) ListDeadLetters(context.Context, *ListDeadLettersRequest) (*ListDeadLettersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDeadLetters not implemented")
+}
+func (UnimplementedJudgeServiceServer) ReplayDeadLetter(context.Context, *ReplayDeadLetterRequest) (*ReplayDeadLetterResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReplayDeadLetter not implemented")
+}
+func (UnimplementedJudgeServiceServer) mustEmbedUnimplementedJudgeServiceServer() {}
+func (UnimplementedJudgeServiceServer) testEmbeddedByValue()                      {}
+
+// UnsafeJudgeServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to JudgeServiceServer will
+// result in compilation errors.
+type UnsafeJudgeServiceServer interface {
+	mustEmbedUnimplementedJudgeServiceServer()
+}
+
+func RegisterJudgeServiceServer(s grpc.ServiceRegistrar, srv JudgeServiceServer) {
+	// If the following call pancis, it indicates UnimplementedJudgeServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&JudgeService_ServiceDesc, srv)
+}
+
+func _JudgeService_SubmitJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JudgeServiceServer).SubmitJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JudgeService_SubmitJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JudgeServiceServer).SubmitJob(ctx, req.(*SubmitJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JudgeService_Run_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RunRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JudgeServiceServer).Run(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JudgeService_Run_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JudgeServiceServer).Run(ctx, req.(*RunRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JudgeService_Generate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GenerateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JudgeServiceServer).Generate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JudgeService_Generate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JudgeServiceServer).Generate(ctx, req.(*GenerateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JudgeService_ListDeadLetters_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDeadLettersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JudgeServiceServer).ListDeadLetters(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JudgeService_ListDeadLetters_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JudgeServiceServer).ListDeadLetters(ctx, req.(*ListDeadLettersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _JudgeService_ReplayDeadLetter_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReplayDeadLetterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(JudgeServiceServer).ReplayDeadLetter(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: JudgeService_ReplayDeadLetter_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(JudgeServiceServer).ReplayDeadLetter(ctx, req.(*ReplayDeadLetterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// JudgeService_ServiceDesc is the grpc.ServiceDesc for JudgeService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var JudgeService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "goera.internal.v1.JudgeService",
+	HandlerType: (*JudgeServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SubmitJob",
+			Handler:    _JudgeService_SubmitJob_Handler,
+		},
+		{
+			MethodName: "Run",
+			Handler:    _JudgeService_Run_Handler,
+		},
+		{
+			MethodName: "Generate",
+			Handler:    _JudgeService_Generate_Handler,
+		},
+		{
+			MethodName: "ListDeadLetters",
+			Handler:    _JudgeService_ListDeadLetters_Handler,
+		},
+		{
+			MethodName: "ReplayDeadLetter",
+			Handler:    _JudgeService_ReplayDeadLetter_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "goera/internal/v1/internal.proto",
+}
+
+const (
+	ResultService_ReportResult_FullMethodName = "/goera.internal.v1.ResultService/ReportResult"
+	ResultService_ReportEvents_FullMethodName = "/goera.internal.v1.ResultService/ReportEvents"
+)
+
+// ResultServiceClient is the client API for ResultService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// ResultService is served by serve and called by the judge
+type ResultServiceClient interface {
+	// ReportResult delivers the final verdict of a submission. It fails with
+	// FAILED_PRECONDITION when the submission already has a final verdict.
+	ReportResult(ctx context.Context, in *ReportResultRequest, opts ...grpc.CallOption) (*ReportResultResponse, error)
+	// ReportEvents appends events to a submission's timeline
+	ReportEvents(ctx context.Context, in *ReportEventsRequest, opts ...grpc.CallOption) (*ReportEventsResponse, error)
+}
+
+type resultServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewResultServiceClient(cc grpc.ClientConnInterface) ResultServiceClient {
+	return &resultServiceClient{cc}
+}
+
+func (c *resultServiceClient) ReportResult(ctx context.Context, in *ReportResultRequest, opts ...grpc.CallOption) (*ReportResultResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReportResultResponse)
+	err := c.cc.Invoke(ctx, ResultService_ReportResult_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *resultServiceClient) ReportEvents(ctx context.Context, in *ReportEventsRequest, opts ...grpc.CallOption) (*ReportEventsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ReportEventsResponse)
+	err := c.cc.Invoke(ctx, ResultService_ReportEvents_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ResultServiceServer is the server API for ResultService service.
+// All implementations must embed UnimplementedResultServiceServer
+// for forward compatibility.
+//
+// ResultService is served by serve and called by the judge
+type ResultServiceServer interface {
+	// ReportResult delivers the final verdict of a submission. It fails with
+	// FAILED_PRECONDITION when the submission already has a final verdict.
+	ReportResult(context.Context, *ReportResultRequest) (*ReportResultResponse, error)
+	// ReportEvents appends events to a submission's timeline
+	ReportEvents(context.Context, *ReportEventsRequest) (*ReportEventsResponse, error)
+	mustEmbedUnimplementedResultServiceServer()
+}
+
+// UnimplementedResultServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedResultServiceServer struct{}
+
+func (UnimplementedResultServiceServer) ReportResult(context.Context, *ReportResultRequest) (*ReportResultResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportResult not implemented")
+}
+func (UnimplementedResultServiceServer) ReportEvents(context.Context, *ReportEventsRequest) (*ReportEventsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportEvents not implemented")
+}
+func (UnimplementedResultServiceServer) mustEmbedUnimplementedResultServiceServer() {}
+func (UnimplementedResultServiceServer) testEmbeddedByValue()                       {}
+
+// UnsafeResultServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ResultServiceServer will
+// result in compilation errors.
+type UnsafeResultServiceServer interface {
+	mustEmbedUnimplementedResultServiceServer()
+}
+
+func RegisterResultServiceServer(s grpc.ServiceRegistrar, srv ResultServiceServer) {
+	// If the following call pancis, it indicates UnimplementedResultServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ResultService_ServiceDesc, srv)
+}
+
+func _ResultService_ReportResult_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReportResultRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ResultServiceServer).ReportResult(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ResultService_ReportResult_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ResultServiceServer).ReportResult(ctx, req.(*ReportResultRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ResultService_ReportEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReportEventsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ResultServiceServer).ReportEvents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ResultService_ReportEvents_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ResultServiceServer).ReportEvents(ctx, req.(*ReportEventsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ResultService_ServiceDesc is the grpc.ServiceDesc for ResultService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ResultService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "goera.internal.v1.ResultService",
+	HandlerType: (*ResultServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ReportResult",
+			Handler:    _ResultService_ReportResult_Handler,
+		},
+		{
+			MethodName: "ReportEvents",
+			Handler:    _ResultService_ReportEvents_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "goera/internal/v1/internal.proto",
+}
+
+const (
+	RunnerService_RunJob_FullMethodName       = "/goera.internal.v1.RunnerService/RunJob"
+	RunnerService_Generate_FullMethodName     = "/goera.internal.v1.RunnerService/Generate"
+	RunnerService_RunnerStatus_FullMethodName = "/goera.internal.v1.RunnerService/RunnerStatus"
+)
+
+// RunnerServiceClient is the client API for RunnerService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// RunnerService is served by each code-runner and called by the judge
+type RunnerServiceClient interface {
+	// RunJob judges a job, streaming each event as it happens. The last
+	// message carries the result.
+	RunJob(ctx context.Context, in *RunJobRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RunJobUpdate], error)
+	// Generate runs a test case generator once per input
+	Generate(ctx context.Context, in *GenerateRequest, opts ...grpc.CallOption) (*GenerateResponse, error)
+	// RunnerStatus reports the code-runner's worker pool
+	RunnerStatus(ctx context.Context, in *RunnerStatusRequest, opts ...grpc.CallOption) (*RunnerStatusResponse, error)
+}
+
+type runnerServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRunnerServiceClient(cc grpc.ClientConnInterface) RunnerServiceClient {
+	return &runnerServiceClient{cc}
+}
+
+func (c *runnerServiceClient) RunJob(ctx context.Context, in *RunJobRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[RunJobUpdate], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &RunnerService_ServiceDesc.Streams[0], RunnerService_RunJob_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[RunJobRequest, RunJobUpdate]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RunnerService_RunJobClient = grpc.ServerStreamingClient[RunJobUpdate]
+
+func (c *runnerServiceClient) Generate(ctx context.Context, in *GenerateRequest, opts ...grpc.CallOption) (*GenerateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GenerateResponse)
+	err := c.cc.Invoke(ctx, RunnerService_Generate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *runnerServiceClient) RunnerStatus(ctx context.Context, in *RunnerStatusRequest, opts ...grpc.CallOption) (*RunnerStatusResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RunnerStatusResponse)
+	err := c.cc.Invoke(ctx, RunnerService_RunnerStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RunnerServiceServer is the server API for RunnerService service.
+// All implementations must embed UnimplementedRunnerServiceServer
+// for forward compatibility.
+//
+// RunnerService is served by each code-runner and called by the judge
+type RunnerServiceServer interface {
+	// RunJob judges a job, streaming each event as it happens. The last
+	// message carries the result.
+	RunJob(*RunJobRequest, grpc.ServerStreamingServer[RunJobUpdate]) error
+	// Generate runs a test case generator once per input
+	Generate(context.Context, *GenerateRequest) (*GenerateResponse, error)
+	// RunnerStatus reports the code-runner's worker pool
+	RunnerStatus(context.Context, *RunnerStatusRequest) (*RunnerStatusResponse, error)
+	mustEmbedUnimplementedRunnerServiceServer()
+}
+
+// UnimplementedRunnerServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedRunnerServiceServer struct{}
+
+func (UnimplementedRunnerServiceServer) RunJob(*RunJobRequest, grpc.ServerStreamingServer[RunJobUpdate]) error {
+	return status.Errorf(codes.Unimplemented, "method RunJob not implemented")
+}
+func (UnimplementedRunnerServiceServer) Generate(context.Context, *GenerateRequest) (*GenerateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Generate not implemented")
+}
+func (UnimplementedRunnerServiceServer) RunnerStatus(context.Context, *RunnerStatusRequest) (*RunnerStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RunnerStatus not implemented")
+}
+func (UnimplementedRunnerServiceServer) mustEmbedUnimplementedRunnerServiceServer() {}
+func (UnimplementedRunnerServiceServer) testEmbeddedByValue()                       {}
+
+// UnsafeRunnerServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RunnerServiceServer will
+// result in compilation errors.
+type UnsafeRunnerServiceServer interface {
+	mustEmbedUnimplementedRunnerServiceServer()
+}
+
+func RegisterRunnerServiceServer(s grpc.ServiceRegistrar, srv RunnerServiceServer) {
+	// If the following call pancis, it indicates UnimplementedRunnerServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&RunnerService_ServiceDesc, srv)
+}
+
+func _RunnerService_RunJob_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RunJobRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RunnerServiceServer).RunJob(m, &grpc.GenericServerStream[RunJobRequest, RunJobUpdate]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RunnerService_RunJobServer = grpc.ServerStreamingServer[RunJobUpdate]
+
+func _RunnerService_Generate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GenerateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RunnerServiceServer).Generate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RunnerService_Generate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RunnerServiceServer).Generate(ctx, req.(*GenerateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RunnerService_RunnerStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RunnerStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RunnerServiceServer).RunnerStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RunnerService_RunnerStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RunnerServiceServer).RunnerStatus(ctx, req.(*RunnerStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// RunnerService_ServiceDesc is the grpc.ServiceDesc for RunnerService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RunnerService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "goera.internal.v1.RunnerService",
+	HandlerType: (*RunnerServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Generate",
+			Handler:    _RunnerService_Generate_Handler,
+		},
+		{
+			MethodName: "RunnerStatus",
+			Handler:    _RunnerService_RunnerStatus_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "RunJob",
+			Handler:       _RunnerService_RunJob_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "goera/internal/v1/internal.proto",
+}
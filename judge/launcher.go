@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How code-runners are launched, set by CODE_RUNNER_MODE or judge.runner.mode
+const (
+	launchProcess = "process" // Started as a child process from runnerPath
+	launchDocker  = "docker"  // Started as a container from runnerImage
+)
+
+// runnerPortLabel marks the containers of the docker launcher with their port
+const runnerPortLabel = "goera.code-runner.port"
+
+// runnerEnvPrefixes are passed on from the judge's environment to code-runner
+// containers, which do not inherit it like child processes do
+var runnerEnvPrefixes = []string{"CODE_RUNNER_", "INTERNAL_", "OTEL_", "TRACING_", "NSJAIL_"}
+
+// runnerLauncher starts code-runners and stops them again
+type runnerLauncher interface {
+	// check reports why runners cannot be started, before starting any
+	check() error
+	// start launches a code-runner listening on port
+	start(port int) (RunnerProcess, error)
+	// alive reports whether a runner from the state is still running
+	alive(runner RunnerProcess) bool
+	// stop ends a runner
+	stop(runner RunnerProcess) error
+	// running lists the runners up on this machine, including those
+	// missing from the state
+	running() []RunnerProcess
+}
+
+// newLauncher returns the launcher of the configured mode
+func newLauncher() runnerLauncher {
+	if runnerMode == launchDocker {
+		return dockerLauncher{image: runnerImage, args: strings.Fields(runnerDockerArgs)}
+	}
+	return processLauncher{path: runnerPath}
+}
+
+// processLauncher runs code-runners as processes of the runner binary
+type processLauncher struct {
+	path string
+}
+
+// binary resolves the runner path. A relative path is looked up from the
+// working directory first and then next to the judge's own executable, so
+// the judge can be started from anywhere; a bare name is looked up in PATH.
+func (l processLauncher) binary() (string, error) {
+	if !strings.ContainsRune(l.path, filepath.Separator) && !strings.Contains(l.path, "/") {
+		return exec.LookPath(l.path)
+	}
+	candidates := []string{l.path}
+	if !filepath.IsAbs(l.path) {
+		if self, err := os.Executable(); err == nil {
+			candidates = append(candidates, filepath.Join(filepath.Dir(self), l.path))
+		}
+	}
+	for _, candidate := range candidates {
+		info, err := os.Stat(candidate)
+		if err != nil {
+			continue
+		}
+		if !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+			return "", fmt.Errorf("code-runner %s is not an executable file", candidate)
+		}
+		return filepath.Abs(candidate)
+	}
+	return "", fmt.Errorf("code-runner binary %s not found", l.path)
+}
+
+func (l processLauncher) check() error {
+	_, err := l.binary()
+	return err
+}
+
+func (l processLauncher) start(port int) (RunnerProcess, error) {
+	binary, err := l.binary()
+	if err != nil {
+		return RunnerProcess{}, err
+	}
+	cmd := exec.Command(binary, "serve", "--listen", strconv.Itoa(port))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return RunnerProcess{}, err
+	}
+
+	// Wait for process in background
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			log.Printf("Code-runner on port %d exited with error: %v\n", port, err)
+		} else {
+			log.Printf("Code-runner on port %d exited normally\n", port)
+		}
+		// Update state when process ends
+		removeRunnerFromState(port)
+		// Don't remove port from configuration automatically
+		// as it's part of the history
+	}()
+
+	return RunnerProcess{Port: port, PID: cmd.Process.Pid}, nil
+}
+
+func (l processLauncher) alive(runner RunnerProcess) bool {
+	return isRunnerProcess(runner.PID, runner.Port)
+}
+
+func (l processLauncher) stop(runner RunnerProcess) error {
+	process, err := os.FindProcess(runner.PID)
+	if err != nil {
+		return fmt.Errorf("failed to find process with PID %d: %v", runner.PID, err)
+	}
+	if err := process.Kill(); err != nil {
+		return fmt.Errorf("failed to kill process with PID %d: %v", runner.PID, err)
+	}
+	return nil
+}
+
+func (l processLauncher) running() []RunnerProcess {
+	return findRunnerProcesses()
+}
+
+// dockerLauncher runs code-runners as containers of image, one per port, with
+// the port published on the host. args are added to docker run, e.g. to mount
+// the Docker socket the runner's own sandbox needs.
+type dockerLauncher struct {
+	image string
+	args  []string
+}
+
+// containerName is the name of the container of the runner on port
+func containerName(port int) string {
+	return fmt.Sprintf("goera-code-runner-%d", port)
+}
+
+// docker runs the docker CLI and returns what it printed
+func docker(args ...string) (string, error) {
+	out, err := exec.Command("docker", args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("docker %s: %w: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (l dockerLauncher) check() error {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return fmt.Errorf("docker launcher needs the docker CLI: %w", err)
+	}
+	return nil
+}
+
+func (l dockerLauncher) start(port int) (RunnerProcess, error) {
+	name := containerName(port)
+	// A stopped container of an earlier runner would hold the name
+	docker("rm", "-f", name)
+
+	args := []string{"run", "-d", "--name", name,
+		"--label", fmt.Sprintf("%s=%d", runnerPortLabel, port),
+		"-p", fmt.Sprintf("%d:%d", port, port)}
+	for _, env := range os.Environ() {
+		key, _, _ := strings.Cut(env, "=")
+		for _, prefix := range runnerEnvPrefixes {
+			if strings.HasPrefix(key, prefix) {
+				args = append(args, "-e", key)
+				break
+			}
+		}
+	}
+	args = append(args, l.args...)
+	args = append(args, l.image, "serve", "--listen", strconv.Itoa(port))
+
+	if _, err := docker(args...); err != nil {
+		return RunnerProcess{}, err
+	}
+	return RunnerProcess{Port: port, Container: name}, nil
+}
+
+func (l dockerLauncher) alive(runner RunnerProcess) bool {
+	if runner.Container == "" {
+		return false
+	}
+	out, err := docker("inspect", "-f", "{{.State.Running}}", runner.Container)
+	return err == nil && out == "true"
+}
+
+func (l dockerLauncher) stop(runner RunnerProcess) error {
+	if runner.Container == "" {
+		return errors.New("runner has no container")
+	}
+	_, err := docker("rm", "-f", runner.Container)
+	return err
+}
+
+func (l dockerLauncher) running() []RunnerProcess {
+	out, err := docker("ps", "--filter", "label="+runnerPortLabel,
+		"--format", fmt.Sprintf(`{{.Names}} {{.Label "%s"}}`, runnerPortLabel))
+	if err != nil {
+		log.Printf("Failed to list code-runner containers: %v", err)
+		return nil
+	}
+
+	var found []RunnerProcess
+	for _, line := range strings.Split(out, "\n") {
+		name, value, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		if port, err := strconv.Atoi(value); err == nil {
+			found = append(found, RunnerProcess{Port: port, Container: name, Time: time.Now()})
+		}
+	}
+	return found
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"time"
+
+	pb "goera/judge/internalpb"
+)
+
+// Queue settings, configurable through the config file and environment
+var (
+	// priorityWeight is how many high priority jobs are sent on for every
+	// normal one while both lanes have jobs waiting
+	priorityWeight = 4
+	// maxNormalWait is how long a normal job waits at most before it goes
+	// ahead of the high priority lane, so a busy contest cannot starve practice
+	maxNormalWait = 2 * time.Minute
+)
+
+// jobQueue holds the submissions waiting for a free code-runner in one lane
+// per priority. The high lane is served first, but every priorityWeight high
+// jobs one normal job gets its turn, and a normal job that waited
+// maxNormalWait goes next regardless.
+type jobQueue struct {
+	normal []queuedJob
+	high   []queuedJob
+	streak int // High jobs sent on since the last normal one, while normal ones waited
+}
+
+// push adds a job to the end of its lane, returning its position in the lane
+func (q *jobQueue) push(job queuedJob) int {
+	if job.job.GetPriority() == pb.Priority_PRIORITY_HIGH {
+		q.high = append(q.high, job)
+		return len(q.high)
+	}
+	q.normal = append(q.normal, job)
+	return len(q.normal)
+}
+
+// pop takes the job that is due next, reporting false when none is waiting
+func (q *jobQueue) pop() (queuedJob, bool) {
+	if len(q.normal) > 0 && (len(q.high) == 0 || q.streak >= priorityWeight ||
+		time.Since(q.normal[0].queuedAt) >= maxNormalWait) {
+		next := q.normal[0]
+		q.normal = q.normal[1:]
+		q.streak = 0
+		return next, true
+	}
+	if len(q.high) > 0 {
+		next := q.high[0]
+		q.high = q.high[1:]
+		if len(q.normal) > 0 {
+			q.streak++
+		}
+		return next, true
+	}
+	return queuedJob{}, false
+}
+
+// len returns how many jobs wait in both lanes
+func (q *jobQueue) len() int {
+	return len(q.high) + len(q.normal)
+}
+
+// laneName names the lane of a job, for events and logs
+func laneName(job *pb.Job) string {
+	if job.GetPriority() == pb.Priority_PRIORITY_HIGH {
+		return "high priority"
+	}
+	return "normal"
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	pb "goera/judge/internalpb"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// InFlightDir keeps a copy of every submission the judge accepted and has not
+// finished judging, so they are dispatched again after a restart. Like the
+// dead letters it is not removed on shutdown.
+const InFlightDir = "inflight"
+
+// How long a code-runner found at startup gets to answer before it is given up
+const (
+	runnerProbeAttempts = 3
+	runnerProbeDelay    = time.Second
+)
+
+// inFlightPath is the file of a submission's job in InFlightDir
+func inFlightPath(submissionID uint64) string {
+	return filepath.Join(InFlightDir, fmt.Sprintf("%d.job", submissionID))
+}
+
+// trackJob records a job as in flight until untrackJob is called for it.
+// Failing to record it only costs the redelivery after a crash.
+func trackJob(job *pb.Job) {
+	data, err := proto.Marshal(job)
+	if err != nil {
+		log.Printf("Error encoding job for submission %d: %v", job.GetSubmissionId(), err)
+		return
+	}
+	if err := os.MkdirAll(InFlightDir, 0755); err != nil {
+		log.Printf("Error creating %s: %v", InFlightDir, err)
+		return
+	}
+
+	// Written aside and renamed, so a crash never leaves half a job behind
+	path := inFlightPath(job.GetSubmissionId())
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		log.Printf("Error writing in-flight job for submission %d: %v", job.GetSubmissionId(), err)
+		return
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		log.Printf("Error writing in-flight job for submission %d: %v", job.GetSubmissionId(), err)
+	}
+}
+
+// untrackJob forgets a job once it was judged or given up on
+func untrackJob(submissionID uint64) {
+	if err := os.Remove(inFlightPath(submissionID)); err != nil && !os.IsNotExist(err) {
+		log.Printf("Error removing in-flight job for submission %d: %v", submissionID, err)
+	}
+}
+
+// loadInFlightJobs reads the jobs left in flight by the previous run
+func loadInFlightJobs() []*pb.Job {
+	entries, err := os.ReadDir(InFlightDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error reading %s: %v", InFlightDir, err)
+		}
+		return nil
+	}
+
+	var jobs []*pb.Job
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".job") {
+			continue
+		}
+		path := filepath.Join(InFlightDir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("Error reading in-flight job %s: %v", path, err)
+			continue
+		}
+		job := &pb.Job{}
+		if err := proto.Unmarshal(data, job); err != nil {
+			log.Printf("Discarding unreadable in-flight job %s: %v", path, err)
+			os.Remove(path)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+// resumeInFlightJobs dispatches the submissions the previous run accepted but
+// never finished. Serve ignores a verdict for a submission it already has one
+// for, so one that was judged after all does no harm.
+func resumeInFlightJobs() {
+	jobs := loadInFlightJobs()
+	if len(jobs) == 0 {
+		return
+	}
+
+	log.Printf("Resuming %d submissions left in flight by the previous run", len(jobs))
+	for _, job := range jobs {
+		reportEvent(job.GetSubmissionId(), "resumed", "Judge restarted, judging the submission again")
+		dispatchJob(context.Background(), job)
+	}
+}
+
+// reconcileRunners brings the runner state in line with the code-runners that
+// are actually up. After a restart the state may list runners that died, and
+// miss ones that outlived the judge because the state file was removed on
+// shutdown. Runners that answer are adopted, dead entries are dropped, and
+// runner processes that never answer are stopped so they free their port.
+func reconcileRunners() {
+	launcher := newLauncher()
+	state := loadRunnerState()
+	known := map[int]bool{}
+	runners := make([]RunnerProcess, 0, len(state.Runners))
+
+	for _, runner := range state.Runners {
+		if !launcher.alive(runner) {
+			log.Printf("Code-runner on port %d (%s) is gone, dropping it", runner.Port, runner.describe())
+			continue
+		}
+		if !probeRunner(runner.Port) {
+			log.Printf("Code-runner on port %d (%s) does not answer, stopping it", runner.Port, runner.describe())
+			stopRunner(launcher, runner)
+			continue
+		}
+		runner.State = "running"
+		runners = append(runners, runner)
+		known[runner.Port] = true
+	}
+
+	for _, orphan := range launcher.running() {
+		if known[orphan.Port] {
+			continue
+		}
+		if !probeRunner(orphan.Port) {
+			log.Printf("Orphaned code-runner on port %d (%s) does not answer, stopping it", orphan.Port, orphan.describe())
+			stopRunner(launcher, orphan)
+			continue
+		}
+		log.Printf("Adopted orphaned code-runner on port %d (%s)", orphan.Port, orphan.describe())
+		orphan.State = "running"
+		orphan.Time = time.Now()
+		runners = append(runners, orphan)
+		known[orphan.Port] = true
+		addPort(orphan.Port)
+	}
+
+	saveRunnerState(RunnerState{Runners: runners})
+	log.Printf("Found %d live code-runners", len(runners))
+}
+
+// probeRunner asks a code-runner for its status a few times, giving one that
+// is still starting up the time to listen, and records its worker slots
+func probeRunner(port int) bool {
+	for attempt := 1; attempt <= runnerProbeAttempts; attempt++ {
+		if refreshRunnerStatus(port) {
+			return true
+		}
+		if attempt < runnerProbeAttempts {
+			time.Sleep(runnerProbeDelay)
+		}
+	}
+	return false
+}
+
+// stopRunner stops a runner that cannot be used
+func stopRunner(launcher runnerLauncher, runner RunnerProcess) {
+	if err := launcher.stop(runner); err != nil {
+		log.Printf("Failed to stop code-runner on port %d: %v", runner.Port, err)
+	}
+}
+
+// isRunnerProcess reports whether pid is a live code-runner listening on port.
+// The command line is checked because the PID of a dead runner may have been
+// reused by an unrelated process. Without /proc, any live process will do.
+func isRunnerProcess(pid, port int) bool {
+	if pid <= 0 {
+		return false
+	}
+	cmdline, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if errors.Is(err, fs.ErrNotExist) {
+		if _, statErr := os.Stat("/proc"); statErr == nil {
+			return false
+		}
+		process, err := os.FindProcess(pid)
+		return err == nil && process.Signal(syscall.Signal(0)) == nil
+	}
+	if err != nil {
+		return false
+	}
+	runnerPort, ok := runnerPortFromArgs(strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00"))
+	return ok && runnerPort == port
+}
+
+// findRunnerProcesses lists the code-runner processes on this machine
+func findRunnerProcesses() []RunnerProcess {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+
+	var found []RunnerProcess
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		cmdline, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "cmdline"))
+		if err != nil {
+			continue
+		}
+		if port, ok := runnerPortFromArgs(strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00")); ok {
+			found = append(found, RunnerProcess{Port: port, PID: pid})
+		}
+	}
+	return found
+}
+
+// runnerPortFromArgs returns the port of a code-runner started as
+// "<runnerPath> serve --listen <port>", the way startCodeRunner starts them
+func runnerPortFromArgs(args []string) (int, bool) {
+	if len(args) < 2 || filepath.Base(args[0]) != filepath.Base(runnerPath) || args[1] != "serve" {
+		return 0, false
+	}
+	for i, arg := range args[2:] {
+		var value string
+		switch {
+		case strings.HasPrefix(arg, "--listen="):
+			value = strings.TrimPrefix(arg, "--listen=")
+		case arg == "--listen" && i+3 < len(args):
+			value = args[i+3]
+		default:
+			continue
+		}
+		if j := strings.LastIndex(value, ":"); j >= 0 {
+			value = value[j+1:]
+		}
+		port, err := strconv.Atoi(value)
+		return port, err == nil
+	}
+	return 0, false
+}
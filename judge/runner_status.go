@@ -0,0 +1,62 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// runnerStatusInterval is how often the code-runners are asked how many
+// worker slots they have
+const runnerStatusInterval = 10 * time.Second
+
+// runnerWorkers holds the worker slots each code-runner last reported. It is
+// only refreshed outside mu, so a slow code-runner never holds up dispatching.
+// Guarded by mu.
+var runnerWorkers = map[int]int{}
+
+// refreshRunnerStatus asks the code-runner on port for its worker slots and
+// records them, reporting whether it answered. Must be called without mu held.
+func refreshRunnerStatus(port int) bool {
+	status, err := fetchRunnerStatus(port)
+	if err != nil {
+		return false
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	runnerWorkers[port] = int(status.GetWorkers())
+	// Slots it gained can take queued submissions right away
+	if !retiring[port] {
+		fillRunner(port)
+	}
+	return true
+}
+
+// pollRunnerStatus refreshes the worker slots of every running code-runner
+// every runnerStatusInterval, so runners started with the coderunner command
+// or given more workers are picked up. It blocks, so run it in its own
+// goroutine.
+func pollRunnerStatus() {
+	for range time.Tick(runnerStatusInterval) {
+		for _, runner := range loadRunnerState().Runners {
+			if runner.State != "running" || isRetiring(runner.Port) {
+				continue
+			}
+			if !refreshRunnerStatus(runner.Port) {
+				log.Printf("Failed to get status of code-runner on port %d\n", runner.Port)
+			}
+		}
+	}
+}
+
+// fillRunner sends queued submissions to the code-runner on port while it has
+// free worker slots. Must be called with mu held.
+func fillRunner(port int) {
+	for !isRunnerBusy(port) {
+		next, ok := queue.pop()
+		if !ok {
+			return
+		}
+		sendQueued(next, port)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"goera/proto/signing"
+)
+
+// Signing keys shared with serve and the code-runners. Every key is accepted
+// when verifying; outgoing calls are signed with signingKeyID.
+var (
+	internalKeys = map[string]string{}
+	signingKeyID = ""
+	maxClockSkew = 5 * time.Minute
+)
+
+// signer signs the calls to serve and the code-runners and verifies the calls
+// from serve
+var signer = signing.New(func() signing.Keys {
+	return signing.Keys{Secrets: internalKeys, SigningKeyID: signingKeyID, MaxSkew: maxClockSkew}
+})
+
+// loadInternalKeys reads the signing keys from INTERNAL_HMAC_KEYS (id:secret pairs
+// separated by commas) and INTERNAL_HMAC_KEY_ID, falling back to INTERNAL_API_KEY.
+// Keys from the environment replace those from the config file.
+func loadInternalKeys() {
+	if value := os.Getenv("INTERNAL_HMAC_KEYS"); value != "" {
+		internalKeys, signingKeyID = signing.ParseKeys(value)
+	}
+
+	if len(internalKeys) == 0 {
+		if legacyKey := os.Getenv("INTERNAL_API_KEY"); legacyKey != "" {
+			internalKeys["default"] = legacyKey
+			signingKeyID = "default"
+		}
+	}
+
+	if id := os.Getenv("INTERNAL_HMAC_KEY_ID"); id != "" {
+		signingKeyID = id
+	}
+	if seconds, err := strconv.Atoi(os.Getenv("INTERNAL_SIGNATURE_MAX_SKEW_SECONDS")); err == nil && seconds > 0 {
+		maxClockSkew = time.Duration(seconds) * time.Second
+	}
+
+	if _, ok := internalKeys[signingKeyID]; !ok {
+		log.Println("Warning: no internal signing key configured, internal requests will be rejected")
+	}
+}
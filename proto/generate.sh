@@ -0,0 +1,26 @@
+#!/bin/sh
+# Generates the Go code of the internal API into serve, the judge and the
+# code-runner. Each is its own module and Docker build context, so each gets
+# its own copy of the generated package.
+#
+# Requires protoc, protoc-gen-go and protoc-gen-go-grpc:
+#   go install google.golang.org/protobuf/cmd/protoc-gen-go@v1.36.5
+#   go install google.golang.org/grpc/cmd/protoc-gen-go-grpc@v1.5.1
+set -e
+cd "$(dirname "$0")/.."
+
+PROTO=goera/internal/v1/internal.proto
+
+generate() {
+	dir=$1
+	module=$2
+	pkg=$3
+	protoc -I proto \
+		--go_out="$dir" --go_opt=module="$module" --go_opt=M$PROTO="$pkg" \
+		--go-grpc_out="$dir" --go-grpc_opt=module="$module" --go-grpc_opt=M$PROTO="$pkg" \
+		"proto/$PROTO"
+}
+
+generate serve goera/serve goera/serve/internal/internalpb
+generate judge goera/judge goera/judge/internalpb
+generate judge/code-runner goera/code-runner goera/code-runner/internalpb
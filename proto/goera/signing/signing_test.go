@@ -0,0 +1,170 @@
+package signing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const testMethod = "/goera.internal.v1.JudgeService/Submit"
+
+// received turns the metadata a call was signed with into that of the
+// incoming call on the other end
+func received(t *testing.T, ctx context.Context) context.Context {
+	t.Helper()
+	md, _ := metadata.FromOutgoingContext(ctx)
+	return metadata.NewIncomingContext(context.Background(), md)
+}
+
+func TestVerify(t *testing.T) {
+	keys := Keys{
+		Secrets:      map[string]string{"old": "old-secret", "new": "new-secret"},
+		SigningKeyID: "new",
+		MaxSkew:      5 * time.Minute,
+	}
+	now := time.Unix(1700000000, 0)
+	req := wrapperspb.String("print(1)")
+
+	tests := []struct {
+		name string
+		// call signs a call as the client would and returns the context and
+		// request the server receives
+		call    func(t *testing.T, client, server *Signer) (context.Context, interface{})
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			call: func(t *testing.T, client, server *Signer) (context.Context, interface{}) {
+				ctx, err := client.Sign(context.Background(), testMethod, req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return received(t, ctx), req
+			},
+		},
+		{
+			name: "signed with a previous key",
+			call: func(t *testing.T, client, server *Signer) (context.Context, interface{}) {
+				rotating := New(func() Keys { k := keys; k.SigningKeyID = "old"; return k })
+				rotating.now = client.now
+				ctx, err := rotating.Sign(context.Background(), testMethod, req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return received(t, ctx), req
+			},
+		},
+		{
+			name: "tampered request",
+			call: func(t *testing.T, client, server *Signer) (context.Context, interface{}) {
+				ctx, err := client.Sign(context.Background(), testMethod, req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return received(t, ctx), wrapperspb.String("print(2)")
+			},
+			wantErr: true,
+		},
+		{
+			name: "tampered signature",
+			call: func(t *testing.T, client, server *Signer) (context.Context, interface{}) {
+				ctx, err := client.Sign(context.Background(), testMethod, req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				md, _ := metadata.FromOutgoingContext(ctx)
+				md.Set(SignatureHeader, computeSignature("guessed", testMethod, md.Get(TimestampHeader)[0], md.Get(NonceHeader)[0], nil))
+				return metadata.NewIncomingContext(context.Background(), md), req
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown key",
+			call: func(t *testing.T, client, server *Signer) (context.Context, interface{}) {
+				stranger := New(func() Keys {
+					return Keys{Secrets: map[string]string{"other": "other-secret"}, SigningKeyID: "other", MaxSkew: keys.MaxSkew}
+				})
+				stranger.now = client.now
+				ctx, err := stranger.Sign(context.Background(), testMethod, req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return received(t, ctx), req
+			},
+			wantErr: true,
+		},
+		{
+			name: "unsigned",
+			call: func(t *testing.T, client, server *Signer) (context.Context, interface{}) {
+				return context.Background(), req
+			},
+			wantErr: true,
+		},
+		{
+			name: "stale",
+			call: func(t *testing.T, client, server *Signer) (context.Context, interface{}) {
+				client.now = func() time.Time { return now.Add(-6 * time.Minute) }
+				ctx, err := client.Sign(context.Background(), testMethod, req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return received(t, ctx), req
+			},
+			wantErr: true,
+		},
+		{
+			name: "from the future",
+			call: func(t *testing.T, client, server *Signer) (context.Context, interface{}) {
+				client.now = func() time.Time { return now.Add(6 * time.Minute) }
+				ctx, err := client.Sign(context.Background(), testMethod, req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return received(t, ctx), req
+			},
+			wantErr: true,
+		},
+		{
+			name: "replayed",
+			call: func(t *testing.T, client, server *Signer) (context.Context, interface{}) {
+				ctx, err := client.Sign(context.Background(), testMethod, req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := server.Verify(received(t, ctx), testMethod, req); err != nil {
+					t.Fatalf("first call refused: %v", err)
+				}
+				return received(t, ctx), req
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := New(func() Keys { return keys })
+			client.now = func() time.Time { return now }
+			server := New(func() Keys { return keys })
+			server.now = func() time.Time { return now }
+
+			ctx, got := tt.call(t, client, server)
+			err := server.Verify(ctx, testMethod, got)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	keys, first := ParseKeys(" k2:s2, broken, :nokey, k1:s1 ")
+	if first != "k2" {
+		t.Errorf("first key = %q, want k2", first)
+	}
+	if len(keys) != 2 || keys["k1"] != "s1" || keys["k2"] != "s2" {
+		t.Errorf("keys = %v, want k1 and k2", keys)
+	}
+}
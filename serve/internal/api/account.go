@@ -0,0 +1,474 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/config"
+	"goera/serve/internal/database"
+	"goera/serve/internal/mail"
+	"goera/serve/internal/models"
+	"goera/serve/internal/preferences"
+	"goera/serve/internal/storage"
+	"goera/serve/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// accountDeletionSweepInterval is how often accounts past their deletion
+// grace period are looked for
+const accountDeletionSweepInterval = time.Hour
+
+// AccountExport is everything the service stores about a user, as served by
+// /api/user/self/export
+type AccountExport struct {
+	ExportedAt            time.Time                     `json:"exportedAt"`
+	Profile               AccountProfile                `json:"profile"`
+	Preferences           *models.UserPreferences       `json:"preferences"`
+	Submissions           []models.Submission           `json:"submissions"`
+	Questions             []ExportedQuestion            `json:"questions"`
+	Drafts                []models.QuestionDraft        `json:"drafts"`
+	Clarifications        []models.Clarification        `json:"clarifications"`
+	ContestClarifications []models.ContestClarification `json:"contestClarifications"`
+	DifficultyVotes       []models.DifficultyVote       `json:"difficultyVotes"`
+	Achievements          []models.Achievement          `json:"achievements"`
+	Contests              []models.ContestRegistration  `json:"contestRegistrations"`
+	Groups                []models.GroupMember          `json:"groupMemberships"`
+	Collections           []models.Collection           `json:"collections"`
+	Notifications         []models.Notification         `json:"notifications"`
+	Sessions              []models.Session              `json:"sessions"`
+	Logins                []models.LoginEvent           `json:"logins"`
+	Tokens                []models.APIToken             `json:"tokens"`
+	Webhooks              []models.Webhook              `json:"webhooks"`
+	LinkedIdentities      []models.OAuthIdentity        `json:"linkedIdentities"`
+	DeletionScheduled     *time.Time                    `json:"deletionScheduledAt,omitempty"`
+}
+
+// AccountProfile is the user's own account, without the password hash
+type AccountProfile struct {
+	ID        uint            `json:"id"`
+	Username  string          `json:"username"`
+	Role      models.UserRole `json:"role"`
+	Email     string          `json:"email,omitempty"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// ExportedQuestion is a question the user wrote, with the parts that are
+// otherwise only served to its editors
+type ExportedQuestion struct {
+	models.Question
+	Editorial         string `json:"editorial"`
+	Hints             string `json:"hints"`
+	ReferenceSolution string `json:"referenceSolution"`
+}
+
+// AccountDeletionRequest asks for the user's account to be deleted, or for a
+// scheduled deletion to be cancelled. Accounts with a password confirm it.
+type AccountDeletionRequest struct {
+	Password string `json:"password" validate:"max=72"`
+	Cancel   bool   `json:"cancel"`
+}
+
+// AccountDeletionResponse describes the deletion of the user's account
+type AccountDeletionResponse struct {
+	Scheduled   bool       `json:"scheduled"`
+	ScheduledAt *time.Time `json:"scheduledAt,omitempty"` // When the deletion was asked for
+	DeleteAt    *time.Time `json:"deleteAt,omitempty"`    // When the account will be anonymized
+}
+
+// AccountExportHandler handles requests to /api/user/self/export
+func AccountExportHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		exportAccount(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// AccountDeletionHandler handles requests to /api/user/self/delete
+func AccountDeletionHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getAccountDeletion(w, r)
+	case http.MethodPost:
+		scheduleAccountDeletion(w, r)
+	case http.MethodDelete:
+		cancelAccountDeletion(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// loadAccount returns the signed in user, writing an error response when
+// there is none
+func loadAccount(w http.ResponseWriter, r *http.Request) (*gorm.DB, *models.User, bool) {
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	return db, &user, true
+}
+
+// exportAccount serves the user's data as a JSON file to download
+func exportAccount(w http.ResponseWriter, r *http.Request) {
+	db, user, ok := loadAccount(w, r)
+	if !ok {
+		return
+	}
+
+	export, err := buildAccountExport(r, db, user)
+	if err != nil {
+		log.Printf("Failed to export the data of user %d: %v", user.ID, err)
+		apierror.Write(w, r, "Failed to export account data", http.StatusInternalServerError)
+		return
+	}
+
+	filename := fmt.Sprintf("goera-%s-%s.json", user.Username, export.ExportedAt.Format("2006-01-02"))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", "attachment; filename="+strconv.Quote(filename))
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(export); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+	}
+}
+
+func buildAccountExport(r *http.Request, db *gorm.DB, user *models.User) (*AccountExport, error) {
+	export := AccountExport{
+		ExportedAt: time.Now().UTC(),
+		Profile: AccountProfile{
+			ID:        user.ID,
+			Username:  user.Username,
+			Role:      user.Role,
+			Email:     user.Email,
+			CreatedAt: user.CreatedAt,
+		},
+		DeletionScheduled: user.DeletionScheduledAt,
+	}
+
+	var prefs models.UserPreferences
+	err := db.Where("user_id = ?", user.ID).First(&prefs).Error
+	if err == nil {
+		export.Preferences = &prefs
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	byUser := db.Where("user_id = ?", user.ID).Order("id")
+	for _, list := range []interface{}{
+		&export.Drafts,
+		&export.Clarifications,
+		&export.ContestClarifications,
+		&export.DifficultyVotes,
+		&export.Achievements,
+		&export.Contests,
+		&export.Groups,
+		&export.Notifications,
+		&export.Sessions,
+		&export.Logins,
+		&export.Tokens,
+		&export.Webhooks,
+		&export.LinkedIdentities,
+	} {
+		if err := byUser.Session(&gorm.Session{}).Find(list).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if err := byUser.Session(&gorm.Session{}).Find(&export.Submissions).Error; err != nil {
+		return nil, err
+	}
+	for i := range export.Submissions {
+		submission := &export.Submissions[i]
+		if submission.ErrorRef != "" {
+			if submission.Error, err = storage.Load(r.Context(), submission.ErrorRef); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	err = db.Where("owner_id = ?", user.ID).Order("id").
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("position ASC") }).
+		Find(&export.Collections).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var questions []models.Question
+	if err := byUser.Session(&gorm.Session{}).Preload("TestCases").Find(&questions).Error; err != nil {
+		return nil, err
+	}
+	export.Questions = make([]ExportedQuestion, len(questions))
+	for i, question := range questions {
+		for j := range question.TestCases {
+			if err := question.TestCases[j].LoadData(r.Context()); err != nil {
+				return nil, err
+			}
+		}
+		export.Questions[i] = ExportedQuestion{
+			Question:          question,
+			Editorial:         question.Editorial,
+			Hints:             question.Hints,
+			ReferenceSolution: question.ReferenceSolution,
+		}
+	}
+	return &export, nil
+}
+
+func accountDeletionResponse(user *models.User) AccountDeletionResponse {
+	if user.DeletionScheduledAt == nil {
+		return AccountDeletionResponse{}
+	}
+	deleteAt := user.DeletionScheduledAt.Add(config.AccountDeletionGracePeriod)
+	return AccountDeletionResponse{
+		Scheduled:   true,
+		ScheduledAt: user.DeletionScheduledAt,
+		DeleteAt:    &deleteAt,
+	}
+}
+
+func getAccountDeletion(w http.ResponseWriter, r *http.Request) {
+	_, user, ok := loadAccount(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(accountDeletionResponse(user)); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// scheduleAccountDeletion schedules the user's account for deletion after
+// the grace period, during which it can still be used and the deletion
+// cancelled. Form posts from the profile page, which cannot send DELETE,
+// cancel with the cancel field and are redirected back to it.
+func scheduleAccountDeletion(w http.ResponseWriter, r *http.Request) {
+	var deletionReq AccountDeletionRequest
+	formProcessor := func(r *http.Request) (interface{}, error) {
+		return AccountDeletionRequest{
+			Password: r.PostFormValue("password"),
+			Cancel:   r.PostFormValue("cancel") == "true",
+		}, nil
+	}
+	result, err := utils.ProcessRequestData(r, &deletionReq, formProcessor)
+	if err != nil {
+		writeRequestDataError(w, r, err)
+		return
+	}
+	if formData, ok := result.(AccountDeletionRequest); ok {
+		deletionReq = formData
+	}
+	if deletionReq.Cancel {
+		cancelAccountDeletion(w, r)
+		return
+	}
+	if !validRequest(w, r, &deletionReq) {
+		return
+	}
+
+	db, user, ok := loadAccount(w, r)
+	if !ok {
+		return
+	}
+
+	// Accounts created through OAuth have no password to confirm
+	if user.Password != "" && !auth.CheckPasswordHash(deletionReq.Password, user.Password) {
+		if utils.IsFormRequest(r) {
+			http.Redirect(w, r, fmt.Sprintf("/profile/%d?error=wrong_password", user.ID), http.StatusSeeOther)
+			return
+		}
+		apierror.Write(w, r, "Incorrect password", http.StatusForbidden)
+		return
+	}
+
+	if user.DeletionScheduledAt == nil {
+		now := time.Now()
+		if err := db.Model(user).Update("deletion_scheduled_at", now).Error; err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to schedule account deletion", http.StatusInternalServerError)
+			return
+		}
+		user.DeletionScheduledAt = &now
+		sendAccountDeletionEmail(user)
+	}
+
+	if utils.IsFormRequest(r) {
+		http.Redirect(w, r, fmt.Sprintf("/profile/%d?success=deletion_scheduled", user.ID), http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(accountDeletionResponse(user)); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+func cancelAccountDeletion(w http.ResponseWriter, r *http.Request) {
+	db, user, ok := loadAccount(w, r)
+	if !ok {
+		return
+	}
+
+	if user.DeletionScheduledAt != nil {
+		if err := db.Model(user).Update("deletion_scheduled_at", nil).Error; err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to cancel account deletion", http.StatusInternalServerError)
+			return
+		}
+		user.DeletionScheduledAt = nil
+	}
+
+	if utils.IsFormRequest(r) {
+		http.Redirect(w, r, fmt.Sprintf("/profile/%d?success=deletion_cancelled", user.ID), http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(accountDeletionResponse(user)); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// sendAccountDeletionEmail tells a user with an email address when their
+// account will be deleted, in case someone else asked for it
+func sendAccountDeletionEmail(user *models.User) {
+	if user.Email == "" {
+		return
+	}
+	deleteAt := user.DeletionScheduledAt.Add(config.AccountDeletionGracePeriod)
+	body := fmt.Sprintf("Hi %s,\n\n"+
+		"Your Goera account is scheduled for deletion on %s. Until then you can cancel the deletion from your profile page.\n\n"+
+		"After that your personal data is removed, and the submissions and questions you made stay under an anonymous name.\n",
+		user.Username, deleteAt.UTC().Format("January 2, 2006 15:04 MST"))
+	if err := mail.Send(user.Email, "Your Goera account will be deleted", body); err != nil {
+		log.Printf("Failed to send account deletion email to user %d: %v", user.ID, err)
+	}
+}
+
+// StartAccountDeletionWorker anonymizes the accounts whose deletion grace
+// period has passed, on start and then every accountDeletionSweepInterval. It
+// blocks, so run it in its own goroutine.
+func StartAccountDeletionWorker() {
+	sweep := func() {
+		db := database.GetDB()
+		if db == nil {
+			log.Println("Account deletion: database connection is nil")
+			return
+		}
+		var users []models.User
+		err := db.Where("deletion_scheduled_at <= ?", time.Now().Add(-config.AccountDeletionGracePeriod)).Find(&users).Error
+		if err != nil {
+			log.Printf("Account deletion: failed to find accounts: %v", err)
+			return
+		}
+		for i := range users {
+			if err := anonymizeAccount(db, &users[i]); err != nil {
+				log.Printf("Account deletion: failed to anonymize user %d: %v", users[i].ID, err)
+				continue
+			}
+			log.Printf("Account deletion: anonymized user %d", users[i].ID)
+		}
+	}
+	sweep()
+
+	ticker := time.NewTicker(accountDeletionSweepInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		sweep()
+	}
+}
+
+// anonymizeAccount deletes the personal data of user and renames the account.
+// Submissions, questions, contests, groups and public clarifications are kept
+// for the scoreboards and problems that rely on them, attributed to the
+// anonymous account, which can no longer sign in.
+func anonymizeAccount(db *gorm.DB, user *models.User) error {
+	previousUsername := user.Username
+	err := db.Transaction(func(tx *gorm.DB) error {
+		byUser := tx.Unscoped().Where("user_id = ?", user.ID)
+		for _, model := range []interface{}{
+			&models.Session{},
+			&models.LoginEvent{},
+			&models.APIToken{},
+			&models.OAuthIdentity{},
+			&models.UserPreferences{},
+			&models.Notification{},
+			&models.QuestionDraft{},
+			&models.APIUsage{},
+			&models.UserQuota{},
+			&models.GroupMember{},
+		} {
+			if err := byUser.Session(&gorm.Session{}).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		webhooks := tx.Model(&models.Webhook{}).Select("id").Where("user_id = ?", user.ID)
+		if err := tx.Unscoped().Where("webhook_id IN (?)", webhooks).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		if err := byUser.Session(&gorm.Session{}).Delete(&models.Webhook{}).Error; err != nil {
+			return err
+		}
+		if err := byUser.Session(&gorm.Session{}).Where("public = ?", false).Delete(&models.Clarification{}).Error; err != nil {
+			return err
+		}
+		if err := byUser.Session(&gorm.Session{}).Where("public = ?", false).Delete(&models.ContestClarification{}).Error; err != nil {
+			return err
+		}
+		private := tx.Model(&models.Collection{}).Select("id").Where("owner_id = ? AND public = ?", user.ID, false)
+		if err := tx.Where("collection_id IN (?)", private).Delete(&models.CollectionQuestion{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("owner_id = ? AND public = ?", user.ID, false).Delete(&models.Collection{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("key = ?", "account:"+previousUsername).Delete(&models.LoginThrottle{}).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		return tx.Model(user).Updates(map[string]interface{}{
+			"username":              fmt.Sprintf("deleted-user-%d", user.ID),
+			"password":              "",
+			"email":                 "",
+			"role":                  models.RegularRole,
+			"deletion_scheduled_at": nil,
+			"anonymized_at":         now,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+	preferences.Invalidate(context.Background(), user.ID)
+	audit(db, user.ID, models.AuditAccountAnonymized, "user", user.ID, "")
+	return nil
+}
@@ -0,0 +1,296 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/config"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Thresholds of the milestone badges
+const (
+	solvedMilestone = 100 // Questions solved for BadgeSolved100
+	streakDays      = 30  // Days in a row for BadgeStreak30
+)
+
+// badgeInfo names and describes each badge for people
+var badgeInfo = map[models.Badge]struct{ title, description string }{
+	models.BadgeFirstSolve: {"First Solve", "First to solve a problem"},
+	models.BadgeSolved100:  {"Centurion", fmt.Sprintf("Solved %d problems", solvedMilestone)},
+	models.BadgeStreak30:   {"On Fire", fmt.Sprintf("Solved a problem on %d days in a row", streakDays)},
+}
+
+// AchievementResponse is a badge a user earned, as shown on their profile
+type AchievementResponse struct {
+	ID           uint         `json:"id"`
+	Badge        models.Badge `json:"badge"`
+	Title        string       `json:"title"`
+	Description  string       `json:"description"`
+	QuestionID   uint         `json:"questionId,omitempty"` // Set for a first solve
+	QuestionName string       `json:"questionName,omitempty"`
+	AwardedAt    time.Time    `json:"awardedAt"`
+}
+
+// UserAchievementsHandler handles requests to /api/user/{id}/achievements
+func UserAchievementsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getUserAchievements(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getUserAchievements lists the badges a user earned, newest first. Like the
+// rest of a profile, anyone may see them.
+func getUserAchievements(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	user, ok := profileUser(w, r, db)
+	if !ok {
+		return
+	}
+
+	var achievements []models.Achievement
+	if err := db.Where("user_id = ?", user.ID).Order("created_at DESC").Order("id DESC").Find(&achievements).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve achievements", http.StatusInternalServerError)
+		return
+	}
+
+	var questionIDs []uint
+	for _, achievement := range achievements {
+		if achievement.QuestionID != 0 {
+			questionIDs = append(questionIDs, achievement.QuestionID)
+		}
+	}
+	titles := map[uint]string{}
+	if len(questionIDs) > 0 {
+		var questions []models.Question
+		if err := db.Select("id, title").Where("id IN ?", questionIDs).Find(&questions).Error; err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve achievements", http.StatusInternalServerError)
+			return
+		}
+		for _, question := range questions {
+			titles[question.ID] = question.Title
+		}
+	}
+
+	response := make([]AchievementResponse, 0, len(achievements))
+	for _, achievement := range achievements {
+		info := badgeInfo[achievement.Badge]
+		response = append(response, AchievementResponse{
+			ID:           achievement.ID,
+			Badge:        achievement.Badge,
+			Title:        info.title,
+			Description:  info.description,
+			QuestionID:   achievement.QuestionID,
+			QuestionName: titles[achievement.QuestionID],
+			AwardedAt:    achievement.CreatedAt,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// StartAchievementWorker awards badges. It checks every accepted verdict
+// published on this instance as it comes in, and sweeps the accepted
+// submissions since its last sweep every config.AchievementSweepInterval,
+// starting with all of them, for verdicts it missed. Badges found by the first
+// sweep are awarded without notifications, so deploying the badges does not
+// notify users about old solves. It blocks, so run it in its own goroutine.
+func StartAchievementWorker() {
+	updates := subscribeSubmissions()
+	defer unsubscribeSubmissions(updates)
+
+	var lastSweep time.Time
+	sweep := func() {
+		db := database.GetDB()
+		if db == nil {
+			log.Println("Achievements: database connection is nil")
+			return
+		}
+		started := time.Now()
+		if err := sweepAchievements(db, lastSweep, started); err != nil {
+			log.Printf("Achievements: sweep failed: %v", err)
+			return
+		}
+		lastSweep = started
+	}
+	sweep()
+
+	ticker := time.NewTicker(config.AchievementSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case update := <-updates:
+			if update.JudgeStatus != models.Accepted {
+				continue
+			}
+			if db := database.GetDB(); db != nil {
+				if err := checkAchievements(db, update.UserID, update.QuestionID, true); err != nil {
+					log.Printf("Achievements: failed to check submission %d: %v", update.ID, err)
+				}
+			}
+		case <-ticker.C:
+			sweep()
+		}
+	}
+}
+
+// sweepAchievements checks the badges of the accepted submissions that
+// changed since since, or became public as their contest ended since then.
+// A zero since checks every submission and notifies no one.
+func sweepAchievements(db *gorm.DB, since, now time.Time) error {
+	notifyUsers := !since.IsZero()
+	type solve struct {
+		UserID     uint
+		QuestionID uint
+	}
+	var solves []solve
+	err := allPublicSubmissions(db).
+		Distinct("submissions.user_id", "submissions.question_id").
+		Where("submissions.judge_status = ?", models.Accepted).
+		Where("submissions.updated_at >= ? OR submissions.contest_id IN (?)", since,
+			db.Model(&models.Contest{}).Select("id").Where("end_time >= ? AND end_time <= ?", since, now)).
+		Scan(&solves).Error
+	if err != nil {
+		return err
+	}
+
+	// A user or question with several solves is checked once
+	users, questions := map[uint]bool{}, map[uint]bool{}
+	for _, s := range solves {
+		if !questions[s.QuestionID] {
+			questions[s.QuestionID] = true
+			if err := checkFirstSolve(db, s.QuestionID, notifyUsers); err != nil {
+				return err
+			}
+		}
+		if !users[s.UserID] {
+			users[s.UserID] = true
+			if err := checkMilestones(db, s.UserID, notifyUsers); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkAchievements awards the badges an accepted submission of userID to
+// questionID may have earned. Only public submissions count, so solving a
+// problem of a running contest earns nothing until the contest ends.
+func checkAchievements(db *gorm.DB, userID, questionID uint, notifyUsers bool) error {
+	if err := checkFirstSolve(db, questionID, notifyUsers); err != nil {
+		return err
+	}
+	return checkMilestones(db, userID, notifyUsers)
+}
+
+// checkFirstSolve gives BadgeFirstSolve to whoever solved questionID first,
+// leaving out its owner and co-authors
+func checkFirstSolve(db *gorm.DB, questionID uint, notifyUsers bool) error {
+	var first []models.Submission
+	err := allPublicSubmissions(db).
+		Select("submissions.id, submissions.user_id").
+		Where("submissions.question_id = ? AND submissions.judge_status = ?", questionID, models.Accepted).
+		Where("submissions.user_id <> questions.user_id").
+		Where("submissions.user_id NOT IN (?)",
+			db.Model(&models.QuestionContributor{}).Select("user_id").Where("question_id = ?", questionID)).
+		Order("submissions.submission_time").Order("submissions.id").
+		Limit(1).Scan(&first).Error
+	if err != nil || len(first) == 0 {
+		return err
+	}
+	return awardBadge(db, first[0].UserID, models.BadgeFirstSolve, questionID, first[0].ID, notifyUsers)
+}
+
+// checkMilestones gives userID the badges for how many questions they solved
+// and on how many days in a row
+func checkMilestones(db *gorm.DB, userID uint, notifyUsers bool) error {
+	var accepted []models.Submission
+	err := publicSubmissions(db, userID).
+		Select("submissions.id, submissions.question_id, submissions.submission_time").
+		Where("submissions.judge_status = ?", models.Accepted).
+		Order("submissions.submission_time").Order("submissions.id").
+		Scan(&accepted).Error
+	if err != nil {
+		return err
+	}
+
+	// The first accepted submission of each question solves it
+	solved := map[uint]bool{}
+	for _, submission := range accepted {
+		if solved[submission.QuestionID] {
+			continue
+		}
+		solved[submission.QuestionID] = true
+		if len(solved) == solvedMilestone {
+			if err := awardBadge(db, userID, models.BadgeSolved100, 0, submission.ID, notifyUsers); err != nil {
+				return err
+			}
+			break
+		}
+	}
+
+	// Days are counted in UTC, the first accepted submission of a day
+	// keeping the streak going
+	streak := 0
+	var lastDay time.Time
+	for _, submission := range accepted {
+		day := submission.SubmissionTime.UTC().Truncate(24 * time.Hour)
+		switch {
+		case day.Equal(lastDay):
+			continue
+		case day.Equal(lastDay.AddDate(0, 0, 1)):
+			streak++
+		default:
+			streak = 1
+		}
+		lastDay = day
+		if streak == streakDays {
+			return awardBadge(db, userID, models.BadgeStreak30, 0, submission.ID, notifyUsers)
+		}
+	}
+	return nil
+}
+
+// awardBadge gives userID a badge they do not have yet, notifying them when
+// notifyUser is set
+func awardBadge(db *gorm.DB, userID uint, badge models.Badge, questionID, submissionID uint, notifyUser bool) error {
+	achievement := models.Achievement{UserID: userID, Badge: badge, QuestionID: questionID, SubmissionID: submissionID}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&achievement)
+	if result.Error != nil || result.RowsAffected == 0 || !notifyUser {
+		return result.Error
+	}
+
+	info := badgeInfo[badge]
+	message := fmt.Sprintf("You earned the %s badge: %s", info.title, info.description)
+	if questionID != 0 {
+		var question models.Question
+		if err := db.Select("title").First(&question, questionID).Error; err == nil {
+			message = fmt.Sprintf("You earned the %s badge for %s", info.title, question.Title)
+		}
+	}
+	notify(db, userID, models.NotificationAchievement, message, fmt.Sprintf("/profile/%d", userID))
+	return nil
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+)
+
+const (
+	// overviewWindow is the period the submission figures of the overview cover
+	overviewWindow = 24 * time.Hour
+	// overviewSlowestQuestions is the number of slowest questions in the overview
+	overviewSlowestQuestions = 10
+)
+
+// QueueDepth counts the submissions that are waiting for a verdict
+type QueueDepth struct {
+	Pending int64 `json:"pending"` // Stored but not yet taken by the judge
+	Judging int64 `json:"judging"` // Taken by the judge, waiting for the callback
+	Outbox  int64 `json:"outbox"`  // Waiting to be sent again because the judge was unavailable
+}
+
+// SlowQuestion is a question ranked by the average execution time of its submissions
+type SlowQuestion struct {
+	QuestionID           uint    `json:"questionId"`
+	Title                string  `json:"title"`
+	Submissions          int64   `json:"submissions"`
+	AverageExecutionTime float64 `json:"averageExecutionTime"` // Milliseconds
+	MaxExecutionTime     int     `json:"maxExecutionTime"`     // Milliseconds
+}
+
+// AdminOverview is a snapshot of the system for operators. Submission figures
+// cover the last 24 hours.
+type AdminOverview struct {
+	GeneratedAt         time.Time                    `json:"generatedAt"`
+	Users               int64                        `json:"users"`
+	SubmissionsLast24h  int64                        `json:"submissionsLast24h"`
+	VerdictDistribution map[models.JudgeStatus]int64 `json:"verdictDistribution"`
+	Queue               QueueDepth                   `json:"queue"`
+	JudgeCircuit        circuitState                 `json:"judgeCircuit"` // closed, open or half_open
+	SlowestQuestions    []SlowQuestion               `json:"slowestQuestions"`
+}
+
+// AdminOverviewHandler handles requests to /api/admin/overview
+func AdminOverviewHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getAdminOverview(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getAdminOverview returns user, submission and queue figures to admins
+func getAdminOverview(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, "Only administrators can view the overview") {
+		return
+	}
+	db := database.GetDB()
+
+	now := time.Now()
+	since := now.Add(-overviewWindow)
+	overview := AdminOverview{
+		GeneratedAt:         now,
+		VerdictDistribution: map[models.JudgeStatus]int64{},
+		SlowestQuestions:    []SlowQuestion{},
+		JudgeCircuit:        judgeCircuit.current(),
+	}
+
+	if err := db.Model(&models.User{}).Count(&overview.Users).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to compute overview", http.StatusInternalServerError)
+		return
+	}
+
+	var verdicts []struct {
+		JudgeStatus models.JudgeStatus
+		Count       int64
+	}
+	if err := db.Model(&models.Submission{}).
+		Select("judge_status, COUNT(*) AS count").
+		Where("submission_time >= ?", since).
+		Group("judge_status").
+		Scan(&verdicts).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to compute overview", http.StatusInternalServerError)
+		return
+	}
+	for _, v := range verdicts {
+		overview.VerdictDistribution[v.JudgeStatus] = v.Count
+		overview.SubmissionsLast24h += v.Count
+	}
+
+	// The queue is counted over all submissions, old stuck ones included
+	var queued []struct {
+		JudgeStatus models.JudgeStatus
+		Count       int64
+	}
+	if err := db.Model(&models.Submission{}).
+		Select("judge_status, COUNT(*) AS count").
+		Where("judge_status IN ?", pendingStatuses).
+		Group("judge_status").
+		Scan(&queued).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to compute overview", http.StatusInternalServerError)
+		return
+	}
+	for _, q := range queued {
+		switch q.JudgeStatus {
+		case models.Pending:
+			overview.Queue.Pending = q.Count
+		case models.Judging:
+			overview.Queue.Judging = q.Count
+		}
+	}
+	if err := db.Model(&models.JudgeOutbox{}).Count(&overview.Queue.Outbox).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to compute overview", http.StatusInternalServerError)
+		return
+	}
+
+	if err := db.Model(&models.Submission{}).
+		Select("submissions.question_id, questions.title, COUNT(*) AS submissions, "+
+			"AVG(submissions.execution_time) AS average_execution_time, "+
+			"MAX(submissions.execution_time) AS max_execution_time").
+		Joins("JOIN questions ON questions.id = submissions.question_id").
+		Where("submissions.submission_time >= ? AND submissions.judge_status NOT IN ?", since, pendingStatuses).
+		Group("submissions.question_id, questions.title").
+		Order("average_execution_time DESC").
+		Limit(overviewSlowestQuestions).
+		Scan(&overview.SlowestQuestions).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to compute overview", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(overview); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
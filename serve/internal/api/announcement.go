@@ -0,0 +1,365 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/banners"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+	"goera/serve/internal/preferences"
+	"goera/serve/internal/utils"
+	"goera/serve/internal/validation"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// AnnouncementRequest represents the request body for posting or changing an announcement
+type AnnouncementRequest struct {
+	Title     string                   `json:"title" validate:"required,max=200"`
+	Body      string                   `json:"body" validate:"required,max=20000"` // Markdown
+	Pinned    bool                     `json:"pinned"`
+	Level     models.AnnouncementLevel `json:"level" validate:"omitempty,oneof=info warning critical"` // info (default), warning or critical
+	Banner    bool                     `json:"banner"`                                                 // Show it at the top of every page
+	StartsAt  string                   `json:"startsAt"`                                               // RFC 3339 or YYYY-MM-DD, empty to show it right away
+	ExpiresAt string                   `json:"expiresAt"`                                              // RFC 3339 or YYYY-MM-DD, empty to keep it up
+}
+
+// AnnouncementsHandler handles requests to /api/announcements
+func AnnouncementsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getAnnouncements(w, r)
+	case http.MethodPost:
+		createAnnouncement(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// AnnouncementHandler handles requests to /api/announcements/{id}
+func AnnouncementHandler(w http.ResponseWriter, r *http.Request) {
+	// Check for method override in form submissions
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err == nil {
+			if method := r.FormValue("_method"); method == "PUT" {
+				r.Method = http.MethodPut
+			} else if method == "DELETE" {
+				r.Method = http.MethodDelete
+			}
+		}
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		getAnnouncement(w, r)
+	case http.MethodPut:
+		updateAnnouncement(w, r)
+	case http.MethodDelete:
+		deleteAnnouncement(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// activeAnnouncements returns the announcements shown at now, pinned ones
+// first and then newest first
+func activeAnnouncements(db *gorm.DB, now time.Time, limit int) ([]models.Announcement, error) {
+	announcements := []models.Announcement{}
+	query := db.Where("expires_at IS NULL OR expires_at > ?", now).
+		Where("starts_at IS NULL OR starts_at <= ?", now).
+		Order("pinned DESC").Order("created_at DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Find(&announcements).Error
+	return announcements, err
+}
+
+// getAnnouncements lists the announcements that are up. Admins may list the
+// expired and scheduled ones too with all=true.
+func getAnnouncements(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	var announcements []models.Announcement
+	var err error
+	if r.URL.Query().Get("all") == "true" {
+		if !requireAdmin(w, r, "Only administrators can list expired and scheduled announcements") {
+			return
+		}
+		err = db.Order("created_at DESC").Find(&announcements).Error
+	} else {
+		announcements, err = activeAnnouncements(db, time.Now(), 0)
+	}
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve announcements", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(announcements); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// getAnnouncement returns one announcement. Expired and scheduled
+// announcements are only shown to admins.
+func getAnnouncement(w http.ResponseWriter, r *http.Request) {
+	announcement, ok := findAnnouncement(w, r)
+	if !ok {
+		return
+	}
+	if !announcement.Active(time.Now()) {
+		var user models.User
+		userID, userExists := auth.UserIDFromContext(r.Context())
+		if !userExists || database.GetDB().First(&user, userID).Error != nil || user.Role != models.AdminRole {
+			apierror.Write(w, r, "Announcement not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(announcement); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// parseAnnouncementRequest reads and validates the body of a post or change,
+// returning when the announcement starts and expires
+func parseAnnouncementRequest(w http.ResponseWriter, r *http.Request) (AnnouncementRequest, *time.Time, *time.Time, bool) {
+	var announcementReq AnnouncementRequest
+
+	formProcessor := func(r *http.Request) (interface{}, error) {
+		return AnnouncementRequest{
+			Title:     r.FormValue("title"),
+			Body:      r.FormValue("body"),
+			Pinned:    r.FormValue("pinned") == "true",
+			Level:     models.AnnouncementLevel(r.FormValue("level")),
+			Banner:    r.FormValue("banner") == "true",
+			StartsAt:  r.FormValue("startsAt"),
+			ExpiresAt: r.FormValue("expiresAt"),
+		}, nil
+	}
+
+	result, err := utils.ProcessRequestData(r, &announcementReq, formProcessor)
+	if err != nil {
+		writeRequestDataError(w, r, err)
+		return announcementReq, nil, nil, false
+	}
+
+	if formData, ok := result.(AnnouncementRequest); ok {
+		announcementReq = formData
+	}
+
+	if !validRequest(w, r, &announcementReq) {
+		return announcementReq, nil, nil, false
+	}
+	if announcementReq.Level == "" {
+		announcementReq.Level = models.AnnouncementInfo
+	}
+
+	var errs validation.Errors
+	loc := preferences.Location(preferences.FromContext(r.Context()))
+	startsAt, err := parseScheduleTime(announcementReq.StartsAt, false, loc)
+	if err != nil {
+		errs.Add("startsAt", "time", "%s must be a date or an RFC 3339 time")
+	}
+	expiresAt, err := parseScheduleTime(announcementReq.ExpiresAt, true, loc)
+	if err != nil {
+		errs.Add("expiresAt", "time", "%s must be a date or an RFC 3339 time")
+	}
+	if startsAt != nil && expiresAt != nil && !expiresAt.After(*startsAt) {
+		errs.Add("expiresAt", "after", "%s must be after %s", "startsAt")
+	}
+	if errs != nil {
+		writeInvalidFields(w, r, errs)
+		return announcementReq, nil, nil, false
+	}
+	return announcementReq, startsAt, expiresAt, true
+}
+
+// parseScheduleTime reads when an announcement starts or expires: RFC 3339,
+// a date, or the date and time of a datetime-local form field in the
+// admin's timezone. Empty means no time.
+func parseScheduleTime(value string, upperBound bool, loc *time.Location) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02T15:04", value, loc)
+	if err != nil {
+		t, err = parseTimeFilter(value, upperBound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// createAnnouncement lets an admin post an announcement
+func createAnnouncement(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, "Only administrators can post announcements") {
+		return
+	}
+
+	announcementReq, startsAt, expiresAt, ok := parseAnnouncementRequest(w, r)
+	if !ok {
+		return
+	}
+
+	userID, _ := auth.UserIDFromContext(r.Context())
+	db := database.GetDB()
+
+	announcement := models.Announcement{
+		Title:     announcementReq.Title,
+		Body:      announcementReq.Body,
+		UserID:    userID,
+		Pinned:    announcementReq.Pinned,
+		Level:     announcementReq.Level,
+		Banner:    announcementReq.Banner,
+		StartsAt:  startsAt,
+		ExpiresAt: expiresAt,
+	}
+
+	if err := db.Create(&announcement).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to post announcement", http.StatusInternalServerError)
+		return
+	}
+	banners.Invalidate()
+	audit(db, userID, models.AuditAnnouncementPosted, "announcement", announcement.ID,
+		fmt.Sprintf("Posted announcement %q", announcement.Title))
+
+	if utils.IsFormRequest(r) {
+		http.Redirect(w, r, "/?success=announcement_posted", http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(announcement); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// updateAnnouncement lets an admin change an announcement
+func updateAnnouncement(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, "Only administrators can edit announcements") {
+		return
+	}
+
+	announcementReq, startsAt, expiresAt, ok := parseAnnouncementRequest(w, r)
+	if !ok {
+		return
+	}
+
+	announcement, ok := findAnnouncement(w, r)
+	if !ok {
+		return
+	}
+
+	announcement.Title = announcementReq.Title
+	announcement.Body = announcementReq.Body
+	announcement.Pinned = announcementReq.Pinned
+	announcement.Level = announcementReq.Level
+	announcement.Banner = announcementReq.Banner
+	announcement.StartsAt = startsAt
+	announcement.ExpiresAt = expiresAt
+
+	db := database.GetDB()
+	if err := db.Save(&announcement).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to update announcement", http.StatusInternalServerError)
+		return
+	}
+	userID, _ := auth.UserIDFromContext(r.Context())
+	banners.Invalidate()
+	audit(db, userID, models.AuditAnnouncementEdited, "announcement", announcement.ID,
+		fmt.Sprintf("Edited announcement %q", announcement.Title))
+
+	if utils.IsFormRequest(r) {
+		http.Redirect(w, r, "/?success=announcement_updated", http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(announcement); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// deleteAnnouncement lets an admin take an announcement down
+func deleteAnnouncement(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, "Only administrators can delete announcements") {
+		return
+	}
+
+	announcement, ok := findAnnouncement(w, r)
+	if !ok {
+		return
+	}
+
+	db := database.GetDB()
+	if err := db.Delete(&announcement).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to delete announcement", http.StatusInternalServerError)
+		return
+	}
+	userID, _ := auth.UserIDFromContext(r.Context())
+	banners.Invalidate()
+	audit(db, userID, models.AuditAnnouncementDeleted, "announcement", announcement.ID,
+		fmt.Sprintf("Deleted announcement %q", announcement.Title))
+
+	if utils.IsFormRequest(r) {
+		http.Redirect(w, r, "/?success=announcement_deleted", http.StatusSeeOther)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// findAnnouncement loads the announcement of the request's {id}, writing the
+// error response when there is none
+func findAnnouncement(w http.ResponseWriter, r *http.Request) (models.Announcement, bool) {
+	var announcement models.Announcement
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid announcement ID", http.StatusBadRequest)
+		return announcement, false
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return announcement, false
+	}
+
+	if err := db.First(&announcement, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Announcement not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve announcement", http.StatusInternalServerError)
+		}
+		return announcement, false
+	}
+	return announcement, true
+}
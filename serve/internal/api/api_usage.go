@@ -0,0 +1,289 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/config"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+	"goera/serve/internal/quota"
+	"goera/serve/internal/validation"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// usageHistoryDays is how many days of calls a usage report covers
+const usageHistoryDays = 7
+
+// QuotaUsage is how much of one daily quota a user has used today
+type QuotaUsage struct {
+	Kind      string `json:"kind"`
+	Limit     int    `json:"limit"`     // 0 when the kind is not limited
+	Custom    bool   `json:"custom"`    // Whether an admin set the limit for this user
+	Used      int64  `json:"used"`      // Calls made today
+	Remaining *int64 `json:"remaining"` // Null when the kind is not limited
+}
+
+// TokenUsage is how many calls of one kind a user made today with one token
+type TokenUsage struct {
+	TokenID   uint   `json:"tokenId"`   // 0 for calls made with a login session
+	TokenName string `json:"tokenName"` // Empty for calls made with a login session
+	Kind      string `json:"kind"`
+	Calls     int64  `json:"calls"`
+}
+
+// DailyUsage is how many calls of one kind a user made on one day
+type DailyUsage struct {
+	Day   string `json:"day"`
+	Kind  string `json:"kind"`
+	Calls int64  `json:"calls"`
+}
+
+// UsageReport shows a user their API calls and what is left of their quotas
+type UsageReport struct {
+	UserID  uint         `json:"userId"`
+	Day     string       `json:"day"`     // Today, in UTC
+	ResetAt time.Time    `json:"resetAt"` // When the quotas renew
+	Quotas  []QuotaUsage `json:"quotas"`
+	Tokens  []TokenUsage `json:"tokens"`  // Today's calls by token
+	History []DailyUsage `json:"history"` // Calls of the last days, newest first
+}
+
+// UserUsageHandler handles requests to /api/user/usage
+func UserUsageHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getUserUsage(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// UserQuotasHandler handles requests to /api/admin/users/{id}/quotas
+func UserQuotasHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getUserQuotas(w, r)
+	case http.MethodPut:
+		setUserQuotas(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getUserUsage shows the requesting user their API usage
+func getUserUsage(w http.ResponseWriter, r *http.Request) {
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	writeUsageReport(w, r, db, userID)
+}
+
+// getUserQuotas shows an admin the API usage and quotas of a user
+func getUserQuotas(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, "Only administrators can view the quotas of other users") {
+		return
+	}
+	user, ok := quotaUser(w, r)
+	if !ok {
+		return
+	}
+	writeUsageReport(w, r, database.GetDB(), user.ID)
+}
+
+// setUserQuotas lets an admin change the daily quotas of a user. The body maps
+// kinds to limits; 0 lifts the limit and null goes back to the default.
+func setUserQuotas(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, "Only administrators can change quotas") {
+		return
+	}
+	user, ok := quotaUser(w, r)
+	if !ok {
+		return
+	}
+
+	var quotaReq map[string]*int
+	if err := json.NewDecoder(r.Body).Decode(&quotaReq); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+	var errs validation.Errors
+	for _, kind := range slices.Sorted(maps.Keys(quotaReq)) {
+		limit := quotaReq[kind]
+		if _, ok := config.DailyQuotas[kind]; !ok {
+			errs.Add(kind, "known", "%s is not a known quota")
+		} else if limit != nil && *limit < 0 {
+			errs.Add(kind, "min", "%s must be at least %d", 0)
+		}
+	}
+	if errs != nil {
+		writeInvalidFields(w, r, errs)
+		return
+	}
+
+	adminID, _ := auth.UserIDFromContext(r.Context())
+	db := database.GetDB()
+	var changes []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for kind, limit := range quotaReq {
+			if limit == nil {
+				if err := tx.Unscoped().Where("user_id = ? AND kind = ?", user.ID, kind).Delete(&models.UserQuota{}).Error; err != nil {
+					return err
+				}
+				changes = append(changes, kind+"=default")
+				continue
+			}
+			override := models.UserQuota{UserID: user.ID, Kind: kind, DailyLimit: *limit, UpdatedBy: adminID}
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "user_id"}, {Name: "kind"}},
+				DoUpdates: clause.AssignmentColumns([]string{"daily_limit", "updated_by", "updated_at"}),
+			}).Create(&override).Error
+			if err != nil {
+				return err
+			}
+			changes = append(changes, fmt.Sprintf("%s=%d", kind, *limit))
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to change quotas", http.StatusInternalServerError)
+		return
+	}
+	if len(changes) > 0 {
+		slices.Sort(changes)
+		audit(db, adminID, models.AuditQuotaChanged, "user", user.ID, strings.Join(changes, ", "))
+	}
+
+	writeUsageReport(w, r, db, user.ID)
+}
+
+// quotaUser loads the user named in the path, writing an error response when
+// that fails
+func quotaUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	userID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		apierror.Write(w, r, "Invalid user ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	var user models.User
+	if err := database.GetDB().First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "User not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+	return &user, true
+}
+
+// writeUsageReport responds with the usage report of userID
+func writeUsageReport(w http.ResponseWriter, r *http.Request, db *gorm.DB, userID uint) {
+	report, err := usageReport(db, userID, time.Now())
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve API usage", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// usageReport collects the API usage of userID as of now
+func usageReport(db *gorm.DB, userID uint, now time.Time) (*UsageReport, error) {
+	today := quota.Day(now)
+	report := &UsageReport{
+		UserID:  userID,
+		Day:     today,
+		ResetAt: quota.ResetAt(now),
+		Quotas:  []QuotaUsage{},
+		Tokens:  []TokenUsage{},
+		History: []DailyUsage{},
+	}
+
+	limits, err := quota.Limits(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	var custom []string
+	if err := db.Model(&models.UserQuota{}).Where("user_id = ?", userID).Pluck("kind", &custom).Error; err != nil {
+		return nil, err
+	}
+
+	var usages []models.APIUsage
+	if err := db.Where("user_id = ? AND day = ?", userID, today).Order("token_id").Order("kind").Find(&usages).Error; err != nil {
+		return nil, err
+	}
+	used := map[string]int64{}
+	for _, usage := range usages {
+		used[usage.Kind] += usage.Calls
+	}
+
+	for _, kind := range slices.Sorted(maps.Keys(limits)) {
+		quotaUsage := QuotaUsage{Kind: kind, Limit: limits[kind], Custom: slices.Contains(custom, kind), Used: used[kind]}
+		if quotaUsage.Limit > 0 {
+			remaining := max(int64(quotaUsage.Limit)-quotaUsage.Used, 0)
+			quotaUsage.Remaining = &remaining
+		}
+		report.Quotas = append(report.Quotas, quotaUsage)
+	}
+
+	var tokens []models.APIToken
+	if err := db.Unscoped().Where("user_id = ?", userID).Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	tokenNames := map[uint]string{}
+	for _, token := range tokens {
+		tokenNames[token.ID] = token.Name
+	}
+	for _, usage := range usages {
+		report.Tokens = append(report.Tokens, TokenUsage{
+			TokenID:   usage.TokenID,
+			TokenName: tokenNames[usage.TokenID],
+			Kind:      usage.Kind,
+			Calls:     usage.Calls,
+		})
+	}
+
+	since := quota.Day(now.AddDate(0, 0, -(usageHistoryDays - 1)))
+	err = db.Model(&models.APIUsage{}).
+		Select("day, kind, SUM(calls) AS calls").
+		Where("user_id = ? AND day >= ?", userID, since).
+		Group("day, kind").Order("day DESC").Order("kind").
+		Scan(&report.History).Error
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AttemptStatus tells a user how many more submissions a question accepts
+// from them and when the next one is allowed
+type AttemptStatus struct {
+	MaxAttempts     int        `json:"maxAttempts"`          // 0 when attempts are not limited
+	Used            int64      `json:"used"`                 // Submissions made so far
+	Remaining       *int64     `json:"remaining"`            // Null when attempts are not limited
+	CooldownSeconds int        `json:"cooldownSeconds"`      // 0 when there is no cooldown
+	NextAttemptAt   *time.Time `json:"nextAttemptAt"`        // Set while the cooldown is running
+	RetryAfter      int        `json:"retryAfter,omitempty"` // Seconds left of the cooldown
+}
+
+// parseAttemptLimits reads the attempt limit fields of the question forms
+func parseAttemptLimits(r *http.Request, req *QuestionRequest) error {
+	if value := r.FormValue("max_attempts"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid max attempts: %v", err)
+		}
+		req.MaxAttempts = n
+	}
+	if value := r.FormValue("attempt_cooldown_seconds"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid attempt cooldown: %v", err)
+		}
+		req.AttemptCooldown = n
+	}
+	return nil
+}
+
+// AttemptsHandler handles requests to /api/questions/{id}/attempts
+func AttemptsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getAttempts(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getAttempts shows the signed in user their attempts at a question
+func getAttempts(w http.ResponseWriter, r *http.Request) {
+	questionID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		apierror.Write(w, r, "Invalid question ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	var question models.Question
+	if err := db.First(&question, questionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Question not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve question", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	status, err := attemptStatus(db, &question, userID, time.Now())
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve submissions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// attemptStatus counts the submissions userID made to question. Authors and
+// co-authors are never limited on their own questions.
+func attemptStatus(db *gorm.DB, question *models.Question, userID uint, now time.Time) (AttemptStatus, error) {
+	status := AttemptStatus{}
+	if question.UserID == userID {
+		return status, nil
+	}
+	if contributor, err := isContributor(db, question.ID, userID); err != nil || contributor {
+		return status, err
+	}
+	status.MaxAttempts = question.MaxAttempts
+	status.CooldownSeconds = question.AttemptCooldown
+
+	if err := db.Model(&models.Submission{}).
+		Where("question_id = ? AND user_id = ?", question.ID, userID).
+		Count(&status.Used).Error; err != nil {
+		return status, err
+	}
+	if question.MaxAttempts > 0 {
+		remaining := max(int64(question.MaxAttempts)-status.Used, 0)
+		status.Remaining = &remaining
+	}
+
+	if question.AttemptCooldown > 0 && status.Used > 0 {
+		var last models.Submission
+		if err := db.Select("submission_time").
+			Where("question_id = ? AND user_id = ?", question.ID, userID).
+			Order("submission_time DESC").First(&last).Error; err != nil {
+			return status, err
+		}
+		next := last.SubmissionTime.Add(time.Duration(question.AttemptCooldown) * time.Second)
+		if next.After(now) {
+			status.NextAttemptAt = &next
+			status.RetryAfter = int(math.Ceil(next.Sub(now).Seconds()))
+		}
+	}
+	return status, nil
+}
+
+// errAttemptRefused is returned by checkAttempts when the question's attempt
+// limit or cooldown refuses a submission
+var errAttemptRefused = errors.New("attempt refused by the question's limits")
+
+// checkAttempts checks a submission against the question's attempt limit and
+// cooldown in tx, the transaction that stores it. The user's row is locked
+// first, so submissions the user makes at the same time are counted one after
+// another and cannot all get past the limit. A refused submission gets
+// errAttemptRefused, with the status to report.
+func checkAttempts(tx *gorm.DB, question *models.Question, userID uint) (AttemptStatus, error) {
+	if question.MaxAttempts == 0 && question.AttemptCooldown == 0 {
+		return AttemptStatus{}, nil
+	}
+
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.User{}, userID).Error; err != nil {
+		return AttemptStatus{}, err
+	}
+	status, err := attemptStatus(tx, question, userID, time.Now())
+	if err != nil {
+		return status, err
+	}
+	if (status.Remaining != nil && *status.Remaining == 0) || status.NextAttemptAt != nil {
+		return status, errAttemptRefused
+	}
+	return status, nil
+}
+
+// writeAttemptRefused writes the error response for a submission that
+// checkAttempts refused
+func writeAttemptRefused(w http.ResponseWriter, r *http.Request, status AttemptStatus) {
+	if status.Remaining != nil && *status.Remaining == 0 {
+		apierror.WriteDetails(w, r, "You have used all your attempts at this question", http.StatusForbidden, status)
+		return
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(status.RetryAfter))
+	apierror.WriteDetails(w, r, "Please wait before submitting to this question again", http.StatusTooManyRequests, status)
+}
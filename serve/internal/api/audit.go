@@ -0,0 +1,147 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+	"goera/serve/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// AuditEntry is an audit log entry with the name of the user who acted
+type AuditEntry struct {
+	models.AuditLog
+	ActorName string `json:"actorName"`
+}
+
+// AuditLogHandler handles requests to /api/admin/audit
+func AuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getAuditLog(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// audit records an administrative action in the audit log. targetID is the
+// ID of what was acted on, a number or a string. Like notify, audit only logs
+// failures so the action itself still succeeds.
+func audit(db *gorm.DB, actorID uint, action models.AuditAction, targetType string, targetID any, details string) {
+	entry := models.AuditLog{
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   fmt.Sprint(targetID),
+		Details:    details,
+	}
+	if err := db.Create(&entry).Error; err != nil {
+		log.Printf("Failed to record %s by user %d in the audit log: %v", action, actorID, err)
+	}
+}
+
+// getAuditLog lists audit log entries newest first. Entries can be filtered by
+// actorId, action, targetType, targetId and a from/to date range.
+func getAuditLog(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, "Only administrators can view the audit log") {
+		return
+	}
+
+	db := database.GetDB()
+	query := db.Model(&models.AuditLog{})
+
+	if actorStr := r.URL.Query().Get("actorId"); actorStr != "" {
+		actorID, err := strconv.ParseUint(actorStr, 10, 64)
+		if err != nil {
+			apierror.Write(w, r, "Invalid actorId", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("actor_id = ?", actorID)
+	}
+	if action := r.URL.Query().Get("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if targetType := r.URL.Query().Get("targetType"); targetType != "" {
+		query = query.Where("target_type = ?", targetType)
+	}
+	if targetID := r.URL.Query().Get("targetId"); targetID != "" {
+		query = query.Where("target_id = ?", targetID)
+	}
+	if fromStr := r.URL.Query().Get("from"); fromStr != "" {
+		from, err := parseTimeFilter(fromStr, false)
+		if err != nil {
+			apierror.Write(w, r, "Invalid from date", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("created_at >= ?", from)
+	}
+	if toStr := r.URL.Query().Get("to"); toStr != "" {
+		to, err := parseTimeFilter(toStr, true)
+		if err != nil {
+			apierror.Write(w, r, "Invalid to date", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("created_at <= ?", to)
+	}
+
+	pagination := utils.ParsePagination(r, "audit")
+
+	var totalItems int64
+	if err := query.Count(&totalItems).Error; err != nil {
+		log.Printf("Database error counting audit log entries: %v", err)
+		apierror.Write(w, r, "Failed to count audit log entries", http.StatusInternalServerError)
+		return
+	}
+
+	var logs []models.AuditLog
+	if err := query.Order("created_at DESC, id DESC").Limit(pagination.PageSize).Offset(pagination.Offset()).Find(&logs).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve audit log", http.StatusInternalServerError)
+		return
+	}
+
+	actorIDs := make([]uint, 0, len(logs))
+	for _, entry := range logs {
+		actorIDs = append(actorIDs, entry.ActorID)
+	}
+	var actors []models.User
+	if len(actorIDs) > 0 {
+		if err := db.Unscoped().Select("id", "username").Where("id IN ?", actorIDs).Find(&actors).Error; err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve users", http.StatusInternalServerError)
+			return
+		}
+	}
+	names := make(map[uint]string, len(actors))
+	for _, actor := range actors {
+		names[actor.ID] = actor.Username
+	}
+
+	entries := make([]AuditEntry, 0, len(logs))
+	for _, entry := range logs {
+		entries = append(entries, AuditEntry{AuditLog: entry, ActorName: names[entry.ActorID]})
+	}
+
+	totalPages := utils.TotalPages(totalItems, pagination.PageSize)
+	response := PaginatedResponse{
+		Data:       entries,
+		Page:       pagination.Page,
+		PageSize:   pagination.PageSize,
+		TotalItems: totalItems,
+		TotalPages: totalPages,
+	}
+
+	utils.SetPageLinks(w, r, pagination, totalPages)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
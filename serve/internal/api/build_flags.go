@@ -0,0 +1,51 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Values allowed for the compiler and linker flags of a question's build
+// flags. The code-runner checks the same allowlist before building, this
+// copy only tells authors early.
+var (
+	allowedGCFlags = map[string]bool{"-N": true, "-l": true, "-B": true}
+	allowedLDFlags = map[string]bool{"-s": true, "-w": true}
+)
+
+// buildTagPattern matches the build tags -tags may set
+var buildTagPattern = regexp.MustCompile(`^[A-Za-z0-9_.]+$`)
+
+// normalizeBuildFlags cleans up a space separated list of go build flags and
+// checks each against the allowlist: -tags=a,b, -gcflags=-N, -l or -B,
+// -ldflags=-s or -w, and -trimpath
+func normalizeBuildFlags(value string) (string, error) {
+	flags := strings.Fields(value)
+	for _, flag := range flags {
+		name, value, hasValue := strings.Cut(flag, "=")
+		switch name {
+		case "-tags":
+			for _, tag := range strings.Split(value, ",") {
+				if !buildTagPattern.MatchString(tag) {
+					return "", fmt.Errorf("invalid build tag %q", tag)
+				}
+			}
+		case "-gcflags":
+			if !allowedGCFlags[value] {
+				return "", fmt.Errorf("compiler flag %q is not allowed, use -N, -l or -B", value)
+			}
+		case "-ldflags":
+			if !allowedLDFlags[value] {
+				return "", fmt.Errorf("linker flag %q is not allowed, use -s or -w", value)
+			}
+		case "-trimpath":
+			if hasValue {
+				return "", fmt.Errorf("build flag %q takes no value", name)
+			}
+		default:
+			return "", fmt.Errorf("build flag %q is not allowed, use -tags, -gcflags, -ldflags or -trimpath", flag)
+		}
+	}
+	return strings.Join(flags, " "), nil
+}
@@ -0,0 +1,324 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+	"goera/serve/internal/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// ClarificationRequest represents the request body for asking a clarification
+type ClarificationRequest struct {
+	Body string `json:"body" validate:"required,max=5000"`
+}
+
+// ClarificationAnswerRequest represents the request body for answering a clarification
+type ClarificationAnswerRequest struct {
+	Answer string `json:"answer" validate:"required,max=5000"`
+	Public bool   `json:"public"`
+}
+
+// ClarificationsHandler handles requests to /api/questions/{id}/clarifications
+func ClarificationsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getClarifications(w, r)
+	case http.MethodPost:
+		createClarification(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ClarificationAnswerHandler handles requests to /api/clarifications/{id}/answer
+func ClarificationAnswerHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPut, http.MethodPost:
+		answerClarification(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getClarifications lists the clarifications of a question. Everyone sees public
+// answers and their own clarifications; admins and the author see all of them.
+func getClarifications(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	questionID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid question ID", http.StatusBadRequest)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var question models.Question
+	if err := db.First(&question, questionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Question not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve question", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+		return
+	}
+
+	canManage := canEditQuestion(db, &question, &user)
+	if !canManage && !questionVisible(w, r, db, &question, userID) {
+		return
+	}
+
+	query := db.Where("question_id = ?", question.ID)
+	if !canManage {
+		query = query.Where("(public = ? AND answered_at IS NOT NULL) OR user_id = ?", true, userID)
+	}
+
+	var clarifications []models.Clarification
+	if err := query.Order("created_at DESC").Find(&clarifications).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve clarifications", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(clarifications); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// questionVisible checks that a user who cannot edit question may see it, so
+// its clarifications are no wider open than the question itself. It writes a
+// not found response and returns false when they may not.
+func questionVisible(w http.ResponseWriter, r *http.Request, db *gorm.DB, question *models.Question, userID uint) bool {
+	visible, err := visibleToReader(db, question, userID)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve contest", http.StatusInternalServerError)
+		return false
+	}
+	if !visible {
+		apierror.Write(w, r, "Question not found", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+func createClarification(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	questionID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid question ID", http.StatusBadRequest)
+		return
+	}
+
+	var clarificationReq ClarificationRequest
+
+	formProcessor := func(r *http.Request) (interface{}, error) {
+		return ClarificationRequest{Body: r.FormValue("body")}, nil
+	}
+
+	result, err := utils.ProcessRequestData(r, &clarificationReq, formProcessor)
+	if err != nil {
+		writeRequestDataError(w, r, err)
+		return
+	}
+
+	if formData, ok := result.(ClarificationRequest); ok {
+		clarificationReq = formData
+	}
+
+	if !validRequest(w, r, &clarificationReq) {
+		return
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	var question models.Question
+	if err := db.First(&question, questionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Question not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve question", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+		return
+	}
+	if !canEditQuestion(db, &question, &user) && !questionVisible(w, r, db, &question, userID) {
+		return
+	}
+
+	clarification := models.Clarification{
+		QuestionID: question.ID,
+		UserID:     userID,
+		Body:       clarificationReq.Body,
+	}
+
+	if err := db.Create(&clarification).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to create clarification", http.StatusInternalServerError)
+		return
+	}
+
+	if utils.IsFormRequest(r) {
+		http.Redirect(w, r, fmt.Sprintf("/question/%d?success=clarification_asked", question.ID), http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(clarification); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// answerClarification lets an admin or the question's author answer a clarification,
+// either publicly or privately to the asker
+func answerClarification(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid clarification ID", http.StatusBadRequest)
+		return
+	}
+
+	var answerReq ClarificationAnswerRequest
+
+	formProcessor := func(r *http.Request) (interface{}, error) {
+		return ClarificationAnswerRequest{
+			Answer: r.FormValue("answer"),
+			Public: r.FormValue("public") == "true",
+		}, nil
+	}
+
+	result, err := utils.ProcessRequestData(r, &answerReq, formProcessor)
+	if err != nil {
+		writeRequestDataError(w, r, err)
+		return
+	}
+
+	if formData, ok := result.(ClarificationAnswerRequest); ok {
+		answerReq = formData
+	}
+
+	if !validRequest(w, r, &answerReq) {
+		return
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	var clarification models.Clarification
+	if err := db.Preload("Question").First(&clarification, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Clarification not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve clarification", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+		return
+	}
+
+	if !canEditQuestion(db, &clarification.Question, &user) {
+		apierror.Write(w, r, "Only administrators or the question authors can answer clarifications", http.StatusForbidden)
+		return
+	}
+
+	now := time.Now()
+	clarification.Answer = answerReq.Answer
+	clarification.Public = answerReq.Public
+	clarification.AnsweredBy = &user.ID
+	clarification.AnsweredAt = &now
+
+	if err := db.Save(&clarification).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to answer clarification", http.StatusInternalServerError)
+		return
+	}
+
+	if clarification.UserID != userID {
+		notify(db, clarification.UserID, models.NotificationClarificationAnswered,
+			fmt.Sprintf("Your clarification on %s was answered", clarification.Question.Title),
+			fmt.Sprintf("/question/%d#clarifications", clarification.QuestionID))
+	}
+
+	if utils.IsFormRequest(r) {
+		http.Redirect(w, r, fmt.Sprintf("/question/%d?success=clarification_answered", clarification.QuestionID), http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(clarification); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,549 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+	"goera/serve/internal/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// CollectionRequest represents the request body for creating or changing a
+// collection. QuestionIDs lists the questions in the order they are shown in.
+type CollectionRequest struct {
+	Title       string `json:"title" validate:"required,max=200"`
+	Description string `json:"description" validate:"max=20000"`
+	Public      bool   `json:"public"`
+	QuestionIDs []uint `json:"questionIds" validate:"max=500,unique"`
+}
+
+// CollectionQuestionSummary is a question as listed in a collection
+type CollectionQuestionSummary struct {
+	ID         uint                      `json:"id"`
+	Title      string                    `json:"title"`
+	Difficulty models.Difficulty         `json:"difficulty"`
+	Tags       string                    `json:"tags"`
+	UserStatus models.UserQuestionStatus `json:"userStatus,omitempty"` // Only set for signed in users
+}
+
+// CollectionResponse is a collection with the questions in it that the
+// requester may see, in order
+type CollectionResponse struct {
+	models.Collection
+	Questions []CollectionQuestionSummary `json:"questions"`
+}
+
+// CollectionsHandler handles requests to /api/collections
+func CollectionsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getCollections(w, r)
+	case http.MethodPost:
+		createCollection(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// CollectionHandler handles requests to /api/collections/{id}. Forms post
+// their changes, as they cannot send PUT.
+func CollectionHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getCollectionByID(w, r)
+	case http.MethodPut, http.MethodPost:
+		updateCollection(w, r)
+	case http.MethodDelete:
+		deleteCollection(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// parseQuestionIDs reads a list of question IDs separated by commas or
+// whitespace, as typed into a form
+func parseQuestionIDs(value string) ([]uint, error) {
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n' || r == '\r' || r == '\t'
+	})
+	ids := make([]uint, 0, len(fields))
+	for _, field := range fields {
+		id, err := strconv.ParseUint(strings.TrimPrefix(field, "#"), 10, 32)
+		if err != nil || id == 0 {
+			return nil, fmt.Errorf("invalid question ID %q", field)
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+// readCollectionRequest reads and validates a collection from a JSON body or
+// a form, writing an error response and returning false when it is invalid
+func readCollectionRequest(w http.ResponseWriter, r *http.Request) (*CollectionRequest, bool) {
+	var collectionReq CollectionRequest
+	formProcessor := func(r *http.Request) (interface{}, error) {
+		questionIDs, err := parseQuestionIDs(r.FormValue("question_ids"))
+		if err != nil {
+			return nil, err
+		}
+		// A checkbox follows a hidden field of the same name, so the last
+		// value is the one that counts
+		public := false
+		if values := r.PostForm["public"]; len(values) > 0 {
+			public = values[len(values)-1] == "true"
+		}
+		return CollectionRequest{
+			Title:       r.FormValue("title"),
+			Description: r.FormValue("description"),
+			Public:      public,
+			QuestionIDs: questionIDs,
+		}, nil
+	}
+
+	result, err := utils.ProcessRequestData(r, &collectionReq, formProcessor)
+	if err != nil {
+		writeRequestDataError(w, r, err)
+		return nil, false
+	}
+	if formData, ok := result.(CollectionRequest); ok {
+		collectionReq = formData
+	}
+	collectionReq.Title = strings.TrimSpace(collectionReq.Title)
+
+	if !validRequest(w, r, &collectionReq) {
+		return nil, false
+	}
+	return &collectionReq, true
+}
+
+// findCollection loads the collection named in the URL. Public collections
+// are found by anyone, private ones only by their owner and admins. manage
+// reports whether the requester may change it, which only the owner and
+// admins can.
+func findCollection(w http.ResponseWriter, r *http.Request, db *gorm.DB) (collection *models.Collection, manage bool, ok bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid collection ID", http.StatusBadRequest)
+		return nil, false, false
+	}
+
+	collection = &models.Collection{}
+	if err := db.Preload("Owner").First(collection, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Collection not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve collection", http.StatusInternalServerError)
+		}
+		return nil, false, false
+	}
+	collection.OwnerName = collection.Owner.Username
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if userExists && collection.OwnerID == userID {
+		return collection, true, true
+	}
+	if userExists {
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+			return nil, false, false
+		}
+		if user.Role == models.AdminRole {
+			return collection, true, true
+		}
+	}
+	if !collection.Public {
+		// Outsiders are not told that a private collection exists
+		apierror.Write(w, r, "Collection not found", http.StatusNotFound)
+		return nil, false, false
+	}
+	return collection, false, true
+}
+
+// checkCollectionQuestions makes sure the requester can see every question
+// they put in a collection, writing an error response and returning false
+// when one is missing
+func checkCollectionQuestions(w http.ResponseWriter, r *http.Request, db *gorm.DB, userID uint, questionIDs []uint) bool {
+	if len(questionIDs) == 0 {
+		return true
+	}
+
+	visible, _, err := visibleQuestions(db, userID, true)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve questions", http.StatusInternalServerError)
+		return false
+	}
+	var found int64
+	if err := visible.Model(&models.Question{}).Where("id IN ?", questionIDs).Count(&found).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve questions", http.StatusInternalServerError)
+		return false
+	}
+	if int(found) != len(questionIDs) {
+		apierror.Write(w, r, "Question not found", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// saveCollectionQuestions replaces the questions of a collection with
+// questionIDs, in that order
+func saveCollectionQuestions(tx *gorm.DB, collectionID uint, questionIDs []uint) error {
+	if err := tx.Where("collection_id = ?", collectionID).Delete(&models.CollectionQuestion{}).Error; err != nil {
+		return err
+	}
+	if len(questionIDs) == 0 {
+		return nil
+	}
+	items := make([]models.CollectionQuestion, len(questionIDs))
+	for i, questionID := range questionIDs {
+		items[i] = models.CollectionQuestion{CollectionID: collectionID, QuestionID: questionID, Position: i}
+	}
+	return tx.Create(&items).Error
+}
+
+// respondWithCollection answers a change to a collection, sending form posts
+// from the collection pages to the collection's page
+func respondWithCollection(w http.ResponseWriter, r *http.Request, collection *models.Collection, status int) {
+	if utils.IsFormRequest(r) {
+		http.Redirect(w, r, fmt.Sprintf("/collection/%d", collection.ID), http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(collection); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// getCollections lists public collections, newest first. With mine=true it
+// lists the requester's own collections instead, private ones included.
+func getCollections(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	query := db.Model(&models.Collection{})
+	if r.URL.Query().Get("mine") == "true" {
+		userID, userExists := auth.UserIDFromContext(r.Context())
+		if !userExists {
+			apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		query = query.Where("owner_id = ?", userID)
+	} else {
+		query = query.Where("public = ?", true)
+	}
+
+	pagination := utils.ParsePagination(r, "collections")
+
+	var totalItems int64
+	if err := query.Session(&gorm.Session{}).Count(&totalItems).Error; err != nil {
+		log.Printf("Database error counting collections: %v", err)
+		apierror.Write(w, r, "Failed to count collections", http.StatusInternalServerError)
+		return
+	}
+
+	collections := []models.Collection{}
+	err := query.Preload("Owner").
+		Order("created_at DESC").Order("id DESC").
+		Limit(pagination.PageSize).Offset(pagination.Offset()).
+		Find(&collections).Error
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve collections", http.StatusInternalServerError)
+		return
+	}
+
+	ids := make([]uint, len(collections))
+	for i, collection := range collections {
+		ids[i] = collection.ID
+	}
+	var counts []struct {
+		CollectionID uint
+		Count        int
+	}
+	if len(ids) > 0 {
+		err := db.Model(&models.CollectionQuestion{}).
+			Select("collection_id, COUNT(*) AS count").
+			Where("collection_id IN ?", ids).
+			Group("collection_id").
+			Scan(&counts).Error
+		if err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve collections", http.StatusInternalServerError)
+			return
+		}
+	}
+	questionCounts := make(map[uint]int, len(counts))
+	for _, c := range counts {
+		questionCounts[c.CollectionID] = c.Count
+	}
+	for i := range collections {
+		collections[i].OwnerName = collections[i].Owner.Username
+		collections[i].QuestionCount = questionCounts[collections[i].ID]
+	}
+
+	totalPages := utils.TotalPages(totalItems, pagination.PageSize)
+	response := PaginatedResponse{
+		Data:       collections,
+		Page:       pagination.Page,
+		PageSize:   pagination.PageSize,
+		TotalItems: totalItems,
+		TotalPages: totalPages,
+	}
+
+	utils.SetPageLinks(w, r, pagination, totalPages)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// getCollectionByID returns a collection with its questions in order. Only
+// the questions the requester may see are listed, so a shared collection
+// never reveals unpublished questions or those of a running contest.
+func getCollectionByID(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	collection, _, ok := findCollection(w, r, db)
+	if !ok {
+		return
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	visible, _, err := visibleQuestions(db, userID, userExists)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve questions", http.StatusInternalServerError)
+		return
+	}
+	var items []models.CollectionQuestion
+	if err := db.Where("collection_id = ?", collection.ID).Order("position ASC").Find(&items).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve questions", http.StatusInternalServerError)
+		return
+	}
+	questionIDs := make([]uint, len(items))
+	for i, item := range items {
+		questionIDs[i] = item.QuestionID
+	}
+	var questions []models.Question
+	if len(questionIDs) > 0 {
+		if err := visible.Select("id, title, difficulty, tags").Where("id IN ?", questionIDs).Find(&questions).Error; err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve questions", http.StatusInternalServerError)
+			return
+		}
+	}
+	slices.SortFunc(questions, func(a, b models.Question) int {
+		return slices.Index(questionIDs, a.ID) - slices.Index(questionIDs, b.ID)
+	})
+	if userExists {
+		if err := loadUserStatuses(db, questions, userID); err != nil {
+			log.Printf("Database error loading solved status: %v", err)
+		}
+	}
+
+	response := CollectionResponse{Collection: *collection, Questions: make([]CollectionQuestionSummary, 0, len(questions))}
+	for _, question := range questions {
+		response.Questions = append(response.Questions, CollectionQuestionSummary{
+			ID:         question.ID,
+			Title:      question.Title,
+			Difficulty: question.Difficulty,
+			Tags:       question.Tags,
+			UserStatus: question.UserStatus,
+		})
+	}
+	response.QuestionCount = len(response.Questions)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// createCollection creates a collection owned by the requesting user
+func createCollection(w http.ResponseWriter, r *http.Request) {
+	collectionReq, ok := readCollectionRequest(w, r)
+	if !ok {
+		return
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	if !checkCollectionQuestions(w, r, db, userID, collectionReq.QuestionIDs) {
+		return
+	}
+
+	collection := models.Collection{
+		Title:       collectionReq.Title,
+		Description: collectionReq.Description,
+		OwnerID:     userID,
+		Public:      collectionReq.Public,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&collection).Error; err != nil {
+			return err
+		}
+		return saveCollectionQuestions(tx, collection.ID, collectionReq.QuestionIDs)
+	})
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to create collection", http.StatusInternalServerError)
+		return
+	}
+	collection.QuestionCount = len(collectionReq.QuestionIDs)
+	var owner models.User
+	if err := db.Select("username").First(&owner, userID).Error; err == nil {
+		collection.OwnerName = owner.Username
+	}
+
+	respondWithCollection(w, r, &collection, http.StatusCreated)
+}
+
+// updateCollection replaces a collection's title, description, visibility
+// and questions
+func updateCollection(w http.ResponseWriter, r *http.Request) {
+	collectionReq, ok := readCollectionRequest(w, r)
+	if !ok {
+		return
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	collection, manage, ok := findCollection(w, r, db)
+	if !ok {
+		return
+	}
+	if !manage {
+		apierror.Write(w, r, "Only the collection owner can change the collection", http.StatusForbidden)
+		return
+	}
+
+	// Questions already in the collection may stay even when the editor can no
+	// longer see them, e.g. after they were unpublished. Only added ones are checked.
+	var existing []uint
+	err := db.Model(&models.CollectionQuestion{}).Where("collection_id = ?", collection.ID).Pluck("question_id", &existing).Error
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve questions", http.StatusInternalServerError)
+		return
+	}
+	var added []uint
+	for _, questionID := range collectionReq.QuestionIDs {
+		if !slices.Contains(existing, questionID) {
+			added = append(added, questionID)
+		}
+	}
+	if !checkCollectionQuestions(w, r, db, userID, added) {
+		return
+	}
+
+	collection.Title = collectionReq.Title
+	collection.Description = collectionReq.Description
+	collection.Public = collectionReq.Public
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Owner").Save(collection).Error; err != nil {
+			return err
+		}
+		return saveCollectionQuestions(tx, collection.ID, collectionReq.QuestionIDs)
+	})
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to update collection", http.StatusInternalServerError)
+		return
+	}
+	collection.QuestionCount = len(collectionReq.QuestionIDs)
+
+	respondWithCollection(w, r, collection, http.StatusOK)
+}
+
+func deleteCollection(w http.ResponseWriter, r *http.Request) {
+	if _, userExists := auth.UserIDFromContext(r.Context()); !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	collection, manage, ok := findCollection(w, r, db)
+	if !ok {
+		return
+	}
+	if !manage {
+		apierror.Write(w, r, "Only the collection owner can delete the collection", http.StatusForbidden)
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("collection_id = ?", collection.ID).Delete(&models.CollectionQuestion{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(collection).Error
+	})
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to delete collection", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/config"
+	"goera/serve/internal/database"
+	"goera/serve/internal/internalpb"
+	"goera/serve/internal/models"
+	"goera/serve/internal/preferences"
+
+	"gorm.io/gorm"
+)
+
+// compileCheckTimeout bounds a compile check. The code-runner gives up on a
+// build after 30 seconds.
+const compileCheckTimeout = 45 * time.Second
+
+// compilerMessage matches a compiler message about a place in the code, such
+// as "main.go:4:2: declared and not used: x"
+var compilerMessage = regexp.MustCompile(`^(\S+\.go):(\d+)(?::(\d+))?: (.*)$`)
+
+// CompileDiagnostic is a compiler message about a place in the code
+type CompileDiagnostic struct {
+	File    string `json:"file"` // main.go for code sent as a single file
+	Line    int    `json:"line"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// CompileCheckResult tells whether code compiles, with what the compiler said
+type CompileCheckResult struct {
+	Compiled    bool                `json:"compiled"`
+	Diagnostics []CompileDiagnostic `json:"diagnostics"`
+	Output      string              `json:"output"` // Everything the compiler printed
+}
+
+// CompileCheckHandler handles requests to /api/compile-check
+func CompileCheckHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		compileCheck(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// compileCheck builds code on the judge without running it or storing a
+// submission, so users can see that it compiles before using up an attempt.
+// It takes the same code, files and language as a submission.
+func compileCheck(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, int64(config.MaxSourceCodeBytes)*2+4096)
+
+	var checkReq SubmissionRequest
+	if err := json.NewDecoder(r.Body).Decode(&checkReq); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+
+	_, userExists := auth.UserIDFromContext(r.Context())
+	_, isAnonymous := auth.AnonymousSessionFromContext(r.Context())
+	if !userExists && !isAnonymous {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if checkReq.Language == "" {
+		checkReq.Language = preferences.FromContext(r.Context()).Language
+	}
+	if !validRequest(w, r, &checkReq) {
+		return
+	}
+
+	if len(checkReq.Code) > config.MaxSourceCodeBytes {
+		apierror.Write(w, r, fmt.Sprintf("Source code exceeds the maximum size of %d bytes", config.MaxSourceCodeBytes), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if len(checkReq.Files) > 0 {
+		if err := validateSourceFiles(checkReq.Files); err != nil {
+			if errors.Is(err, errSourceTooLarge) {
+				apierror.Write(w, r, fmt.Sprintf("Source code exceeds the maximum size of %d bytes", config.MaxSourceCodeBytes), http.StatusRequestEntityTooLarge)
+			} else {
+				apierror.Write(w, r, err.Error(), http.StatusBadRequest)
+			}
+			return
+		}
+	}
+
+	job := &internalpb.Job{
+		SourceCode:  checkReq.Code,
+		Files:       checkReq.Files,
+		Limits:      jobLimits(0, 0, checkReq.Language),
+		CompileOnly: true,
+	}
+	// With a questionId the code is built the way submissions to it are
+	if checkReq.QuestionID != 0 {
+		db := database.GetDB()
+		if db == nil {
+			log.Println("Database connection is nil")
+			apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+			return
+		}
+		var question models.Question
+		if err := db.Select("id", "build_flags").First(&question, checkReq.QuestionID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				apierror.Write(w, r, "Question not found", http.StatusNotFound)
+			} else {
+				log.Printf("Database error: %v", err)
+				apierror.Write(w, r, "Failed to retrieve question", http.StatusInternalServerError)
+			}
+			return
+		}
+		job.BuildFlags = question.BuildFlagList()
+	}
+	judgeResult, err := runOnJudge(job, compileCheckTimeout)
+	if err != nil {
+		log.Printf("Failed to run compile check on judge: %v", err)
+		apierror.Write(w, r, "Judge service could not compile the code", http.StatusServiceUnavailable)
+		return
+	}
+
+	result := CompileCheckResult{
+		Compiled:    judgeResult.Status == models.JudgeStatus(Accepted),
+		Diagnostics: compilerDiagnostics(judgeResult.Output),
+		Output:      judgeResult.Output,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// compilerDiagnostics picks the messages about places in the code out of the
+// compiler's output. Indented lines that follow a message, like the have and
+// want lines of a type error, belong to it.
+func compilerDiagnostics(output string) []CompileDiagnostic {
+	diagnostics := []CompileDiagnostic{}
+	for _, line := range strings.Split(output, "\n") {
+		if match := compilerMessage.FindStringSubmatch(line); match != nil {
+			lineNumber, _ := strconv.Atoi(match[2])
+			column, _ := strconv.Atoi(match[3])
+			diagnostics = append(diagnostics, CompileDiagnostic{
+				File:    strings.TrimPrefix(match[1], "./"),
+				Line:    lineNumber,
+				Column:  column,
+				Message: match[4],
+			})
+			continue
+		}
+		if n := len(diagnostics); n > 0 && strings.HasPrefix(line, "\t") {
+			diagnostics[n-1].Message += "\n" + strings.TrimSpace(line)
+		}
+	}
+	return diagnostics
+}
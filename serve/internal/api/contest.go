@@ -0,0 +1,317 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/config"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+	"goera/serve/internal/utils"
+	"goera/serve/internal/validation"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// ContestRequest represents the request body for creating a contest
+type ContestRequest struct {
+	Title         string    `json:"title" validate:"required,max=200"`
+	Description   string    `json:"description" validate:"max=20000"`
+	StartTime     time.Time `json:"startTime" validate:"required"`
+	EndTime       time.Time `json:"endTime" validate:"required"`
+	FreezeMinutes *int      `json:"freezeMinutes" validate:"min=0"`                 // Defaults to CONTEST_FREEZE_MINUTES
+	QuestionIDs   []uint    `json:"questionIds" validate:"required,max=100,unique"` // Labelled A, B, C... in this order
+}
+
+// Validate checks that the contest ends after it starts
+func (req *ContestRequest) Validate(errs *validation.Errors) {
+	if !req.StartTime.IsZero() && !req.EndTime.After(req.StartTime) {
+		errs.Add("endTime", "after", "%s must be after %s", "startTime")
+	}
+}
+
+// ContestsHandler handles requests to /api/contests
+func ContestsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getContests(w, r)
+	case http.MethodPost:
+		createContest(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ContestHandler handles requests to /api/contests/{id}
+func ContestHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getContestByID(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ContestUnfreezeHandler handles requests to /api/contests/{id}/unfreeze
+func ContestUnfreezeHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		unfreezeContest(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// contestLabel returns the label of the i-th problem: A to Z, then AA, AB...
+func contestLabel(i int) string {
+	label := ""
+	for i >= 0 {
+		label = string(rune('A'+i%26)) + label
+		i = i/26 - 1
+	}
+	return label
+}
+
+// orderedContestProblems preloads contest problems in label order
+func orderedContestProblems(db *gorm.DB) *gorm.DB {
+	return db.Order("id ASC")
+}
+
+func getContests(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	pagination := utils.ParsePagination(r, "contests")
+
+	var totalItems int64
+	if err := db.Model(&models.Contest{}).Count(&totalItems).Error; err != nil {
+		log.Printf("Database error counting contests: %v", err)
+		apierror.Write(w, r, "Failed to count contests", http.StatusInternalServerError)
+		return
+	}
+
+	totalPages := utils.TotalPages(totalItems, pagination.PageSize)
+
+	var contests []models.Contest
+	result := db.Order("start_time DESC, id DESC").Limit(pagination.PageSize).Offset(pagination.Offset()).Find(&contests)
+	if result.Error != nil {
+		log.Printf("Database error: %v", result.Error)
+		apierror.Write(w, r, "Failed to retrieve contests", http.StatusInternalServerError)
+		return
+	}
+
+	response := PaginatedResponse{
+		Data:       contests,
+		Page:       pagination.Page,
+		PageSize:   pagination.PageSize,
+		TotalItems: totalItems,
+		TotalPages: totalPages,
+	}
+
+	utils.SetPageLinks(w, r, pagination, totalPages)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// getContestByID returns a contest and its problems. Problems stay hidden from
+// contestants until the contest starts, and from users who did not register
+// until it ends.
+func getContestByID(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid contest ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	var contest models.Contest
+	if err := db.Preload("Problems", orderedContestProblems).First(&contest, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Contest not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve contest", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := fillContestRegistration(db, &contest, userID); err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve registration", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	hidden := now.Before(contest.StartTime) || (contest.Running(now) && !contest.Registered)
+	if hidden && contest.UserID != userID {
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+			return
+		}
+		if user.Role != models.AdminRole {
+			contest.Problems = []models.ContestProblem{}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(contest); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// createContest lets an admin schedule a contest over existing questions
+func createContest(w http.ResponseWriter, r *http.Request) {
+	var contestReq ContestRequest
+	if err := json.NewDecoder(r.Body).Decode(&contestReq); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+	if !validRequest(w, r, &contestReq) {
+		return
+	}
+	contestReq.Title = strings.TrimSpace(contestReq.Title)
+
+	freezeMinutes := config.ContestFreezeMinutes
+	if contestReq.FreezeMinutes != nil {
+		freezeMinutes = *contestReq.FreezeMinutes
+	}
+	// A freeze longer than the contest freezes the scoreboard from the start
+	if duration := int(contestReq.EndTime.Sub(contestReq.StartTime) / time.Minute); freezeMinutes > duration {
+		freezeMinutes = duration
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	if !requireAdmin(w, r, "Only administrators can create contests") {
+		return
+	}
+	userID, _ := auth.UserIDFromContext(r.Context())
+
+	var found int64
+	if err := db.Model(&models.Question{}).Where("id IN ?", contestReq.QuestionIDs).Count(&found).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve questions", http.StatusInternalServerError)
+		return
+	}
+	if int(found) != len(contestReq.QuestionIDs) {
+		apierror.Write(w, r, "Question not found", http.StatusNotFound)
+		return
+	}
+
+	contest := models.Contest{
+		Title:         contestReq.Title,
+		Description:   contestReq.Description,
+		StartTime:     contestReq.StartTime,
+		EndTime:       contestReq.EndTime,
+		FreezeMinutes: freezeMinutes,
+		UserID:        userID,
+	}
+	for i, questionID := range contestReq.QuestionIDs {
+		contest.Problems = append(contest.Problems, models.ContestProblem{
+			QuestionID: questionID,
+			Label:      contestLabel(i),
+		})
+	}
+
+	if err := db.Create(&contest).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to create contest", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(contest); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// unfreezeContest reveals the results hidden by the scoreboard freeze, usually
+// after the contest has ended
+func unfreezeContest(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid contest ID", http.StatusBadRequest)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	if !requireAdmin(w, r, "Only administrators can unfreeze the scoreboard") {
+		return
+	}
+
+	var contest models.Contest
+	if err := db.First(&contest, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Contest not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve contest", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if contest.UnfrozenAt == nil {
+		now := time.Now()
+		contest.UnfrozenAt = &now
+		if err := db.Model(&contest).Update("unfrozen_at", now).Error; err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to unfreeze scoreboard", http.StatusInternalServerError)
+			return
+		}
+		userID, _ := auth.UserIDFromContext(r.Context())
+		audit(db, userID, models.AuditContestUnfrozen, "contest", contest.ID, contest.Title)
+		scoreboardChanged(contest.ID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(contest); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
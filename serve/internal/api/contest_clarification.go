@@ -0,0 +1,453 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+	"goera/serve/internal/validation"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// ContestAnnouncementRequest represents the request body for posting a contest announcement
+type ContestAnnouncementRequest struct {
+	Body string `json:"body" validate:"required,max=5000"`
+}
+
+// ContestClarificationRequest represents the request body for asking the
+// organizers of a contest. QuestionID names the problem it is about, if any.
+type ContestClarificationRequest struct {
+	QuestionID *uint  `json:"questionId"`
+	Body       string `json:"body" validate:"required,max=5000"`
+}
+
+// Events of the contest message stream
+const (
+	contestAnnouncementEvent  = "announcement"
+	contestClarificationEvent = "clarification"
+)
+
+// contestListener is one open contest message stream
+type contestListener struct {
+	contestID uint
+	userID    uint
+	organizer bool // Receives every clarification
+	send      chan contestEvent
+}
+
+// contestEvent is an announcement or clarification pushed to listeners
+type contestEvent struct {
+	contestID     uint
+	announcement  *models.ContestAnnouncement
+	clarification *models.ContestClarification
+}
+
+// contestFeed fans contest announcements and clarifications out to the open
+// streams of their contest
+var contestFeed = struct {
+	mu        sync.Mutex
+	listeners map[*contestListener]struct{}
+}{listeners: map[*contestListener]struct{}{}}
+
+func listenToContest(contestID, userID uint, organizer bool) *contestListener {
+	listener := &contestListener{contestID: contestID, userID: userID, organizer: organizer, send: make(chan contestEvent, 16)}
+	contestFeed.mu.Lock()
+	contestFeed.listeners[listener] = struct{}{}
+	contestFeed.mu.Unlock()
+	return listener
+}
+
+func stopListeningToContest(listener *contestListener) {
+	contestFeed.mu.Lock()
+	delete(contestFeed.listeners, listener)
+	contestFeed.mu.Unlock()
+}
+
+// publishContestEvent pushes an event to the listeners of its contest allowed
+// to see it. Slow listeners miss events rather than holding up the request.
+func publishContestEvent(event contestEvent) {
+	contestFeed.mu.Lock()
+	defer contestFeed.mu.Unlock()
+	for listener := range contestFeed.listeners {
+		if listener.contestID != event.contestID {
+			continue
+		}
+		if c := event.clarification; c != nil && !listener.organizer && !seesContestClarification(c, listener.userID) {
+			continue
+		}
+		select {
+		case listener.send <- event:
+		default:
+		}
+	}
+}
+
+// organizesContest reports whether user runs contest: admins and the
+// contest's creator
+func organizesContest(contest *models.Contest, user *models.User) bool {
+	return user.Role == models.AdminRole || contest.UserID == user.ID
+}
+
+// seesContestClarification reports whether a contestant other than the
+// organizers may read a clarification: their own, and answers made public
+func seesContestClarification(clarification *models.ContestClarification, userID uint) bool {
+	return clarification.UserID == userID || (clarification.Public && clarification.AnsweredAt != nil)
+}
+
+// loadContestChannel reads the contest named in the URL and the requesting
+// user, who must organize it or be registered for it. On failure it writes
+// the error response and returns false.
+func loadContestChannel(w http.ResponseWriter, r *http.Request) (*gorm.DB, models.Contest, models.User, bool, bool) {
+	var user models.User
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return nil, models.Contest{}, user, false, false
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return nil, models.Contest{}, user, false, false
+	}
+
+	contest, ok := loadContest(w, r, db)
+	if !ok {
+		return nil, contest, user, false, false
+	}
+
+	if err := db.First(&user, userID).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+		return nil, contest, user, false, false
+	}
+	if organizesContest(&contest, &user) {
+		return db, contest, user, true, true
+	}
+
+	registered, err := isContestParticipant(db, contest.ID, user.ID)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve registration", http.StatusInternalServerError)
+		return nil, contest, user, false, false
+	}
+	if !registered {
+		apierror.Write(w, r, "Only registered contestants and organizers can follow a contest's announcements and clarifications", http.StatusForbidden)
+		return nil, contest, user, false, false
+	}
+	return db, contest, user, false, true
+}
+
+// ContestAnnouncementsHandler handles requests to /api/contests/{id}/announcements
+func ContestAnnouncementsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getContestAnnouncements(w, r)
+	case http.MethodPost:
+		createContestAnnouncement(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ContestClarificationsHandler handles requests to /api/contests/{id}/clarifications
+func ContestClarificationsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getContestClarifications(w, r)
+	case http.MethodPost:
+		createContestClarification(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ContestClarificationAnswerHandler handles requests to
+// /api/contests/{id}/clarifications/{clarificationId}/answer
+func ContestClarificationAnswerHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPut, http.MethodPost:
+		answerContestClarification(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ContestMessageStreamHandler handles requests to /api/contests/{id}/messages/stream
+func ContestMessageStreamHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		streamContestMessages(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getContestAnnouncements lists a contest's announcements, newest first
+func getContestAnnouncements(w http.ResponseWriter, r *http.Request) {
+	db, contest, _, _, ok := loadContestChannel(w, r)
+	if !ok {
+		return
+	}
+
+	var announcements []models.ContestAnnouncement
+	if err := db.Where("contest_id = ?", contest.ID).Order("created_at DESC").Find(&announcements).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve announcements", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(announcements); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// createContestAnnouncement lets an organizer post to everyone in the contest
+func createContestAnnouncement(w http.ResponseWriter, r *http.Request) {
+	var announcementReq ContestAnnouncementRequest
+	if err := json.NewDecoder(r.Body).Decode(&announcementReq); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+	if !validRequest(w, r, &announcementReq) {
+		return
+	}
+
+	db, contest, user, organizer, ok := loadContestChannel(w, r)
+	if !ok {
+		return
+	}
+	if !organizer {
+		apierror.Write(w, r, "Only administrators or the contest's creator can post contest announcements", http.StatusForbidden)
+		return
+	}
+
+	announcement := models.ContestAnnouncement{
+		ContestID: contest.ID,
+		UserID:    user.ID,
+		Body:      announcementReq.Body,
+	}
+	if err := db.Create(&announcement).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to create announcement", http.StatusInternalServerError)
+		return
+	}
+	publishContestEvent(contestEvent{contestID: contest.ID, announcement: &announcement})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(announcement); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// getContestClarifications lists a contest's clarifications, newest first.
+// Organizers see all of them, contestants their own and the public answers.
+func getContestClarifications(w http.ResponseWriter, r *http.Request) {
+	db, contest, user, organizer, ok := loadContestChannel(w, r)
+	if !ok {
+		return
+	}
+
+	query := db.Where("contest_id = ?", contest.ID)
+	if !organizer {
+		query = query.Where("(public = ? AND answered_at IS NOT NULL) OR user_id = ?", true, user.ID)
+	}
+
+	var clarifications []models.ContestClarification
+	if err := query.Order("created_at DESC").Find(&clarifications).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve clarifications", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(clarifications); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// createContestClarification lets a registered contestant ask the organizers
+// while the contest runs
+func createContestClarification(w http.ResponseWriter, r *http.Request) {
+	var clarificationReq ContestClarificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&clarificationReq); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+	if !validRequest(w, r, &clarificationReq) {
+		return
+	}
+
+	db, contest, user, _, ok := loadContestChannel(w, r)
+	if !ok {
+		return
+	}
+	if !contest.Running(time.Now()) {
+		apierror.Write(w, r, "Clarifications can only be asked while the contest runs", http.StatusConflict)
+		return
+	}
+
+	if clarificationReq.QuestionID != nil {
+		var count int64
+		err := db.Model(&models.ContestProblem{}).
+			Where("contest_id = ? AND question_id = ?", contest.ID, *clarificationReq.QuestionID).
+			Count(&count).Error
+		if err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve contest problems", http.StatusInternalServerError)
+			return
+		}
+		if count == 0 {
+			var errs validation.Errors
+			errs.Add("questionId", "contest_problem", "%s must be a problem of the contest")
+			writeInvalidFields(w, r, errs)
+			return
+		}
+	}
+
+	clarification := models.ContestClarification{
+		ContestID:  contest.ID,
+		QuestionID: clarificationReq.QuestionID,
+		UserID:     user.ID,
+		Body:       clarificationReq.Body,
+	}
+	if err := db.Create(&clarification).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to create clarification", http.StatusInternalServerError)
+		return
+	}
+	publishContestEvent(contestEvent{contestID: contest.ID, clarification: &clarification})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(clarification); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// answerContestClarification lets an organizer answer a clarification, either
+// to the contestant who asked or to everyone in the contest
+func answerContestClarification(w http.ResponseWriter, r *http.Request) {
+	clarificationID, err := strconv.Atoi(mux.Vars(r)["clarificationId"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid clarification ID", http.StatusBadRequest)
+		return
+	}
+
+	var answerReq ClarificationAnswerRequest
+	if err := json.NewDecoder(r.Body).Decode(&answerReq); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+	if !validRequest(w, r, &answerReq) {
+		return
+	}
+
+	db, contest, user, organizer, ok := loadContestChannel(w, r)
+	if !ok {
+		return
+	}
+	if !organizer {
+		apierror.Write(w, r, "Only administrators or the contest's creator can answer contest clarifications", http.StatusForbidden)
+		return
+	}
+
+	var clarification models.ContestClarification
+	err = db.Where("contest_id = ?", contest.ID).First(&clarification, clarificationID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Clarification not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve clarification", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	now := time.Now()
+	clarification.Answer = answerReq.Answer
+	clarification.Public = answerReq.Public
+	clarification.AnsweredBy = &user.ID
+	clarification.AnsweredAt = &now
+	if err := db.Save(&clarification).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to answer clarification", http.StatusInternalServerError)
+		return
+	}
+	publishContestEvent(contestEvent{contestID: contest.ID, clarification: &clarification})
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(clarification); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// streamContestMessages pushes a contest's new announcements and
+// clarifications as Server-Sent Events, each clarification again when it is
+// answered. Contestants receive what getContestClarifications would show them.
+func streamContestMessages(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		apierror.Write(w, r, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	_, contest, user, organizer, ok := loadContestChannel(w, r)
+	if !ok {
+		return
+	}
+
+	listener := listenToContest(contest.ID, user.ID, organizer)
+	defer stopListeningToContest(listener)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		case event := <-listener.send:
+			eventType, payload := contestAnnouncementEvent, interface{}(event.announcement)
+			if event.clarification != nil {
+				eventType, payload = contestClarificationEvent, event.clarification
+			}
+			data, err := json.Marshal(payload)
+			if err != nil {
+				log.Printf("JSON encoding error: %v", err)
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventType, data)
+			flusher.Flush()
+		}
+	}
+}
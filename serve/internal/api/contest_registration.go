@@ -0,0 +1,346 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// contestPublishInterval is how often ended contests are checked for problems
+// to publish
+const contestPublishInterval = time.Minute
+
+// ContestParticipant is a user registered for a contest
+type ContestParticipant struct {
+	UserID       uint      `json:"userId"`
+	Username     string    `json:"username"`
+	RegisteredAt time.Time `json:"registeredAt"`
+}
+
+// ContestRegistrationHandler handles requests to /api/contests/{id}/register
+func ContestRegistrationHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		registerForContest(w, r)
+	case http.MethodDelete:
+		unregisterFromContest(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ContestParticipantsHandler handles requests to /api/contests/{id}/participants
+func ContestParticipantsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getContestParticipants(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// loadContest reads the contest named in the URL. On failure it writes the
+// error response and returns false.
+func loadContest(w http.ResponseWriter, r *http.Request, db *gorm.DB) (models.Contest, bool) {
+	var contest models.Contest
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid contest ID", http.StatusBadRequest)
+		return contest, false
+	}
+	if err := db.First(&contest, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Contest not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve contest", http.StatusInternalServerError)
+		}
+		return contest, false
+	}
+	return contest, true
+}
+
+// registerForContest signs the user up for a contest. Registration stays open
+// until the contest ends, so latecomers can still join.
+func registerForContest(w http.ResponseWriter, r *http.Request) {
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	contest, ok := loadContest(w, r, db)
+	if !ok {
+		return
+	}
+	if contest.Ended(time.Now()) {
+		apierror.Write(w, r, "Contest has ended", http.StatusConflict)
+		return
+	}
+
+	registered, err := isContestParticipant(db, contest.ID, userID)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve registration", http.StatusInternalServerError)
+		return
+	}
+	status := http.StatusOK
+	if !registered {
+		registration := models.ContestRegistration{ContestID: contest.ID, UserID: userID}
+		if err := db.Create(&registration).Error; err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to register for contest", http.StatusInternalServerError)
+			return
+		}
+		status = http.StatusCreated
+	}
+
+	if err := fillContestRegistration(db, &contest, userID); err != nil {
+		log.Printf("Database error: %v", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(contest); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// unregisterFromContest withdraws the user's registration. Once the contest
+// has started the registration is kept, since it decides who sees the problems
+// and who is on the scoreboard.
+func unregisterFromContest(w http.ResponseWriter, r *http.Request) {
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	contest, ok := loadContest(w, r, db)
+	if !ok {
+		return
+	}
+	if !time.Now().Before(contest.StartTime) {
+		apierror.Write(w, r, "Registration cannot be withdrawn after the contest starts", http.StatusConflict)
+		return
+	}
+
+	// Deleted for good, so the user can register again
+	result := db.Unscoped().Where("contest_id = ? AND user_id = ?", contest.ID, userID).Delete(&models.ContestRegistration{})
+	if result.Error != nil {
+		log.Printf("Database error: %v", result.Error)
+		apierror.Write(w, r, "Failed to withdraw registration", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		apierror.Write(w, r, "You are not registered for this contest", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getContestParticipants lists the users registered for a contest, in the
+// order they registered
+func getContestParticipants(w http.ResponseWriter, r *http.Request) {
+	if _, userExists := auth.UserIDFromContext(r.Context()); !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	contest, ok := loadContest(w, r, db)
+	if !ok {
+		return
+	}
+
+	var registrations []models.ContestRegistration
+	if err := db.Preload("User").Where("contest_id = ?", contest.ID).Order("created_at, id").Find(&registrations).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve participants", http.StatusInternalServerError)
+		return
+	}
+
+	participants := make([]ContestParticipant, len(registrations))
+	for i, registration := range registrations {
+		participants[i] = ContestParticipant{
+			UserID:       registration.UserID,
+			Username:     registration.User.Username,
+			RegisteredAt: registration.CreatedAt,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(participants); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// isContestParticipant reports whether userID is registered for contestID
+func isContestParticipant(db *gorm.DB, contestID, userID uint) (bool, error) {
+	var count int64
+	err := db.Model(&models.ContestRegistration{}).
+		Where("contest_id = ? AND user_id = ?", contestID, userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// fillContestRegistration sets whether userID is registered for contest and
+// how many users are
+func fillContestRegistration(db *gorm.DB, contest *models.Contest, userID uint) error {
+	registered, err := isContestParticipant(db, contest.ID, userID)
+	if err != nil {
+		return err
+	}
+	contest.Registered = registered
+	return db.Model(&models.ContestRegistration{}).Where("contest_id = ?", contest.ID).Count(&contest.Participants).Error
+}
+
+// runningContestQuestions selects the IDs of the questions that are problems
+// of a contest running at now
+func runningContestQuestions(db *gorm.DB, now time.Time) *gorm.DB {
+	return db.Model(&models.ContestProblem{}).
+		Select("contest_problems.question_id").
+		Joins("JOIN contests ON contests.id = contest_problems.contest_id AND contests.deleted_at IS NULL").
+		Where("contests.start_time <= ? AND contests.end_time > ?", now, now)
+}
+
+// contestQuestionAccess reports whether questionID is a problem of a running
+// contest and, if so, whether userID is registered for one of those contests.
+// Such questions are only shown to the contest's participants.
+func contestQuestionAccess(db *gorm.DB, questionID, userID uint) (inContest, participant bool, err error) {
+	now := time.Now()
+	var contestIDs []uint
+	err = db.Model(&models.ContestProblem{}).
+		Joins("JOIN contests ON contests.id = contest_problems.contest_id AND contests.deleted_at IS NULL").
+		Where("contest_problems.question_id = ? AND contests.start_time <= ? AND contests.end_time > ?", questionID, now, now).
+		Pluck("contest_problems.contest_id", &contestIDs).Error
+	if err != nil || len(contestIDs) == 0 || userID == 0 {
+		return len(contestIDs) > 0, false, err
+	}
+
+	var registered int64
+	err = db.Model(&models.ContestRegistration{}).
+		Where("contest_id IN ? AND user_id = ?", contestIDs, userID).
+		Count(&registered).Error
+	return true, registered > 0, err
+}
+
+// StartContestPublisher periodically publishes the problems of contests that
+// have ended, so everyone can practice them. It blocks, so run it in its own
+// goroutine.
+func StartContestPublisher() {
+	ticker := time.NewTicker(contestPublishInterval)
+	defer ticker.Stop()
+
+	for {
+		db := database.GetDB()
+		if db == nil {
+			log.Println("Contest publisher: database connection is nil")
+		} else {
+			publishEndedContests(db)
+		}
+		<-ticker.C
+	}
+}
+
+// publishEndedContests publishes the problems of every ended contest not
+// handled yet, on behalf of the contest's creator
+func publishEndedContests(db *gorm.DB) {
+	now := time.Now()
+	var contests []models.Contest
+	err := db.Preload("Problems").
+		Where("end_time <= ? AND problems_published_at IS NULL", now).
+		Order("id").
+		Find(&contests).Error
+	if err != nil {
+		log.Printf("Contest publisher: failed to find ended contests: %v", err)
+		return
+	}
+
+	for i := range contests {
+		if err := publishContestProblems(db, &contests[i], now); err != nil {
+			log.Printf("Contest publisher: failed to publish the problems of contest %d: %v", contests[i].ID, err)
+		}
+	}
+}
+
+// publishContestProblems publishes the unpublished problems of an ended
+// contest and marks the contest as handled
+func publishContestProblems(db *gorm.DB, contest *models.Contest, now time.Time) error {
+	questionIDs := make([]uint, len(contest.Problems))
+	for i, problem := range contest.Problems {
+		questionIDs[i] = problem.QuestionID
+	}
+
+	var published []models.Question
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if len(questionIDs) > 0 {
+			if err := tx.Where("id IN ? AND published = ?", questionIDs, false).Find(&published).Error; err != nil {
+				return err
+			}
+		}
+		for i := range published {
+			question := &published[i]
+			question.Status = models.QuestionStatusPublished
+			question.Published = true
+			question.PublishedAt = &now
+			question.PublishedBy = &contest.UserID
+			if err := tx.Save(question).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(contest).Update("problems_published_at", now).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	// Every problem is dropped from the cache, since the question list hid
+	// them while the contest was running
+	for _, questionID := range questionIDs {
+		invalidateQuestion(context.Background(), questionID)
+	}
+	if len(published) > 0 {
+		audit(db, contest.UserID, models.AuditContestPublished, "contest", contest.ID,
+			fmt.Sprintf("%s: published %d problems", contest.Title, len(published)))
+	}
+	return nil
+}
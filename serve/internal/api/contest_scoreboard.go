@@ -0,0 +1,315 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/config"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ScoreboardCell is a user's result on one contest problem
+type ScoreboardCell struct {
+	Label       string `json:"label"`
+	QuestionID  uint   `json:"questionId"`
+	Solved      bool   `json:"solved"`
+	Attempts    int    `json:"attempts"`    // Penalized attempts, not counting the accepted one
+	SolveMinute int    `json:"solveMinute"` // Only meaningful when solved
+	Pending     int    `json:"pending"`     // Submissions hidden by the freeze
+}
+
+// ScoreboardRow is one user's line on the scoreboard
+type ScoreboardRow struct {
+	Rank     int              `json:"rank"`
+	UserID   uint             `json:"userId"`
+	Username string           `json:"username"`
+	Solved   int              `json:"solved"`
+	Penalty  int              `json:"penalty"` // Minutes
+	Problems []ScoreboardCell `json:"problems"`
+
+	lastSolve int
+}
+
+// Scoreboard is a contest's ranking as seen by the requesting user
+type Scoreboard struct {
+	ContestID  uint            `json:"contestId"`
+	Frozen     bool            `json:"frozen"` // Results after FreezeTime are hidden
+	FreezeTime time.Time       `json:"freezeTime"`
+	Rows       []ScoreboardRow `json:"rows"`
+}
+
+// ContestScoreboardHandler handles requests to /api/contests/{id}/scoreboard
+func ContestScoreboardHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getContestScoreboard(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// penalizedVerdict reports whether a verdict adds penalty time once the problem
+// is solved. As in ICPC, compilation errors and judging failures are free.
+func penalizedVerdict(status models.JudgeStatus) bool {
+	switch status {
+	case models.Rejected, models.TimeLimitExceeded, models.MemoryLimitExceeded, models.OutputLimitExceeded, models.RuntimeError:
+		return true
+	}
+	return false
+}
+
+// updateContestResult recomputes the result of a contest submission's author on
+// its problem. Only that user's submissions to that problem are read, so the
+// scoreboard is kept up to date as verdicts arrive, in any order.
+func updateContestResult(db *gorm.DB, submission *models.Submission) error {
+	if submission.ContestID == nil {
+		return nil
+	}
+
+	var contest models.Contest
+	if err := db.First(&contest, *submission.ContestID).Error; err != nil {
+		return err
+	}
+
+	var submissions []models.Submission
+	err := db.Where("contest_id = ? AND user_id = ? AND question_id = ?", contest.ID, submission.UserID, submission.QuestionID).
+		Order("submission_time ASC, id ASC").
+		Find(&submissions).Error
+	if err != nil {
+		return err
+	}
+
+	result := models.ContestResult{
+		ContestID:  contest.ID,
+		UserID:     submission.UserID,
+		QuestionID: submission.QuestionID,
+	}
+	freezeTime := contest.FreezeTime()
+
+	for _, s := range submissions {
+		if s.JudgeStatus == models.CompilationError || s.JudgeStatus == models.SystemError {
+			continue
+		}
+		minute := int(s.SubmissionTime.Sub(contest.StartTime) / time.Minute)
+
+		if !result.Solved {
+			if s.JudgeStatus == models.Accepted {
+				result.Solved = true
+				result.SolveMinute = minute
+			} else if penalizedVerdict(s.JudgeStatus) {
+				result.Attempts++
+			}
+		}
+
+		if result.FrozenSolved {
+			continue
+		}
+		if contest.FreezeMinutes > 0 && !s.SubmissionTime.Before(freezeTime) {
+			// Contestants only learn that something was submitted, judged or not
+			result.PendingAttempts++
+		} else if s.JudgeStatus == models.Accepted {
+			result.FrozenSolved = true
+			result.FrozenSolveMinute = minute
+		} else if penalizedVerdict(s.JudgeStatus) {
+			result.FrozenAttempts++
+		}
+	}
+
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "contest_id"}, {Name: "user_id"}, {Name: "question_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"solved", "attempts", "solve_minute",
+			"frozen_solved", "frozen_attempts", "frozen_solve_minute", "pending_attempts",
+			"updated_at",
+		}),
+	}).Create(&result).Error
+}
+
+// buildScoreboard ranks the results of a contest. With frozen set, results of
+// submissions made during the freeze are hidden.
+func buildScoreboard(contest *models.Contest, results []models.ContestResult, usernames map[uint]string, frozen bool) []ScoreboardRow {
+	labels := make(map[uint]int, len(contest.Problems))
+	for i, problem := range contest.Problems {
+		labels[problem.QuestionID] = i
+	}
+
+	rowsByUser := map[uint]*ScoreboardRow{}
+	for _, result := range results {
+		index, ok := labels[result.QuestionID]
+		if !ok {
+			continue
+		}
+
+		row, ok := rowsByUser[result.UserID]
+		if !ok {
+			row = &ScoreboardRow{
+				UserID:   result.UserID,
+				Username: usernames[result.UserID],
+				Problems: make([]ScoreboardCell, len(contest.Problems)),
+			}
+			for i, problem := range contest.Problems {
+				row.Problems[i] = ScoreboardCell{Label: problem.Label, QuestionID: problem.QuestionID}
+			}
+			rowsByUser[result.UserID] = row
+		}
+
+		cell := &row.Problems[index]
+		if frozen {
+			cell.Solved = result.FrozenSolved
+			cell.Attempts = result.FrozenAttempts
+			cell.SolveMinute = result.FrozenSolveMinute
+			cell.Pending = result.PendingAttempts
+		} else {
+			cell.Solved = result.Solved
+			cell.Attempts = result.Attempts
+			cell.SolveMinute = result.SolveMinute
+		}
+
+		if cell.Solved {
+			row.Solved++
+			row.Penalty += cell.SolveMinute + cell.Attempts*config.ContestPenaltyMinutes
+			if cell.SolveMinute > row.lastSolve {
+				row.lastSolve = cell.SolveMinute
+			}
+		}
+	}
+
+	rows := make([]ScoreboardRow, 0, len(rowsByUser))
+	for _, row := range rowsByUser {
+		rows = append(rows, *row)
+	}
+
+	// Ties on solved and penalty are broken by the earlier last solve
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a.Solved != b.Solved {
+			return a.Solved > b.Solved
+		}
+		if a.Penalty != b.Penalty {
+			return a.Penalty < b.Penalty
+		}
+		if a.lastSolve != b.lastSolve {
+			return a.lastSolve < b.lastSolve
+		}
+		return a.UserID < b.UserID
+	})
+
+	for i := range rows {
+		if i > 0 && rows[i].Solved == rows[i-1].Solved && rows[i].Penalty == rows[i-1].Penalty && rows[i].lastSolve == rows[i-1].lastSolve {
+			rows[i].Rank = rows[i-1].Rank
+		} else {
+			rows[i].Rank = i + 1
+		}
+	}
+	return rows
+}
+
+// seesLiveScoreboard reports whether user sees through the scoreboard freeze
+func seesLiveScoreboard(contest *models.Contest, user *models.User) bool {
+	return organizesContest(contest, user)
+}
+
+// loadScoreboard reads the results of a contest and ranks them
+func loadScoreboard(db *gorm.DB, contest *models.Contest, frozen bool) ([]ScoreboardRow, error) {
+	var results []models.ContestResult
+	if err := db.Where("contest_id = ?", contest.ID).Find(&results).Error; err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]uint, 0, len(results))
+	for _, result := range results {
+		userIDs = append(userIDs, result.UserID)
+	}
+	var users []models.User
+	if len(userIDs) > 0 {
+		if err := db.Select("id", "username").Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+			return nil, err
+		}
+	}
+	usernames := make(map[uint]string, len(users))
+	for _, u := range users {
+		usernames[u.ID] = u.Username
+	}
+
+	return buildScoreboard(contest, results, usernames, frozen), nil
+}
+
+// getContestScoreboard returns the ranking of a contest. While the scoreboard
+// is frozen contestants see it as of the freeze; admins and the contest's
+// creator see live results unless they pass view=public.
+func getContestScoreboard(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid contest ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	var contest models.Contest
+	if err := db.Preload("Problems", orderedContestProblems).First(&contest, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Contest not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve contest", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+		return
+	}
+
+	frozen := contest.Frozen(time.Now())
+	if frozen && seesLiveScoreboard(&contest, &user) && r.URL.Query().Get("view") != "public" {
+		frozen = false
+	}
+
+	rows, err := loadScoreboard(db, &contest, frozen)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve contest results", http.StatusInternalServerError)
+		return
+	}
+	response := Scoreboard{
+		ContestID:  contest.ID,
+		Frozen:     frozen,
+		FreezeTime: contest.FreezeTime(),
+		Rows:       rows,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,393 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/config"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"gorm.io/gorm"
+)
+
+// scoreboardResync reloads a watched scoreboard this often even when no
+// verdict came in, catching verdicts reported to another serve instance
+const scoreboardResync = 30 * time.Second
+
+// scoreboardSendBuffer is how many messages a spectator may fall behind by
+// before it is disconnected
+const scoreboardSendBuffer = 16
+
+// scoreboardWriteTimeout bounds a write to a spectator
+const scoreboardWriteTimeout = 10 * time.Second
+
+// Types of scoreboard stream messages
+const (
+	scoreboardSnapshot = "snapshot"
+	scoreboardDelta    = "delta"
+)
+
+// ScoreboardMessage is sent over the scoreboard stream. A snapshot carries
+// the whole scoreboard; a delta carries the rows that were added or changed
+// and the users no longer on it since the previous message. Seq goes up by
+// one with every message, so a client that sees a gap reconnects for a new
+// snapshot.
+type ScoreboardMessage struct {
+	Type       string          `json:"type"`
+	Seq        int             `json:"seq"`
+	Scoreboard *Scoreboard     `json:"scoreboard,omitempty"` // Snapshot only
+	Rows       []ScoreboardRow `json:"rows,omitempty"`       // Delta only
+	Removed    []uint          `json:"removed,omitempty"`    // Delta only
+}
+
+var scoreboardUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+}
+
+// scoreboardSpectator is one open scoreboard stream
+type scoreboardSpectator struct {
+	live   bool // Sees through the freeze
+	synced bool // Got a snapshot, so it gets deltas
+	send   chan []byte
+}
+
+// scoreboardView is the last scoreboard sent to the spectators of one view
+type scoreboardView struct {
+	seq      int
+	snapshot Scoreboard
+	message  []byte // The snapshot message, encoded once for all spectators
+}
+
+// scoreboardTopic is a contest whose scoreboard is being watched. Verdicts
+// mark it dirty and its goroutine pushes the changes at most every
+// config.ScoreboardPushInterval, however many verdicts came in meanwhile.
+type scoreboardTopic struct {
+	contestID  uint
+	spectators map[*scoreboardSpectator]struct{}
+	views      map[bool]*scoreboardView // By live
+	dirty      bool
+	loadedAt   time.Time
+	contest    models.Contest
+}
+
+// scoreboardTopics are the watched contests by ID
+var scoreboardTopics = struct {
+	mu     sync.Mutex
+	topics map[uint]*scoreboardTopic
+}{topics: map[uint]*scoreboardTopic{}}
+
+// scoreboardChanged tells the spectators of a contest, if it has any, that
+// its scoreboard changed
+func scoreboardChanged(contestID uint) {
+	scoreboardTopics.mu.Lock()
+	defer scoreboardTopics.mu.Unlock()
+	if topic, ok := scoreboardTopics.topics[contestID]; ok {
+		topic.dirty = true
+	}
+}
+
+// watchScoreboard adds a spectator to the topic of a contest, starting the
+// topic when it is the first. The spectator gets the last snapshot of its
+// view right away, or with the next push when there is none yet.
+func watchScoreboard(contestID uint, live bool) *scoreboardSpectator {
+	spectator := &scoreboardSpectator{live: live, send: make(chan []byte, scoreboardSendBuffer)}
+
+	scoreboardTopics.mu.Lock()
+	defer scoreboardTopics.mu.Unlock()
+	topic, ok := scoreboardTopics.topics[contestID]
+	if !ok {
+		topic = &scoreboardTopic{
+			contestID:  contestID,
+			spectators: map[*scoreboardSpectator]struct{}{},
+			views:      map[bool]*scoreboardView{},
+			dirty:      true,
+		}
+		scoreboardTopics.topics[contestID] = topic
+		go topic.run()
+	}
+	topic.spectators[spectator] = struct{}{}
+
+	if view, ok := topic.views[live]; ok {
+		topic.sendSnapshot(spectator, view)
+	} else {
+		topic.dirty = true
+	}
+	return spectator
+}
+
+// unwatchScoreboard removes a spectator; the topic stops on its next tick
+// once nobody watches it
+func unwatchScoreboard(contestID uint, spectator *scoreboardSpectator) {
+	scoreboardTopics.mu.Lock()
+	defer scoreboardTopics.mu.Unlock()
+	if topic, ok := scoreboardTopics.topics[contestID]; ok {
+		topic.drop(spectator)
+	}
+}
+
+// run pushes the scoreboard while anyone watches it
+func (t *scoreboardTopic) run() {
+	ticker := time.NewTicker(config.ScoreboardPushInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		scoreboardTopics.mu.Lock()
+		if len(t.spectators) == 0 {
+			delete(scoreboardTopics.topics, t.contestID)
+			scoreboardTopics.mu.Unlock()
+			return
+		}
+		now := time.Now()
+		due := t.dirty || now.Sub(t.loadedAt) >= scoreboardResync ||
+			t.contest.Frozen(now) != t.contest.Frozen(t.loadedAt)
+		t.dirty = false
+		scoreboardTopics.mu.Unlock()
+
+		if due {
+			if err := t.push(now); err != nil {
+				log.Printf("Failed to push scoreboard of contest %d: %v", t.contestID, err)
+			}
+		}
+	}
+}
+
+// push reloads the scoreboard and sends each spectator what changed in its
+// view, or a snapshot when it has none yet or the freeze began or ended
+func (t *scoreboardTopic) push(now time.Time) error {
+	db := database.GetDB()
+	if db == nil {
+		return errors.New("database connection is nil")
+	}
+
+	var contest models.Contest
+	if err := db.Preload("Problems", orderedContestProblems).First(&contest, t.contestID).Error; err != nil {
+		return err
+	}
+	frozen := contest.Frozen(now)
+	public, err := loadScoreboard(db, &contest, frozen)
+	if err != nil {
+		return err
+	}
+	live := public
+	if frozen {
+		if live, err = loadScoreboard(db, &contest, false); err != nil {
+			return err
+		}
+	}
+
+	scoreboardTopics.mu.Lock()
+	defer scoreboardTopics.mu.Unlock()
+	t.contest = contest
+	t.loadedAt = now
+	for _, seesLive := range []bool{false, true} {
+		scoreboard := Scoreboard{ContestID: contest.ID, Frozen: frozen && !seesLive, FreezeTime: contest.FreezeTime(), Rows: public}
+		if seesLive {
+			scoreboard.Rows = live
+		}
+		t.update(seesLive, scoreboard)
+	}
+	return nil
+}
+
+// update sends the spectators of a view the changes from its last scoreboard
+func (t *scoreboardTopic) update(live bool, scoreboard Scoreboard) {
+	view, ok := t.views[live]
+	resync := !ok || view.snapshot.Frozen != scoreboard.Frozen
+	if !ok {
+		view = &scoreboardView{}
+		t.views[live] = view
+	}
+
+	var delta []byte
+	if !resync {
+		rows, removed := scoreboardDiff(view.snapshot.Rows, scoreboard.Rows)
+		if len(rows) == 0 && len(removed) == 0 {
+			return
+		}
+		var err error
+		delta, err = json.Marshal(ScoreboardMessage{Type: scoreboardDelta, Seq: view.seq + 1, Rows: rows, Removed: removed})
+		if err != nil {
+			log.Printf("JSON encoding error: %v", err)
+			return
+		}
+	}
+	message, err := json.Marshal(ScoreboardMessage{Type: scoreboardSnapshot, Seq: view.seq + 1, Scoreboard: &scoreboard})
+	if err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		return
+	}
+	view.seq++
+	view.snapshot = scoreboard
+	view.message = message
+
+	for spectator := range t.spectators {
+		if spectator.live != live {
+			continue
+		}
+		if resync || !spectator.synced {
+			t.sendSnapshot(spectator, view)
+		} else {
+			t.send(spectator, delta)
+		}
+	}
+}
+
+// sendSnapshot sends the last scoreboard of a view to a spectator
+func (t *scoreboardTopic) sendSnapshot(spectator *scoreboardSpectator, view *scoreboardView) {
+	spectator.synced = true
+	t.send(spectator, view.message)
+}
+
+// send queues a message for a spectator, dropping it when it fell too far
+// behind; it then has to reconnect for a new snapshot
+func (t *scoreboardTopic) send(spectator *scoreboardSpectator, message []byte) {
+	select {
+	case spectator.send <- message:
+	default:
+		t.drop(spectator)
+	}
+}
+
+// drop removes a spectator and closes its queue, ending its stream
+func (t *scoreboardTopic) drop(spectator *scoreboardSpectator) {
+	if _, ok := t.spectators[spectator]; ok {
+		delete(t.spectators, spectator)
+		close(spectator.send)
+	}
+}
+
+// scoreboardDiff returns the rows of next that are new or differ from prev,
+// and the users of prev missing from next
+func scoreboardDiff(prev, next []ScoreboardRow) ([]ScoreboardRow, []uint) {
+	before := make(map[uint]ScoreboardRow, len(prev))
+	for _, row := range prev {
+		before[row.UserID] = row
+	}
+
+	var changed []ScoreboardRow
+	for _, row := range next {
+		old, ok := before[row.UserID]
+		delete(before, row.UserID)
+		if !ok || !reflect.DeepEqual(old, row) {
+			changed = append(changed, row)
+		}
+	}
+	var removed []uint
+	for userID := range before {
+		removed = append(removed, userID)
+	}
+	return changed, removed
+}
+
+// ContestScoreboardStreamHandler handles requests to /api/contests/{id}/scoreboard/ws
+func ContestScoreboardStreamHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		streamContestScoreboard(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// streamContestScoreboard upgrades to a WebSocket that pushes the contest's
+// scoreboard, so spectators do not have to poll it. It starts with a snapshot
+// and then sends deltas as verdicts land. Who sees through the freeze follows
+// getContestScoreboard, including view=public.
+func streamContestScoreboard(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid contest ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	var contest models.Contest
+	if err := db.First(&contest, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Contest not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve contest", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+		return
+	}
+	live := seesLiveScoreboard(&contest, &user) && r.URL.Query().Get("view") != "public"
+
+	// The upgrader writes its own error response
+	conn, err := scoreboardUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Scoreboard stream upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	spectator := watchScoreboard(contest.ID, live)
+	defer unwatchScoreboard(contest.ID, spectator)
+
+	// Spectators only listen; reading notices when they go away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.SetReadLimit(512)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case message, ok := <-spectator.send:
+			conn.SetWriteDeadline(time.Now().Add(scoreboardWriteTimeout))
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "fell behind, reconnect for a new snapshot"))
+				return
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(scoreboardWriteTimeout)); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/database"
+	"goera/serve/internal/internalpb"
+	"goera/serve/internal/models"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DeadLettersHandler handles requests to /api/admin/dead-letters
+func DeadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getDeadLetters(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ReplayDeadLetterHandler handles requests to /api/admin/dead-letters/{id}/replay
+func ReplayDeadLetterHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		replayDeadLetter(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// requireAdmin writes an error response and returns false unless the requester is an admin
+func requireAdmin(w http.ResponseWriter, r *http.Request, forbiddenMessage string) bool {
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return false
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+		return false
+	}
+
+	if user.Role != models.AdminRole {
+		apierror.Write(w, r, forbiddenMessage, http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// DeadLetter is a judge result that the judge could not deliver
+type DeadLetter struct {
+	ID             string           `json:"id"`
+	SubmissionID   uint             `json:"submissionId"`
+	IdempotencyKey string           `json:"idempotencyKey"`
+	Result         DeadLetterResult `json:"result"`
+	Attempts       int              `json:"attempts"`
+	LastError      string           `json:"lastError"`
+	FailedAt       time.Time        `json:"failedAt"`
+}
+
+// DeadLetterResult is the undelivered result of a dead letter
+type DeadLetterResult struct {
+	SubmissionID uint                     `json:"submissionId"`
+	Status       models.JudgeStatus       `json:"status"`
+	Output       string                   `json:"output"`
+	Events       []SubmissionEventRequest `json:"events"`
+	Stderr       []DeadLetterStderr       `json:"stderr,omitempty"`
+}
+
+// DeadLetterStderr is the stderr of a failing test case in a dead letter
+type DeadLetterStderr struct {
+	TestCase  int    `json:"testCase"`
+	Output    string `json:"output"`
+	Truncated bool   `json:"truncated"`
+}
+
+// writeJudgeError reports a failed call to the judge's admin API in the API
+// error format
+func writeJudgeError(w http.ResponseWriter, r *http.Request, err error) {
+	st := status.Convert(err)
+	switch st.Code() {
+	case codes.NotFound:
+		apierror.Write(w, r, st.Message(), http.StatusNotFound)
+	case codes.InvalidArgument:
+		apierror.Write(w, r, st.Message(), http.StatusBadRequest)
+	case codes.Unavailable, codes.DeadlineExceeded:
+		log.Printf("Failed to reach judge: %v", err)
+		apierror.Write(w, r, "Judge service unavailable", http.StatusServiceUnavailable)
+	default:
+		log.Printf("Judge call failed: %v", err)
+		apierror.Write(w, r, st.Message(), http.StatusBadGateway)
+	}
+}
+
+// getDeadLetters lists judge results that could not be delivered
+func getDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, "Only administrators can view dead letters") {
+		return
+	}
+
+	client, err := judgeClient()
+	if err != nil {
+		log.Printf("Failed to create judge client: %v", err)
+		apierror.Write(w, r, "Failed to contact judge", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	resp, err := client.ListDeadLetters(ctx, &internalpb.ListDeadLettersRequest{})
+	if err != nil {
+		writeJudgeError(w, r, err)
+		return
+	}
+
+	letters := make([]DeadLetter, 0, len(resp.GetDeadLetters()))
+	for _, letter := range resp.GetDeadLetters() {
+		result := letter.GetResult()
+		deadLetter := DeadLetter{
+			ID:             letter.GetId(),
+			SubmissionID:   uint(letter.GetSubmissionId()),
+			IdempotencyKey: letter.GetIdempotencyKey(),
+			Result: DeadLetterResult{
+				SubmissionID: uint(letter.GetSubmissionId()),
+				Status:       models.JudgeStatus(verdictResults[result.GetVerdict()]),
+				Output:       result.GetOutput(),
+				Events:       eventsFromProto(result.GetEvents()),
+			},
+			Attempts:  int(letter.GetAttempts()),
+			LastError: letter.GetLastError(),
+			FailedAt:  letter.GetFailedAt().AsTime(),
+		}
+		for _, stderr := range result.GetStderr() {
+			deadLetter.Result.Stderr = append(deadLetter.Result.Stderr, DeadLetterStderr{
+				TestCase:  int(stderr.GetTestCase()),
+				Output:    stderr.GetOutput(),
+				Truncated: stderr.GetTruncated(),
+			})
+		}
+		letters = append(letters, deadLetter)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(letters); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+	}
+}
+
+// replayDeadLetter asks the judge to deliver a dead-lettered result again
+func replayDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, "Only administrators can replay dead letters") {
+		return
+	}
+
+	client, err := judgeClient()
+	if err != nil {
+		log.Printf("Failed to create judge client: %v", err)
+		apierror.Write(w, r, "Failed to contact judge", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	if _, err := client.ReplayDeadLetter(ctx, &internalpb.ReplayDeadLetterRequest{Id: mux.Vars(r)["id"]}); err != nil {
+		writeJudgeError(w, r, err)
+		return
+	}
+
+	userID, _ := auth.UserIDFromContext(r.Context())
+	audit(database.GetDB(), userID, models.AuditDeadLetterReplayed, "dead_letter", mux.Vars(r)["id"], "")
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Replayed"))
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+	"goera/serve/internal/validation"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DifficultyVoteRequest represents the request body for rating a question
+type DifficultyVoteRequest struct {
+	Rating int `json:"rating"`
+}
+
+// Validate checks that the rating is on the scale of difficulty votes
+func (req *DifficultyVoteRequest) Validate(errs *validation.Errors) {
+	errs.Range("rating", req.Rating, models.MinDifficultyVote, models.MaxDifficultyVote)
+}
+
+// DifficultyVoteResponse is the question's rating after a vote
+type DifficultyVoteResponse struct {
+	QuestionID       uint    `json:"questionId"`
+	DifficultyRating float64 `json:"difficultyRating"`
+	DifficultyVotes  int64   `json:"difficultyVotes"`
+	YourVote         int     `json:"yourVote"`
+}
+
+// DifficultyVoteHandler handles requests to /api/questions/{id}/difficulty-vote
+func DifficultyVoteHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		voteDifficulty(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// updateDifficultyRating recomputes a question's rating from its votes
+func updateDifficultyRating(tx *gorm.DB, question *models.Question) error {
+	var aggregate struct {
+		Rating float64
+		Votes  int64
+	}
+	if err := tx.Model(&models.DifficultyVote{}).
+		Select("COALESCE(AVG(rating), 0) AS rating, COUNT(*) AS votes").
+		Where("question_id = ?", question.ID).
+		Scan(&aggregate).Error; err != nil {
+		return err
+	}
+
+	question.DifficultyRating = aggregate.Rating
+	question.DifficultyVotes = aggregate.Votes
+	return tx.Model(question).UpdateColumns(map[string]interface{}{
+		"difficulty_rating": aggregate.Rating,
+		"difficulty_votes":  aggregate.Votes,
+	}).Error
+}
+
+// voteDifficulty records the requester's difficulty rating of a question.
+// Only users who submitted to a published question can rate it, so ratings
+// come from people who actually tried it.
+func voteDifficulty(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid question ID", http.StatusBadRequest)
+		return
+	}
+
+	var voteReq DifficultyVoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&voteReq); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+	if !validRequest(w, r, &voteReq) {
+		return
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	var question models.Question
+	if err := db.First(&question, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Question not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve question", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if !question.Published {
+		apierror.Write(w, r, "Only published questions can be rated", http.StatusConflict)
+		return
+	}
+
+	var submissions int64
+	if err := db.Model(&models.Submission{}).
+		Where("question_id = ? AND user_id = ?", question.ID, userID).
+		Count(&submissions).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to check submissions", http.StatusInternalServerError)
+		return
+	}
+	if submissions == 0 {
+		apierror.Write(w, r, "Submit a solution before rating this question", http.StatusForbidden)
+		return
+	}
+
+	vote := models.DifficultyVote{
+		QuestionID: question.ID,
+		UserID:     userID,
+		Rating:     voteReq.Rating,
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "question_id"}, {Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"rating", "updated_at"}),
+		}).Create(&vote).Error; err != nil {
+			return err
+		}
+		return updateDifficultyRating(tx, &question)
+	})
+	if err != nil {
+		log.Printf("Database error saving difficulty vote: %v", err)
+		apierror.Write(w, r, "Failed to save vote", http.StatusInternalServerError)
+		return
+	}
+	invalidateQuestion(r.Context(), question.ID)
+
+	response := DifficultyVoteResponse{
+		QuestionID:       question.ID,
+		DifficultyRating: question.DifficultyRating,
+		DifficultyVotes:  question.DifficultyVotes,
+		YourVote:         vote.Rating,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
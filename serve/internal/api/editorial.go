@@ -0,0 +1,300 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/config"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+	"goera/serve/internal/utils"
+	"goera/serve/internal/validation"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// EditorialRequest represents the request body for updating a question's editorial
+type EditorialRequest struct {
+	Editorial  string                     `json:"editorial" validate:"max=100000"`
+	Hints      string                     `json:"hints" validate:"max=20000"`
+	Visibility models.EditorialVisibility `json:"visibility" validate:"omitempty,oneof=after_solve after_release after_contest always"`
+	ReleaseAt  string                     `json:"releaseAt"` // RFC 3339 or YYYY-MM-DD, empty to clear
+}
+
+// EditorialResponse is a question's editorial as seen by the requesting user.
+// Editorial and Hints are empty while the editorial is still locked.
+type EditorialResponse struct {
+	QuestionID uint                       `json:"questionId"`
+	Editorial  string                     `json:"editorial"`
+	Hints      string                     `json:"hints"`
+	Visibility models.EditorialVisibility `json:"visibility"`
+	ReleaseAt  *time.Time                 `json:"releaseAt"`
+	Available  bool                       `json:"available"`
+}
+
+// EditorialHandler handles requests to /api/questions/{id}/editorial
+func EditorialHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getEditorial(w, r)
+	case http.MethodPut, http.MethodPost:
+		updateEditorial(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// effectiveEditorialVisibility returns the question's visibility, falling back
+// to the deployment default
+func effectiveEditorialVisibility(question *models.Question) models.EditorialVisibility {
+	if question.EditorialVisibility != "" {
+		return question.EditorialVisibility
+	}
+	if v := models.EditorialVisibility(config.EditorialVisibility); v.IsValid() {
+		return v
+	}
+	return models.EditorialAfterSolve
+}
+
+// editorialAvailable reports whether a user may read the editorial of a question.
+// A zero userID stands for an anonymous visitor.
+func editorialAvailable(db *gorm.DB, question *models.Question, userID uint) (bool, error) {
+	switch effectiveEditorialVisibility(question) {
+	case models.EditorialAlways:
+		return true, nil
+	case models.EditorialAfterRelease:
+		return question.EditorialReleaseAt != nil && !time.Now().Before(*question.EditorialReleaseAt), nil
+	case models.EditorialAfterContest:
+		inContest, _, err := contestQuestionAccess(db, question.ID, 0)
+		return !inContest, err
+	default:
+		if userID == 0 {
+			return false, nil
+		}
+		var solved int64
+		err := db.Model(&models.Submission{}).
+			Where("question_id = ? AND user_id = ? AND judge_status = ?", question.ID, userID, models.Accepted).
+			Count(&solved).Error
+		return solved > 0, err
+	}
+}
+
+func getEditorial(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid question ID", http.StatusBadRequest)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	_, isAnonymous := auth.AnonymousSessionFromContext(r.Context())
+	if !userExists && !isAnonymous {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var question models.Question
+	if err := db.First(&question, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Question not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve question", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Admins and the authors can always read the editorial; others only of
+	// questions they can see, so not of a running contest they are not in
+	canManage := false
+	if userExists {
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+			return
+		}
+		canManage = canEditQuestion(db, &question, &user)
+	}
+
+	if !canManage {
+		visible, err := visibleToReader(db, &question, userID)
+		if err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve contest", http.StatusInternalServerError)
+			return
+		}
+		if !visible {
+			apierror.Write(w, r, "Question not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	available := canManage
+	if !available {
+		available, err = editorialAvailable(db, &question, userID)
+		if err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve editorial", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	response := EditorialResponse{
+		QuestionID: question.ID,
+		Visibility: effectiveEditorialVisibility(&question),
+		ReleaseAt:  question.EditorialReleaseAt,
+		Available:  available,
+	}
+	if available {
+		response.Editorial = question.Editorial
+		response.Hints = question.Hints
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// updateEditorial lets an admin or the question's author change the editorial
+// and hints without touching the statement or test cases
+func updateEditorial(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid question ID", http.StatusBadRequest)
+		return
+	}
+
+	var editorialReq EditorialRequest
+
+	formProcessor := func(r *http.Request) (interface{}, error) {
+		return EditorialRequest{
+			Editorial:  r.FormValue("editorial"),
+			Hints:      r.FormValue("hints"),
+			Visibility: models.EditorialVisibility(r.FormValue("visibility")),
+			ReleaseAt:  r.FormValue("releaseAt"),
+		}, nil
+	}
+
+	result, err := utils.ProcessRequestData(r, &editorialReq, formProcessor)
+	if err != nil {
+		writeRequestDataError(w, r, err)
+		return
+	}
+
+	if formData, ok := result.(EditorialRequest); ok {
+		editorialReq = formData
+	}
+
+	if !validRequest(w, r, &editorialReq) {
+		return
+	}
+
+	var errs validation.Errors
+	var releaseAt *time.Time
+	if editorialReq.ReleaseAt != "" {
+		t, err := parseTimeFilter(editorialReq.ReleaseAt, false)
+		if err != nil {
+			errs.Add("releaseAt", "time", "%s must be a date or an RFC 3339 time")
+		} else {
+			releaseAt = &t
+		}
+	} else if editorialReq.Visibility == models.EditorialAfterRelease {
+		errs.Add("releaseAt", "required", "%s is required for after_release visibility")
+	}
+	if errs != nil {
+		writeInvalidFields(w, r, errs)
+		return
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	var question models.Question
+	if err := db.First(&question, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Question not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve question", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+		return
+	}
+
+	if !canEditQuestion(db, &question, &user) {
+		apierror.Write(w, r, "Unauthorized to edit this editorial", http.StatusForbidden)
+		return
+	}
+
+	question.Editorial = editorialReq.Editorial
+	question.Hints = editorialReq.Hints
+	question.EditorialVisibility = editorialReq.Visibility
+	question.EditorialReleaseAt = releaseAt
+
+	// Only the editorial columns are written so concurrent statement edits are kept
+	err = db.Model(&question).Select("Editorial", "Hints", "EditorialVisibility", "EditorialReleaseAt").Updates(&question).Error
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to update editorial", http.StatusInternalServerError)
+		return
+	}
+	invalidateQuestion(r.Context(), question.ID)
+
+	if utils.IsFormRequest(r) {
+		http.Redirect(w, r, fmt.Sprintf("/question/%d?success=editorial_updated#editorial", question.ID), http.StatusSeeOther)
+		return
+	}
+
+	response := EditorialResponse{
+		QuestionID: question.ID,
+		Editorial:  question.Editorial,
+		Hints:      question.Hints,
+		Visibility: effectiveEditorialVisibility(&question),
+		ReleaseAt:  question.EditorialReleaseAt,
+		Available:  true,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"goera/serve/internal/apierror"
+)
+
+// writeJSONWithETag writes v as a JSON response tagged with a hash of its body,
+// answering 304 Not Modified without a body when the request's If-None-Match
+// already names it. The body is hashed as sent, so anything that differs per
+// user, like solved statuses, changes the tag too. lastModified is only
+// informational and left out when zero; If-Modified-Since is not honored as a
+// response can change without any question changing.
+func writeJSONWithETag(w http.ResponseWriter, r *http.Request, v interface{}, lastModified time.Time) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	body = append(body, '\n')
+
+	writeTaggedBody(w, r, body, hashETag(body), lastModified)
+}
+
+// writeJSONTagged writes v as a JSON response tagged with etag, a validator
+// the caller worked out from what v is built of and already checked with
+// notModified
+func writeJSONTagged(w http.ResponseWriter, r *http.Request, v interface{}, etag string, lastModified time.Time) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	body = append(body, '\n')
+
+	writeTaggedBody(w, r, body, etag, lastModified)
+}
+
+func writeTaggedBody(w http.ResponseWriter, r *http.Request, body []byte, etag string, lastModified time.Time) {
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	if notModified(w, r, etag) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// notModified tags the response with etag and, when the request's
+// If-None-Match already names it, answers 304 Not Modified and reports true
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	// Responses depend on who asks, so only the client may keep them, and it
+	// has to check back before using them
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("ETag", etag)
+	if !etagMatches(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// hashETag returns a strong tag for data
+func hashETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header names etag. Tags are
+// compared weakly, as the header allows.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+)
+
+// Items of each kind the feed shows
+const feedSize = 10
+
+// Feed is the homepage dashboard: what was published lately, what the admins
+// announced and how the user's latest submissions did
+type Feed struct {
+	Questions     []FeedQuestion        `json:"questions"`
+	Announcements []models.Announcement `json:"announcements"`
+	Verdicts      []FeedVerdict         `json:"verdicts"` // Empty unless signed in
+}
+
+// FeedQuestion is a recently published question
+type FeedQuestion struct {
+	ID          uint       `json:"id"`
+	Title       string     `json:"title"`
+	Difficulty  string     `json:"difficulty"`
+	Tags        string     `json:"tags"`
+	PublishedAt *time.Time `json:"publishedAt"`
+}
+
+// FeedVerdict is one of the user's recent submissions, without its code
+type FeedVerdict struct {
+	ID             uint               `json:"id"`
+	QuestionID     uint               `json:"questionId"`
+	QuestionName   string             `json:"questionName"`
+	Language       string             `json:"language"`
+	JudgeStatus    models.JudgeStatus `json:"judgeStatus"`
+	SubmissionTime time.Time          `json:"submissionTime"`
+}
+
+// FeedHandler handles requests to /api/feed
+func FeedHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getFeed(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getFeed returns the homepage feed. It is public; the verdicts are only
+// filled in for a signed in user.
+func getFeed(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	feed := Feed{
+		Questions: []FeedQuestion{},
+		Verdicts:  []FeedVerdict{},
+	}
+
+	// Problems of running contests stay hidden like in the question list
+	err := db.Model(&models.Question{}).
+		Where("published = ? AND id NOT IN (?)", true, runningContestQuestions(db, now)).
+		Order("published_at DESC").Limit(feedSize).
+		Find(&feed.Questions).Error
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve questions", http.StatusInternalServerError)
+		return
+	}
+
+	feed.Announcements, err = activeAnnouncements(db, now, feedSize)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve announcements", http.StatusInternalServerError)
+		return
+	}
+
+	if userID, signedIn := auth.UserIDFromContext(r.Context()); signedIn {
+		err := db.Model(&models.Submission{}).
+			Where("user_id = ?", userID).
+			Order("submission_time DESC").Limit(feedSize).
+			Find(&feed.Verdicts).Error
+		if err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve submissions", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(feed); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,479 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// inviteCodeAlphabet leaves out characters that are easily confused when a
+// code is read out in class
+const inviteCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+const inviteCodeLength = 8
+
+// GroupRequest represents the request body for creating a group
+type GroupRequest struct {
+	Name        string `json:"name" validate:"required,max=100"`
+	Description string `json:"description" validate:"max=5000"`
+}
+
+// GroupJoinRequest represents the request body for joining a group
+type GroupJoinRequest struct {
+	InviteCode string `json:"inviteCode" validate:"required,max=32"`
+}
+
+// GroupsHandler handles requests to /api/groups
+func GroupsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getGroups(w, r)
+	case http.MethodPost:
+		createGroup(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GroupHandler handles requests to /api/groups/{id}
+func GroupHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getGroupByID(w, r)
+	case http.MethodDelete:
+		deleteGroup(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GroupJoinHandler handles requests to /api/groups/join
+func GroupJoinHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		joinGroup(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GroupInviteCodeHandler handles requests to /api/groups/{id}/invite-code
+func GroupInviteCodeHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		regenerateInviteCode(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GroupMemberHandler handles requests to /api/groups/{id}/members/{userId}
+func GroupMemberHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodDelete:
+		removeGroupMember(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// generateInviteCode returns a random code users join a group with
+func generateInviteCode() (string, error) {
+	b := make([]byte, inviteCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = inviteCodeAlphabet[int(b[i])%len(inviteCodeAlphabet)]
+	}
+	return string(b), nil
+}
+
+// findGroup loads a group the requesting user may see: as its owner, one of
+// its members or an admin. manage reports whether the user may change it,
+// which only the owner and admins can.
+func findGroup(w http.ResponseWriter, r *http.Request, db *gorm.DB, userID uint) (group *models.Group, manage bool, ok bool) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid group ID", http.StatusBadRequest)
+		return nil, false, false
+	}
+
+	group = &models.Group{}
+	if err := db.First(group, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Group not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve group", http.StatusInternalServerError)
+		}
+		return nil, false, false
+	}
+	if group.OwnerID == userID {
+		return group, true, true
+	}
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+		return nil, false, false
+	}
+	if user.Role == models.AdminRole {
+		return group, true, true
+	}
+
+	var members int64
+	if err := db.Model(&models.GroupMember{}).Where("group_id = ? AND user_id = ?", group.ID, userID).Count(&members).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve group members", http.StatusInternalServerError)
+		return nil, false, false
+	}
+	if members == 0 {
+		// Groups are private, so outsiders are not told that one exists
+		apierror.Write(w, r, "Group not found", http.StatusNotFound)
+		return nil, false, false
+	}
+	return group, false, true
+}
+
+// getGroups lists the groups the requesting user owns or is a member of
+func getGroups(w http.ResponseWriter, r *http.Request) {
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	var groups []models.Group
+	err := db.Where("owner_id = ? OR id IN (?)", userID,
+		db.Model(&models.GroupMember{}).Select("group_id").Where("user_id = ?", userID)).
+		Order("created_at DESC").
+		Find(&groups).Error
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve groups", http.StatusInternalServerError)
+		return
+	}
+	for i := range groups {
+		if groups[i].OwnerID != userID {
+			groups[i].InviteCode = ""
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(groups); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// getGroupByID returns a group with its members and assignments
+func getGroupByID(w http.ResponseWriter, r *http.Request) {
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	group, manage, ok := findGroup(w, r, db, userID)
+	if !ok {
+		return
+	}
+
+	err := db.Preload("Members.User").
+		Preload("Assignments", func(db *gorm.DB) *gorm.DB { return db.Order("deadline ASC, id ASC") }).
+		Preload("Assignments.Questions", orderedAssignmentQuestions).
+		First(group, group.ID).Error
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve group", http.StatusInternalServerError)
+		return
+	}
+	for i := range group.Members {
+		group.Members[i].Username = group.Members[i].User.Username
+	}
+	if !manage {
+		group.InviteCode = ""
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(group); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// createGroup creates a group owned by the requesting user
+func createGroup(w http.ResponseWriter, r *http.Request) {
+	var groupReq GroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&groupReq); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+	if !validRequest(w, r, &groupReq) {
+		return
+	}
+	groupReq.Name = strings.TrimSpace(groupReq.Name)
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	inviteCode, err := generateInviteCode()
+	if err != nil {
+		log.Printf("Error generating invite code: %v", err)
+		apierror.Write(w, r, "Failed to generate invite code", http.StatusInternalServerError)
+		return
+	}
+
+	group := models.Group{
+		Name:        groupReq.Name,
+		Description: groupReq.Description,
+		OwnerID:     userID,
+		InviteCode:  inviteCode,
+	}
+	if err := db.Create(&group).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to create group", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(group); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+func deleteGroup(w http.ResponseWriter, r *http.Request) {
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	group, manage, ok := findGroup(w, r, db, userID)
+	if !ok {
+		return
+	}
+	if !manage {
+		apierror.Write(w, r, "Only the group owner can delete the group", http.StatusForbidden)
+		return
+	}
+
+	if err := db.Delete(group).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to delete group", http.StatusInternalServerError)
+		return
+	}
+	if err := db.Where("group_id = ?", group.ID).Delete(&models.Webhook{}).Error; err != nil {
+		log.Printf("Failed to delete the webhooks of group %d: %v", group.ID, err)
+	}
+	audit(db, userID, models.AuditGroupDeleted, "group", group.ID, group.Name)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// joinGroup adds the requesting user to the group with the given invite code.
+// Joining a group twice is not an error.
+func joinGroup(w http.ResponseWriter, r *http.Request) {
+	var joinReq GroupJoinRequest
+	if err := json.NewDecoder(r.Body).Decode(&joinReq); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+	if !validRequest(w, r, &joinReq) {
+		return
+	}
+	inviteCode := strings.ToUpper(strings.TrimSpace(joinReq.InviteCode))
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	var group models.Group
+	if err := db.Where("invite_code = ?", inviteCode).First(&group).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Invalid invite code", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve group", http.StatusInternalServerError)
+		}
+		return
+	}
+	if group.OwnerID == userID {
+		apierror.Write(w, r, "You already own this group", http.StatusBadRequest)
+		return
+	}
+
+	member := models.GroupMember{GroupID: group.ID, UserID: userID}
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "group_id"}, {Name: "user_id"}},
+		DoNothing: true,
+	}).Create(&member).Error
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to join group", http.StatusInternalServerError)
+		return
+	}
+
+	group.InviteCode = ""
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(group); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// regenerateInviteCode replaces a group's invite code, e.g. after it leaked.
+// Members who already joined stay in the group.
+func regenerateInviteCode(w http.ResponseWriter, r *http.Request) {
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	group, manage, ok := findGroup(w, r, db, userID)
+	if !ok {
+		return
+	}
+	if !manage {
+		apierror.Write(w, r, "Only the group owner can change the invite code", http.StatusForbidden)
+		return
+	}
+
+	inviteCode, err := generateInviteCode()
+	if err != nil {
+		log.Printf("Error generating invite code: %v", err)
+		apierror.Write(w, r, "Failed to generate invite code", http.StatusInternalServerError)
+		return
+	}
+	group.InviteCode = inviteCode
+	if err := db.Model(group).Update("invite_code", inviteCode).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to update invite code", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(group); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// removeGroupMember removes a member from a group. The owner can remove anyone,
+// members can only remove themselves to leave the group.
+func removeGroupMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	memberID, err := strconv.Atoi(vars["userId"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	group, manage, ok := findGroup(w, r, db, userID)
+	if !ok {
+		return
+	}
+	if !manage && uint(memberID) != userID {
+		apierror.Write(w, r, "Only the group owner can remove other members", http.StatusForbidden)
+		return
+	}
+
+	result := db.Unscoped().Where("group_id = ? AND user_id = ?", group.ID, memberID).Delete(&models.GroupMember{})
+	if result.Error != nil {
+		log.Printf("Database error: %v", result.Error)
+		apierror.Write(w, r, "Failed to remove member", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		apierror.Write(w, r, "Member not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
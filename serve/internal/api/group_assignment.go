@@ -0,0 +1,340 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// AssignmentRequest represents the request body for assigning questions to a group
+type AssignmentRequest struct {
+	Title       string    `json:"title" validate:"required,max=200"`
+	Description string    `json:"description" validate:"max=20000"`
+	Deadline    time.Time `json:"deadline" validate:"required"`
+	QuestionIDs []uint    `json:"questionIds" validate:"required,max=100,unique"`
+}
+
+// QuestionProgress is a member's progress on one assigned question
+type QuestionProgress struct {
+	QuestionID uint       `json:"questionId"`
+	Attempts   int        `json:"attempts"` // Submissions up to and including the first accepted one
+	Solved     bool       `json:"solved"`
+	SolvedAt   *time.Time `json:"solvedAt"`
+	Late       bool       `json:"late"` // Solved after the deadline
+}
+
+// AssignmentProgress is a member's progress on one assignment
+type AssignmentProgress struct {
+	AssignmentID uint               `json:"assignmentId"`
+	Title        string             `json:"title"`
+	Deadline     time.Time          `json:"deadline"`
+	Solved       int                `json:"solved"`
+	SolvedOnTime int                `json:"solvedOnTime"`
+	Questions    []QuestionProgress `json:"questions"`
+}
+
+// MemberProgress is one member's line in a group's progress report
+type MemberProgress struct {
+	UserID       uint                 `json:"userId"`
+	Username     string               `json:"username"`
+	Solved       int                  `json:"solved"`
+	SolvedOnTime int                  `json:"solvedOnTime"`
+	Assignments  []AssignmentProgress `json:"assignments"`
+}
+
+// GroupProgress is the progress report of a group
+type GroupProgress struct {
+	GroupID uint             `json:"groupId"`
+	Members []MemberProgress `json:"members"`
+}
+
+// GroupAssignmentsHandler handles requests to /api/groups/{id}/assignments
+func GroupAssignmentsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getGroupAssignments(w, r)
+	case http.MethodPost:
+		createGroupAssignment(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GroupProgressHandler handles requests to /api/groups/{id}/progress
+func GroupProgressHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getGroupProgress(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// orderedAssignmentQuestions preloads assignment questions in the order they were given
+func orderedAssignmentQuestions(db *gorm.DB) *gorm.DB {
+	return db.Order("id ASC")
+}
+
+// loadAssignments returns a group's assignments, earliest deadline first
+func loadAssignments(db *gorm.DB, groupID uint) ([]models.Assignment, error) {
+	var assignments []models.Assignment
+	err := db.Preload("Questions", orderedAssignmentQuestions).
+		Where("group_id = ?", groupID).
+		Order("deadline ASC, id ASC").
+		Find(&assignments).Error
+	return assignments, err
+}
+
+func getGroupAssignments(w http.ResponseWriter, r *http.Request) {
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	group, _, ok := findGroup(w, r, db, userID)
+	if !ok {
+		return
+	}
+
+	assignments, err := loadAssignments(db, group.ID)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve assignments", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(assignments); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// createGroupAssignment assigns a set of published questions to a group
+func createGroupAssignment(w http.ResponseWriter, r *http.Request) {
+	var assignmentReq AssignmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&assignmentReq); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+	if !validRequest(w, r, &assignmentReq) {
+		return
+	}
+	assignmentReq.Title = strings.TrimSpace(assignmentReq.Title)
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	group, manage, ok := findGroup(w, r, db, userID)
+	if !ok {
+		return
+	}
+	if !manage {
+		apierror.Write(w, r, "Only the group owner can create assignments", http.StatusForbidden)
+		return
+	}
+
+	// Members must be able to open every question they are assigned
+	var found int64
+	err := db.Model(&models.Question{}).
+		Where("id IN ? AND published = ?", assignmentReq.QuestionIDs, true).
+		Count(&found).Error
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve questions", http.StatusInternalServerError)
+		return
+	}
+	if int(found) != len(assignmentReq.QuestionIDs) {
+		apierror.Write(w, r, "Question not found or not published", http.StatusNotFound)
+		return
+	}
+
+	assignment := models.Assignment{
+		GroupID:     group.ID,
+		Title:       assignmentReq.Title,
+		Description: assignmentReq.Description,
+		Deadline:    assignmentReq.Deadline,
+	}
+	for _, questionID := range assignmentReq.QuestionIDs {
+		assignment.Questions = append(assignment.Questions, models.AssignmentQuestion{QuestionID: questionID})
+	}
+
+	if err := db.Create(&assignment).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to create assignment", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(assignment); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// buildGroupProgress works out each member's progress on each assignment from
+// their submissions, which must be ordered by submission time
+func buildGroupProgress(assignments []models.Assignment, members []models.GroupMember, submissions []models.Submission) []MemberProgress {
+	type key struct{ userID, questionID uint }
+	progress := map[key]*QuestionProgress{}
+	for _, s := range submissions {
+		k := key{s.UserID, s.QuestionID}
+		p, ok := progress[k]
+		if !ok {
+			p = &QuestionProgress{QuestionID: s.QuestionID}
+			progress[k] = p
+		}
+		if p.Solved {
+			continue
+		}
+		p.Attempts++
+		if s.JudgeStatus == models.Accepted {
+			solvedAt := s.SubmissionTime
+			p.Solved = true
+			p.SolvedAt = &solvedAt
+		}
+	}
+
+	rows := make([]MemberProgress, 0, len(members))
+	for _, member := range members {
+		row := MemberProgress{
+			UserID:      member.UserID,
+			Username:    member.User.Username,
+			Assignments: make([]AssignmentProgress, 0, len(assignments)),
+		}
+		for _, assignment := range assignments {
+			ap := AssignmentProgress{
+				AssignmentID: assignment.ID,
+				Title:        assignment.Title,
+				Deadline:     assignment.Deadline,
+				Questions:    make([]QuestionProgress, 0, len(assignment.Questions)),
+			}
+			for _, question := range assignment.Questions {
+				qp := QuestionProgress{QuestionID: question.QuestionID}
+				if p, ok := progress[key{member.UserID, question.QuestionID}]; ok {
+					qp = *p
+				}
+				if qp.Solved {
+					qp.Late = qp.SolvedAt.After(assignment.Deadline)
+					ap.Solved++
+					if !qp.Late {
+						ap.SolvedOnTime++
+					}
+				}
+				ap.Questions = append(ap.Questions, qp)
+			}
+			row.Solved += ap.Solved
+			row.SolvedOnTime += ap.SolvedOnTime
+			row.Assignments = append(row.Assignments, ap)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// getGroupProgress reports how far members got with the group's assignments.
+// The owner sees every member, members only see themselves.
+func getGroupProgress(w http.ResponseWriter, r *http.Request) {
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	group, manage, ok := findGroup(w, r, db, userID)
+	if !ok {
+		return
+	}
+
+	assignments, err := loadAssignments(db, group.ID)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve assignments", http.StatusInternalServerError)
+		return
+	}
+
+	memberQuery := db.Preload("User").Where("group_id = ?", group.ID)
+	if !manage {
+		memberQuery = memberQuery.Where("user_id = ?", userID)
+	}
+	var members []models.GroupMember
+	if err := memberQuery.Order("id ASC").Find(&members).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve group members", http.StatusInternalServerError)
+		return
+	}
+
+	userIDs := make([]uint, 0, len(members))
+	for _, member := range members {
+		userIDs = append(userIDs, member.UserID)
+	}
+	var questionIDs []uint
+	for _, assignment := range assignments {
+		for _, question := range assignment.Questions {
+			questionIDs = append(questionIDs, question.QuestionID)
+		}
+	}
+
+	var submissions []models.Submission
+	if len(userIDs) > 0 && len(questionIDs) > 0 {
+		err := db.Select("user_id", "question_id", "judge_status", "submission_time").
+			Where("user_id IN ? AND question_id IN ?", userIDs, questionIDs).
+			Where("judge_status <> ?", models.SystemError).
+			Order("submission_time ASC, id ASC").
+			Find(&submissions).Error
+		if err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve submissions", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	response := GroupProgress{
+		GroupID: group.ID,
+		Members: buildGroupProgress(assignments, members, submissions),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
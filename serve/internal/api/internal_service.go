@@ -0,0 +1,187 @@
+package api
+
+import (
+	"sync"
+	"time"
+
+	"goera/serve/internal/auth"
+	"goera/serve/internal/config"
+	"goera/serve/internal/internalpb"
+	"goera/serve/internal/models"
+	"goera/serve/internal/storage"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// maxInternalMessageBytes bounds the messages exchanged with the judge, which
+// carry source code and test cases
+const maxInternalMessageBytes = 64 * 1024 * 1024
+
+// judgeServiceConfig has gRPC retry the judge's idempotent calls while it is
+// briefly unavailable, e.g. while it restarts. SubmitJob is retried as well:
+// the judge never answers it with UNAVAILABLE itself, so such a job did not
+// arrive. gRPC picks each delay at random up to the backoff, so instances do
+// not retry in step.
+const judgeServiceConfig = `{
+	"methodConfig": [{
+		"name": [
+			{"service": "goera.internal.v1.JudgeService", "method": "ListDeadLetters"},
+			{"service": "goera.internal.v1.JudgeService", "method": "SubmitJob"}
+		],
+		"retryPolicy": {
+			"maxAttempts": 3,
+			"initialBackoff": "0.2s",
+			"maxBackoff": "2s",
+			"backoffMultiplier": 2,
+			"retryableStatusCodes": ["UNAVAILABLE"]
+		}
+	}]
+}`
+
+// dockerImage is the sandbox image jobs run in unless the code-runner has a
+// pinned runtime image for their language
+const dockerImage = "go-judge-runner:latest"
+
+// defaultLanguage is the language of reference solutions and generators
+const defaultLanguage = "go"
+
+var (
+	judgeConnMu sync.Mutex
+	judgeConn   *grpc.ClientConn
+)
+
+// judgeClient returns a client for the judge at config.JudgeAddr. The
+// connection is shared and established lazily.
+func judgeClient() (internalpb.JudgeServiceClient, error) {
+	judgeConnMu.Lock()
+	defer judgeConnMu.Unlock()
+
+	if judgeConn == nil {
+		conn, err := grpc.NewClient(config.JudgeAddr,
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithUnaryInterceptor(auth.SignUnary),
+			grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+			grpc.WithDefaultServiceConfig(judgeServiceConfig),
+			grpc.WithDefaultCallOptions(
+				grpc.MaxCallRecvMsgSize(maxInternalMessageBytes),
+				grpc.MaxCallSendMsgSize(maxInternalMessageBytes),
+			),
+		)
+		if err != nil {
+			return nil, err
+		}
+		judgeConn = conn
+	}
+	return internalpb.NewJudgeServiceClient(judgeConn), nil
+}
+
+// NewInternalServer returns the gRPC server the judge reports results to.
+// Every call must be signed with one of the internal keys.
+func NewInternalServer() *grpc.Server {
+	server := grpc.NewServer(
+		grpc.MaxRecvMsgSize(maxInternalMessageBytes),
+		grpc.ChainUnaryInterceptor(auth.VerifyUnary),
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+	)
+	internalpb.RegisterResultServiceServer(server, &ResultServer{})
+	return server
+}
+
+// ResultServer receives verdicts and timeline events from the judge
+type ResultServer struct {
+	internalpb.UnimplementedResultServiceServer
+}
+
+// jobLimits returns the limits of a job on a question for a program in language
+func jobLimits(timeLimitMs, memoryLimitMB int, language string) *internalpb.Limits {
+	return &internalpb.Limits{
+		TimeLimitMs:   int64(timeLimitMs),
+		MemoryLimitMb: uint64(memoryLimitMB),
+		CpuCount:      1.0,
+		DockerImage:   dockerImage,
+		Language:      language,
+	}
+}
+
+// testCasesProto returns the test cases of a job. Data kept in file storage
+// is linked to rather than sent, for the code-runner to download.
+func testCasesProto(testCases []models.TestCase) ([]*internalpb.TestCase, error) {
+	out := make([]*internalpb.TestCase, 0, len(testCases))
+	for _, tc := range testCases {
+		pbTestCase := &internalpb.TestCase{Input: tc.Input, ExpectedOutput: tc.ExpectedOutput}
+		if tc.InputRef != "" {
+			link, err := storage.URL(tc.InputRef)
+			if err != nil {
+				return nil, err
+			}
+			pbTestCase.InputUrl = link
+		}
+		if tc.OutputRef != "" {
+			link, err := storage.URL(tc.OutputRef)
+			if err != nil {
+				return nil, err
+			}
+			pbTestCase.ExpectedOutputUrl = link
+		}
+		out = append(out, pbTestCase)
+	}
+	return out, nil
+}
+
+// verdictResults maps the judge's verdicts to the results stored as a
+// submission's judge status
+var verdictResults = map[internalpb.Verdict]Result{
+	internalpb.Verdict_VERDICT_ACCEPTED:              Accepted,
+	internalpb.Verdict_VERDICT_COMPILE_ERROR:         CompileError,
+	internalpb.Verdict_VERDICT_WRONG_ANSWER:          WrongAnswer,
+	internalpb.Verdict_VERDICT_MEMORY_LIMIT:          MemoryLimit,
+	internalpb.Verdict_VERDICT_TIME_LIMIT:            TimeLimit,
+	internalpb.Verdict_VERDICT_RUNTIME_ERROR:         RuntimeError,
+	internalpb.Verdict_VERDICT_OUTPUT_LIMIT_EXCEEDED: OutputLimitExceeded,
+}
+
+func eventsFromProto(events []*internalpb.Event) []SubmissionEventRequest {
+	out := make([]SubmissionEventRequest, 0, len(events))
+	for _, e := range events {
+		var occurredAt time.Time
+		if e.GetTime() != nil {
+			occurredAt = e.GetTime().AsTime()
+		}
+		out = append(out, SubmissionEventRequest{
+			Source:  e.GetSource(),
+			Type:    models.SubmissionEventType(e.GetType()),
+			Message: e.GetMessage(),
+			Time:    occurredAt,
+		})
+	}
+	return out
+}
+
+func usageFromProto(usages []*internalpb.TestCaseUsage) []models.SubmissionTestUsage {
+	out := make([]models.SubmissionTestUsage, 0, len(usages))
+	for _, usage := range usages {
+		out = append(out, models.SubmissionTestUsage{
+			TestCase:        int(usage.GetTestCase()),
+			WallTimeMs:      usage.GetWallTimeMs(),
+			CPUTimeMs:       usage.GetCpuTimeMs(),
+			PeakMemoryBytes: usage.GetPeakMemoryBytes(),
+			IOReadBytes:     usage.GetIoReadBytes(),
+			IOWriteBytes:    usage.GetIoWriteBytes(),
+		})
+	}
+	return out
+}
+
+func stderrFromProto(stderrs []*internalpb.TestCaseStderr) []models.SubmissionStderr {
+	out := make([]models.SubmissionStderr, 0, len(stderrs))
+	for _, stderr := range stderrs {
+		out = append(out, models.SubmissionStderr{
+			TestCase:  int(stderr.GetTestCase()),
+			Output:    stderr.GetOutput(),
+			Truncated: stderr.GetTruncated(),
+		})
+	}
+	return out
+}
@@ -1,96 +1,224 @@
-package api
-
-import (
-	"encoding/json"
-	"log"
-	"net/http"
-	"strconv"
-
-	"goera/serve/internal/database"
-	"goera/serve/internal/models"
-
-	"github.com/gorilla/mux"
-	"gorm.io/gorm"
-)
-
-type Result string
-
-const (
-	Accepted     Result = "Accepted"
-	CompileError Result = "CompileError"
-	WrongAnswer  Result = "WrongAnswer"
-	MemoryLimit  Result = "MemoryLimit"
-	TimeLimit    Result = "TimeLimit"
-	RuntimeError Result = "RuntimeError"
-)
-
-func ServerJudgeHandler(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodPost:
-		updateSubmission(w, r)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
-}
-
-// updateSubmission updates a submission's status and results
-func updateSubmission(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id, err := strconv.Atoi(vars["id"])
-	if err != nil {
-		http.Error(w, "Invalid submission ID", http.StatusBadRequest)
-		return
-	}
-
-	// Parse request body
-	var updateData struct {
-		QuestionID uint               `json:"questionId"`
-		Status     models.JudgeStatus `json:"status"`
-		Output     string             `json:"output"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&updateData); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	log.Println(updateData.Status)
-
-	db := database.GetDB()
-	if db == nil {
-		log.Println("Database connection is nil")
-		http.Error(w, "Database connection error", http.StatusInternalServerError)
-		return
-	}
-
-	// Find the submission
-	var submission models.Submission
-	result := db.First(&submission, id)
-	if result.Error != nil {
-		if result.Error == gorm.ErrRecordNotFound {
-			http.Error(w, "Submission not found", http.StatusNotFound)
-		} else {
-			log.Printf("Database error: %v", result.Error)
-			http.Error(w, "Failed to retrieve submission", http.StatusInternalServerError)
-		}
-		return
-	}
-
-	// Update fields
-	submission.JudgeStatus = updateData.Status
-	submission.Error = updateData.Output
-
-	// Save updates
-	result = db.Save(&submission)
-	if result.Error != nil {
-		log.Printf("Database error updating submission: %v", result.Error)
-		http.Error(w, "Failed to update submission", http.StatusInternalServerError)
-		return
-	}
-
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(submission); err != nil {
-		log.Printf("JSON encoding error: %v", err)
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-	}
-}
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"goera/serve/internal/database"
+	"goera/serve/internal/internalpb"
+	"goera/serve/internal/models"
+	"goera/serve/internal/storage"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+type Result string
+
+const (
+	Accepted            Result = "Accepted"
+	CompileError        Result = "CompileError"
+	WrongAnswer         Result = "WrongAnswer"
+	MemoryLimit         Result = "MemoryLimit"
+	TimeLimit           Result = "TimeLimit"
+	RuntimeError        Result = "RuntimeError"
+	OutputLimitExceeded Result = "OutputLimitExceeded"
+)
+
+// isFinal reports whether a submission has left the judging pipeline. Only a
+// pending or judging submission may take a verdict from the judge.
+func isFinal(status models.JudgeStatus) bool {
+	return status != models.Pending && status != models.Judging
+}
+
+// ReportResult applies a final verdict reported by the judge. A retried
+// delivery carrying an already applied idempotency key is acknowledged
+// without changes, and a verdict for a submission that already has one is
+// refused with FAILED_PRECONDITION, so duplicated or late deliveries cannot
+// overwrite it.
+func (s *ResultServer) ReportResult(ctx context.Context, req *internalpb.ReportResultRequest) (*internalpb.ReportResultResponse, error) {
+	verdict := models.JudgeStatus(verdictResults[req.GetResult().GetVerdict()])
+	if verdict == "" || !isFinal(verdict) {
+		return nil, status.Error(codes.InvalidArgument, "Result must carry a final verdict")
+	}
+	output := req.GetResult().GetOutput()
+	idempotencyKey := req.GetIdempotencyKey()
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		return nil, status.Error(codes.Internal, "Database connection error")
+	}
+	db = db.WithContext(ctx)
+
+	// Find the submission
+	var submission models.Submission
+	result := db.First(&submission, req.GetSubmissionId())
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, status.Error(codes.NotFound, "Submission not found")
+		}
+		log.Printf("Database error: %v", result.Error)
+		return nil, status.Error(codes.Internal, "Failed to retrieve submission")
+	}
+
+	// A long judge log is kept in file storage, with only its reference in the row
+	storedOutput := output
+	outputRef, err := storage.Offload(ctx, "logs", output)
+	if err != nil {
+		log.Printf("Failed to store judge log of submission %d: %v", submission.ID, err)
+		return nil, status.Error(codes.Internal, "Failed to store judge log")
+	}
+	if outputRef != "" {
+		storedOutput = ""
+	}
+
+	// The verdict is only applied while the submission is still waiting for
+	// one, checked in the update itself so concurrent deliveries cannot both win
+	duplicate, stale := false, false
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if idempotencyKey != "" {
+			var applied int64
+			if err := tx.Model(&models.JudgeCallback{}).Where("idempotency_key = ?", idempotencyKey).Count(&applied).Error; err != nil {
+				return err
+			}
+			if applied > 0 {
+				duplicate = true
+				return nil
+			}
+		}
+
+		result := tx.Model(&models.Submission{}).
+			Where("id = ? AND judge_status IN ?", submission.ID, []models.JudgeStatus{models.Pending, models.Judging}).
+			Updates(map[string]interface{}{
+				"judge_status": verdict,
+				"error":        storedOutput,
+				"error_ref":    outputRef,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			stale = true
+			return nil
+		}
+
+		if idempotencyKey != "" {
+			return tx.Create(&models.JudgeCallback{
+				SubmissionID:   submission.ID,
+				IdempotencyKey: idempotencyKey,
+				Status:         verdict,
+			}).Error
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Database error updating submission: %v", err)
+		return nil, status.Error(codes.Internal, "Failed to update submission")
+	}
+
+	if duplicate {
+		// A retry of a delivery that was already applied, acknowledge it again
+		return &internalpb.ReportResultResponse{}, nil
+	}
+
+	if stale {
+		if err := db.First(&submission, submission.ID).Error; err != nil {
+			log.Printf("Database error: %v", err)
+		}
+		recordSubmissionEvent(db, submission.ID, "serve", models.EventError,
+			fmt.Sprintf("Discarded late verdict %s, submission already has verdict %s", verdict, submission.JudgeStatus))
+		return nil, status.Errorf(codes.FailedPrecondition, "Submission already has final verdict %s", submission.JudgeStatus)
+	}
+
+	if err := saveReportedEvents(db, submission.ID, eventsFromProto(req.GetResult().GetEvents())); err != nil {
+		log.Printf("Failed to save reported events for submission %d: %v", submission.ID, err)
+	}
+	recordSubmissionEvent(db, submission.ID, "serve", models.EventCallbackReceived, fmt.Sprintf("Final verdict %s received", verdict))
+	if err := saveSubmissionStderr(db, submission.ID, stderrFromProto(req.GetResult().GetStderr())); err != nil {
+		log.Printf("Failed to save stderr for submission %d: %v", submission.ID, err)
+	}
+	if err := saveSubmissionUsage(db, &submission, usageFromProto(req.GetResult().GetUsage())); err != nil {
+		log.Printf("Failed to save resource usage for submission %d: %v", submission.ID, err)
+	}
+
+	submission.JudgeStatus = verdict
+	submission.Error = output
+	publishSubmission(&submission)
+	notify(db, submission.UserID, models.NotificationSubmissionJudged,
+		fmt.Sprintf("Your submission to %s was judged: %s", submission.QuestionName, submission.JudgeStatus),
+		fmt.Sprintf("/submission/%d", submission.ID))
+	sendVerdictWebhooks(db, &submission)
+
+	if err := updateContestResult(db, &submission); err != nil {
+		log.Printf("Failed to update contest result for submission %d: %v", submission.ID, err)
+	} else if submission.ContestID != nil {
+		scoreboardChanged(*submission.ContestID)
+	}
+
+	if submission.JudgeStatus == models.Accepted {
+		// Plagiarism scoring compares against every accepted submission, keep it off the delivery path
+		accepted := submission
+		go func() {
+			if err := scoreSubmissionSimilarity(db, &accepted); err != nil {
+				log.Printf("Failed to score similarity of submission %d: %v", accepted.ID, err)
+			}
+		}()
+	}
+
+	return &internalpb.ReportResultResponse{}, nil
+}
+
+// saveSubmissionUsage replaces the per-test resource usage stored for a
+// submission and sets its execution time and memory usage to the highest of
+// its tests
+func saveSubmissionUsage(db *gorm.DB, submission *models.Submission, usages []models.SubmissionTestUsage) error {
+	var wallTimeMs int64
+	var peakMemoryBytes uint64
+	for _, usage := range usages {
+		wallTimeMs = max(wallTimeMs, usage.WallTimeMs)
+		peakMemoryBytes = max(peakMemoryBytes, usage.PeakMemoryBytes)
+	}
+	submission.ExecutionTime = int(wallTimeMs)
+	submission.MemoryUsage = int((peakMemoryBytes + 1024*1024 - 1) / (1024 * 1024))
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("submission_id = ?", submission.ID).Delete(&models.SubmissionTestUsage{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Submission{}).Where("id = ?", submission.ID).Updates(map[string]interface{}{
+			"execution_time": submission.ExecutionTime,
+			"memory_usage":   submission.MemoryUsage,
+		}).Error; err != nil {
+			return err
+		}
+		if len(usages) == 0 {
+			return nil
+		}
+		for i := range usages {
+			usages[i].ID = 0
+			usages[i].SubmissionID = submission.ID
+		}
+		return tx.Create(&usages).Error
+	})
+}
+
+// saveSubmissionStderr replaces the stderr stored for a submission, so a
+// rejudge does not leave the previous run's output behind
+func saveSubmissionStderr(db *gorm.DB, submissionID uint, stderrs []models.SubmissionStderr) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("submission_id = ?", submissionID).Delete(&models.SubmissionStderr{}).Error; err != nil {
+			return err
+		}
+		if len(stderrs) == 0 {
+			return nil
+		}
+		for i := range stderrs {
+			stderrs[i].ID = 0
+			stderrs[i].SubmissionID = submissionID
+		}
+		return tx.Create(&stderrs).Error
+	})
+}
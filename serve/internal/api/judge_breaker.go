@@ -0,0 +1,129 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"goera/serve/internal/config"
+	"goera/serve/internal/metrics"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errJudgeCircuitOpen is returned instead of calling a judge that failed too
+// often recently. Submissions stay in the outbox until it recovers.
+var errJudgeCircuitOpen = errors.New("judge circuit breaker is open")
+
+var judgeCircuitTransitions = metrics.NewCounter("goera_judge_circuit_transitions_total",
+	"Changes of the circuit breaker in front of the judge, by the state entered", "state")
+
+type circuitState string
+
+const (
+	circuitClosed   circuitState = "closed"    // Calls go through
+	circuitOpen     circuitState = "open"      // Calls fail right away until the cooldown is over
+	circuitHalfOpen circuitState = "half_open" // One trial call decides whether to close again
+)
+
+// circuitBreaker stops calls to a dependency after
+// config.JudgeBreakerThreshold failures in a row, so requests do not pile up
+// waiting for timeouts. After config.JudgeBreakerCooldown a single trial call
+// is let through, and the breaker closes again once it succeeds.
+type circuitBreaker struct {
+	mu       sync.Mutex
+	state    circuitState
+	failures int       // Consecutive failures while closed
+	openedAt time.Time // When the breaker last opened
+	trial    bool      // Whether the trial call of the half open breaker is out
+}
+
+// judgeCircuit guards the delivery of submissions to the judge
+var judgeCircuit = &circuitBreaker{state: circuitClosed}
+
+// allow reports whether a call may go out. A caller that is allowed must
+// report the outcome with record.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if time.Since(b.openedAt) < config.JudgeBreakerCooldown {
+			return false
+		}
+		b.setState(circuitHalfOpen)
+		b.trial = true
+		return true
+	case circuitHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	default:
+		return true
+	}
+}
+
+// ready reports whether a call would be allowed, without claiming the trial
+// call of a half open breaker
+func (b *circuitBreaker) ready() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case circuitOpen:
+		return time.Since(b.openedAt) >= config.JudgeBreakerCooldown
+	case circuitHalfOpen:
+		return !b.trial
+	default:
+		return true
+	}
+}
+
+// record reports the outcome of an allowed call
+func (b *circuitBreaker) record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+	if !failed {
+		b.failures = 0
+		if b.state != circuitClosed {
+			log.Println("Judge circuit breaker closed, the judge is reachable again")
+			b.setState(circuitClosed)
+		}
+		return
+	}
+
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= config.JudgeBreakerThreshold {
+		if b.state != circuitOpen {
+			log.Printf("Judge circuit breaker opened after %d failed calls, retrying in %s", b.failures, config.JudgeBreakerCooldown)
+			b.setState(circuitOpen)
+		}
+		b.openedAt = time.Now()
+	}
+}
+
+// current returns the state of the breaker
+func (b *circuitBreaker) current() circuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// setState changes the state. The caller holds mu.
+func (b *circuitBreaker) setState(state circuitState) {
+	b.state = state
+	judgeCircuitTransitions.Inc(string(state))
+}
+
+// judgeUnreachable reports whether a judge call failed because the judge
+// could not be reached in time, as opposed to refusing the call
+func judgeUnreachable(err error) bool {
+	code := status.Code(err)
+	return code == codes.Unavailable || code == codes.DeadlineExceeded
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"goera/serve/internal/config"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+	"goera/serve/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// outboxClaimTimeout keeps the dispatcher away from a new outbox row while
+	// the request that created it makes the first delivery attempt
+	outboxClaimTimeout = 30 * time.Second
+	// outboxMaxBackoff caps the delay between attempts for one submission
+	outboxMaxBackoff = 5 * time.Minute
+	// outboxBatchSize is the number of submissions delivered per dispatcher run
+	outboxBatchSize = 50
+)
+
+// enqueueSubmission adds a submission to the judge outbox, to be delivered
+// after delay. Submissions already queued are left as they are. The trace of
+// tx's context is stored with it, so later deliveries continue that trace.
+func enqueueSubmission(tx *gorm.DB, submissionID uint, delay time.Duration) (*models.JudgeOutbox, error) {
+	entry := models.JudgeOutbox{
+		SubmissionID:  submissionID,
+		NextAttemptAt: time.Now().Add(delay),
+		TraceParent:   tracing.Inject(tx.Statement.Context),
+	}
+	err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "submission_id"}},
+		DoNothing: true,
+	}).Create(&entry).Error
+	return &entry, err
+}
+
+// outboxBackoff returns the delay before the next delivery attempt, doubling
+// from the dispatch interval with every failed attempt
+func outboxBackoff(attempts int) time.Duration {
+	delay := config.JudgeDispatchInterval
+	for i := 1; i < attempts && delay < outboxMaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, outboxMaxBackoff)
+}
+
+// deliverSubmission hands a queued submission to the judge. It leaves the
+// outbox once the judge accepted it; otherwise the next attempt is scheduled.
+func deliverSubmission(db *gorm.DB, entry *models.JudgeOutbox, submission *models.Submission, question *models.Question) error {
+	err := dispatchToJudge(db, submission, question)
+	if err == nil {
+		if err := db.Unscoped().Where("submission_id = ?", submission.ID).Delete(&models.JudgeOutbox{}).Error; err != nil {
+			log.Printf("Failed to remove submission %d from the judge outbox: %v", submission.ID, err)
+		}
+		return nil
+	}
+
+	entry.Attempts++
+	entry.NextAttemptAt = time.Now().Add(outboxBackoff(entry.Attempts))
+	entry.LastError = err.Error()
+	updateErr := db.Model(&models.JudgeOutbox{}).Where("submission_id = ?", submission.ID).Updates(map[string]interface{}{
+		"attempts":        entry.Attempts,
+		"next_attempt_at": entry.NextAttemptAt,
+		"last_error":      entry.LastError,
+	}).Error
+	if updateErr != nil {
+		log.Printf("Failed to reschedule submission %d in the judge outbox: %v", submission.ID, updateErr)
+	}
+	return err
+}
+
+// StartJudgeDispatcher periodically delivers submissions from the judge outbox.
+// It blocks, so run it in its own goroutine.
+func StartJudgeDispatcher() {
+	ticker := time.NewTicker(config.JudgeDispatchInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		db := database.GetDB()
+		if db == nil {
+			log.Println("Judge dispatcher: database connection is nil")
+			continue
+		}
+		dispatchOutbox(db)
+	}
+}
+
+// dispatchOutbox delivers the submissions whose next attempt is due. It stops
+// at the first submission the judge could not be reached for, as the rest
+// would only wait for the same timeout, and skips the run while the judge
+// circuit breaker is open.
+func dispatchOutbox(db *gorm.DB) {
+	if !judgeCircuit.ready() {
+		return
+	}
+
+	var entries []models.JudgeOutbox
+	err := db.Where("next_attempt_at <= ?", time.Now()).
+		Order("next_attempt_at, id").
+		Limit(outboxBatchSize).
+		Find(&entries).Error
+	if err != nil {
+		log.Printf("Judge dispatcher: failed to read the outbox: %v", err)
+		return
+	}
+
+	for i := range entries {
+		if !dispatchOutboxEntry(db, &entries[i]) {
+			return
+		}
+	}
+}
+
+// dispatchOutboxEntry tries to deliver one outbox entry, in a span of the
+// trace that queued it. It returns false when the judge could not be reached,
+// and true when the dispatcher can go on with the next entry.
+func dispatchOutboxEntry(db *gorm.DB, entry *models.JudgeOutbox) bool {
+	ctx, span := tracing.Start(tracing.Extract(context.Background(), entry.TraceParent), "judge_outbox.dispatch",
+		attribute.Int64("goera.submission_id", int64(entry.SubmissionID)),
+		attribute.Int("goera.outbox_attempts", entry.Attempts))
+	defer span.End()
+	db = db.WithContext(ctx)
+
+	var submission models.Submission
+	err := db.First(&submission, entry.SubmissionID).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("Judge dispatcher: failed to load submission %d: %v", entry.SubmissionID, err)
+		return true
+	}
+	if err != nil || (submission.JudgeStatus != models.Pending && submission.JudgeStatus != models.Judging) {
+		// Deleted or already judged, nothing left to deliver
+		db.Unscoped().Delete(entry)
+		return true
+	}
+
+	if time.Since(entry.CreatedAt) > config.JudgeOutboxMaxAge {
+		log.Printf("Judge dispatcher: giving up on submission %d after %d attempts", submission.ID, entry.Attempts)
+		recordSubmissionEvent(db, submission.ID, "serve", models.EventError,
+			fmt.Sprintf("Judge could not be reached for %s, marked as failed", config.JudgeOutboxMaxAge))
+		submission.JudgeStatus = models.SystemError
+		submission.Error = "The judge was unavailable for too long. Please submit again."
+		if err := db.Save(&submission).Error; err != nil {
+			log.Printf("Judge dispatcher: failed to update submission %d: %v", submission.ID, err)
+			return true
+		}
+		db.Unscoped().Delete(entry)
+		publishSubmission(&submission)
+		sendVerdictWebhooks(db, &submission)
+		return true
+	}
+
+	var question models.Question
+	if err := db.Preload("TestCases").First(&question, submission.QuestionID).Error; err != nil {
+		log.Printf("Judge dispatcher: failed to load question %d of submission %d: %v", submission.QuestionID, submission.ID, err)
+		return true
+	}
+
+	err = deliverSubmission(db, entry, &submission, &question)
+	if err == nil {
+		log.Printf("Judge dispatcher: delivered submission %d after %d failed attempts", submission.ID, entry.Attempts)
+		return true
+	}
+	log.Printf("Judge dispatcher: failed to deliver submission %d (attempt %d): %v", submission.ID, entry.Attempts, err)
+
+	var rejected *judgeRejectedError
+	return errors.As(err, &rejected)
+}
@@ -0,0 +1,19 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"goera/serve/internal/auth"
+)
+
+// JWKSHandler publishes the public keys login tokens are signed with, so other
+// services can validate them without sharing a secret
+func JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	if err := json.NewEncoder(w).Encode(auth.PublicJWKS()); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+	}
+}
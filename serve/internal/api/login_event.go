@@ -0,0 +1,148 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/config"
+	"goera/serve/internal/database"
+	"goera/serve/internal/mail"
+	"goera/serve/internal/models"
+	"goera/serve/internal/preferences"
+	"goera/serve/internal/utils"
+)
+
+// How logins are made, as recorded in LoginEvent.Method
+const (
+	loginMethodPassword = "password"
+	loginMethodOAuth    = "oauth"
+	loginMethodRegister = "register"
+)
+
+const (
+	// loginEventsKept is how many of a user's most recent logins are kept
+	loginEventsKept = 50
+	// maxLoginUserAgentLength bounds the user agent stored with a login, like
+	// the one stored with its session
+	maxLoginUserAgentLength = 512
+)
+
+// LoginEventsHandler handles requests to /api/logins
+func LoginEventsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getLoginEvents(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// recordLogin records that user signed in from the device r came from, and
+// emails them about it when it is a device none of their earlier logins came
+// from and they asked for login alerts. The very first recorded login of a
+// user is never new, as there is nothing to compare it with. Failing to record
+// is only logged, so it never stops a login.
+func recordLogin(r *http.Request, user *models.User, method string) {
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		return
+	}
+
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxLoginUserAgentLength {
+		userAgent = userAgent[:maxLoginUserAgentLength]
+	}
+	event := models.LoginEvent{
+		UserID:    user.ID,
+		Method:    method,
+		IP:        utils.ClientIP(r),
+		UserAgent: userAgent,
+	}
+
+	var earlier, sameDevice int64
+	if err := db.Model(&models.LoginEvent{}).Where("user_id = ?", user.ID).Count(&earlier).Error; err != nil {
+		log.Printf("Failed to look up the logins of user %d: %v", user.ID, err)
+		return
+	}
+	if earlier > 0 {
+		if err := db.Model(&models.LoginEvent{}).Where("user_id = ? AND user_agent = ?", user.ID, userAgent).Count(&sameDevice).Error; err != nil {
+			log.Printf("Failed to look up the logins of user %d: %v", user.ID, err)
+			return
+		}
+		event.NewDevice = sameDevice == 0
+	}
+
+	if err := db.Create(&event).Error; err != nil {
+		log.Printf("Failed to record login of user %d: %v", user.ID, err)
+		return
+	}
+	// Only the most recent logins are kept
+	kept := db.Model(&models.LoginEvent{}).Select("id").Where("user_id = ?", user.ID).Order("id DESC").Limit(loginEventsKept)
+	if err := db.Where("user_id = ? AND id NOT IN (?)", user.ID, kept).Delete(&models.LoginEvent{}).Error; err != nil {
+		log.Printf("Failed to prune the logins of user %d: %v", user.ID, err)
+	}
+
+	if !event.NewDevice || user.Email == "" {
+		return
+	}
+	prefs, err := preferences.Load(r.Context(), db, user.ID)
+	if err != nil {
+		log.Printf("Failed to load the preferences of user %d: %v", user.ID, err)
+		return
+	}
+	if prefs.LoginAlerts {
+		// Sending can take a while, and the login should not wait for it
+		go sendLoginAlertEmail(*user, event)
+	}
+}
+
+// sendLoginAlertEmail tells a user about a login from a new device. Failing
+// to send is only logged.
+func sendLoginAlertEmail(user models.User, event models.LoginEvent) {
+	link := fmt.Sprintf("%s/profile/%d", strings.TrimSuffix(config.PublicURL, "/"), user.ID)
+	body := fmt.Sprintf("Hello %s,\n\n"+
+		"Your Goera account was signed in to from a new device:\n\n"+
+		"Time: %s\nIP address: %s\nDevice: %s\n\n"+
+		"If this was you, there is nothing to do. If it was not, sign out the device from the Security tab of your profile and change your password:\n%s\n",
+		user.Username, event.CreatedAt.UTC().Format(time.RFC1123), event.IP, event.UserAgent, link)
+	if err := mail.Send(user.Email, "New sign in to your Goera account", body); err != nil {
+		log.Printf("Failed to send login alert to user %d: %v", user.ID, err)
+	}
+}
+
+// getLoginEvents lists the requesting user's recent logins, newest first
+func getLoginEvents(w http.ResponseWriter, r *http.Request) {
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	events := []models.LoginEvent{}
+	if err := db.Where("user_id = ?", userID).Order("id DESC").Find(&events).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve logins", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(events); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
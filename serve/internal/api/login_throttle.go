@@ -0,0 +1,233 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"goera/serve/internal/config"
+	"goera/serve/internal/database"
+	"goera/serve/internal/mail"
+	"goera/serve/internal/metrics"
+	"goera/serve/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	loginAttempts = metrics.NewCounter("goera_login_attempts_total",
+		"Login attempts by outcome: success, invalid, throttled or locked", "outcome")
+	loginFailures = metrics.NewCounter("goera_login_failures_total",
+		"Failed logins counted against an account or a client IP", "scope")
+	loginLockouts = metrics.NewCounter("goera_login_lockouts_total",
+		"Accounts locked after too many failed logins")
+	loginUnlocks = metrics.NewCounter("goera_login_unlocks_total",
+		"Accounts unlocked through the emailed link")
+)
+
+// loginThrottleKeys returns the throttles a login attempt counts against:
+// its account, whether or not it exists, and its client IP
+func loginThrottleKeys(username, ip string) []string {
+	return []string{"account:" + username, "ip:" + ip}
+}
+
+// loginDelay is how long after the last failure a throttle with the given
+// number of failures lets the next attempt through
+func loginDelay(failures int) time.Duration {
+	if failures < config.LoginFreeAttempts {
+		return 0
+	}
+	delay := config.LoginBaseDelay
+	for i := config.LoginFreeAttempts; i < failures && delay < config.LoginMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, config.LoginMaxDelay)
+}
+
+// expired reports whether the failures of a throttle are forgotten at now:
+// its lock ran out, or it has none and no failure happened for a lockout
+// duration
+func expired(t *models.LoginThrottle, now time.Time) bool {
+	if t.LockedUntil != nil {
+		return !now.Before(*t.LockedUntil)
+	}
+	return now.Sub(t.LastFailure) > config.LoginLockoutDuration
+}
+
+// beginLoginAttempt decides whether a login attempt counting against keys may
+// go ahead, returning how long it has to wait otherwise and whether that is
+// because its account is locked. An attempt that may go ahead is counted as a
+// failure before its password is checked, with the throttles locked, so that
+// attempts made at the same time see each other and a burst cannot get past
+// the delay or the lockout. loginSucceeded takes it back. An account reaching
+// the lockout threshold is locked when user is the account's user, and
+// unlockToken is then the token to email if the password was wrong.
+func beginLoginAttempt(db *gorm.DB, keys []string, user *models.User) (wait time.Duration, locked bool, unlockToken string, err error) {
+	now := time.Now()
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Throttles that do not exist yet could not be locked
+		rows := make([]models.LoginThrottle, len(keys))
+		for i, key := range keys {
+			rows[i] = models.LoginThrottle{Key: key}
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error; err != nil {
+			return err
+		}
+
+		var throttles []models.LoginThrottle
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("key IN ?", keys).Order("key").Find(&throttles).Error
+		if err != nil {
+			return err
+		}
+		for i := range throttles {
+			t := &throttles[i]
+			if t.Failures > 0 && expired(t, now) {
+				*t = models.LoginThrottle{Key: t.Key}
+			}
+			if t.LockedUntil != nil {
+				wait = max(wait, t.LockedUntil.Sub(now))
+				locked = true
+				continue
+			}
+			wait = max(wait, t.LastFailure.Add(loginDelay(t.Failures)).Sub(now))
+		}
+		if wait > 0 {
+			return nil
+		}
+
+		for i := range throttles {
+			t := &throttles[i]
+			t.Failures++
+			t.LastFailure = now
+
+			scope, _, _ := strings.Cut(t.Key, ":")
+			if scope == "account" && user != nil && t.Failures >= config.LoginLockoutThreshold {
+				token, err := newUnlockToken()
+				if err != nil {
+					return err
+				}
+				lockedUntil := now.Add(config.LoginLockoutDuration)
+				t.LockedUntil = &lockedUntil
+				t.UnlockToken = hashUnlockToken(token)
+				unlockToken = token
+			}
+			if err := tx.Save(t).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, false, "", err
+	}
+	return wait, locked, unlockToken, nil
+}
+
+// loginFailed reports a login attempt begun with beginLoginAttempt whose
+// password was wrong, or whose account does not exist. The failure is already
+// counted; when it locked user's account, its owner is emailed unlockToken.
+func loginFailed(keys []string, user *models.User, unlockToken string) {
+	for _, key := range keys {
+		scope, _, _ := strings.Cut(key, ":")
+		loginFailures.Inc(scope)
+	}
+	if unlockToken != "" && user != nil {
+		loginLockouts.Inc()
+		log.Printf("Locked account %q after %d failed logins", user.Username, config.LoginLockoutThreshold)
+		sendUnlockEmail(user, unlockToken)
+	}
+}
+
+// loginSucceeded takes back the failure beginLoginAttempt counted for a login
+// that succeeded. The failures of its account are forgotten, but those of the
+// client IP stay, or signing in to an account of one's own would reset the
+// IP's backoff between guesses.
+func loginSucceeded(db *gorm.DB, keys []string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, key := range keys {
+			if scope, _, _ := strings.Cut(key, ":"); scope == "account" {
+				if err := tx.Where("key = ?", key).Delete(&models.LoginThrottle{}).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			err := tx.Model(&models.LoginThrottle{}).Where("key = ? AND failures > 0", key).
+				Update("failures", gorm.Expr("failures - 1")).Error
+			if err != nil {
+				return err
+			}
+			if err := tx.Where("key = ? AND failures <= 0", key).Delete(&models.LoginThrottle{}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func newUnlockToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashUnlockToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// sendUnlockEmail tells a user their account was locked and how to unlock
+// it. Failing to send is only logged; the lock runs out on its own.
+func sendUnlockEmail(user *models.User, token string) {
+	if user.Email == "" {
+		return
+	}
+	link := strings.TrimSuffix(config.PublicURL, "/") + "/api/login/unlock?token=" + token
+	body := fmt.Sprintf("Hello %s,\n\n"+
+		"Your Goera account was locked after %d failed login attempts. It unlocks on its own in %s.\n\n"+
+		"If these attempts were yours, you can unlock it right away:\n%s\n\n"+
+		"If they were not, someone may be guessing your password. Unlocking is still safe, but consider choosing a stronger password.\n",
+		user.Username, config.LoginLockoutThreshold, config.LoginLockoutDuration, link)
+	if err := mail.Send(user.Email, "Your Goera account was locked", body); err != nil {
+		log.Printf("Failed to send unlock email to user %d: %v", user.ID, err)
+	}
+}
+
+// UnlockAccountHandler unlocks the account of a link from an unlock email.
+// The link is opened in a browser, so every outcome leads to the login page.
+func UnlockAccountHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+	db := database.GetDB()
+	if token == "" || db == nil {
+		http.Redirect(w, r, "/login?error=invalid_unlock_link", http.StatusSeeOther)
+		return
+	}
+
+	result := db.Where("unlock_token = ? AND key LIKE ?", hashUnlockToken(token), "account:%").
+		Delete(&models.LoginThrottle{})
+	if result.Error != nil {
+		log.Printf("Database error: %v", result.Error)
+		http.Redirect(w, r, "/login?error=server_error", http.StatusSeeOther)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Redirect(w, r, "/login?error=invalid_unlock_link", http.StatusSeeOther)
+		return
+	}
+
+	loginUnlocks.Inc()
+	http.Redirect(w, r, "/login?success=account_unlocked", http.StatusSeeOther)
+}
@@ -1,27 +1,43 @@
-package api
-
-import (
-	"net/http"
-	"time"
-)
-
-func LogoutHandler(w http.ResponseWriter, r *http.Request) {
-	http.SetCookie(w, &http.Cookie{
-		Name:     "token",
-		Value:    "",
-		Path:     "/",
-		Expires:  time.Now().Add(-1 * time.Hour),
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteStrictMode,
-	})
-
-	contentType := r.Header.Get("Content-Type")
-
-	if contentType == "application/json" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
-	http.Redirect(w, r, "/", http.StatusSeeOther)
-}
+package api
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"goera/serve/internal/auth"
+)
+
+func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	userID, signedIn := auth.UserIDFromContext(r.Context())
+	sessionID, hasSession := auth.SessionIDFromContext(r.Context())
+	if signedIn && hasSession {
+		if err := auth.RevokeSessions(userID, sessionID); err != nil {
+			log.Printf("Failed to revoke session %d: %v", sessionID, err)
+		}
+	}
+
+	clearTokenCookie(w)
+
+	contentType := r.Header.Get("Content-Type")
+
+	if contentType == "application/json" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// clearTokenCookie removes the login cookie from the browser
+func clearTokenCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "token",
+		Value:    "",
+		Path:     "/",
+		Expires:  time.Now().Add(-1 * time.Hour),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/config"
+	"goera/serve/internal/database"
+	"goera/serve/internal/maintenance"
+	"goera/serve/internal/models"
+	"goera/serve/internal/utils"
+)
+
+// MaintenanceRequest turns maintenance mode on or off
+type MaintenanceRequest struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message" validate:"max=1000"` // Empty shows MAINTENANCE_MESSAGE
+}
+
+// MaintenanceHandler handles requests to /api/admin/maintenance
+func MaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getMaintenance(w, r)
+	case http.MethodPut, http.MethodPost:
+		setMaintenance(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getMaintenance returns whether maintenance mode is on
+func getMaintenance(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, "Only administrators can view maintenance mode") {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(maintenance.Current()); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// setMaintenance lets an admin turn maintenance mode on or off for every
+// serve instance
+func setMaintenance(w http.ResponseWriter, r *http.Request) {
+	if !requireAdmin(w, r, "Only administrators can change maintenance mode") {
+		return
+	}
+
+	var maintenanceReq MaintenanceRequest
+	formProcessor := func(r *http.Request) (interface{}, error) {
+		enabled, err := strconv.ParseBool(r.FormValue("enabled"))
+		if err != nil {
+			return nil, err
+		}
+		return MaintenanceRequest{Enabled: enabled, Message: r.FormValue("message")}, nil
+	}
+	result, err := utils.ProcessRequestData(r, &maintenanceReq, formProcessor)
+	if err != nil {
+		apierror.Write(w, r, "Invalid maintenance request", http.StatusBadRequest)
+		return
+	}
+	if formData, ok := result.(MaintenanceRequest); ok {
+		maintenanceReq = formData
+	}
+	if !validRequest(w, r, &maintenanceReq) {
+		return
+	}
+
+	if !maintenanceReq.Enabled && config.MaintenanceMode {
+		apierror.Write(w, r, "Maintenance mode is kept on by the MAINTENANCE_MODE setting", http.StatusConflict)
+		return
+	}
+
+	userID, _ := auth.UserIDFromContext(r.Context())
+	db := database.GetDB()
+	state, err := maintenance.Set(db, maintenanceReq.Enabled, strings.TrimSpace(maintenanceReq.Message), userID)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to change maintenance mode", http.StatusInternalServerError)
+		return
+	}
+	if state.Enabled {
+		log.Printf("Maintenance mode turned on by user %d", userID)
+		audit(db, userID, models.AuditMaintenanceStarted, "maintenance", 1, state.Message)
+	} else {
+		log.Printf("Maintenance mode turned off by user %d", userID)
+		audit(db, userID, models.AuditMaintenanceEnded, "maintenance", 1, "")
+	}
+
+	if utils.IsFormRequest(r) {
+		http.Redirect(w, r, "/admin", http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(state); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,251 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+	"goera/serve/internal/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// UnreadNotifications is the response of /api/notifications/unread
+type UnreadNotifications struct {
+	Unread int64 `json:"unread"`
+}
+
+// NotificationsHandler handles requests to /api/notifications
+func NotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getNotifications(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// UnreadNotificationsHandler handles requests to /api/notifications/unread
+func UnreadNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		countUnreadNotifications(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// NotificationsReadHandler handles requests to /api/notifications/read
+func NotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		markNotificationsRead(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// NotificationReadHandler handles requests to /api/notifications/{id}/read
+func NotificationReadHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		markNotificationRead(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// notify creates a notification for a user. Failures are only logged since a
+// missed notification must never fail the action that caused it.
+func notify(db *gorm.DB, userID uint, notificationType models.NotificationType, message, link string) {
+	notification := models.Notification{
+		UserID:  userID,
+		Type:    notificationType,
+		Message: message,
+		Link:    link,
+	}
+	if err := db.Create(&notification).Error; err != nil {
+		log.Printf("Failed to create %s notification for user %d: %v", notificationType, userID, err)
+	}
+}
+
+// getNotifications returns the requester's notifications, newest first.
+// unread=true leaves out the ones already read.
+func getNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	pagination := utils.ParsePagination(r, "notifications")
+
+	query := db.Model(&models.Notification{}).Where("user_id = ?", userID)
+	if r.URL.Query().Get("unread") == "true" {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var totalItems int64
+	if err := query.Count(&totalItems).Error; err != nil {
+		log.Printf("Database error counting notifications: %v", err)
+		apierror.Write(w, r, "Failed to count notifications", http.StatusInternalServerError)
+		return
+	}
+
+	totalPages := utils.TotalPages(totalItems, pagination.PageSize)
+
+	var notifications []models.Notification
+	result := query.Order("created_at DESC, id DESC").Limit(pagination.PageSize).Offset(pagination.Offset()).Find(&notifications)
+	if result.Error != nil {
+		log.Printf("Database error: %v", result.Error)
+		apierror.Write(w, r, "Failed to retrieve notifications", http.StatusInternalServerError)
+		return
+	}
+
+	response := PaginatedResponse{
+		Data:       notifications,
+		Page:       pagination.Page,
+		PageSize:   pagination.PageSize,
+		TotalItems: totalItems,
+		TotalPages: totalPages,
+	}
+
+	utils.SetPageLinks(w, r, pagination, totalPages)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// countUnreadNotifications returns how many unread notifications the requester has
+func countUnreadNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	var unread UnreadNotifications
+	if err := db.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Count(&unread.Unread).Error; err != nil {
+		log.Printf("Database error counting notifications: %v", err)
+		apierror.Write(w, r, "Failed to count notifications", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(unread); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// markNotificationsRead marks all of the requester's notifications as read
+func markNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := db.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now()).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to mark notifications as read", http.StatusInternalServerError)
+		return
+	}
+
+	if utils.IsFormRequest(r) {
+		http.Redirect(w, r, "/notifications", http.StatusSeeOther)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// markNotificationRead marks one of the requester's notifications as read
+func markNotificationRead(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid notification ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	// Other users' notifications are reported as missing rather than forbidden
+	var notification models.Notification
+	if err := db.Where("user_id = ?", userID).First(&notification, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Notification not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve notification", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if notification.ReadAt == nil {
+		now := time.Now()
+		notification.ReadAt = &now
+		if err := db.Save(&notification).Error; err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to mark notification as read", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(notification); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,232 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"time"
+
+	"goera/serve/internal/auth"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+	"goera/serve/internal/oauth"
+	"goera/serve/internal/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+const oauthStateCookie = "oauth_state"
+
+var errIdentityLinkedElsewhere = errors.New("provider account is linked to another user")
+
+// signedInPage hands the browser over to /questions once the session cookie is
+// set. The token cookie is SameSite=Strict, so a plain redirect at the end of
+// the cross-site OAuth round trip would arrive without it.
+var signedInPage = template.Must(template.New("signedIn").Parse(
+	`<!DOCTYPE html><html><head><meta http-equiv="refresh" content="0;url=/questions"></head>` +
+		`<body><a href="/questions">Continue</a></body></html>`))
+
+// OAuthLoginHandler handles requests to /auth/{provider}/login
+func OAuthLoginHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		startOAuthLogin(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// OAuthCallbackHandler handles requests to /auth/{provider}/callback
+func OAuthCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		finishOAuthLogin(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// startOAuthLogin redirects to the provider's consent page with a random state
+// that is checked again on the callback
+func startOAuthLogin(w http.ResponseWriter, r *http.Request) {
+	provider, ok := oauth.Get(mux.Vars(r)["provider"])
+	if !ok {
+		http.Error(w, "Unknown login provider", http.StatusNotFound)
+		return
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		log.Printf("Failed to generate OAuth state: %v", err)
+		http.Redirect(w, r, "/login?error=server_error", http.StatusSeeOther)
+		return
+	}
+	state := hex.EncodeToString(buf)
+
+	// Lax so the cookie survives the top-level redirect back from the provider
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state,
+		Path:     "/auth/",
+		Expires:  time.Now().Add(10 * time.Minute),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, provider.Config.AuthCodeURL(state), http.StatusFound)
+}
+
+// finishOAuthLogin completes the provider round trip, links or creates the
+// local user and signs them in
+func finishOAuthLogin(w http.ResponseWriter, r *http.Request) {
+	provider, ok := oauth.Get(mux.Vars(r)["provider"])
+	if !ok {
+		http.Error(w, "Unknown login provider", http.StatusNotFound)
+		return
+	}
+
+	stateCookie, err := r.Cookie(oauthStateCookie)
+	http.SetCookie(w, &http.Cookie{Name: oauthStateCookie, Path: "/auth/", MaxAge: -1})
+	if err != nil || stateCookie.Value == "" || stateCookie.Value != r.URL.Query().Get("state") {
+		http.Redirect(w, r, "/login?error=oauth_failed", http.StatusSeeOther)
+		return
+	}
+
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		// The user declined consent or the provider reported an error
+		http.Redirect(w, r, "/login?error=oauth_failed", http.StatusSeeOther)
+		return
+	}
+
+	identity, err := provider.FetchIdentity(r.Context(), code)
+	if err != nil {
+		log.Printf("OAuth login with %s failed: %v", provider.Name, err)
+		http.Redirect(w, r, "/login?error=oauth_failed", http.StatusSeeOther)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		http.Redirect(w, r, "/login?error=server_error", http.StatusSeeOther)
+		return
+	}
+
+	currentUserID, _ := auth.UserIDFromContext(r.Context())
+	user, err := findOrCreateOAuthUser(db, provider.Name, identity, currentUserID)
+	if err != nil {
+		if errors.Is(err, errIdentityLinkedElsewhere) {
+			http.Redirect(w, r, "/login?error=oauth_already_linked", http.StatusSeeOther)
+			return
+		}
+		log.Printf("Failed to sign in %s user %s: %v", provider.Name, identity.ProviderUserID, err)
+		http.Redirect(w, r, "/login?error=server_error", http.StatusSeeOther)
+		return
+	}
+
+	token, expirationTime, err := auth.StartSession(r, user.ID)
+	if err != nil {
+		http.Redirect(w, r, "/login?error=server_error", http.StatusSeeOther)
+		return
+	}
+
+	utils.SetCookie(w, token, "token", expirationTime)
+	recordLogin(r, user, loginMethodOAuth)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := signedInPage.Execute(w, nil); err != nil {
+		log.Printf("Template error: %v", err)
+	}
+}
+
+// findOrCreateOAuthUser resolves the local user for a provider account. An
+// existing link wins; otherwise the account is linked to the signed-in user,
+// then to a user with the same verified email, and finally a new user is created.
+func findOrCreateOAuthUser(db *gorm.DB, provider string, identity *oauth.Identity, currentUserID uint) (*models.User, error) {
+	var user models.User
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var link models.OAuthIdentity
+		err := tx.Where("provider = ? AND provider_user_id = ?", provider, identity.ProviderUserID).First(&link).Error
+		if err == nil {
+			if currentUserID != 0 && link.UserID != currentUserID {
+				return errIdentityLinkedElsewhere
+			}
+			return tx.First(&user, link.UserID).Error
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		switch {
+		case currentUserID != 0:
+			err = tx.First(&user, currentUserID).Error
+		case identity.Email != "":
+			err = tx.Where("email = ?", identity.Email).First(&user).Error
+		default:
+			err = gorm.ErrRecordNotFound
+		}
+
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			username, err := availableUsername(tx, provider, identity)
+			if err != nil {
+				return err
+			}
+			// No password is set, so the account can only sign in through the provider
+			user = models.User{
+				Username: username,
+				Role:     models.RegularRole,
+				Email:    identity.Email,
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		}
+
+		if user.Email == "" && identity.Email != "" {
+			if err := tx.Model(&user).Update("email", identity.Email).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(&models.OAuthIdentity{
+			Provider:       provider,
+			ProviderUserID: identity.ProviderUserID,
+			Email:          identity.Email,
+			UserID:         user.ID,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// availableUsername picks a free username based on the provider's account name
+func availableUsername(db *gorm.DB, provider string, identity *oauth.Identity) (string, error) {
+	base := identity.Username
+	if base == "" {
+		base = fmt.Sprintf("%s_%s", provider, identity.ProviderUserID)
+	}
+
+	candidate := base
+	for i := 1; ; i++ {
+		var count int64
+		if err := db.Model(&models.User{}).Where("username = ?", candidate).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s_%d", base, i)
+	}
+}
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/config"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+	"goera/serve/internal/similarity"
+	"goera/serve/internal/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PlagiarismScanResult reports how many submissions a scan compared
+type PlagiarismScanResult struct {
+	QuestionID  uint `json:"questionId"`
+	Submissions int  `json:"submissions"`
+	Pairs       int  `json:"pairs"`
+}
+
+// PlagiarismReportHandler handles requests to /api/admin/plagiarism
+func PlagiarismReportHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getPlagiarismReport(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// PlagiarismScanHandler handles requests to /api/admin/plagiarism/scan
+func PlagiarismScanHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		scanPlagiarism(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// scoreSubmissionSimilarity compares an accepted submission with the accepted
+// submissions of other users to the same question and stores the scores
+func scoreSubmissionSimilarity(db *gorm.DB, submission *models.Submission) error {
+	var others []models.Submission
+	err := db.Where("question_id = ? AND judge_status = ? AND user_id <> ?", submission.QuestionID, models.Accepted, submission.UserID).
+		Find(&others).Error
+	if err != nil {
+		return err
+	}
+
+	fingerprint := similarity.Compute(submission.Code)
+	scores := make([]models.SimilarityScore, 0, len(others))
+	for _, other := range others {
+		scores = append(scores, newSimilarityScore(submission, fingerprint, &other, similarity.Compute(other.Code)))
+	}
+	return saveSimilarityScores(db, scores)
+}
+
+// newSimilarityScore builds the score of a pair, ordering it by submission ID
+func newSimilarityScore(a *models.Submission, fa similarity.Fingerprint, b *models.Submission, fb similarity.Fingerprint) models.SimilarityScore {
+	if a.ID > b.ID {
+		a, b = b, a
+	}
+	return models.SimilarityScore{
+		QuestionID:    a.QuestionID,
+		SubmissionAID: a.ID,
+		SubmissionBID: b.ID,
+		UserAID:       a.UserID,
+		UserBID:       b.UserID,
+		Score:         similarity.Score(fa, fb),
+	}
+}
+
+// saveSimilarityScores inserts scores, replacing those of pairs already compared
+func saveSimilarityScores(db *gorm.DB, scores []models.SimilarityScore) error {
+	if len(scores) == 0 {
+		return nil
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "submission_a_id"}, {Name: "submission_b_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"score", "updated_at"}),
+	}).CreateInBatches(&scores, 100).Error
+}
+
+// getPlagiarismReport lists submission pairs scoring at least the threshold,
+// most similar first. Supports questionId and threshold query parameters.
+func getPlagiarismReport(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	if !requireAdmin(w, r, "Only administrators can view the plagiarism report") {
+		return
+	}
+
+	threshold := config.PlagiarismThreshold
+	if thresholdParam := r.URL.Query().Get("threshold"); thresholdParam != "" {
+		parsed, err := strconv.ParseFloat(thresholdParam, 64)
+		if err != nil || parsed < 0 || parsed > 1 {
+			apierror.Write(w, r, "Invalid threshold, expected a number between 0 and 1", http.StatusBadRequest)
+			return
+		}
+		threshold = parsed
+	}
+
+	query := db.Model(&models.SimilarityScore{}).Where("score >= ?", threshold)
+	if questionIDStr := r.URL.Query().Get("questionId"); questionIDStr != "" {
+		questionID, err := strconv.Atoi(questionIDStr)
+		if err != nil {
+			apierror.Write(w, r, "Invalid question ID", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("question_id = ?", questionID)
+	}
+
+	pagination := utils.ParsePagination(r, "plagiarism")
+
+	var totalItems int64
+	if err := query.Count(&totalItems).Error; err != nil {
+		log.Printf("Database error counting similarity scores: %v", err)
+		apierror.Write(w, r, "Failed to count similarity scores", http.StatusInternalServerError)
+		return
+	}
+
+	totalPages := utils.TotalPages(totalItems, pagination.PageSize)
+
+	var scores []models.SimilarityScore
+	result := query.Order("score DESC, id ASC").Limit(pagination.PageSize).Offset(pagination.Offset()).Find(&scores)
+	if result.Error != nil {
+		log.Printf("Database error: %v", result.Error)
+		apierror.Write(w, r, "Failed to retrieve similarity scores", http.StatusInternalServerError)
+		return
+	}
+
+	response := PaginatedResponse{
+		Data:       scores,
+		Page:       pagination.Page,
+		PageSize:   pagination.PageSize,
+		TotalItems: totalItems,
+		TotalPages: totalPages,
+	}
+
+	utils.SetPageLinks(w, r, pagination, totalPages)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// scanPlagiarism recomputes the similarity of every pair of accepted
+// submissions to a question, e.g. for submissions accepted before scoring existed
+func scanPlagiarism(w http.ResponseWriter, r *http.Request) {
+	questionID, err := strconv.Atoi(r.URL.Query().Get("questionId"))
+	if err != nil {
+		apierror.Write(w, r, "Invalid question ID", http.StatusBadRequest)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	if !requireAdmin(w, r, "Only administrators can scan for plagiarism") {
+		return
+	}
+
+	var submissions []models.Submission
+	if err := db.Where("question_id = ? AND judge_status = ?", questionID, models.Accepted).Find(&submissions).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve submissions", http.StatusInternalServerError)
+		return
+	}
+
+	fingerprints := make([]similarity.Fingerprint, len(submissions))
+	for i := range submissions {
+		fingerprints[i] = similarity.Compute(submissions[i].Code)
+	}
+
+	var scores []models.SimilarityScore
+	for i := range submissions {
+		for j := i + 1; j < len(submissions); j++ {
+			if submissions[i].UserID == submissions[j].UserID {
+				continue
+			}
+			scores = append(scores, newSimilarityScore(&submissions[i], fingerprints[i], &submissions[j], fingerprints[j]))
+		}
+	}
+
+	if err := saveSimilarityScores(db, scores); err != nil {
+		log.Printf("Database error saving similarity scores: %v", err)
+		apierror.Write(w, r, "Failed to save similarity scores", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(PlagiarismScanResult{
+		QuestionID:  uint(questionID),
+		Submissions: len(submissions),
+		Pairs:       len(scores),
+	}); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/config"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+	"goera/serve/internal/utils"
+)
+
+// Names of the checks a question has to pass before it is published
+const (
+	PublishCheckHiddenTests       = "hidden_tests"
+	PublishCheckLimits            = "limits"
+	PublishCheckDifficulty        = "difficulty"
+	PublishCheckReferenceSolution = "reference_solution"
+)
+
+// PublishCheck is one item of a question's publishing checklist
+type PublishCheck struct {
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message"`
+}
+
+// PublishChecklistHandler handles requests to /api/questions/{id}/publish-checklist
+func PublishChecklistHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getPublishChecklist(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getPublishChecklist shows a question's editors what still stands in the way
+// of publishing it. The reference solution is not run here, only when the
+// question is published.
+func getPublishChecklist(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	question, _, ok := loadEditableQuestion(w, r, db)
+	if !ok {
+		return
+	}
+
+	var testCases []models.TestCase
+	if err := db.Where("question_id = ?", question.ID).Order("id").Find(&testCases).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve test cases", http.StatusInternalServerError)
+		return
+	}
+
+	checks, _ := publishChecklist(question, testCases, false)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(checks); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// publishChecklist checks whether question can be published with the given
+// test cases. With runSolution its reference solution is run on the judge once
+// every other check passed; an error means the judge could not run it.
+func publishChecklist(question *models.Question, testCases []models.TestCase, runSolution bool) ([]PublishCheck, error) {
+	hidden := len(testCases) - len(sampleTestCases(testCases))
+	tests := PublishCheck{Name: PublishCheckHiddenTests, Passed: hidden > 0}
+	if tests.Passed {
+		tests.Message = fmt.Sprintf("%d hidden test cases", hidden)
+	} else {
+		tests.Message = "At least one test case is needed besides the example"
+	}
+
+	limits := PublishCheck{Name: PublishCheckLimits, Passed: question.TimeLimit > 0 && question.MemoryLimit > 0}
+	if limits.Passed {
+		limits.Message = fmt.Sprintf("Time limit %d ms, memory limit %d MB", question.TimeLimit, question.MemoryLimit)
+	} else {
+		limits.Message = "Both the time and the memory limit must be set"
+	}
+
+	difficulty := PublishCheck{Name: PublishCheckDifficulty, Passed: question.Difficulty.IsValid()}
+	if difficulty.Passed {
+		difficulty.Message = fmt.Sprintf("Difficulty %s", question.Difficulty)
+	} else {
+		difficulty.Message = "The question needs a difficulty"
+	}
+
+	checks := []PublishCheck{tests, limits, difficulty}
+	ready := tests.Passed && limits.Passed && difficulty.Passed
+
+	solution := PublishCheck{Name: PublishCheckReferenceSolution, Passed: true}
+	switch {
+	case question.ReferenceSolution == "" && config.PublishRequiresReferenceSolution:
+		solution.Passed = false
+		solution.Message = "A reference solution is required"
+	case question.ReferenceSolution == "":
+		solution.Message = "No reference solution"
+	case !runSolution || !ready:
+		solution.Message = "The reference solution is run on the test cases when the question is published"
+	default:
+		err := validateReferenceSolution(question.ReferenceSolution, testCases, question.TimeLimit, question.MemoryLimit, question.BuildFlagList())
+		var failure *referenceSolutionError
+		if errors.As(err, &failure) {
+			solution.Passed = false
+			solution.Message = failure.Error()
+		} else if err != nil {
+			return append(checks, solution), err
+		} else {
+			solution.Message = "The reference solution passes every test case"
+		}
+	}
+	return append(checks, solution), nil
+}
+
+// failedPublishChecks returns the checks that did not pass
+func failedPublishChecks(checks []PublishCheck) []PublishCheck {
+	var failed []PublishCheck
+	for _, check := range checks {
+		if !check.Passed {
+			failed = append(failed, check)
+		}
+	}
+	return failed
+}
+
+// checkPublishable runs the publishing checklist of question. On failure it
+// writes the error response, listing the failed checks, and returns false.
+func checkPublishable(w http.ResponseWriter, r *http.Request, question *models.Question, testCases []models.TestCase) bool {
+	checks, err := publishChecklist(question, testCases, true)
+	if err != nil {
+		writeReferenceSolutionError(w, r, question.ID, err)
+		return false
+	}
+	failed := failedPublishChecks(checks)
+	if len(failed) == 0 {
+		return true
+	}
+
+	if utils.IsFormRequest(r) {
+		names := make([]string, len(failed))
+		for i, check := range failed {
+			names[i] = check.Name
+		}
+		http.Redirect(w, r, fmt.Sprintf("/question/%d?error=publish_checklist&failed=%s", question.ID, strings.Join(names, ",")), http.StatusSeeOther)
+		return false
+	}
+	apierror.WriteDetails(w, r, "Question is not ready to be published", http.StatusUnprocessableEntity, failed)
+	return false
+}
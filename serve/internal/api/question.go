@@ -1,779 +1,1234 @@
-package api
-
-import (
-	"encoding/json"
-	"errors"
-	"fmt"
-	"log"
-	"net/http"
-	"strconv"
-	"time"
-
-	"goera/serve/internal/auth"
-	"goera/serve/internal/database"
-	"goera/serve/internal/models"
-	"goera/serve/internal/utils"
-
-	"github.com/gorilla/mux"
-	"gorm.io/gorm"
-)
-
-// SampleIO represents a single pair of input and output examples
-type SampleIO struct {
-	Input  string `json:"input"`
-	Output string `json:"output"`
-}
-
-type QuestionRequest struct {
-	Title         string   `json:"title"`
-	Content       string   `json:"content"`
-	TimeLimit     int      `json:"time_limit_ms"`
-	MemoryLimit   int      `json:"memory_limit_mb"`
-	SampleInputs  []string `json:"sample_inputs"`
-	SampleOutputs []string `json:"sample_outputs"`
-	Tags          string   `json:"tags"`
-}
-
-type QuestionPublishRequest struct {
-	Published bool `json:"published"`
-}
-
-type PaginatedResponse struct {
-	Data       any   `json:"data"`
-	Page       int   `json:"page"`
-	PageSize   int   `json:"page_size"`
-	TotalItems int64 `json:"total_items"`
-	TotalPages int   `json:"total_pages"`
-}
-
-type QuestionsByIdResponse struct {
-}
-
-func QuestionsHandler(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		getQuestions(w, r)
-	case http.MethodPost:
-		createQuestion(w, r)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
-}
-
-// QuestionHandler handles all requests to /api/questions/{id}
-func QuestionHandler(w http.ResponseWriter, r *http.Request) {
-	// Check for method override in form submissions
-	if r.Method == http.MethodPost {
-		if err := r.ParseForm(); err == nil {
-			if method := r.FormValue("_method"); method == "PUT" {
-				r.Method = http.MethodPut
-			} else if method == "DELETE" {
-				r.Method = http.MethodDelete
-			}
-		}
-	}
-
-	switch r.Method {
-	case http.MethodGet:
-		getQuestionByID(w, r)
-	case http.MethodPut:
-		updateQuestion(w, r)
-	case http.MethodDelete:
-		deleteQuestion(w, r)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
-}
-
-func PublishQuestionHandler(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodPut, http.MethodPost:
-		publishQuestion(w, r)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
-}
-
-func TestCaseHandler(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		getTestCasesByQuestionID(w, r)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
-}
-
-func getQuestions(w http.ResponseWriter, r *http.Request) {
-	db := database.GetDB()
-	if db == nil {
-		log.Println("Database connection is nil")
-		http.Error(w, "Database connection error", http.StatusInternalServerError)
-		return
-	}
-
-	userID, userExists := auth.UserIDFromContext(r.Context())
-	if !userExists {
-		log.Println("User ID not found in context")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	// Parse pagination parameters
-	page := 1
-	pageSize := 3
-
-	if pageParam := r.URL.Query().Get("page"); pageParam != "" {
-		if parsedPage, err := strconv.Atoi(pageParam); err == nil && parsedPage > 0 {
-			page = parsedPage
-		}
-	}
-
-	if pageSizeParam := r.URL.Query().Get("page_size"); pageSizeParam != "" {
-		if parsedPageSize, err := strconv.Atoi(pageSizeParam); err == nil && parsedPageSize > 0 && parsedPageSize <= 100 {
-			pageSize = parsedPageSize
-		}
-	}
-
-	offset := (page - 1) * pageSize
-
-	var user models.User
-	result := db.First(&user, userID)
-	if result.Error != nil {
-		log.Printf("Database error: %v", result.Error)
-		http.Error(w, "Failed to retrieve user", http.StatusInternalServerError)
-		return
-	}
-
-	query := db
-	if user.Role != models.AdminRole {
-		query = query.Where("published = ? OR user_id = ?", true, userID)
-	}
-
-	var totalItems int64
-	if err := query.Model(&models.Question{}).Count(&totalItems).Error; err != nil {
-		log.Printf("Database error counting questions: %v", err)
-		http.Error(w, "Failed to count questions", http.StatusInternalServerError)
-		return
-	}
-
-	totalPages := int((totalItems + int64(pageSize) - 1) / int64(pageSize))
-
-	var questions []models.Question
-	result = query.Limit(pageSize).Offset(offset).Find(&questions)
-	if result.Error != nil {
-		log.Printf("Database error: %v", result.Error)
-		http.Error(w, "Failed to retrieve questions", http.StatusInternalServerError)
-		return
-	}
-
-	response := PaginatedResponse{
-		Data:       questions,
-		Page:       page,
-		PageSize:   pageSize,
-		TotalItems: totalItems,
-		TotalPages: totalPages,
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("JSON encoding error: %v", err)
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-	}
-}
-
-func getQuestionByID(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id, err := strconv.Atoi(vars["id"])
-	if err != nil {
-		http.Error(w, "Invalid question ID", http.StatusBadRequest)
-		return
-	}
-
-	db := database.GetDB()
-	if db == nil {
-		log.Println("Database connection is nil")
-		http.Error(w, "Database connection error", http.StatusInternalServerError)
-		return
-	}
-
-	userID, userExists := auth.UserIDFromContext(r.Context())
-	if !userExists {
-		log.Println("User ID not found in context")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	var question models.Question
-	result := db.First(&question, id)
-	if result.Error != nil {
-		if result.Error == gorm.ErrRecordNotFound {
-			http.Error(w, "Question not found", http.StatusNotFound)
-		} else {
-			log.Printf("Database error: %v", result.Error)
-			http.Error(w, "Failed to retrieve question", http.StatusInternalServerError)
-		}
-		return
-	}
-
-	var user models.User
-	result = db.First(&user, userID)
-	if result.Error != nil {
-		log.Printf("Database error: %v", result.Error)
-		http.Error(w, "Failed to retrieve user", http.StatusInternalServerError)
-		return
-	}
-
-	// Users can view questions if:
-	// 1. They are admin
-	// 2. The question is published
-	// 3. They are the owner of the question
-	if !question.Published && user.Role != models.AdminRole && question.UserID != userID {
-		http.Error(w, "Unauthorized to view this question", http.StatusForbidden)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(question); err != nil {
-		log.Printf("JSON encoding error: %v", err)
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-	}
-}
-
-func createQuestion(w http.ResponseWriter, r *http.Request) {
-	var questionReq QuestionRequest
-
-	// Process form data using our utility function
-	formProcessor := func(r *http.Request) (interface{}, error) {
-		var formReq QuestionRequest
-
-		formReq.Title = r.FormValue("title")
-		formReq.Content = r.FormValue("content")
-
-		// Parse time limit
-		if timeLimitStr := r.FormValue("time_limit_ms"); timeLimitStr != "" {
-			timeLimit, err := strconv.Atoi(timeLimitStr)
-			if err != nil {
-				return nil, fmt.Errorf("invalid time limit: %v", err)
-			}
-			formReq.TimeLimit = timeLimit
-		}
-
-		// Parse memory limit
-		if memoryLimitStr := r.FormValue("memory_limit_mb"); memoryLimitStr != "" {
-			memoryLimit, err := strconv.Atoi(memoryLimitStr)
-			if err != nil {
-				return nil, fmt.Errorf("invalid memory limit: %v", err)
-			}
-			formReq.MemoryLimit = memoryLimit
-		}
-
-		// Get sample inputs and outputs
-		formReq.SampleInputs = r.Form["sample_inputs[]"]
-		formReq.SampleOutputs = r.Form["sample_outputs[]"]
-
-		// Get tags
-		formReq.Tags = r.FormValue("tags")
-
-		// Validate required fields
-		if formReq.Title == "" || formReq.Content == "" {
-			return nil, fmt.Errorf("title and content are required")
-		}
-
-		log.Println("Form data processed successfully:", formReq.Title)
-		log.Println("Sample inputs:", formReq.SampleInputs)
-		log.Println("Sample outputs:", formReq.SampleOutputs)
-
-		return formReq, nil
-	}
-
-	result, err := utils.ProcessRequestData(r, &questionReq, formProcessor)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	// If the result came from form processing, we need to update our questionReq
-	if formData, ok := result.(QuestionRequest); ok {
-		questionReq = formData
-	}
-
-	userID, userExists := auth.UserIDFromContext(r.Context())
-	if !userExists {
-		log.Println("User ID not found in context")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	question := models.Question{
-		Title:       questionReq.Title,
-		Content:     questionReq.Content,
-		UserID:      userID,
-		Published:   false,
-		TimeLimit:   questionReq.TimeLimit,
-		MemoryLimit: questionReq.MemoryLimit,
-		Tags:        questionReq.Tags,
-	}
-	db := database.GetDB()
-	if db == nil {
-		log.Println("Database connection is nil")
-		http.Error(w, "Database connection error", http.StatusInternalServerError)
-		return
-	}
-
-	dbResult := db.Create(&question)
-	if dbResult.Error != nil {
-		log.Printf("Database error: %v", dbResult.Error)
-		http.Error(w, "Failed to create question", http.StatusInternalServerError)
-		return
-	}
-
-	var testCases []models.TestCase
-	for i := range questionReq.SampleInputs {
-		if i < len(questionReq.SampleOutputs) {
-			testCase := models.TestCase{
-				QuestionID:     question.ID,
-				Input:          questionReq.SampleInputs[i],
-				ExpectedOutput: questionReq.SampleOutputs[i],
-			}
-			testCases = append(testCases, testCase)
-		}
-	}
-
-	if len(testCases) > 0 {
-		if err := db.Create(&testCases).Error; err != nil {
-			log.Printf("Failed to create test cases: %v", err)
-			http.Error(w, "Failed to create test cases", http.StatusInternalServerError)
-			return
-		}
-	}
-
-	log.Printf("Question created successfully with ID: %d", question.ID)
-
-	// Based on content type, return appropriate response
-	if utils.IsJSONRequest(r) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusCreated)
-		if err := json.NewEncoder(w).Encode(question); err != nil {
-			log.Printf("JSON encoding error: %v", err)
-			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-		}
-	} else {
-		http.Redirect(w, r, fmt.Sprintf("/question/%d", question.ID), http.StatusSeeOther)
-	}
-}
-
-func updateQuestion(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id, err := strconv.Atoi(vars["id"])
-	if err != nil {
-		http.Error(w, "Invalid question ID", http.StatusBadRequest)
-		return
-	}
-
-	var questionReq QuestionRequest
-
-	formProcessor := func(r *http.Request) (any, error) {
-		var formReq QuestionRequest
-
-		formReq.Title = r.FormValue("title")
-		formReq.Content = r.FormValue("content")
-
-		// Parse time limit
-		if timeLimitStr := r.FormValue("time_limit_ms"); timeLimitStr != "" {
-			timeLimit, err := strconv.Atoi(timeLimitStr)
-			if err != nil {
-				return nil, fmt.Errorf("invalid time limit: %v", err)
-			}
-			formReq.TimeLimit = timeLimit
-		}
-
-		// Parse memory limit
-		if memoryLimitStr := r.FormValue("memory_limit_mb"); memoryLimitStr != "" {
-			memoryLimit, err := strconv.Atoi(memoryLimitStr)
-			if err != nil {
-				return nil, fmt.Errorf("invalid memory limit: %v", err)
-			}
-			formReq.MemoryLimit = memoryLimit
-		}
-
-		// Collect sample inputs and outputs
-		formReq.SampleInputs = r.Form["sample_inputs[]"]
-		formReq.SampleOutputs = r.Form["sample_outputs[]"]
-
-		// Validate input and output pairs
-		if len(formReq.SampleInputs) != len(formReq.SampleOutputs) {
-			return nil, fmt.Errorf("number of sample inputs and outputs must match")
-		}
-
-		formReq.Tags = r.FormValue("tags")
-
-		// Validate required fields
-		if formReq.Title == "" || formReq.Content == "" {
-			return nil, fmt.Errorf("title and content are required")
-		}
-
-		return formReq, nil
-	}
-
-	result, err := utils.ProcessRequestData(r, &questionReq, formProcessor)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	if formData, ok := result.(QuestionRequest); ok {
-		questionReq = formData
-	}
-
-	userID, userExists := auth.UserIDFromContext(r.Context())
-	if !userExists {
-		log.Println("User ID not found in context")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	db := database.GetDB()
-	if db == nil {
-		log.Println("Database connection is nil")
-		http.Error(w, "Database connection error", http.StatusInternalServerError)
-		return
-	}
-
-	// Start a transaction
-	tx := db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
-
-	var question models.Question
-	if err := tx.First(&question, id).Error; err != nil {
-		tx.Rollback()
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			http.Error(w, "Question not found", http.StatusNotFound)
-		} else {
-			log.Printf("Database error: %v", err)
-			http.Error(w, "Failed to retrieve question", http.StatusInternalServerError)
-		}
-		return
-	}
-
-	var user models.User
-	if err := tx.First(&user, userID).Error; err != nil {
-		tx.Rollback()
-		log.Printf("Database error: %v", err)
-		http.Error(w, "Failed to retrieve user", http.StatusInternalServerError)
-		return
-	}
-
-	// Check permissions
-	if question.UserID != userID && user.Role != models.AdminRole {
-		tx.Rollback()
-		if utils.IsFormRequest(r) {
-			http.Redirect(w, r, fmt.Sprintf("/question/%d", question.ID), http.StatusSeeOther)
-			return
-		}
-		http.Error(w, "Unauthorized to edit this question", http.StatusForbidden)
-		return
-	}
-
-	// Update question fields
-	question.Title = questionReq.Title
-	question.Content = questionReq.Content
-	question.TimeLimit = questionReq.TimeLimit
-	question.MemoryLimit = questionReq.MemoryLimit
-	question.Tags = questionReq.Tags
-
-	// Handle publishing if the user is an admin
-	if user.Role == models.AdminRole {
-		// Assume form includes 'published' field; adjust as needed
-		if publishedStr := r.FormValue("published"); publishedStr != "" {
-			published, err := strconv.ParseBool(publishedStr)
-			if err != nil {
-				tx.Rollback()
-				http.Error(w, "Invalid published value", http.StatusBadRequest)
-				return
-			}
-			question.Published = published
-			if published {
-				now := time.Now()
-				question.PublishedAt = &now
-				question.PublishedBy = &user.ID
-			} else {
-				question.PublishedAt = nil
-				question.PublishedBy = nil
-			}
-		}
-	}
-
-	// Save the question
-	if err := tx.Save(&question).Error; err != nil {
-		tx.Rollback()
-		log.Printf("Database error: %v", err)
-		http.Error(w, "Failed to update question", http.StatusInternalServerError)
-		return
-	}
-
-	// Delete existing test cases
-	if err := tx.Where("question_id = ?", question.ID).Delete(&models.TestCase{}).Error; err != nil {
-		tx.Rollback()
-		log.Printf("Failed to delete test cases: %v", err)
-		http.Error(w, "Failed to update test cases", http.StatusInternalServerError)
-		return
-	}
-
-	// Create new test cases
-	var testCases []models.TestCase
-	for i := range questionReq.SampleInputs {
-		testCase := models.TestCase{
-			QuestionID:     question.ID,
-			Input:          questionReq.SampleInputs[i],
-			ExpectedOutput: questionReq.SampleOutputs[i],
-		}
-		testCases = append(testCases, testCase)
-	}
-
-	if len(testCases) > 0 {
-		if err := tx.Create(&testCases).Error; err != nil {
-			tx.Rollback()
-			log.Printf("Failed to create test cases: %v", err)
-			http.Error(w, "Failed to create test cases", http.StatusInternalServerError)
-			return
-		}
-	}
-
-	// Commit transaction
-	if err := tx.Commit().Error; err != nil {
-		tx.Rollback()
-		log.Printf("Failed to commit transaction: %v", err)
-		http.Error(w, "Failed to update question", http.StatusInternalServerError)
-		return
-	}
-
-	if utils.IsFormRequest(r) {
-		http.Redirect(w, r, fmt.Sprintf("/question/%d", question.ID), http.StatusSeeOther)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(question); err != nil {
-		log.Printf("JSON encoding error: %v", err)
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-	}
-}
-
-func deleteQuestion(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id, err := strconv.Atoi(vars["id"])
-	if err != nil {
-		http.Error(w, "Invalid question ID", http.StatusBadRequest)
-		return
-	}
-
-	userID, userExists := auth.UserIDFromContext(r.Context())
-	if !userExists {
-		log.Println("User ID not found in context")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	db := database.GetDB()
-	if db == nil {
-		log.Println("Database connection is nil")
-		http.Error(w, "Database connection error", http.StatusInternalServerError)
-		return
-	}
-
-	var question models.Question
-	result := db.First(&question, id)
-	if result.Error != nil {
-		if result.Error == gorm.ErrRecordNotFound {
-			http.Error(w, "Question not found", http.StatusNotFound)
-		} else {
-			log.Printf("Database error: %v", result.Error)
-			http.Error(w, "Failed to retrieve question", http.StatusInternalServerError)
-		}
-		return
-	}
-
-	var user models.User
-	result = db.First(&user, userID)
-	if result.Error != nil {
-		log.Printf("Database error: %v", result.Error)
-		http.Error(w, "Failed to retrieve user", http.StatusInternalServerError)
-		return
-	}
-
-	if question.UserID != userID && user.Role != models.AdminRole {
-		http.Error(w, "Unauthorized to delete this question", http.StatusForbidden)
-		return
-	}
-
-	result = db.Delete(&question)
-	if result.Error != nil {
-		log.Printf("Database error: %v", result.Error)
-		http.Error(w, "Failed to delete question", http.StatusInternalServerError)
-		return
-	}
-
-	w.WriteHeader(http.StatusNoContent)
-}
-
-func publishQuestion(w http.ResponseWriter, r *http.Request) {
-	log.Println("Publishing question...")
-	vars := mux.Vars(r)
-	id, err := strconv.Atoi(vars["id"])
-	if err != nil {
-		http.Error(w, "Invalid question ID", http.StatusBadRequest)
-		return
-	}
-
-	var publishReq QuestionPublishRequest
-
-	// Process form data using our utility function
-	formProcessor := func(r *http.Request) (interface{}, error) {
-		var formReq QuestionPublishRequest
-
-		publishedStr := r.FormValue("published")
-		formReq.Published = publishedStr == "true"
-
-		return formReq, nil
-	}
-
-	result, err := utils.ProcessRequestData(r, &publishReq, formProcessor)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	// If the result came from form processing, we need to update our publishReq
-	if formData, ok := result.(QuestionPublishRequest); ok {
-		publishReq = formData
-	}
-
-	userID, userExists := auth.UserIDFromContext(r.Context())
-	if !userExists {
-		log.Println("User ID not found in context")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	db := database.GetDB()
-	if db == nil {
-		log.Println("Database connection is nil")
-		http.Error(w, "Database connection error", http.StatusInternalServerError)
-		return
-	}
-
-	var user models.User
-	dbResult := db.First(&user, userID)
-	if dbResult.Error != nil {
-		log.Printf("Database error: %v", dbResult.Error)
-		http.Error(w, "Failed to retrieve user", http.StatusInternalServerError)
-		return
-	}
-
-	if user.Role != models.AdminRole {
-		http.Error(w, "Only administrators can publish or unpublish questions", http.StatusForbidden)
-		return
-	}
-
-	var question models.Question
-	dbResult = db.First(&question, id)
-	if dbResult.Error != nil {
-		if dbResult.Error == gorm.ErrRecordNotFound {
-			http.Error(w, "Question not found", http.StatusNotFound)
-		} else {
-			log.Printf("Database error: %v", dbResult.Error)
-			http.Error(w, "Failed to retrieve question", http.StatusInternalServerError)
-		}
-		return
-	}
-
-	if question.Published == publishReq.Published {
-		errorMsg := "Question is already in the requested publish state"
-		if utils.IsFormRequest(r) {
-			var state string
-			if publishReq.Published {
-				state = "published"
-			} else {
-				state = "unpublished"
-			}
-			http.Redirect(w, r, fmt.Sprintf("/questions/%d?error=already_%s", id, state), http.StatusSeeOther)
-			return
-		}
-		http.Error(w, errorMsg, http.StatusBadRequest)
-		return
-	}
-
-	question.Published = publishReq.Published
-	if publishReq.Published {
-		publishedByID := userID
-		question.PublishedBy = &publishedByID
-		now := time.Now()
-		question.PublishedAt = &now
-	} else {
-		question.PublishedBy = nil
-		question.PublishedAt = nil
-	}
-
-	dbResult = db.Save(&question)
-	if dbResult.Error != nil {
-		log.Printf("Database error: %v", dbResult.Error)
-		http.Error(w, "Failed to update question", http.StatusInternalServerError)
-		return
-	}
-
-	if utils.IsFormRequest(r) {
-		var successAction string
-		if publishReq.Published {
-			successAction = "published"
-		} else {
-			successAction = "unpublished"
-		}
-		http.Redirect(w, r, fmt.Sprintf("/question/%d?success=%s", id, successAction), http.StatusSeeOther)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(question); err != nil {
-		log.Printf("JSON encoding error: %v", err)
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-	}
-}
-
-func getTestCasesByQuestionID(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	questionID, err := strconv.Atoi(vars["id"])
-	if err != nil {
-		http.Error(w, "Invalid question ID", http.StatusBadRequest)
-		return
-	}
-
-	db := database.GetDB()
-	if db == nil {
-		log.Println("Database connection is nil")
-		http.Error(w, "Database connection error", http.StatusInternalServerError)
-		return
-	}
-
-	var testCases []models.TestCase
-	result := db.Where("question_id = ?", questionID).Find(&testCases)
-	if result.Error != nil {
-		log.Printf("Database error: %v", result.Error)
-		http.Error(w, "Failed to retrieve test cases", http.StatusInternalServerError)
-		return
-	}
-
-	if len(testCases) == 0 {
-		http.Error(w, "No test cases found for this question", http.StatusNotFound)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(testCases); err != nil {
-		log.Printf("JSON encoding error: %v", err)
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-	}
-}
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/cache"
+	"goera/serve/internal/config"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+	"goera/serve/internal/utils"
+	"goera/serve/internal/validation"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+type QuestionRequest struct {
+	Title           string            `json:"title" validate:"required,max=200"`
+	Content         string            `json:"content" validate:"required,max=100000"`
+	TimeLimit       int               `json:"time_limit_ms"`   // Checked by Validate, the ceiling is configurable
+	MemoryLimit     int               `json:"memory_limit_mb"` // Checked by Validate, the ceiling is configurable
+	SampleInputs    []string          `json:"sample_inputs"`
+	SampleOutputs   []string          `json:"sample_outputs"`
+	SampleFlags     []bool            `json:"sample_flags"` // Which test cases are shown as examples, by default the first
+	Tags            string            `json:"tags" validate:"max=500"`
+	Difficulty      models.Difficulty `json:"difficulty" validate:"omitempty,oneof=easy medium hard"` // Needed before publishing
+	Languages       string            `json:"allowed_languages" validate:"max=500"`                   // Comma separated, empty allows every supported language
+	BuildFlags      string            `json:"build_flags" validate:"max=500"`                         // Space separated go build flags, checked against the allowlist
+	MaxAttempts     int               `json:"max_attempts" validate:"min=0"`                          // Submissions per user, 0 for no limit
+	AttemptCooldown int               `json:"attempt_cooldown_seconds" validate:"min=0,max=86400"`    // Seconds between a user's submissions, 0 for none
+}
+
+// Bounds of a question's limits. The ceilings are config.MaxTimeLimitMs and
+// config.MaxMemoryLimitMB.
+const (
+	minTimeLimitMs   = 100
+	minMemoryLimitMB = 16
+)
+
+// isSample reports whether the request's i-th test case is shown as an example
+func (req QuestionRequest) isSample(i int) bool {
+	if len(req.SampleFlags) == 0 {
+		return i == 0
+	}
+	return i < len(req.SampleFlags) && req.SampleFlags[i]
+}
+
+// parseSampleFlags reads the example flags of the question forms, one per
+// test case alongside its input and output
+func parseSampleFlags(r *http.Request, req *QuestionRequest) error {
+	for _, value := range r.Form["sample_flags[]"] {
+		sample, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid example flag: %v", err)
+		}
+		req.SampleFlags = append(req.SampleFlags, sample)
+	}
+	return nil
+}
+
+type QuestionPublishRequest struct {
+	Published bool `json:"published"`
+}
+
+type PaginatedResponse struct {
+	Data       any   `json:"data"`
+	Page       int   `json:"page"`
+	PageSize   int   `json:"page_size"`
+	TotalItems int64 `json:"total_items"`
+	TotalPages int   `json:"total_pages"`
+	NextAfter  *uint `json:"next_after,omitempty"` // Cursor for the next page in cursor mode
+}
+
+type QuestionsByIdResponse struct {
+}
+
+func QuestionsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getQuestions(w, r)
+	case http.MethodPost:
+		createQuestion(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// QuestionHandler handles all requests to /api/questions/{id}
+func QuestionHandler(w http.ResponseWriter, r *http.Request) {
+	// Check for method override in form submissions
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err == nil {
+			if method := r.FormValue("_method"); method == "PUT" {
+				r.Method = http.MethodPut
+			} else if method == "DELETE" {
+				r.Method = http.MethodDelete
+			}
+		}
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		getQuestionByID(w, r)
+	case http.MethodPut:
+		updateQuestion(w, r)
+	case http.MethodDelete:
+		deleteQuestion(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func PublishQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPut, http.MethodPost:
+		publishQuestion(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func TestCaseHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getTestCasesByQuestionID(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// visibleQuestions narrows db to the questions listed to a user, or to an
+// anonymous practice session without one. The returned scope names that set
+// of questions, for cache keys.
+func visibleQuestions(db *gorm.DB, userID uint, userExists bool) (*gorm.DB, string, error) {
+	if !userExists {
+		// Anonymous practice sessions only see published questions
+		return db.Where("published = ? AND id NOT IN (?)", true, runningContestQuestions(db, time.Now())), "published", nil
+	}
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return nil, "", err
+	}
+	if user.Role == models.AdminRole {
+		return db, "all", nil
+	}
+	// Problems of running contests are left out, participants find them on
+	// the contest page. Authors and co-authors see their unpublished ones.
+	query := db.Where("(published = ? AND id NOT IN (?)) OR user_id = ? OR id IN (?)",
+		true, runningContestQuestions(db, time.Now()), userID, contributedQuestions(db, userID))
+	return query, fmt.Sprintf("user:%d", userID), nil
+}
+
+// questionListETag tags a page of the question list without reading it, so a
+// revalidation costs two aggregates instead of the list, its count and its
+// statistics. The page follows from the query string and the questions the
+// filtered query can show: their number, IDs and latest update change when a
+// question is added, edited, rated, deleted or hidden by a contest. Solved
+// statuses follow the user's own submissions. Acceptance rates are too costly
+// to check here, so the tag also moves on every config.CacheTTL and they lag
+// like they do in cached pages.
+func questionListETag(db, query *gorm.DB, rawQuery string, userID uint, userExists bool) (string, error) {
+	var total, ids, updated sql.NullString
+	row := query.Session(&gorm.Session{}).Model(&models.Question{}).
+		Select("COUNT(*), SUM(id), MAX(updated_at)").Row()
+	if err := row.Scan(&total, &ids, &updated); err != nil {
+		return "", err
+	}
+
+	var submissions, submitted sql.NullString
+	if userExists {
+		row := db.Model(&models.Submission{}).
+			Select("COUNT(*), MAX(updated_at)").Where("user_id = ?", userID).Row()
+		if err := row.Scan(&submissions, &submitted); err != nil {
+			return "", err
+		}
+	}
+
+	window := time.Now().UnixNano() / int64(config.CacheTTL)
+	return hashETag([]byte(fmt.Sprintf("%s|%d|%s|%s|%s|%s|%s|%d",
+		rawQuery, userID, total.String, ids.String, updated.String,
+		submissions.String, submitted.String, window))), nil
+}
+
+func getQuestions(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	_, isAnonymous := auth.AnonymousSessionFromContext(r.Context())
+	if !userExists && !isAnonymous {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	pagination := utils.ParsePagination(r, "questions")
+
+	query, scope, err := visibleQuestions(db, userID, userExists)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+		return
+	}
+
+	// Filtering by rating leaves out questions nobody has rated yet
+	if minStr := r.URL.Query().Get("minRating"); minStr != "" {
+		minRating, err := strconv.ParseFloat(minStr, 64)
+		if err != nil {
+			apierror.Write(w, r, "Invalid minRating", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("difficulty_votes > 0 AND difficulty_rating >= ?", minRating)
+	}
+	if maxStr := r.URL.Query().Get("maxRating"); maxStr != "" {
+		maxRating, err := strconv.ParseFloat(maxStr, 64)
+		if err != nil {
+			apierror.Write(w, r, "Invalid maxRating", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("difficulty_votes > 0 AND difficulty_rating <= ?", maxRating)
+	}
+	// The author's difficulty, one or several separated by commas
+	if value := r.URL.Query().Get("difficulty"); value != "" {
+		var difficulties []models.Difficulty
+		for _, name := range strings.Split(value, ",") {
+			difficulty := models.Difficulty(strings.ToLower(strings.TrimSpace(name)))
+			if !difficulty.IsValid() {
+				apierror.Write(w, r, "Invalid difficulty, use easy, medium or hard", http.StatusBadRequest)
+				return
+			}
+			difficulties = append(difficulties, difficulty)
+		}
+		query = query.Where("difficulty IN ?", difficulties)
+	}
+
+	// Unrated questions sort last in both directions
+	var order string
+	switch r.URL.Query().Get("sort") {
+	case "":
+	case "rating":
+		order = "CASE WHEN difficulty_votes = 0 THEN 1 ELSE 0 END, difficulty_rating ASC, id ASC"
+	case "-rating":
+		order = "CASE WHEN difficulty_votes = 0 THEN 1 ELSE 0 END, difficulty_rating DESC, id ASC"
+	default:
+		apierror.Write(w, r, "Invalid sort, use rating or -rating", http.StatusBadRequest)
+		return
+	}
+	if order != "" && pagination.Cursor {
+		apierror.Write(w, r, "Cursor paging with after only supports the default order", http.StatusBadRequest)
+		return
+	}
+
+	// Clients revalidating an unchanged page are answered before the list is
+	// read
+	etag, err := questionListETag(db, query, r.URL.Query().Encode(), userID, userExists)
+	if err != nil {
+		log.Printf("Database error tagging questions: %v", err)
+		apierror.Write(w, r, "Failed to retrieve questions", http.StatusInternalServerError)
+		return
+	}
+	if notModified(w, r, etag) {
+		return
+	}
+
+	// The list version is read before the database, so a page read while a
+	// question changes is cached under the old version and never served
+	cacheKey := fmt.Sprintf("questions:%s:%s:%s", cache.Version(r.Context(), questionListVersion), scope, r.URL.Query().Encode())
+	var page cachedQuestionPage
+	if !cache.GetJSON(r.Context(), cacheKey, &page) {
+		if err := query.Model(&models.Question{}).Count(&page.TotalItems).Error; err != nil {
+			log.Printf("Database error counting questions: %v", err)
+			apierror.Write(w, r, "Failed to count questions", http.StatusInternalServerError)
+			return
+		}
+
+		if pagination.Cursor {
+			// Cursor mode follows the IDs, so new questions do not shift the pages
+			if err := query.Where("id > ?", pagination.After).Order("id ASC").Limit(pagination.PageSize + 1).Find(&page.Questions).Error; err != nil {
+				log.Printf("Database error: %v", err)
+				apierror.Write(w, r, "Failed to retrieve questions", http.StatusInternalServerError)
+				return
+			}
+			if len(page.Questions) > pagination.PageSize {
+				page.Questions = page.Questions[:pagination.PageSize]
+				page.HasMore = true
+			}
+		} else {
+			if order != "" {
+				query = query.Order(order)
+			}
+			result := query.Limit(pagination.PageSize).Offset(pagination.Offset()).Find(&page.Questions)
+			if result.Error != nil {
+				log.Printf("Database error: %v", result.Error)
+				apierror.Write(w, r, "Failed to retrieve questions", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if err := loadAcceptanceStats(db, page.Questions); err != nil {
+			// Statistics are informational; list the questions without them
+			log.Printf("Database error loading question statistics: %v", err)
+		}
+		cache.SetJSON(r.Context(), cacheKey, page)
+	}
+
+	questions := page.Questions
+	response := PaginatedResponse{
+		PageSize:   pagination.PageSize,
+		TotalItems: page.TotalItems,
+	}
+	if pagination.Cursor {
+		if page.HasMore {
+			lastID := questions[len(questions)-1].ID
+			response.NextAfter = &lastID
+			utils.SetCursorLink(w, r, pagination, lastID, true)
+		}
+	} else {
+		response.Page = pagination.Page
+		response.TotalPages = utils.TotalPages(page.TotalItems, pagination.PageSize)
+		utils.SetPageLinks(w, r, pagination, response.TotalPages)
+	}
+
+	// Solved statuses differ per user, so they are never cached
+	if userExists {
+		if err := loadUserStatuses(db, questions, userID); err != nil {
+			log.Printf("Database error loading solved status: %v", err)
+		}
+	}
+
+	response.Data = questions
+
+	var lastModified time.Time
+	for _, question := range questions {
+		if question.UpdatedAt.After(lastModified) {
+			lastModified = question.UpdatedAt
+		}
+	}
+	writeJSONTagged(w, r, response, etag, lastModified)
+}
+
+func getQuestionByID(w http.ResponseWriter, r *http.Request) {
+	question, ok := viewableQuestion(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSONWithETag(w, r, question, question.UpdatedAt)
+}
+
+// viewableQuestion loads the question named in the path with its examples,
+// writing an error response when it is missing or the requester cannot view it
+func viewableQuestion(w http.ResponseWriter, r *http.Request) (*models.Question, bool) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid question ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	_, isAnonymous := auth.AnonymousSessionFromContext(r.Context())
+	if !userExists && !isAnonymous {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	var question models.Question
+	cacheKey := questionCacheKey(uint(id))
+	if !cache.GetJSON(r.Context(), cacheKey, &question) {
+		result := db.First(&question, id)
+		if result.Error != nil {
+			if result.Error == gorm.ErrRecordNotFound {
+				apierror.Write(w, r, "Question not found", http.StatusNotFound)
+			} else {
+				log.Printf("Database error: %v", result.Error)
+				apierror.Write(w, r, "Failed to retrieve question", http.StatusInternalServerError)
+			}
+			return nil, false
+		}
+		cache.SetJSON(r.Context(), cacheKey, question)
+	}
+
+	if question.Examples, err = questionExamples(r.Context(), db, question.ID); err != nil {
+		log.Printf("Failed to load examples of question %d: %v", question.ID, err)
+		apierror.Write(w, r, "Failed to retrieve question", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if !userExists {
+		inContest, _, err := contestQuestionAccess(db, question.ID, 0)
+		if err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve contest", http.StatusInternalServerError)
+			return nil, false
+		}
+		if !question.Published || inContest {
+			apierror.Write(w, r, "Unauthorized to view this question", http.StatusForbidden)
+			return nil, false
+		}
+		return &question, true
+	}
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	// Users can view questions if:
+	// 1. They are admin
+	// 2. They are the owner or a co-author of the question
+	// 3. The question is a problem of a running contest they registered for
+	// 4. The question is published and not a problem of a running contest
+	if !canEditQuestion(db, &question, &user) {
+		inContest, participant, err := contestQuestionAccess(db, question.ID, userID)
+		if err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve contest", http.StatusInternalServerError)
+			return nil, false
+		}
+		if inContest && !participant {
+			apierror.Write(w, r, "This question is only available to contest participants", http.StatusForbidden)
+			return nil, false
+		}
+		if !inContest && !question.Published {
+			apierror.Write(w, r, "Unauthorized to view this question", http.StatusForbidden)
+			return nil, false
+		}
+	}
+	return &question, true
+}
+
+// visibleToReader reports whether someone who cannot edit question may still
+// see it, following the rules of viewableQuestion: while it is a problem of a
+// running contest only the contest's participants may, and otherwise everyone
+// once it is published. A zero userID stands for an anonymous visitor.
+func visibleToReader(db *gorm.DB, question *models.Question, userID uint) (bool, error) {
+	inContest, participant, err := contestQuestionAccess(db, question.ID, userID)
+	if err != nil {
+		return false, err
+	}
+	if inContest {
+		return participant, nil
+	}
+	return question.Published, nil
+}
+
+// questionExamples returns the examples of a question from its sample test cases
+func questionExamples(ctx context.Context, db *gorm.DB, questionID uint) ([]models.Example, error) {
+	var samples []models.TestCase
+	if err := db.Where("question_id = ? AND sample = ?", questionID, true).Order("id").Find(&samples).Error; err != nil {
+		return nil, err
+	}
+	examples := make([]models.Example, len(samples))
+	for i := range samples {
+		if err := samples[i].LoadData(ctx); err != nil {
+			return nil, err
+		}
+		examples[i] = models.Example{Input: samples[i].Input, Output: samples[i].ExpectedOutput}
+	}
+	return examples, nil
+}
+
+// Validate checks the requested limits and test cases against the
+// deployment-wide ceilings in config
+func (req *QuestionRequest) Validate(errs *validation.Errors) {
+	errs.Range("time_limit_ms", req.TimeLimit, minTimeLimitMs, config.MaxTimeLimitMs)
+	errs.Range("memory_limit_mb", req.MemoryLimit, minMemoryLimitMB, config.MaxMemoryLimitMB)
+	if len(req.SampleInputs) > config.MaxTestCasesPerQuestion {
+		errs.Add("sample_inputs", "max", "%s must have at most %d items", config.MaxTestCasesPerQuestion)
+	}
+	if len(req.SampleOutputs) != len(req.SampleInputs) {
+		errs.Add("sample_outputs", "match", "%s must have one output per input")
+	}
+	if len(req.SampleFlags) > 0 && len(req.SampleFlags) != len(req.SampleInputs) {
+		errs.Add("sample_flags", "match", "%s must have one flag per test case")
+	}
+}
+
+// normalizeLanguages cleans up a comma separated list of allowed languages and
+// checks that the judge supports each of them
+func normalizeLanguages(value string) (string, error) {
+	var languages []string
+	seen := map[string]bool{}
+	for _, language := range strings.Split(value, ",") {
+		language = strings.ToLower(strings.TrimSpace(language))
+		if language == "" || seen[language] {
+			continue
+		}
+		if !slices.Contains(config.SupportedLanguages, language) {
+			return "", fmt.Errorf("unsupported language %q, supported languages are %s", language, strings.Join(config.SupportedLanguages, ", "))
+		}
+		seen[language] = true
+		languages = append(languages, language)
+	}
+	return strings.Join(languages, ","), nil
+}
+
+// validateTestCaseSizes checks each test case and their total against the
+// configured size limits
+func validateTestCaseSizes(req QuestionRequest) error {
+	total := 0
+	for i := range req.SampleInputs {
+		size := len(req.SampleInputs[i])
+		if i < len(req.SampleOutputs) {
+			size += len(req.SampleOutputs[i])
+		}
+		if size > config.MaxTestCaseSizeBytes {
+			return fmt.Errorf("test case %d exceeds the maximum size of %d bytes", i+1, config.MaxTestCaseSizeBytes)
+		}
+		total += size
+	}
+	if total > config.MaxTotalTestCaseBytes {
+		return fmt.Errorf("test cases exceed the maximum total size of %d bytes per question", config.MaxTotalTestCaseBytes)
+	}
+	return nil
+}
+
+func createQuestion(w http.ResponseWriter, r *http.Request) {
+	var questionReq QuestionRequest
+
+	// Process form data using our utility function
+	formProcessor := func(r *http.Request) (interface{}, error) {
+		var formReq QuestionRequest
+
+		formReq.Title = r.FormValue("title")
+		formReq.Content = r.FormValue("content")
+
+		// Parse time limit
+		if timeLimitStr := r.FormValue("time_limit_ms"); timeLimitStr != "" {
+			timeLimit, err := strconv.Atoi(timeLimitStr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid time limit: %v", err)
+			}
+			formReq.TimeLimit = timeLimit
+		}
+
+		// Parse memory limit
+		if memoryLimitStr := r.FormValue("memory_limit_mb"); memoryLimitStr != "" {
+			memoryLimit, err := strconv.Atoi(memoryLimitStr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid memory limit: %v", err)
+			}
+			formReq.MemoryLimit = memoryLimit
+		}
+
+		// Get sample inputs and outputs
+		formReq.SampleInputs = r.Form["sample_inputs[]"]
+		formReq.SampleOutputs = r.Form["sample_outputs[]"]
+		if err := parseSampleFlags(r, &formReq); err != nil {
+			return nil, err
+		}
+
+		// Get tags
+		formReq.Tags = r.FormValue("tags")
+		formReq.Difficulty = models.Difficulty(r.FormValue("difficulty"))
+		formReq.Languages = r.FormValue("allowed_languages")
+		formReq.BuildFlags = r.FormValue("build_flags")
+		if err := parseAttemptLimits(r, &formReq); err != nil {
+			return nil, err
+		}
+
+		log.Println("Form data processed successfully:", formReq.Title)
+
+		return formReq, nil
+	}
+
+	result, err := utils.ProcessRequestData(r, &questionReq, formProcessor)
+	if err != nil {
+		writeRequestDataError(w, r, err)
+		return
+	}
+
+	// If the result came from form processing, we need to update our questionReq
+	if formData, ok := result.(QuestionRequest); ok {
+		questionReq = formData
+	}
+
+	if !validRequest(w, r, &questionReq) {
+		return
+	}
+
+	if questionReq.Languages, err = normalizeLanguages(questionReq.Languages); err != nil {
+		apierror.Write(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if questionReq.BuildFlags, err = normalizeBuildFlags(questionReq.BuildFlags); err != nil {
+		apierror.Write(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateTestCaseSizes(questionReq); err != nil {
+		apierror.Write(w, r, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	question := models.Question{
+		Title:            questionReq.Title,
+		Content:          questionReq.Content,
+		UserID:           userID,
+		Published:        false,
+		TimeLimit:        questionReq.TimeLimit,
+		MemoryLimit:      questionReq.MemoryLimit,
+		Tags:             questionReq.Tags,
+		Difficulty:       questionReq.Difficulty,
+		AllowedLanguages: questionReq.Languages,
+		BuildFlags:       questionReq.BuildFlags,
+		MaxAttempts:      questionReq.MaxAttempts,
+		AttemptCooldown:  questionReq.AttemptCooldown,
+	}
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	dbResult := db.Create(&question)
+	if dbResult.Error != nil {
+		log.Printf("Database error: %v", dbResult.Error)
+		apierror.Write(w, r, "Failed to create question", http.StatusInternalServerError)
+		return
+	}
+	invalidateQuestion(r.Context(), question.ID)
+
+	var testCases []models.TestCase
+	for i := range questionReq.SampleInputs {
+		if i < len(questionReq.SampleOutputs) {
+			testCase := models.TestCase{
+				QuestionID:     question.ID,
+				Input:          questionReq.SampleInputs[i],
+				ExpectedOutput: questionReq.SampleOutputs[i],
+				Sample:         questionReq.isSample(i),
+			}
+			testCases = append(testCases, testCase)
+		}
+	}
+
+	if len(testCases) > 0 {
+		if err := db.Create(&testCases).Error; err != nil {
+			log.Printf("Failed to create test cases: %v", err)
+			apierror.Write(w, r, "Failed to create test cases", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := recordQuestionRevision(db, &question, testCases, question.UserID, ""); err != nil {
+		log.Printf("Failed to record question revision: %v", err)
+	}
+
+	log.Printf("Question created successfully with ID: %d", question.ID)
+
+	// Based on content type, return appropriate response
+	if utils.IsJSONRequest(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(question); err != nil {
+			log.Printf("JSON encoding error: %v", err)
+			apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+		}
+	} else {
+		http.Redirect(w, r, fmt.Sprintf("/question/%d", question.ID), http.StatusSeeOther)
+	}
+}
+
+func updateQuestion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid question ID", http.StatusBadRequest)
+		return
+	}
+
+	var questionReq QuestionRequest
+
+	formProcessor := func(r *http.Request) (any, error) {
+		var formReq QuestionRequest
+
+		formReq.Title = r.FormValue("title")
+		formReq.Content = r.FormValue("content")
+
+		// Parse time limit
+		if timeLimitStr := r.FormValue("time_limit_ms"); timeLimitStr != "" {
+			timeLimit, err := strconv.Atoi(timeLimitStr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid time limit: %v", err)
+			}
+			formReq.TimeLimit = timeLimit
+		}
+
+		// Parse memory limit
+		if memoryLimitStr := r.FormValue("memory_limit_mb"); memoryLimitStr != "" {
+			memoryLimit, err := strconv.Atoi(memoryLimitStr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid memory limit: %v", err)
+			}
+			formReq.MemoryLimit = memoryLimit
+		}
+
+		// Collect sample inputs and outputs
+		formReq.SampleInputs = r.Form["sample_inputs[]"]
+		formReq.SampleOutputs = r.Form["sample_outputs[]"]
+		if err := parseSampleFlags(r, &formReq); err != nil {
+			return nil, err
+		}
+
+		formReq.Tags = r.FormValue("tags")
+		formReq.Difficulty = models.Difficulty(r.FormValue("difficulty"))
+		formReq.Languages = r.FormValue("allowed_languages")
+		formReq.BuildFlags = r.FormValue("build_flags")
+		if err := parseAttemptLimits(r, &formReq); err != nil {
+			return nil, err
+		}
+
+		return formReq, nil
+	}
+
+	result, err := utils.ProcessRequestData(r, &questionReq, formProcessor)
+	if err != nil {
+		writeRequestDataError(w, r, err)
+		return
+	}
+
+	if formData, ok := result.(QuestionRequest); ok {
+		questionReq = formData
+	}
+
+	if !validRequest(w, r, &questionReq) {
+		return
+	}
+
+	if questionReq.Languages, err = normalizeLanguages(questionReq.Languages); err != nil {
+		apierror.Write(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if questionReq.BuildFlags, err = normalizeBuildFlags(questionReq.BuildFlags); err != nil {
+		apierror.Write(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateTestCaseSizes(questionReq); err != nil {
+		apierror.Write(w, r, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	// Start a transaction
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var question models.Question
+	if err := tx.First(&question, id).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Question not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve question", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	var user models.User
+	if err := tx.First(&user, userID).Error; err != nil {
+		tx.Rollback()
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+		return
+	}
+
+	// Check permissions
+	if !canEditQuestion(tx, &question, &user) {
+		tx.Rollback()
+		if utils.IsFormRequest(r) {
+			http.Redirect(w, r, fmt.Sprintf("/question/%d", question.ID), http.StatusSeeOther)
+			return
+		}
+		apierror.Write(w, r, "Unauthorized to edit this question", http.StatusForbidden)
+		return
+	}
+
+	// Admins can publish or unpublish while editing. The change goes through
+	// the review workflow like a status change, and publishing runs the
+	// checklist on the new test cases, limits and difficulty before anything
+	// is written.
+	var newStatus models.QuestionStatus
+	var transition questionTransition
+	if publishedStr := r.FormValue("published"); publishedStr != "" && user.Role == models.AdminRole {
+		published, err := strconv.ParseBool(publishedStr)
+		if err != nil {
+			tx.Rollback()
+			apierror.Write(w, r, "Invalid published value", http.StatusBadRequest)
+			return
+		}
+		if published != question.Published {
+			newStatus = models.QuestionStatusDraft
+			if published {
+				newStatus = models.QuestionStatusPublished
+			}
+			var ok bool
+			if transition, ok = findQuestionTransition(question.Status, newStatus); !ok {
+				tx.Rollback()
+				apierror.Write(w, r, fmt.Sprintf("A question cannot go from %s to %s", question.Status, newStatus), http.StatusConflict)
+				return
+			}
+		}
+		if newStatus == models.QuestionStatusPublished {
+			testCases := make([]models.TestCase, len(questionReq.SampleInputs))
+			for i := range questionReq.SampleInputs {
+				testCases[i] = models.TestCase{Input: questionReq.SampleInputs[i], ExpectedOutput: questionReq.SampleOutputs[i], Sample: questionReq.isSample(i)}
+			}
+			edited := question
+			edited.TimeLimit = questionReq.TimeLimit
+			edited.MemoryLimit = questionReq.MemoryLimit
+			edited.Difficulty = questionReq.Difficulty
+			edited.BuildFlags = questionReq.BuildFlags
+			if !checkPublishable(w, r, &edited, testCases) {
+				tx.Rollback()
+				return
+			}
+		}
+	}
+
+	// Snapshot questions that predate revision history before overwriting them
+	if err := ensureBaseRevision(tx, &question); err != nil {
+		tx.Rollback()
+		log.Printf("Failed to record base revision: %v", err)
+		apierror.Write(w, r, "Failed to update question", http.StatusInternalServerError)
+		return
+	}
+
+	// Update question fields
+	question.Title = questionReq.Title
+	question.Content = questionReq.Content
+	question.TimeLimit = questionReq.TimeLimit
+	question.MemoryLimit = questionReq.MemoryLimit
+	question.Tags = questionReq.Tags
+	question.Difficulty = questionReq.Difficulty
+	question.AllowedLanguages = questionReq.Languages
+	question.BuildFlags = questionReq.BuildFlags
+	question.MaxAttempts = questionReq.MaxAttempts
+	question.AttemptCooldown = questionReq.AttemptCooldown
+
+	// Save the question
+	if err := tx.Save(&question).Error; err != nil {
+		tx.Rollback()
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to update question", http.StatusInternalServerError)
+		return
+	}
+
+	// Delete existing test cases
+	if err := tx.Where("question_id = ?", question.ID).Delete(&models.TestCase{}).Error; err != nil {
+		tx.Rollback()
+		log.Printf("Failed to delete test cases: %v", err)
+		apierror.Write(w, r, "Failed to update test cases", http.StatusInternalServerError)
+		return
+	}
+
+	// Create new test cases
+	var testCases []models.TestCase
+	for i := range questionReq.SampleInputs {
+		testCase := models.TestCase{
+			QuestionID:     question.ID,
+			Input:          questionReq.SampleInputs[i],
+			ExpectedOutput: questionReq.SampleOutputs[i],
+			Sample:         questionReq.isSample(i),
+		}
+		testCases = append(testCases, testCase)
+	}
+
+	if len(testCases) > 0 {
+		if err := tx.Create(&testCases).Error; err != nil {
+			tx.Rollback()
+			log.Printf("Failed to create test cases: %v", err)
+			apierror.Write(w, r, "Failed to create test cases", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := recordQuestionRevision(tx, &question, testCases, userID, ""); err != nil {
+		tx.Rollback()
+		log.Printf("Failed to record question revision: %v", err)
+		apierror.Write(w, r, "Failed to update question", http.StatusInternalServerError)
+		return
+	}
+
+	// The autosaved draft is now part of the question
+	if err := deleteDraft(tx, question.ID, userID); err != nil {
+		tx.Rollback()
+		log.Printf("Failed to delete question draft: %v", err)
+		apierror.Write(w, r, "Failed to update question", http.StatusInternalServerError)
+		return
+	}
+
+	// The review is recorded against the revision of this edit
+	from := question.Status
+	if newStatus != "" {
+		if err := saveQuestionStatus(tx, &question, &user, newStatus, ""); err != nil {
+			tx.Rollback()
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to update question", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		log.Printf("Failed to commit transaction: %v", err)
+		apierror.Write(w, r, "Failed to update question", http.StatusInternalServerError)
+		return
+	}
+	invalidateQuestion(r.Context(), question.ID)
+	if newStatus != "" {
+		questionStatusChanged(r.Context(), db, &question, &user, from, transition, "")
+	}
+
+	if utils.IsFormRequest(r) {
+		http.Redirect(w, r, fmt.Sprintf("/question/%d", question.ID), http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(question); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+func deleteQuestion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid question ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	var question models.Question
+	result := db.First(&question, id)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			apierror.Write(w, r, "Question not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", result.Error)
+			apierror.Write(w, r, "Failed to retrieve question", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	var user models.User
+	result = db.First(&user, userID)
+	if result.Error != nil {
+		log.Printf("Database error: %v", result.Error)
+		apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+		return
+	}
+
+	if !canEditQuestion(db, &question, &user) {
+		apierror.Write(w, r, "Unauthorized to delete this question", http.StatusForbidden)
+		return
+	}
+
+	if err := softDeleteQuestion(db, &question); err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to delete question", http.StatusInternalServerError)
+		return
+	}
+	invalidateQuestion(r.Context(), question.ID)
+	audit(db, userID, models.AuditQuestionDeleted, "question", question.ID, question.Title)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func publishQuestion(w http.ResponseWriter, r *http.Request) {
+	log.Println("Publishing question...")
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid question ID", http.StatusBadRequest)
+		return
+	}
+
+	var publishReq QuestionPublishRequest
+
+	// Process form data using our utility function
+	formProcessor := func(r *http.Request) (interface{}, error) {
+		var formReq QuestionPublishRequest
+
+		publishedStr := r.FormValue("published")
+		formReq.Published = publishedStr == "true"
+
+		return formReq, nil
+	}
+
+	result, err := utils.ProcessRequestData(r, &publishReq, formProcessor)
+	if err != nil {
+		writeRequestDataError(w, r, err)
+		return
+	}
+
+	// If the result came from form processing, we need to update our publishReq
+	if formData, ok := result.(QuestionPublishRequest); ok {
+		publishReq = formData
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	var user models.User
+	dbResult := db.First(&user, userID)
+	if dbResult.Error != nil {
+		log.Printf("Database error: %v", dbResult.Error)
+		apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+		return
+	}
+
+	if user.Role != models.AdminRole {
+		apierror.Write(w, r, "Only administrators can publish or unpublish questions", http.StatusForbidden)
+		return
+	}
+
+	var question models.Question
+	dbResult = db.First(&question, id)
+	if dbResult.Error != nil {
+		if dbResult.Error == gorm.ErrRecordNotFound {
+			apierror.Write(w, r, "Question not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", dbResult.Error)
+			apierror.Write(w, r, "Failed to retrieve question", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if question.Published == publishReq.Published {
+		errorMsg := "Question is already in the requested publish state"
+		if utils.IsFormRequest(r) {
+			var state string
+			if publishReq.Published {
+				state = "published"
+			} else {
+				state = "unpublished"
+			}
+			http.Redirect(w, r, fmt.Sprintf("/questions/%d?error=already_%s", id, state), http.StatusSeeOther)
+			return
+		}
+		apierror.Write(w, r, errorMsg, http.StatusBadRequest)
+		return
+	}
+
+	// Publishing skips the review workflow; unpublishing sends the question
+	// back to draft
+	status := models.QuestionStatusDraft
+	if publishReq.Published {
+		status = models.QuestionStatusPublished
+	}
+	if !changeQuestionStatus(w, r, db, &question, &user, status, "") {
+		return
+	}
+
+	if utils.IsFormRequest(r) {
+		var successAction string
+		if publishReq.Published {
+			successAction = "published"
+		} else {
+			successAction = "unpublished"
+		}
+		http.Redirect(w, r, fmt.Sprintf("/question/%d?success=%s", id, successAction), http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(question); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+func getTestCasesByQuestionID(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	questionID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid question ID", http.StatusBadRequest)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	var question models.Question
+	if err := db.First(&question, questionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Question not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve question", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Admins and the authors get every test case; others, including anonymous
+	// practice sessions, only the samples of questions they can see
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	canManage := false
+	if userExists {
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+			return
+		}
+		canManage = canEditQuestion(db, &question, &user)
+	}
+	if !canManage {
+		visible, err := visibleToReader(db, &question, userID)
+		if err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve contest", http.StatusInternalServerError)
+			return
+		}
+		if !visible {
+			apierror.Write(w, r, "Question not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	var testCases []models.TestCase
+	result := db.Where("question_id = ?", questionID).Order("id").Find(&testCases)
+	if result.Error != nil {
+		log.Printf("Database error: %v", result.Error)
+		apierror.Write(w, r, "Failed to retrieve test cases", http.StatusInternalServerError)
+		return
+	}
+	if !canManage {
+		testCases = sampleTestCases(testCases)
+	}
+
+	if len(testCases) == 0 {
+		apierror.Write(w, r, "No test cases found for this question", http.StatusNotFound)
+		return
+	}
+	for i := range testCases {
+		if err := testCases[i].LoadData(r.Context()); err != nil {
+			log.Printf("Storage error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve test cases", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(testCases); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,324 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+	"goera/serve/internal/validation"
+
+	"gorm.io/gorm"
+)
+
+// Actions of a bulk request
+const (
+	bulkPublish   = "publish"
+	bulkUnpublish = "unpublish"
+	bulkDelete    = "delete"
+	bulkTag       = "tag"
+)
+
+// Outcomes of a question in a bulk request
+const (
+	bulkUpdated   = "updated"
+	bulkUnchanged = "unchanged" // Already as requested
+	bulkFailed    = "failed"
+)
+
+// QuestionBulkRequest applies one action to many questions. Tags, comma
+// separated, are added by the tag action. With Atomic a failure on any
+// question leaves all of them unchanged; otherwise the others are changed.
+type QuestionBulkRequest struct {
+	Action string `json:"action" validate:"required,oneof=publish unpublish delete tag"`
+	IDs    []uint `json:"ids" validate:"required,max=500"`
+	Tags   string `json:"tags" validate:"max=500"`
+	Atomic bool   `json:"atomic"`
+}
+
+// Validate checks that the tag action has tags to add
+func (req *QuestionBulkRequest) Validate(errs *validation.Errors) {
+	if req.Action == bulkTag && len(splitTags(req.Tags)) == 0 {
+		errs.Add("tags", "required", "%s is required to tag questions")
+	}
+}
+
+// QuestionBulkResult is the outcome for one question of a bulk request
+type QuestionBulkResult struct {
+	ID     uint   `json:"id"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// QuestionBulkResponse reports a bulk request question by question
+type QuestionBulkResponse struct {
+	Action    string               `json:"action"`
+	Updated   int                  `json:"updated"`
+	Unchanged int                  `json:"unchanged"`
+	Failed    int                  `json:"failed"`
+	Results   []QuestionBulkResult `json:"results"`
+}
+
+// QuestionBulkHandler handles requests to /api/questions/bulk
+func QuestionBulkHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		bulkUpdateQuestions(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// bulkUpdateQuestions publishes, unpublishes, deletes or tags a list of
+// questions for administrators managing a large archive. Every question is
+// checked first, publishing ones running the publishing checklist, and the
+// changes are then made in one transaction, so a database error changes
+// nothing. Audit entries, notifications and cache invalidation follow the
+// commit as they do for single changes.
+func bulkUpdateQuestions(w http.ResponseWriter, r *http.Request) {
+	var req QuestionBulkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+	if !validRequest(w, r, &req) {
+		return
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+		return
+	}
+	if user.Role != models.AdminRole {
+		apierror.Write(w, r, "Only administrators can change questions in bulk", http.StatusForbidden)
+		return
+	}
+
+	var questions []models.Question
+	if err := db.Where("id IN ?", req.IDs).Find(&questions).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve questions", http.StatusInternalServerError)
+		return
+	}
+	byID := make(map[uint]*models.Question, len(questions))
+	for i := range questions {
+		byID[questions[i].ID] = &questions[i]
+	}
+
+	// Check every question before changing any
+	response := QuestionBulkResponse{Action: req.Action, Results: make([]QuestionBulkResult, 0, len(req.IDs))}
+	var pending []*models.Question
+	seen := map[uint]bool{}
+	for _, id := range req.IDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		result := QuestionBulkResult{ID: id, Status: bulkUpdated}
+		question, found := byID[id]
+		if !found {
+			result.Status, result.Error = bulkFailed, "Question not found"
+		} else if changed, err := checkBulkAction(db, question, req); err != nil {
+			result.Status, result.Error = bulkFailed, err.Error()
+		} else if !changed {
+			result.Status = bulkUnchanged
+		} else {
+			pending = append(pending, question)
+		}
+		response.Results = append(response.Results, result)
+	}
+	for _, result := range response.Results {
+		switch result.Status {
+		case bulkUpdated:
+			response.Updated++
+		case bulkUnchanged:
+			response.Unchanged++
+		case bulkFailed:
+			response.Failed++
+		}
+	}
+
+	if req.Atomic && response.Failed > 0 {
+		var failed []QuestionBulkResult
+		for _, result := range response.Results {
+			if result.Status == bulkFailed {
+				failed = append(failed, result)
+			}
+		}
+		apierror.WriteDetails(w, r, "No question was changed", http.StatusUnprocessableEntity, failed)
+		return
+	}
+
+	// Transitions are looked up before the statuses change
+	from := make(map[uint]models.QuestionStatus, len(pending))
+	for _, question := range pending {
+		from[question.ID] = question.Status
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, question := range pending {
+			if err := applyBulkAction(tx, question, &user, req); err != nil {
+				return fmt.Errorf("question %d: %w", question.ID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to update questions", http.StatusInternalServerError)
+		return
+	}
+
+	for _, question := range pending {
+		switch req.Action {
+		case bulkPublish, bulkUnpublish:
+			transition, _ := findQuestionTransition(from[question.ID], question.Status)
+			questionStatusChanged(r.Context(), db, question, &user, from[question.ID], transition, "")
+		case bulkDelete:
+			invalidateQuestion(r.Context(), question.ID)
+			audit(db, user.ID, models.AuditQuestionDeleted, "question", question.ID, question.Title)
+		case bulkTag:
+			invalidateQuestion(r.Context(), question.ID)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// checkBulkAction reports whether the action of req would change question,
+// or why it cannot be applied to it
+func checkBulkAction(db *gorm.DB, question *models.Question, req QuestionBulkRequest) (bool, error) {
+	switch req.Action {
+	case bulkPublish, bulkUnpublish:
+		status := models.QuestionStatusDraft
+		if req.Action == bulkPublish {
+			status = models.QuestionStatusPublished
+		}
+		if question.Published == (status == models.QuestionStatusPublished) {
+			return false, nil
+		}
+		if _, ok := findQuestionTransition(question.Status, status); !ok {
+			return false, fmt.Errorf("A question cannot go from %s to %s", question.Status, status)
+		}
+		if status == models.QuestionStatusPublished {
+			return true, checkBulkPublishable(db, question)
+		}
+		return true, nil
+	case bulkTag:
+		return mergeTags(question.Tags, req.Tags) != question.Tags, nil
+	}
+	return true, nil
+}
+
+// checkBulkPublishable runs the publishing checklist of question, returning
+// why it cannot be published
+func checkBulkPublishable(db *gorm.DB, question *models.Question) error {
+	var testCases []models.TestCase
+	if err := db.Where("question_id = ?", question.ID).Order("id").Find(&testCases).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		return errors.New("Failed to retrieve test cases")
+	}
+	checks, err := publishChecklist(question, testCases, true)
+	if err != nil {
+		var failure *referenceSolutionError
+		if errors.As(err, &failure) {
+			return failure
+		}
+		log.Printf("Failed to check reference solution of question %d: %v", question.ID, err)
+		return errors.New("Judge service could not check the reference solution")
+	}
+	failed := failedPublishChecks(checks)
+	if len(failed) == 0 {
+		return nil
+	}
+	names := make([]string, len(failed))
+	for i, check := range failed {
+		names[i] = check.Name
+	}
+	return fmt.Errorf("Question is not ready to be published: %s", strings.Join(names, ", "))
+}
+
+// applyBulkAction makes the change checked by checkBulkAction in tx
+func applyBulkAction(tx *gorm.DB, question *models.Question, user *models.User, req QuestionBulkRequest) error {
+	switch req.Action {
+	case bulkPublish:
+		return saveQuestionStatus(tx, question, user, models.QuestionStatusPublished, "")
+	case bulkUnpublish:
+		return saveQuestionStatus(tx, question, user, models.QuestionStatusDraft, "")
+	case bulkDelete:
+		return softDeleteQuestion(tx, question)
+	case bulkTag:
+		if err := ensureBaseRevision(tx, question); err != nil {
+			return err
+		}
+		question.Tags = mergeTags(question.Tags, req.Tags)
+		if err := tx.Model(question).Update("tags", question.Tags).Error; err != nil {
+			return err
+		}
+		var testCases []models.TestCase
+		if err := tx.Where("question_id = ?", question.ID).Order("id").Find(&testCases).Error; err != nil {
+			return err
+		}
+		return recordQuestionRevision(tx, question, testCases, user.ID, "Tagged in bulk")
+	}
+	return nil
+}
+
+// splitTags splits a comma separated tag list, dropping empty tags
+func splitTags(tags string) []string {
+	var split []string
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			split = append(split, tag)
+		}
+	}
+	return split
+}
+
+// mergeTags adds the tags in added missing from tags, ignoring case, keeping
+// tags unchanged when there are none
+func mergeTags(tags, added string) string {
+	merged := splitTags(tags)
+	have := map[string]bool{}
+	for _, tag := range merged {
+		have[strings.ToLower(tag)] = true
+	}
+	changed := false
+	for _, tag := range splitTags(added) {
+		if !have[strings.ToLower(tag)] {
+			have[strings.ToLower(tag)] = true
+			merged = append(merged, tag)
+			changed = true
+		}
+	}
+	if !changed {
+		return tags
+	}
+	return strings.Join(merged, ", ")
+}
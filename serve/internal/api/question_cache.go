@@ -0,0 +1,32 @@
+package api
+
+import (
+	"context"
+	"fmt"
+
+	"goera/serve/internal/cache"
+	"goera/serve/internal/models"
+)
+
+// questionListVersion is the cache version of every question list page
+const questionListVersion = "questions"
+
+// cachedQuestionPage is a page of the question list as it is cached, before
+// the per-user solved statuses are added
+type cachedQuestionPage struct {
+	Questions  []models.Question `json:"questions"`
+	TotalItems int64             `json:"total_items"`
+	HasMore    bool              `json:"has_more"` // In cursor mode, whether another page follows
+}
+
+// questionCacheKey is the cache key of the question with id
+func questionCacheKey(id uint) string {
+	return fmt.Sprintf("question:%d", id)
+}
+
+// invalidateQuestion drops a changed question and every question list page
+// from the cache
+func invalidateQuestion(ctx context.Context, id uint) {
+	cache.Delete(ctx, questionCacheKey(id))
+	cache.Bump(ctx, questionListVersion)
+}
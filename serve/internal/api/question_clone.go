@@ -0,0 +1,142 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+	"goera/serve/internal/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// CloneQuestionHandler handles requests to /api/questions/{id}/clone
+func CloneQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		cloneQuestion(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// cloneQuestion copies a question's statement, limits, tags and test cases into
+// a new unpublished question owned by the caller. Only admins and the author may
+// clone, since the copy exposes the hidden test cases.
+func cloneQuestion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid question ID", http.StatusBadRequest)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var original models.Question
+	if err := db.Preload("TestCases").First(&original, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Question not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve question", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+		return
+	}
+
+	if !canEditQuestion(db, &original, &user) {
+		apierror.Write(w, r, "Unauthorized to clone this question", http.StatusForbidden)
+		return
+	}
+
+	clone := models.Question{
+		Title:             original.Title + " (copy)",
+		Content:           original.Content,
+		Published:         false,
+		UserID:            userID,
+		Difficulty:        original.Difficulty,
+		Tags:              original.Tags,
+		TimeLimit:         original.TimeLimit,
+		MemoryLimit:       original.MemoryLimit,
+		AllowedLanguages:  original.AllowedLanguages,
+		BuildFlags:        original.BuildFlags,
+		MaxAttempts:       original.MaxAttempts,
+		AttemptCooldown:   original.AttemptCooldown,
+		ReferenceSolution: original.ReferenceSolution,
+	}
+
+	testCases := make([]models.TestCase, len(original.TestCases))
+	for i, tc := range original.TestCases {
+		testCases[i] = models.TestCase{
+			Input:          tc.Input,
+			ExpectedOutput: tc.ExpectedOutput,
+			Sample:         tc.Sample,
+			InputRef:       tc.InputRef,
+			OutputRef:      tc.OutputRef,
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&clone).Error; err != nil {
+			return err
+		}
+		for i := range testCases {
+			testCases[i].QuestionID = clone.ID
+		}
+		if len(testCases) > 0 {
+			if err := tx.Create(&testCases).Error; err != nil {
+				return err
+			}
+		}
+		return recordQuestionRevision(tx, &clone, testCases, userID, fmt.Sprintf("Cloned from question %d", original.ID))
+	})
+	if err != nil {
+		log.Printf("Failed to clone question %d: %v", original.ID, err)
+		apierror.Write(w, r, "Failed to clone question", http.StatusInternalServerError)
+		return
+	}
+
+	invalidateQuestion(r.Context(), clone.ID)
+	log.Printf("Question %d cloned into %d by user %d", original.ID, clone.ID, userID)
+
+	if utils.IsFormRequest(r) {
+		http.Redirect(w, r, fmt.Sprintf("/edit/%d", clone.ID), http.StatusSeeOther)
+		return
+	}
+
+	clone.TestCases = testCases
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(clone); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
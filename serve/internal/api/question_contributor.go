@@ -0,0 +1,376 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+	"goera/serve/internal/validation"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// QuestionUserRequest names the user a question is shared with or handed
+// over to, by ID or by username
+type QuestionUserRequest struct {
+	UserID   uint   `json:"userId"`
+	Username string `json:"username" validate:"max=64"`
+	// KeepAsContributor makes the previous owner of a transferred question one
+	// of its co-authors
+	KeepAsContributor bool `json:"keepAsContributor"`
+}
+
+// Validate checks that the request names a user
+func (req *QuestionUserRequest) Validate(errs *validation.Errors) {
+	if req.UserID == 0 && strings.TrimSpace(req.Username) == "" {
+		errs.Add("userId", "required", "%s or %s is required", "username")
+	}
+}
+
+// QuestionContributorsResponse lists who can edit a question besides admins
+type QuestionContributorsResponse struct {
+	OwnerID       uint                         `json:"ownerId"`
+	OwnerUsername string                       `json:"ownerUsername"`
+	Contributors  []models.QuestionContributor `json:"contributors"`
+}
+
+// QuestionContributorsHandler handles requests to /api/questions/{id}/contributors
+func QuestionContributorsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getQuestionContributors(w, r)
+	case http.MethodPost:
+		addQuestionContributor(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// QuestionContributorHandler handles requests to /api/questions/{id}/contributors/{userId}
+func QuestionContributorHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodDelete:
+		removeQuestionContributor(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// TransferQuestionHandler handles requests to /api/questions/{id}/transfer
+func TransferQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		transferQuestion(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// isContributor reports whether userID is a co-author of a question
+func isContributor(db *gorm.DB, questionID, userID uint) (bool, error) {
+	var count int64
+	err := db.Model(&models.QuestionContributor{}).
+		Where("question_id = ? AND user_id = ?", questionID, userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// canEditQuestion reports whether user may edit a question: admins, its owner
+// and its co-authors can. A failed lookup is logged and denies access.
+func canEditQuestion(db *gorm.DB, question *models.Question, user *models.User) bool {
+	if user.Role == models.AdminRole || question.UserID == user.ID {
+		return true
+	}
+	contributor, err := isContributor(db, question.ID, user.ID)
+	if err != nil {
+		log.Printf("Database error checking the co-authors of question %d: %v", question.ID, err)
+		return false
+	}
+	return contributor
+}
+
+// contributedQuestions selects the IDs of the questions userID co-authors
+func contributedQuestions(db *gorm.DB, userID uint) *gorm.DB {
+	return db.Model(&models.QuestionContributor{}).Select("question_id").Where("user_id = ?", userID)
+}
+
+// loadQuestionForSharing loads the question of the request along with the
+// requester, who must be allowed to edit it. manage reports whether they may
+// also change its co-authors and owner, which only the owner and admins can.
+func loadQuestionForSharing(w http.ResponseWriter, r *http.Request, db *gorm.DB) (question *models.Question, user *models.User, manage, ok bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid question ID", http.StatusBadRequest)
+		return nil, nil, false, false
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return nil, nil, false, false
+	}
+
+	question = &models.Question{}
+	if err := db.First(question, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Question not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve question", http.StatusInternalServerError)
+		}
+		return nil, nil, false, false
+	}
+
+	user = &models.User{}
+	if err := db.First(user, userID).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+		return nil, nil, false, false
+	}
+
+	if !canEditQuestion(db, question, user) {
+		apierror.Write(w, r, "Unauthorized to edit this question", http.StatusForbidden)
+		return nil, nil, false, false
+	}
+	manage = user.Role == models.AdminRole || question.UserID == user.ID
+	return question, user, manage, true
+}
+
+// findRequestedUser decodes a QuestionUserRequest and loads the user it names
+func findRequestedUser(w http.ResponseWriter, r *http.Request, db *gorm.DB) (*models.User, QuestionUserRequest, bool) {
+	var req QuestionUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, r, err)
+		return nil, req, false
+	}
+	if !validRequest(w, r, &req) {
+		return nil, req, false
+	}
+	req.Username = strings.TrimSpace(req.Username)
+
+	query := db.Where("username = ?", req.Username)
+	if req.UserID != 0 {
+		query = db.Where("id = ?", req.UserID)
+	}
+
+	var target models.User
+	if err := query.First(&target).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "User not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+		}
+		return nil, req, false
+	}
+	return &target, req, true
+}
+
+// getQuestionContributors lists the owner and co-authors of a question to
+// those who can edit it
+func getQuestionContributors(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	question, _, _, ok := loadQuestionForSharing(w, r, db)
+	if !ok {
+		return
+	}
+
+	response := QuestionContributorsResponse{OwnerID: question.UserID, Contributors: []models.QuestionContributor{}}
+	var owner models.User
+	if err := db.Select("username").First(&owner, question.UserID).Error; err == nil {
+		response.OwnerUsername = owner.Username
+	}
+	if err := db.Preload("User").Where("question_id = ?", question.ID).Order("created_at").Find(&response.Contributors).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve contributors", http.StatusInternalServerError)
+		return
+	}
+	for i := range response.Contributors {
+		response.Contributors[i].Username = response.Contributors[i].User.Username
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// addQuestionContributor makes a user a co-author of a question. Adding an
+// existing co-author again changes nothing.
+func addQuestionContributor(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	question, user, manage, ok := loadQuestionForSharing(w, r, db)
+	if !ok {
+		return
+	}
+	if !manage {
+		apierror.Write(w, r, "Only the owner or an administrator can manage co-authors", http.StatusForbidden)
+		return
+	}
+
+	target, _, ok := findRequestedUser(w, r, db)
+	if !ok {
+		return
+	}
+	if target.ID == question.UserID {
+		apierror.Write(w, r, "The owner of a question cannot be its co-author", http.StatusBadRequest)
+		return
+	}
+
+	contributor := models.QuestionContributor{QuestionID: question.ID, UserID: target.ID, AddedBy: user.ID}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&contributor)
+	if result.Error != nil {
+		log.Printf("Database error: %v", result.Error)
+		apierror.Write(w, r, "Failed to add contributor", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		if err := db.Where("question_id = ? AND user_id = ?", question.ID, target.ID).First(&contributor).Error; err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to add contributor", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		invalidateQuestion(r.Context(), question.ID)
+		audit(db, user.ID, models.AuditContributorAdded, "question", question.ID, target.Username)
+		notify(db, target.ID, models.NotificationQuestionShared,
+			fmt.Sprintf("You were made a co-author of %s", question.Title),
+			fmt.Sprintf("/question/%d", question.ID))
+	}
+
+	contributor.Username = target.Username
+	w.Header().Set("Content-Type", "application/json")
+	if result.RowsAffected > 0 {
+		w.WriteHeader(http.StatusCreated)
+	}
+	if err := json.NewEncoder(w).Encode(contributor); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// removeQuestionContributor takes a co-author off a question. Co-authors can
+// remove themselves; everyone else needs to be the owner or an admin.
+func removeQuestionContributor(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	question, user, manage, ok := loadQuestionForSharing(w, r, db)
+	if !ok {
+		return
+	}
+	contributorID, err := strconv.Atoi(mux.Vars(r)["userId"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	if !manage && uint(contributorID) != user.ID {
+		apierror.Write(w, r, "Only the owner or an administrator can manage co-authors", http.StatusForbidden)
+		return
+	}
+
+	result := db.Unscoped().Where("question_id = ? AND user_id = ?", question.ID, contributorID).Delete(&models.QuestionContributor{})
+	if result.Error != nil {
+		log.Printf("Database error: %v", result.Error)
+		apierror.Write(w, r, "Failed to remove contributor", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		apierror.Write(w, r, "Contributor not found", http.StatusNotFound)
+		return
+	}
+	invalidateQuestion(r.Context(), question.ID)
+	audit(db, user.ID, models.AuditContributorRemoved, "question", question.ID, strconv.Itoa(contributorID))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// transferQuestion hands a question over to another user, who becomes its
+// owner. The new owner stops being a co-author, and the previous owner
+// becomes one if the request asks for it.
+func transferQuestion(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	question, user, manage, ok := loadQuestionForSharing(w, r, db)
+	if !ok {
+		return
+	}
+	if !manage {
+		apierror.Write(w, r, "Only the owner or an administrator can transfer a question", http.StatusForbidden)
+		return
+	}
+
+	target, req, ok := findRequestedUser(w, r, db)
+	if !ok {
+		return
+	}
+	if target.ID == question.UserID {
+		apierror.Write(w, r, "The question already belongs to this user", http.StatusBadRequest)
+		return
+	}
+
+	previousOwner := question.UserID
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(question).Update("user_id", target.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("question_id = ? AND user_id = ?", question.ID, target.ID).Delete(&models.QuestionContributor{}).Error; err != nil {
+			return err
+		}
+		if req.KeepAsContributor {
+			contributor := models.QuestionContributor{QuestionID: question.ID, UserID: previousOwner, AddedBy: user.ID}
+			return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&contributor).Error
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to transfer question", http.StatusInternalServerError)
+		return
+	}
+	invalidateQuestion(r.Context(), question.ID)
+	audit(db, user.ID, models.AuditQuestionTransferred, "question", question.ID,
+		fmt.Sprintf("from user %d to %s", previousOwner, target.Username))
+	notify(db, target.ID, models.NotificationQuestionShared,
+		fmt.Sprintf("%s is now yours", question.Title),
+		fmt.Sprintf("/question/%d", question.ID))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(question); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,223 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxDraftBytes caps the body of an autosave request
+const maxDraftBytes = 1024 * 1024
+
+// QuestionDraftRequest represents the request body for autosaving a draft.
+// Fields left out keep their current value.
+type QuestionDraftRequest struct {
+	Title   *string `json:"title" validate:"max=200"`
+	Content *string `json:"content" validate:"max=100000"`
+	Tags    *string `json:"tags" validate:"max=500"`
+}
+
+// QuestionDraftHandler handles requests to /api/questions/{id}/draft
+func QuestionDraftHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getQuestionDraft(w, r)
+	case http.MethodPatch:
+		saveQuestionDraft(w, r)
+	case http.MethodDelete:
+		deleteQuestionDraft(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// loadEditableQuestion loads a question and checks that the requester may edit
+// it, returning the requester's ID
+func loadEditableQuestion(w http.ResponseWriter, r *http.Request, db *gorm.DB) (*models.Question, uint, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid question ID", http.StatusBadRequest)
+		return nil, 0, false
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return nil, 0, false
+	}
+
+	var question models.Question
+	if err := db.First(&question, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Question not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve question", http.StatusInternalServerError)
+		}
+		return nil, 0, false
+	}
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+		return nil, 0, false
+	}
+
+	if !canEditQuestion(db, &question, &user) {
+		apierror.Write(w, r, "Unauthorized to edit this question", http.StatusForbidden)
+		return nil, 0, false
+	}
+
+	return &question, userID, true
+}
+
+// deleteDraft removes an editor's draft of a question, if there is one
+func deleteDraft(tx *gorm.DB, questionID, userID uint) error {
+	return tx.Unscoped().
+		Where("question_id = ? AND user_id = ?", questionID, userID).
+		Delete(&models.QuestionDraft{}).Error
+}
+
+// getQuestionDraft returns the requester's draft of a question
+func getQuestionDraft(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	question, userID, ok := loadEditableQuestion(w, r, db)
+	if !ok {
+		return
+	}
+
+	var draft models.QuestionDraft
+	if err := db.Where("question_id = ? AND user_id = ?", question.ID, userID).First(&draft).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "No draft for this question", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve draft", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(draft); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// saveQuestionDraft merges the given statement fields into the requester's
+// draft. Drafts are not validated and never touch the question or its test
+// cases; the edit form submits the question as usual once it is done.
+func saveQuestionDraft(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxDraftBytes)
+
+	var draftReq QuestionDraftRequest
+	if err := json.NewDecoder(r.Body).Decode(&draftReq); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+	if !validRequest(w, r, &draftReq) {
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	question, userID, ok := loadEditableQuestion(w, r, db)
+	if !ok {
+		return
+	}
+
+	// A new draft starts from the saved statement, so it is always complete
+	var draft models.QuestionDraft
+	err := db.Where("question_id = ? AND user_id = ?", question.ID, userID).First(&draft).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		draft = models.QuestionDraft{
+			QuestionID: question.ID,
+			UserID:     userID,
+			Title:      question.Title,
+			Content:    question.Content,
+			Tags:       question.Tags,
+		}
+	} else if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve draft", http.StatusInternalServerError)
+		return
+	}
+
+	if draftReq.Title != nil {
+		draft.Title = *draftReq.Title
+	}
+	if draftReq.Content != nil {
+		draft.Content = *draftReq.Content
+	}
+	if draftReq.Tags != nil {
+		draft.Tags = *draftReq.Tags
+	}
+
+	if draft.ID == 0 {
+		// Two autosaves racing to create the draft both end up written
+		err = db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "question_id"}, {Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"title", "content", "tags", "updated_at"}),
+		}).Create(&draft).Error
+	} else {
+		err = db.Save(&draft).Error
+	}
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to save draft", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(draft); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// deleteQuestionDraft discards the requester's draft of a question
+func deleteQuestionDraft(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	question, userID, ok := loadEditableQuestion(w, r, db)
+	if !ok {
+		return
+	}
+
+	if err := deleteDraft(db, question.ID, userID); err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to delete draft", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,243 @@
+package api
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/config"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+)
+
+// Questions listed by the question feeds
+const questionFeedSize = 50
+
+// questionSummaryLength is the characters of a question's statement that
+// summarize it in the feeds
+const questionSummaryLength = 280
+
+const questionFeedTitle = "Goera problems"
+
+// JSONFeed is a JSON Feed 1.1 document, see https://jsonfeed.org/version/1.1
+type JSONFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Items       []JSONFeedItem `json:"items"`
+}
+
+// JSONFeedItem is a published question in the JSON feed
+type JSONFeedItem struct {
+	ID            string     `json:"id"`
+	URL           string     `json:"url"`
+	Title         string     `json:"title"`
+	ContentText   string     `json:"content_text"`
+	Summary       string     `json:"summary"`
+	DatePublished *time.Time `json:"date_published,omitempty"`
+	Tags          []string   `json:"tags,omitempty"`
+
+	// Extension with the question's details for bots mirroring problems
+	Goera JSONFeedQuestion `json:"_goera"`
+}
+
+// JSONFeedQuestion holds the details of a question in a JSON feed item
+type JSONFeedQuestion struct {
+	QuestionID  uint              `json:"question_id"`
+	Difficulty  models.Difficulty `json:"difficulty"`
+	TimeLimit   int               `json:"time_limit_ms"`
+	MemoryLimit int               `json:"memory_limit_mb"`
+}
+
+// atomFeed is an Atom 1.0 document, see RFC 4287
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published,omitempty"`
+	Updated    string         `xml:"updated"`
+	Summary    string         `xml:"summary"`
+	Categories []atomCategory `xml:"category"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// QuestionAtomFeedHandler handles requests to /feed/questions.atom
+func QuestionAtomFeedHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getQuestionAtomFeed(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// QuestionJSONFeedHandler handles requests to /api/feed/questions.json
+func QuestionJSONFeedHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getQuestionJSONFeed(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// feedQuestions returns the most recently published questions, leaving out
+// the problems of running contests like the question list. On failure it
+// writes the error response and returns false.
+func feedQuestions(w http.ResponseWriter, r *http.Request) ([]models.Question, bool) {
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	var questions []models.Question
+	err := db.Where("published = ? AND id NOT IN (?)", true, runningContestQuestions(db, time.Now())).
+		Order("published_at DESC").Limit(questionFeedSize).
+		Find(&questions).Error
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve questions", http.StatusInternalServerError)
+		return nil, false
+	}
+	return questions, true
+}
+
+// publicURL returns the absolute URL of path on the site
+func publicURL(path string) string {
+	return strings.TrimSuffix(config.PublicURL, "/") + path
+}
+
+// questionSummary is the start of a question's statement on a single line
+func questionSummary(content string) string {
+	summary := strings.Join(strings.Fields(content), " ")
+	if utf8.RuneCountInString(summary) <= questionSummaryLength {
+		return summary
+	}
+	runes := []rune(summary)
+	return strings.TrimSpace(string(runes[:questionSummaryLength-1])) + "…"
+}
+
+// getQuestionJSONFeed serves the recently published questions as a JSON Feed
+func getQuestionJSONFeed(w http.ResponseWriter, r *http.Request) {
+	questions, ok := feedQuestions(w, r)
+	if !ok {
+		return
+	}
+
+	feed := JSONFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       questionFeedTitle,
+		HomePageURL: publicURL("/questions"),
+		FeedURL:     publicURL("/api/feed/questions.json"),
+		Items:       make([]JSONFeedItem, len(questions)),
+	}
+	for i, question := range questions {
+		url := publicURL(fmt.Sprintf("/question/%d", question.ID))
+		feed.Items[i] = JSONFeedItem{
+			ID:            url,
+			URL:           url,
+			Title:         question.Title,
+			ContentText:   question.Content,
+			Summary:       questionSummary(question.Content),
+			DatePublished: question.PublishedAt,
+			Tags:          splitTags(question.Tags),
+			Goera: JSONFeedQuestion{
+				QuestionID:  question.ID,
+				Difficulty:  question.Difficulty,
+				TimeLimit:   question.TimeLimit,
+				MemoryLimit: question.MemoryLimit,
+			},
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/feed+json")
+	if err := json.NewEncoder(w).Encode(feed); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// getQuestionAtomFeed serves the recently published questions as an Atom
+// feed for feed readers
+func getQuestionAtomFeed(w http.ResponseWriter, r *http.Request) {
+	questions, ok := feedQuestions(w, r)
+	if !ok {
+		return
+	}
+
+	feed := atomFeed{
+		Title: questionFeedTitle,
+		ID:    publicURL("/questions"),
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: publicURL("/feed/questions.atom")},
+			{Rel: "alternate", Type: "text/html", Href: publicURL("/questions")},
+		},
+		Author:  atomAuthor{Name: "Goera"},
+		Entries: make([]atomEntry, len(questions)),
+	}
+	// A feed without entries was last updated when it was read
+	updated := time.Now()
+	for i, question := range questions {
+		url := publicURL(fmt.Sprintf("/question/%d", question.ID))
+		published := question.UpdatedAt
+		if question.PublishedAt != nil {
+			published = *question.PublishedAt
+		}
+		if i == 0 {
+			updated = published
+		}
+		entry := atomEntry{
+			Title:     question.Title,
+			ID:        url,
+			Link:      atomLink{Rel: "alternate", Type: "text/html", Href: url},
+			Published: published.UTC().Format(time.RFC3339),
+			Updated:   published.UTC().Format(time.RFC3339),
+			Summary:   questionSummary(question.Content),
+		}
+		for _, tag := range splitTags(question.Tags) {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		feed.Entries[i] = entry
+	}
+	feed.Updated = updated.UTC().Format(time.RFC3339)
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	fmt.Fprint(w, xml.Header)
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(feed); err != nil {
+		log.Printf("XML encoding error: %v", err)
+	}
+}
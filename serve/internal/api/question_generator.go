@@ -0,0 +1,354 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/config"
+	"goera/serve/internal/database"
+	"goera/serve/internal/internalpb"
+	"goera/serve/internal/models"
+	"goera/serve/internal/validation"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxGeneratorSpecBytes caps the spec passed to a generator
+const maxGeneratorSpecBytes = 64 * 1024
+
+// generateTimeout bounds a whole generation request to the judge, which runs
+// the generator once per test case
+const generateTimeout = 10 * time.Minute
+
+// QuestionGeneratorRequest represents the request body for attaching a generator
+type QuestionGeneratorRequest struct {
+	SourceCode string `json:"sourceCode" validate:"required"`
+	Spec       string `json:"spec"`
+}
+
+// GenerateTestCasesRequest represents the request body for running a generator
+type GenerateTestCasesRequest struct {
+	Count int `json:"count"`
+}
+
+// Validate checks Count against the most test cases a question can have. How
+// many more the question has room for is checked once it is loaded.
+func (req *GenerateTestCasesRequest) Validate(errs *validation.Errors) {
+	errs.Range("count", req.Count, 1, config.MaxTestCasesPerQuestion)
+}
+
+// GenerateResult is what a generator printed for each input
+type GenerateResult struct {
+	Status  Result
+	Output  string
+	Outputs []string
+}
+
+// QuestionGeneratorHandler handles requests to /api/questions/{id}/generator
+func QuestionGeneratorHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getQuestionGenerator(w, r)
+	case http.MethodPut:
+		saveQuestionGenerator(w, r)
+	case http.MethodDelete:
+		deleteQuestionGenerator(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GenerateTestCasesHandler handles requests to /api/questions/{id}/generator/run
+func GenerateTestCasesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		generateTestCases(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getQuestionGenerator returns the generator attached to a question
+func getQuestionGenerator(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	question, _, ok := loadEditableQuestion(w, r, db)
+	if !ok {
+		return
+	}
+
+	var generator models.QuestionGenerator
+	if err := db.Where("question_id = ?", question.ID).First(&generator).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Question has no generator", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve generator", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(generator); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// saveQuestionGenerator attaches a generator and its spec to a question,
+// replacing the previous one
+func saveQuestionGenerator(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, int64(config.MaxSourceCodeBytes+maxGeneratorSpecBytes)*2+4096)
+
+	var generatorReq QuestionGeneratorRequest
+	if err := json.NewDecoder(r.Body).Decode(&generatorReq); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+	if !validRequest(w, r, &generatorReq) {
+		return
+	}
+	if len(generatorReq.SourceCode) > config.MaxSourceCodeBytes {
+		apierror.Write(w, r, fmt.Sprintf("Source code exceeds the maximum size of %d bytes", config.MaxSourceCodeBytes), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if len(generatorReq.Spec) > maxGeneratorSpecBytes {
+		apierror.Write(w, r, fmt.Sprintf("Spec exceeds the maximum size of %d bytes", maxGeneratorSpecBytes), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	question, _, ok := loadEditableQuestion(w, r, db)
+	if !ok {
+		return
+	}
+
+	generator := models.QuestionGenerator{
+		QuestionID: question.ID,
+		SourceCode: generatorReq.SourceCode,
+		Spec:       generatorReq.Spec,
+	}
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "question_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"source_code", "spec", "updated_at"}),
+	}).Create(&generator).Error
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to save generator", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(generator); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// deleteQuestionGenerator detaches the generator from a question. Test cases
+// it already generated are kept.
+func deleteQuestionGenerator(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	question, _, ok := loadEditableQuestion(w, r, db)
+	if !ok {
+		return
+	}
+
+	if err := db.Unscoped().Where("question_id = ?", question.ID).Delete(&models.QuestionGenerator{}).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to delete generator", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// splitGeneratedTest divides a generator's output into the test input and
+// the expected output at the first separator line
+func splitGeneratedTest(output string) (input, expected string, ok bool) {
+	lines := strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		if line == models.GeneratorSeparator {
+			return strings.Join(lines[:i], "\n"), strings.TrimRight(strings.Join(lines[i+1:], "\n"), "\n"), true
+		}
+	}
+	return "", "", false
+}
+
+// runGenerator has the judge run a generator once per input in its sandbox,
+// with the deployment's maximum time and memory limits
+func runGenerator(generator *models.QuestionGenerator, inputs []string) (*GenerateResult, error) {
+	return runProgram(generator.SourceCode, inputs, jobLimits(config.MaxTimeLimitMs, config.MaxMemoryLimitMB, defaultLanguage))
+}
+
+// runProgram has the judge run a Go program once per input in its sandbox,
+// collecting what it printed. The runs stop at the first one that fails.
+func runProgram(sourceCode string, inputs []string, limits *internalpb.Limits) (*GenerateResult, error) {
+	client, err := judgeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create judge client: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), generateTimeout)
+	defer cancel()
+	resp, err := client.Generate(ctx, &internalpb.GenerateRequest{
+		SourceCode: sourceCode,
+		Inputs:     inputs,
+		Limits:     limits,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("judge service error: %w", err)
+	}
+
+	return &GenerateResult{
+		Status:  verdictResults[resp.GetVerdict()],
+		Output:  resp.GetOutput(),
+		Outputs: resp.GetOutputs(),
+	}, nil
+}
+
+// generateTestCases runs a question's generator Count times and appends the
+// generated test cases to the question as a new revision. Admin only, since
+// it runs author code and changes what submissions are judged against.
+func generateTestCases(w http.ResponseWriter, r *http.Request) {
+	var generateReq GenerateTestCasesRequest
+	if err := json.NewDecoder(r.Body).Decode(&generateReq); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+	if !validRequest(w, r, &generateReq) {
+		return
+	}
+
+	if !requireAdmin(w, r, "Only admins can generate test cases") {
+		return
+	}
+
+	db := database.GetDB()
+	question, userID, ok := loadEditableQuestion(w, r, db)
+	if !ok {
+		return
+	}
+
+	var generator models.QuestionGenerator
+	if err := db.Where("question_id = ?", question.ID).First(&generator).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Question has no generator", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve generator", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	var existing []models.TestCase
+	if err := db.Where("question_id = ?", question.ID).Order("id").Find(&existing).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve test cases", http.StatusInternalServerError)
+		return
+	}
+	for i := range existing {
+		if err := existing[i].LoadData(r.Context()); err != nil {
+			log.Printf("Storage error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve test cases", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	room := config.MaxTestCasesPerQuestion - len(existing)
+	if generateReq.Count < 1 || generateReq.Count > room {
+		apierror.Write(w, r, fmt.Sprintf("Count must be between 1 and %d, as a question can have at most %d test cases", max(room, 0), config.MaxTestCasesPerQuestion), http.StatusBadRequest)
+		return
+	}
+
+	// Every run gets its own seed, so the generated tests differ
+	inputs := make([]string, generateReq.Count)
+	for i := range inputs {
+		inputs[i] = fmt.Sprintf("%d\n%s", rand.Int64(), generator.Spec)
+	}
+
+	result, err := runGenerator(&generator, inputs)
+	if err != nil {
+		log.Printf("Failed to run generator of question %d: %v", question.ID, err)
+		apierror.Write(w, r, "Judge service could not run the generator", http.StatusServiceUnavailable)
+		return
+	}
+	if result.Status != Accepted || len(result.Outputs) != len(inputs) {
+		apierror.Write(w, r, fmt.Sprintf("Generator failed with %s:\n%s", result.Status, result.Output), http.StatusUnprocessableEntity)
+		return
+	}
+
+	// Size limits apply to the question's test cases as a whole
+	sizes := QuestionRequest{}
+	for _, tc := range existing {
+		sizes.SampleInputs = append(sizes.SampleInputs, tc.Input)
+		sizes.SampleOutputs = append(sizes.SampleOutputs, tc.ExpectedOutput)
+	}
+
+	generated := make([]models.TestCase, len(result.Outputs))
+	for i, output := range result.Outputs {
+		input, expected, ok := splitGeneratedTest(output)
+		if !ok {
+			apierror.Write(w, r, fmt.Sprintf("Generator output %d has no %q line between the input and the expected output", i+1, models.GeneratorSeparator), http.StatusUnprocessableEntity)
+			return
+		}
+		generated[i] = models.TestCase{QuestionID: question.ID, Input: input, ExpectedOutput: expected}
+		sizes.SampleInputs = append(sizes.SampleInputs, input)
+		sizes.SampleOutputs = append(sizes.SampleOutputs, expected)
+	}
+
+	if err := validateTestCaseSizes(sizes); err != nil {
+		apierror.Write(w, r, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := ensureBaseRevision(tx, question); err != nil {
+			return err
+		}
+		if err := tx.Create(&generated).Error; err != nil {
+			return err
+		}
+		return recordQuestionRevision(tx, question, append(existing, generated...), userID, fmt.Sprintf("Generated %d test cases", len(generated)))
+	})
+	if err != nil {
+		log.Printf("Failed to store generated test cases of question %d: %v", question.ID, err)
+		apierror.Write(w, r, "Failed to store generated test cases", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Generated %d test cases for question %d by user %d", len(generated), question.ID, userID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(generated); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os/exec"
+	"strings"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/config"
+	"goera/serve/internal/models"
+	"goera/serve/internal/templates"
+)
+
+// PrintableQuestion is what the printable statement of a question shows
+type PrintableQuestion struct {
+	QuestionID  uint
+	Title       string
+	Statement   string
+	TimeLimit   int
+	MemoryLimit int
+	Difficulty  models.Difficulty
+	Examples    []models.Example
+	URL         string // Where the question is solved, printed at the bottom
+	// ForPDF leaves out the buttons, and PDFAvailable offers the PDF download
+	ForPDF       bool
+	PDFAvailable bool
+}
+
+// NewPrintableQuestion returns the printable statement of question
+func NewPrintableQuestion(question *models.Question) PrintableQuestion {
+	return PrintableQuestion{
+		QuestionID:   question.ID,
+		Title:        question.Title,
+		Statement:    question.Content,
+		TimeLimit:    question.TimeLimit,
+		MemoryLimit:  question.MemoryLimit,
+		Difficulty:   question.Difficulty,
+		Examples:     question.Examples,
+		URL:          fmt.Sprintf("%s/question/%d", strings.TrimRight(config.PublicURL, "/"), question.ID),
+		PDFAvailable: config.PDFCommand != "",
+	}
+}
+
+// QuestionPDFHandler handles requests to /api/questions/{id}/pdf
+func QuestionPDFHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getQuestionPDF(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getQuestionPDF returns the printable statement of a question as a PDF, so
+// instructors can hand problems out on paper. Anyone who can view the
+// question can download it.
+func getQuestionPDF(w http.ResponseWriter, r *http.Request) {
+	if config.PDFCommand == "" {
+		apierror.Write(w, r, "PDF export is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	question, ok := viewableQuestion(w, r)
+	if !ok {
+		return
+	}
+
+	page := NewPrintableQuestion(question)
+	page.ForPDF = true
+	var html bytes.Buffer
+	if err := templates.Render(&html, r, "questionPrint.html", page); err != nil {
+		log.Printf("Error rendering printable statement of question %d: %v", question.ID, err)
+		apierror.Write(w, r, "Failed to create the PDF", http.StatusInternalServerError)
+		return
+	}
+
+	pdf, err := htmlToPDF(r.Context(), html.Bytes())
+	if err != nil {
+		log.Printf("Error creating PDF of question %d: %v", question.ID, err)
+		if errors.Is(err, exec.ErrNotFound) {
+			apierror.Write(w, r, "PDF export is not available", http.StatusServiceUnavailable)
+		} else {
+			apierror.Write(w, r, "Failed to create the PDF", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="question-%d.pdf"`, question.ID))
+	w.Write(pdf)
+}
+
+// htmlToPDF runs config.PDFCommand with html on its standard input and
+// returns the PDF it writes
+func htmlToPDF(ctx context.Context, html []byte) ([]byte, error) {
+	args := strings.Fields(config.PDFCommand)
+	ctx, cancel := context.WithTimeout(ctx, config.PDFTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(html)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if !bytes.HasPrefix(stdout.Bytes(), []byte("%PDF-")) {
+		return nil, fmt.Errorf("%s did not write a PDF", args[0])
+	}
+	return stdout.Bytes(), nil
+}
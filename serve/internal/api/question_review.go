@@ -0,0 +1,310 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+	"goera/serve/internal/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// QuestionStatusRequest asks to move a question to another review status
+type QuestionStatusRequest struct {
+	Status  models.QuestionStatus `json:"status" validate:"required,oneof=draft in_review changes_requested published"`
+	Comment string                `json:"comment" validate:"max=5000"`
+}
+
+// questionTransition is a change of review status the workflow allows
+type questionTransition struct {
+	from, to     models.QuestionStatus
+	adminOnly    bool // Made by a reviewer rather than the author
+	needsComment bool // The reviewer has to say what to change
+	message      string
+}
+
+// questionTransitions are the allowed changes of a question's review status.
+// Authors submit their questions for review and may withdraw them; admins
+// request changes, publish and unpublish.
+var questionTransitions = []questionTransition{
+	{from: models.QuestionStatusDraft, to: models.QuestionStatusInReview, message: "submitted for review"},
+	{from: models.QuestionStatusChangesRequested, to: models.QuestionStatusInReview, message: "submitted for review"},
+	{from: models.QuestionStatusInReview, to: models.QuestionStatusDraft, message: "withdrawn from review"},
+	{from: models.QuestionStatusInReview, to: models.QuestionStatusChangesRequested, adminOnly: true, needsComment: true, message: "sent back with requested changes"},
+	{from: models.QuestionStatusDraft, to: models.QuestionStatusPublished, adminOnly: true, message: "published"},
+	{from: models.QuestionStatusInReview, to: models.QuestionStatusPublished, adminOnly: true, message: "published"},
+	{from: models.QuestionStatusChangesRequested, to: models.QuestionStatusPublished, adminOnly: true, message: "published"},
+	{from: models.QuestionStatusPublished, to: models.QuestionStatusDraft, adminOnly: true, message: "unpublished"},
+}
+
+// findQuestionTransition returns the allowed transition between two statuses
+func findQuestionTransition(from, to models.QuestionStatus) (questionTransition, bool) {
+	for _, transition := range questionTransitions {
+		if transition.from == from && transition.to == to {
+			return transition, true
+		}
+	}
+	return questionTransition{}, false
+}
+
+// QuestionStatusHandler handles requests to /api/questions/{id}/status
+func QuestionStatusHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPut, http.MethodPost:
+		updateQuestionStatus(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// QuestionReviewsHandler handles requests to /api/questions/{id}/reviews
+func QuestionReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getQuestionReviews(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// updateQuestionStatus moves a question through the review workflow. The
+// author and admins may use it, within the limits of questionTransitions.
+func updateQuestionStatus(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid question ID", http.StatusBadRequest)
+		return
+	}
+
+	var statusReq QuestionStatusRequest
+	formProcessor := func(r *http.Request) (interface{}, error) {
+		return QuestionStatusRequest{
+			Status:  models.QuestionStatus(r.FormValue("status")),
+			Comment: r.FormValue("comment"),
+		}, nil
+	}
+	result, err := utils.ProcessRequestData(r, &statusReq, formProcessor)
+	if err != nil {
+		writeRequestDataError(w, r, err)
+		return
+	}
+	if formData, ok := result.(QuestionStatusRequest); ok {
+		statusReq = formData
+	}
+	if !validRequest(w, r, &statusReq) {
+		return
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+		return
+	}
+
+	var question models.Question
+	if err := db.First(&question, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Question not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve question", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if !canEditQuestion(db, &question, &user) {
+		apierror.Write(w, r, "Unauthorized to change the status of this question", http.StatusForbidden)
+		return
+	}
+
+	if question.Status == statusReq.Status {
+		if utils.IsFormRequest(r) {
+			http.Redirect(w, r, fmt.Sprintf("/question/%d?error=already_%s", question.ID, statusReq.Status), http.StatusSeeOther)
+			return
+		}
+		apierror.Write(w, r, fmt.Sprintf("Question is already %s", statusReq.Status), http.StatusBadRequest)
+		return
+	}
+
+	if !changeQuestionStatus(w, r, db, &question, &user, statusReq.Status, statusReq.Comment) {
+		return
+	}
+
+	if utils.IsFormRequest(r) {
+		http.Redirect(w, r, fmt.Sprintf("/question/%d?success=%s", question.ID, question.Status), http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(question); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// changeQuestionStatus moves question to status on behalf of user and records
+// the review with its comment against the current revision. Publishing checks
+// the reference solution first. The author is notified when someone else
+// changed the status. On failure it writes the error response and returns false.
+func changeQuestionStatus(w http.ResponseWriter, r *http.Request, db *gorm.DB, question *models.Question, user *models.User, status models.QuestionStatus, comment string) bool {
+	transition, ok := findQuestionTransition(question.Status, status)
+	if !ok {
+		apierror.Write(w, r, fmt.Sprintf("A question cannot go from %s to %s", question.Status, status), http.StatusConflict)
+		return false
+	}
+	if transition.adminOnly && user.Role != models.AdminRole {
+		apierror.Write(w, r, "Only administrators can make this change", http.StatusForbidden)
+		return false
+	}
+	comment = strings.TrimSpace(comment)
+	if transition.needsComment && comment == "" {
+		apierror.Write(w, r, "A comment is required to request changes", http.StatusBadRequest)
+		return false
+	}
+
+	// The publishing checklist, reference solution included, must pass before
+	// the question goes live
+	if status == models.QuestionStatusPublished {
+		var testCases []models.TestCase
+		if err := db.Where("question_id = ?", question.ID).Order("id").Find(&testCases).Error; err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve test cases", http.StatusInternalServerError)
+			return false
+		}
+		if !checkPublishable(w, r, question, testCases) {
+			return false
+		}
+	}
+
+	from := question.Status
+	if err := saveQuestionStatus(db, question, user, status, comment); err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to update question", http.StatusInternalServerError)
+		return false
+	}
+	questionStatusChanged(r.Context(), db, question, user, from, transition, comment)
+	return true
+}
+
+// saveQuestionStatus moves question to status and records the review, without
+// checking that the change is allowed
+func saveQuestionStatus(db *gorm.DB, question *models.Question, user *models.User, status models.QuestionStatus, comment string) error {
+	from := question.Status
+	question.Status = status
+	question.Published = status == models.QuestionStatusPublished
+	if question.Published {
+		publishedByID := user.ID
+		question.PublishedBy = &publishedByID
+		now := time.Now()
+		question.PublishedAt = &now
+	} else {
+		question.PublishedBy = nil
+		question.PublishedAt = nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(question).Error; err != nil {
+			return err
+		}
+		// The review points at a revision, so questions without history get one
+		if err := ensureBaseRevision(tx, question); err != nil {
+			return err
+		}
+		var revision int
+		if err := tx.Model(&models.QuestionRevision{}).
+			Where("question_id = ?", question.ID).
+			Select("COALESCE(MAX(revision), 0)").
+			Scan(&revision).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.QuestionReview{
+			QuestionID: question.ID,
+			Revision:   revision,
+			ReviewerID: user.ID,
+			FromStatus: from,
+			ToStatus:   question.Status,
+			Comment:    comment,
+		}).Error
+	})
+}
+
+// questionStatusChanged follows up on a saved status change: it drops the
+// cached question, audits publishing and tells the author
+func questionStatusChanged(ctx context.Context, db *gorm.DB, question *models.Question, user *models.User, from models.QuestionStatus, transition questionTransition, comment string) {
+	invalidateQuestion(ctx, question.ID)
+
+	status := question.Status
+	if status == models.QuestionStatusPublished {
+		audit(db, user.ID, models.AuditQuestionPublished, "question", question.ID, question.Title)
+	} else if from == models.QuestionStatusPublished {
+		audit(db, user.ID, models.AuditQuestionUnpublished, "question", question.ID, question.Title)
+	}
+
+	if question.UserID != user.ID {
+		notificationType := models.NotificationQuestionReviewed
+		if status == models.QuestionStatusPublished {
+			notificationType = models.NotificationQuestionPublished
+		}
+		message := fmt.Sprintf("Your question %s was %s", question.Title, transition.message)
+		if comment != "" {
+			message += ": " + comment
+		}
+		notify(db, question.UserID, notificationType, message, fmt.Sprintf("/question/%d", question.ID))
+	}
+}
+
+// getQuestionReviews lists the review history of a question, oldest first.
+// Like revisions, it is only shown to the author and admins.
+func getQuestionReviews(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	question, _, ok := loadQuestionForHistory(w, r, db)
+	if !ok {
+		return
+	}
+
+	var reviews []models.QuestionReview
+	if err := db.Where("question_id = ?", question.ID).Order("created_at, id").Find(&reviews).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve reviews", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(reviews); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
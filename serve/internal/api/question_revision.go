@@ -0,0 +1,435 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+	"goera/serve/internal/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// TestCaseChange describes how a test case differs between two revisions
+type TestCaseChange struct {
+	Index  int    `json:"index"`
+	Status string `json:"status"` // added, removed, changed or unchanged
+}
+
+// RevisionDiff compares two revisions of a question
+type RevisionDiff struct {
+	From        int              `json:"from"`
+	To          int              `json:"to"`
+	Title       []utils.DiffLine `json:"title"`
+	Content     []utils.DiffLine `json:"content"`
+	Tags        []utils.DiffLine `json:"tags"`
+	Languages   [2]string        `json:"allowedLanguages"` // Old and new value
+	BuildFlags  [2]string        `json:"buildFlags"`       // Old and new value
+	TimeLimit   [2]int           `json:"timeLimit"`        // Old and new value
+	MemoryLimit [2]int           `json:"memoryLimit"`      // Old and new value
+	Difficulty  [2]string        `json:"difficulty"`       // Old and new value
+	MaxAttempts [2]int           `json:"maxAttempts"`      // Old and new value
+	Cooldown    [2]int           `json:"attemptCooldown"`  // Old and new value
+	TestCases   []TestCaseChange `json:"testCases"`
+}
+
+// QuestionRevisionsHandler handles requests to /api/questions/{id}/revisions
+func QuestionRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getQuestionRevisions(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// QuestionRevisionHandler handles requests to /api/questions/{id}/revisions/{rev}
+func QuestionRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getQuestionRevision(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// QuestionRevisionDiffHandler handles requests to /api/questions/{id}/revisions/{rev}/diff
+func QuestionRevisionDiffHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		diffQuestionRevision(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// QuestionRollbackHandler handles requests to /api/questions/{id}/revisions/{rev}/rollback
+func QuestionRollbackHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		rollbackQuestion(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// recordQuestionRevision stores a snapshot of the question and its test cases
+// as the next revision, noting whether the test cases changed since the last one
+func recordQuestionRevision(tx *gorm.DB, question *models.Question, testCases []models.TestCase, editorID uint, note string) error {
+	var latest int
+	if err := tx.Model(&models.QuestionRevision{}).
+		Where("question_id = ?", question.ID).
+		Select("COALESCE(MAX(revision), 0)").
+		Scan(&latest).Error; err != nil {
+		return err
+	}
+
+	snapshot := make([]models.RevisionTestCase, len(testCases))
+	for i, tc := range testCases {
+		snapshot[i] = models.RevisionTestCase{Sample: tc.Sample, InputRef: tc.InputRef, OutputRef: tc.OutputRef}
+		if tc.InputRef == "" {
+			snapshot[i].Input = tc.Input
+		}
+		if tc.OutputRef == "" {
+			snapshot[i].ExpectedOutput = tc.ExpectedOutput
+		}
+	}
+
+	changed := false
+	if latest > 0 {
+		var previous models.QuestionRevision
+		if err := tx.Select("test_cases").
+			Where("question_id = ? AND revision = ?", question.ID, latest).
+			First(&previous).Error; err != nil {
+			return err
+		}
+		changed = !slices.Equal(previous.TestCases, snapshot)
+	}
+
+	return tx.Create(&models.QuestionRevision{
+		QuestionID:       question.ID,
+		Revision:         latest + 1,
+		Title:            question.Title,
+		Content:          question.Content,
+		Tags:             question.Tags,
+		TimeLimit:        question.TimeLimit,
+		MemoryLimit:      question.MemoryLimit,
+		Difficulty:       question.Difficulty,
+		MaxAttempts:      question.MaxAttempts,
+		AttemptCooldown:  question.AttemptCooldown,
+		TestCases:        snapshot,
+		TestCasesChanged: changed,
+		EditedBy:         editorID,
+		Note:             note,
+		AllowedLanguages: question.AllowedLanguages,
+		BuildFlags:       question.BuildFlags,
+	}).Error
+}
+
+// ensureBaseRevision snapshots the current state of questions created before
+// revisions were recorded, so their first edit can still be rolled back
+func ensureBaseRevision(tx *gorm.DB, question *models.Question) error {
+	var count int64
+	if err := tx.Model(&models.QuestionRevision{}).Where("question_id = ?", question.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	var testCases []models.TestCase
+	if err := tx.Where("question_id = ?", question.ID).Order("id").Find(&testCases).Error; err != nil {
+		return err
+	}
+	return recordQuestionRevision(tx, question, testCases, question.UserID, "")
+}
+
+// loadQuestionForHistory loads a question and checks that the requester may see
+// its history: admins and the author only, since revisions include hidden test cases
+func loadQuestionForHistory(w http.ResponseWriter, r *http.Request, db *gorm.DB) (*models.Question, *models.User, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid question ID", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+
+	var question models.Question
+	if err := db.First(&question, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Question not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve question", http.StatusInternalServerError)
+		}
+		return nil, nil, false
+	}
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+
+	if !canEditQuestion(db, &question, &user) {
+		apierror.Write(w, r, "Unauthorized to view the history of this question", http.StatusForbidden)
+		return nil, nil, false
+	}
+
+	return &question, &user, true
+}
+
+// findRevision loads a single revision of a question, writing 404 if it does not exist
+func findRevision(w http.ResponseWriter, r *http.Request, db *gorm.DB, questionID uint, revision int) (*models.QuestionRevision, bool) {
+	var rev models.QuestionRevision
+	if err := db.Where("question_id = ? AND revision = ?", questionID, revision).First(&rev).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Revision not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve revision", http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+	return &rev, true
+}
+
+func getQuestionRevisions(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	question, _, ok := loadQuestionForHistory(w, r, db)
+	if !ok {
+		return
+	}
+
+	var revisions []models.QuestionRevision
+	if err := db.Where("question_id = ?", question.ID).Order("revision DESC").Find(&revisions).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve revisions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(revisions); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+func getQuestionRevision(w http.ResponseWriter, r *http.Request) {
+	revNumber, err := strconv.Atoi(mux.Vars(r)["rev"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid revision", http.StatusBadRequest)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	question, _, ok := loadQuestionForHistory(w, r, db)
+	if !ok {
+		return
+	}
+
+	rev, ok := findRevision(w, r, db, question.ID, revNumber)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(rev); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// diffQuestionRevision compares a revision with the one given in ?against=,
+// defaulting to the revision right before it
+func diffQuestionRevision(w http.ResponseWriter, r *http.Request) {
+	revNumber, err := strconv.Atoi(mux.Vars(r)["rev"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid revision", http.StatusBadRequest)
+		return
+	}
+
+	against := revNumber - 1
+	if againstParam := r.URL.Query().Get("against"); againstParam != "" {
+		against, err = strconv.Atoi(againstParam)
+		if err != nil {
+			apierror.Write(w, r, "Invalid against revision", http.StatusBadRequest)
+			return
+		}
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	question, _, ok := loadQuestionForHistory(w, r, db)
+	if !ok {
+		return
+	}
+
+	to, ok := findRevision(w, r, db, question.ID, revNumber)
+	if !ok {
+		return
+	}
+
+	// Diffing the first revision shows everything as added
+	from := &models.QuestionRevision{}
+	if against > 0 {
+		if from, ok = findRevision(w, r, db, question.ID, against); !ok {
+			return
+		}
+	}
+
+	diff := RevisionDiff{
+		From:        from.Revision,
+		To:          to.Revision,
+		Title:       utils.LineDiff(from.Title, to.Title),
+		Content:     utils.LineDiff(from.Content, to.Content),
+		Tags:        utils.LineDiff(from.Tags, to.Tags),
+		Languages:   [2]string{from.AllowedLanguages, to.AllowedLanguages},
+		BuildFlags:  [2]string{from.BuildFlags, to.BuildFlags},
+		TimeLimit:   [2]int{from.TimeLimit, to.TimeLimit},
+		MemoryLimit: [2]int{from.MemoryLimit, to.MemoryLimit},
+		Difficulty:  [2]string{string(from.Difficulty), string(to.Difficulty)},
+		MaxAttempts: [2]int{from.MaxAttempts, to.MaxAttempts},
+		Cooldown:    [2]int{from.AttemptCooldown, to.AttemptCooldown},
+	}
+
+	for i := 0; i < len(from.TestCases) || i < len(to.TestCases); i++ {
+		change := TestCaseChange{Index: i, Status: "unchanged"}
+		switch {
+		case i >= len(from.TestCases):
+			change.Status = "added"
+		case i >= len(to.TestCases):
+			change.Status = "removed"
+		case from.TestCases[i] != to.TestCases[i]:
+			change.Status = "changed"
+		}
+		diff.TestCases = append(diff.TestCases, change)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(diff); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// rollbackQuestion restores a question to a prior revision. The rollback is
+// itself recorded as a new revision so no history is lost.
+func rollbackQuestion(w http.ResponseWriter, r *http.Request) {
+	revNumber, err := strconv.Atoi(mux.Vars(r)["rev"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid revision", http.StatusBadRequest)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	question, user, ok := loadQuestionForHistory(w, r, db)
+	if !ok {
+		return
+	}
+
+	if user.Role != models.AdminRole {
+		apierror.Write(w, r, "Only administrators can roll back questions", http.StatusForbidden)
+		return
+	}
+
+	rev, ok := findRevision(w, r, db, question.ID, revNumber)
+	if !ok {
+		return
+	}
+
+	question.Title = rev.Title
+	question.Content = rev.Content
+	question.Tags = rev.Tags
+	question.AllowedLanguages = rev.AllowedLanguages
+	question.BuildFlags = rev.BuildFlags
+	question.TimeLimit = rev.TimeLimit
+	question.MemoryLimit = rev.MemoryLimit
+	question.Difficulty = rev.Difficulty
+	question.MaxAttempts = rev.MaxAttempts
+	question.AttemptCooldown = rev.AttemptCooldown
+
+	testCases := make([]models.TestCase, len(rev.TestCases))
+	for i, tc := range rev.TestCases {
+		testCases[i] = models.TestCase{
+			QuestionID:     question.ID,
+			Input:          tc.Input,
+			ExpectedOutput: tc.ExpectedOutput,
+			Sample:         tc.Sample,
+			InputRef:       tc.InputRef,
+			OutputRef:      tc.OutputRef,
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(question).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("question_id = ?", question.ID).Delete(&models.TestCase{}).Error; err != nil {
+			return err
+		}
+		if len(testCases) > 0 {
+			if err := tx.Create(&testCases).Error; err != nil {
+				return err
+			}
+		}
+		return recordQuestionRevision(tx, question, testCases, user.ID, fmt.Sprintf("Rolled back to revision %d", rev.Revision))
+	})
+	if err != nil {
+		log.Printf("Failed to roll back question %d: %v", question.ID, err)
+		apierror.Write(w, r, "Failed to roll back question", http.StatusInternalServerError)
+		return
+	}
+	invalidateQuestion(r.Context(), question.ID)
+	audit(db, user.ID, models.AuditQuestionRolledBack, "question", question.ID, fmt.Sprintf("Rolled back to revision %d", rev.Revision))
+
+	if utils.IsFormRequest(r) {
+		http.Redirect(w, r, fmt.Sprintf("/question/%d", question.ID), http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(question); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
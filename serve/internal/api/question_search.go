@@ -0,0 +1,223 @@
+package api
+
+import (
+	"encoding/json"
+	"html"
+	"log"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+	"goera/serve/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// Markers around the matched words of a snippet until it is HTML escaped.
+// They are private use characters, which statements do not contain.
+const (
+	snippetStart = "\uE000"
+	snippetStop  = "\uE001"
+)
+
+// snippetLength is about how many characters of a statement a snippet shows
+const snippetLength = 200
+
+// tsQuery parses a search the way web search engines do: words are all
+// required, "quoted phrases" match in order, or and -word work too
+const tsQuery = "websearch_to_tsquery('english', ?)"
+
+// QuestionSearchResult is a question found by a search
+type QuestionSearchResult struct {
+	models.Question
+	Rank    float64 `json:"rank"`    // Relevance on PostgreSQL, higher first; 0 on other databases
+	Snippet string  `json:"snippet"` // HTML escaped excerpt of the statement with the matched words in <mark>
+}
+
+// QuestionSearchHandler handles requests to /api/questions/search
+func QuestionSearchHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		searchQuestions(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// searchQuestions finds the questions the caller can see whose title, tags or
+// statement match q, most relevant first. PostgreSQL ranks them with its
+// full-text search; other databases, such as SQLite in development, match
+// every word with LIKE and list title matches first.
+func searchQuestions(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	_, isAnonymous := auth.AnonymousSessionFromContext(r.Context())
+	if !userExists && !isAnonymous {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		apierror.Write(w, r, "Search query q is required", http.StatusBadRequest)
+		return
+	}
+	pagination := utils.ParsePagination(r, "questions")
+	if pagination.Cursor {
+		apierror.Write(w, r, "Search results are ranked and do not support cursor paging", http.StatusBadRequest)
+		return
+	}
+
+	query, _, err := visibleQuestions(db, userID, userExists)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+		return
+	}
+
+	postgres := db.Dialector.Name() == "postgres"
+	var matches *gorm.DB
+	if postgres {
+		matches = query.Model(&models.Question{}).Where("search_vector @@ "+tsQuery, q)
+	} else {
+		matches = likeMatches(query.Model(&models.Question{}), strings.Fields(q))
+	}
+	matches = matches.Session(&gorm.Session{})
+
+	var total int64
+	if err := matches.Count(&total).Error; err != nil {
+		log.Printf("Database error counting search results: %v", err)
+		apierror.Write(w, r, "Failed to search questions", http.StatusInternalServerError)
+		return
+	}
+
+	results := []QuestionSearchResult{}
+	if postgres {
+		headlineOptions := "StartSel=" + snippetStart + ", StopSel=" + snippetStop + ", MaxWords=35, MinWords=15, MaxFragments=2"
+		err = matches.
+			Select("questions.*, ts_rank(search_vector, "+tsQuery+") AS rank, ts_headline('english', content, "+tsQuery+", ?) AS snippet", q, q, headlineOptions).
+			Order("rank DESC, questions.id ASC").
+			Limit(pagination.PageSize).Offset(pagination.Offset()).
+			Find(&results).Error
+	} else {
+		first := "%" + strings.Fields(q)[0] + "%"
+		err = matches.
+			Select("questions.*, 0 AS rank, '' AS snippet").
+			Order(gorm.Expr("CASE WHEN title LIKE ? THEN 0 ELSE 1 END, questions.id ASC", first)).
+			Limit(pagination.PageSize).Offset(pagination.Offset()).
+			Find(&results).Error
+	}
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to search questions", http.StatusInternalServerError)
+		return
+	}
+
+	for i := range results {
+		if !postgres {
+			results[i].Snippet = likeSnippet(results[i].Content, strings.Fields(q))
+		}
+		results[i].Snippet = highlightSnippet(results[i].Snippet)
+	}
+
+	response := PaginatedResponse{
+		Data:       results,
+		Page:       pagination.Page,
+		PageSize:   pagination.PageSize,
+		TotalItems: total,
+		TotalPages: utils.TotalPages(total, pagination.PageSize),
+	}
+	utils.SetPageLinks(w, r, pagination, response.TotalPages)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// likeMatches narrows query to the questions containing every word in their
+// title, tags or statement
+func likeMatches(query *gorm.DB, words []string) *gorm.DB {
+	for _, word := range words {
+		pattern := "%" + word + "%"
+		query = query.Where("title LIKE ? OR tags LIKE ? OR content LIKE ?", pattern, pattern, pattern)
+	}
+	return query
+}
+
+// likeSnippet cuts the part of content around the first of words it
+// contains, marking every word in it like ts_headline does
+func likeSnippet(content string, words []string) string {
+	lower := strings.ToLower(content)
+	start := -1
+	for _, word := range words {
+		if i := strings.Index(lower, strings.ToLower(word)); i >= 0 && (start < 0 || i < start) {
+			start = i
+		}
+	}
+	match := max(start, 0)
+	start = max(match-snippetLength/4, 0)
+	if start > 0 {
+		// Start at a word rather than in the middle of one
+		if space := strings.IndexByte(content[start:match], ' '); space >= 0 {
+			start += space + 1
+		}
+	}
+	for start > 0 && !utf8.RuneStart(content[start]) {
+		start--
+	}
+	end := min(start+snippetLength, len(content))
+	for end < len(content) && !utf8.RuneStart(content[end]) {
+		end++
+	}
+
+	snippet := content[start:end]
+	lowerSnippet := strings.ToLower(snippet)
+	var b strings.Builder
+	for i := 0; i < len(snippet); {
+		matched := ""
+		for _, word := range words {
+			// Lowercasing can change the length of some characters, so only
+			// mark matches whose position carries over
+			if len(lowerSnippet) == len(snippet) && strings.HasPrefix(lowerSnippet[i:], strings.ToLower(word)) && len(word) > len(matched) {
+				matched = snippet[i : i+len(word)]
+			}
+		}
+		if matched != "" {
+			b.WriteString(snippetStart + matched + snippetStop)
+			i += len(matched)
+			continue
+		}
+		b.WriteByte(snippet[i])
+		i++
+	}
+
+	result := b.String()
+	if start > 0 {
+		result = "..." + result
+	}
+	if end < len(content) {
+		result += "..."
+	}
+	return result
+}
+
+// highlightSnippet HTML escapes a snippet and turns its markers into <mark>
+// elements, so it can be shown as is
+func highlightSnippet(snippet string) string {
+	snippet = html.EscapeString(snippet)
+	snippet = strings.ReplaceAll(snippet, snippetStart, "<mark>")
+	return strings.ReplaceAll(snippet, snippetStop, "</mark>")
+}
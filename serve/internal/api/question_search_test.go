@@ -0,0 +1,73 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"goera/serve/internal/api"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/config"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+)
+
+// TestQuestionSearch searches questions on in-memory SQLite, through the LIKE
+// fallback used where PostgreSQL's full text search is not available
+func TestQuestionSearch(t *testing.T) {
+	t.Setenv("DB_DRIVER", "sqlite")
+	t.Setenv("DB_PATH", ":memory:")
+	// Each connection to :memory: opens a database of its own
+	t.Setenv("DB_MAX_OPEN_CONNS", "1")
+	t.Setenv("DB_MAX_IDLE_CONNS", "1")
+	t.Setenv("ANONYMOUS_PRACTICE", "true")
+	if err := config.Init(); err != nil {
+		t.Fatalf("config: %v", err)
+	}
+	if err := database.InitDB(); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	defer database.CloseDB()
+
+	db := database.GetDB()
+	author := models.User{Username: "author", Role: models.RegularRole}
+	if err := db.Create(&author).Error; err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+	questions := []models.Question{
+		{Title: "Sum of pairs", Content: "Find two numbers adding up to a target.", Published: true, UserID: author.ID},
+		{Title: "Matrix paths", Content: "Count the paths; print the sum modulo a prime.", Published: true, UserID: author.ID},
+		{Title: "Sum of digits", Content: "Not published yet.", UserID: author.ID},
+	}
+	if err := db.Create(&questions).Error; err != nil {
+		t.Fatalf("creating questions: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/questions/search?q=sum", nil)
+	rec := httptest.NewRecorder()
+	auth.Middleware(http.HandlerFunc(api.QuestionSearchHandler)).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("search returned %d: %s", rec.Code, rec.Body)
+	}
+
+	var response struct {
+		Data []api.QuestionSearchResult `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	// Title matches come first and unpublished questions are left out
+	want := []string{"Sum of pairs", "Matrix paths"}
+	if len(response.Data) != len(want) {
+		t.Fatalf("got %d results, want %d: %+v", len(response.Data), len(want), response.Data)
+	}
+	for i, title := range want {
+		if response.Data[i].Title != title {
+			t.Errorf("result %d is %q, want %q", i, response.Data[i].Title, title)
+		}
+	}
+	if response.Data[1].Snippet == "" {
+		t.Errorf("no snippet for a statement match")
+	}
+}
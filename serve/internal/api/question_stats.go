@@ -0,0 +1,267 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// QuestionStats summarizes the submissions made to a question
+type QuestionStats struct {
+	QuestionID           uint                         `json:"questionId"`
+	TotalSubmissions     int64                        `json:"totalSubmissions"`
+	AcceptedSubmissions  int64                        `json:"acceptedSubmissions"`
+	UniqueSolvers        int64                        `json:"uniqueSolvers"`
+	AcceptanceRate       float64                      `json:"acceptanceRate"` // Percentage of judged submissions that were accepted
+	VerdictDistribution  map[models.JudgeStatus]int64 `json:"verdictDistribution"`
+	AverageExecutionTime float64                      `json:"averageExecutionTime"` // Milliseconds, over judged submissions
+	// The slowest and most memory hungry accepted submissions, to calibrate
+	// the limits against
+	MaxAcceptedExecutionTime int `json:"maxAcceptedExecutionTime"` // Milliseconds
+	MaxAcceptedMemoryUsage   int `json:"maxAcceptedMemoryUsage"`   // Megabytes
+}
+
+// QuestionStatsHandler handles requests to /api/questions/{id}/stats
+func QuestionStatsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getQuestionStats(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// pendingStatuses are the verdicts of submissions that have not been judged yet
+var pendingStatuses = []models.JudgeStatus{models.Pending, models.Judging}
+
+// acceptanceRate returns the percentage of judged submissions that were accepted
+func acceptanceRate(accepted, judged int64) float64 {
+	if judged == 0 {
+		return 0
+	}
+	return float64(accepted) * 100 / float64(judged)
+}
+
+// loadAcceptanceStats fills in the submission count and acceptance rate of
+// each question with a single grouped query
+func loadAcceptanceStats(db *gorm.DB, questions []models.Question) error {
+	if len(questions) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(questions))
+	for i, q := range questions {
+		ids[i] = q.ID
+	}
+
+	var rows []struct {
+		QuestionID uint
+		Total      int64
+		Judged     int64
+		Accepted   int64
+	}
+	err := db.Model(&models.Submission{}).
+		Select("question_id, COUNT(*) AS total, "+
+			"SUM(CASE WHEN judge_status NOT IN ? THEN 1 ELSE 0 END) AS judged, "+
+			"SUM(CASE WHEN judge_status = ? THEN 1 ELSE 0 END) AS accepted",
+			pendingStatuses, models.Accepted).
+		Where("question_id IN ?", ids).
+		Group("question_id").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	byQuestion := make(map[uint]int, len(questions))
+	for i, q := range questions {
+		byQuestion[q.ID] = i
+	}
+	for _, row := range rows {
+		q := &questions[byQuestion[row.QuestionID]]
+		q.TotalSubmissions = row.Total
+		q.AcceptanceRate = acceptanceRate(row.Accepted, row.Judged)
+	}
+	return nil
+}
+
+// loadUserStatuses fills in whether userID solved, attempted or has not tried
+// each of the listed questions, with a single query over the user's submissions
+func loadUserStatuses(db *gorm.DB, questions []models.Question, userID uint) error {
+	if len(questions) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(questions))
+	for i, q := range questions {
+		ids[i] = q.ID
+	}
+
+	var rows []struct {
+		QuestionID uint
+		Solved     int64
+	}
+	err := db.Model(&models.Submission{}).
+		Select("question_id, MAX(CASE WHEN judge_status = ? THEN 1 ELSE 0 END) AS solved", models.Accepted).
+		Where("user_id = ? AND question_id IN ?", userID, ids).
+		Group("question_id").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	statuses := make(map[uint]models.UserQuestionStatus, len(rows))
+	for _, row := range rows {
+		if row.Solved > 0 {
+			statuses[row.QuestionID] = models.UserSolved
+		} else {
+			statuses[row.QuestionID] = models.UserAttempted
+		}
+	}
+	for i := range questions {
+		status, ok := statuses[questions[i].ID]
+		if !ok {
+			status = models.UserUntried
+		}
+		questions[i].UserStatus = status
+	}
+	return nil
+}
+
+// getQuestionStats returns submission statistics for a question to anyone who can view it
+func getQuestionStats(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid question ID", http.StatusBadRequest)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	_, isAnonymous := auth.AnonymousSessionFromContext(r.Context())
+	if !userExists && !isAnonymous {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var question models.Question
+	if err := db.First(&question, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Question not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve question", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Problems of a running contest are hidden from everyone but its
+	// participants, like the question itself
+	canManage := false
+	if userExists {
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+			return
+		}
+		canManage = canEditQuestion(db, &question, &user)
+	}
+	if !canManage {
+		visible, err := visibleToReader(db, &question, userID)
+		if err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve contest", http.StatusInternalServerError)
+			return
+		}
+		if !visible {
+			apierror.Write(w, r, "Question not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	stats := QuestionStats{
+		QuestionID:          question.ID,
+		VerdictDistribution: map[models.JudgeStatus]int64{},
+	}
+
+	var verdicts []struct {
+		JudgeStatus models.JudgeStatus
+		Count       int64
+	}
+	if err := db.Model(&models.Submission{}).
+		Select("judge_status, COUNT(*) AS count").
+		Where("question_id = ?", question.ID).
+		Group("judge_status").
+		Scan(&verdicts).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to compute statistics", http.StatusInternalServerError)
+		return
+	}
+
+	var judged int64
+	for _, v := range verdicts {
+		stats.VerdictDistribution[v.JudgeStatus] = v.Count
+		stats.TotalSubmissions += v.Count
+		if v.JudgeStatus != models.Pending && v.JudgeStatus != models.Judging {
+			judged += v.Count
+		}
+	}
+	stats.AcceptedSubmissions = stats.VerdictDistribution[models.Accepted]
+	stats.AcceptanceRate = acceptanceRate(stats.AcceptedSubmissions, judged)
+
+	if err := db.Model(&models.Submission{}).
+		Where("question_id = ? AND judge_status = ?", question.ID, models.Accepted).
+		Distinct("user_id").
+		Count(&stats.UniqueSolvers).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to compute statistics", http.StatusInternalServerError)
+		return
+	}
+
+	var avg struct{ Average float64 }
+	if err := db.Model(&models.Submission{}).
+		Select("COALESCE(AVG(execution_time), 0) AS average").
+		Where("question_id = ? AND judge_status NOT IN ?", question.ID, pendingStatuses).
+		Scan(&avg).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to compute statistics", http.StatusInternalServerError)
+		return
+	}
+	stats.AverageExecutionTime = avg.Average
+
+	var accepted struct{ Time, Memory int }
+	if err := db.Model(&models.Submission{}).
+		Select("COALESCE(MAX(execution_time), 0) AS time, COALESCE(MAX(memory_usage), 0) AS memory").
+		Where("question_id = ? AND judge_status = ?", question.ID, models.Accepted).
+		Scan(&accepted).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to compute statistics", http.StatusInternalServerError)
+		return
+	}
+	stats.MaxAcceptedExecutionTime = accepted.Time
+	stats.MaxAcceptedMemoryUsage = accepted.Memory
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,229 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/config"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// stressBatchSize is how many cases are sent to the judge at once. A stress
+// test stops after the batch with the first mismatch.
+const stressBatchSize = 50
+
+// StressTestRequest compares a brute-force solution with a candidate on
+// random inputs. The generator and its spec default to the question's
+// generator, and the candidate to its reference solution. A zero Seed picks
+// a random one.
+type StressTestRequest struct {
+	Generator  string `json:"generator"`
+	Spec       string `json:"spec"`
+	BruteForce string `json:"bruteForce" validate:"required"`
+	Candidate  string `json:"candidate"`
+	Count      int    `json:"count" validate:"min=1,max=1000"`
+	Seed       uint64 `json:"seed"`
+}
+
+// StressMismatch is the first case on which the candidate disagreed with the
+// brute force. Status is set instead of Output when the candidate failed,
+// e.g. with a runtime error, and Log then has its judge output.
+type StressMismatch struct {
+	Case     int    `json:"case"` // 1-based
+	Input    string `json:"input"`
+	Expected string `json:"expected"` // What the brute force printed
+	Output   string `json:"output,omitempty"`
+	Status   Result `json:"status,omitempty"`
+	Log      string `json:"log,omitempty"`
+}
+
+// StressTestResult reports a stress test. Running it again with the same seed
+// and programs gives the same cases.
+type StressTestResult struct {
+	Seed     uint64          `json:"seed"`
+	Cases    int             `json:"cases"` // Cases run, up to the mismatch
+	Mismatch *StressMismatch `json:"mismatch,omitempty"`
+}
+
+// QuestionStressHandler handles requests to /api/questions/{id}/stress
+func QuestionStressHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		stressTestQuestion(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// stressTestQuestion runs a generator, a brute-force solution and a candidate
+// solution in the sandbox on up to Count random cases and reports the first
+// input on which the two solutions print different answers, so authors find
+// counterexamples before publishing. Nothing is stored. The generator and the
+// brute force run with the deployment's maximum limits, the candidate with
+// the question's.
+func stressTestQuestion(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, int64(config.MaxSourceCodeBytes)*6+int64(maxGeneratorSpecBytes)*2+4096)
+
+	var stressReq StressTestRequest
+	if err := json.NewDecoder(r.Body).Decode(&stressReq); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+	if !validRequest(w, r, &stressReq) {
+		return
+	}
+	if len(stressReq.Spec) > maxGeneratorSpecBytes {
+		apierror.Write(w, r, fmt.Sprintf("Spec exceeds the maximum size of %d bytes", maxGeneratorSpecBytes), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	question, userID, ok := loadEditableQuestion(w, r, db)
+	if !ok {
+		return
+	}
+
+	generator := models.QuestionGenerator{SourceCode: stressReq.Generator, Spec: stressReq.Spec}
+	if strings.TrimSpace(generator.SourceCode) == "" {
+		var saved models.QuestionGenerator
+		if err := db.Where("question_id = ?", question.ID).First(&saved).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				apierror.Write(w, r, "A generator is required, as the question has none", http.StatusBadRequest)
+			} else {
+				log.Printf("Database error: %v", err)
+				apierror.Write(w, r, "Failed to retrieve generator", http.StatusInternalServerError)
+			}
+			return
+		}
+		generator.SourceCode = saved.SourceCode
+		if generator.Spec == "" {
+			generator.Spec = saved.Spec
+		}
+	}
+	candidate := stressReq.Candidate
+	if strings.TrimSpace(candidate) == "" {
+		candidate = question.ReferenceSolution
+	}
+	if strings.TrimSpace(candidate) == "" {
+		apierror.Write(w, r, "A candidate solution is required, as the question has no reference solution", http.StatusBadRequest)
+		return
+	}
+	for _, source := range []string{generator.SourceCode, stressReq.BruteForce, candidate} {
+		if len(source) > config.MaxSourceCodeBytes {
+			apierror.Write(w, r, fmt.Sprintf("Source code exceeds the maximum size of %d bytes", config.MaxSourceCodeBytes), http.StatusRequestEntityTooLarge)
+			return
+		}
+	}
+
+	seed := stressReq.Seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	seeds := rand.New(rand.NewPCG(seed, seed))
+
+	maxLimits := jobLimits(config.MaxTimeLimitMs, config.MaxMemoryLimitMB, defaultLanguage)
+	timeLimit, memoryLimit := question.TimeLimit, question.MemoryLimit
+	if timeLimit <= 0 {
+		timeLimit = config.MaxTimeLimitMs
+	}
+	if memoryLimit <= 0 {
+		memoryLimit = config.MaxMemoryLimitMB
+	}
+	candidateLimits := jobLimits(timeLimit, memoryLimit, defaultLanguage)
+
+	result := StressTestResult{Seed: seed}
+	for result.Cases < stressReq.Count && result.Mismatch == nil {
+		batch := make([]string, min(stressBatchSize, stressReq.Count-result.Cases))
+		for i := range batch {
+			batch[i] = fmt.Sprintf("%d\n%s", seeds.Int64(), generator.Spec)
+		}
+
+		generated, err := runProgram(generator.SourceCode, batch, maxLimits)
+		if !stressStepOK(w, r, question.ID, "Generator failed with %s:\n%s", generated, err, len(batch)) {
+			return
+		}
+		inputs := make([]string, len(generated.Outputs))
+		for i, output := range generated.Outputs {
+			// A question's generator also prints the expected output
+			if input, _, ok := splitGeneratedTest(output); ok {
+				output = input
+			}
+			inputs[i] = output
+		}
+
+		expected, err := runProgram(stressReq.BruteForce, inputs, maxLimits)
+		if !stressStepOK(w, r, question.ID, "Brute-force solution failed with %s:\n%s", expected, err, len(inputs)) {
+			return
+		}
+
+		actual, err := runProgram(candidate, inputs, candidateLimits)
+		if err != nil {
+			log.Printf("Failed to stress test question %d: %v", question.ID, err)
+			apierror.Write(w, r, "Judge service could not run the stress test", http.StatusServiceUnavailable)
+			return
+		}
+		if actual.Status == CompileError {
+			apierror.Write(w, r, fmt.Sprintf("Candidate solution failed with %s:\n%s", actual.Status, actual.Output), http.StatusUnprocessableEntity)
+			return
+		}
+
+		for i, input := range inputs {
+			mismatch := StressMismatch{Case: result.Cases + i + 1, Input: input, Expected: expected.Outputs[i]}
+			if i >= len(actual.Outputs) {
+				// The candidate's runs stopped at the one that failed
+				mismatch.Status, mismatch.Log = actual.Status, actual.Output
+				result.Mismatch = &mismatch
+			} else if strings.TrimSpace(actual.Outputs[i]) != strings.TrimSpace(expected.Outputs[i]) {
+				mismatch.Output = actual.Outputs[i]
+				result.Mismatch = &mismatch
+			}
+			if result.Mismatch != nil {
+				result.Cases = mismatch.Case
+				break
+			}
+		}
+		if result.Mismatch == nil {
+			result.Cases += len(inputs)
+		}
+	}
+
+	log.Printf("Stress tested question %d by user %d: %d cases, mismatch: %t", question.ID, userID, result.Cases, result.Mismatch != nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// stressStepOK checks that the generator or brute force of a stress test ran
+// on all count inputs. Otherwise it writes the error response, failure
+// formatting the verdict and judge output of a program that failed, and
+// returns false.
+func stressStepOK(w http.ResponseWriter, r *http.Request, questionID uint, failure string, result *GenerateResult, err error, count int) bool {
+	if err != nil {
+		log.Printf("Failed to stress test question %d: %v", questionID, err)
+		apierror.Write(w, r, "Judge service could not run the stress test", http.StatusServiceUnavailable)
+		return false
+	}
+	if result.Status != Accepted || len(result.Outputs) != count {
+		apierror.Write(w, r, fmt.Sprintf(failure, result.Status, result.Output), http.StatusUnprocessableEntity)
+		return false
+	}
+	return true
+}
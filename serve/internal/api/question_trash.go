@@ -0,0 +1,179 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// QuestionTrashHandler handles requests to /api/questions/trash
+func QuestionTrashHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getDeletedQuestions(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// RestoreQuestionHandler handles requests to /api/questions/{id}/restore
+func RestoreQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPut, http.MethodPost:
+		restoreQuestion(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// softDeleteQuestion marks a question and everything hanging off it as deleted
+// inside a single transaction. All rows share the question's deletion time so
+// restoreQuestion can bring back exactly the rows removed by this cascade.
+func softDeleteQuestion(db *gorm.DB, question *models.Question) error {
+	now := time.Now()
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.TestCase{}).
+			Where("question_id = ?", question.ID).
+			UpdateColumn("deleted_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Submission{}).
+			Where("question_id = ?", question.ID).
+			UpdateColumn("deleted_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(question).UpdateColumn("deleted_at", now).Error
+	})
+}
+
+// getDeletedQuestions lists soft-deleted questions for administrators
+func getDeletedQuestions(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+		return
+	}
+
+	if user.Role != models.AdminRole {
+		apierror.Write(w, r, "Only administrators can view deleted questions", http.StatusForbidden)
+		return
+	}
+
+	var questions []models.Question
+	result := db.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&questions)
+	if result.Error != nil {
+		log.Printf("Database error: %v", result.Error)
+		apierror.Write(w, r, "Failed to retrieve deleted questions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(questions); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// restoreQuestion undoes a soft delete, bringing back the test cases and
+// submissions that were removed together with the question
+func restoreQuestion(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid question ID", http.StatusBadRequest)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+		return
+	}
+
+	if user.Role != models.AdminRole {
+		apierror.Write(w, r, "Only administrators can restore questions", http.StatusForbidden)
+		return
+	}
+
+	var question models.Question
+	if err := db.Unscoped().Where("deleted_at IS NOT NULL").First(&question, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Deleted question not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve question", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	deletedAt := question.DeletedAt.Time
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&models.TestCase{}).
+			Where("question_id = ? AND deleted_at = ?", question.ID, deletedAt).
+			UpdateColumn("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&models.Submission{}).
+			Where("question_id = ? AND deleted_at = ?", question.ID, deletedAt).
+			UpdateColumn("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Model(&question).UpdateColumn("deleted_at", nil).Error
+	})
+	if err != nil {
+		log.Printf("Failed to restore question %d: %v", question.ID, err)
+		apierror.Write(w, r, "Failed to restore question", http.StatusInternalServerError)
+		return
+	}
+
+	question.DeletedAt = gorm.DeletedAt{}
+	invalidateQuestion(r.Context(), question.ID)
+	audit(db, user.ID, models.AuditQuestionRestored, "question", question.ID, question.Title)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(question); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"goera/serve/internal/config"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// StartSubmissionReaper periodically recovers submissions that never received a
+// verdict, e.g. because a runner crashed mid-test. It blocks, so run it in its
+// own goroutine.
+func StartSubmissionReaper() {
+	ticker := time.NewTicker(config.SubmissionReaperInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		db := database.GetDB()
+		if db == nil {
+			log.Println("Submission reaper: database connection is nil")
+			continue
+		}
+		reapStuckSubmissions(db)
+	}
+}
+
+// reapStuckSubmissions requeues submissions stuck beyond the configured timeout,
+// or marks them as a system error once they used up their judge attempts.
+// Submissions waiting in the judge outbox are not stuck, only undelivered.
+func reapStuckSubmissions(db *gorm.DB) {
+	cutoff := time.Now().Add(-config.SubmissionStuckTimeout)
+
+	var stuck []models.Submission
+	err := db.Where("judge_status IN ? AND updated_at < ?", []models.JudgeStatus{models.Pending, models.Judging}, cutoff).
+		Where("id NOT IN (?)", db.Model(&models.JudgeOutbox{}).Select("submission_id")).
+		Order("id").
+		Find(&stuck).Error
+	if err != nil {
+		log.Printf("Submission reaper: failed to find stuck submissions: %v", err)
+		return
+	}
+
+	for i := range stuck {
+		submission := &stuck[i]
+		stuckFor := time.Since(submission.UpdatedAt).Round(time.Second)
+
+		if submission.JudgeAttempts < config.MaxJudgeAttempts {
+			log.Printf("Submission reaper: requeueing submission %d, stuck in %s for %s (attempt %d of %d)",
+				submission.ID, submission.JudgeStatus, stuckFor, submission.JudgeAttempts+1, config.MaxJudgeAttempts)
+			if _, err := enqueueSubmission(db, submission.ID, 0); err != nil {
+				log.Printf("Submission reaper: failed to requeue submission %d: %v", submission.ID, err)
+				continue
+			}
+			recordSubmissionEvent(db, submission.ID, "serve", models.EventReaped,
+				fmt.Sprintf("Stuck in %s for %s, sending to the judge again", submission.JudgeStatus, stuckFor))
+			continue
+		}
+
+		log.Printf("Submission reaper: marking submission %d as failed after %d judge attempts, stuck in %s for %s",
+			submission.ID, submission.JudgeAttempts, submission.JudgeStatus, stuckFor)
+		recordSubmissionEvent(db, submission.ID, "serve", models.EventReaped,
+			fmt.Sprintf("Stuck in %s for %s after %d judge attempts, marked as failed", submission.JudgeStatus, stuckFor, submission.JudgeAttempts))
+
+		submission.JudgeStatus = models.SystemError
+		submission.Error = "Judging did not finish. Please submit again."
+		if err := db.Save(submission).Error; err != nil {
+			log.Printf("Submission reaper: failed to update submission %d: %v", submission.ID, err)
+			continue
+		}
+		publishSubmission(submission)
+		sendVerdictWebhooks(db, submission)
+	}
+}
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/config"
+	"goera/serve/internal/database"
+	"goera/serve/internal/internalpb"
+	"goera/serve/internal/models"
+	"goera/serve/internal/utils"
+)
+
+// referenceSolutionTimeout bounds the judge run that checks a reference
+// solution, which goes through every test case of the question
+const referenceSolutionTimeout = 10 * time.Minute
+
+// ReferenceSolutionRequest represents the request body for setting a question's
+// reference solution. An empty solution removes it.
+type ReferenceSolutionRequest struct {
+	ReferenceSolution string `json:"referenceSolution"`
+}
+
+// ReferenceSolutionResponse is a question's reference solution, shown to its editors
+type ReferenceSolutionResponse struct {
+	QuestionID        uint   `json:"questionId"`
+	ReferenceSolution string `json:"referenceSolution"`
+}
+
+// ReferenceSolutionHandler handles requests to /api/questions/{id}/reference-solution
+func ReferenceSolutionHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getReferenceSolution(w, r)
+	case http.MethodPut, http.MethodPost:
+		updateReferenceSolution(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// referenceSolutionError is returned when a reference solution is not
+// accepted on its question's test cases
+type referenceSolutionError struct {
+	Status  models.JudgeStatus
+	Verdict string // The last test verdict, e.g. "Test 3/10: WrongAnswer"
+}
+
+func (e *referenceSolutionError) Error() string {
+	if e.Verdict != "" {
+		return fmt.Sprintf("Reference solution failed on %s", e.Verdict)
+	}
+	return fmt.Sprintf("Reference solution failed with %s", e.Status)
+}
+
+// validateReferenceSolution runs a reference solution against all the given
+// test cases on the judge with the question's limits and build flags. It returns a
+// *referenceSolutionError when the solution is not accepted, and other errors
+// when the judge could not run it.
+func validateReferenceSolution(solution string, testCases []models.TestCase, timeLimitMs, memoryLimitMB int, buildFlags []string) error {
+	pbTestCases, err := testCasesProto(testCases)
+	if err != nil {
+		return fmt.Errorf("failed to link test data: %w", err)
+	}
+	result, err := runOnJudge(&internalpb.Job{
+		SourceCode: solution,
+		TestCases:  pbTestCases,
+		Limits:     jobLimits(timeLimitMs, memoryLimitMB, defaultLanguage),
+		BuildFlags: buildFlags,
+	}, referenceSolutionTimeout)
+	if err != nil {
+		return err
+	}
+
+	if result.Status == models.JudgeStatus(Accepted) {
+		return nil
+	}
+
+	failure := &referenceSolutionError{Status: result.Status}
+	for _, event := range result.Events {
+		if event.Type == models.EventTestVerdict {
+			failure.Verdict = event.Message
+		}
+	}
+	return failure
+}
+
+// writeReferenceSolutionError reports why a question could not be published
+// after checking its reference solution
+func writeReferenceSolutionError(w http.ResponseWriter, r *http.Request, questionID uint, err error) {
+	var failure *referenceSolutionError
+	if errors.As(err, &failure) {
+		if utils.IsFormRequest(r) {
+			http.Redirect(w, r, fmt.Sprintf("/question/%d?error=reference_solution_failed", questionID), http.StatusSeeOther)
+			return
+		}
+		apierror.Write(w, r, failure.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	log.Printf("Failed to check reference solution of question %d: %v", questionID, err)
+	if utils.IsFormRequest(r) {
+		http.Redirect(w, r, fmt.Sprintf("/question/%d?error=reference_solution_unchecked", questionID), http.StatusSeeOther)
+		return
+	}
+	apierror.Write(w, r, "Judge service could not check the reference solution", http.StatusServiceUnavailable)
+}
+
+// getReferenceSolution returns a question's reference solution to its editors
+func getReferenceSolution(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	question, _, ok := loadEditableQuestion(w, r, db)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ReferenceSolutionResponse{
+		QuestionID:        question.ID,
+		ReferenceSolution: question.ReferenceSolution,
+	}); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// updateReferenceSolution sets or removes a question's reference solution.
+// It is only checked when the question is published.
+func updateReferenceSolution(w http.ResponseWriter, r *http.Request) {
+	var solutionReq ReferenceSolutionRequest
+
+	formProcessor := func(r *http.Request) (interface{}, error) {
+		return ReferenceSolutionRequest{ReferenceSolution: r.FormValue("referenceSolution")}, nil
+	}
+
+	result, err := utils.ProcessRequestData(r, &solutionReq, formProcessor)
+	if err != nil {
+		writeRequestDataError(w, r, err)
+		return
+	}
+
+	if formData, ok := result.(ReferenceSolutionRequest); ok {
+		solutionReq = formData
+	}
+
+	if len(solutionReq.ReferenceSolution) > config.MaxSourceCodeBytes {
+		apierror.Write(w, r, fmt.Sprintf("Source code exceeds the maximum size of %d bytes", config.MaxSourceCodeBytes), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	question, _, ok := loadEditableQuestion(w, r, db)
+	if !ok {
+		return
+	}
+
+	question.ReferenceSolution = solutionReq.ReferenceSolution
+	if err := db.Model(question).Select("ReferenceSolution").Updates(question).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to update reference solution", http.StatusInternalServerError)
+		return
+	}
+
+	if utils.IsFormRequest(r) {
+		http.Redirect(w, r, fmt.Sprintf("/edit/%d#reference_solution", question.ID), http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ReferenceSolutionResponse{
+		QuestionID:        question.ID,
+		ReferenceSolution: question.ReferenceSolution,
+	}); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
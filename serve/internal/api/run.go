@@ -0,0 +1,188 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/config"
+	"goera/serve/internal/database"
+	"goera/serve/internal/internalpb"
+	"goera/serve/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// RunResult is the verdict of a practice run against a question's samples
+type RunResult struct {
+	Status models.JudgeStatus `json:"status"`
+	Output string             `json:"output"`
+}
+
+// judgeRunResponse is the judge's answer to a synchronous run
+type judgeRunResponse struct {
+	Status models.JudgeStatus
+	Output string
+	Events []SubmissionEventRequest
+}
+
+// RunHandler handles requests to /api/run
+func RunHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		runSamples(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// sampleTestCases returns the test cases shown as examples on the question page.
+// Practice runs are limited to these so hidden tests are never exposed.
+func sampleTestCases(testCases []models.TestCase) []models.TestCase {
+	var samples []models.TestCase
+	for _, tc := range testCases {
+		if tc.Sample {
+			samples = append(samples, tc)
+		}
+	}
+	return samples
+}
+
+// runSamples runs code against a question's samples without storing a submission.
+// Available to logged in users and to anonymous practice sessions.
+func runSamples(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, int64(config.MaxSourceCodeBytes)*2+4096)
+
+	var runReq SubmissionRequest
+	if err := json.NewDecoder(r.Body).Decode(&runReq); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+	if !validRequest(w, r, &runReq) {
+		return
+	}
+
+	if len(runReq.Code) > config.MaxSourceCodeBytes {
+		apierror.Write(w, r, fmt.Sprintf("Source code exceeds the maximum size of %d bytes", config.MaxSourceCodeBytes), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	_, isAnonymous := auth.AnonymousSessionFromContext(r.Context())
+	if !userExists && !isAnonymous {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	var question models.Question
+	if err := db.Preload("TestCases").First(&question, runReq.QuestionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Question not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve question", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Problems of a running contest are hidden from everyone but its
+	// participants, like the question itself
+	canManage := false
+	if userExists {
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+			return
+		}
+		canManage = canEditQuestion(db, &question, &user)
+	}
+	if !canManage {
+		visible, err := visibleToReader(db, &question, userID)
+		if err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve contest", http.StatusInternalServerError)
+			return
+		}
+		if !visible {
+			apierror.Write(w, r, "Question not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	if !question.AllowsLanguage(runReq.Language) {
+		apierror.Write(w, r, fmt.Sprintf("Language %q is not allowed for this question", runReq.Language), http.StatusBadRequest)
+		return
+	}
+
+	samples := sampleTestCases(question.TestCases)
+	if len(samples) == 0 {
+		apierror.Write(w, r, "Question has no sample test cases", http.StatusBadRequest)
+		return
+	}
+
+	testCases, err := testCasesProto(samples)
+	if err != nil {
+		log.Printf("Storage error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve test cases", http.StatusInternalServerError)
+		return
+	}
+
+	// Practice runs have no submission ID; the judge answers synchronously
+	job := &internalpb.Job{
+		SourceCode: runReq.Code,
+		TestCases:  testCases,
+		Limits:     jobLimits(question.TimeLimit, question.MemoryLimit, runReq.Language),
+		BuildFlags: question.BuildFlagList(),
+	}
+
+	judgeResult, err := runOnJudge(job, 60*time.Second)
+	if err != nil {
+		log.Printf("Failed to run practice run on judge: %v", err)
+		apierror.Write(w, r, "Judge service could not run the code", http.StatusServiceUnavailable)
+		return
+	}
+	runResult := RunResult{Status: judgeResult.Status, Output: judgeResult.Output}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(runResult); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// runOnJudge judges a job synchronously on the judge, without storing a
+// submission
+func runOnJudge(job *internalpb.Job, timeout time.Duration) (*judgeRunResponse, error) {
+	client, err := judgeClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create judge client: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	result, err := client.Run(ctx, &internalpb.RunRequest{Job: job})
+	if err != nil {
+		return nil, fmt.Errorf("judge service error: %w", err)
+	}
+
+	return &judgeRunResponse{
+		Status: models.JudgeStatus(verdictResults[result.GetVerdict()]),
+		Output: result.GetOutput(),
+		Events: eventsFromProto(result.GetEvents()),
+	}, nil
+}
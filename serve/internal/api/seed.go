@@ -0,0 +1,380 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"goera/serve/internal/auth"
+	"goera/serve/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// SeedOptions says what `serve seed` creates
+type SeedOptions struct {
+	AdminUsername string
+	AdminPassword string
+	Users         int    // Regular users named user1, user2, ...
+	UserPassword  string // Password of the regular users
+	Submissions   int    // Fake submissions spread over the seeded questions
+	RandomSeed    uint64 // The same seed gives the same submissions
+}
+
+// SeedSummary counts what Seed created. Records that already existed are
+// left alone and not counted.
+type SeedSummary struct {
+	Users       int
+	Questions   int
+	TestCases   int
+	Submissions int
+}
+
+// seedTestCase is an input and its expected output; the first test case of a
+// sample question is its example
+type seedTestCase struct {
+	input, output string
+}
+
+// seedQuestion is a sample question with a Go solution that passes its test
+// cases, used as the code of the accepted fake submissions
+type seedQuestion struct {
+	title      string
+	content    string
+	difficulty models.Difficulty
+	tags       string
+	solution   string
+	testCases  []seedTestCase
+}
+
+var seedQuestions = []seedQuestion{
+	{
+		title:      "Sum of Two Numbers",
+		content:    "Read two integers a and b from a single line and print their sum.\n\n-10^9 <= a, b <= 10^9",
+		difficulty: models.DifficultyEasy,
+		tags:       "math, implementation",
+		solution: `package main
+
+import "fmt"
+
+func main() {
+	var a, b int
+	fmt.Scan(&a, &b)
+	fmt.Println(a + b)
+}
+`,
+		testCases: []seedTestCase{
+			{"1 2\n", "3\n"},
+			{"-5 5\n", "0\n"},
+			{"1000000000 1000000000\n", "2000000000\n"},
+		},
+	},
+	{
+		title:      "Reverse a String",
+		content:    "Read a word of at most 1000 lowercase letters and print it reversed.",
+		difficulty: models.DifficultyEasy,
+		tags:       "strings",
+		solution: `package main
+
+import "fmt"
+
+func main() {
+	var s string
+	fmt.Scan(&s)
+	b := []byte(s)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	fmt.Println(string(b))
+}
+`,
+		testCases: []seedTestCase{
+			{"goera\n", "areog\n"},
+			{"a\n", "a\n"},
+			{"racecar\n", "racecar\n"},
+		},
+	},
+	{
+		title:      "FizzBuzz",
+		content:    "Read n (1 <= n <= 1000) and print the numbers from 1 to n, one per line, printing Fizz instead of multiples of 3, Buzz instead of multiples of 5 and FizzBuzz instead of multiples of both.",
+		difficulty: models.DifficultyEasy,
+		tags:       "implementation",
+		solution: `package main
+
+import "fmt"
+
+func main() {
+	var n int
+	fmt.Scan(&n)
+	for i := 1; i <= n; i++ {
+		switch {
+		case i%15 == 0:
+			fmt.Println("FizzBuzz")
+		case i%3 == 0:
+			fmt.Println("Fizz")
+		case i%5 == 0:
+			fmt.Println("Buzz")
+		default:
+			fmt.Println(i)
+		}
+	}
+}
+`,
+		testCases: []seedTestCase{
+			{"5\n", "1\n2\nFizz\n4\nBuzz\n"},
+			{"1\n", "1\n"},
+			{"15\n", "1\n2\nFizz\n4\nBuzz\nFizz\n7\n8\nFizz\nBuzz\n11\nFizz\n13\n14\nFizzBuzz\n"},
+		},
+	},
+	{
+		title:      "Maximum Subarray",
+		content:    "Read n (1 <= n <= 10^5) and then n integers, each between -10^4 and 10^4. Print the largest sum of a non-empty run of consecutive numbers.",
+		difficulty: models.DifficultyMedium,
+		tags:       "dp, arrays",
+		solution: `package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+)
+
+func main() {
+	in := bufio.NewReader(os.Stdin)
+	var n int
+	fmt.Fscan(in, &n)
+	best, current := -1<<62, 0
+	for i := 0; i < n; i++ {
+		var x int
+		fmt.Fscan(in, &x)
+		current = max(x, current+x)
+		best = max(best, current)
+	}
+	fmt.Println(best)
+}
+`,
+		testCases: []seedTestCase{
+			{"9\n-2 1 -3 4 -1 2 1 -5 4\n", "6\n"},
+			{"1\n-7\n", "-7\n"},
+			{"5\n1 2 3 4 5\n", "15\n"},
+			{"4\n-1 -2 -3 -4\n", "-1\n"},
+		},
+	},
+	{
+		title:      "Shortest Path in a Grid",
+		content:    "Read r and c (1 <= r, c <= 100) and then r lines of c characters, where . is free and # is a wall. Print the fewest steps from the top left to the bottom right corner moving up, down, left or right, or -1 if it cannot be reached.",
+		difficulty: models.DifficultyHard,
+		tags:       "graphs, bfs",
+		solution: `package main
+
+import "fmt"
+
+func main() {
+	var r, c int
+	fmt.Scan(&r, &c)
+	grid := make([]string, r)
+	for i := range grid {
+		fmt.Scan(&grid[i])
+	}
+	dist := make([][]int, r)
+	for i := range dist {
+		dist[i] = make([]int, c)
+		for j := range dist[i] {
+			dist[i][j] = -1
+		}
+	}
+	if grid[0][0] == '#' {
+		fmt.Println(-1)
+		return
+	}
+	dist[0][0] = 0
+	queue := [][2]int{{0, 0}}
+	for len(queue) > 0 {
+		cell := queue[0]
+		queue = queue[1:]
+		for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+			y, x := cell[0]+d[0], cell[1]+d[1]
+			if y < 0 || y >= r || x < 0 || x >= c || grid[y][x] == '#' || dist[y][x] >= 0 {
+				continue
+			}
+			dist[y][x] = dist[cell[0]][cell[1]] + 1
+			queue = append(queue, [2]int{y, x})
+		}
+	}
+	fmt.Println(dist[r-1][c-1])
+}
+`,
+		testCases: []seedTestCase{
+			{"3 3\n...\n.#.\n...\n", "4\n"},
+			{"2 2\n.#\n#.\n", "-1\n"},
+			{"1 1\n.\n", "0\n"},
+		},
+	},
+}
+
+// seedVerdicts are the verdicts of the fake submissions, accepted ones taking
+// the question's solution and the rest a wrong one
+var seedVerdicts = []models.JudgeStatus{
+	models.Accepted, models.Accepted, models.Accepted, models.Accepted,
+	models.Rejected, models.Rejected,
+	models.TimeLimitExceeded,
+	models.RuntimeError,
+	models.CompilationError,
+}
+
+// seedWrongCode is the code of the fake submissions that were not accepted
+const seedWrongCode = `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println(0)
+}
+`
+
+// Seed fills db with an admin, regular users, published sample questions with
+// test cases and fake judged submissions, for development and integration
+// tests. It can be run again: users and questions that exist by name are kept
+// as they are, and submissions are only added to questions it created.
+func Seed(db *gorm.DB, opts SeedOptions) (*SeedSummary, error) {
+	summary := &SeedSummary{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		admin, err := seedUser(tx, opts.AdminUsername, opts.AdminPassword, models.AdminRole, summary)
+		if err != nil {
+			return err
+		}
+
+		users := []models.User{*admin}
+		for i := 1; i <= opts.Users; i++ {
+			user, err := seedUser(tx, fmt.Sprintf("user%d", i), opts.UserPassword, models.RegularRole, summary)
+			if err != nil {
+				return err
+			}
+			users = append(users, *user)
+		}
+
+		now := time.Now()
+		var created []models.Question
+		var solutions []string
+		for _, sample := range seedQuestions {
+			var count int64
+			if err := tx.Model(&models.Question{}).Where("title = ?", sample.title).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				continue
+			}
+
+			question := models.Question{
+				Title:       sample.title,
+				Content:     sample.content,
+				UserID:      admin.ID,
+				Published:   true,
+				PublishedBy: &admin.ID,
+				PublishedAt: &now,
+				Status:      models.QuestionStatusPublished,
+				Difficulty:  sample.difficulty,
+				Tags:        sample.tags,
+				TimeLimit:   1000,
+				MemoryLimit: 256,
+			}
+			if err := tx.Create(&question).Error; err != nil {
+				return fmt.Errorf("creating question %q: %w", sample.title, err)
+			}
+			testCases := make([]models.TestCase, len(sample.testCases))
+			for i, tc := range sample.testCases {
+				testCases[i] = models.TestCase{QuestionID: question.ID, Input: tc.input, ExpectedOutput: tc.output, Sample: i == 0}
+			}
+			if err := tx.Create(&testCases).Error; err != nil {
+				return fmt.Errorf("creating test cases of %q: %w", sample.title, err)
+			}
+			if err := recordQuestionRevision(tx, &question, testCases, admin.ID, "Seeded"); err != nil {
+				return err
+			}
+			question.TestCases = testCases
+			created = append(created, question)
+			solutions = append(solutions, sample.solution)
+			summary.Questions++
+			summary.TestCases += len(testCases)
+		}
+
+		if len(created) == 0 {
+			return nil
+		}
+		random := rand.New(rand.NewPCG(opts.RandomSeed, opts.RandomSeed))
+		submissions := make([]models.Submission, opts.Submissions)
+		for i := range submissions {
+			q := random.IntN(len(created))
+			question := &created[q]
+			verdict := seedVerdicts[random.IntN(len(seedVerdicts))]
+			submittedAt := now.Add(-time.Duration(random.Int64N(int64(14 * 24 * time.Hour))))
+			total := len(question.TestCases)
+
+			submission := models.Submission{
+				Code:           seedWrongCode,
+				Language:       "go",
+				JudgeStatus:    verdict,
+				SubmissionTime: submittedAt,
+				QuestionID:     question.ID,
+				QuestionName:   question.Title,
+				UserID:         users[random.IntN(len(users))].ID,
+				JudgeAttempts:  1,
+				TestsTotal:     total,
+			}
+			submission.CreatedAt = submittedAt
+			switch verdict {
+			case models.Accepted:
+				submission.Code = solutions[q]
+				submission.TestsDone = total
+			case models.CompilationError:
+				submission.Code = seedWrongCode[:len(seedWrongCode)-2]
+				submission.Error = "./main.go:7:1: syntax error: unexpected EOF, expected }"
+			default:
+				submission.TestsDone = 1 + random.IntN(total)
+			}
+			if verdict != models.CompilationError {
+				submission.ExecutionTime = 1 + random.IntN(question.TimeLimit/2)
+				submission.MemoryUsage = 1 + random.IntN(16)
+				if verdict == models.TimeLimitExceeded {
+					submission.ExecutionTime = question.TimeLimit
+				}
+			}
+			submissions[i] = submission
+		}
+		if len(submissions) > 0 {
+			if err := tx.Create(&submissions).Error; err != nil {
+				return fmt.Errorf("creating submissions: %w", err)
+			}
+		}
+		summary.Submissions = len(submissions)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// seedUser returns the user called username, creating them when there is none
+func seedUser(tx *gorm.DB, username, password string, role models.UserRole, summary *SeedSummary) (*models.User, error) {
+	var user models.User
+	err := tx.Where("username = ?", username).First(&user).Error
+	if err == nil {
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	user = models.User{Username: username, Password: hashedPassword, Role: role}
+	if err := tx.Create(&user).Error; err != nil {
+		return nil, fmt.Errorf("creating user %s: %w", username, err)
+	}
+	summary.Users++
+	return &user, nil
+}
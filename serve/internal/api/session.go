@@ -0,0 +1,149 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// SessionResponse is an active session, marked when the request was made with it
+type SessionResponse struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+// SessionsHandler handles requests to /api/sessions
+func SessionsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getSessions(w, r)
+	case http.MethodDelete:
+		revokeAllSessions(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// SessionHandler handles requests to /api/sessions/{id}
+func SessionHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodDelete:
+		revokeSession(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getSessions lists the requesting user's active sessions, most recently seen first
+func getSessions(w http.ResponseWriter, r *http.Request) {
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	var sessions []models.Session
+	if err := db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").Find(&sessions).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve sessions", http.StatusInternalServerError)
+		return
+	}
+
+	currentID, _ := auth.SessionIDFromContext(r.Context())
+	response := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, SessionResponse{Session: session, Current: session.ID == currentID})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// revokeSession logs one of the requesting user's devices out
+func revokeSession(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	var session models.Session
+	if err := db.Where("user_id = ?", userID).First(&session, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Session not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve session", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := auth.RevokeSessions(userID, session.ID); err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to revoke session", http.StatusInternalServerError)
+		return
+	}
+
+	if currentID, ok := auth.SessionIDFromContext(r.Context()); ok && currentID == session.ID {
+		clearTokenCookie(w)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// revokeAllSessions logs the requesting user out everywhere, including on the
+// device making the request
+func revokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := auth.RevokeSessions(userID, 0); err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+
+	clearTokenCookie(w)
+	w.WriteHeader(http.StatusNoContent)
+}
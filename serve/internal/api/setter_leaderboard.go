@@ -0,0 +1,215 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+	"goera/serve/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// Points a problem setter gets for each kind of contribution
+const (
+	publishedPoints     = 10 // Per published question
+	clarificationPoints = 1  // Per answered clarification
+	testCaseFixPoints   = 3  // Per edit that changed a question's test cases
+)
+
+// SetterStats counts what a user contributed as a problem setter
+type SetterStats struct {
+	UserID                 uint   `json:"userId"`
+	Username               string `json:"username"`
+	Published              int64  `json:"published"`              // Published questions the user owns
+	ClarificationsAnswered int64  `json:"clarificationsAnswered"` // Clarifications the user answered
+	TestCaseFixes          int64  `json:"testCaseFixes"`          // Edits by the user that changed a question's test cases
+	Score                  int64  `json:"score"`
+}
+
+// SetterLeaderboardEntry is a problem setter's place on the leaderboard
+type SetterLeaderboardEntry struct {
+	Rank int `json:"rank"`
+	SetterStats
+}
+
+// SetterLeaderboardHandler handles requests to /api/leaderboard/setters
+func SetterLeaderboardHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getSetterLeaderboard(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// UserSetterStatsHandler handles requests to /api/user/{id}/setter
+func UserSetterStatsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getUserSetterStats(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// setterCount is the number of contributions of one kind by a user
+type setterCount struct {
+	UserID uint
+	Count  int64
+}
+
+// countSetterContributions counts the contributions of userID, or of every
+// user when userID is 0, keyed by user ID
+func countSetterContributions(db *gorm.DB, userID uint) (map[uint]*SetterStats, error) {
+	stats := map[uint]*SetterStats{}
+	count := func(query *gorm.DB, column string, add func(*SetterStats, int64)) error {
+		if userID != 0 {
+			query = query.Where(column+" = ?", userID)
+		}
+		var counts []setterCount
+		if err := query.Select(column + " AS user_id, COUNT(*) AS count").Group(column).Scan(&counts).Error; err != nil {
+			return err
+		}
+		for _, c := range counts {
+			if stats[c.UserID] == nil {
+				stats[c.UserID] = &SetterStats{UserID: c.UserID}
+			}
+			add(stats[c.UserID], c.Count)
+		}
+		return nil
+	}
+
+	if err := count(db.Model(&models.Question{}).Where("published = ?", true), "user_id",
+		func(s *SetterStats, n int64) { s.Published = n }); err != nil {
+		return nil, err
+	}
+	if err := count(db.Model(&models.Clarification{}).Where("answered_by IS NOT NULL"), "answered_by",
+		func(s *SetterStats, n int64) { s.ClarificationsAnswered = n }); err != nil {
+		return nil, err
+	}
+	if err := count(db.Model(&models.QuestionRevision{}).Where("test_cases_changed = ?", true), "edited_by",
+		func(s *SetterStats, n int64) { s.TestCaseFixes = n }); err != nil {
+		return nil, err
+	}
+
+	for _, s := range stats {
+		s.Score = s.Published*publishedPoints + s.ClarificationsAnswered*clarificationPoints + s.TestCaseFixes*testCaseFixPoints
+	}
+	return stats, nil
+}
+
+// getSetterLeaderboard ranks the users who contributed problems, answers or
+// test case fixes by their score, then by the questions they published
+func getSetterLeaderboard(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	stats, err := countSetterContributions(db, 0)
+	if err != nil {
+		log.Printf("Database error counting setter contributions: %v", err)
+		apierror.Write(w, r, "Failed to retrieve leaderboard", http.StatusInternalServerError)
+		return
+	}
+
+	userIDs := make([]uint, 0, len(stats))
+	for id := range stats {
+		userIDs = append(userIDs, id)
+	}
+	// Deleted users drop off the leaderboard
+	var users []models.User
+	if len(userIDs) > 0 {
+		if err := db.Select("id, username").Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve leaderboard", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	entries := make([]SetterLeaderboardEntry, 0, len(users))
+	for _, user := range users {
+		s := stats[user.ID]
+		s.Username = user.Username
+		entries = append(entries, SetterLeaderboardEntry{SetterStats: *s})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Published != b.Published {
+			return a.Published > b.Published
+		}
+		return a.UserID < b.UserID
+	})
+	// Setters with the same score share a rank
+	for i := range entries {
+		entries[i].Rank = i + 1
+		if i > 0 && entries[i].Score == entries[i-1].Score {
+			entries[i].Rank = entries[i-1].Rank
+		}
+	}
+
+	pagination := utils.ParsePagination(r, "setter_leaderboard")
+	totalItems := int64(len(entries))
+	start := min(pagination.Offset(), len(entries))
+	end := min(start+pagination.PageSize, len(entries))
+
+	totalPages := utils.TotalPages(totalItems, pagination.PageSize)
+	response := PaginatedResponse{
+		Data:       entries[start:end],
+		Page:       pagination.Page,
+		PageSize:   pagination.PageSize,
+		TotalItems: totalItems,
+		TotalPages: totalPages,
+	}
+
+	utils.SetPageLinks(w, r, pagination, totalPages)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// getUserSetterStats returns what a user contributed as a problem setter. Like
+// the rest of a profile, anyone may see it.
+func getUserSetterStats(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	user, ok := profileUser(w, r, db)
+	if !ok {
+		return
+	}
+
+	stats, err := countSetterContributions(db, user.ID)
+	if err != nil {
+		log.Printf("Database error counting setter contributions: %v", err)
+		apierror.Write(w, r, "Failed to retrieve setter statistics", http.StatusInternalServerError)
+		return
+	}
+	response := SetterStats{UserID: user.ID}
+	if s, ok := stats[user.ID]; ok {
+		response = *s
+	}
+	response.Username = user.Username
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
@@ -1,39 +1,130 @@
 package api
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
-	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
+	"goera/serve/internal/apierror"
 	"goera/serve/internal/auth"
+	"goera/serve/internal/config"
 	"goera/serve/internal/database"
+	"goera/serve/internal/internalpb"
 	"goera/serve/internal/models"
+	"goera/serve/internal/preferences"
+	"goera/serve/internal/storage"
+	"goera/serve/internal/utils"
+	"goera/serve/internal/validation"
 
 	"github.com/gorilla/mux"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"gorm.io/gorm"
 )
 
 // SubmissionRequest represents the request body for creating a submission
 type SubmissionRequest struct {
-	Code       string `json:"code"`
-	Language   string `json:"language"`
-	QuestionID uint   `json:"questionId"`
+	Code       string            `json:"code"`
+	Files      map[string]string `json:"files"` // Path to content, for Go submissions made of several files or a module
+	Language   string            `json:"language"`
+	QuestionID uint              `json:"questionId"`
+	ContestID  uint              `json:"contestId"` // Set when submitting to a running contest
 }
 
-type PendingSubmission struct {
-	SubmissionID uint              `json:"submissionId"`
-	SourceCode   string            `json:"sourceCode"`
-	TestCases    []models.TestCase `json:"testCases"`
-	TimeLimit    string            `json:"timeLimit"`
-	MemoryLimit  string            `json:"memoryLimit"`
-	CPUCount     string            `json:"cpuCount"`
-	DockerImage  string            `json:"dockerImage"`
+// Validate checks that the submission has either code or files, and a
+// language the judge supports
+func (req *SubmissionRequest) Validate(errs *validation.Errors) {
+	switch {
+	case len(req.Files) > 0 && req.Code != "":
+		errs.Add("files", "exclusive", "%s cannot be sent with %s", "code")
+	case len(req.Files) > 0 && req.Language != defaultLanguage:
+		errs.Add("files", "language", "%s can only be sent for %s submissions", defaultLanguage)
+	case len(req.Files) == 0 && strings.TrimSpace(req.Code) == "":
+		errs.Add("code", "required", "%s or %s is required", "files")
+	}
+	if req.Language != "" && !slices.Contains(config.SupportedLanguages, req.Language) {
+		errs.Add("language", "oneof", "%s must be one of %s", strings.Join(config.SupportedLanguages, ", "))
+	}
+}
+
+// judgeRejectedError is returned when the judge refuses a submission, as
+// opposed to not being reachable
+type judgeRejectedError struct {
+	Code    codes.Code
+	Message string
+}
+
+func (e *judgeRejectedError) Error() string {
+	return fmt.Sprintf("judge rejected submission with %s: %s", e.Code, e.Message)
+}
+
+// dispatchToJudge sends a submission to the judge and marks it as judging once
+// the judge accepted it. The question must have its test cases loaded. Callers
+// normally go through the judge outbox, which retries failed deliveries. While
+// the judge circuit breaker is open the judge is not called at all.
+func dispatchToJudge(db *gorm.DB, submission *models.Submission, question *models.Question) error {
+	client, err := judgeClient()
+	if err != nil {
+		return fmt.Errorf("failed to create judge client: %w", err)
+	}
+
+	// The call continues the trace of db's context, but is not cut short when
+	// the request that made it ends
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(db.Statement.Context), 10*time.Second)
+	defer cancel()
+	testCases, err := testCasesProto(question.TestCases)
+	if err != nil {
+		return fmt.Errorf("failed to link test data: %w", err)
+	}
+	job := &internalpb.Job{
+		SubmissionId: uint64(submission.ID),
+		SourceCode:   submission.Code,
+		TestCases:    testCases,
+		Limits:       jobLimits(question.TimeLimit, question.MemoryLimit, submission.Language),
+		BuildFlags:   question.BuildFlagList(),
+	}
+	if len(submission.Files) > 0 {
+		// Code only holds a listing of the files
+		job.SourceCode = ""
+		job.Files = submission.Files
+	}
+	if submission.ContestID != nil {
+		// Contest submissions skip ahead of practice ones at a busy judge
+		job.Priority = internalpb.Priority_PRIORITY_HIGH
+	}
+	if !judgeCircuit.allow() {
+		return fmt.Errorf("judge unreachable: %w", errJudgeCircuitOpen)
+	}
+	_, err = client.SubmitJob(ctx, &internalpb.SubmitJobRequest{Job: job})
+	judgeCircuit.record(judgeUnreachable(err))
+	if err != nil {
+		st := status.Convert(err)
+		if judgeUnreachable(err) {
+			recordSubmissionEvent(db, submission.ID, "serve", models.EventError, fmt.Sprintf("Judge unreachable: %v", st.Message()))
+			return fmt.Errorf("judge unreachable: %w", err)
+		}
+		recordSubmissionEvent(db, submission.ID, "serve", models.EventError, fmt.Sprintf("Judge rejected submission with %s", st.Code()))
+		return &judgeRejectedError{Code: st.Code(), Message: st.Message()}
+	}
+
+	// Update submission status to Judging; a requeued submission starts over
+	submission.JudgeAttempts++
+	submission.JudgeStatus = models.Judging
+	submission.TestsDone = 0
+	submission.TestsTotal = len(question.TestCases)
+	if err := db.Save(submission).Error; err != nil {
+		log.Printf("Failed to update submission status: %v", err)
+		// Note: We don't fail here since the judge has accepted it
+	}
+	publishSubmission(submission)
+	return nil
 }
 
 // SubmissionsHandler handles all requests to /api/submissions
@@ -44,7 +135,7 @@ func SubmissionsHandler(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPost:
 		createSubmission(w, r)
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -54,53 +145,107 @@ func SubmissionHandler(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		getSubmissionByID(w, r)
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// parseTimeFilter parses a date range bound given either as RFC 3339 or as a
+// plain date. Plain dates used as an upper bound cover the whole day.
+func parseTimeFilter(value string, upperBound bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
 	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if upperBound {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, nil
 }
 
-// getUserSubmissions retrieves all submissions for the current user
+// getUserSubmissions retrieves the current user's submissions. Results can be
+// filtered by verdict, language, question and submission date range. Admins
+// may also list every user's submissions with all=true or pick one with userId.
+// Besides offset paging it supports cursor paging with after=<submission id>.
 func getUserSubmissions(w http.ResponseWriter, r *http.Request) {
 	db := database.GetDB()
 	if db == nil {
 		log.Println("Database connection is nil")
-		http.Error(w, "Database connection error", http.StatusInternalServerError)
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
 		return
 	}
 
 	userID, userExists := auth.UserIDFromContext(r.Context())
 	if !userExists {
 		log.Println("User ID not found in context")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	// Parse pagination parameters
-	page := 1
-	pageSize := 5 // Default page size for submissions
+	pagination := utils.ParsePagination(r, "submissions")
 
-	if pageParam := r.URL.Query().Get("page"); pageParam != "" {
-		if parsedPage, err := strconv.Atoi(pageParam); err == nil && parsedPage > 0 {
-			page = parsedPage
+	// Start with a query for the current user's submissions
+	query := db.Where("user_id = ?", userID)
+
+	userIDStr := r.URL.Query().Get("userId")
+	if userIDStr != "" || r.URL.Query().Get("all") == "true" {
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+			return
 		}
-	}
 
-	if pageSizeParam := r.URL.Query().Get("page_size"); pageSizeParam != "" {
-		if parsedPageSize, err := strconv.Atoi(pageSizeParam); err == nil && parsedPageSize > 0 && parsedPageSize <= 100 {
-			pageSize = parsedPageSize
+		if user.Role != models.AdminRole {
+			apierror.Write(w, r, "Only administrators can view other users' submissions", http.StatusForbidden)
+			return
+		}
+
+		query = db
+		if userIDStr != "" {
+			filterUserID, err := strconv.Atoi(userIDStr)
+			if err != nil {
+				apierror.Write(w, r, "Invalid user ID", http.StatusBadRequest)
+				return
+			}
+			query = query.Where("user_id = ?", filterUserID)
 		}
 	}
 
-	offset := (page - 1) * pageSize
+	if verdict := r.URL.Query().Get("verdict"); verdict != "" {
+		query = query.Where("judge_status IN ?", strings.Split(verdict, ","))
+	}
 
-	// Start with a query for the current user's submissions
-	query := db.Where("user_id = ?", userID)
+	if language := r.URL.Query().Get("language"); language != "" {
+		query = query.Where("language = ?", language)
+	}
+
+	if fromStr := r.URL.Query().Get("from"); fromStr != "" {
+		from, err := parseTimeFilter(fromStr, false)
+		if err != nil {
+			apierror.Write(w, r, "Invalid from date", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("submission_time >= ?", from)
+	}
+
+	if toStr := r.URL.Query().Get("to"); toStr != "" {
+		to, err := parseTimeFilter(toStr, true)
+		if err != nil {
+			apierror.Write(w, r, "Invalid to date", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("submission_time <= ?", to)
+	}
 
 	// Handle query parameters for filtering
 	questionIDStr := r.URL.Query().Get("questionId")
 	if questionIDStr != "" {
 		questionID, err := strconv.Atoi(questionIDStr)
 		if err != nil {
-			http.Error(w, "Invalid question ID", http.StatusBadRequest)
+			apierror.Write(w, r, "Invalid question ID", http.StatusBadRequest)
 			return
 		}
 
@@ -112,35 +257,54 @@ func getUserSubmissions(w http.ResponseWriter, r *http.Request) {
 	var totalItems int64
 	if err := query.Model(&models.Submission{}).Count(&totalItems).Error; err != nil {
 		log.Printf("Database error counting submissions: %v", err)
-		http.Error(w, "Failed to count submissions", http.StatusInternalServerError)
+		apierror.Write(w, r, "Failed to count submissions", http.StatusInternalServerError)
 		return
 	}
 
-	// Calculate total pages
-	totalPages := int((totalItems + int64(pageSize) - 1) / int64(pageSize))
+	response := PaginatedResponse{
+		PageSize:   pagination.PageSize,
+		TotalItems: totalItems,
+	}
 
-	// Order by submission time (newest first) and get paginated results
 	var submissions []models.Submission
-	result := query.Order("submission_time DESC").Limit(pageSize).Offset(offset).Find(&submissions)
-	if result.Error != nil {
-		log.Printf("Database error: %v", result.Error)
-		http.Error(w, "Failed to retrieve submissions", http.StatusInternalServerError)
-		return
-	}
+	if pagination.Cursor {
+		// Cursor mode walks the IDs downwards, newest first like offset mode, so
+		// submissions arriving meanwhile do not shift the pages. after=0 starts
+		// at the newest submission.
+		if pagination.After > 0 {
+			query = query.Where("id < ?", pagination.After)
+		}
+		if err := query.Order("id DESC").Limit(pagination.PageSize + 1).Find(&submissions).Error; err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve submissions", http.StatusInternalServerError)
+			return
+		}
 
-	// Create paginated response
-	response := PaginatedResponse{
-		Data:       submissions,
-		Page:       page,
-		PageSize:   pageSize,
-		TotalItems: totalItems,
-		TotalPages: totalPages,
+		if len(submissions) > pagination.PageSize {
+			submissions = submissions[:pagination.PageSize]
+			lastID := submissions[len(submissions)-1].ID
+			response.NextAfter = &lastID
+			utils.SetCursorLink(w, r, pagination, lastID, true)
+		}
+	} else {
+		// Order by submission time (newest first) and get paginated results
+		result := query.Order("submission_time DESC").Limit(pagination.PageSize).Offset(pagination.Offset()).Find(&submissions)
+		if result.Error != nil {
+			log.Printf("Database error: %v", result.Error)
+			apierror.Write(w, r, "Failed to retrieve submissions", http.StatusInternalServerError)
+			return
+		}
+
+		response.Page = pagination.Page
+		response.TotalPages = utils.TotalPages(totalItems, pagination.PageSize)
+		utils.SetPageLinks(w, r, pagination, response.TotalPages)
 	}
+	response.Data = submissions
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		log.Printf("JSON encoding error: %v", err)
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
 	}
 }
 
@@ -149,21 +313,21 @@ func getSubmissionByID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		http.Error(w, "Invalid submission ID", http.StatusBadRequest)
+		apierror.Write(w, r, "Invalid submission ID", http.StatusBadRequest)
 		return
 	}
 
 	db := database.GetDB()
 	if db == nil {
 		log.Println("Database connection is nil")
-		http.Error(w, "Database connection error", http.StatusInternalServerError)
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
 		return
 	}
 
 	userID, userExists := auth.UserIDFromContext(r.Context())
 	if !userExists {
 		log.Println("User ID not found in context")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
@@ -171,141 +335,267 @@ func getSubmissionByID(w http.ResponseWriter, r *http.Request) {
 	result := db.First(&submission, id)
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
-			http.Error(w, "Submission not found", http.StatusNotFound)
+			apierror.Write(w, r, "Submission not found", http.StatusNotFound)
 		} else {
 			log.Printf("Database error: %v", result.Error)
-			http.Error(w, "Failed to retrieve submission", http.StatusInternalServerError)
+			apierror.Write(w, r, "Failed to retrieve submission", http.StatusInternalServerError)
 		}
 		return
 	}
 
-	// Users can only see their own submissions
+	// Users can only see their own submissions, admins can see all of them
 	if submission.UserID != userID {
-		http.Error(w, "Unauthorized to view this submission", http.StatusForbidden)
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+			return
+		}
+
+		if user.Role != models.AdminRole {
+			apierror.Write(w, r, "Unauthorized to view this submission", http.StatusForbidden)
+			return
+		}
+	} else {
+		// Stderr can echo test data, so only the author gets to debug with it
+		if err := db.Where("submission_id = ?", submission.ID).Order("test_case ASC").Find(&submission.Stderr).Error; err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve submission stderr", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	testResults, err := loadTestResults(db, submission.ID)
+	if err == nil {
+		err = attachTestUsage(db, submission.ID, testResults)
+	}
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve test results", http.StatusInternalServerError)
 		return
 	}
+	submission.TestResults = testResults
+
+	if submission.ErrorRef != "" {
+		submission.Error, err = storage.Load(r.Context(), submission.ErrorRef)
+		if err != nil {
+			log.Printf("Storage error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve judge log", http.StatusInternalServerError)
+			return
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(submission); err != nil {
 		log.Printf("JSON encoding error: %v", err)
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
 	}
 }
 
 func createSubmission(w http.ResponseWriter, r *http.Request) {
+	// JSON escaping can inflate the source, so leave headroom over the raw limit
+	r.Body = http.MaxBytesReader(w, r.Body, int64(config.MaxSourceCodeBytes)*2+4096)
+
 	var submissionReq SubmissionRequest
-	if err := json.NewDecoder(r.Body).Decode(&submissionReq); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	var err error
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		submissionReq, err = parseSubmissionUpload(r)
+	} else {
+		err = json.NewDecoder(r.Body).Decode(&submissionReq)
+	}
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr):
+			apierror.Write(w, r, fmt.Sprintf("Request body exceeds the maximum size of %d bytes", maxBytesErr.Limit), http.StatusRequestEntityTooLarge)
+		case errors.Is(err, errSourceTooLarge):
+			apierror.Write(w, r, fmt.Sprintf("Source code exceeds the maximum size of %d bytes", config.MaxSourceCodeBytes), http.StatusRequestEntityTooLarge)
+		case errors.Is(err, errInvalidUpload):
+			apierror.Write(w, r, err.Error(), http.StatusBadRequest)
+		default:
+			writeBodyError(w, r, err)
+		}
+		return
+	}
+	if !validRequest(w, r, &submissionReq) {
 		return
 	}
 
+	if len(submissionReq.Code) > config.MaxSourceCodeBytes {
+		apierror.Write(w, r, fmt.Sprintf("Source code exceeds the maximum size of %d bytes", config.MaxSourceCodeBytes), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if len(submissionReq.Files) > 0 {
+		if err := validateSourceFiles(submissionReq.Files); err != nil {
+			if errors.Is(err, errSourceTooLarge) {
+				apierror.Write(w, r, fmt.Sprintf("Source code exceeds the maximum size of %d bytes", config.MaxSourceCodeBytes), http.StatusRequestEntityTooLarge)
+			} else {
+				apierror.Write(w, r, err.Error(), http.StatusBadRequest)
+			}
+			return
+		}
+		submissionReq.Code = combinedSource(submissionReq.Files)
+	}
+
 	userID, userExists := auth.UserIDFromContext(r.Context())
 	if !userExists {
 		log.Println("User ID not found in context")
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
 	db := database.GetDB()
 	if db == nil {
 		log.Println("Database connection is nil")
-		http.Error(w, "Database connection error", http.StatusInternalServerError)
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
 		return
 	}
+	// Queries and the call to the judge become part of the request's trace
+	db = db.WithContext(r.Context())
 
 	var question models.Question
 	result := db.Preload("TestCases").First(&question, submissionReq.QuestionID)
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
-			http.Error(w, "Question not found", http.StatusNotFound)
+			apierror.Write(w, r, "Question not found", http.StatusNotFound)
 		} else {
 			log.Printf("Database error: %v", result.Error)
-			http.Error(w, "Failed to retrieve question", http.StatusInternalServerError)
+			apierror.Write(w, r, "Failed to retrieve question", http.StatusInternalServerError)
 		}
 		return
 	}
 
+	// Submissions without a language use the user's default
+	if submissionReq.Language == "" {
+		submissionReq.Language = preferences.FromContext(r.Context()).Language
+	}
+	if !question.AllowsLanguage(submissionReq.Language) {
+		apierror.Write(w, r, fmt.Sprintf("Language %q is not allowed for this question", submissionReq.Language), http.StatusBadRequest)
+		return
+	}
+
 	// Validate test cases
 	if len(question.TestCases) == 0 {
 		log.Printf("No test cases found for question ID %d", submissionReq.QuestionID)
-		http.Error(w, "Question has no test cases", http.StatusBadRequest)
+		apierror.Write(w, r, "Question has no test cases", http.StatusBadRequest)
 		return
 	}
 
+	var contestID *uint
+	if submissionReq.ContestID != 0 {
+		var contest models.Contest
+		if err := db.First(&contest, submissionReq.ContestID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				apierror.Write(w, r, "Contest not found", http.StatusNotFound)
+			} else {
+				log.Printf("Database error: %v", err)
+				apierror.Write(w, r, "Failed to retrieve contest", http.StatusInternalServerError)
+			}
+			return
+		}
+		if !contest.Running(time.Now()) {
+			apierror.Write(w, r, "Contest is not running", http.StatusForbidden)
+			return
+		}
+		var inContest int64
+		if err := db.Model(&models.ContestProblem{}).Where("contest_id = ? AND question_id = ?", contest.ID, question.ID).Count(&inContest).Error; err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve contest", http.StatusInternalServerError)
+			return
+		}
+		if inContest == 0 {
+			apierror.Write(w, r, "Question is not part of the contest", http.StatusBadRequest)
+			return
+		}
+		registered, err := isContestParticipant(db, contest.ID, userID)
+		if err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve registration", http.StatusInternalServerError)
+			return
+		}
+		if !registered {
+			apierror.Write(w, r, "You are not registered for this contest", http.StatusForbidden)
+			return
+		}
+		contestID = &contest.ID
+	} else if question.UserID != userID {
+		// Outside the contest, its problems stay closed to everyone but the
+		// participants until it ends
+		inContest, participant, err := contestQuestionAccess(db, question.ID, userID)
+		if err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve contest", http.StatusInternalServerError)
+			return
+		}
+		if inContest && !participant {
+			var user models.User
+			if err := db.First(&user, userID).Error; err != nil {
+				log.Printf("Database error: %v", err)
+				apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+				return
+			}
+			if !canEditQuestion(db, &question, &user) {
+				apierror.Write(w, r, "This question is only available to contest participants", http.StatusForbidden)
+				return
+			}
+		}
+	}
+
 	// Create the submission
 	submission := models.Submission{
 		Code:           submissionReq.Code,
+		Files:          submissionReq.Files,
 		Language:       submissionReq.Language,
 		JudgeStatus:    models.Pending,
 		SubmissionTime: time.Now(),
 		QuestionID:     submissionReq.QuestionID,
 		QuestionName:   question.Title,
 		UserID:         userID,
+		ContestID:      contestID,
 	}
 
-	result = db.Create(&submission)
-	if result.Error != nil {
-		log.Printf("Database error: %v", result.Error)
-		http.Error(w, "Failed to create submission", http.StatusInternalServerError)
-		return
-	}
-
-	// Prepare submission for judge service
-	pendingSubmission := PendingSubmission{
-		SubmissionID: submission.ID,
-		SourceCode:   submission.Code,
-		TestCases:    question.TestCases,
-		TimeLimit:    fmt.Sprintf("%dms", question.TimeLimit),
-		MemoryLimit:  fmt.Sprintf("%d", question.MemoryLimit),
-		CPUCount:     "1.0",
-		DockerImage:  "go-judge-runner:latest",
-	}
-
-	payload, err := json.Marshal(pendingSubmission)
-	if err != nil {
-		log.Printf("Failed to marshal judge submission: %v", err)
-		http.Error(w, "Failed to prepare submission for judging", http.StatusInternalServerError)
-		return
-	}
-
-	req, err := http.NewRequest("POST", "http://judge:8080/submit", bytes.NewReader(payload))
-	if err != nil {
-		log.Printf("Failed to create judge request: %v", err)
-		http.Error(w, "Failed to send submission to judge", http.StatusInternalServerError)
+	// The submission and its outbox entry are stored together, so it reaches the
+	// judge eventually even if the judge is down right now. The attempt limits
+	// are checked in the same transaction, so they count every submission
+	// stored before this one.
+	var outboxEntry *models.JudgeOutbox
+	var attempts AttemptStatus
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if attempts, err = checkAttempts(tx, &question, userID); err != nil {
+			return err
+		}
+		if err := tx.Create(&submission).Error; err != nil {
+			return err
+		}
+		entry, err := enqueueSubmission(tx, submission.ID, outboxClaimTimeout)
+		outboxEntry = entry
+		return err
+	})
+	if errors.Is(err, errAttemptRefused) {
+		writeAttemptRefused(w, r, attempts)
 		return
 	}
-	req.Header.Set("Content-Type", "application/json")
-	apiKey := os.Getenv("INTERNAL_API_KEY")
-	req.Header.Set("X-API-Key", apiKey)
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
 	if err != nil {
-		log.Printf("Failed to send submission to judge: %v", err)
-		http.Error(w, "Judge service unavailable", http.StatusInternalServerError)
-		return
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusAccepted {
-		body, _ := io.ReadAll(resp.Body)
-		log.Printf("Judge service error: %d %s", resp.StatusCode, string(body))
-		http.Error(w, fmt.Sprintf("Judge service rejected submission: %s", string(body)), http.StatusInternalServerError)
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to create submission", http.StatusInternalServerError)
 		return
 	}
-
-	// Update submission status to Judging
-	submission.JudgeStatus = models.Judging
-	result = db.Save(&submission)
-	if result.Error != nil {
-		log.Printf("Failed to update submission status: %v", result.Error)
-		// Note: We don't fail the request here since the judge has accepted it
+	recordSubmissionEvent(db, submission.ID, "serve", models.EventCreated, fmt.Sprintf("Submission created for question %d", question.ID))
+
+	status := http.StatusCreated
+	if err := deliverSubmission(db, outboxEntry, &submission, &question); err != nil {
+		log.Printf("Failed to send submission %d to judge, leaving it in the outbox: %v", submission.ID, err)
+		recordSubmissionEvent(db, submission.ID, "serve", models.EventDeferred, "Judge unavailable, the submission will be sent again shortly")
+		publishSubmission(&submission)
+		status = http.StatusAccepted
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(status)
 	if err := json.NewEncoder(w).Encode(submission); err != nil {
 		log.Printf("JSON encoding error: %v", err)
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
 	}
 }
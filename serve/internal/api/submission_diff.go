@@ -0,0 +1,140 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+	"goera/serve/internal/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// submissionDiffContext is the number of unchanged lines shown around each
+// change of a submission diff
+const submissionDiffContext = 3
+
+// SubmissionDiff compares the code of two submissions to the same question
+type SubmissionDiff struct {
+	From    uint             `json:"from"` // The earlier attempt, given by against
+	To      uint             `json:"to"`
+	Hunks   []utils.DiffHunk `json:"hunks"`
+	Unified string           `json:"unified"` // Empty when the code is the same
+}
+
+// SubmissionDiffHandler handles requests to /api/submissions/{id}/diff
+func SubmissionDiffHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		diffSubmissions(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// diffSubmissions compares a submission with the one given in ?against=,
+// defaulting to the same user's previous submission to the question. Both
+// have to be by the same user for the same question, and only that user and
+// admins may compare them.
+func diffSubmissions(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid submission ID", http.StatusBadRequest)
+		return
+	}
+
+	against := 0
+	if againstParam := r.URL.Query().Get("against"); againstParam != "" {
+		against, err = strconv.Atoi(againstParam)
+		if err != nil || against <= 0 {
+			apierror.Write(w, r, "Invalid against submission ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	var to models.Submission
+	if err := db.First(&to, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Submission not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve submission", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if to.UserID != userID {
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+			return
+		}
+		if user.Role != models.AdminRole {
+			apierror.Write(w, r, "Unauthorized to view this submission", http.StatusForbidden)
+			return
+		}
+	}
+
+	var from models.Submission
+	if against > 0 {
+		err = db.First(&from, against).Error
+	} else {
+		err = db.Where("user_id = ? AND question_id = ? AND id < ?", to.UserID, to.QuestionID, to.ID).
+			Order("id DESC").
+			First(&from).Error
+	}
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve submission", http.StatusInternalServerError)
+		} else if against > 0 {
+			apierror.Write(w, r, "Submission to compare against not found", http.StatusNotFound)
+		} else {
+			apierror.Write(w, r, "No earlier submission to compare against", http.StatusNotFound)
+		}
+		return
+	}
+	if from.UserID != to.UserID || from.QuestionID != to.QuestionID {
+		apierror.Write(w, r, "Only submissions by the same user for the same question can be compared", http.StatusBadRequest)
+		return
+	}
+
+	// The final newline would show up as an empty last line
+	hunks := utils.DiffHunks(utils.LineDiff(strings.TrimSuffix(from.Code, "\n"), strings.TrimSuffix(to.Code, "\n")), submissionDiffContext)
+	diff := SubmissionDiff{
+		From:    from.ID,
+		To:      to.ID,
+		Hunks:   hunks,
+		Unified: utils.UnifiedDiff(fmt.Sprintf("submission/%d", from.ID), fmt.Sprintf("submission/%d", to.ID), hunks),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(diff); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,292 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/database"
+	"goera/serve/internal/internalpb"
+	"goera/serve/internal/models"
+	"goera/serve/internal/utils"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+// SubmissionEventRequest is a single timeline event reported by the judge or a runner
+type SubmissionEventRequest struct {
+	Source  string                     `json:"source"`
+	Type    models.SubmissionEventType `json:"type"`
+	Message string                     `json:"message"`
+	Time    time.Time                  `json:"time"`
+}
+
+// SubmissionEventsHandler handles requests to /api/submissions/{id}/events
+func SubmissionEventsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getSubmissionEvents(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// recordSubmissionEvent appends an event to a submission's timeline. Failures
+// are only logged since the timeline is diagnostic and must never break judging.
+func recordSubmissionEvent(db *gorm.DB, submissionID uint, source string, eventType models.SubmissionEventType, message string) {
+	event := models.SubmissionEvent{
+		SubmissionID: submissionID,
+		Source:       source,
+		Type:         eventType,
+		Message:      message,
+		OccurredAt:   time.Now(),
+	}
+	if err := db.Create(&event).Error; err != nil {
+		log.Printf("Failed to record %s event for submission %d: %v", eventType, submissionID, err)
+	}
+}
+
+// saveReportedEvents stores events reported by other services
+func saveReportedEvents(db *gorm.DB, submissionID uint, reported []SubmissionEventRequest) error {
+	if len(reported) == 0 {
+		return nil
+	}
+
+	events := make([]models.SubmissionEvent, 0, len(reported))
+	for _, e := range reported {
+		occurredAt := e.Time
+		if occurredAt.IsZero() {
+			occurredAt = time.Now()
+		}
+		events = append(events, models.SubmissionEvent{
+			SubmissionID: submissionID,
+			Source:       e.Source,
+			Type:         e.Type,
+			Message:      e.Message,
+			OccurredAt:   occurredAt,
+		})
+	}
+	return db.Create(&events).Error
+}
+
+// loadTestResults rebuilds a submission's per-test verdicts from the test_verdict
+// events of its latest judging. A requeued submission is judged again from the
+// first test, so earlier attempts are dropped when the numbering restarts.
+func loadTestResults(db *gorm.DB, submissionID uint) ([]models.TestResult, error) {
+	var events []models.SubmissionEvent
+	err := db.Where("submission_id = ? AND type = ?", submissionID, models.EventTestVerdict).
+		Order("occurred_at ASC, id ASC").
+		Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var results []models.TestResult
+	for _, event := range events {
+		result, ok := parseTestVerdict(event.Message)
+		if !ok {
+			continue
+		}
+		if result.TestCase == 1 {
+			results = results[:0]
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// attachTestUsage adds the resource usage stored for a submission to its
+// per-test verdicts
+func attachTestUsage(db *gorm.DB, submissionID uint, results []models.TestResult) error {
+	var usages []models.SubmissionTestUsage
+	if err := db.Where("submission_id = ?", submissionID).Find(&usages).Error; err != nil {
+		return err
+	}
+	byTest := make(map[int]*models.SubmissionTestUsage, len(usages))
+	for i := range usages {
+		byTest[usages[i].TestCase] = &usages[i]
+	}
+	for i := range results {
+		results[i].Usage = byTest[results[i].TestCase]
+	}
+	return nil
+}
+
+// parseTestVerdict reads the message of a test_verdict event
+func parseTestVerdict(message string) (models.TestResult, bool) {
+	var result models.TestResult
+	_, err := fmt.Sscanf(message, "Test %d/%d: %s", &result.TestCase, &result.Total, &result.Verdict)
+	return result, err == nil
+}
+
+// recordTestProgress moves a submission that is being judged on to the last
+// test verdict among events, and pushes the progress to stream subscribers
+func recordTestProgress(db *gorm.DB, submissionID uint, events []SubmissionEventRequest) {
+	var last models.TestResult
+	for _, e := range events {
+		if e.Type != models.EventTestVerdict {
+			continue
+		}
+		if result, ok := parseTestVerdict(e.Message); ok {
+			last = result
+		}
+	}
+	if last.Total == 0 {
+		return
+	}
+
+	result := db.Model(&models.Submission{}).
+		Where("id = ? AND judge_status IN ?", submissionID, []models.JudgeStatus{models.Pending, models.Judging}).
+		Updates(map[string]interface{}{"tests_done": last.TestCase, "tests_total": last.Total})
+	if result.Error != nil {
+		log.Printf("Failed to record test progress of submission %d: %v", submissionID, result.Error)
+		return
+	}
+	if result.RowsAffected == 0 {
+		return
+	}
+
+	var submission models.Submission
+	if err := db.First(&submission, submissionID).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		return
+	}
+	publishSubmission(&submission)
+}
+
+// ReportEvents stores events pushed by the judge while a submission is in flight
+func (s *ResultServer) ReportEvents(ctx context.Context, req *internalpb.ReportEventsRequest) (*internalpb.ReportEventsResponse, error) {
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		return nil, status.Error(codes.Internal, "Database connection error")
+	}
+	db = db.WithContext(ctx)
+
+	events := eventsFromProto(req.GetEvents())
+	if err := saveReportedEvents(db, uint(req.GetSubmissionId()), events); err != nil {
+		log.Printf("Database error saving submission events: %v", err)
+		return nil, status.Error(codes.Internal, "Failed to save events")
+	}
+	recordTestProgress(db, uint(req.GetSubmissionId()), events)
+	return &internalpb.ReportEventsResponse{}, nil
+}
+
+// getSubmissionEvents returns the paginated event timeline of a submission for administrators.
+// Supports filtering by source and type and a free text search over messages,
+// and cursor paging in insertion order with after=<event id>.
+func getSubmissionEvents(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid submission ID", http.StatusBadRequest)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+		return
+	}
+
+	if user.Role != models.AdminRole {
+		apierror.Write(w, r, "Only administrators can view submission timelines", http.StatusForbidden)
+		return
+	}
+
+	var submission models.Submission
+	if err := db.First(&submission, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Submission not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve submission", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	pagination := utils.ParsePagination(r, "submission_events")
+
+	query := db.Model(&models.SubmissionEvent{}).Where("submission_id = ?", submission.ID)
+	if source := r.URL.Query().Get("source"); source != "" {
+		query = query.Where("source = ?", source)
+	}
+	if eventType := r.URL.Query().Get("type"); eventType != "" {
+		query = query.Where("type = ?", eventType)
+	}
+	if search := r.URL.Query().Get("q"); search != "" {
+		query = query.Where("LOWER(message) LIKE LOWER(?)", "%"+search+"%")
+	}
+
+	var totalItems int64
+	if err := query.Count(&totalItems).Error; err != nil {
+		log.Printf("Database error counting submission events: %v", err)
+		apierror.Write(w, r, "Failed to count submission events", http.StatusInternalServerError)
+		return
+	}
+
+	response := PaginatedResponse{
+		PageSize:   pagination.PageSize,
+		TotalItems: totalItems,
+	}
+
+	var events []models.SubmissionEvent
+	if pagination.Cursor {
+		// Cursor mode follows insertion order so pages stay stable while events keep arriving
+		if err := query.Where("id > ?", pagination.After).Order("id ASC").Limit(pagination.PageSize + 1).Find(&events).Error; err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve submission events", http.StatusInternalServerError)
+			return
+		}
+
+		hasMore := len(events) > pagination.PageSize
+		if hasMore {
+			events = events[:pagination.PageSize]
+			lastID := events[len(events)-1].ID
+			response.NextAfter = &lastID
+			utils.SetCursorLink(w, r, pagination, lastID, true)
+		}
+	} else {
+		if err := query.Order("occurred_at ASC, id ASC").Limit(pagination.PageSize).Offset(pagination.Offset()).Find(&events).Error; err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve submission events", http.StatusInternalServerError)
+			return
+		}
+
+		response.Page = pagination.Page
+		response.TotalPages = utils.TotalPages(totalItems, pagination.PageSize)
+		utils.SetPageLinks(w, r, pagination, response.TotalPages)
+	}
+	response.Data = events
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,239 @@
+package api
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"goera/serve/internal/config"
+)
+
+// errSourceTooLarge is returned when the files of a submission exceed
+// MaxSourceCodeBytes together
+var errSourceTooLarge = errors.New("source files are too large")
+
+// errInvalidUpload wraps the problems of an uploaded submission form that
+// can be shown to the user as they are
+var errInvalidUpload = errors.New("invalid upload")
+
+// parseSubmissionUpload reads a submission sent as a multipart form, with the
+// questionId, language and contestId fields and the files in an archive
+// field, or the code in a code field
+func parseSubmissionUpload(r *http.Request) (SubmissionRequest, error) {
+	var req SubmissionRequest
+	if err := r.ParseMultipartForm(int64(config.MaxSourceCodeBytes) * 2); err != nil {
+		return req, err
+	}
+
+	questionID, err := strconv.ParseUint(r.FormValue("questionId"), 10, 64)
+	if err != nil {
+		return req, fmt.Errorf("%w: questionId must be a number", errInvalidUpload)
+	}
+	req.QuestionID = uint(questionID)
+	if value := r.FormValue("contestId"); value != "" {
+		contestID, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return req, fmt.Errorf("%w: contestId must be a number", errInvalidUpload)
+		}
+		req.ContestID = uint(contestID)
+	}
+	req.Language = r.FormValue("language")
+	req.Code = r.FormValue("code")
+
+	file, header, err := r.FormFile("archive")
+	if errors.Is(err, http.ErrMissingFile) {
+		return req, nil
+	}
+	if err != nil {
+		return req, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return req, err
+	}
+	files, err := readSourceArchive(header.Filename, data)
+	if err != nil {
+		if errors.Is(err, errSourceTooLarge) {
+			return req, err
+		}
+		return req, fmt.Errorf("%w: %v", errInvalidUpload, err)
+	}
+	if len(files) == 0 {
+		return req, fmt.Errorf("%w: the archive holds no Go source files", errInvalidUpload)
+	}
+	req.Files = files
+	return req, nil
+}
+
+// isSourceFile reports whether name is a file a multi-file submission may
+// contain: Go sources and the module's go.mod and go.sum
+func isSourceFile(name string) bool {
+	base := path.Base(name)
+	return strings.HasSuffix(base, ".go") || base == "go.mod" || base == "go.sum"
+}
+
+// validateSourceFiles checks the paths, number and size of a multi-file
+// submission's files
+func validateSourceFiles(files map[string]string) error {
+	if len(files) > config.MaxSourceFiles {
+		return fmt.Errorf("a submission can have at most %d files", config.MaxSourceFiles)
+	}
+
+	size := 0
+	hasGo := false
+	for name, content := range files {
+		if name == "" || path.Clean(name) != name || !filepath.IsLocal(filepath.FromSlash(name)) || strings.Contains(name, "\\") {
+			return fmt.Errorf("invalid file path %q", name)
+		}
+		if !isSourceFile(name) {
+			return fmt.Errorf("file %q is not a Go source file, go.mod or go.sum", name)
+		}
+		hasGo = hasGo || strings.HasSuffix(name, ".go")
+		size += len(content)
+	}
+	if size > config.MaxSourceCodeBytes {
+		return errSourceTooLarge
+	}
+	if !hasGo {
+		return errors.New("a submission needs at least one Go source file")
+	}
+	return nil
+}
+
+// combinedSource lists every file of a multi-file submission in one text, in
+// path order, for showing the code and comparing it in plagiarism checks
+func combinedSource(files map[string]string) string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for i, name := range names {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "// ==> %s <==\n%s", name, files[name])
+		if !strings.HasSuffix(files[name], "\n") {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// readSourceArchive extracts the source files of a .zip, .tar, .tar.gz or .tgz
+// upload. Other files, such as a README, are skipped, and a directory holding
+// everything is stripped from the paths.
+func readSourceArchive(filename string, data []byte) (map[string]string, error) {
+	files := map[string]string{}
+	remaining := config.MaxSourceCodeBytes
+	add := func(name string, r io.Reader) error {
+		if !isSourceFile(name) {
+			return nil
+		}
+		content, err := io.ReadAll(io.LimitReader(r, int64(remaining)+1))
+		if err != nil {
+			return fmt.Errorf("failed to read %s from the archive: %w", name, err)
+		}
+		if len(content) > remaining {
+			return errSourceTooLarge
+		}
+		remaining -= len(content)
+		files[name] = string(content)
+		return nil
+	}
+
+	lower := strings.ToLower(filename)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid zip archive: %w", err)
+		}
+		for _, entry := range archive.File {
+			if entry.FileInfo().IsDir() {
+				continue
+			}
+			rc, err := entry.Open()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s from the archive: %w", entry.Name, err)
+			}
+			err = add(entry.Name, rc)
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+	case strings.HasSuffix(lower, ".tar"), strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		var r io.Reader = bytes.NewReader(data)
+		if !strings.HasSuffix(lower, ".tar") {
+			gz, err := gzip.NewReader(r)
+			if err != nil {
+				return nil, fmt.Errorf("invalid gzip archive: %w", err)
+			}
+			defer gz.Close()
+			r = gz
+		}
+		archive := tar.NewReader(r)
+		for {
+			header, err := archive.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid tar archive: %w", err)
+			}
+			if header.Typeflag != tar.TypeReg {
+				continue
+			}
+			if err := add(header.Name, archive); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, errors.New("archive must be a .zip, .tar, .tar.gz or .tgz file")
+	}
+
+	return stripCommonDir(files), nil
+}
+
+// stripCommonDir removes a leading directory shared by every path, as in an
+// archive of a project folder
+func stripCommonDir(files map[string]string) map[string]string {
+	dir := ""
+	for name := range files {
+		name = strings.TrimPrefix(name, "./")
+		first, _, found := strings.Cut(name, "/")
+		if !found || (dir != "" && first != dir) {
+			return trimDotSlash(files)
+		}
+		dir = first
+	}
+	stripped := make(map[string]string, len(files))
+	for name, content := range files {
+		stripped[strings.TrimPrefix(strings.TrimPrefix(name, "./"), dir+"/")] = content
+	}
+	return stripped
+}
+
+// trimDotSlash removes a leading ./ from every path
+func trimDotSlash(files map[string]string) map[string]string {
+	trimmed := make(map[string]string, len(files))
+	for name, content := range files {
+		trimmed[strings.TrimPrefix(name, "./")] = content
+	}
+	return trimmed
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+)
+
+// SubmissionUpdate is pushed to stream subscribers whenever a submission is
+// created, its verdict changes or one of its tests was judged
+type SubmissionUpdate struct {
+	ID             uint               `json:"id"`
+	QuestionID     uint               `json:"questionId"`
+	QuestionName   string             `json:"questionName"`
+	UserID         uint               `json:"userId"`
+	JudgeStatus    models.JudgeStatus `json:"judgeStatus"`
+	SubmissionTime time.Time          `json:"submissionTime"`
+	TestsDone      int                `json:"testsDone"`
+	TestsTotal     int                `json:"testsTotal"`
+}
+
+// streamHeartbeatInterval keeps idle streams from being closed by proxies
+const streamHeartbeatInterval = 30 * time.Second
+
+// submissionFeed fans submission updates out to every open stream
+var submissionFeed = struct {
+	mu          sync.Mutex
+	subscribers map[chan SubmissionUpdate]struct{}
+}{subscribers: map[chan SubmissionUpdate]struct{}{}}
+
+func subscribeSubmissions() chan SubmissionUpdate {
+	ch := make(chan SubmissionUpdate, 32)
+	submissionFeed.mu.Lock()
+	submissionFeed.subscribers[ch] = struct{}{}
+	submissionFeed.mu.Unlock()
+	return ch
+}
+
+func unsubscribeSubmissions(ch chan SubmissionUpdate) {
+	submissionFeed.mu.Lock()
+	delete(submissionFeed.subscribers, ch)
+	submissionFeed.mu.Unlock()
+}
+
+// publishSubmission notifies stream subscribers about a submission. Slow
+// subscribers miss updates rather than holding up judging.
+func publishSubmission(submission *models.Submission) {
+	update := SubmissionUpdate{
+		ID:             submission.ID,
+		QuestionID:     submission.QuestionID,
+		QuestionName:   submission.QuestionName,
+		UserID:         submission.UserID,
+		JudgeStatus:    submission.JudgeStatus,
+		SubmissionTime: submission.SubmissionTime,
+		TestsDone:      submission.TestsDone,
+		TestsTotal:     submission.TestsTotal,
+	}
+
+	submissionFeed.mu.Lock()
+	defer submissionFeed.mu.Unlock()
+	for ch := range submissionFeed.subscribers {
+		select {
+		case ch <- update:
+		default:
+		}
+	}
+}
+
+// SubmissionStreamHandler handles requests to /api/submissions/stream
+func SubmissionStreamHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		streamSubmissions(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// streamSubmissions pushes submission updates as Server-Sent Events. Users
+// receive their own submissions; admins can pass all=true to receive everyone's.
+// questionId narrows the stream to a single question.
+func streamSubmissions(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		apierror.Write(w, r, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	allUsers := false
+	if r.URL.Query().Get("all") == "true" {
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+			return
+		}
+		if user.Role != models.AdminRole {
+			apierror.Write(w, r, "Only administrators can stream other users' submissions", http.StatusForbidden)
+			return
+		}
+		allUsers = true
+	}
+
+	var questionID uint
+	if questionParam := r.URL.Query().Get("questionId"); questionParam != "" {
+		parsed, err := strconv.Atoi(questionParam)
+		if err != nil {
+			apierror.Write(w, r, "Invalid question ID", http.StatusBadRequest)
+			return
+		}
+		questionID = uint(parsed)
+	}
+
+	updates := subscribeSubmissions()
+	defer unsubscribeSubmissions(updates)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		case update := <-updates:
+			if !allUsers && update.UserID != userID {
+				continue
+			}
+			if questionID != 0 && update.QuestionID != questionID {
+				continue
+			}
+			payload, err := json.Marshal(update)
+			if err != nil {
+				log.Printf("JSON encoding error: %v", err)
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\nevent: submission\ndata: %s\n\n", update.ID, payload)
+			flusher.Flush()
+		}
+	}
+}
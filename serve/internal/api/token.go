@@ -0,0 +1,211 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+	"goera/serve/internal/validation"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// APITokenRequest represents the request body for creating a personal access token
+type APITokenRequest struct {
+	Name          string              `json:"name" validate:"required,max=100"`
+	Scopes        []models.TokenScope `json:"scopes" validate:"required,unique"`
+	ExpiresInDays int                 `json:"expiresInDays" validate:"min=0,max=3650"` // 0 for a token that does not expire
+}
+
+// Validate checks that every scope is known
+func (req *APITokenRequest) Validate(errs *validation.Errors) {
+	for i, scope := range req.Scopes {
+		if !scope.IsValid() {
+			errs.Add(fmt.Sprintf("scopes[%d]", i), "oneof", "%s must be one of %s", strings.Join([]string{string(models.ScopeRead), string(models.ScopeSubmit)}, ", "))
+		}
+	}
+}
+
+// APITokenCreatedResponse carries the plaintext token, which is only ever
+// returned once, next to the stored token
+type APITokenCreatedResponse struct {
+	models.APIToken
+	Token string `json:"token"`
+}
+
+// TokensHandler handles requests to /api/tokens
+func TokensHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getTokens(w, r)
+	case http.MethodPost:
+		createToken(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// TokenHandler handles requests to /api/tokens/{id}
+func TokenHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodDelete:
+		revokeToken(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getTokens lists the requesting user's tokens, newest first
+func getTokens(w http.ResponseWriter, r *http.Request) {
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	var tokens []models.APIToken
+	if err := db.Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve tokens", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(tokens); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+func createToken(w http.ResponseWriter, r *http.Request) {
+	var tokenReq APITokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&tokenReq); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+	if !validRequest(w, r, &tokenReq) {
+		return
+	}
+
+	tokenReq.Name = strings.TrimSpace(tokenReq.Name)
+	scopes := make([]string, 0, len(tokenReq.Scopes))
+	for _, scope := range tokenReq.Scopes {
+		scopes = append(scopes, string(scope))
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	plaintext, hash, prefix, err := auth.GenerateAPIToken()
+	if err != nil {
+		log.Printf("Error generating API token: %v", err)
+		apierror.Write(w, r, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	token := models.APIToken{
+		UserID:    userID,
+		Name:      tokenReq.Name,
+		Prefix:    prefix,
+		TokenHash: hash,
+		Scopes:    strings.Join(scopes, ","),
+	}
+	if tokenReq.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, tokenReq.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	if err := db.Create(&token).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to create token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(APITokenCreatedResponse{APIToken: token, Token: plaintext}); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// revokeToken revokes one of the requesting user's tokens. Revoked tokens are
+// kept so they still show up in the token list.
+func revokeToken(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid token ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	var token models.APIToken
+	if err := db.Where("user_id = ?", userID).First(&token, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Token not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve token", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if token.RevokedAt == nil {
+		now := time.Now()
+		token.RevokedAt = &now
+		if err := db.Model(&token).Update("revoked_at", now).Error; err != nil {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to revoke token", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(token); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
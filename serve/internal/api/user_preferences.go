@@ -0,0 +1,257 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/config"
+	"goera/serve/internal/database"
+	"goera/serve/internal/i18n"
+	"goera/serve/internal/models"
+	"goera/serve/internal/preferences"
+	"goera/serve/internal/utils"
+	"goera/serve/internal/validation"
+
+	"gorm.io/gorm"
+)
+
+// Bounds of the code editor settings
+const (
+	minEditorFontSize = 8
+	maxEditorFontSize = 32
+	minEditorTabSize  = 1
+	maxEditorTabSize  = 8
+)
+
+// UserPreferencesRequest changes a user's preferences. Fields left out keep
+// their current value.
+type UserPreferencesRequest struct {
+	Language       *string `json:"language"`
+	EditorTheme    *string `json:"editorTheme" validate:"oneof=light dark"`
+	EditorFontSize *int    `json:"editorFontSize"`
+	EditorTabSize  *int    `json:"editorTabSize"`
+	Timezone       *string `json:"timezone"`
+	Locale         *string `json:"locale"`
+	LoginAlerts    *bool   `json:"loginAlerts"`
+}
+
+// UserPreferencesHandler handles requests to /api/user/preferences
+func UserPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getUserPreferences(w, r)
+	case http.MethodPut, http.MethodPost:
+		updateUserPreferences(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func getUserPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	prefs, err := preferences.Load(r.Context(), db, userID)
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve preferences", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(prefs); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// updateUserPreferences saves the user's preferences. A changed locale is
+// also stored in the language cookie, which would otherwise take precedence.
+// Form posts from the profile page are redirected back to it.
+func updateUserPreferences(w http.ResponseWriter, r *http.Request) {
+	var prefsReq UserPreferencesRequest
+	formProcessor := func(r *http.Request) (interface{}, error) {
+		var req UserPreferencesRequest
+		for name, target := range map[string]**string{
+			"language":    &req.Language,
+			"editorTheme": &req.EditorTheme,
+			"timezone":    &req.Timezone,
+			"locale":      &req.Locale,
+		} {
+			if values, ok := r.PostForm[name]; ok && len(values) > 0 {
+				value := values[0]
+				*target = &value
+			}
+		}
+		for name, target := range map[string]**int{
+			"editorFontSize": &req.EditorFontSize,
+			"editorTabSize":  &req.EditorTabSize,
+		} {
+			if value := r.PostFormValue(name); value != "" {
+				n, err := strconv.Atoi(value)
+				if err != nil {
+					return nil, fmt.Errorf("%s must be a number", name)
+				}
+				*target = &n
+			}
+		}
+		// A checkbox follows a hidden field of the same name, so the last
+		// value is the one that counts
+		if values := r.PostForm["loginAlerts"]; len(values) > 0 {
+			enabled := values[len(values)-1] == "true"
+			req.LoginAlerts = &enabled
+		}
+		return req, nil
+	}
+	result, err := utils.ProcessRequestData(r, &prefsReq, formProcessor)
+	if err != nil {
+		writeRequestDataError(w, r, err)
+		return
+	}
+	if formData, ok := result.(UserPreferencesRequest); ok {
+		prefsReq = formData
+	}
+	if !validRequest(w, r, &prefsReq) {
+		return
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	var prefs models.UserPreferences
+	err = db.Where("user_id = ?", userID).First(&prefs).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		prefs = models.DefaultUserPreferences(userID)
+	} else if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve preferences", http.StatusInternalServerError)
+		return
+	}
+	previousLocale := prefs.Locale
+
+	applyUserPreferences(&prefs, prefsReq)
+
+	if err := db.Save(&prefs).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to save preferences", http.StatusInternalServerError)
+		return
+	}
+	preferences.Invalidate(r.Context(), userID)
+
+	if prefs.Locale != previousLocale {
+		cookie := &http.Cookie{
+			Name:     i18n.CookieName,
+			Value:    prefs.Locale,
+			Path:     "/",
+			Expires:  time.Now().AddDate(1, 0, 0),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		}
+		if prefs.Locale == "" {
+			// Following the browser again
+			cookie.Expires = time.Time{}
+			cookie.MaxAge = -1
+		}
+		http.SetCookie(w, cookie)
+	}
+
+	if utils.IsFormRequest(r) {
+		http.Redirect(w, r, fmt.Sprintf("/profile/%d?success=preferences_saved", userID), http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(prefs); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// Validate checks the fields set in req
+func (req *UserPreferencesRequest) Validate(errs *validation.Errors) {
+	if req.Language != nil {
+		if language := strings.TrimSpace(*req.Language); language != "" && !slices.Contains(config.SupportedLanguages, language) {
+			errs.Add("language", "oneof", "%s must be one of %s", strings.Join(config.SupportedLanguages, ", "))
+		}
+	}
+	if req.EditorFontSize != nil {
+		errs.Range("editorFontSize", *req.EditorFontSize, minEditorFontSize, maxEditorFontSize)
+	}
+	if req.EditorTabSize != nil {
+		errs.Range("editorTabSize", *req.EditorTabSize, minEditorTabSize, maxEditorTabSize)
+	}
+	if req.Timezone != nil {
+		timezone := preferenceTimezone(*req.Timezone)
+		// Local would be the server's zone, not the user's
+		if _, err := time.LoadLocation(timezone); err != nil || timezone == "Local" {
+			errs.Add("timezone", "timezone", "%s must be an IANA time zone such as Asia/Tehran")
+		}
+	}
+	if req.Locale != nil && *req.Locale != "" && !i18n.IsSupported(*req.Locale) {
+		errs.Add("locale", "oneof", "%s must be one of %s", strings.Join(i18n.Locales(), ", "))
+	}
+}
+
+// preferenceTimezone is the timezone a request sets, UTC when it is blank
+func preferenceTimezone(timezone string) string {
+	if timezone = strings.TrimSpace(timezone); timezone == "" {
+		return "UTC"
+	}
+	return timezone
+}
+
+// applyUserPreferences copies the fields set in req, once validated, to prefs
+func applyUserPreferences(prefs *models.UserPreferences, req UserPreferencesRequest) {
+	if req.Language != nil {
+		prefs.Language = strings.TrimSpace(*req.Language)
+	}
+	if req.EditorTheme != nil {
+		prefs.EditorTheme = *req.EditorTheme
+	}
+	if req.EditorFontSize != nil {
+		prefs.EditorFontSize = *req.EditorFontSize
+	}
+	if req.EditorTabSize != nil {
+		prefs.EditorTabSize = *req.EditorTabSize
+	}
+	if req.Timezone != nil {
+		prefs.Timezone = preferenceTimezone(*req.Timezone)
+	}
+	if req.Locale != nil {
+		prefs.Locale = *req.Locale
+	}
+	if req.LoginAlerts != nil {
+		prefs.LoginAlerts = *req.LoginAlerts
+	}
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+	"goera/serve/internal/utils"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// PublicSubmission is what anyone may see of another user's submission. The
+// code, output and errors stay private to the author.
+type PublicSubmission struct {
+	ID             uint               `json:"id"`
+	QuestionID     uint               `json:"questionId"`
+	QuestionName   string             `json:"questionName"`
+	Language       string             `json:"language"`
+	JudgeStatus    models.JudgeStatus `json:"judgeStatus"`
+	ExecutionTime  int                `json:"executionTime"`
+	MemoryUsage    int                `json:"memoryUsage"`
+	SubmissionTime time.Time          `json:"submissionTime"`
+}
+
+// SolvedQuestion is a question a user has an accepted submission for
+type SolvedQuestion struct {
+	QuestionID uint      `json:"questionId"`
+	Title      string    `json:"title"`
+	SolvedAt   time.Time `json:"solvedAt"` // Time of the first accepted submission
+}
+
+// SolvedResponse lists a user's solved questions next to the number of
+// questions they submitted to at all
+type SolvedResponse struct {
+	Attempted int64            `json:"attempted"`
+	Solved    []SolvedQuestion `json:"solved"`
+}
+
+// UserSubmissionsHandler handles requests to /api/user/{id}/submissions
+func UserSubmissionsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getPublicSubmissions(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// UserSolvedHandler handles requests to /api/user/{id}/solved
+func UserSolvedHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getSolvedQuestions(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// publicSubmissions returns a query over the submissions of userID that
+// anyone may see: those on published questions that were not made in a
+// contest that is still running
+func publicSubmissions(db *gorm.DB, userID uint) *gorm.DB {
+	return allPublicSubmissions(db).Where("submissions.user_id = ?", userID)
+}
+
+// allPublicSubmissions is publicSubmissions for every user
+func allPublicSubmissions(db *gorm.DB) *gorm.DB {
+	return db.Model(&models.Submission{}).
+		Joins("JOIN questions ON questions.id = submissions.question_id AND questions.deleted_at IS NULL").
+		Where("questions.published = ?", true).
+		Where("submissions.contest_id IS NULL OR submissions.contest_id IN (?)",
+			db.Model(&models.Contest{}).Select("id").Where("end_time <= ?", time.Now()))
+}
+
+// profileUser loads the user named in the URL, writing an error response and
+// returning false when there is none
+func profileUser(w http.ResponseWriter, r *http.Request, db *gorm.DB) (*models.User, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid user ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	var user models.User
+	if err := db.First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "User not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+	return &user, true
+}
+
+// getPublicSubmissions lists a user's public submissions, newest first
+func getPublicSubmissions(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	user, ok := profileUser(w, r, db)
+	if !ok {
+		return
+	}
+
+	pagination := utils.ParsePagination(r, "user_submissions")
+
+	var totalItems int64
+	if err := publicSubmissions(db, user.ID).Count(&totalItems).Error; err != nil {
+		log.Printf("Database error counting submissions: %v", err)
+		apierror.Write(w, r, "Failed to count submissions", http.StatusInternalServerError)
+		return
+	}
+
+	submissions := []PublicSubmission{}
+	err := publicSubmissions(db, user.ID).
+		Select("submissions.id, submissions.question_id, questions.title AS question_name, submissions.language, " +
+			"submissions.judge_status, submissions.execution_time, submissions.memory_usage, submissions.submission_time").
+		Order("submissions.submission_time DESC").
+		Limit(pagination.PageSize).Offset(pagination.Offset()).
+		Scan(&submissions).Error
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve submissions", http.StatusInternalServerError)
+		return
+	}
+
+	totalPages := utils.TotalPages(totalItems, pagination.PageSize)
+	response := PaginatedResponse{
+		Data:       submissions,
+		Page:       pagination.Page,
+		PageSize:   pagination.PageSize,
+		TotalItems: totalItems,
+		TotalPages: totalPages,
+	}
+
+	utils.SetPageLinks(w, r, pagination, totalPages)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// getSolvedQuestions lists the questions a user solved, most recently solved first
+func getSolvedQuestions(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	user, ok := profileUser(w, r, db)
+	if !ok {
+		return
+	}
+
+	response := SolvedResponse{Solved: []SolvedQuestion{}}
+	if err := publicSubmissions(db, user.ID).Distinct("submissions.question_id").Count(&response.Attempted).Error; err != nil {
+		log.Printf("Database error counting attempted questions: %v", err)
+		apierror.Write(w, r, "Failed to count attempted questions", http.StatusInternalServerError)
+		return
+	}
+
+	// The first accepted submission per question marks when it was solved
+	var accepted []SolvedQuestion
+	err := publicSubmissions(db, user.ID).
+		Select("submissions.question_id, questions.title, submissions.submission_time AS solved_at").
+		Where("submissions.judge_status = ?", models.Accepted).
+		Order("submissions.submission_time ASC").
+		Scan(&accepted).Error
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve solved questions", http.StatusInternalServerError)
+		return
+	}
+	seen := make(map[uint]bool, len(accepted))
+	for _, question := range accepted {
+		if !seen[question.QuestionID] {
+			seen[question.QuestionID] = true
+			response.Solved = append(response.Solved, question)
+		}
+	}
+	slices.Reverse(response.Solved)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/i18n"
+	"goera/serve/internal/validation"
+)
+
+// validRequest checks a decoded request against its validate tags and
+// Validate method. When fields are invalid it writes them as a 400 with one
+// detail per field and returns false.
+func validRequest(w http.ResponseWriter, r *http.Request, req interface{}) bool {
+	errs := validation.Struct(req)
+	if errs == nil {
+		return true
+	}
+	writeInvalidFields(w, r, errs)
+	return false
+}
+
+// writeBodyError writes the error for a request body that could not be
+// decoded, naming the field when one held a value of the wrong type
+func writeBodyError(w http.ResponseWriter, r *http.Request, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		apierror.Write(w, r, fmt.Sprintf("Request body exceeds the maximum size of %d bytes", maxBytesErr.Limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if errs := validation.DecodeError(err); errs != nil {
+		writeInvalidFields(w, r, errs)
+		return
+	}
+	apierror.Write(w, r, "Invalid request body", http.StatusBadRequest)
+}
+
+// writeRequestDataError writes the error returned by utils.ProcessRequestData,
+// whose form processors describe what is wrong with the form
+func writeRequestDataError(w http.ResponseWriter, r *http.Request, err error) {
+	if errs := validation.DecodeError(err); errs != nil {
+		writeInvalidFields(w, r, errs)
+		return
+	}
+	apierror.Write(w, r, err.Error(), http.StatusBadRequest)
+}
+
+// writeInvalidFields writes errs in the locale of the request, their joined
+// messages making up the error message for clients that show only that
+func writeInvalidFields(w http.ResponseWriter, r *http.Request, errs validation.Errors) {
+	errs = errs.Translate(i18n.FromContext(r.Context()))
+	apierror.WriteDetails(w, r, errs.Error(), http.StatusBadRequest, errs)
+}
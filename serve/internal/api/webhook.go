@@ -0,0 +1,440 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/config"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+	"goera/serve/internal/validation"
+	"goera/serve/internal/webhooks"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// Webhook events
+const (
+	webhookEventJudged = "submission.judged" // A submission got a final verdict
+	webhookEventPing   = "ping"              // Sent on request, to try a webhook out
+)
+
+// maxWebhooksPerUser bounds the webhooks one user can register
+const maxWebhooksPerUser = 10
+
+// webhookDeliveriesShown is how many recent deliveries of a webhook are listed
+const webhookDeliveriesShown = 50
+
+// WebhookRequest represents the request body for registering a webhook
+type WebhookRequest struct {
+	URL     string `json:"url" validate:"required,max=2048"`
+	Secret  string `json:"secret" validate:"omitempty,min=16,max=256"` // Generated when empty
+	GroupID *uint  `json:"groupId"`                                    // Set to watch a group the user owns
+}
+
+// Validate checks that the webhook URL can be delivered to
+func (req *WebhookRequest) Validate(errs *validation.Errors) {
+	if req.URL != "" && webhooks.ValidateURL(strings.TrimSpace(req.URL)) != nil {
+		errs.Add("url", "url", "%s must be a public http or https URL")
+	}
+}
+
+// WebhookCreatedResponse carries the webhook's secret, which is only ever
+// returned once, next to the stored webhook
+type WebhookCreatedResponse struct {
+	models.Webhook
+	Secret string `json:"secret"`
+}
+
+// WebhookSubmission describes a judged submission in a webhook payload
+type WebhookSubmission struct {
+	ID            uint               `json:"id"`
+	UserID        uint               `json:"userId"`
+	Username      string             `json:"username"`
+	QuestionID    uint               `json:"questionId"`
+	QuestionName  string             `json:"questionName"`
+	ContestID     *uint              `json:"contestId"`
+	Language      string             `json:"language"`
+	Verdict       models.JudgeStatus `json:"verdict"`
+	ExecutionTime int                `json:"executionTime"` // Milliseconds
+	MemoryUsage   int                `json:"memoryUsage"`   // Megabytes
+	SubmittedAt   time.Time          `json:"submittedAt"`
+	URL           string             `json:"url"` // Submission page
+}
+
+// WebhookPayload is the body POSTed to a webhook
+type WebhookPayload struct {
+	Event      string             `json:"event"`
+	GroupID    *uint              `json:"groupId,omitempty"` // The group a group webhook watches
+	Submission *WebhookSubmission `json:"submission,omitempty"`
+	SentAt     time.Time          `json:"sentAt"`
+}
+
+// WebhooksHandler handles requests to /api/webhooks
+func WebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getWebhooks(w, r)
+	case http.MethodPost:
+		createWebhook(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// WebhookHandler handles requests to /api/webhooks/{id}
+func WebhookHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodDelete:
+		deleteWebhook(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// WebhookDeliveriesHandler handles requests to /api/webhooks/{id}/deliveries
+func WebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getWebhookDeliveries(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// WebhookPingHandler handles requests to /api/webhooks/{id}/ping
+func WebhookPingHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		pingWebhook(w, r)
+	default:
+		apierror.Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getWebhooks lists the webhooks the requesting user registered, newest first
+func getWebhooks(w http.ResponseWriter, r *http.Request) {
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	hooks := []models.Webhook{}
+	if err := db.Where("user_id = ?", userID).Order("created_at DESC").Find(&hooks).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve webhooks", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(hooks); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// createWebhook registers a webhook for the requesting user's submissions, or
+// for those of a group they own
+func createWebhook(w http.ResponseWriter, r *http.Request) {
+	var webhookReq WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&webhookReq); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+	if !validRequest(w, r, &webhookReq) {
+		return
+	}
+	webhookReq.URL = strings.TrimSpace(webhookReq.URL)
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return
+	}
+
+	if webhookReq.GroupID != nil {
+		var group models.Group
+		if err := db.First(&group, *webhookReq.GroupID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				apierror.Write(w, r, "Group not found", http.StatusNotFound)
+			} else {
+				log.Printf("Database error: %v", err)
+				apierror.Write(w, r, "Failed to retrieve group", http.StatusInternalServerError)
+			}
+			return
+		}
+		if group.OwnerID != userID {
+			apierror.Write(w, r, "Only the group owner can add webhooks to the group", http.StatusForbidden)
+			return
+		}
+	}
+
+	var count int64
+	if err := db.Model(&models.Webhook{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to create webhook", http.StatusInternalServerError)
+		return
+	}
+	if count >= maxWebhooksPerUser {
+		apierror.Write(w, r, fmt.Sprintf("You can register at most %d webhooks", maxWebhooksPerUser), http.StatusConflict)
+		return
+	}
+
+	secret := webhookReq.Secret
+	if secret == "" {
+		var err error
+		if secret, err = webhooks.GenerateSecret(); err != nil {
+			log.Printf("Error generating webhook secret: %v", err)
+			apierror.Write(w, r, "Failed to create webhook", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	hook := models.Webhook{
+		UserID:  userID,
+		GroupID: webhookReq.GroupID,
+		URL:     webhookReq.URL,
+		Secret:  secret,
+	}
+	if err := db.Create(&hook).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to create webhook", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(WebhookCreatedResponse{Webhook: hook, Secret: secret}); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// findOwnWebhook loads the webhook in the path, which the requesting user must
+// have registered, writing an error response when that fails
+func findOwnWebhook(w http.ResponseWriter, r *http.Request) (*gorm.DB, *models.Webhook, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		apierror.Write(w, r, "Invalid webhook ID", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	userID, userExists := auth.UserIDFromContext(r.Context())
+	if !userExists {
+		log.Println("User ID not found in context")
+		apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		log.Println("Database connection is nil")
+		apierror.Write(w, r, "Database connection error", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+
+	var hook models.Webhook
+	if err := db.Where("user_id = ?", userID).First(&hook, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Write(w, r, "Webhook not found", http.StatusNotFound)
+		} else {
+			log.Printf("Database error: %v", err)
+			apierror.Write(w, r, "Failed to retrieve webhook", http.StatusInternalServerError)
+		}
+		return nil, nil, false
+	}
+	return db, &hook, true
+}
+
+// deleteWebhook removes one of the requesting user's webhooks
+func deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	db, hook, ok := findOwnWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	if err := db.Delete(hook).Error; err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to delete webhook", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getWebhookDeliveries lists the latest deliveries of one of the requesting
+// user's webhooks, newest first
+func getWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	db, hook, ok := findOwnWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	deliveries := []models.WebhookDelivery{}
+	err := db.Where("webhook_id = ?", hook.ID).Order("id DESC").Limit(webhookDeliveriesShown).Find(&deliveries).Error
+	if err != nil {
+		log.Printf("Database error: %v", err)
+		apierror.Write(w, r, "Failed to retrieve webhook deliveries", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(deliveries); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// pingWebhook sends a ping event to one of the requesting user's webhooks and
+// reports how it went, so the receiving end can be tried out
+func pingWebhook(w http.ResponseWriter, r *http.Request) {
+	db, hook, ok := findOwnWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	delivery := deliverWebhook(db, hook, 0, WebhookPayload{Event: webhookEventPing, GroupID: hook.GroupID}, 1)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(delivery); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+		apierror.Write(w, r, "Failed to encode response", http.StatusInternalServerError)
+	}
+}
+
+// watchingWebhooks returns the webhooks watching submission: its author's own
+// and those of the groups the author is in that assigned its question
+func watchingWebhooks(db *gorm.DB, submission *models.Submission) ([]models.Webhook, error) {
+	groups := db.Model(&models.GroupMember{}).
+		Select("group_members.group_id").
+		Joins("JOIN groups ON groups.id = group_members.group_id AND groups.deleted_at IS NULL").
+		Joins("JOIN assignments ON assignments.group_id = group_members.group_id AND assignments.deleted_at IS NULL").
+		Joins("JOIN assignment_questions ON assignment_questions.assignment_id = assignments.id AND assignment_questions.deleted_at IS NULL").
+		Where("group_members.user_id = ? AND assignment_questions.question_id = ?", submission.UserID, submission.QuestionID)
+
+	var hooks []models.Webhook
+	err := db.Where("(user_id = ? AND group_id IS NULL) OR group_id IN (?)", submission.UserID, groups).
+		Find(&hooks).Error
+	return hooks, err
+}
+
+// sendVerdictWebhooks tells the webhooks watching submission about its final
+// verdict. Deliveries run in the background, so a slow webhook does not hold
+// up the judge.
+func sendVerdictWebhooks(db *gorm.DB, submission *models.Submission) {
+	hooks, err := watchingWebhooks(db, submission)
+	if err != nil {
+		log.Printf("Failed to find webhooks of submission %d: %v", submission.ID, err)
+		return
+	}
+	if len(hooks) == 0 {
+		return
+	}
+
+	var author models.User
+	if err := db.Select("id", "username").First(&author, submission.UserID).Error; err != nil {
+		log.Printf("Failed to load the author of submission %d: %v", submission.ID, err)
+	}
+	event := &WebhookSubmission{
+		ID:            submission.ID,
+		UserID:        submission.UserID,
+		Username:      author.Username,
+		QuestionID:    submission.QuestionID,
+		QuestionName:  submission.QuestionName,
+		ContestID:     submission.ContestID,
+		Language:      submission.Language,
+		Verdict:       submission.JudgeStatus,
+		ExecutionTime: submission.ExecutionTime,
+		MemoryUsage:   submission.MemoryUsage,
+		SubmittedAt:   submission.SubmissionTime,
+		URL:           fmt.Sprintf("%s/submission/%d", strings.TrimSuffix(config.PublicURL, "/"), submission.ID),
+	}
+
+	for i := range hooks {
+		hook := hooks[i]
+		payload := WebhookPayload{Event: webhookEventJudged, GroupID: hook.GroupID, Submission: event}
+		go deliverWebhook(db.WithContext(context.Background()), &hook, submission.ID, payload, config.WebhookMaxAttempts)
+	}
+}
+
+// deliverWebhook POSTs payload to hook, trying up to attempts times with
+// pauses of 1, 2, 4... seconds in between, and records the delivery
+func deliverWebhook(db *gorm.DB, hook *models.Webhook, submissionID uint, payload WebhookPayload, attempts int) *models.WebhookDelivery {
+	delivery := &models.WebhookDelivery{
+		WebhookID:    hook.ID,
+		SubmissionID: submissionID,
+		DeliveryID:   webhooks.NewDeliveryID(),
+	}
+	if err := db.Create(delivery).Error; err != nil {
+		log.Printf("Failed to record delivery to webhook %d: %v", hook.ID, err)
+	}
+
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(time.Duration(1<<(attempt-2)) * time.Second)
+		}
+		payload.SentAt = time.Now().UTC()
+		body, err := json.Marshal(payload)
+		if err != nil {
+			log.Printf("Failed to encode webhook payload: %v", err)
+			break
+		}
+
+		status, err := webhooks.Send(context.Background(), hook.URL, hook.Secret, payload.Event, delivery.DeliveryID, body)
+		delivery.Attempts = attempt
+		delivery.Status = status
+		delivery.Delivered = err == nil
+		delivery.Error = ""
+		if err != nil {
+			delivery.Error = err.Error()
+		}
+		if delivery.Delivered {
+			break
+		}
+	}
+
+	now := time.Now()
+	if err := db.Save(delivery).Error; err != nil {
+		log.Printf("Failed to record delivery to webhook %d: %v", hook.ID, err)
+	}
+	err := db.Model(&models.Webhook{}).Where("id = ?", hook.ID).Updates(map[string]interface{}{
+		"last_status":       delivery.Status,
+		"last_error":        delivery.Error,
+		"last_delivered_at": now,
+	}).Error
+	if err != nil {
+		log.Printf("Failed to update webhook %d: %v", hook.ID, err)
+	}
+	if !delivery.Delivered {
+		log.Printf("Webhook %d: giving up on %s delivery %s after %d attempts: %s",
+			hook.ID, payload.Event, delivery.DeliveryID, delivery.Attempts, delivery.Error)
+	}
+	return delivery
+}
@@ -0,0 +1,93 @@
+// Package apierror writes the JSON error responses returned by /api routes.
+package apierror
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"goera/serve/internal/i18n"
+)
+
+// Response is the body of every /api error
+type Response struct {
+	Code      string      `json:"code"`              // Stable, machine readable error code
+	Message   string      `json:"message"`           // Human readable description
+	Details   interface{} `json:"details,omitempty"` // Optional extra context, e.g. invalid fields
+	RequestID string      `json:"request_id,omitempty"`
+}
+
+// codes maps status codes to error codes. Statuses not listed here use their
+// status text in snake case.
+var codes = map[int]string{
+	http.StatusBadRequest:            "bad_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusMethodNotAllowed:      "method_not_allowed",
+	http.StatusConflict:              "conflict",
+	http.StatusRequestEntityTooLarge: "payload_too_large",
+	http.StatusUnprocessableEntity:   "unprocessable_entity",
+	http.StatusTooManyRequests:       "rate_limited",
+	http.StatusInternalServerError:   "internal_error",
+	http.StatusBadGateway:            "bad_gateway",
+	http.StatusServiceUnavailable:    "service_unavailable",
+	http.StatusGatewayTimeout:        "gateway_timeout",
+}
+
+// Code returns the error code used for status
+func Code(status int) string {
+	if code, ok := codes[status]; ok {
+		return code
+	}
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+}
+
+// Write sends message as a JSON error with the given status. It takes the same
+// arguments as http.Error plus the request, which carries the request ID.
+func Write(w http.ResponseWriter, r *http.Request, message string, status int) {
+	WriteDetails(w, r, message, status, nil)
+}
+
+// WriteDetails is Write with extra details attached to the error. The message
+// is translated into the locale of the request; the code is not.
+func WriteDetails(w http.ResponseWriter, r *http.Request, message string, status int, details interface{}) {
+	response := Response{
+		Code:    Code(status),
+		Message: message,
+		Details: details,
+	}
+	if r != nil {
+		response.Message = i18n.T(i18n.FromContext(r.Context()), message)
+		response.RequestID = RequestIDFromContext(r.Context())
+	}
+
+	h := w.Header()
+	// Drop headers set for a successful response, as http.Error does
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("JSON encoding error: %v", err)
+	}
+}
+
+// NotFoundHandler answers unknown /api routes with a JSON error
+func NotFoundHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Write(w, r, "Not found", http.StatusNotFound)
+	})
+}
+
+// MethodNotAllowedHandler answers /api routes called with an unsupported method
+func MethodNotAllowedHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Write(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	})
+}
@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+const (
+	anonSessionCookie = "anon_session"
+	anonSessionTTL    = 24 * time.Hour
+)
+
+// AnonymousSessionFromContext returns the ephemeral practice session of a
+// visitor who is not logged in. Anonymous sessions have no database user.
+func AnonymousSessionFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(anonSessionKey).(string)
+	return id, ok && id != ""
+}
+
+// anonymousSession returns the visitor's ephemeral session ID, issuing a new
+// session cookie if the request does not carry one
+func anonymousSession(w http.ResponseWriter, r *http.Request) string {
+	if cookie, err := r.Cookie(anonSessionCookie); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return ""
+	}
+	id := hex.EncodeToString(buf)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     anonSessionCookie,
+		Value:    id,
+		Path:     "/",
+		Expires:  time.Now().Add(anonSessionTTL),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return id
+}
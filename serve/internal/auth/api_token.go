@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+)
+
+// APITokenPrefix marks personal access tokens so they are not mistaken for JWTs
+const APITokenPrefix = "goera_pat_"
+
+// apiTokenTouchInterval limits how often last_used_at is written for a busy token
+const apiTokenTouchInterval = time.Minute
+
+// GenerateAPIToken returns a new random personal access token together with the
+// hash to store and a short prefix to display
+func GenerateAPIToken() (token, hash, prefix string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	token = APITokenPrefix + hex.EncodeToString(b)
+	return token, HashAPIToken(token), token[:len(APITokenPrefix)+6], nil
+}
+
+// HashAPIToken hashes a personal access token for storage. Tokens are random
+// and long, so a fast hash is enough unlike for passwords.
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ValidateAPIToken looks up an active personal access token and records its use
+func ValidateAPIToken(token string) (*models.APIToken, error) {
+	db := database.GetDB()
+	if db == nil {
+		return nil, errors.New("database connection failed")
+	}
+
+	var apiToken models.APIToken
+	if err := db.Where("token_hash = ?", HashAPIToken(token)).First(&apiToken).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if !apiToken.Active(now) {
+		return nil, errors.New("token revoked or expired")
+	}
+
+	if apiToken.LastUsedAt == nil || now.Sub(*apiToken.LastUsedAt) > apiTokenTouchInterval {
+		db.Model(&apiToken).UpdateColumn("last_used_at", now)
+	}
+
+	return &apiToken, nil
+}
+
+// tokenAllows reports whether a request may be made with a personal access
+// token. Read requests need the read scope and running, compiling or
+// submitting code the submit scope; anything else, including managing tokens,
+// needs a login session.
+func tokenAllows(token *models.APIToken, r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		return token.HasScope(models.ScopeRead)
+	case http.MethodPost:
+		path := strings.TrimSuffix(r.URL.Path, "/")
+		if path == "/api/submissions" || path == "/api/run" || path == "/api/compile-check" {
+			return token.HasScope(models.ScopeSubmit)
+		}
+	}
+	return false
+}
+
+// APITokenIDFromContext returns the ID of the personal access token a request
+// was made with. Requests made with a login session have none.
+func APITokenIDFromContext(ctx context.Context) (uint, bool) {
+	id, ok := ctx.Value(apiTokenIDKey).(uint)
+	return id, ok
+}
@@ -1,19 +1,25 @@
 package auth
 
 import (
-	"net/http"
-	"os"
+	"goera/proto/signing"
+	"goera/serve/internal/config"
 )
 
-func InternalAuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		providedKey := r.Header.Get("X-API-Key")
-		validKey := os.Getenv("INTERNAL_API_KEY")
+// internalSigner signs and verifies the internal gRPC calls with the keys in
+// config, so keys reloaded from the config file apply right away
+var internalSigner = signing.New(func() signing.Keys {
+	return signing.Keys{
+		Secrets:      config.InternalKeys,
+		SigningKeyID: config.InternalKeyID,
+		MaxSkew:      config.InternalSignatureMaxSkew,
+	}
+})
 
-		if providedKey != validKey {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-		next.ServeHTTP(w, r)
-	})
-}
+var (
+	// SignUnary is a client interceptor signing every internal call with the
+	// active key
+	SignUnary = internalSigner.SignUnary
+	// VerifyUnary rejects internal calls that are not signed with one of the
+	// configured keys, are outside the allowed clock skew, or are replayed
+	VerifyUnary = internalSigner.VerifyUnary
+)
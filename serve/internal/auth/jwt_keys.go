@@ -0,0 +1,214 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"goera/serve/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwtKey is one key of the token keyset, identified in tokens by the kid header
+type jwtKey struct {
+	id     string
+	method jwt.SigningMethod
+	sign   interface{} // nil for keys that only verify
+	verify interface{}
+}
+
+// jwtKeySet holds the keys tokens are checked against and the one new tokens
+// are signed with. A nil signing key signs with the legacy JWT secret.
+type jwtKeySet struct {
+	keys    map[string]*jwtKey
+	signing *jwtKey
+}
+
+var (
+	keySetMu sync.RWMutex
+	keySet   = &jwtKeySet{keys: map[string]*jwtKey{}}
+)
+
+// currentKeySet returns the keyset in use
+func currentKeySet() *jwtKeySet {
+	keySetMu.RLock()
+	defer keySetMu.RUnlock()
+	return keySet
+}
+
+// LoadJWTKeys reads the keys in config.JWTKeysDir and swaps them in. Without
+// an explicit config.JWTSigningKeyID, the signing key is the one with the
+// greatest ID that can sign, so naming keys by date rotates them in order.
+func LoadJWTKeys() error {
+	set := &jwtKeySet{keys: map[string]*jwtKey{}}
+	if config.JWTKeysDir != "" {
+		entries, err := os.ReadDir(config.JWTKeysDir)
+		if err != nil {
+			return fmt.Errorf("failed to read JWT keys directory: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			key, err := loadJWTKey(filepath.Join(config.JWTKeysDir, entry.Name()))
+			if err != nil {
+				return err
+			}
+			if key != nil {
+				set.keys[key.id] = key
+			}
+		}
+	}
+
+	if config.JWTSigningKeyID != "" {
+		key, ok := set.keys[config.JWTSigningKeyID]
+		if !ok {
+			return fmt.Errorf("JWT signing key %q not found in %s", config.JWTSigningKeyID, config.JWTKeysDir)
+		}
+		if key.sign == nil {
+			return fmt.Errorf("JWT signing key %q is a public key and cannot sign", key.id)
+		}
+		set.signing = key
+	} else {
+		ids := make([]string, 0, len(set.keys))
+		for id, key := range set.keys {
+			if key.sign != nil {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+		if len(ids) > 0 {
+			set.signing = set.keys[ids[len(ids)-1]]
+		}
+	}
+
+	keySetMu.Lock()
+	keySet = set
+	keySetMu.Unlock()
+	return nil
+}
+
+// loadJWTKey parses a key file, returning nil for files that are not keys
+func loadJWTKey(path string) (*jwtKey, error) {
+	ext := filepath.Ext(path)
+	id := strings.TrimSuffix(filepath.Base(path), ext)
+	if ext != ".pem" && ext != ".secret" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWT key %s: %w", path, err)
+	}
+
+	if ext == ".secret" {
+		secret := []byte(strings.TrimSpace(string(data)))
+		if len(secret) == 0 {
+			return nil, fmt.Errorf("JWT key %s is empty", path)
+		}
+		return &jwtKey{id: id, method: jwt.SigningMethodHS256, sign: secret, verify: secret}, nil
+	}
+
+	if private, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+		return &jwtKey{id: id, method: jwt.SigningMethodRS256, sign: private, verify: &private.PublicKey}, nil
+	}
+	public, err := jwt.ParseRSAPublicKeyFromPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("JWT key %s is not an RSA private or public key: %w", path, err)
+	}
+	return &jwtKey{id: id, method: jwt.SigningMethodRS256, verify: public}, nil
+}
+
+// WatchJWTKeys reloads the keys every config.JWTKeysReloadInterval. A failed
+// reload keeps the previous keys so a half-written key file cannot lock
+// everyone out.
+func WatchJWTKeys() {
+	if config.JWTKeysDir == "" {
+		return
+	}
+
+	ticker := time.NewTicker(config.JWTKeysReloadInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := LoadJWTKeys(); err != nil {
+			log.Printf("Failed to reload JWT keys, keeping the current ones: %v", err)
+		}
+	}
+}
+
+// tokenKey returns the key a token must be verified with, checking that the
+// token uses the key's algorithm
+func tokenKey(token *jwt.Token) (interface{}, error) {
+	set := currentKeySet()
+
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		// Tokens issued before key rotation, or while only JWT_SECRET is set
+		if config.JWTSecret == "" && len(set.keys) > 0 {
+			return nil, errors.New("token has no key ID")
+		}
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(config.JWTSecret), nil
+	}
+
+	key, ok := set.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %v for key %q", token.Header["alg"], kid)
+	}
+	return key.verify, nil
+}
+
+// JSONWebKey is the public half of an RS256 key in JWK format
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Modulus   string `json:"n"`
+	Exponent  string `json:"e"`
+}
+
+// JSONWebKeySet lists the public keys other services can verify tokens with
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// PublicJWKS returns the RS256 keys of the keyset in JWK format. HS256 secrets
+// are never published.
+func PublicJWKS() JSONWebKeySet {
+	set := currentKeySet()
+
+	jwks := JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, key := range set.keys {
+		public, ok := key.verify.(*rsa.PublicKey)
+		if !ok {
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, JSONWebKey{
+			KeyType:   "RSA",
+			KeyID:     key.id,
+			Use:       "sig",
+			Algorithm: key.method.Alg(),
+			Modulus:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+			Exponent:  base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+		})
+	}
+	sort.Slice(jwks.Keys, func(i, j int) bool { return jwks.Keys[i].KeyID < jwks.Keys[j].KeyID })
+	return jwks
+}
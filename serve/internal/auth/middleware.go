@@ -1,72 +1,131 @@
-package auth
-
-import (
-	"context"
-	"goera/serve/internal/config"
-	"net/http"
-	"strings"
-)
-
-func Middleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var userID uint
-		var hasValidToken bool
-
-		path := r.URL.Path
-		isApiReq := strings.HasPrefix(path, "/api")
-
-		authHeader := r.Header.Get("Authorization")
-		if strings.HasPrefix(authHeader, "Bearer ") {
-			tokenString := authHeader[len("Bearer "):]
-			claims, err := ValidateJWT(tokenString)
-			if err == nil {
-				userID = claims.UserID
-				hasValidToken = true
-			}
-		}
-
-		if !hasValidToken {
-			cookie, err := r.Cookie("token")
-			if err == nil {
-				claims, err := ValidateJWT(cookie.Value)
-				if err == nil {
-					userID = claims.UserID
-					hasValidToken = true
-				}
-			}
-		}
-
-		if isProtected(path, config.ProtectedPrefixes) && !hasValidToken {
-			if isApiReq {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-			// originalURL := r.URL.String()
-			// http.SetCookie(w, &http.Cookie{
-			// 	Name:     "redirect_url",
-			// 	Value:    originalURL,
-			// 	Path:     "/",
-			// 	HttpOnly: true,
-			// })
-
-			http.Redirect(w, r, "/login?error=unauthorized", http.StatusFound)
-			return
-		}
-
-		if hasValidToken {
-			ctx := context.WithValue(r.Context(), userIDKey, userID)
-			r = r.WithContext(ctx)
-		}
-
-		next.ServeHTTP(w, r)
-	})
-}
-
-func isProtected(path string, protectedPrefixes []string) bool {
-	for _, prefix := range protectedPrefixes {
-		if strings.HasPrefix(path, prefix) {
-			return true
-		}
-	}
-	return false
-}
+package auth
+
+import (
+	"context"
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/config"
+	"log"
+	"net/http"
+	"strings"
+)
+
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var userID uint
+		var sessionID uint
+		var apiTokenID uint
+		var hasValidToken bool
+		var viaAPIToken bool
+
+		path := r.URL.Path
+		isApiReq := strings.HasPrefix(path, "/api")
+
+		authHeader := r.Header.Get("Authorization")
+		if strings.HasPrefix(authHeader, "Bearer ") {
+			tokenString := authHeader[len("Bearer "):]
+			if strings.HasPrefix(tokenString, APITokenPrefix) {
+				apiToken, err := ValidateAPIToken(tokenString)
+				if err != nil {
+					apierror.Write(w, r, "Invalid API token", http.StatusUnauthorized)
+					return
+				}
+				if !tokenAllows(apiToken, r) {
+					apierror.Write(w, r, "API token scope does not allow this request", http.StatusForbidden)
+					return
+				}
+				userID = apiToken.UserID
+				apiTokenID = apiToken.ID
+				hasValidToken = true
+				viaAPIToken = true
+			} else if claims, session, err := authenticateJWT(tokenString, r); err == nil {
+				userID = claims.UserID
+				sessionID = session.ID
+				hasValidToken = true
+			}
+		}
+
+		if !hasValidToken {
+			cookie, err := r.Cookie("token")
+			if err == nil {
+				claims, session, err := authenticateJWT(cookie.Value, r)
+				if err == nil {
+					userID = claims.UserID
+					sessionID = session.ID
+					hasValidToken = true
+				}
+			}
+		}
+
+		var anonSessionID string
+		if !hasValidToken && config.AnonymousPractice {
+			anonSessionID = anonymousSession(w, r)
+		}
+
+		if policy, ok := routePolicy(r); ok {
+			allowAnonymous := policy.Auth == config.AuthAnonymous && anonSessionID != ""
+			if policy.Auth != config.AuthPublic && !hasValidToken && !allowAnonymous {
+				if isApiReq {
+					apierror.Write(w, r, "Unauthorized", http.StatusUnauthorized)
+					return
+				}
+				// originalURL := r.URL.String()
+				// http.SetCookie(w, &http.Cookie{
+				// 	Name:     "redirect_url",
+				// 	Value:    originalURL,
+				// 	Path:     "/",
+				// 	HttpOnly: true,
+				// })
+
+				http.Redirect(w, r, "/login?error=unauthorized", http.StatusFound)
+				return
+			}
+
+			if policy.Auth == config.AuthSession && viaAPIToken {
+				forbidden(w, r, isApiReq, "Personal access tokens cannot be used for this request")
+				return
+			}
+
+			if policy.Role != "" && hasValidToken {
+				allowed, err := hasRole(userID, policy.Role)
+				if err != nil {
+					log.Printf("Failed to check the role of user %d: %v", userID, err)
+					if isApiReq {
+						apierror.Write(w, r, "Failed to retrieve user", http.StatusInternalServerError)
+					} else {
+						http.Error(w, "Server error", http.StatusInternalServerError)
+					}
+					return
+				}
+				if !allowed {
+					forbidden(w, r, isApiReq, "Your role does not allow this request")
+					return
+				}
+			}
+		}
+
+		if hasValidToken {
+			ctx := context.WithValue(r.Context(), userIDKey, userID)
+			if sessionID != 0 {
+				ctx = context.WithValue(ctx, sessionIDKey, sessionID)
+			}
+			if apiTokenID != 0 {
+				ctx = context.WithValue(ctx, apiTokenIDKey, apiTokenID)
+			}
+			r = r.WithContext(ctx)
+		} else if anonSessionID != "" {
+			ctx := context.WithValue(r.Context(), anonSessionKey, anonSessionID)
+			r = r.WithContext(ctx)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// forbidden refuses a request, in the API error format for API requests
+func forbidden(w http.ResponseWriter, r *http.Request, isApiReq bool, message string) {
+	if isApiReq {
+		apierror.Write(w, r, message, http.StatusForbidden)
+		return
+	}
+	http.Error(w, message, http.StatusForbidden)
+}
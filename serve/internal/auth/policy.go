@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"errors"
+	"goera/serve/internal/config"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// routePolicy returns the first route policy matching the request, if any
+func routePolicy(r *http.Request) (config.RoutePolicy, bool) {
+	for _, policy := range config.RoutePolicies {
+		if policyMatches(policy, r.Method, r.URL.Path) {
+			return policy, true
+		}
+	}
+	return config.RoutePolicy{}, false
+}
+
+// policyMatches reports whether policy applies to a request for path with method
+func policyMatches(policy config.RoutePolicy, method, path string) bool {
+	if len(policy.Methods) > 0 && !slices.ContainsFunc(policy.Methods, func(m string) bool {
+		return strings.EqualFold(m, method)
+	}) {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(policy.Pattern, "*"); ok {
+		return strings.HasPrefix(path, prefix)
+	}
+	return path == policy.Pattern
+}
+
+// hasRole reports whether the user has the role a route policy requires
+func hasRole(userID uint, role string) (bool, error) {
+	db := database.GetDB()
+	if db == nil {
+		return false, errors.New("database connection failed")
+	}
+
+	var user models.User
+	if err := db.Select("role").First(&user, userID).Error; err != nil {
+		return false, err
+	}
+	return strings.EqualFold(string(user.Role), role), nil
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+	"goera/serve/internal/utils"
+)
+
+// SessionLifetime is how long a login stays valid
+const SessionLifetime = 168 * time.Hour
+
+// sessionTouchInterval limits how often last_seen_at is written for a busy session
+const sessionTouchInterval = time.Minute
+
+// maxUserAgentLength bounds the user agent stored with a session
+const maxUserAgentLength = 512
+
+// StartSession records a new login of userID from the device r came from and
+// returns its login token and when the token expires
+func StartSession(r *http.Request, userID uint) (string, time.Time, error) {
+	db := database.GetDB()
+	if db == nil {
+		return "", time.Time{}, errors.New("database connection failed")
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	session := models.Session{
+		UserID:     userID,
+		TokenID:    hex.EncodeToString(b),
+		UserAgent:  userAgent,
+		IP:         utils.ClientIP(r),
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(SessionLifetime),
+	}
+	if err := db.Create(&session).Error; err != nil {
+		return "", time.Time{}, err
+	}
+
+	token, err := GenerateJWT(userID, session.TokenID, session.ExpiresAt)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, session.ExpiresAt, nil
+}
+
+// validateSession looks up the active session of a login token and records
+// that it was seen from r. Tokens issued before sessions were tracked carry
+// no session and are refused, so revoking every session logs a user out for good.
+func validateSession(claims *Claims, r *http.Request) (*models.Session, error) {
+	if claims.ID == "" {
+		return nil, errors.New("token has no session")
+	}
+
+	db := database.GetDB()
+	if db == nil {
+		return nil, errors.New("database connection failed")
+	}
+
+	var session models.Session
+	if err := db.Where("token_id = ? AND user_id = ?", claims.ID, claims.UserID).First(&session).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if !session.Active(now) {
+		return nil, errors.New("session revoked or expired")
+	}
+
+	ip := utils.ClientIP(r)
+	if now.Sub(session.LastSeenAt) > sessionTouchInterval || ip != session.IP {
+		db.Model(&session).UpdateColumns(map[string]interface{}{"last_seen_at": now, "ip": ip})
+	}
+
+	return &session, nil
+}
+
+// authenticateJWT validates a login token and its session
+func authenticateJWT(tokenString string, r *http.Request) (*Claims, *models.Session, error) {
+	claims, err := ValidateJWT(tokenString)
+	if err != nil {
+		return nil, nil, err
+	}
+	session, err := validateSession(claims, r)
+	if err != nil {
+		return nil, nil, err
+	}
+	return claims, session, nil
+}
+
+// SessionIDFromContext returns the ID of the session a request was made with.
+// Requests authenticated with a personal access token have none.
+func SessionIDFromContext(ctx context.Context) (uint, bool) {
+	id, ok := ctx.Value(sessionIDKey).(uint)
+	return id, ok
+}
+
+// RevokeSessions revokes the active sessions of userID. With a session ID only
+// that session is revoked.
+func RevokeSessions(userID uint, sessionID uint) error {
+	db := database.GetDB()
+	if db == nil {
+		return errors.New("database connection failed")
+	}
+
+	query := db.Model(&models.Session{}).Where("user_id = ? AND revoked_at IS NULL", userID)
+	if sessionID != 0 {
+		query = query.Where("id = ?", sessionID)
+	}
+	return query.Update("revoked_at", time.Now()).Error
+}
@@ -0,0 +1,119 @@
+// Package banners shows announcements marked as banners at the top of every
+// page while they are up. Pages get them from templates.Render, so no
+// template has to include them.
+package banners
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+	"log"
+	"sync"
+	"time"
+
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+
+	"github.com/yuin/goldmark"
+)
+
+// refreshInterval is how long an instance relies on the banners it last read,
+// so changes made on another instance show up within it
+const refreshInterval = 30 * time.Second
+
+// Banner is an announcement ready to be shown on a page
+type Banner struct {
+	ID    uint
+	Title string
+	Level models.AnnouncementLevel
+	Body  template.HTML // Rendered from markdown, with raw HTML left out
+}
+
+var (
+	mu       sync.Mutex
+	upcoming []models.Announcement // Banners that have not expired, including scheduled ones
+	loadedAt time.Time
+)
+
+// Active returns the banners shown at now, pinned ones first and then newest
+// first. Banners that have not started yet are kept, so one scheduled for a
+// contest appears on time without waiting for a refresh.
+func Active(now time.Time) []Banner {
+	mu.Lock()
+	if time.Since(loadedAt) >= refreshInterval {
+		load(now)
+	}
+	announcements := upcoming
+	mu.Unlock()
+
+	var active []Banner
+	for i := range announcements {
+		if announcements[i].Active(now) {
+			active = append(active, Banner{
+				ID:    announcements[i].ID,
+				Title: announcements[i].Title,
+				Level: announcements[i].Level,
+				Body:  Markdown(announcements[i].Body),
+			})
+		}
+	}
+	return active
+}
+
+// load reads the banners that have not expired. The caller holds mu.
+func load(now time.Time) {
+	loadedAt = time.Now()
+	db := database.GetDB()
+	if db == nil {
+		return
+	}
+	var announcements []models.Announcement
+	err := db.Where("banner = ? AND (expires_at IS NULL OR expires_at > ?)", true, now).
+		Order("pinned DESC").Order("created_at DESC").
+		Find(&announcements).Error
+	if err != nil {
+		log.Printf("Failed to read banners: %v", err)
+		return
+	}
+	upcoming = announcements
+}
+
+// Invalidate makes the next page read the banners again, after one was
+// posted, changed or deleted on this instance
+func Invalidate() {
+	mu.Lock()
+	loadedAt = time.Time{}
+	mu.Unlock()
+}
+
+// Markdown renders an announcement body. Raw HTML and dangerous links are
+// left out, so the result is safe to insert into a page.
+func Markdown(source string) template.HTML {
+	var out bytes.Buffer
+	if err := goldmark.Convert([]byte(source), &out); err != nil {
+		return template.HTML(html.EscapeString(source))
+	}
+	return template.HTML(out.String())
+}
+
+// HTML renders the banners shown at now, or nothing when there are none
+func HTML(now time.Time) []byte {
+	active := Active(now)
+	if len(active) == 0 {
+		return nil
+	}
+	var out bytes.Buffer
+	out.WriteString(`<link rel="stylesheet" href="/static/stylesheets/banners.css" />`)
+	out.WriteString(`<div class="site_banners">`)
+	for _, banner := range active {
+		fmt.Fprintf(&out, `<div class="site_banner site_banner_%s" role="status" data-banner="%d">`,
+			html.EscapeString(string(banner.Level)), banner.ID)
+		fmt.Fprintf(&out, `<strong class="site_banner_title">%s</strong>`, html.EscapeString(banner.Title))
+		fmt.Fprintf(&out, `<div class="site_banner_body">%s</div>`, banner.Body)
+		out.WriteString(`<button type="button" class="site_banner_close" aria-label="Close" onclick="this.parentElement.remove()">&times;</button>`)
+		out.WriteString(`</div>`)
+	}
+	out.WriteString(`</div>`)
+	return out.Bytes()
+}
@@ -0,0 +1,120 @@
+// Package cache keeps hot, read-mostly data such as questions for a short
+// time, so repeated reads skip the database
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"goera/serve/internal/config"
+)
+
+// Store keeps cached values by key. A zero ttl keeps a value until it is
+// deleted or evicted.
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// store is the Store of config.CacheBackend, set up by Init
+var store Store = noStore{}
+
+// Init sets up the store for config.CacheBackend
+func Init() {
+	switch config.CacheBackend {
+	case "memory":
+		store = NewMemoryStore(config.CacheSize)
+	case "redis":
+		store = NewRedisStore(config.RedisAddr, config.RedisPassword, config.RedisDB)
+	default:
+		store = noStore{}
+	}
+}
+
+// GetJSON decodes the cached value of key into v and reports whether there
+// was one. Store failures are logged and count as a miss, so the caller
+// reads from the database instead.
+func GetJSON(ctx context.Context, key string, v interface{}) bool {
+	data, ok, err := store.Get(ctx, key)
+	if err != nil {
+		log.Printf("Cache error getting %s: %v", key, err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		log.Printf("Cache error decoding %s: %v", key, err)
+		return false
+	}
+	return true
+}
+
+// SetJSON caches v under key for config.CacheTTL
+func SetJSON(ctx context.Context, key string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Cache error encoding %s: %v", key, err)
+		return
+	}
+	if err := store.Set(ctx, key, data, config.CacheTTL); err != nil {
+		log.Printf("Cache error setting %s: %v", key, err)
+	}
+}
+
+// Delete removes keys from the cache
+func Delete(ctx context.Context, keys ...string) {
+	if err := store.Delete(ctx, keys...); err != nil {
+		log.Printf("Cache error deleting %v: %v", keys, err)
+	}
+}
+
+// Version returns the current version of name, for keys of values that
+// cannot be deleted one by one, such as every page of a listing. Putting the
+// version in those keys and calling Bump makes them all miss at once.
+func Version(ctx context.Context, name string) string {
+	key := "version:" + name
+	data, ok, err := store.Get(ctx, key)
+	if err != nil {
+		log.Printf("Cache error getting %s: %v", key, err)
+	}
+	if ok {
+		return string(data)
+	}
+	// A lost version is replaced by a new one, so entries cached under the
+	// old one are never read again
+	version := strconv.FormatInt(time.Now().UnixNano(), 36)
+	if err := store.Set(ctx, key, []byte(version), 0); err != nil {
+		log.Printf("Cache error setting %s: %v", key, err)
+	}
+	return version
+}
+
+// Bump moves name to a new version, leaving the entries cached under the old
+// one to expire
+func Bump(ctx context.Context, name string) {
+	key := "version:" + name
+	version := strconv.FormatInt(time.Now().UnixNano(), 36)
+	if err := store.Set(ctx, key, []byte(version), 0); err != nil {
+		log.Printf("Cache error setting %s: %v", key, err)
+	}
+}
+
+// noStore caches nothing, for CacheBackend none
+type noStore struct{}
+
+func (noStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	return nil, false, nil
+}
+
+func (noStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return nil
+}
+
+func (noStore) Delete(ctx context.Context, keys ...string) error {
+	return nil
+}
@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"goera/serve/internal/redis"
+)
+
+// redisKeyPrefix namespaces the cache in a shared Redis
+const redisKeyPrefix = "goera:cache:"
+
+// RedisStore keeps the cache in Redis, so it is shared by every serve
+// instance and invalidations reach all of them
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore returns a RedisStore for the Redis server at addr. Connections
+// are opened on first use.
+func NewRedisStore(addr, password string, db int) *RedisStore {
+	return &RedisStore{client: redis.NewClient(addr, password, db)}
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := s.client.Do(ctx, "GET", redisKeyPrefix+key)
+	if err != nil || reply == nil {
+		return nil, false, err
+	}
+	value, _ := reply.(string)
+	return []byte(value), true, nil
+}
+
+func (s *RedisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", redisKeyPrefix + key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	_, err := s.client.Do(ctx, args...)
+	return err
+}
+
+func (s *RedisStore) Delete(ctx context.Context, keys ...string) error {
+	args := []string{"DEL"}
+	for _, key := range keys {
+		args = append(args, redisKeyPrefix+key)
+	}
+	_, err := s.client.Do(ctx, args...)
+	return err
+}
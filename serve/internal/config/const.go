@@ -1,57 +1,589 @@
-package config
-
-import (
-	"os"
-)
-
-func Init() {
-	DBHost = getEnv("DB_HOST", DBHost)
-	DBUser = getEnv("DB_USER", DBUser)
-	DBPassword = getEnv("DB_PASSWORD", DBPassword)
-	DBName = getEnv("DB_NAME", DBName)
-	DBPort = getEnv("DB_PORT", DBPort)
-	DBSSLMode = getEnv("DB_SSL_MODE", DBSSLMode)
-
-	// Set default server port if not already set
-	if ServerPort == "" {
-		ServerPort = ":5000"
-	}
-}
-
-const (
-	StaticRouterDir = "web/static"
-	StaticRouter    = "/static/"
-)
-
-var (
-	ServerPort = ":5000"
-	DBHost     = "localhost"
-	DBUser     = "goera_user"
-	DBPassword = ""
-	DBName     = "goera"
-	DBPort     = "5432"
-	DBSSLMode  = "disable"
-)
-
-// SetServerPort updates the server port
-func SetServerPort(port string) {
-	ServerPort = port
-}
-
-var ProtectedPrefixes = []string{
-	"/questions",
-	"/profile",
-	"/question",
-	"/api/user",
-	"/submissions",
-	"/createQuestion",
-}
-
-// getEnv returns the value of an environment variable or a default value if not set
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
-	}
-	return value
-}
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"goera/proto/signing"
+)
+
+// Init loads the settings from the config file, if there is one, and then from
+// environment variables, which take precedence over the file
+func Init() error {
+	if path := configFilePath(); path != "" {
+		if err := loadFile(path); err != nil {
+			return err
+		}
+	}
+
+	DBDriver = getEnv("DB_DRIVER", DBDriver)
+	DBPath = getEnv("DB_PATH", DBPath)
+	DBHost = getEnv("DB_HOST", DBHost)
+	DBUser = getEnv("DB_USER", DBUser)
+	DBPassword = getEnv("DB_PASSWORD", DBPassword)
+	DBName = getEnv("DB_NAME", DBName)
+	DBPort = getEnv("DB_PORT", DBPort)
+	DBSSLMode = getEnv("DB_SSL_MODE", DBSSLMode)
+	DBMaxOpenConns = getEnvInt("DB_MAX_OPEN_CONNS", DBMaxOpenConns)
+	DBMaxIdleConns = getEnvInt("DB_MAX_IDLE_CONNS", DBMaxIdleConns)
+	DBConnMaxLifetime = time.Duration(getEnvInt("DB_CONN_MAX_LIFETIME_SECONDS", int(DBConnMaxLifetime/time.Second))) * time.Second
+	DBStatementTimeout = time.Duration(getEnvInt("DB_STATEMENT_TIMEOUT_SECONDS", int(DBStatementTimeout/time.Second))) * time.Second
+	DBConnectTimeout = time.Duration(getEnvInt("DB_CONNECT_TIMEOUT_SECONDS", int(DBConnectTimeout/time.Second))) * time.Second
+
+	MaxTimeLimitMs = getEnvInt("MAX_TIME_LIMIT_MS", MaxTimeLimitMs)
+	MaxMemoryLimitMB = getEnvInt("MAX_MEMORY_LIMIT_MB", MaxMemoryLimitMB)
+	MaxTestCasesPerQuestion = getEnvInt("MAX_TEST_CASES_PER_QUESTION", MaxTestCasesPerQuestion)
+	MaxTestCaseSizeBytes = getEnvInt("MAX_TEST_CASE_SIZE_BYTES", MaxTestCaseSizeBytes)
+	MaxTotalTestCaseBytes = getEnvInt("MAX_TOTAL_TEST_CASE_BYTES", MaxTotalTestCaseBytes)
+	MaxSourceCodeBytes = getEnvInt("MAX_SOURCE_CODE_BYTES", MaxSourceCodeBytes)
+	MaxSourceFiles = getEnvInt("MAX_SOURCE_FILES", MaxSourceFiles)
+
+	for resource, size := range PageSizes {
+		envName := strings.ToUpper(resource)
+		size.Default = getEnvInt("PAGE_SIZE_"+envName, size.Default)
+		size.Max = getEnvInt("MAX_PAGE_SIZE_"+envName, size.Max)
+		PageSizes[resource] = size
+	}
+
+	for group, limit := range RateLimits {
+		envName := strings.ToUpper(group)
+		limit.Limit = getEnvInt("RATE_LIMIT_"+envName, limit.Limit)
+		limit.Window = time.Duration(getEnvInt("RATE_LIMIT_WINDOW_"+envName+"_SECONDS", int(limit.Window/time.Second))) * time.Second
+		RateLimits[group] = limit
+	}
+	for kind, quota := range DailyQuotas {
+		DailyQuotas[kind] = getEnvInt("DAILY_QUOTA_"+strings.ToUpper(kind), quota)
+	}
+	RateLimitBackend = getEnv("RATE_LIMIT_BACKEND", RateLimitBackend)
+	RedisAddr = getEnv("REDIS_ADDR", RedisAddr)
+	RedisPassword = getEnv("REDIS_PASSWORD", RedisPassword)
+	RedisDB = getEnvInt("REDIS_DB", RedisDB)
+	CacheBackend = getEnv("CACHE_BACKEND", CacheBackend)
+	CacheTTL = time.Duration(getEnvInt("CACHE_TTL_SECONDS", int(CacheTTL/time.Second))) * time.Second
+	CacheSize = getEnvInt("CACHE_SIZE", CacheSize)
+
+	if legacyURL := os.Getenv("JUDGE_API_URL"); legacyURL != "" {
+		// Deployments from before the judge spoke gRPC name it by URL
+		if u, err := url.Parse(legacyURL); err == nil && u.Host != "" {
+			JudgeAddr = u.Host
+		}
+	}
+	JudgeAddr = getEnv("JUDGE_ADDR", JudgeAddr)
+	GRPCListen = getEnv("GRPC_LISTEN", GRPCListen)
+	if GRPCListen != "" && !strings.Contains(GRPCListen, ":") {
+		GRPCListen = ":" + GRPCListen
+	}
+	SubmissionStuckTimeout = time.Duration(getEnvInt("SUBMISSION_STUCK_TIMEOUT_SECONDS", int(SubmissionStuckTimeout/time.Second))) * time.Second
+	SubmissionReaperInterval = time.Duration(getEnvInt("SUBMISSION_REAPER_INTERVAL_SECONDS", int(SubmissionReaperInterval/time.Second))) * time.Second
+	MaxJudgeAttempts = getEnvInt("MAX_JUDGE_ATTEMPTS", MaxJudgeAttempts)
+	JudgeDispatchInterval = time.Duration(getEnvInt("JUDGE_DISPATCH_INTERVAL_SECONDS", int(JudgeDispatchInterval/time.Second))) * time.Second
+	JudgeOutboxMaxAge = time.Duration(getEnvInt("JUDGE_OUTBOX_MAX_AGE_SECONDS", int(JudgeOutboxMaxAge/time.Second))) * time.Second
+	JudgeBreakerThreshold = getEnvInt("JUDGE_BREAKER_THRESHOLD", JudgeBreakerThreshold)
+	JudgeBreakerCooldown = time.Duration(getEnvInt("JUDGE_BREAKER_COOLDOWN_SECONDS", int(JudgeBreakerCooldown/time.Second))) * time.Second
+	PlagiarismThreshold = getEnvFloat("PLAGIARISM_THRESHOLD", PlagiarismThreshold)
+	AnonymousPractice = getEnvBool("ANONYMOUS_PRACTICE", AnonymousPractice)
+	PublishRequiresReferenceSolution = getEnvBool("PUBLISH_REQUIRE_REFERENCE_SOLUTION", PublishRequiresReferenceSolution)
+	EditorialVisibility = getEnv("EDITORIAL_VISIBILITY", EditorialVisibility)
+	SupportedLanguages = getEnvList("SUPPORTED_LANGUAGES", SupportedLanguages)
+	ContestPenaltyMinutes = getEnvInt("CONTEST_PENALTY_MINUTES", ContestPenaltyMinutes)
+	ContestFreezeMinutes = getEnvInt("CONTEST_FREEZE_MINUTES", ContestFreezeMinutes)
+	ScoreboardPushInterval = time.Duration(getEnvInt("CONTEST_SCOREBOARD_PUSH_INTERVAL_SECONDS", int(ScoreboardPushInterval/time.Second))) * time.Second
+	SentryDSN = getEnv("SENTRY_DSN", SentryDSN)
+	SentryEnvironment = getEnv("SENTRY_ENVIRONMENT", SentryEnvironment)
+	TracingEndpoint = getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", TracingEndpoint)
+	TracingSampleRatio = getEnvFloat("TRACING_SAMPLE_RATIO", TracingSampleRatio)
+	StorageBackend = getEnv("STORAGE_BACKEND", StorageBackend)
+	StorageInlineLimit = getEnvInt("STORAGE_INLINE_LIMIT_BYTES", StorageInlineLimit)
+	StorageDir = getEnv("STORAGE_DIR", StorageDir)
+	StorageURLBase = getEnv("STORAGE_URL_BASE", StorageURLBase)
+	StorageURLTTL = time.Duration(getEnvInt("STORAGE_URL_TTL_SECONDS", int(StorageURLTTL/time.Second))) * time.Second
+	S3Endpoint = getEnv("S3_ENDPOINT", S3Endpoint)
+	S3Bucket = getEnv("S3_BUCKET", S3Bucket)
+	S3Region = getEnv("S3_REGION", S3Region)
+	S3AccessKey = getEnv("S3_ACCESS_KEY", S3AccessKey)
+	S3SecretKey = getEnv("S3_SECRET_KEY", S3SecretKey)
+	LoginFreeAttempts = getEnvInt("LOGIN_FREE_ATTEMPTS", LoginFreeAttempts)
+	LoginBaseDelay = time.Duration(getEnvInt("LOGIN_BASE_DELAY_SECONDS", int(LoginBaseDelay/time.Second))) * time.Second
+	LoginMaxDelay = time.Duration(getEnvInt("LOGIN_MAX_DELAY_SECONDS", int(LoginMaxDelay/time.Second))) * time.Second
+	LoginLockoutThreshold = getEnvInt("LOGIN_LOCKOUT_THRESHOLD", LoginLockoutThreshold)
+	LoginLockoutDuration = time.Duration(getEnvInt("LOGIN_LOCKOUT_SECONDS", int(LoginLockoutDuration/time.Second))) * time.Second
+	SMTPAddr = getEnv("SMTP_ADDR", SMTPAddr)
+	SMTPUsername = getEnv("SMTP_USERNAME", SMTPUsername)
+	SMTPPassword = getEnv("SMTP_PASSWORD", SMTPPassword)
+	SMTPFrom = getEnv("SMTP_FROM", SMTPFrom)
+	PublicURL = getEnv("PUBLIC_URL", PublicURL)
+	TrustedProxies = getEnv("TRUSTED_PROXIES", TrustedProxies)
+	WebhookTimeout = time.Duration(getEnvInt("WEBHOOK_TIMEOUT_SECONDS", int(WebhookTimeout/time.Second))) * time.Second
+	WebhookMaxAttempts = getEnvInt("WEBHOOK_MAX_ATTEMPTS", WebhookMaxAttempts)
+	WebhookAllowPrivate = getEnvBool("WEBHOOK_ALLOW_PRIVATE", WebhookAllowPrivate)
+	PDFCommand = getEnv("PDF_COMMAND", PDFCommand)
+	AchievementSweepInterval = time.Duration(getEnvInt("ACHIEVEMENT_SWEEP_INTERVAL_SECONDS", int(AchievementSweepInterval/time.Second))) * time.Second
+	AccountDeletionGracePeriod = time.Duration(getEnvInt("ACCOUNT_DELETION_GRACE_DAYS", int(AccountDeletionGracePeriod/(24*time.Hour)))) * 24 * time.Hour
+	PDFTimeout = time.Duration(getEnvInt("PDF_TIMEOUT_SECONDS", int(PDFTimeout/time.Second))) * time.Second
+	MaintenanceMode = getEnvBool("MAINTENANCE_MODE", MaintenanceMode)
+	MaintenanceMessage = getEnv("MAINTENANCE_MESSAGE", MaintenanceMessage)
+
+	OAuthRedirectBaseURL = getEnv("OAUTH_REDIRECT_BASE_URL", OAuthRedirectBaseURL)
+	GitHubClientID = getEnv("GITHUB_CLIENT_ID", GitHubClientID)
+	GitHubClientSecret = getEnv("GITHUB_CLIENT_SECRET", GitHubClientSecret)
+	GoogleClientID = getEnv("GOOGLE_CLIENT_ID", GoogleClientID)
+	GoogleClientSecret = getEnv("GOOGLE_CLIENT_SECRET", GoogleClientSecret)
+
+	if value := os.Getenv("INTERNAL_HMAC_KEYS"); value != "" {
+		InternalKeys, InternalKeyID = signing.ParseKeys(value)
+	}
+	if len(InternalKeys) == 0 {
+		// Fall back to the legacy shared API key as a single signing key
+		if legacyKey := os.Getenv("INTERNAL_API_KEY"); legacyKey != "" {
+			InternalKeys = map[string]string{"default": legacyKey}
+			InternalKeyID = "default"
+		}
+	}
+	InternalKeyID = getEnv("INTERNAL_HMAC_KEY_ID", InternalKeyID)
+	InternalSignatureMaxSkew = time.Duration(getEnvInt("INTERNAL_SIGNATURE_MAX_SKEW_SECONDS", int(InternalSignatureMaxSkew/time.Second))) * time.Second
+
+	JWTSecret = getEnv("JWT_SECRET", JWTSecret)
+	JWTKeysDir = getEnv("JWT_KEYS_DIR", JWTKeysDir)
+	JWTSigningKeyID = getEnv("JWT_SIGNING_KEY_ID", JWTSigningKeyID)
+	JWTKeysReloadInterval = time.Duration(getEnvInt("JWT_KEYS_RELOAD_INTERVAL_SECONDS", int(JWTKeysReloadInterval/time.Second))) * time.Second
+
+	// Set default server port if not already set
+	if ServerPort == "" {
+		ServerPort = ":5000"
+	}
+
+	if err := validate(); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return nil
+}
+
+const (
+	StaticRouterDir = "web/static"
+	StaticRouter    = "/static/"
+)
+
+var (
+	ServerPort = ":5000"
+	DBDriver   = "postgres" // "postgres" or "sqlite"
+	DBPath     = "goera.db" // Database file used when DBDriver is "sqlite"
+	DBHost     = "localhost"
+	DBUser     = "goera_user"
+	DBPassword = ""
+	DBName     = "goera"
+	DBPort     = "5432"
+	DBSSLMode  = "disable"
+)
+
+// Database connection pool settings. A zero max open connections, lifetime or
+// statement timeout means no limit; the statement timeout only applies to
+// PostgreSQL. At boot the server keeps retrying the database for up to
+// DBConnectTimeout, so it survives the database restarting alongside it.
+var (
+	DBMaxOpenConns     = 25
+	DBMaxIdleConns     = 5
+	DBConnMaxLifetime  = 30 * time.Minute
+	DBStatementTimeout = 30 * time.Second
+	DBConnectTimeout   = time.Minute
+)
+
+// Deployment-wide resource ceilings. Questions asking for more than these
+// are rejected so a single problem cannot starve the runner fleet.
+var (
+	MaxTimeLimitMs          = 10000
+	MaxMemoryLimitMB        = 1024
+	MaxTestCasesPerQuestion = 100
+	MaxTestCaseSizeBytes    = 5 * 1024 * 1024
+	MaxTotalTestCaseBytes   = 50 * 1024 * 1024
+	MaxSourceCodeBytes      = 64 * 1024
+	MaxSourceFiles          = 50 // Files in a multi-file submission
+)
+
+// PlagiarismThreshold is the similarity score, from 0 to 1, above which a pair
+// of accepted submissions is flagged in the plagiarism report
+var PlagiarismThreshold = 0.8
+
+// PageSize holds the default and maximum page size of a paginated resource
+type PageSize struct {
+	Default int
+	Max     int
+}
+
+// PageSizes are the page size limits of the paginated API resources. Each can be
+// overridden with PAGE_SIZE_<RESOURCE> and MAX_PAGE_SIZE_<RESOURCE>.
+var PageSizes = map[string]PageSize{
+	"questions":          {Default: 3, Max: 100},
+	"submissions":        {Default: 5, Max: 100},
+	"submission_events":  {Default: 50, Max: 200},
+	"plagiarism":         {Default: 20, Max: 100},
+	"contests":           {Default: 20, Max: 100},
+	"notifications":      {Default: 20, Max: 100},
+	"user_submissions":   {Default: 10, Max: 100},
+	"audit":              {Default: 50, Max: 200},
+	"setter_leaderboard": {Default: 20, Max: 100},
+	"collections":        {Default: 20, Max: 100},
+}
+
+// RateLimit allows Limit requests per Window to each user, or to each IP
+// address for requests without a user. A zero Limit turns it off.
+type RateLimit struct {
+	Limit  int
+	Window time.Duration
+}
+
+// RateLimits are the limits of the rate limited API route groups. Each can be
+// overridden with RATE_LIMIT_<GROUP> and RATE_LIMIT_WINDOW_<GROUP>_SECONDS.
+var RateLimits = map[string]RateLimit{
+	"login":    {Limit: 10, Window: time.Minute},
+	"register": {Limit: 5, Window: time.Hour},
+	"api_read": {Limit: 600, Window: time.Minute},
+}
+
+// DailyQuotas limit how many calls of each expensive kind a user may make per
+// UTC day. Each can be overridden with DAILY_QUOTA_<KIND>, and for a single
+// user by an admin. A zero quota means no limit.
+var DailyQuotas = map[string]int{
+	"submissions":  200,  // POST /api/submissions
+	"runs":         1000, // POST /api/run and /api/compile-check
+	"stress_tests": 50,   // POST /api/questions/{id}/stress
+}
+
+// Rate limit counters are kept in memory, per serve instance, unless
+// RateLimitBackend is redis. Redis shares them between instances; while it
+// cannot be reached, the in-memory counters are used instead.
+var (
+	RateLimitBackend = "memory"
+	RedisAddr        = "localhost:6379"
+	RedisPassword    = ""
+	RedisDB          = 0
+)
+
+// Question reads are cached for CacheTTL in memory, per serve instance, in
+// up to CacheSize entries. With CacheBackend redis the cache is kept in the
+// Redis of the rate limiter and shared between instances; none turns it off.
+var (
+	CacheBackend = "memory"
+	CacheTTL     = 30 * time.Second
+	CacheSize    = 1000
+)
+
+// Serve calls the judge's gRPC service at JudgeAddr, and serves the result
+// service the judge reports verdicts to on GRPCListen
+var (
+	JudgeAddr  = "judge:8080"
+	GRPCListen = ":5001"
+)
+
+// Submissions left pending or judging for longer than SubmissionStuckTimeout
+// are sent to the judge again, up to MaxJudgeAttempts times in total, and
+// then marked as a system error. The check runs every SubmissionReaperInterval.
+var (
+	SubmissionStuckTimeout   = 15 * time.Minute
+	SubmissionReaperInterval = time.Minute
+	MaxJudgeAttempts         = 3
+)
+
+// Submissions the judge could not take are retried from the outbox every
+// JudgeDispatchInterval, backing off per submission, until they are older than
+// JudgeOutboxMaxAge and marked as a system error.
+var (
+	JudgeDispatchInterval = 5 * time.Second
+	JudgeOutboxMaxAge     = 24 * time.Hour
+)
+
+// After JudgeBreakerThreshold deliveries in a row fail to reach the judge, new
+// submissions go straight to the outbox for JudgeBreakerCooldown instead of
+// waiting on the judge. Then one delivery is tried to see if it is back.
+var (
+	JudgeBreakerThreshold = 5
+	JudgeBreakerCooldown  = 30 * time.Second
+)
+
+// AnonymousPractice lets visitors without an account browse published questions
+// and run code against their samples using an ephemeral session. Full
+// submissions still require registration.
+var AnonymousPractice = false
+
+// PublishRequiresReferenceSolution refuses to publish questions that have no
+// reference solution. Questions that have one always need it to pass.
+var PublishRequiresReferenceSolution = false
+
+// EditorialVisibility is the default for questions that do not set their own:
+// "after_solve", "after_release", "after_contest" or "always"
+var EditorialVisibility = "after_solve"
+
+// SupportedLanguages are the languages the judge can run. Questions can restrict
+// submissions to a subset of them.
+var SupportedLanguages = []string{"go"}
+
+// ContestPenaltyMinutes is added to a solved problem's time for every rejected
+// attempt before it was accepted. ContestFreezeMinutes is how long before the
+// end the scoreboard of a new contest freezes when it does not set its own.
+var (
+	ContestPenaltyMinutes = 20
+	ContestFreezeMinutes  = 60
+)
+
+// ScoreboardPushInterval is how often the live scoreboard of a contest is
+// pushed to its spectators at most, however fast verdicts come in
+var ScoreboardPushInterval = 2 * time.Second
+
+// Panics in HTTP handlers are always logged. With SentryDSN set they are also
+// sent to that Sentry project, tagged with SentryEnvironment.
+var (
+	SentryDSN         = ""
+	SentryEnvironment = ""
+)
+
+// With TracingEndpoint set, spans of HTTP requests, database queries and calls
+// to the judge are exported to that OpenTelemetry collector over OTLP/HTTP.
+// TracingSampleRatio of the traces started here are kept; traces started by
+// another service follow its decision.
+var (
+	TracingEndpoint    = ""
+	TracingSampleRatio = 1.0
+)
+
+// Test case data and judge logs larger than StorageInlineLimit bytes are kept
+// in file storage instead of the database, which then only holds a reference.
+// StorageBackend is database to keep everything in the database, local for
+// files under StorageDir, or s3 for a bucket of an S3 compatible service such
+// as MinIO. Code-runners download test data over links that expire after
+// StorageURLTTL; for local storage these point at StorageURLBase, the URL the
+// code-runners reach serve at.
+var (
+	StorageBackend     = "database"
+	StorageInlineLimit = 64 * 1024
+	StorageDir         = "storage"
+	StorageURLBase     = "http://localhost:5000"
+	StorageURLTTL      = 24 * time.Hour
+	S3Endpoint         = ""
+	S3Bucket           = ""
+	S3Region           = "us-east-1"
+	S3AccessKey        = ""
+	S3SecretKey        = ""
+)
+
+// Verdicts are POSTed to webhooks with WebhookTimeout per attempt, and a
+// delivery is tried up to WebhookMaxAttempts times with growing pauses.
+// Unless WebhookAllowPrivate is on, webhooks cannot reach loopback, private
+// or link-local addresses, so users cannot make serve call into its own
+// network.
+var (
+	WebhookTimeout      = 10 * time.Second
+	WebhookMaxAttempts  = 3
+	WebhookAllowPrivate = false
+)
+
+// PDFCommand turns the printable statement of a question into a PDF. It gets
+// the page's HTML on its standard input and writes the PDF to its standard
+// output, and is split on spaces into the program and its arguments. The PDF
+// export is turned off when it is empty.
+var (
+	PDFCommand = "wkhtmltopdf --quiet --print-media-type --encoding utf-8 - -"
+	PDFTimeout = 30 * time.Second
+)
+
+// Badges are awarded as verdicts come in, and every AchievementSweepInterval
+// the accepted submissions since the last sweep are checked again, which
+// catches those judged on another instance and contest problems that became
+// public when their contest ended
+var AchievementSweepInterval = 10 * time.Minute
+
+// An account whose owner asks for it to be deleted is kept for
+// AccountDeletionGracePeriod, during which they can change their mind, and is
+// then anonymized by a background job
+var AccountDeletionGracePeriod = 14 * 24 * time.Hour
+
+// Failed logins are counted per account and per client IP. Once either has
+// LoginFreeAttempts failures, the next attempt has to wait LoginBaseDelay
+// after the last failure, doubling with every further failure up to
+// LoginMaxDelay. An account with LoginLockoutThreshold failures is locked for
+// LoginLockoutDuration and its owner is emailed a link that unlocks it.
+// Failures are forgotten after a successful login, or once none happened for
+// LoginLockoutDuration.
+var (
+	LoginFreeAttempts     = 3
+	LoginBaseDelay        = time.Second
+	LoginMaxDelay         = 15 * time.Minute
+	LoginLockoutThreshold = 10
+	LoginLockoutDuration  = time.Hour
+)
+
+// Emails, such as account unlock links, are sent from SMTPFrom through the
+// SMTP server at SMTPAddr, host:port. Without SMTPAddr they are written to
+// the log instead. Links in emails point at PublicURL.
+var (
+	SMTPAddr     = ""
+	SMTPUsername = ""
+	SMTPPassword = ""
+	SMTPFrom     = "goera@localhost"
+	PublicURL    = "http://localhost:5000"
+)
+
+// TrustedProxies lists the reverse proxies, as comma separated IP addresses or
+// CIDR ranges, whose X-Forwarded-For entries are believed. A client's address
+// is the right-most entry not added by one of them.
+var TrustedProxies = "127.0.0.0/8,::1/128"
+
+// MaintenanceMode answers user requests with a 503 page showing
+// MaintenanceMessage, while admins and the judge keep working. Admins can also
+// turn maintenance on through the API; MaintenanceMode keeps it on regardless.
+var (
+	MaintenanceMode    = false
+	MaintenanceMessage = "Goera is down for maintenance and will be back shortly."
+)
+
+// Authentication a route policy requires
+const (
+	AuthPublic    = "public"    // Anyone, signed in or not
+	AuthUser      = "user"      // A login session or a personal access token
+	AuthSession   = "session"   // A login session; personal access tokens are refused
+	AuthAnonymous = "anonymous" // A signed in user, or an anonymous practice session while AnonymousPractice is on
+)
+
+// RoutePolicy says who may request the paths matching Pattern. A pattern
+// matches its path exactly, or every path starting with it when it ends in *.
+// Methods limits the policy to those request methods, all when empty. Role is
+// the user role required on top of Auth, any role when empty.
+type RoutePolicy struct {
+	Pattern string   `yaml:"pattern"`
+	Methods []string `yaml:"methods,omitempty"`
+	Role    string   `yaml:"role,omitempty"`
+	Auth    string   `yaml:"auth"`
+}
+
+// RoutePolicies are checked by the auth middleware in order and the first
+// policy matching a request applies. Requests no policy matches are public;
+// the handlers behind them do their own checks.
+var RoutePolicies = []RoutePolicy{
+	{Pattern: "/questions*", Auth: AuthAnonymous},
+	{Pattern: "/question/*", Auth: AuthAnonymous},
+	{Pattern: "/api/run", Auth: AuthAnonymous},
+	{Pattern: "/api/compile-check", Auth: AuthAnonymous},
+	{Pattern: "/profile/*", Auth: AuthUser},
+	{Pattern: "/submissions*", Auth: AuthUser},
+	{Pattern: "/submission/*", Auth: AuthUser},
+	{Pattern: "/createQuestion", Auth: AuthUser},
+	{Pattern: "/notifications*", Auth: AuthUser},
+	{Pattern: "/admin*", Role: "admin", Auth: AuthSession},
+	{Pattern: "/api/user/self/*", Auth: AuthSession},
+	{Pattern: "/api/user/*", Auth: AuthUser},
+	{Pattern: "/api/tokens*", Auth: AuthSession},
+	{Pattern: "/api/sessions*", Auth: AuthSession},
+	{Pattern: "/api/logins", Auth: AuthSession},
+	{Pattern: "/api/webhooks*", Auth: AuthSession},
+	{Pattern: "/api/groups*", Auth: AuthUser},
+	{Pattern: "/api/collections*", Methods: []string{"POST", "PUT", "DELETE"}, Auth: AuthUser},
+	{Pattern: "/api/notifications*", Auth: AuthUser},
+	{Pattern: "/api/announcements*", Methods: []string{"POST", "PUT", "DELETE"}, Role: "admin", Auth: AuthUser},
+	{Pattern: "/api/admin/*", Role: "admin", Auth: AuthUser},
+	{Pattern: "/api/questions/bulk", Role: "admin", Auth: AuthUser},
+	{Pattern: "/metrics", Role: "admin", Auth: AuthUser},
+}
+
+// OAuth login providers. A provider is enabled when both its client ID and
+// secret are set. Callbacks are served at OAuthRedirectBaseURL/auth/{provider}/callback.
+var (
+	OAuthRedirectBaseURL = "http://localhost:5000"
+	GitHubClientID       = ""
+	GitHubClientSecret   = ""
+	GoogleClientID       = ""
+	GoogleClientSecret   = ""
+)
+
+// Keys for signing requests between serve, judge and code-runner. Every key in
+// InternalKeys is accepted when verifying, while new requests are signed with
+// InternalKeyID, so keys can be rotated by rolling out the new key first and
+// switching the signing key afterwards.
+var (
+	InternalKeys             = map[string]string{}
+	InternalKeyID            = ""
+	InternalSignatureMaxSkew = 5 * time.Minute
+)
+
+// Login tokens are signed with JWTSigningKeyID, one of the keys in JWTKeysDir.
+// A "<kid>.pem" file holds an RSA key for RS256; private keys can sign and
+// public keys only verify, e.g. tokens of another service or a retired key.
+// A "<kid>.secret" file holds an HS256 secret. The directory is read again
+// every JWTKeysReloadInterval, so keys rotate without a restart. Tokens without
+// a key ID are checked against JWTSecret, which also signs new tokens when no
+// key directory is set.
+var (
+	JWTSecret             = ""
+	JWTKeysDir            = ""
+	JWTSigningKeyID       = ""
+	JWTKeysReloadInterval = time.Minute
+)
+
+// SetServerPort updates the server port
+func SetServerPort(port string) {
+	ServerPort = port
+}
+
+// getEnv returns the value of an environment variable or a default value if not set
+func getEnv(key, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvList returns a comma separated environment variable as a list, or a
+// default value if not set
+func getEnvList(key string, defaultValue []string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return defaultValue
+	}
+	return values
+}
+
+// getEnvBool returns the boolean value of an environment variable or a default value if not set or invalid
+func getEnvBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return defaultValue
+	}
+	return parsed
+}
+
+// getEnvInt returns the integer value of an environment variable or a default value if not set or invalid
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return defaultValue
+	}
+	return parsed
+}
+
+// getEnvFloat returns the float value of an environment variable or a default value if not set or invalid
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return parsed
+}
@@ -0,0 +1,625 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"goera/proto/signing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultConfigFile is read when GOERA_CONFIG is not set and the file exists
+const DefaultConfigFile = "goera.yaml"
+
+// fileConfig is the layout of the shared config file. Serve only reads the
+// serve section; the other sections belong to the judge and the code-runner
+// and are checked by them.
+type fileConfig struct {
+	Serve      serveFileConfig `yaml:"serve"`
+	Judge      yaml.Node       `yaml:"judge"`
+	CodeRunner yaml.Node       `yaml:"code_runner"`
+}
+
+type serveFileConfig struct {
+	Port       string `yaml:"port"`
+	GRPCListen string `yaml:"grpc_listen"`
+	JudgeAddr  string `yaml:"judge_addr"`
+
+	Database struct {
+		Driver   string `yaml:"driver"`
+		Path     string `yaml:"path"`
+		Host     string `yaml:"host"`
+		User     string `yaml:"user"`
+		Password string `yaml:"password"`
+		Name     string `yaml:"name"`
+		Port     string `yaml:"port"`
+		SSLMode  string `yaml:"ssl_mode"`
+
+		MaxOpenConns     int           `yaml:"max_open_conns"`
+		MaxIdleConns     int           `yaml:"max_idle_conns"`
+		ConnMaxLifetime  time.Duration `yaml:"conn_max_lifetime"`
+		StatementTimeout time.Duration `yaml:"statement_timeout"`
+		ConnectTimeout   time.Duration `yaml:"connect_timeout"`
+	} `yaml:"database"`
+
+	Limits struct {
+		MaxTimeLimitMs          int `yaml:"max_time_limit_ms"`
+		MaxMemoryLimitMB        int `yaml:"max_memory_limit_mb"`
+		MaxTestCasesPerQuestion int `yaml:"max_test_cases_per_question"`
+		MaxTestCaseSizeBytes    int `yaml:"max_test_case_size_bytes"`
+		MaxTotalTestCaseBytes   int `yaml:"max_total_test_case_bytes"`
+		MaxSourceCodeBytes      int `yaml:"max_source_code_bytes"`
+		MaxSourceFiles          int `yaml:"max_source_files"`
+	} `yaml:"limits"`
+
+	PageSizes map[string]struct {
+		Default int `yaml:"default"`
+		Max     int `yaml:"max"`
+	} `yaml:"page_sizes"`
+
+	RateLimits struct {
+		Backend string `yaml:"backend"`
+		Redis   struct {
+			Addr     string `yaml:"addr"`
+			Password string `yaml:"password"`
+			DB       int    `yaml:"db"`
+		} `yaml:"redis"`
+		Groups map[string]struct {
+			Limit  int           `yaml:"limit"`
+			Window time.Duration `yaml:"window"`
+		} `yaml:"groups"`
+	} `yaml:"rate_limits"`
+
+	DailyQuotas map[string]int `yaml:"daily_quotas"`
+
+	Cache struct {
+		Backend string        `yaml:"backend"`
+		TTL     time.Duration `yaml:"ttl"`
+		Size    int           `yaml:"size"`
+	} `yaml:"cache"`
+
+	Judging struct {
+		StuckTimeout     time.Duration `yaml:"stuck_timeout"`
+		ReaperInterval   time.Duration `yaml:"reaper_interval"`
+		MaxAttempts      int           `yaml:"max_attempts"`
+		DispatchInterval time.Duration `yaml:"dispatch_interval"`
+		OutboxMaxAge     time.Duration `yaml:"outbox_max_age"`
+		BreakerThreshold int           `yaml:"breaker_threshold"`
+		BreakerCooldown  time.Duration `yaml:"breaker_cooldown"`
+	} `yaml:"judging"`
+
+	PlagiarismThreshold float64  `yaml:"plagiarism_threshold"`
+	AnonymousPractice   bool     `yaml:"anonymous_practice"`
+	PublishRequiresRef  bool     `yaml:"publish_require_reference_solution"`
+	EditorialVisibility string   `yaml:"editorial_visibility"`
+	SupportedLanguages  []string `yaml:"supported_languages"`
+
+	Contests struct {
+		PenaltyMinutes         int           `yaml:"penalty_minutes"`
+		FreezeMinutes          int           `yaml:"freeze_minutes"`
+		ScoreboardPushInterval time.Duration `yaml:"scoreboard_push_interval"`
+	} `yaml:"contests"`
+
+	Sentry struct {
+		DSN         string `yaml:"dsn"`
+		Environment string `yaml:"environment"`
+	} `yaml:"sentry"`
+
+	Tracing struct {
+		Endpoint    string  `yaml:"endpoint"`
+		SampleRatio float64 `yaml:"sample_ratio"`
+	} `yaml:"tracing"`
+
+	Storage struct {
+		Backend     string        `yaml:"backend"`
+		InlineLimit int           `yaml:"inline_limit_bytes"`
+		Dir         string        `yaml:"dir"`
+		URLBase     string        `yaml:"url_base"`
+		URLTTL      time.Duration `yaml:"url_ttl"`
+		S3          struct {
+			Endpoint  string `yaml:"endpoint"`
+			Bucket    string `yaml:"bucket"`
+			Region    string `yaml:"region"`
+			AccessKey string `yaml:"access_key"`
+			SecretKey string `yaml:"secret_key"`
+		} `yaml:"s3"`
+	} `yaml:"storage"`
+
+	Login struct {
+		FreeAttempts     int           `yaml:"free_attempts"`
+		BaseDelay        time.Duration `yaml:"base_delay"`
+		MaxDelay         time.Duration `yaml:"max_delay"`
+		LockoutThreshold int           `yaml:"lockout_threshold"`
+		LockoutDuration  time.Duration `yaml:"lockout_duration"`
+	} `yaml:"login"`
+
+	Mail struct {
+		SMTPAddr string `yaml:"smtp_addr"`
+		Username string `yaml:"username"`
+		Password string `yaml:"password"`
+		From     string `yaml:"from"`
+	} `yaml:"mail"`
+
+	PublicURL      string `yaml:"public_url"`
+	TrustedProxies string `yaml:"trusted_proxies"` // Comma separated, as TRUSTED_PROXIES
+
+	Webhooks struct {
+		Timeout      time.Duration `yaml:"timeout"`
+		MaxAttempts  int           `yaml:"max_attempts"`
+		AllowPrivate bool          `yaml:"allow_private"`
+	} `yaml:"webhooks"`
+
+	PDF struct {
+		Command string        `yaml:"command"`
+		Timeout time.Duration `yaml:"timeout"`
+	} `yaml:"pdf"`
+
+	Achievements struct {
+		SweepInterval time.Duration `yaml:"sweep_interval"`
+	} `yaml:"achievements"`
+
+	Accounts struct {
+		DeletionGracePeriod time.Duration `yaml:"deletion_grace_period"`
+	} `yaml:"accounts"`
+
+	Maintenance struct {
+		Enabled bool   `yaml:"enabled"`
+		Message string `yaml:"message"`
+	} `yaml:"maintenance"`
+
+	OAuth struct {
+		RedirectBaseURL    string `yaml:"redirect_base_url"`
+		GitHubClientID     string `yaml:"github_client_id"`
+		GitHubClientSecret string `yaml:"github_client_secret"`
+		GoogleClientID     string `yaml:"google_client_id"`
+		GoogleClientSecret string `yaml:"google_client_secret"`
+	} `yaml:"oauth"`
+
+	Internal struct {
+		HMACKeys         string        `yaml:"hmac_keys"` // Comma separated id:secret pairs, as INTERNAL_HMAC_KEYS
+		HMACKeyID        string        `yaml:"hmac_key_id"`
+		SignatureMaxSkew time.Duration `yaml:"signature_max_skew"`
+	} `yaml:"internal"`
+
+	JWT struct {
+		Secret         string        `yaml:"secret"`
+		KeysDir        string        `yaml:"keys_dir"`
+		SigningKeyID   string        `yaml:"signing_key_id"`
+		ReloadInterval time.Duration `yaml:"reload_interval"`
+	} `yaml:"jwt"`
+
+	RoutePolicies []RoutePolicy `yaml:"route_policies"` // Replaces the built-in table when set
+}
+
+// configFilePath returns the config file to load, or "" to only use defaults
+// and environment variables
+func configFilePath() string {
+	if path := os.Getenv("GOERA_CONFIG"); path != "" {
+		return path
+	}
+	if _, err := os.Stat(DefaultConfigFile); err == nil {
+		return DefaultConfigFile
+	}
+	return ""
+}
+
+// loadFile applies the serve section of the config file at path on top of the
+// built-in defaults. Keys missing from the file keep their default, unknown
+// keys are an error so typos do not go unnoticed.
+func loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	file := fileConfig{Serve: currentServeConfig()}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	s := file.Serve
+	ServerPort = s.Port
+	if ServerPort != "" && !strings.Contains(ServerPort, ":") {
+		ServerPort = ":" + ServerPort
+	}
+	GRPCListen = s.GRPCListen
+	if GRPCListen != "" && !strings.Contains(GRPCListen, ":") {
+		GRPCListen = ":" + GRPCListen
+	}
+	JudgeAddr = s.JudgeAddr
+
+	DBDriver = s.Database.Driver
+	DBPath = s.Database.Path
+	DBHost = s.Database.Host
+	DBUser = s.Database.User
+	DBPassword = s.Database.Password
+	DBName = s.Database.Name
+	DBPort = s.Database.Port
+	DBSSLMode = s.Database.SSLMode
+	DBMaxOpenConns = s.Database.MaxOpenConns
+	DBMaxIdleConns = s.Database.MaxIdleConns
+	DBConnMaxLifetime = s.Database.ConnMaxLifetime
+	DBStatementTimeout = s.Database.StatementTimeout
+	DBConnectTimeout = s.Database.ConnectTimeout
+
+	MaxTimeLimitMs = s.Limits.MaxTimeLimitMs
+	MaxMemoryLimitMB = s.Limits.MaxMemoryLimitMB
+	MaxTestCasesPerQuestion = s.Limits.MaxTestCasesPerQuestion
+	MaxTestCaseSizeBytes = s.Limits.MaxTestCaseSizeBytes
+	MaxTotalTestCaseBytes = s.Limits.MaxTotalTestCaseBytes
+	MaxSourceCodeBytes = s.Limits.MaxSourceCodeBytes
+	MaxSourceFiles = s.Limits.MaxSourceFiles
+
+	for resource, size := range s.PageSizes {
+		if _, ok := PageSizes[resource]; !ok {
+			return fmt.Errorf("invalid config file %s: unknown page size resource %q", path, resource)
+		}
+		PageSizes[resource] = PageSize{Default: size.Default, Max: size.Max}
+	}
+
+	RateLimitBackend = s.RateLimits.Backend
+	RedisAddr = s.RateLimits.Redis.Addr
+	RedisPassword = s.RateLimits.Redis.Password
+	RedisDB = s.RateLimits.Redis.DB
+	for group, limit := range s.RateLimits.Groups {
+		if _, ok := RateLimits[group]; !ok {
+			return fmt.Errorf("invalid config file %s: unknown rate limit group %q", path, group)
+		}
+		RateLimits[group] = RateLimit{Limit: limit.Limit, Window: limit.Window}
+	}
+
+	for kind, quota := range s.DailyQuotas {
+		if _, ok := DailyQuotas[kind]; !ok {
+			return fmt.Errorf("invalid config file %s: unknown daily quota %q", path, kind)
+		}
+		DailyQuotas[kind] = quota
+	}
+
+	CacheBackend = s.Cache.Backend
+	CacheTTL = s.Cache.TTL
+	CacheSize = s.Cache.Size
+
+	SubmissionStuckTimeout = s.Judging.StuckTimeout
+	SubmissionReaperInterval = s.Judging.ReaperInterval
+	MaxJudgeAttempts = s.Judging.MaxAttempts
+	JudgeDispatchInterval = s.Judging.DispatchInterval
+	JudgeOutboxMaxAge = s.Judging.OutboxMaxAge
+	JudgeBreakerThreshold = s.Judging.BreakerThreshold
+	JudgeBreakerCooldown = s.Judging.BreakerCooldown
+
+	PlagiarismThreshold = s.PlagiarismThreshold
+	AnonymousPractice = s.AnonymousPractice
+	PublishRequiresReferenceSolution = s.PublishRequiresRef
+	EditorialVisibility = s.EditorialVisibility
+	SupportedLanguages = s.SupportedLanguages
+	ContestPenaltyMinutes = s.Contests.PenaltyMinutes
+	ContestFreezeMinutes = s.Contests.FreezeMinutes
+	ScoreboardPushInterval = s.Contests.ScoreboardPushInterval
+	SentryDSN = s.Sentry.DSN
+	SentryEnvironment = s.Sentry.Environment
+	TracingEndpoint = s.Tracing.Endpoint
+	TracingSampleRatio = s.Tracing.SampleRatio
+	StorageBackend = s.Storage.Backend
+	StorageInlineLimit = s.Storage.InlineLimit
+	StorageDir = s.Storage.Dir
+	StorageURLBase = s.Storage.URLBase
+	StorageURLTTL = s.Storage.URLTTL
+	S3Endpoint = s.Storage.S3.Endpoint
+	S3Bucket = s.Storage.S3.Bucket
+	S3Region = s.Storage.S3.Region
+	S3AccessKey = s.Storage.S3.AccessKey
+	S3SecretKey = s.Storage.S3.SecretKey
+	LoginFreeAttempts = s.Login.FreeAttempts
+	LoginBaseDelay = s.Login.BaseDelay
+	LoginMaxDelay = s.Login.MaxDelay
+	LoginLockoutThreshold = s.Login.LockoutThreshold
+	LoginLockoutDuration = s.Login.LockoutDuration
+	SMTPAddr = s.Mail.SMTPAddr
+	SMTPUsername = s.Mail.Username
+	SMTPPassword = s.Mail.Password
+	SMTPFrom = s.Mail.From
+	WebhookTimeout = s.Webhooks.Timeout
+	WebhookMaxAttempts = s.Webhooks.MaxAttempts
+	WebhookAllowPrivate = s.Webhooks.AllowPrivate
+	PDFCommand = s.PDF.Command
+	PDFTimeout = s.PDF.Timeout
+	AchievementSweepInterval = s.Achievements.SweepInterval
+	AccountDeletionGracePeriod = s.Accounts.DeletionGracePeriod
+	PublicURL = s.PublicURL
+	TrustedProxies = s.TrustedProxies
+	MaintenanceMode = s.Maintenance.Enabled
+	MaintenanceMessage = s.Maintenance.Message
+
+	OAuthRedirectBaseURL = s.OAuth.RedirectBaseURL
+	GitHubClientID = s.OAuth.GitHubClientID
+	GitHubClientSecret = s.OAuth.GitHubClientSecret
+	GoogleClientID = s.OAuth.GoogleClientID
+	GoogleClientSecret = s.OAuth.GoogleClientSecret
+
+	if s.Internal.HMACKeys != "" {
+		InternalKeys, InternalKeyID = signing.ParseKeys(s.Internal.HMACKeys)
+	}
+	if s.Internal.HMACKeyID != "" {
+		InternalKeyID = s.Internal.HMACKeyID
+	}
+	InternalSignatureMaxSkew = s.Internal.SignatureMaxSkew
+
+	JWTSecret = s.JWT.Secret
+	JWTKeysDir = s.JWT.KeysDir
+	JWTSigningKeyID = s.JWT.SigningKeyID
+	JWTKeysReloadInterval = s.JWT.ReloadInterval
+
+	RoutePolicies = s.RoutePolicies
+	return nil
+}
+
+// currentServeConfig returns the current settings in the config file layout
+func currentServeConfig() serveFileConfig {
+	var s serveFileConfig
+	s.Port = ServerPort
+	s.GRPCListen = GRPCListen
+	s.JudgeAddr = JudgeAddr
+
+	s.Database.Driver = DBDriver
+	s.Database.Path = DBPath
+	s.Database.Host = DBHost
+	s.Database.User = DBUser
+	s.Database.Password = DBPassword
+	s.Database.Name = DBName
+	s.Database.Port = DBPort
+	s.Database.SSLMode = DBSSLMode
+	s.Database.MaxOpenConns = DBMaxOpenConns
+	s.Database.MaxIdleConns = DBMaxIdleConns
+	s.Database.ConnMaxLifetime = DBConnMaxLifetime
+	s.Database.StatementTimeout = DBStatementTimeout
+	s.Database.ConnectTimeout = DBConnectTimeout
+
+	s.Limits.MaxTimeLimitMs = MaxTimeLimitMs
+	s.Limits.MaxMemoryLimitMB = MaxMemoryLimitMB
+	s.Limits.MaxTestCasesPerQuestion = MaxTestCasesPerQuestion
+	s.Limits.MaxTestCaseSizeBytes = MaxTestCaseSizeBytes
+	s.Limits.MaxTotalTestCaseBytes = MaxTotalTestCaseBytes
+	s.Limits.MaxSourceCodeBytes = MaxSourceCodeBytes
+	s.Limits.MaxSourceFiles = MaxSourceFiles
+
+	s.RateLimits.Backend = RateLimitBackend
+	s.RateLimits.Redis.Addr = RedisAddr
+	s.RateLimits.Redis.Password = RedisPassword
+	s.RateLimits.Redis.DB = RedisDB
+
+	s.Cache.Backend = CacheBackend
+	s.Cache.TTL = CacheTTL
+	s.Cache.Size = CacheSize
+
+	s.Judging.StuckTimeout = SubmissionStuckTimeout
+	s.Judging.ReaperInterval = SubmissionReaperInterval
+	s.Judging.MaxAttempts = MaxJudgeAttempts
+	s.Judging.DispatchInterval = JudgeDispatchInterval
+	s.Judging.OutboxMaxAge = JudgeOutboxMaxAge
+	s.Judging.BreakerThreshold = JudgeBreakerThreshold
+	s.Judging.BreakerCooldown = JudgeBreakerCooldown
+
+	s.PlagiarismThreshold = PlagiarismThreshold
+	s.AnonymousPractice = AnonymousPractice
+	s.PublishRequiresRef = PublishRequiresReferenceSolution
+	s.EditorialVisibility = EditorialVisibility
+	s.SupportedLanguages = SupportedLanguages
+	s.Contests.PenaltyMinutes = ContestPenaltyMinutes
+	s.Contests.FreezeMinutes = ContestFreezeMinutes
+	s.Contests.ScoreboardPushInterval = ScoreboardPushInterval
+	s.Sentry.DSN = SentryDSN
+	s.Sentry.Environment = SentryEnvironment
+	s.Tracing.Endpoint = TracingEndpoint
+	s.Tracing.SampleRatio = TracingSampleRatio
+	s.Storage.Backend = StorageBackend
+	s.Storage.InlineLimit = StorageInlineLimit
+	s.Storage.Dir = StorageDir
+	s.Storage.URLBase = StorageURLBase
+	s.Storage.URLTTL = StorageURLTTL
+	s.Storage.S3.Endpoint = S3Endpoint
+	s.Storage.S3.Bucket = S3Bucket
+	s.Storage.S3.Region = S3Region
+	s.Storage.S3.AccessKey = S3AccessKey
+	s.Storage.S3.SecretKey = S3SecretKey
+	s.Login.FreeAttempts = LoginFreeAttempts
+	s.Login.BaseDelay = LoginBaseDelay
+	s.Login.MaxDelay = LoginMaxDelay
+	s.Login.LockoutThreshold = LoginLockoutThreshold
+	s.Login.LockoutDuration = LoginLockoutDuration
+	s.Mail.SMTPAddr = SMTPAddr
+	s.Mail.Username = SMTPUsername
+	s.Mail.Password = SMTPPassword
+	s.Mail.From = SMTPFrom
+	s.PublicURL = PublicURL
+	s.TrustedProxies = TrustedProxies
+	s.Webhooks.Timeout = WebhookTimeout
+	s.Webhooks.MaxAttempts = WebhookMaxAttempts
+	s.Webhooks.AllowPrivate = WebhookAllowPrivate
+	s.PDF.Command = PDFCommand
+	s.PDF.Timeout = PDFTimeout
+	s.Achievements.SweepInterval = AchievementSweepInterval
+	s.Accounts.DeletionGracePeriod = AccountDeletionGracePeriod
+	s.Maintenance.Enabled = MaintenanceMode
+	s.Maintenance.Message = MaintenanceMessage
+
+	s.OAuth.RedirectBaseURL = OAuthRedirectBaseURL
+	s.OAuth.GitHubClientID = GitHubClientID
+	s.OAuth.GitHubClientSecret = GitHubClientSecret
+	s.OAuth.GoogleClientID = GoogleClientID
+	s.OAuth.GoogleClientSecret = GoogleClientSecret
+
+	s.Internal.HMACKeyID = InternalKeyID
+	s.Internal.SignatureMaxSkew = InternalSignatureMaxSkew
+
+	s.JWT.Secret = JWTSecret
+	s.JWT.KeysDir = JWTKeysDir
+	s.JWT.SigningKeyID = JWTSigningKeyID
+	s.JWT.ReloadInterval = JWTKeysReloadInterval
+
+	s.RoutePolicies = RoutePolicies
+	return s
+}
+
+// validate checks the final settings, after the config file and environment
+// variables were applied, and reports every problem at once
+func validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(DBDriver == "postgres" || DBDriver == "sqlite", "database driver must be postgres or sqlite, got %q", DBDriver)
+	check(DBDriver != "sqlite" || DBPath != "", "database path is required for sqlite")
+	check(DBMaxOpenConns >= 0, "database max open connections cannot be negative")
+	check(DBMaxIdleConns >= 0, "database max idle connections cannot be negative")
+	check(DBMaxOpenConns == 0 || DBMaxIdleConns <= DBMaxOpenConns, "database max idle connections cannot exceed max open connections")
+	check(DBConnMaxLifetime >= 0, "database connection max lifetime cannot be negative")
+	check(DBStatementTimeout >= 0, "database statement timeout cannot be negative")
+	check(DBConnectTimeout >= 0, "database connect timeout cannot be negative")
+	check(validAddr(JudgeAddr), "judge address %q is not a host:port address", JudgeAddr)
+	check(strings.TrimLeft(GRPCListen, ":") != "", "gRPC listen address is required")
+	check(validURL(OAuthRedirectBaseURL), "OAuth redirect base URL %q is not an http(s) URL", OAuthRedirectBaseURL)
+
+	check(MaxTimeLimitMs > 0, "max time limit must be positive")
+	check(MaxMemoryLimitMB > 0, "max memory limit must be positive")
+	check(MaxTestCasesPerQuestion > 0, "max test cases per question must be positive")
+	check(MaxTestCaseSizeBytes > 0, "max test case size must be positive")
+	check(MaxTotalTestCaseBytes >= MaxTestCaseSizeBytes, "max total test case size must be at least the max test case size")
+	check(MaxSourceCodeBytes > 0, "max source code size must be positive")
+	check(MaxSourceFiles > 0, "max source files must be positive")
+
+	for resource, size := range PageSizes {
+		check(size.Default > 0 && size.Max >= size.Default, "page size of %s must be positive and at most its max", resource)
+	}
+
+	for group, limit := range RateLimits {
+		check(limit.Limit >= 0, "rate limit of %s cannot be negative", group)
+		check(limit.Window > 0, "rate limit window of %s must be positive", group)
+	}
+	for kind, quota := range DailyQuotas {
+		check(quota >= 0, "daily quota of %s cannot be negative", kind)
+	}
+	check(RateLimitBackend == "memory" || RateLimitBackend == "redis", "rate limit backend must be memory or redis, got %q", RateLimitBackend)
+	check(RateLimitBackend != "redis" || RedisAddr != "", "a Redis address is required for the redis rate limit backend")
+	check(RedisDB >= 0, "Redis database cannot be negative")
+	check(slices.Contains([]string{"memory", "redis", "none"}, CacheBackend), "cache backend must be memory, redis or none, got %q", CacheBackend)
+	check(CacheBackend != "redis" || RedisAddr != "", "a Redis address is required for the redis cache backend")
+	check(CacheTTL > 0, "cache TTL must be positive")
+	check(CacheSize > 0, "cache size must be positive")
+
+	check(SubmissionStuckTimeout > 0, "submission stuck timeout must be positive")
+	check(SubmissionReaperInterval > 0, "submission reaper interval must be positive")
+	check(MaxJudgeAttempts > 0, "max judge attempts must be positive")
+	check(JudgeDispatchInterval > 0, "judge dispatch interval must be positive")
+	check(JudgeOutboxMaxAge > 0, "judge outbox max age must be positive")
+	check(JudgeBreakerThreshold > 0, "judge breaker threshold must be positive")
+	check(JudgeBreakerCooldown > 0, "judge breaker cooldown must be positive")
+
+	check(PlagiarismThreshold >= 0 && PlagiarismThreshold <= 1, "plagiarism threshold must be between 0 and 1")
+	check(slices.Contains([]string{"after_solve", "after_release", "after_contest", "always"}, EditorialVisibility),
+		"editorial visibility must be after_solve, after_release, after_contest or always, got %q", EditorialVisibility)
+	check(len(SupportedLanguages) > 0, "at least one supported language is required")
+	check(ContestPenaltyMinutes >= 0, "contest penalty minutes cannot be negative")
+	check(ContestFreezeMinutes >= 0, "contest freeze minutes cannot be negative")
+	check(ScoreboardPushInterval > 0, "contest scoreboard push interval must be positive")
+	check(SentryDSN == "" || validURL(SentryDSN), "Sentry DSN is not an http(s) URL")
+	check(TracingEndpoint == "" || validURL(TracingEndpoint), "tracing endpoint is not an http(s) URL")
+	check(TracingSampleRatio >= 0 && TracingSampleRatio <= 1, "tracing sample ratio must be between 0 and 1")
+	check(slices.Contains([]string{"database", "local", "s3"}, StorageBackend), "storage backend must be database, local or s3, got %q", StorageBackend)
+	check(StorageInlineLimit >= 0, "storage inline limit cannot be negative")
+	check(StorageBackend != "local" || StorageDir != "", "a storage directory is required for the local storage backend")
+	check(StorageBackend != "local" || validURL(StorageURLBase), "storage URL base %q is not an http(s) URL", StorageURLBase)
+	check(StorageBackend != "local" || len(InternalKeys) > 0, "internal HMAC keys are required to sign local storage links")
+	check(StorageURLTTL > 0, "storage URL TTL must be positive")
+	check(StorageBackend != "s3" || validURL(S3Endpoint), "S3 endpoint %q is not an http(s) URL", S3Endpoint)
+	check(StorageBackend != "s3" || S3Bucket != "", "an S3 bucket is required for the s3 storage backend")
+	check(StorageBackend != "s3" || S3Region != "", "an S3 region is required for the s3 storage backend")
+	check(StorageBackend != "s3" || (S3AccessKey != "" && S3SecretKey != ""), "S3 credentials are required for the s3 storage backend")
+	check(LoginFreeAttempts >= 0, "login free attempts cannot be negative")
+	check(LoginBaseDelay > 0 && LoginMaxDelay >= LoginBaseDelay, "login base delay must be positive and at most the max delay")
+	check(LoginLockoutThreshold > LoginFreeAttempts, "login lockout threshold must be above the free attempts")
+	check(LoginLockoutDuration > 0, "login lockout duration must be positive")
+	check(SMTPAddr == "" || validAddr(SMTPAddr), "SMTP address %q is not a host:port address", SMTPAddr)
+	check(SMTPFrom != "", "mail sender address is required")
+	check(WebhookTimeout > 0, "webhook timeout must be positive")
+	check(WebhookMaxAttempts > 0, "webhook max attempts must be positive")
+	check(PDFTimeout > 0, "PDF timeout must be positive")
+	check(AchievementSweepInterval > 0, "achievement sweep interval must be positive")
+	check(AccountDeletionGracePeriod >= 0, "account deletion grace period cannot be negative")
+	check(validURL(PublicURL), "public URL %q is not an http(s) URL", PublicURL)
+	for _, proxy := range strings.Split(TrustedProxies, ",") {
+		check(strings.TrimSpace(proxy) == "" || ParseProxy(proxy) != nil, "trusted proxy %q is not an IP address or CIDR range", proxy)
+	}
+	check(MaintenanceMessage != "", "maintenance message is required")
+
+	if len(InternalKeys) > 0 {
+		_, ok := InternalKeys[InternalKeyID]
+		check(ok, "internal HMAC key ID %q is not one of the configured keys", InternalKeyID)
+	}
+	check(InternalSignatureMaxSkew > 0, "internal signature max skew must be positive")
+	check(JWTSigningKeyID == "" || JWTKeysDir != "", "a JWT keys directory is required to use a JWT signing key ID")
+	check(JWTKeysReloadInterval > 0, "JWT keys reload interval must be positive")
+
+	for i, policy := range RoutePolicies {
+		pattern := strings.TrimSuffix(policy.Pattern, "*")
+		check(strings.HasPrefix(pattern, "/") && !strings.Contains(pattern, "*"),
+			"route policy %d: pattern %q must start with / and may only end in *", i+1, policy.Pattern)
+		check(slices.Contains([]string{AuthPublic, AuthUser, AuthSession, AuthAnonymous}, policy.Auth),
+			"route policy %d: auth must be public, user, session or anonymous, got %q", i+1, policy.Auth)
+		check(policy.Role == "" || strings.EqualFold(policy.Role, "admin") || strings.EqualFold(policy.Role, "user"),
+			"route policy %d: role must be admin or user, got %q", i+1, policy.Role)
+		check(policy.Role == "" || policy.Auth == AuthUser || policy.Auth == AuthSession,
+			"route policy %d: a role requires user or session auth", i+1)
+		for _, method := range policy.Methods {
+			check(slices.Contains(httpMethods, strings.ToUpper(method)), "route policy %d: unknown method %q", i+1, method)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// httpMethods are the request methods a route policy can name
+var httpMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// validAddr reports whether value is a host:port address
+func validAddr(value string) bool {
+	host, port, err := net.SplitHostPort(value)
+	return err == nil && host != "" && port != ""
+}
+
+// ParseProxy parses an entry of TrustedProxies, an IP address or a CIDR
+// range, returning nil when it is neither
+func ParseProxy(value string) *net.IPNet {
+	value = strings.TrimSpace(value)
+	if _, network, err := net.ParseCIDR(value); err == nil {
+		return network
+	}
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil
+	}
+	bits := 8 * net.IPv4len
+	if ip.To4() == nil {
+		bits = 8 * net.IPv6len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+}
+
+// validURL reports whether value is an absolute http or https URL
+func validURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
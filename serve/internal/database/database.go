@@ -1,52 +1,146 @@
-package database
-
-import (
-	"fmt"
-	"goera/serve/internal/config"
-	"goera/serve/internal/models"
-	"log"
-
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
-)
-
-var DB *gorm.DB
-
-func InitDB() error {
-	var err error
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
-		config.DBHost, config.DBUser, config.DBPassword, config.DBName, config.DBPort, config.DBSSLMode)
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
-	if err != nil {
-		log.Printf("Error: Failed to connect as application user '%s': %v", config.DBUser, err)
-		return fmt.Errorf("failed to connect database as user %s: %w", config.DBUser, err)
-	}
-
-	// Run migrations
-	migrations := map[string]func(*gorm.DB) error{
-		"Question":   models.MigrateQuestion,
-		"User":       models.MigrateUser,
-		"Submission": models.MigrateSubmission,
-		"TestCase":   models.MigrateTestCase,
-	}
-	for name, migrateFunc := range migrations {
-		if err := migrateFunc(DB); err != nil {
-			log.Printf("Error: Failed to run migration for %s: %v", name, err)
-			return fmt.Errorf("failed migration for %s: %w", name, err)
-		}
-	}
-
-	return nil
-}
-
-func CloseDB() error {
-	db, err := DB.DB()
-	if err != nil {
-		return err
-	}
-	return db.Close()
-}
-
-func GetDB() *gorm.DB {
-	return DB
-}
+package database
+
+import (
+	"fmt"
+	"goera/serve/internal/config"
+	"goera/serve/internal/models"
+	"goera/serve/internal/tracing"
+	"log"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+var DB *gorm.DB
+
+// migrations create and update the table of each model, by model name
+var migrations = map[string]func(*gorm.DB) error{
+	"Question":            models.MigrateQuestion,
+	"User":                models.MigrateUser,
+	"Submission":          models.MigrateSubmission,
+	"TestCase":            models.MigrateTestCase,
+	"SubmissionEvent":     models.MigrateSubmissionEvent,
+	"Clarification":       models.MigrateClarification,
+	"Announcement":        models.MigrateAnnouncement,
+	"OAuthIdentity":       models.MigrateOAuthIdentity,
+	"QuestionRevision":    models.MigrateQuestionRevision,
+	"SimilarityScore":     models.MigrateSimilarityScore,
+	"APIToken":            models.MigrateAPIToken,
+	"Contest":             models.MigrateContest,
+	"JudgeOutbox":         models.MigrateJudgeOutbox,
+	"Group":               models.MigrateGroup,
+	"QuestionDraft":       models.MigrateQuestionDraft,
+	"DifficultyVote":      models.MigrateDifficultyVote,
+	"JudgeCallback":       models.MigrateJudgeCallback,
+	"Notification":        models.MigrateNotification,
+	"QuestionGenerator":   models.MigrateQuestionGenerator,
+	"Session":             models.MigrateSession,
+	"AuditLog":            models.MigrateAuditLog,
+	"QuestionReview":      models.MigrateQuestionReview,
+	"UserPreferences":     models.MigrateUserPreferences,
+	"LoginThrottle":       models.MigrateLoginThrottle,
+	"Maintenance":         models.MigrateMaintenance,
+	"QuestionContributor": models.MigrateQuestionContributor,
+	"APIUsage":            models.MigrateAPIUsage,
+	"Webhook":             models.MigrateWebhook,
+	"Achievement":         models.MigrateAchievement,
+	"LoginEvent":          models.MigrateLoginEvent,
+	"Collection":          models.MigrateCollection,
+}
+
+// dialector returns the gorm dialector for the configured database driver
+func dialector() (gorm.Dialector, error) {
+	switch config.DBDriver {
+	case "", "postgres":
+		dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
+			config.DBHost, config.DBUser, config.DBPassword, config.DBName, config.DBPort, config.DBSSLMode)
+		if config.DBStatementTimeout > 0 {
+			// Unknown DSN keys are sent to the server as session parameters
+			dsn += fmt.Sprintf(" statement_timeout=%d", config.DBStatementTimeout.Milliseconds())
+		}
+		return postgres.Open(dsn), nil
+	case "sqlite":
+		// Foreign keys are off by default in SQLite
+		return sqlite.Open(config.DBPath + "?_foreign_keys=on"), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", config.DBDriver)
+	}
+}
+
+// Backoff between connection attempts at boot
+const (
+	connectRetryInitial = time.Second
+	connectRetryMax     = 10 * time.Second
+)
+
+// open connects to the database. PostgreSQL is retried with exponential
+// backoff for up to config.DBConnectTimeout, so the server can boot while the
+// database is still starting or restarting.
+func open(dial gorm.Dialector) (*gorm.DB, error) {
+	deadline := time.Now().Add(config.DBConnectTimeout)
+	wait := connectRetryInitial
+	for {
+		// gorm pings the database on open, so a refused connection fails here
+		db, err := gorm.Open(dial, &gorm.Config{})
+		if err == nil || config.DBDriver == "sqlite" || time.Now().Add(wait).After(deadline) {
+			return db, err
+		}
+
+		log.Printf("Failed to connect to the database, retrying in %s: %v", wait, err)
+		time.Sleep(wait)
+		wait = min(wait*2, connectRetryMax)
+	}
+}
+
+func InitDB() error {
+	dial, err := dialector()
+	if err != nil {
+		return err
+	}
+
+	DB, err = open(dial)
+	if err != nil {
+		if config.DBDriver == "sqlite" {
+			log.Printf("Error: Failed to open SQLite database '%s': %v", config.DBPath, err)
+			return fmt.Errorf("failed to open sqlite database %s: %w", config.DBPath, err)
+		}
+		log.Printf("Error: Failed to connect as application user '%s': %v", config.DBUser, err)
+		return fmt.Errorf("failed to connect database as user %s: %w", config.DBUser, err)
+	}
+
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get database connection pool: %w", err)
+	}
+	sqlDB.SetMaxOpenConns(config.DBMaxOpenConns)
+	sqlDB.SetMaxIdleConns(config.DBMaxIdleConns)
+	sqlDB.SetConnMaxLifetime(config.DBConnMaxLifetime)
+
+	if err := DB.Use(tracing.GormPlugin{}); err != nil {
+		return fmt.Errorf("failed to register query tracing: %w", err)
+	}
+
+	// Run migrations
+	for name, migrateFunc := range migrations {
+		if err := migrateFunc(DB); err != nil {
+			log.Printf("Error: Failed to run migration for %s: %v", name, err)
+			return fmt.Errorf("failed migration for %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func CloseDB() error {
+	db, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	return db.Close()
+}
+
+func GetDB() *gorm.DB {
+	return DB
+}
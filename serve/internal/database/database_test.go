@@ -0,0 +1,32 @@
+package database
+
+import (
+	"testing"
+
+	"goera/serve/internal/config"
+)
+
+// TestInitDBSQLite boots the server's database on in-memory SQLite and checks
+// that every migration created its table, so the development driver keeps
+// working alongside PostgreSQL
+func TestInitDBSQLite(t *testing.T) {
+	t.Setenv("DB_DRIVER", "sqlite")
+	t.Setenv("DB_PATH", ":memory:")
+	// Each connection to :memory: opens a database of its own
+	t.Setenv("DB_MAX_OPEN_CONNS", "1")
+	t.Setenv("DB_MAX_IDLE_CONNS", "1")
+	if err := config.Init(); err != nil {
+		t.Fatalf("config: %v", err)
+	}
+	if err := InitDB(); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	defer CloseDB()
+
+	for name := range migrations {
+		table := DB.NamingStrategy.TableName(name)
+		if !DB.Migrator().HasTable(table) {
+			t.Errorf("migration %s did not create table %s", name, table)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"goera/serve/internal/api"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/maintenance"
+	"goera/serve/internal/models"
+	"goera/serve/internal/templates"
+	"goera/serve/internal/utils"
+)
+
+// AdminDashboardData holds the data needed for the admin dashboard template
+type AdminDashboardData struct {
+	Overview      api.AdminOverview
+	Maintenance   maintenance.State
+	CurrentUserID uint
+}
+
+// AdminDashboardHandler shows operators the system overview
+func AdminDashboardHandler(w http.ResponseWriter, r *http.Request) {
+	userID, exists := auth.UserIDFromContext(r.Context())
+	if !exists {
+		http.Redirect(w, r, "/login?error=unauthorized", http.StatusSeeOther)
+		return
+	}
+
+	user, err := auth.GetUserFromContext(r.Context())
+	if err != nil {
+		log.Printf("Error getting user from context: %v", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+	if user.Role != models.AdminRole {
+		http.Error(w, "Only administrators can view the dashboard", http.StatusForbidden)
+		return
+	}
+
+	apiClient := utils.GetAPIClient()
+	var overview api.AdminOverview
+	if err := apiClient.Get(r, "/api/admin/overview", &overview); err != nil {
+		log.Printf("Error fetching admin overview: %v", err)
+		http.Error(w, "Failed to fetch overview", http.StatusInternalServerError)
+		return
+	}
+
+	data := AdminDashboardData{
+		Overview:      overview,
+		Maintenance:   maintenance.Current(),
+		CurrentUserID: userID,
+	}
+
+	err = templates.Render(w, r, "adminDashboard.html", data)
+	if err != nil {
+		log.Printf("Error executing admin dashboard template: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"goera/serve/internal/api"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/config"
+	"goera/serve/internal/models"
+	"goera/serve/internal/templates"
+	"goera/serve/internal/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// CollectionsData holds the data needed for the collections template
+type CollectionsData struct {
+	Collections   []models.Collection // Public collections, newest first
+	Mine          []models.Collection // The current user's own collections
+	Page          int
+	TotalPages    int
+	CurrentUserID uint
+	SignedIn      bool
+}
+
+// CollectionData holds the data needed for the collection template
+type CollectionData struct {
+	Collection    api.CollectionResponse
+	QuestionIDs   string // The collection's question IDs in order, for the edit form
+	ShareURL      string
+	CanEdit       bool
+	CurrentUserID uint
+	SignedIn      bool
+}
+
+// collectionsAPIResponse is a page of /api/collections
+type collectionsAPIResponse struct {
+	Data       []models.Collection `json:"data"`
+	Page       int                 `json:"page"`
+	TotalPages int                 `json:"total_pages"`
+}
+
+// CollectionsHandler lists public collections and the current user's own
+func CollectionsHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	apiClient := utils.GetAPIClient()
+	var public collectionsAPIResponse
+	if err := apiClient.Get(r, fmt.Sprintf("/api/collections?page=%d", page), &public); err != nil {
+		log.Printf("Error fetching collections: %v", err)
+		http.Error(w, "Failed to fetch collections", http.StatusInternalServerError)
+		return
+	}
+
+	currentUserID, signedIn := auth.UserIDFromContext(r.Context())
+	data := CollectionsData{
+		Collections:   public.Data,
+		Page:          public.Page,
+		TotalPages:    public.TotalPages,
+		CurrentUserID: currentUserID,
+		SignedIn:      signedIn,
+	}
+	if signedIn {
+		var mine collectionsAPIResponse
+		if err := apiClient.Get(r, "/api/collections?mine=true&page_size=100", &mine); err != nil {
+			log.Printf("Error fetching own collections: %v", err)
+		}
+		data.Mine = mine.Data
+	}
+
+	err = templates.Render(w, r, "collections.html", data)
+	if err != nil {
+		log.Printf("Error executing collections template: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// CollectionHandler shows a collection with its questions in order
+func CollectionHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	apiClient := utils.GetAPIClient()
+	var collection api.CollectionResponse
+	if err := apiClient.Get(r, "/api/collections/"+id, &collection); err != nil {
+		if err.Error() == "API returned status 404" {
+			http.NotFound(w, r)
+		} else {
+			log.Printf("Error fetching collection via API: %v", err)
+			http.Error(w, "Failed to retrieve collection", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	currentUserID, signedIn := auth.UserIDFromContext(r.Context())
+	canEdit := signedIn && collection.OwnerID == currentUserID
+	if signedIn && !canEdit {
+		if viewer, err := auth.GetUserFromContext(r.Context()); err == nil {
+			canEdit = viewer.Role == models.AdminRole
+		}
+	}
+
+	questionIDs := make([]string, len(collection.Questions))
+	for i, question := range collection.Questions {
+		questionIDs[i] = strconv.FormatUint(uint64(question.ID), 10)
+	}
+
+	data := CollectionData{
+		Collection:    collection,
+		QuestionIDs:   strings.Join(questionIDs, ", "),
+		ShareURL:      strings.TrimSuffix(config.PublicURL, "/") + "/collection/" + id,
+		CanEdit:       canEdit,
+		CurrentUserID: currentUserID,
+		SignedIn:      signedIn,
+	}
+
+	err := templates.Render(w, r, "collection.html", data)
+	if err != nil {
+		log.Printf("Error executing collection template: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
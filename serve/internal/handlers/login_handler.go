@@ -1,54 +1,64 @@
-package handler
-
-import (
-	"html/template"
-	"net/http"
-
-	"goera/serve/internal/auth"
-)
-
-type LoginData struct {
-	ErrorMessage string
-}
-
-func LoginHandler(w http.ResponseWriter, r *http.Request) {
-	cookie, err := r.Cookie("token")
-	if err == nil && cookie.Value != "" {
-		claims, err := auth.ValidateJWT(cookie.Value)
-		if err == nil && claims.UserID > 0 {
-			http.Redirect(w, r, "/questions", http.StatusSeeOther)
-			return
-		}
-	}
-
-	errorCode := r.URL.Query().Get("error")
-	var errorMessage string
-
-	switch errorCode {
-	case "invalid_credentials":
-		errorMessage = "Invalid username or password. Please try again."
-	case "server_error":
-		errorMessage = "A server error occurred. Please try again later."
-	case "unauthorized":
-		errorMessage = "Please login to access that page."
-	case "":
-	default:
-		errorMessage = "An error occurred. Please try again."
-	}
-
-	data := LoginData{
-		ErrorMessage: errorMessage,
-	}
-
-	tmpl, err := template.ParseFiles("web/templates/login.html")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	err = tmpl.Execute(w, data)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-}
+package handler
+
+import (
+	"net/http"
+
+	"goera/serve/internal/auth"
+	"goera/serve/internal/oauth"
+	"goera/serve/internal/templates"
+)
+
+type LoginData struct {
+	ErrorMessage   string
+	SuccessMessage string
+	Providers      []*oauth.Provider
+}
+
+func LoginHandler(w http.ResponseWriter, r *http.Request) {
+	if _, signedIn := auth.UserIDFromContext(r.Context()); signedIn {
+		http.Redirect(w, r, "/questions", http.StatusSeeOther)
+		return
+	}
+
+	errorCode := r.URL.Query().Get("error")
+	var errorMessage string
+
+	switch errorCode {
+	case "invalid_credentials":
+		errorMessage = "Invalid username or password. Please try again."
+	case "server_error":
+		errorMessage = "A server error occurred. Please try again later."
+	case "unauthorized":
+		errorMessage = "Please login to access that page."
+	case "oauth_failed":
+		errorMessage = "Sign in with the external provider failed. Please try again."
+	case "oauth_already_linked":
+		errorMessage = "That external account is already linked to another user."
+	case "too_many_attempts":
+		errorMessage = "Too many failed logins. Please wait a moment before trying again."
+	case "account_locked":
+		errorMessage = "This account is locked after too many failed logins. Check your email for a link to unlock it."
+	case "invalid_unlock_link":
+		errorMessage = "The unlock link is invalid or was already used."
+	case "":
+	default:
+		errorMessage = "An error occurred. Please try again."
+	}
+
+	var successMessage string
+	if r.URL.Query().Get("success") == "account_unlocked" {
+		successMessage = "Your account is unlocked. You can sign in again."
+	}
+
+	data := LoginData{
+		ErrorMessage:   errorMessage,
+		SuccessMessage: successMessage,
+		Providers:      oauth.Enabled(),
+	}
+
+	err := templates.Render(w, r, "login.html", data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
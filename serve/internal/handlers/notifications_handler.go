@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"goera/serve/internal/auth"
+	"goera/serve/internal/models"
+	"goera/serve/internal/templates"
+	"goera/serve/internal/utils"
+)
+
+// NotificationsData holds the data needed for the notifications template
+type NotificationsData struct {
+	Notifications []models.Notification
+	Page          int
+	TotalPages    int
+	CurrentUserID uint
+}
+
+// NotificationsAPIResponse matches the API's response format
+type NotificationsAPIResponse struct {
+	Data       []models.Notification `json:"data"`
+	Page       int                   `json:"page"`
+	PageSize   int                   `json:"page_size"`
+	TotalItems int64                 `json:"total_items"`
+	TotalPages int                   `json:"total_pages"`
+}
+
+// NotificationsHandler lists the current user's notifications
+func NotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	apiClient := utils.GetAPIClient()
+	var apiResponse NotificationsAPIResponse
+	err = apiClient.Get(r, fmt.Sprintf("/api/notifications?page=%d", page), &apiResponse)
+	if err != nil {
+		log.Printf("Error fetching notifications: %v", err)
+		http.Error(w, "Failed to fetch notifications", http.StatusInternalServerError)
+		return
+	}
+
+	currentUserID, _ := auth.UserIDFromContext(r.Context())
+
+	data := NotificationsData{
+		Notifications: apiResponse.Data,
+		Page:          apiResponse.Page,
+		TotalPages:    apiResponse.TotalPages,
+		CurrentUserID: currentUserID,
+	}
+
+	err = templates.Render(w, r, "notifications.html", data)
+	if err != nil {
+		log.Printf("Error executing notifications template: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -1,104 +1,174 @@
-package handler
-
-import (
-	"goera/serve/internal/models"
-	"html/template"
-	"log"
-	"net/http"
-	"strconv"
-
-	"goera/serve/internal/auth"
-	"goera/serve/internal/utils"
-
-	"github.com/gorilla/mux"
-)
-
-// ProfileData holds the information needed for the profile template
-type ProfileData struct {
-	ProfileUser    models.User
-	IsViewerAdmin  bool
-	TotalAttempted int    // Placeholder - Add logic to calculate these later
-	TotalSolved    int    // Placeholder
-	SuccessRate    int    // Placeholder
-	JoinDate       string // Placeholder for formatted join date
-	IsAdmin        bool   // Is the profile user an admin?
-	UserID         uint   // User ID of the profile user
-	Username       string // Username of the profile user
-	CurrentUserID  uint   // Added for dynamic profile link
-}
-
-func ProfileHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	idStr := vars["id"]
-	// Validate idStr is a number before using it? (Optional, depends on desired robustness)
-	_, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		log.Printf("Invalid profile user ID format: %v", err)
-		http.Error(w, "Invalid User ID", http.StatusBadRequest)
-		return
-	}
-
-	apiClient := utils.GetAPIClient()
-
-	// 1. Fetch the user whose profile is being viewed via API
-	var profileUser models.User
-
-	err = apiClient.Get(r, "/api/user/"+idStr, &profileUser)
-	if err != nil {
-		if err.Error() == "API returned status 404" {
-			http.NotFound(w, r)
-		} else {
-			log.Printf("Error fetching profile user via API: %v", err)
-			http.Error(w, "Failed to retrieve user profile", http.StatusInternalServerError)
-		}
-		return
-	}
-
-	// 2. Fetch the currently logged-in user (viewer) via API
-	viewerUserID, viewerExists := auth.UserIDFromContext(r.Context())
-	var isViewerAdmin bool
-	var viewerUser models.User
-	if viewerExists {
-		// Clone the request to avoid modifying the original
-		viewerReq := r.Clone(r.Context())
-		viewerReq.Header.Set("userID", strconv.FormatUint(uint64(viewerUserID), 10))
-		err = apiClient.Get(viewerReq, "/api/users", &viewerUser)
-		if err != nil {
-			if err.Error() != "API returned status 404" {
-				log.Printf("Error fetching viewing user via API: %v", err)
-			}
-		} else {
-			isViewerAdmin = (viewerUser.Role == models.AdminRole)
-		}
-	}
-
-	// 3. Prepare data for the template
-	// TODO: Add logic to calculate stats (TotalAttempted, TotalSolved, SuccessRate)
-	data := ProfileData{
-		ProfileUser:   profileUser,
-		IsViewerAdmin: isViewerAdmin,
-		IsAdmin:       profileUser.Role == models.AdminRole,
-		CurrentUserID: viewerUserID,
-		UserID:        profileUser.ID,
-		Username:      profileUser.Username,
-		// Placeholder values - replace with actual calculations later
-		TotalAttempted: 0,
-		TotalSolved:    0,
-		SuccessRate:    0,
-		JoinDate:       profileUser.CreatedAt.Format("January 2006"), // Format join date
-	}
-
-	// 4. Parse and execute the template
-	tmpl, err := template.ParseFiles("web/templates/profile.html", "web/templates/base.html") // Include base if needed
-	if err != nil {
-		log.Printf("Error parsing profile template: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	err = tmpl.ExecuteTemplate(w, "profile.html", data)
-	if err != nil {
-		log.Printf("Error executing profile template: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	}
-}
+package handler
+
+import (
+	"goera/serve/internal/models"
+	"log"
+	"net/http"
+	"strconv"
+
+	"goera/serve/internal/api"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/config"
+	"goera/serve/internal/templates"
+	"goera/serve/internal/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// ProfileData holds the information needed for the profile template
+type ProfileData struct {
+	ProfileUser    models.User
+	IsViewerAdmin  bool
+	TotalAttempted int64  // Questions the user submitted to
+	TotalSolved    int    // Questions with an accepted submission
+	SuccessRate    int    // Solved questions as a percentage of attempted ones
+	JoinDate       string // Placeholder for formatted join date
+	IsAdmin        bool   // Is the profile user an admin?
+	UserID         uint   // User ID of the profile user
+	Username       string // Username of the profile user
+	CurrentUserID  uint   // Added for dynamic profile link
+
+	RecentSubmissions []api.PublicSubmission
+	SolvedQuestions   []api.SolvedQuestion
+	Achievements      []api.AchievementResponse
+	Setter            api.SetterStats // Contributions as a problem setter, for the setter badge
+
+	// Shown on the user's own profile, for the preferences form
+	Languages      []string
+	Timezones      []string
+	SuccessMessage string
+	ErrorMessage   string
+	Deletion       api.AccountDeletionResponse // Scheduled deletion of the account
+	Logins         []models.LoginEvent         // Recent logins, for the Security tab
+}
+
+// suggestedTimezones are offered in the preferences form; any IANA zone works
+var suggestedTimezones = []string{
+	"UTC", "Asia/Tehran", "Asia/Dubai", "Asia/Kolkata", "Asia/Shanghai", "Asia/Tokyo",
+	"Europe/London", "Europe/Berlin", "Europe/Istanbul", "America/New_York",
+	"America/Chicago", "America/Los_Angeles", "Australia/Sydney",
+}
+
+// profileSubmissionsResponse is a page of /api/user/{id}/submissions
+type profileSubmissionsResponse struct {
+	Data []api.PublicSubmission `json:"data"`
+}
+
+// recentSubmissionsShown is the number of submissions on the profile's Submissions tab
+const recentSubmissionsShown = 20
+
+func ProfileHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+	// Validate idStr is a number before using it? (Optional, depends on desired robustness)
+	_, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		log.Printf("Invalid profile user ID format: %v", err)
+		http.Error(w, "Invalid User ID", http.StatusBadRequest)
+		return
+	}
+
+	apiClient := utils.GetAPIClient()
+
+	// 1. Fetch the user whose profile is being viewed via API
+	var profileUser models.User
+
+	err = apiClient.Get(r, "/api/user/"+idStr, &profileUser)
+	if err != nil {
+		if err.Error() == "API returned status 404" {
+			http.NotFound(w, r)
+		} else {
+			log.Printf("Error fetching profile user via API: %v", err)
+			http.Error(w, "Failed to retrieve user profile", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// 2. Fetch the currently logged-in user (viewer) via API
+	viewerUserID, viewerExists := auth.UserIDFromContext(r.Context())
+	var isViewerAdmin bool
+	var viewerUser models.User
+	if viewerExists {
+		// Clone the request to avoid modifying the original
+		viewerReq := r.Clone(r.Context())
+		viewerReq.Header.Set("userID", strconv.FormatUint(uint64(viewerUserID), 10))
+		err = apiClient.Get(viewerReq, "/api/users", &viewerUser)
+		if err != nil {
+			if err.Error() != "API returned status 404" {
+				log.Printf("Error fetching viewing user via API: %v", err)
+			}
+		} else {
+			isViewerAdmin = (viewerUser.Role == models.AdminRole)
+		}
+	}
+
+	// 3. Fetch the user's public submissions, solved questions, badges and
+	// setter contributions. The profile still renders without them if any call fails.
+	var submissions profileSubmissionsResponse
+	if err := apiClient.Get(r, "/api/user/"+idStr+"/submissions?page_size="+strconv.Itoa(recentSubmissionsShown), &submissions); err != nil {
+		log.Printf("Error fetching profile submissions via API: %v", err)
+	}
+	var solved api.SolvedResponse
+	if err := apiClient.Get(r, "/api/user/"+idStr+"/solved", &solved); err != nil {
+		log.Printf("Error fetching solved questions via API: %v", err)
+	}
+	var achievements []api.AchievementResponse
+	if err := apiClient.Get(r, "/api/user/"+idStr+"/achievements", &achievements); err != nil {
+		log.Printf("Error fetching achievements via API: %v", err)
+	}
+	var setter api.SetterStats
+	if err := apiClient.Get(r, "/api/user/"+idStr+"/setter", &setter); err != nil {
+		log.Printf("Error fetching setter statistics via API: %v", err)
+	}
+
+	successRate := 0
+	if solved.Attempted > 0 {
+		successRate = int(int64(len(solved.Solved)) * 100 / solved.Attempted)
+	}
+
+	// 4. Prepare data for the template
+	data := ProfileData{
+		ProfileUser:       profileUser,
+		IsViewerAdmin:     isViewerAdmin,
+		IsAdmin:           profileUser.Role == models.AdminRole,
+		CurrentUserID:     viewerUserID,
+		UserID:            profileUser.ID,
+		Username:          profileUser.Username,
+		TotalAttempted:    solved.Attempted,
+		TotalSolved:       len(solved.Solved),
+		SuccessRate:       successRate,
+		JoinDate:          profileUser.CreatedAt.Format("January 2006"), // Format join date
+		RecentSubmissions: submissions.Data,
+		SolvedQuestions:   solved.Solved,
+		Achievements:      achievements,
+		Setter:            setter,
+		Languages:         config.SupportedLanguages,
+		Timezones:         suggestedTimezones,
+	}
+	if viewerExists && viewerUserID == profileUser.ID {
+		if err := apiClient.Get(r, "/api/user/self/delete", &data.Deletion); err != nil {
+			log.Printf("Error fetching account deletion via API: %v", err)
+		}
+		if err := apiClient.Get(r, "/api/logins", &data.Logins); err != nil {
+			log.Printf("Error fetching logins via API: %v", err)
+		}
+	}
+	switch r.URL.Query().Get("success") {
+	case "preferences_saved":
+		data.SuccessMessage = "Your preferences were saved."
+	case "deletion_scheduled":
+		data.SuccessMessage = "Your account is scheduled for deletion."
+	case "deletion_cancelled":
+		data.SuccessMessage = "Your account will not be deleted."
+	}
+	if r.URL.Query().Get("error") == "wrong_password" {
+		data.ErrorMessage = "The password was incorrect, so your account was not scheduled for deletion."
+	}
+
+	// 5. Execute the template
+	err = templates.Render(w, r, "profile.html", data)
+	if err != nil {
+		log.Printf("Error executing profile template: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
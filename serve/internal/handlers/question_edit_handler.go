@@ -1,79 +1,107 @@
-package handler
-
-import (
-	"fmt"
-	"goera/serve/internal/utils"
-	"html/template"
-	"log"
-	"net/http"
-
-	"goera/serve/internal/auth"
-	"goera/serve/internal/models"
-
-	"github.com/gorilla/mux"
-)
-
-type QuestionEditData struct {
-	Question      models.Question
-	ErrorMessage  string
-	CurrentUserID uint
-}
-
-func QuestionEditHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	questionID := vars["id"]
-
-	// Get the current user ID from context
-	userID, exists := auth.UserIDFromContext(r.Context())
-	if !exists {
-		http.Redirect(w, r, "/login?error=unauthorized", http.StatusSeeOther)
-		return
-	}
-
-	// Get user details to check if admin
-	user, err := auth.GetUserFromContext(r.Context())
-	if err != nil {
-		log.Printf("Error getting user from context: %v", err)
-		http.Error(w, "Server error", http.StatusInternalServerError)
-		return
-	}
-
-	// Fetch the question from the API
-	apiPath := fmt.Sprintf("/api/questions/%s", questionID)
-	apiClient := utils.GetAPIClient()
-	var question models.Question
-	err = apiClient.Get(r, apiPath, &question)
-	if err != nil {
-		log.Printf("Error fetching question: %v", err)
-		http.Error(w, "Failed to fetch question", http.StatusInternalServerError)
-		return
-	}
-
-	// Check if user is authorized to edit the question
-	// User must be either an admin or the owner of the question
-	if user.Role != models.AdminRole && question.UserID != userID {
-		http.Error(w, "Unauthorized to edit this question", http.StatusForbidden)
-		return
-	}
-
-	// Prepare data for the template
-	data := QuestionEditData{
-		Question:      question,
-		CurrentUserID: userID,
-	}
-
-	// Parse and execute the template
-	tmpl, err := template.ParseFiles("web/templates/questionEditForm.html")
-	if err != nil {
-		log.Printf("Error parsing template: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	err = tmpl.Execute(w, data)
-	if err != nil {
-		log.Printf("Error executing template: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-}
+package handler
+
+import (
+	"fmt"
+	"goera/serve/internal/utils"
+	"log"
+	"net/http"
+	"time"
+
+	"goera/serve/internal/api"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/models"
+	"goera/serve/internal/templates"
+
+	"github.com/gorilla/mux"
+)
+
+type QuestionEditData struct {
+	Question      models.Question
+	ErrorMessage  string
+	CurrentUserID uint
+	DraftSavedAt  *time.Time // Set when the form was filled from an autosaved draft
+	TestCases     []models.TestCase
+
+	ReferenceSolution string
+}
+
+func QuestionEditHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	questionID := vars["id"]
+
+	// Get the current user ID from context
+	userID, exists := auth.UserIDFromContext(r.Context())
+	if !exists {
+		http.Redirect(w, r, "/login?error=unauthorized", http.StatusSeeOther)
+		return
+	}
+
+	// Get user details to check if admin
+	user, err := auth.GetUserFromContext(r.Context())
+	if err != nil {
+		log.Printf("Error getting user from context: %v", err)
+		http.Error(w, "Server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Fetch the question from the API
+	apiPath := fmt.Sprintf("/api/questions/%s", questionID)
+	apiClient := utils.GetAPIClient()
+	var question models.Question
+	err = apiClient.Get(r, apiPath, &question)
+	if err != nil {
+		log.Printf("Error fetching question: %v", err)
+		http.Error(w, "Failed to fetch question", http.StatusInternalServerError)
+		return
+	}
+
+	// Check if user is authorized to edit the question
+	// User must be an admin, the owner or a co-author of the question; only
+	// those can list its co-authors
+	if user.Role != models.AdminRole && question.UserID != userID {
+		var contributors api.QuestionContributorsResponse
+		if err := apiClient.Get(r, apiPath+"/contributors", &contributors); err != nil {
+			http.Error(w, "Unauthorized to edit this question", http.StatusForbidden)
+			return
+		}
+	}
+
+	// Prepare data for the template
+	data := QuestionEditData{
+		Question:      question,
+		CurrentUserID: userID,
+	}
+
+	// A question without test cases is answered with a 404
+	err = apiClient.Get(r, apiPath+"/testcase", &data.TestCases)
+	if err != nil && err.Error() != "API returned status 404" {
+		log.Printf("Error fetching test cases: %v", err)
+		http.Error(w, "Failed to fetch test cases", http.StatusInternalServerError)
+		return
+	}
+
+	// Restore an autosaved draft unless the question was saved after it
+	var draft models.QuestionDraft
+	err = apiClient.Get(r, apiPath+"/draft", &draft)
+	if err == nil && draft.UpdatedAt.After(question.UpdatedAt) {
+		data.Question.Title = draft.Title
+		data.Question.Content = draft.Content
+		data.Question.Tags = draft.Tags
+		data.DraftSavedAt = &draft.UpdatedAt
+	} else if err != nil && err.Error() != "API returned status 404" {
+		log.Printf("Error fetching question draft: %v", err)
+	}
+
+	var solution api.ReferenceSolutionResponse
+	if err := apiClient.Get(r, apiPath+"/reference-solution", &solution); err != nil {
+		log.Printf("Error fetching reference solution: %v", err)
+	}
+	data.ReferenceSolution = solution.ReferenceSolution
+
+	err = templates.Render(w, r, "questionEditForm.html", data)
+	if err != nil {
+		log.Printf("Error executing template: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -1,113 +1,225 @@
-package handler
-
-import (
-	"fmt"
-	"html/template"
-	"log"
-	"net/http"
-
-	"goera/serve/internal/auth"
-	"goera/serve/internal/models"
-	"goera/serve/internal/utils"
-
-	"github.com/gorilla/mux"
-	// "strconv"
-)
-
-type QuestionPageData struct {
-	Title          string
-	TimeLimit      int
-	MemoryLimit    int
-	Statement      string
-	IsAdmin        bool
-	IsPublished    bool
-	IsOwner        bool
-	QuestionID     uint
-	ErrorMessage   string
-	SuccessMessage string
-	ExampleInput   string
-	ExampleOutput  string
-	CurrentUserID  uint
-}
-
-func QuestionHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id := vars["id"]
-
-	apiPath := fmt.Sprintf("/api/questions/%s", id)
-	apiClient := utils.GetAPIClient()
-	var question models.Question
-	err := apiClient.Get(r, apiPath, &question)
-	if err != nil {
-		log.Printf("Error fetching questions: %v", err)
-		http.Error(w, "Failed to fetch questions", http.StatusInternalServerError)
-		return
-	}
-
-	apiPath2 := fmt.Sprintf("/api/questions/%s/testcase", id)
-	var testCases []models.TestCase
-	err = apiClient.Get(r, apiPath2, &testCases)
-	if err != nil {
-		log.Printf("Error fetching questions: %v", err)
-		http.Error(w, "Failed to fetch questions", http.StatusInternalServerError)
-		return
-	}
-
-	// Check for error parameters
-	errorParam := r.URL.Query().Get("error")
-	var errorMessage string = ""
-
-	switch errorParam {
-	case "already_published":
-		errorMessage = "This question is already published."
-	case "already_unpublished":
-		errorMessage = "This question is already unpublished."
-	}
-
-	// Check for success parameters
-	successParam := r.URL.Query().Get("success")
-	var successMessage string = ""
-
-	switch successParam {
-	case "published":
-		successMessage = "The question was successfully published."
-	case "unpublished":
-		successMessage = "The question was successfully unpublished."
-	}
-
-	data := QuestionPageData{
-		Title:          question.Title,
-		TimeLimit:      question.TimeLimit,
-		MemoryLimit:    question.MemoryLimit,
-		Statement:      question.Content,
-		IsAdmin:        false,
-		IsOwner:        false,
-		IsPublished:    question.Published,
-		QuestionID:     question.ID,
-		ErrorMessage:   errorMessage,
-		SuccessMessage: successMessage,
-		ExampleInput:   testCases[0].Input,
-		ExampleOutput:  testCases[0].ExpectedOutput,
-	}
-
-	userID, exists := auth.UserIDFromContext(r.Context())
-	if exists {
-		data.CurrentUserID = userID
-		user, err := auth.GetUserFromContext(r.Context())
-		if err == nil {
-			data.IsAdmin = user.Role == models.AdminRole
-		}
-		data.IsOwner = question.UserID == userID
-	}
-
-	funcMap := template.FuncMap{}
-
-	tmpl := template.Must(template.New("question.html").
-		Funcs(funcMap).ParseFiles("web/templates/question.html", "web/templates/base.html"))
-
-	err = tmpl.Execute(w, data)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-}
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"goera/serve/internal/api"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/config"
+	"goera/serve/internal/models"
+	"goera/serve/internal/templates"
+	"goera/serve/internal/utils"
+
+	"github.com/gorilla/mux"
+	// "strconv"
+)
+
+type QuestionPageData struct {
+	Title          string
+	TimeLimit      int
+	MemoryLimit    int
+	Statement      string
+	IsAdmin        bool
+	IsPublished    bool
+	IsOwner        bool
+	IsContributor  bool // Co-authors can do everything the owner can on the page
+	QuestionID     uint
+	ErrorMessage   string
+	SuccessMessage string
+	Examples       []models.Example
+	CurrentUserID  uint
+	IsAnonymous    bool
+	Clarifications []models.Clarification
+	Editorial      EditorialAPIResponse
+	// Languages are offered in the submission form, restricted by the question
+	Languages []string
+	// EditorialReleaseAt is the release date formatted for the editorial form
+	EditorialReleaseAt string
+	// Average of the users' difficulty votes
+	DifficultyRating float64
+	DifficultyVotes  int64
+	// Status is the question's place in the review workflow, and Reviews the
+	// status changes with their comments, shown to the author and admins
+	Status  models.QuestionStatus
+	Reviews []models.QuestionReview
+	// PublishChecklist is what publishing an unpublished question requires,
+	// shown to the author and admins
+	PublishChecklist []api.PublishCheck
+	// Attempts is how many submissions the question still accepts from the
+	// signed in user, nil when it sets no limits
+	Attempts *api.AttemptStatus
+}
+
+// publishCheckTitles names the publishing checks in error messages
+var publishCheckTitles = map[string]string{
+	api.PublishCheckHiddenTests:       "a hidden test case",
+	api.PublishCheckLimits:            "time and memory limits",
+	api.PublishCheckDifficulty:        "a difficulty",
+	api.PublishCheckReferenceSolution: "a passing reference solution",
+}
+
+// EditorialAPIResponse mirrors the response of /api/questions/{id}/editorial
+type EditorialAPIResponse struct {
+	Editorial  string     `json:"editorial"`
+	Hints      string     `json:"hints"`
+	Visibility string     `json:"visibility"`
+	ReleaseAt  *time.Time `json:"releaseAt"`
+	Available  bool       `json:"available"`
+}
+
+func QuestionHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	apiPath := fmt.Sprintf("/api/questions/%s", id)
+	apiClient := utils.GetAPIClient()
+	var question models.Question
+	err := apiClient.Get(r, apiPath, &question)
+	if err != nil {
+		log.Printf("Error fetching questions: %v", err)
+		http.Error(w, "Failed to fetch questions", http.StatusInternalServerError)
+		return
+	}
+
+	var clarifications []models.Clarification
+	err = apiClient.Get(r, fmt.Sprintf("/api/questions/%s/clarifications", id), &clarifications)
+	if err != nil {
+		// Clarifications are secondary content; render the question without them
+		log.Printf("Error fetching clarifications: %v", err)
+	}
+
+	var editorial EditorialAPIResponse
+	err = apiClient.Get(r, fmt.Sprintf("/api/questions/%s/editorial", id), &editorial)
+	if err != nil {
+		// Without the editorial the tab just shows as locked
+		log.Printf("Error fetching editorial: %v", err)
+	}
+
+	// Check for error parameters
+	errorParam := r.URL.Query().Get("error")
+	var errorMessage string = ""
+
+	switch errorParam {
+	case "already_published":
+		errorMessage = "This question is already published."
+	case "already_unpublished":
+		errorMessage = "This question is already unpublished."
+	case "already_draft":
+		errorMessage = "This question is already a draft."
+	case "already_in_review":
+		errorMessage = "This question is already waiting for review."
+	case "already_changes_requested":
+		errorMessage = "Changes were already requested for this question."
+	case "reference_solution_failed":
+		errorMessage = "The question was not published because its reference solution fails on the test cases."
+	case "reference_solution_unchecked":
+		errorMessage = "The question was not published because the judge could not check its reference solution."
+	case "publish_checklist":
+		var missing []string
+		for _, name := range strings.Split(r.URL.Query().Get("failed"), ",") {
+			if title, ok := publishCheckTitles[name]; ok {
+				missing = append(missing, title)
+			}
+		}
+		errorMessage = "The question was not published because it is missing " + strings.Join(missing, ", ") + "."
+	}
+
+	// Check for success parameters
+	successParam := r.URL.Query().Get("success")
+	var successMessage string = ""
+
+	switch successParam {
+	case "published":
+		successMessage = "The question was successfully published."
+	case "unpublished":
+		successMessage = "The question was successfully unpublished."
+	case "draft":
+		successMessage = "The question was moved back to draft."
+	case "in_review":
+		successMessage = "The question was submitted for review."
+	case "changes_requested":
+		successMessage = "Changes were requested from the author."
+	case "clarification_asked":
+		successMessage = "Your clarification request was sent."
+	case "clarification_answered":
+		successMessage = "The clarification was answered."
+	case "editorial_updated":
+		successMessage = "The editorial was updated."
+	}
+
+	data := QuestionPageData{
+		Title:          question.Title,
+		TimeLimit:      question.TimeLimit,
+		MemoryLimit:    question.MemoryLimit,
+		Statement:      question.Content,
+		IsAdmin:        false,
+		IsOwner:        false,
+		IsPublished:    question.Published,
+		QuestionID:     question.ID,
+		ErrorMessage:   errorMessage,
+		SuccessMessage: successMessage,
+		Examples:       question.Examples,
+		Clarifications: clarifications,
+		Editorial:      editorial,
+		Languages:      question.Languages(),
+
+		DifficultyRating: question.DifficultyRating,
+		DifficultyVotes:  question.DifficultyVotes,
+		Status:           question.Status,
+	}
+
+	if data.Languages == nil {
+		data.Languages = config.SupportedLanguages
+	}
+
+	if editorial.ReleaseAt != nil {
+		data.EditorialReleaseAt = editorial.ReleaseAt.Format("2006-01-02")
+	}
+
+	userID, exists := auth.UserIDFromContext(r.Context())
+	if exists {
+		data.CurrentUserID = userID
+		user, err := auth.GetUserFromContext(r.Context())
+		if err == nil {
+			data.IsAdmin = user.Role == models.AdminRole
+		}
+		data.IsOwner = question.UserID == userID
+		if !data.IsOwner && !data.IsAdmin {
+			// Only those who can edit the question may list its co-authors
+			var contributors api.QuestionContributorsResponse
+			data.IsContributor = apiClient.Get(r, fmt.Sprintf("/api/questions/%s/contributors", id), &contributors) == nil
+		}
+		if !data.IsOwner && !data.IsContributor && (question.MaxAttempts > 0 || question.AttemptCooldown > 0) {
+			var attempts api.AttemptStatus
+			if err := apiClient.Get(r, fmt.Sprintf("/api/questions/%s/attempts", id), &attempts); err != nil {
+				log.Printf("Error fetching attempts: %v", err)
+			} else {
+				data.Attempts = &attempts
+			}
+		}
+		if data.IsAdmin || data.IsOwner || data.IsContributor {
+			err = apiClient.Get(r, fmt.Sprintf("/api/questions/%s/reviews", id), &data.Reviews)
+			if err != nil {
+				log.Printf("Error fetching reviews: %v", err)
+			}
+			if !question.Published {
+				err = apiClient.Get(r, fmt.Sprintf("/api/questions/%s/publish-checklist", id), &data.PublishChecklist)
+				if err != nil {
+					log.Printf("Error fetching publish checklist: %v", err)
+				}
+			}
+		}
+	} else {
+		_, data.IsAnonymous = auth.AnonymousSessionFromContext(r.Context())
+	}
+
+	err = templates.Render(w, r, "question.html", data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"goera/serve/internal/api"
+	"goera/serve/internal/models"
+	"goera/serve/internal/templates"
+	"goera/serve/internal/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// QuestionPrintHandler shows the statement of a question laid out for paper,
+// without the page around it
+func QuestionPrintHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var question models.Question
+	err := utils.GetAPIClient().Get(r, fmt.Sprintf("/api/questions/%s", id), &question)
+	if err != nil {
+		log.Printf("Error fetching question: %v", err)
+		http.Error(w, "Failed to fetch question", http.StatusInternalServerError)
+		return
+	}
+
+	err = templates.Render(w, r, "questionPrint.html", api.NewPrintableQuestion(&question))
+	if err != nil {
+		log.Printf("Error rendering template: %v", err)
+		http.Error(w, "Failed to render page", http.StatusInternalServerError)
+	}
+}
@@ -1,81 +1,129 @@
-package handler
-
-import (
-	"fmt"
-	"html/template"
-	"log"
-	"net/http"
-	"strconv"
-
-	"goera/serve/internal/auth"
-	"goera/serve/internal/models"
-	"goera/serve/internal/utils"
-)
-
-type QuestionsData struct {
-	Questions     []models.Question
-	Page          int
-	PageSize      int
-	TotalItems    int64
-	TotalPages    int
-	CurrentUserID uint
-}
-
-type APIResponse struct {
-	Data       []models.Question `json:"data"`
-	Page       int               `json:"page"`
-	PageSize   int               `json:"page_size"`
-	TotalItems int64             `json:"total_items"`
-	TotalPages int               `json:"total_pages"`
-}
-
-func QuestionsHandler(w http.ResponseWriter, r *http.Request) {
-	pageStr := r.URL.Query().Get("page")
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page < 1 {
-		page = 1
-	}
-
-	apiPath := fmt.Sprintf("/api/questions?page=%d", page)
-	apiClient := utils.GetAPIClient()
-	var apiResponse APIResponse
-	err = apiClient.Get(r, apiPath, &apiResponse)
-	if err != nil {
-		log.Printf("Error fetching questions: %v", err)
-		http.Error(w, "Failed to fetch questions", http.StatusInternalServerError)
-		return
-	}
-
-	// Get current user ID for the profile link
-	currentUserID, _ := auth.UserIDFromContext(r.Context()) // Ignore error, default to 0 if not found
-
-	data := QuestionsData{
-		Questions:     apiResponse.Data,
-		Page:          apiResponse.Page,
-		PageSize:      apiResponse.PageSize,
-		TotalItems:    apiResponse.TotalItems,
-		TotalPages:    apiResponse.TotalPages,
-		CurrentUserID: currentUserID, // Populate the new field
-	}
-	// fmt.Println(currentUserID)
-	funcMap := template.FuncMap{
-		"sub": func(a, b int) int { return a - b },
-		"add": func(a, b int) int { return a + b },
-	}
-
-	// Create a new template, add functions, then parse the file
-	tmpl, err := template.New("questions.html").Funcs(funcMap).ParseFiles("web/templates/questions.html")
-	if err != nil {
-		log.Printf("Error parsing questions template: %v", err)
-		http.Error(w, "Internal server error (template parse)", http.StatusInternalServerError)
-		return
-	}
-
-	// Execute the template
-	err = tmpl.ExecuteTemplate(w, "questions.html", data) // Execute by the name provided in New()
-	if err != nil {
-		log.Printf("Error executing questions template: %v", err)
-		// http.Error(w, err.Error(), http.StatusInternalServerError) // Avoid potentially writing headers twice
-		return
-	}
-}
+package handler
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"goera/serve/internal/auth"
+	"goera/serve/internal/models"
+	"goera/serve/internal/templates"
+	"goera/serve/internal/utils"
+)
+
+type QuestionsData struct {
+	Questions     []models.Question
+	Page          int
+	PageSize      int
+	TotalItems    int64
+	TotalPages    int
+	CurrentUserID uint
+	IsAnonymous   bool
+	// Sort, rating and difficulty filters, passed through to the API and the
+	// page links
+	Sort       string
+	MinRating  string
+	MaxRating  string
+	Difficulty string
+	// Difficulties offered by the difficulty filter
+	Difficulties []models.Difficulty
+	// Query is the search the questions were found by, listed by relevance
+	// with a highlighted excerpt of their statement in Snippets
+	Query    string
+	Snippets map[uint]template.HTML
+}
+
+type APIResponse struct {
+	Data       []models.Question `json:"data"`
+	Page       int               `json:"page"`
+	PageSize   int               `json:"page_size"`
+	TotalItems int64             `json:"total_items"`
+	TotalPages int               `json:"total_pages"`
+}
+
+// searchResponse is a page of /api/questions/search
+type searchResponse struct {
+	Data []struct {
+		models.Question
+		Snippet string `json:"snippet"`
+	} `json:"data"`
+	Page       int   `json:"page"`
+	PageSize   int   `json:"page_size"`
+	TotalItems int64 `json:"total_items"`
+	TotalPages int   `json:"total_pages"`
+}
+
+func QuestionsHandler(w http.ResponseWriter, r *http.Request) {
+	pageStr := r.URL.Query().Get("page")
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	query := url.Values{}
+	query.Set("page", strconv.Itoa(page))
+	search := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	apiClient := utils.GetAPIClient()
+	var apiResponse APIResponse
+	var snippets map[uint]template.HTML
+	if search != "" {
+		// Search results come by relevance, so sorting and filters do not apply
+		query.Set("q", search)
+		var results searchResponse
+		err = apiClient.Get(r, "/api/questions/search?"+query.Encode(), &results)
+		apiResponse = APIResponse{Page: results.Page, PageSize: results.PageSize, TotalItems: results.TotalItems, TotalPages: results.TotalPages}
+		snippets = make(map[uint]template.HTML, len(results.Data))
+		for _, result := range results.Data {
+			apiResponse.Data = append(apiResponse.Data, result.Question)
+			// The API escapes the statement and only adds <mark> elements
+			snippets[result.ID] = template.HTML(result.Snippet)
+		}
+	} else {
+		for _, key := range []string{"sort", "minRating", "maxRating", "difficulty"} {
+			if value := r.URL.Query().Get(key); value != "" {
+				query.Set(key, value)
+			}
+		}
+		err = apiClient.Get(r, "/api/questions?"+query.Encode(), &apiResponse)
+	}
+	if err != nil {
+		log.Printf("Error fetching questions: %v", err)
+		http.Error(w, "Failed to fetch questions", http.StatusInternalServerError)
+		return
+	}
+
+	// Get current user ID for the profile link
+	currentUserID, _ := auth.UserIDFromContext(r.Context()) // Ignore error, default to 0 if not found
+
+	_, isAnonymous := auth.AnonymousSessionFromContext(r.Context())
+
+	data := QuestionsData{
+		Questions:     apiResponse.Data,
+		Page:          apiResponse.Page,
+		PageSize:      apiResponse.PageSize,
+		TotalItems:    apiResponse.TotalItems,
+		TotalPages:    apiResponse.TotalPages,
+		CurrentUserID: currentUserID, // Populate the new field
+		IsAnonymous:   isAnonymous,
+		Sort:          r.URL.Query().Get("sort"),
+		MinRating:     r.URL.Query().Get("minRating"),
+		MaxRating:     r.URL.Query().Get("maxRating"),
+		Difficulty:    r.URL.Query().Get("difficulty"),
+		Difficulties:  models.Difficulties,
+		Query:         search,
+		Snippets:      snippets,
+	}
+	// fmt.Println(currentUserID)
+
+	// Execute the template
+	err = templates.Render(w, r, "questions.html", data)
+	if err != nil {
+		log.Printf("Error executing questions template: %v", err)
+		// http.Error(w, err.Error(), http.StatusInternalServerError) // Avoid potentially writing headers twice
+		return
+	}
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"goera/serve/internal/api"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/models"
+	"goera/serve/internal/templates"
+	"goera/serve/internal/utils"
+
+	"github.com/gorilla/mux"
+)
+
+// SubmissionDetailData holds the data needed for the submission detail template
+type SubmissionDetailData struct {
+	Submission    models.Submission
+	CurrentUserID uint
+	// TimeLimit and MemoryLimit are the question's limits, zero if it could not be loaded
+	TimeLimit   int
+	MemoryLimit int
+	// Diff compares the code with the previous attempt, or the submission in
+	// ?against=; nil for a first attempt
+	Diff *api.SubmissionDiff
+}
+
+// SubmissionDetailHandler shows a single submission with its code, verdict,
+// per-test results and resource usage
+func SubmissionDetailHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	apiClient := utils.GetAPIClient()
+	var submission models.Submission
+	err := apiClient.Get(r, fmt.Sprintf("/api/submissions/%s", id), &submission)
+	if err != nil {
+		switch err.Error() {
+		case "API returned status 404":
+			http.NotFound(w, r)
+		case "API returned status 403":
+			http.Error(w, "You can only view your own submissions", http.StatusForbidden)
+		default:
+			log.Printf("Error fetching submission: %v", err)
+			http.Error(w, "Failed to fetch submission", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	currentUserID, _ := auth.UserIDFromContext(r.Context())
+
+	data := SubmissionDetailData{
+		Submission:    submission,
+		CurrentUserID: currentUserID,
+	}
+
+	var question models.Question
+	if err := apiClient.Get(r, fmt.Sprintf("/api/questions/%d", submission.QuestionID), &question); err != nil {
+		// The limits only put the usage in context; show the submission without them
+		log.Printf("Error fetching question of submission %s: %v", id, err)
+	} else {
+		data.TimeLimit = question.TimeLimit
+		data.MemoryLimit = question.MemoryLimit
+	}
+
+	diffPath := fmt.Sprintf("/api/submissions/%s/diff", id)
+	if against := r.URL.Query().Get("against"); against != "" {
+		diffPath += "?against=" + url.QueryEscape(against)
+	}
+	var diff api.SubmissionDiff
+	if err := apiClient.Get(r, diffPath, &diff); err == nil {
+		data.Diff = &diff
+	} else if err.Error() != "API returned status 404" {
+		log.Printf("Error fetching diff of submission %s: %v", id, err)
+	}
+
+	err = templates.Render(w, r, "submissionDetail.html", data)
+	if err != nil {
+		log.Printf("Error executing submission detail template: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -1,31 +1,70 @@
-package handler
-
-import (
-	"html/template"
-	"net/http"
-
-	"goera/serve/internal/auth"
-)
-
-func WelcomeHandler(w http.ResponseWriter, r *http.Request) {
-	cookie, err := r.Cookie("token")
-	if err == nil && cookie.Value != "" {
-		claims, err := auth.ValidateJWT(cookie.Value)
-		if err == nil && claims.UserID > 0 {
-			http.Redirect(w, r, "/questions", http.StatusSeeOther)
-			return
-		}
-	}
-
-	tmpl, err := template.ParseFiles("web/templates/index.html")
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	err = tmpl.Execute(w, nil)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-}
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"goera/serve/internal/api"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/models"
+	"goera/serve/internal/templates"
+	"goera/serve/internal/utils"
+)
+
+// WelcomeData holds the data needed for the homepage template
+type WelcomeData struct {
+	Feed           api.Feed
+	SignedIn       bool
+	IsAdmin        bool
+	CurrentUserID  uint
+	SuccessMessage string
+}
+
+// WelcomeHandler shows the homepage dashboard. Visitors see the recent
+// problems and announcements; signed in users also see their latest verdicts.
+func WelcomeHandler(w http.ResponseWriter, r *http.Request) {
+	currentUserID, signedIn := auth.UserIDFromContext(r.Context())
+
+	apiClient := utils.GetAPIClient()
+	var feed api.Feed
+	if err := apiClient.Get(r, "/api/feed", &feed); err != nil {
+		log.Printf("Error fetching feed: %v", err)
+		http.Error(w, "Failed to fetch feed", http.StatusInternalServerError)
+		return
+	}
+
+	isAdmin := false
+	if signedIn {
+		user, err := auth.GetUserFromContext(r.Context())
+		if err != nil {
+			log.Printf("Error getting user from context: %v", err)
+		} else {
+			isAdmin = user.Role == models.AdminRole
+		}
+	}
+
+	var successMessage string
+	switch r.URL.Query().Get("success") {
+	case "announcement_posted":
+		successMessage = "The announcement was posted."
+	case "announcement_updated":
+		successMessage = "The announcement was updated."
+	case "announcement_deleted":
+		successMessage = "The announcement was deleted."
+	}
+
+	data := WelcomeData{
+		Feed:           feed,
+		SignedIn:       signedIn,
+		IsAdmin:        isAdmin,
+		CurrentUserID:  currentUserID,
+		SuccessMessage: successMessage,
+	}
+
+	err := templates.Render(w, r, "index.html", data)
+	if err != nil {
+		log.Printf("Error executing index template: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,113 @@
+// Package i18n translates the messages shown to users, in templates and API
+// errors. Messages are looked up by their English text, so untranslated
+// messages are shown in English.
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Default is the locale used when the request does not ask for a supported one
+const Default = "en"
+
+// rtlLocales are the supported locales written right to left
+var rtlLocales = map[string]bool{"fa": true}
+
+// names are the names of the supported locales in their own language, for
+// the language switch
+var names = map[string]string{
+	"en": "English",
+	"fa": "فارسی",
+}
+
+//go:embed locales/*.json
+var bundleFiles embed.FS
+
+// bundles maps each supported locale to its translations, keyed by the English message
+var bundles = loadBundles()
+
+// loadBundles reads the embedded locales/<locale>.json files
+func loadBundles() map[string]map[string]string {
+	entries, err := bundleFiles.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	loaded := make(map[string]map[string]string, len(entries))
+	for _, entry := range entries {
+		data, err := bundleFiles.ReadFile(path.Join("locales", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+		var bundle map[string]string
+		if err := json.Unmarshal(data, &bundle); err != nil {
+			panic(fmt.Sprintf("invalid translation bundle %s: %v", entry.Name(), err))
+		}
+		loaded[strings.TrimSuffix(entry.Name(), ".json")] = bundle
+	}
+	return loaded
+}
+
+// Locales returns the supported locales in alphabetical order
+func Locales() []string {
+	locales := make([]string, 0, len(bundles))
+	for locale := range bundles {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// IsSupported reports whether there is a bundle for locale
+func IsSupported(locale string) bool {
+	_, ok := bundles[locale]
+	return ok
+}
+
+// T translates message into locale and, with args, formats it like
+// fmt.Sprintf. Messages missing from the bundle are kept in English.
+func T(locale, message string, args ...interface{}) string {
+	if translated, ok := bundles[locale][message]; ok && translated != "" {
+		message = translated
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(message, args...)
+	}
+	return message
+}
+
+// Name returns the name of locale in its own language
+func Name(locale string) string {
+	if name, ok := names[locale]; ok {
+		return name
+	}
+	return locale
+}
+
+// Dir returns the text direction of locale, rtl or ltr, for the dir attribute
+func Dir(locale string) string {
+	if rtlLocales[locale] {
+		return "rtl"
+	}
+	return "ltr"
+}
+
+type contextKey struct{}
+
+// WithLocale returns a copy of ctx carrying locale
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, contextKey{}, locale)
+}
+
+// FromContext returns the locale of the request, or Default
+func FromContext(ctx context.Context) string {
+	if locale, ok := ctx.Value(contextKey{}).(string); ok {
+		return locale
+	}
+	return Default
+}
@@ -0,0 +1,79 @@
+package i18n
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CookieName is the cookie remembering the locale a user picked
+const CookieName = "lang"
+
+// Middleware detects the locale of each request and stores it in the
+// context. A lang query parameter picks a locale and remembers it in a
+// cookie; otherwise the cookie and then the Accept-Language header are used.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locale := detectLocale(r)
+		if lang := r.URL.Query().Get("lang"); IsSupported(lang) {
+			locale = lang
+			http.SetCookie(w, &http.Cookie{
+				Name:     CookieName,
+				Value:    lang,
+				Path:     "/",
+				Expires:  time.Now().AddDate(1, 0, 0),
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+
+		w.Header().Set("Content-Language", locale)
+		w.Header().Add("Vary", "Accept-Language")
+		next.ServeHTTP(w, r.WithContext(WithLocale(r.Context(), locale)))
+	})
+}
+
+// Chosen reports whether the request picks its locale with the lang
+// parameter or cookie, rather than leaving it to the browser
+func Chosen(r *http.Request) bool {
+	if IsSupported(r.URL.Query().Get("lang")) {
+		return true
+	}
+	cookie, err := r.Cookie(CookieName)
+	return err == nil && IsSupported(cookie.Value)
+}
+
+// detectLocale returns the locale from the cookie or the Accept-Language
+// header, or Default
+func detectLocale(r *http.Request) string {
+	if cookie, err := r.Cookie(CookieName); err == nil && IsSupported(cookie.Value) {
+		return cookie.Value
+	}
+	if locale := parseAcceptLanguage(r.Header.Get("Accept-Language")); locale != "" {
+		return locale
+	}
+	return Default
+}
+
+// parseAcceptLanguage returns the supported locale the header prefers most,
+// or "" if it names none. Regional variants such as fa-IR match their language.
+func parseAcceptLanguage(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		language, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if IsSupported(language) && q > bestQ {
+			best, bestQ = language, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,43 @@
+// Package mail sends plain text emails to users, such as account unlock links.
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"time"
+
+	"goera/serve/internal/config"
+)
+
+// Send emails body to the address to. Without an SMTP server configured the
+// email is written to the log, so links in it can still be followed during
+// development.
+func Send(to, subject, body string) error {
+	if config.SMTPAddr == "" {
+		log.Printf("Mail to %s (no SMTP server configured): %s\n%s", to, subject, body)
+		return nil
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", config.SMTPFrom)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(body)
+
+	var auth smtp.Auth
+	if config.SMTPUsername != "" {
+		host, _, _ := net.SplitHostPort(config.SMTPAddr)
+		auth = smtp.PlainAuth("", config.SMTPUsername, config.SMTPPassword, host)
+	}
+	if err := smtp.SendMail(config.SMTPAddr, auth, config.SMTPFrom, []string{to}, msg.Bytes()); err != nil {
+		return fmt.Errorf("failed to send mail to %s: %w", to, err)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+// Package maintenance takes serve's user facing routes offline while it is
+// being maintained. Users get a 503 instead, while admins can still check the
+// deployment and the judge keeps reporting verdicts over its own listener, so
+// submissions already being judged are not lost.
+package maintenance
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/config"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+	"goera/serve/internal/storage"
+	"goera/serve/internal/templates"
+
+	"gorm.io/gorm"
+)
+
+// refreshInterval is how long an instance relies on the setting it last read,
+// so a change through the API reaches every instance within it
+const refreshInterval = 5 * time.Second
+
+// State is whether maintenance mode is on and what users are told
+type State struct {
+	Enabled   bool       `json:"enabled"`
+	Message   string     `json:"message"`
+	Forced    bool       `json:"forced"` // Kept on by MAINTENANCE_MODE, which the API cannot turn off
+	UpdatedBy *uint      `json:"updatedBy,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
+
+var (
+	mu       sync.Mutex
+	setting  models.Maintenance
+	loadedAt time.Time
+)
+
+// Current returns the maintenance state. The setting made through the API is
+// read from the database at most once per refreshInterval.
+func Current() State {
+	mu.Lock()
+	defer mu.Unlock()
+	if time.Since(loadedAt) >= refreshInterval {
+		if db := database.GetDB(); db != nil {
+			var m models.Maintenance
+			if err := db.Where("id = ?", 1).Limit(1).Find(&m).Error; err != nil {
+				log.Printf("Failed to read maintenance mode: %v", err)
+			} else {
+				setting = m
+			}
+		}
+		loadedAt = time.Now()
+	}
+	return stateOf(setting)
+}
+
+// Set turns maintenance mode on or off on behalf of the admin userID. An
+// empty message shows the configured one.
+func Set(db *gorm.DB, enabled bool, message string, userID uint) (State, error) {
+	m := models.Maintenance{ID: 1, Enabled: enabled, Message: message, UpdatedBy: &userID}
+	if err := db.Save(&m).Error; err != nil {
+		return State{}, err
+	}
+	mu.Lock()
+	setting = m
+	loadedAt = time.Now()
+	mu.Unlock()
+	return stateOf(m), nil
+}
+
+func stateOf(m models.Maintenance) State {
+	state := State{
+		Enabled: m.Enabled || config.MaintenanceMode,
+		Message: config.MaintenanceMessage,
+		Forced:  config.MaintenanceMode,
+	}
+	if m.Message != "" {
+		state.Message = m.Message
+	}
+	if m.ID != 0 {
+		updatedAt := m.UpdatedAt
+		state.UpdatedBy = m.UpdatedBy
+		state.UpdatedAt = &updatedAt
+	}
+	return state
+}
+
+// exempt reports whether a path keeps working during maintenance: signing
+// in, so admins can get in, and what the judge, code-runners and monitoring
+// fetch
+func exempt(path string) bool {
+	for _, prefix := range []string{config.StaticRouter, storage.URLPrefix, "/auth/"} {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	switch path {
+	case "/login", "/api/login", "/api/logout", "/metrics", "/.well-known/jwks.json":
+		return true
+	}
+	return false
+}
+
+// Middleware answers requests with a 503 while maintenance mode is on, a JSON
+// error for /api routes and the maintenance page for the others. Admins and
+// exempt paths get through.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := Current()
+		if !state.Enabled || exempt(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if user, err := auth.GetUserFromContext(r.Context()); err == nil && user.Role == models.AdminRole {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if strings.HasPrefix(r.URL.Path, "/api") {
+			apierror.Write(w, r, state.Message, http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		if err := templates.Render(w, r, "maintenance.html", state); err != nil {
+			log.Printf("Error executing maintenance template: %v", err)
+		}
+	})
+}
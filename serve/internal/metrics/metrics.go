@@ -0,0 +1,85 @@
+// Package metrics keeps counters of events worth watching, such as failed
+// logins, and serves them at /metrics in the Prometheus text format.
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Counter counts events by the values of its labels
+type Counter struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]uint64 // By label values joined with \xff
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []*Counter
+)
+
+// NewCounter registers a counter with the given label names
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{name: name, help: help, labels: labels, values: map[string]uint64{}}
+	registryMu.Lock()
+	registry = append(registry, c)
+	registryMu.Unlock()
+	return c
+}
+
+// Inc counts one event with the given label values, one per label
+func (c *Counter) Inc(labelValues ...string) {
+	if len(labelValues) != len(c.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", c.name, len(c.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	c.mu.Lock()
+	c.values[key]++
+	c.mu.Unlock()
+}
+
+// write appends the counter in the Prometheus text format
+func (c *Counter) write(b *strings.Builder) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+
+	c.mu.Lock()
+	keys := make([]string, 0, len(c.values))
+	for key := range c.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		b.WriteString(c.name)
+		if len(c.labels) > 0 {
+			values := strings.Split(key, "\xff")
+			pairs := make([]string, len(c.labels))
+			for i, label := range c.labels {
+				pairs[i] = fmt.Sprintf("%s=%q", label, values[i])
+			}
+			b.WriteString("{" + strings.Join(pairs, ",") + "}")
+		}
+		fmt.Fprintf(b, " %d\n", c.values[key])
+	}
+	c.mu.Unlock()
+}
+
+// Handler serves every registered counter. Counters only live in this
+// process, so each serve instance is scraped on its own.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	var b strings.Builder
+	registryMu.Lock()
+	for _, c := range registry {
+		c.write(&b)
+	}
+	registryMu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Badge is a kind of achievement
+type Badge string
+
+const (
+	BadgeFirstSolve Badge = "first_solve" // First to solve a question, other than its authors
+	BadgeSolved100  Badge = "solved_100"  // Solved 100 questions
+	BadgeStreak30   Badge = "streak_30"   // Solved a question on 30 days in a row
+)
+
+// Achievement is a badge a user earned. Badges are awarded once, a first
+// solve once per question.
+type Achievement struct {
+	ID           uint      `json:"id" gorm:"primarykey"`
+	CreatedAt    time.Time `json:"awardedAt"`
+	UserID       uint      `json:"userId" gorm:"uniqueIndex:idx_achievement"`
+	Badge        Badge     `json:"badge" gorm:"size:32;uniqueIndex:idx_achievement"`
+	QuestionID   uint      `json:"questionId,omitempty" gorm:"uniqueIndex:idx_achievement"` // The question of a first solve, 0 for other badges
+	SubmissionID uint      `json:"submissionId"`                                            // The accepted submission that earned the badge
+}
+
+func MigrateAchievement(db *gorm.DB) error {
+	err := db.AutoMigrate(&Achievement{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AnnouncementLevel is how urgent an announcement is, which sets the color
+// of its banner
+type AnnouncementLevel string
+
+const (
+	AnnouncementInfo     AnnouncementLevel = "info"
+	AnnouncementWarning  AnnouncementLevel = "warning"
+	AnnouncementCritical AnnouncementLevel = "critical"
+)
+
+// Announcement is a site-wide message from the admins shown on the homepage.
+// Banner announcements are also shown at the top of every page while they
+// are up, e.g. during a contest or a maintenance window.
+type Announcement struct {
+	gorm.Model
+	Title     string            `json:"title"`
+	Body      string            `json:"body"`   // Markdown
+	UserID    uint              `json:"userId"` // Admin who posted the announcement
+	User      User              `json:"-" gorm:"foreignKey:UserID"`
+	Pinned    bool              `json:"pinned"`                               // Pinned announcements are listed first
+	Level     AnnouncementLevel `json:"level" gorm:"not null;default:info"`   // info, warning or critical
+	Banner    bool              `json:"banner" gorm:"not null;default:false"` // Shown on every page, not only the homepage
+	StartsAt  *time.Time        `json:"startsAt" gorm:"index"`                // Not shown before this time (null to show it right away)
+	ExpiresAt *time.Time        `json:"expiresAt" gorm:"index"`               // No longer shown after this date (null to keep it)
+}
+
+// Active reports whether the announcement is shown at now
+func (a *Announcement) Active(now time.Time) bool {
+	return (a.StartsAt == nil || !a.StartsAt.After(now)) && (a.ExpiresAt == nil || a.ExpiresAt.After(now))
+}
+
+func MigrateAnnouncement(db *gorm.DB) error {
+	err := db.AutoMigrate(&Announcement{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
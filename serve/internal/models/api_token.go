@@ -0,0 +1,62 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TokenScope limits what a personal access token may be used for
+type TokenScope string
+
+const (
+	ScopeRead   TokenScope = "read"   // Read-only API requests
+	ScopeSubmit TokenScope = "submit" // Running code and creating submissions
+)
+
+// IsValid reports whether s is a known scope
+func (s TokenScope) IsValid() bool {
+	return s == ScopeRead || s == ScopeSubmit
+}
+
+// APIToken is a long-lived personal access token. Only the SHA-256 hash of the
+// token is stored; the plaintext is shown once when the token is created.
+type APIToken struct {
+	gorm.Model
+	UserID     uint       `json:"userId" gorm:"index"`
+	User       User       `json:"-" gorm:"foreignKey:UserID"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // Leading characters of the token, to tell tokens apart
+	TokenHash  string     `json:"-" gorm:"uniqueIndex"`
+	Scopes     string     `json:"scopes"` // Comma separated list of scopes
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	RevokedAt  *time.Time `json:"revokedAt"`
+}
+
+// HasScope reports whether the token was granted scope
+func (t *APIToken) HasScope(scope TokenScope) bool {
+	for _, s := range strings.Split(t.Scopes, ",") {
+		if TokenScope(s) == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Active reports whether the token can still be used to authenticate
+func (t *APIToken) Active(now time.Time) bool {
+	if t.RevokedAt != nil {
+		return false
+	}
+	return t.ExpiresAt == nil || now.Before(*t.ExpiresAt)
+}
+
+func MigrateAPIToken(db *gorm.DB) error {
+	err := db.AutoMigrate(&APIToken{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APIUsage counts the API calls of one kind a user made on one UTC day, kept
+// apart for each personal access token they made them with
+type APIUsage struct {
+	ID        uint      `json:"-" gorm:"primarykey"`
+	UserID    uint      `json:"userId" gorm:"uniqueIndex:idx_api_usage"`
+	TokenID   uint      `json:"tokenId" gorm:"uniqueIndex:idx_api_usage"`      // 0 for calls made with a login session
+	Day       string    `json:"day" gorm:"uniqueIndex:idx_api_usage;size:10"`  // As 2006-01-02
+	Kind      string    `json:"kind" gorm:"uniqueIndex:idx_api_usage;size:32"` // A key of config.DailyQuotas, or "other"
+	Calls     int64     `json:"calls"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// UserQuota replaces the daily quota of one kind for one user. A zero limit
+// means no limit.
+type UserQuota struct {
+	gorm.Model
+	UserID     uint   `json:"userId" gorm:"uniqueIndex:idx_user_quota"`
+	Kind       string `json:"kind" gorm:"uniqueIndex:idx_user_quota;size:32"`
+	DailyLimit int    `json:"dailyLimit"`
+	UpdatedBy  uint   `json:"updatedBy"`
+}
+
+func MigrateAPIUsage(db *gorm.DB) error {
+	err := db.AutoMigrate(&APIUsage{}, &UserQuota{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
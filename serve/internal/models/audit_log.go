@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AuditAction identifies an administrative action recorded in the audit log
+type AuditAction string
+
+const (
+	AuditUserPromoted        AuditAction = "user_promoted"        // A user was made an admin
+	AuditQuestionPublished   AuditAction = "question_published"   // A question was published
+	AuditQuestionUnpublished AuditAction = "question_unpublished" // A question was taken offline
+	AuditQuestionDeleted     AuditAction = "question_deleted"     // A question was moved to the trash
+	AuditQuestionRestored    AuditAction = "question_restored"    // A question was restored from the trash
+	AuditQuestionRolledBack  AuditAction = "question_rolled_back" // A question was rolled back to an earlier revision
+	AuditQuestionTransferred AuditAction = "question_transferred" // A question was handed over to another owner
+	AuditContributorAdded    AuditAction = "contributor_added"    // A user was made a co-author of a question
+	AuditContributorRemoved  AuditAction = "contributor_removed"  // A co-author was removed from a question
+	AuditGroupDeleted        AuditAction = "group_deleted"        // A group was deleted
+	AuditContestUnfrozen     AuditAction = "contest_unfrozen"     // A contest's frozen scoreboard was revealed
+	AuditContestPublished    AuditAction = "contest_published"    // An ended contest's problems were published
+	AuditDeadLetterReplayed  AuditAction = "dead_letter_replayed" // An undelivered verdict was delivered again
+	AuditAnnouncementPosted  AuditAction = "announcement_posted"  // An announcement was posted
+	AuditAnnouncementEdited  AuditAction = "announcement_edited"  // An announcement was changed
+	AuditAnnouncementDeleted AuditAction = "announcement_deleted" // An announcement was deleted
+	AuditMaintenanceStarted  AuditAction = "maintenance_started"  // Maintenance mode was turned on
+	AuditMaintenanceEnded    AuditAction = "maintenance_ended"    // Maintenance mode was turned off
+	AuditQuotaChanged        AuditAction = "quota_changed"        // The daily API quotas of a user were changed
+	AuditAccountAnonymized   AuditAction = "account_anonymized"   // A deleted account was anonymized after its grace period
+)
+
+// AuditLog records who performed an administrative action on what. Entries
+// are append-only: they have no update or delete time and nothing changes them.
+type AuditLog struct {
+	ID         uint        `json:"id" gorm:"primarykey"`
+	CreatedAt  time.Time   `json:"createdAt" gorm:"index"`
+	ActorID    uint        `json:"actorId" gorm:"index"`
+	Action     AuditAction `json:"action" gorm:"index"`
+	TargetType string      `json:"targetType" gorm:"index:idx_audit_target"` // e.g. question, user
+	TargetID   string      `json:"targetId" gorm:"index:idx_audit_target"`
+	Details    string      `json:"details"` // Human readable summary
+}
+
+func MigrateAuditLog(db *gorm.DB) error {
+	err := db.AutoMigrate(&AuditLog{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
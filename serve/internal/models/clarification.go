@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Clarification is a user's question about a problem statement and its answer
+type Clarification struct {
+	gorm.Model
+	QuestionID uint       `json:"questionId" gorm:"index"` // Problem the clarification is about
+	Question   Question   `json:"-" gorm:"foreignKey:QuestionID"`
+	UserID     uint       `json:"userId"` // User who asked
+	User       User       `json:"-" gorm:"foreignKey:UserID"`
+	Body       string     `json:"body"`       // The user's question
+	Answer     string     `json:"answer"`     // Answer from an admin or the problem author
+	AnsweredBy *uint      `json:"answeredBy"` // ID of the answering user (null if unanswered)
+	AnsweredAt *time.Time `json:"answeredAt"` // Date when the clarification was answered
+	Public     bool       `json:"public"`     // Whether the answer is published to everyone
+}
+
+func MigrateClarification(db *gorm.DB) error {
+	err := db.AutoMigrate(&Clarification{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// Collection is an ordered list of questions put together by a user, such as
+// a study plan. Public collections are listed to everyone and can be shared by
+// link, private ones are only shown to their owner.
+type Collection struct {
+	gorm.Model
+	Title         string               `json:"title"`
+	Description   string               `json:"description"`
+	OwnerID       uint                 `json:"ownerId" gorm:"index"`
+	Owner         User                 `json:"-" gorm:"foreignKey:OwnerID"`
+	Public        bool                 `json:"public" gorm:"index"`
+	Items         []CollectionQuestion `json:"items,omitempty" gorm:"foreignKey:CollectionID;constraint:OnDelete:CASCADE"`
+	OwnerName     string               `json:"owner" gorm:"-"`
+	QuestionCount int                  `json:"questionCount" gorm:"-"`
+}
+
+// CollectionQuestion places a question in a collection
+type CollectionQuestion struct {
+	ID           uint `json:"-" gorm:"primarykey"`
+	CollectionID uint `json:"collectionId" gorm:"uniqueIndex:idx_collection_question"`
+	QuestionID   uint `json:"questionId" gorm:"uniqueIndex:idx_collection_question;index"`
+	Position     int  `json:"position"` // Place of the question in the collection, starting at 0
+}
+
+func MigrateCollection(db *gorm.DB) error {
+	err := db.AutoMigrate(&Collection{}, &CollectionQuestion{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Contest is a timed set of questions ranked with ICPC rules: more solved
+// problems first, then less penalty time
+type Contest struct {
+	gorm.Model
+	Title         string           `json:"title"`
+	Description   string           `json:"description"`
+	StartTime     time.Time        `json:"startTime"`
+	EndTime       time.Time        `json:"endTime"`
+	FreezeMinutes int              `json:"freezeMinutes"` // Scoreboard freezes this long before the end, 0 never freezes
+	UnfrozenAt    *time.Time       `json:"unfrozenAt"`    // When the frozen scoreboard was revealed (null while frozen)
+	UserID        uint             `json:"userId"`        // ID of the user who created the contest
+	User          User             `json:"-" gorm:"foreignKey:UserID"`
+	Problems      []ContestProblem `json:"problems" gorm:"foreignKey:ContestID;constraint:OnDelete:CASCADE"`
+
+	// Set once the problems were published to everyone after the end
+	ProblemsPublishedAt *time.Time `json:"problemsPublishedAt"`
+
+	// Filled in per request
+	Registered   bool  `json:"registered" gorm:"-"`   // Whether the requesting user is registered
+	Participants int64 `json:"participants" gorm:"-"` // Number of registered users
+}
+
+// FreezeTime returns when the scoreboard stops showing new results
+func (c *Contest) FreezeTime() time.Time {
+	return c.EndTime.Add(-time.Duration(c.FreezeMinutes) * time.Minute)
+}
+
+// Frozen reports whether contestants see the scoreboard as of FreezeTime. It
+// stays frozen after the contest ends until it is unfrozen.
+func (c *Contest) Frozen(now time.Time) bool {
+	return c.FreezeMinutes > 0 && c.UnfrozenAt == nil && !now.Before(c.FreezeTime())
+}
+
+// Running reports whether submissions are accepted for the contest
+func (c *Contest) Running(now time.Time) bool {
+	return !now.Before(c.StartTime) && now.Before(c.EndTime)
+}
+
+// Ended reports whether the contest is over
+func (c *Contest) Ended(now time.Time) bool {
+	return !now.Before(c.EndTime)
+}
+
+// ContestProblem places a question in a contest under a label such as "A"
+type ContestProblem struct {
+	gorm.Model
+	ContestID  uint     `json:"contestId" gorm:"uniqueIndex:idx_contest_problem"`
+	QuestionID uint     `json:"questionId" gorm:"uniqueIndex:idx_contest_problem"`
+	Question   Question `json:"-" gorm:"foreignKey:QuestionID"`
+	Label      string   `json:"label"`
+}
+
+// ContestRegistration signs a user up for a contest. During the contest only
+// registered users see its problems and can submit to it.
+type ContestRegistration struct {
+	gorm.Model
+	ContestID uint `json:"contestId" gorm:"uniqueIndex:idx_contest_registration"`
+	UserID    uint `json:"userId" gorm:"uniqueIndex:idx_contest_registration"`
+	User      User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// ContestResult is a user's standing on one contest problem. It is recomputed
+// from that user's submissions to the problem whenever one of them gets a
+// verdict, so the scoreboard only has to add up rows.
+type ContestResult struct {
+	gorm.Model
+	ContestID  uint `json:"contestId" gorm:"uniqueIndex:idx_contest_result"`
+	UserID     uint `json:"userId" gorm:"uniqueIndex:idx_contest_result"`
+	QuestionID uint `json:"questionId" gorm:"uniqueIndex:idx_contest_result"`
+
+	// Live standing
+	Solved      bool `json:"solved"`
+	Attempts    int  `json:"attempts"`    // Penalized attempts before the first accepted one
+	SolveMinute int  `json:"solveMinute"` // Minutes from the contest start to the first accepted submission
+
+	// Standing as of the freeze, shown to contestants while the scoreboard is frozen
+	FrozenSolved      bool `json:"frozenSolved"`
+	FrozenAttempts    int  `json:"frozenAttempts"`
+	FrozenSolveMinute int  `json:"frozenSolveMinute"`
+	PendingAttempts   int  `json:"pendingAttempts"` // Submissions made during the freeze, results hidden
+}
+
+// ContestAnnouncement is posted by the contest's organizers to everyone in
+// the contest while it runs
+type ContestAnnouncement struct {
+	gorm.Model
+	ContestID uint   `json:"contestId" gorm:"index"`
+	UserID    uint   `json:"userId"` // Organizer who posted it
+	Body      string `json:"body"`
+}
+
+// ContestClarification is a contestant's question to the organizers of a
+// contest, optionally about one of its problems, and their answer. It is kept
+// apart from the clarifications of a question, which everyone can ask.
+type ContestClarification struct {
+	gorm.Model
+	ContestID  uint       `json:"contestId" gorm:"index"`
+	QuestionID *uint      `json:"questionId"` // Problem asked about, null for the contest in general
+	UserID     uint       `json:"userId"`     // Contestant who asked
+	Body       string     `json:"body"`
+	Answer     string     `json:"answer"`
+	AnsweredBy *uint      `json:"answeredBy"`
+	AnsweredAt *time.Time `json:"answeredAt"`
+	Public     bool       `json:"public"` // Whether the answer is shown to every contestant
+}
+
+func MigrateContest(db *gorm.DB) error {
+	err := db.AutoMigrate(&Contest{}, &ContestProblem{}, &ContestResult{}, &ContestRegistration{},
+		&ContestAnnouncement{}, &ContestClarification{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
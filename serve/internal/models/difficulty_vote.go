@@ -0,0 +1,28 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// Difficulty votes range from MinDifficultyVote (very easy) to MaxDifficultyVote (very hard)
+const (
+	MinDifficultyVote = 1
+	MaxDifficultyVote = 5
+)
+
+// DifficultyVote is a user's rating of how hard a question is. Each user has
+// one vote per question, and voting again replaces it.
+type DifficultyVote struct {
+	gorm.Model
+	QuestionID uint `json:"questionId" gorm:"uniqueIndex:idx_difficulty_vote"`
+	UserID     uint `json:"userId" gorm:"uniqueIndex:idx_difficulty_vote"`
+	Rating     int  `json:"rating"`
+}
+
+func MigrateDifficultyVote(db *gorm.DB) error {
+	err := db.AutoMigrate(&DifficultyVote{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
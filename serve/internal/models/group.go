@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Group is a set of users, such as a course's students, that its owner can
+// hand out assignments to. Users join with the group's invite code.
+type Group struct {
+	gorm.Model
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	OwnerID     uint          `json:"ownerId"`
+	Owner       User          `json:"-" gorm:"foreignKey:OwnerID"`
+	InviteCode  string        `json:"inviteCode,omitempty" gorm:"uniqueIndex"` // Only shown to the owner
+	Members     []GroupMember `json:"members,omitempty" gorm:"foreignKey:GroupID;constraint:OnDelete:CASCADE"`
+	Assignments []Assignment  `json:"assignments,omitempty" gorm:"foreignKey:GroupID;constraint:OnDelete:CASCADE"`
+}
+
+// GroupMember is a user's membership in a group
+type GroupMember struct {
+	gorm.Model
+	GroupID  uint   `json:"groupId" gorm:"uniqueIndex:idx_group_member"`
+	UserID   uint   `json:"userId" gorm:"uniqueIndex:idx_group_member"`
+	User     User   `json:"-" gorm:"foreignKey:UserID"`
+	Username string `json:"username" gorm:"-"`
+}
+
+// Assignment is a set of questions a group's members should solve by Deadline
+type Assignment struct {
+	gorm.Model
+	GroupID     uint                 `json:"groupId" gorm:"index"`
+	Title       string               `json:"title"`
+	Description string               `json:"description"`
+	Deadline    time.Time            `json:"deadline"`
+	Questions   []AssignmentQuestion `json:"questions" gorm:"foreignKey:AssignmentID;constraint:OnDelete:CASCADE"`
+}
+
+// AssignmentQuestion places a question in an assignment
+type AssignmentQuestion struct {
+	gorm.Model
+	AssignmentID uint     `json:"assignmentId" gorm:"uniqueIndex:idx_assignment_question"`
+	QuestionID   uint     `json:"questionId" gorm:"uniqueIndex:idx_assignment_question"`
+	Question     Question `json:"-" gorm:"foreignKey:QuestionID"`
+}
+
+func MigrateGroup(db *gorm.DB) error {
+	err := db.AutoMigrate(&Group{}, &GroupMember{}, &Assignment{}, &AssignmentQuestion{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
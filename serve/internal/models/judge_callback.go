@@ -0,0 +1,23 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// JudgeCallback records a verdict applied from a judge callback under the
+// callback's idempotency key, so a retried delivery is recognised and not
+// applied twice.
+type JudgeCallback struct {
+	gorm.Model
+	SubmissionID   uint        `json:"submissionId" gorm:"index"`
+	IdempotencyKey string      `json:"idempotencyKey" gorm:"uniqueIndex"`
+	Status         JudgeStatus `json:"status"`
+}
+
+func MigrateJudgeCallback(db *gorm.DB) error {
+	err := db.AutoMigrate(&JudgeCallback{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
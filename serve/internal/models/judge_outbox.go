@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// JudgeOutbox holds submissions waiting to be delivered to the judge. A row is
+// written together with its submission and removed once the judge accepted it,
+// so submissions survive the judge being down.
+type JudgeOutbox struct {
+	gorm.Model
+	SubmissionID  uint       `json:"submissionId" gorm:"uniqueIndex"`
+	Submission    Submission `json:"-" gorm:"foreignKey:SubmissionID;constraint:OnDelete:CASCADE"`
+	Attempts      int        `json:"attempts"`                   // Failed delivery attempts so far
+	NextAttemptAt time.Time  `json:"nextAttemptAt" gorm:"index"` // When the dispatcher tries again
+	LastError     string     `json:"lastError"`
+	TraceParent   string     `json:"-"` // Trace of the request that queued the submission
+}
+
+func MigrateJudgeOutbox(db *gorm.DB) error {
+	err := db.AutoMigrate(&JudgeOutbox{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
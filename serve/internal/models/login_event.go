@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LoginEvent is a sign in to an account, with the device it came from, so
+// users can review where their account was used
+type LoginEvent struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"createdAt"`
+	UserID    uint      `json:"userId" gorm:"index"`
+	Method    string    `json:"method"` // password, oauth or register
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"userAgent"`
+	NewDevice bool      `json:"newDevice"` // None of the user's earlier logins came from this user agent
+}
+
+func MigrateLoginEvent(db *gorm.DB) error {
+	err := db.AutoMigrate(&LoginEvent{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LoginThrottle counts the failed logins in a row of one account or client IP
+type LoginThrottle struct {
+	Key         string     `gorm:"primaryKey;size:255"` // account:<username> or ip:<address>
+	Failures    int        `gorm:"not null;default:0"`
+	LastFailure time.Time  `gorm:"index"`
+	LockedUntil *time.Time // Accounts only: no login is allowed until then
+	UnlockToken string     `gorm:"index"` // Hash of the token in the unlock email
+}
+
+func MigrateLoginThrottle(db *gorm.DB) error {
+	err := db.AutoMigrate(&LoginThrottle{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
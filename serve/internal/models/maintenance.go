@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Maintenance is the maintenance mode set through the admin API. It is a
+// single row, so every serve instance sees the same setting.
+type Maintenance struct {
+	ID        uint   `gorm:"primaryKey"`
+	Enabled   bool   `gorm:"not null;default:false"`
+	Message   string // Shown instead of the configured message when set
+	UpdatedBy *uint  // Admin who last changed it
+	UpdatedAt time.Time
+}
+
+func MigrateMaintenance(db *gorm.DB) error {
+	err := db.AutoMigrate(&Maintenance{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// NotificationType identifies what a notification is about
+type NotificationType string
+
+const (
+	NotificationSubmissionJudged      NotificationType = "submission_judged"      // One of the user's submissions got a verdict
+	NotificationQuestionPublished     NotificationType = "question_published"     // An admin published the user's question
+	NotificationClarificationAnswered NotificationType = "clarification_answered" // The user's clarification was answered
+	NotificationQuestionReviewed      NotificationType = "question_reviewed"      // The review status of the user's question changed
+	NotificationQuestionShared        NotificationType = "question_shared"        // The user was made the owner or a co-author of a question
+	NotificationAchievement           NotificationType = "achievement"            // The user earned a badge
+)
+
+// Notification is an in-app message for a user
+type Notification struct {
+	gorm.Model
+	UserID  uint             `json:"userId" gorm:"index"`
+	Type    NotificationType `json:"type"`
+	Message string           `json:"message"`
+	Link    string           `json:"link"`   // Page the notification points to
+	ReadAt  *time.Time       `json:"readAt"` // Null while unread
+}
+
+func MigrateNotification(db *gorm.DB) error {
+	err := db.AutoMigrate(&Notification{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package models
+
+import "gorm.io/gorm"
+
+// OAuthIdentity links an account at an OAuth provider to a local user
+type OAuthIdentity struct {
+	gorm.Model
+	Provider       string `json:"provider" gorm:"uniqueIndex:idx_oauth_provider_user"`       // Provider name, e.g. github or google
+	ProviderUserID string `json:"providerUserId" gorm:"uniqueIndex:idx_oauth_provider_user"` // Account ID at the provider
+	Email          string `json:"email"`                                                     // Verified email reported by the provider
+	UserID         uint   `json:"userId" gorm:"index"`
+	User           User   `json:"-" gorm:"foreignKey:UserID"`
+}
+
+func MigrateOAuthIdentity(db *gorm.DB) error {
+	err := db.AutoMigrate(&OAuthIdentity{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -1,54 +1,311 @@
-package models
-
-import (
-	"time"
-
-	"gorm.io/gorm"
-)
-
-type Question struct {
-	gorm.Model
-	Title       string       `json:"title"`       // Question title
-	Content     string       `json:"content"`     // Question content/description
-	Published   bool         `json:"published"`   // Whether the question is published
-	PublishedBy *uint        `json:"publishedBy"` // ID of the admin who published the question (null if not published)
-	PublishedAt *time.Time   `json:"publishedAt"` // Date when the question was published
-	UserID      uint         `json:"userId"`      // ID of the user who created the question
-	User        User         `json:"-" gorm:"foreignKey:UserID"`
-	Submissions []Submission `json:"-" gorm:"foreignKey:QuestionID"`
-	Difficulty  string       `json:"difficulty"`  // Difficulty level
-	Tags        string       `json:"tags"`        // Question tags
-	TimeLimit   int          `json:"timeLimit"`   // Time limit (in milliseconds)
-	MemoryLimit int          `json:"memoryLimit"` // Memory limit (in megabytes)
-	TestCases   []TestCase   `json:"testCases" gorm:"foreignKey:QuestionID"`
-}
-
-type TestCase struct {
-	gorm.Model
-	QuestionID     uint     `json:"questionId"`
-	Question       Question `json:"-" gorm:"foreignKey:QuestionID"`
-	Input          string   `json:"input"`
-	ExpectedOutput string   `json:"expectedOutput"`
-}
-
-func MigrateQuestion(db *gorm.DB) error {
-	err := db.AutoMigrate(&Question{})
-	if err != nil {
-		return err
-	}
-	err = db.AutoMigrate(&TestCase{})
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func MigrateTestCase(db *gorm.DB) error {
-	err := db.AutoMigrate(&TestCase{})
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
+package models
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"goera/serve/internal/storage"
+
+	"gorm.io/gorm"
+)
+
+type Question struct {
+	gorm.Model
+	Title       string       `json:"title"`       // Question title
+	Content     string       `json:"content"`     // Question content/description
+	Published   bool         `json:"published"`   // Whether the question is published
+	PublishedBy *uint        `json:"publishedBy"` // ID of the admin who published the question (null if not published)
+	PublishedAt *time.Time   `json:"publishedAt"` // Date when the question was published
+	UserID      uint         `json:"userId"`      // ID of the user who created the question
+	User        User         `json:"-" gorm:"foreignKey:UserID"`
+	Submissions []Submission `json:"-" gorm:"foreignKey:QuestionID;constraint:OnDelete:CASCADE"`
+	Difficulty  Difficulty   `json:"difficulty" gorm:"index"` // Difficulty level set by the author, empty until set
+	Tags        string       `json:"tags"`                    // Question tags
+	TimeLimit   int          `json:"timeLimit"`               // Time limit (in milliseconds)
+	MemoryLimit int          `json:"memoryLimit"`             // Memory limit (in megabytes)
+	TestCases   []TestCase   `json:"testCases" gorm:"foreignKey:QuestionID;constraint:OnDelete:CASCADE"`
+
+	// AllowedLanguages is a comma separated list of the languages accepted for
+	// submissions. Empty accepts every supported language.
+	AllowedLanguages string `json:"allowedLanguages"`
+
+	// BuildFlags is a space separated list of go build flags submissions are
+	// built with, e.g. "-tags=fast -gcflags=-B". Only flags on the allowlist
+	// are accepted, see README.md.
+	BuildFlags string `json:"buildFlags"`
+
+	// Limits on each user's submissions, 0 for no limit. MaxAttempts caps how
+	// many they make in total, AttemptCooldown is the seconds between two.
+	MaxAttempts     int `json:"maxAttempts"`
+	AttemptCooldown int `json:"attemptCooldown"`
+
+	// Editorial and hints are markdown served separately through the editorial
+	// endpoint, so they never leak through question listings
+	Editorial           string              `json:"-"`
+	Hints               string              `json:"-"`
+	EditorialVisibility EditorialVisibility `json:"editorialVisibility"` // Empty uses the deployment default
+	EditorialReleaseAt  *time.Time          `json:"editorialReleaseAt"`  // Used with EditorialAfterRelease
+
+	// ReferenceSolution is the author's Go solution. When set, publishing runs
+	// it against every test case and is refused unless it is accepted.
+	ReferenceSolution string `json:"-"`
+
+	// Status is where the question is in the review workflow. Published
+	// mirrors it, being true exactly when Status is QuestionStatusPublished.
+	Status QuestionStatus `json:"status" gorm:"index;default:draft"`
+
+	// Average of the users' difficulty votes, kept up to date on every vote so
+	// the question list can sort and filter by it. Zero while there are no votes.
+	DifficultyRating float64 `json:"difficultyRating" gorm:"index"`
+	DifficultyVotes  int64   `json:"difficultyVotes"`
+
+	// Computed for question listings, not stored
+	TotalSubmissions int64              `json:"totalSubmissions" gorm:"-"`
+	AcceptanceRate   float64            `json:"acceptanceRate" gorm:"-"`
+	UserStatus       UserQuestionStatus `json:"userStatus,omitempty" gorm:"-"` // Only set for signed in users
+
+	// Examples are the question's sample test cases, filled in when a single
+	// question is served rather than stored alongside its test cases
+	Examples []Example `json:"examples,omitempty" gorm:"-"`
+}
+
+// Example is an input with its expected output shown on the question page
+type Example struct {
+	Input  string `json:"input"`
+	Output string `json:"output"`
+}
+
+// QuestionStatus is a state of the review workflow a question goes through
+// before it is published
+type QuestionStatus string
+
+const (
+	QuestionStatusDraft            QuestionStatus = "draft"             // Being written by the author
+	QuestionStatusInReview         QuestionStatus = "in_review"         // Submitted by the author, waiting for an admin
+	QuestionStatusChangesRequested QuestionStatus = "changes_requested" // Sent back to the author with review comments
+	QuestionStatusPublished        QuestionStatus = "published"         // Visible to everyone
+)
+
+// UserQuestionStatus is how far a user got with a question
+type UserQuestionStatus string
+
+const (
+	UserSolved    UserQuestionStatus = "solved"    // At least one accepted submission
+	UserAttempted UserQuestionStatus = "attempted" // Submissions, none of them accepted
+	UserUntried   UserQuestionStatus = "untried"   // No submissions
+)
+
+// Languages returns the languages the question is restricted to, or nil when
+// any language is accepted
+func (q *Question) Languages() []string {
+	if q.AllowedLanguages == "" {
+		return nil
+	}
+	return strings.Split(q.AllowedLanguages, ",")
+}
+
+// BuildFlagList returns the question's build flags one by one, as jobs for
+// the judge carry them
+func (q *Question) BuildFlagList() []string {
+	return strings.Fields(q.BuildFlags)
+}
+
+// AllowsLanguage reports whether submissions in language are accepted
+func (q *Question) AllowsLanguage(language string) bool {
+	languages := q.Languages()
+	if languages == nil {
+		return true
+	}
+	for _, l := range languages {
+		if l == language {
+			return true
+		}
+	}
+	return false
+}
+
+// Difficulty is how hard the author rates a question, as opposed to the
+// users' difficulty votes
+type Difficulty string
+
+const (
+	DifficultyEasy   Difficulty = "easy"
+	DifficultyMedium Difficulty = "medium"
+	DifficultyHard   Difficulty = "hard"
+)
+
+// Difficulties lists the difficulties from easiest to hardest
+var Difficulties = []Difficulty{DifficultyEasy, DifficultyMedium, DifficultyHard}
+
+// IsValid reports whether d is a known difficulty
+func (d Difficulty) IsValid() bool {
+	switch d {
+	case DifficultyEasy, DifficultyMedium, DifficultyHard:
+		return true
+	}
+	return false
+}
+
+// legacyDifficulty maps the free text difficulties written before they were
+// restricted, and ratings on the scale of difficulty votes, to a difficulty.
+// Anything else maps to none.
+func legacyDifficulty(value string) Difficulty {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "easy", "beginner", "simple", "1", "2":
+		return DifficultyEasy
+	case "medium", "moderate", "intermediate", "normal", "3":
+		return DifficultyMedium
+	case "hard", "difficult", "advanced", "expert", "4", "5":
+		return DifficultyHard
+	}
+	return ""
+}
+
+// EditorialVisibility controls when solvers can read a question's editorial and hints
+type EditorialVisibility string
+
+const (
+	EditorialAfterSolve   EditorialVisibility = "after_solve"   // Once the user has an accepted submission
+	EditorialAfterRelease EditorialVisibility = "after_release" // Once EditorialReleaseAt has passed, e.g. at contest end
+	EditorialAfterContest EditorialVisibility = "after_contest" // Once no contest with the question is running
+	EditorialAlways       EditorialVisibility = "always"        // Immediately
+)
+
+// IsValid reports whether v is a known visibility
+func (v EditorialVisibility) IsValid() bool {
+	switch v {
+	case EditorialAfterSolve, EditorialAfterRelease, EditorialAfterContest, EditorialAlways:
+		return true
+	}
+	return false
+}
+
+type TestCase struct {
+	gorm.Model
+	QuestionID     uint     `json:"questionId"`
+	Question       Question `json:"-" gorm:"foreignKey:QuestionID"`
+	Input          string   `json:"input"`
+	ExpectedOutput string   `json:"expectedOutput"`
+	Sample         bool     `json:"sample" gorm:"not null;default:false"` // Shown as an example on the question page, see Question.Examples
+
+	// References to the input and expected output when they are kept in file
+	// storage, which leaves the columns above empty. See LoadData.
+	InputRef  string `json:"-"`
+	OutputRef string `json:"-"`
+}
+
+// BeforeSave moves an input or expected output over the storage inline limit
+// to file storage. A test case copied from another keeps its references, as
+// its data is empty.
+func (tc *TestCase) BeforeSave(tx *gorm.DB) error {
+	ref, err := storage.Offload(tx.Statement.Context, "testcases", tc.Input)
+	if err != nil {
+		return err
+	}
+	if ref != "" {
+		tc.Input, tc.InputRef = "", ref
+	} else if tc.Input != "" {
+		tc.InputRef = ""
+	}
+	ref, err = storage.Offload(tx.Statement.Context, "testcases", tc.ExpectedOutput)
+	if err != nil {
+		return err
+	}
+	if ref != "" {
+		tc.ExpectedOutput, tc.OutputRef = "", ref
+	} else if tc.ExpectedOutput != "" {
+		tc.OutputRef = ""
+	}
+	return nil
+}
+
+// LoadData reads an input and expected output kept in file storage back
+// into the test case. Jobs for the judge link to them instead.
+func (tc *TestCase) LoadData(ctx context.Context) error {
+	if tc.InputRef != "" {
+		input, err := storage.Load(ctx, tc.InputRef)
+		if err != nil {
+			return err
+		}
+		tc.Input = input
+	}
+	if tc.OutputRef != "" {
+		output, err := storage.Load(ctx, tc.OutputRef)
+		if err != nil {
+			return err
+		}
+		tc.ExpectedOutput = output
+	}
+	return nil
+}
+
+func MigrateQuestion(db *gorm.DB) error {
+	err := db.AutoMigrate(&Question{})
+	if err != nil {
+		return err
+	}
+	// Questions published before the review workflow start out as drafts
+	err = db.Model(&Question{}).
+		Where("published = ? AND status = ?", true, QuestionStatusDraft).
+		Update("status", QuestionStatusPublished).Error
+	if err != nil {
+		return err
+	}
+	err = migrateDifficulties(db, &Question{})
+	if err != nil {
+		return err
+	}
+	err = migrateQuestionSearch(db)
+	if err != nil {
+		return err
+	}
+	err = MigrateTestCase(db)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// migrateDifficulties rewrites the difficulties of questions, or of their
+// revisions, written before they were restricted to the known ones. Those that
+// cannot be mapped are cleared, which the publish checklist then asks for
+// again.
+func migrateDifficulties(db *gorm.DB, model interface{}) error {
+	var values []string
+	err := db.Unscoped().Model(model).
+		Where("difficulty NOT IN ? AND difficulty <> ''", Difficulties).
+		Distinct().Pluck("difficulty", &values).Error
+	if err != nil {
+		return err
+	}
+	for _, value := range values {
+		err = db.Unscoped().Model(model).Where("difficulty = ?", value).
+			UpdateColumn("difficulty", legacyDifficulty(value)).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func MigrateTestCase(db *gorm.DB) error {
+	flagSamples := !db.Migrator().HasColumn(&TestCase{}, "Sample")
+	err := db.AutoMigrate(&TestCase{})
+	if err != nil {
+		return err
+	}
+	// Before test cases were flagged, the first one of each question was its
+	// example. Test cases are replaced as a whole on every edit, so grouping by
+	// deletion time also flags the first one of each replaced set.
+	if flagSamples {
+		first := db.Unscoped().Model(&TestCase{}).Select("MIN(id)").Group("question_id, deleted_at")
+		err = db.Unscoped().Model(&TestCase{}).Where("id IN (?)", first).UpdateColumn("sample", true).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// QuestionContributor makes a user a co-author of a question. Co-authors can
+// edit it like its owner, but only the owner and admins manage its co-authors
+// and hand it over to someone else.
+type QuestionContributor struct {
+	gorm.Model
+	QuestionID uint   `json:"questionId" gorm:"uniqueIndex:idx_question_contributor"`
+	UserID     uint   `json:"userId" gorm:"uniqueIndex:idx_question_contributor;index"`
+	User       User   `json:"-" gorm:"foreignKey:UserID"`
+	AddedBy    uint   `json:"addedBy"`
+	Username   string `json:"username" gorm:"-"`
+}
+
+func MigrateQuestionContributor(db *gorm.DB) error {
+	err := db.AutoMigrate(&QuestionContributor{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// QuestionDraft holds statement edits autosaved from the edit form that were
+// not submitted yet. Each editor has their own draft of a question, which is
+// removed when they save the question.
+type QuestionDraft struct {
+	gorm.Model
+	QuestionID uint   `json:"questionId" gorm:"uniqueIndex:idx_question_draft"`
+	UserID     uint   `json:"userId" gorm:"uniqueIndex:idx_question_draft"`
+	Title      string `json:"title"`
+	Content    string `json:"content"`
+	Tags       string `json:"tags"`
+}
+
+func MigrateQuestionDraft(db *gorm.DB) error {
+	err := db.AutoMigrate(&QuestionDraft{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
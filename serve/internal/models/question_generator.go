@@ -0,0 +1,27 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// QuestionGenerator is a Go program that writes random test cases for a
+// question. Each run reads a seed on its first input line followed by Spec,
+// and prints the test input, a line with just GeneratorSeparator, and the
+// expected output.
+type QuestionGenerator struct {
+	gorm.Model
+	QuestionID uint   `json:"questionId" gorm:"uniqueIndex"`
+	SourceCode string `json:"sourceCode"`
+	Spec       string `json:"spec"`
+}
+
+// GeneratorSeparator divides a generated test's input from its expected output
+const GeneratorSeparator = "---"
+
+func MigrateQuestionGenerator(db *gorm.DB) error {
+	err := db.AutoMigrate(&QuestionGenerator{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package models
+
+import "gorm.io/gorm"
+
+// QuestionReview records a change of a question's review status, with the
+// reviewer's comment on the revision the change was made at
+type QuestionReview struct {
+	gorm.Model
+	QuestionID uint           `json:"questionId" gorm:"index"`
+	Revision   int            `json:"revision"`   // Revision of the question that was reviewed
+	ReviewerID uint           `json:"reviewerId"` // ID of the user who changed the status
+	FromStatus QuestionStatus `json:"fromStatus"`
+	ToStatus   QuestionStatus `json:"toStatus"`
+	Comment    string         `json:"comment"`
+}
+
+func MigrateQuestionReview(db *gorm.DB) error {
+	err := db.AutoMigrate(&QuestionReview{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"slices"
+
+	"gorm.io/gorm"
+)
+
+// RevisionTestCase is a test case as it was at the time of a revision
+type RevisionTestCase struct {
+	Input          string `json:"input"`
+	ExpectedOutput string `json:"expectedOutput"`
+	Sample         bool   `json:"sample,omitempty"`
+	InputRef       string `json:"inputRef,omitempty"`  // Set when the input is in file storage
+	OutputRef      string `json:"outputRef,omitempty"` // Set when the expected output is in file storage
+}
+
+// QuestionRevision is an immutable snapshot of a question taken on every edit
+type QuestionRevision struct {
+	gorm.Model
+	QuestionID       uint               `json:"questionId" gorm:"uniqueIndex:idx_question_revision"`
+	Revision         int                `json:"revision" gorm:"uniqueIndex:idx_question_revision"` // Sequence number per question, starting at 1
+	Title            string             `json:"title"`
+	Content          string             `json:"content"`
+	Tags             string             `json:"tags"`
+	AllowedLanguages string             `json:"allowedLanguages"`
+	BuildFlags       string             `json:"buildFlags"`
+	TimeLimit        int                `json:"timeLimit"`
+	MemoryLimit      int                `json:"memoryLimit"`
+	Difficulty       Difficulty         `json:"difficulty"`
+	MaxAttempts      int                `json:"maxAttempts"`
+	AttemptCooldown  int                `json:"attemptCooldown"`
+	TestCases        []RevisionTestCase `json:"testCases" gorm:"serializer:json"`
+	TestCasesChanged bool               `json:"testCasesChanged" gorm:"not null;default:false"` // Whether the test cases differ from the previous revision
+	EditedBy         uint               `json:"editedBy"`                                       // ID of the user who made the edit
+	Note             string             `json:"note"`                                           // Optional description, e.g. for rollbacks
+}
+
+func MigrateQuestionRevision(db *gorm.DB) error {
+	flagChanges := !db.Migrator().HasColumn(&QuestionRevision{}, "TestCasesChanged")
+	fillAttemptLimits := !db.Migrator().HasColumn(&QuestionRevision{}, "MaxAttempts")
+	fillDifficulties := !db.Migrator().HasColumn(&QuestionRevision{}, "Difficulty")
+	err := db.AutoMigrate(&QuestionRevision{})
+	if err != nil {
+		return err
+	}
+	if flagChanges {
+		if err := flagTestCaseChanges(db); err != nil {
+			return err
+		}
+	}
+	// Revisions recorded before attempt limits were kept take the question's
+	// current limits, so rolling back to them leaves the limits as they are.
+	// Without the columns on questions yet there are no limits to copy.
+	if fillAttemptLimits && db.Migrator().HasColumn(&Question{}, "MaxAttempts") {
+		err = db.Exec(`UPDATE question_revisions SET
+			max_attempts = (SELECT max_attempts FROM questions WHERE questions.id = question_revisions.question_id),
+			attempt_cooldown = (SELECT attempt_cooldown FROM questions WHERE questions.id = question_revisions.question_id)
+			WHERE question_id IN (SELECT id FROM questions)`).Error
+		if err != nil {
+			return err
+		}
+	}
+	if fillDifficulties {
+		return fillRevisionDifficulties(db)
+	}
+	return nil
+}
+
+// fillRevisionDifficulties gives the revisions recorded before difficulties
+// were kept the question's current difficulty, mapped like migrateDifficulties
+// does in case the questions have not been migrated yet
+func fillRevisionDifficulties(db *gorm.DB) error {
+	err := db.Exec(`UPDATE question_revisions SET
+		difficulty = (SELECT difficulty FROM questions WHERE questions.id = question_revisions.question_id)
+		WHERE question_id IN (SELECT id FROM questions)`).Error
+	if err != nil {
+		return err
+	}
+	return migrateDifficulties(db, &QuestionRevision{})
+}
+
+// flagTestCaseChanges sets TestCasesChanged on the revisions recorded before
+// it was tracked, by comparing each with the previous one of its question
+func flagTestCaseChanges(db *gorm.DB) error {
+	var revisions []QuestionRevision
+	err := db.Select("id, question_id, revision, test_cases").Order("question_id, revision").Find(&revisions).Error
+	if err != nil {
+		return err
+	}
+
+	var changed []uint
+	for i := 1; i < len(revisions); i++ {
+		previous, current := revisions[i-1], revisions[i]
+		if previous.QuestionID == current.QuestionID && !slices.Equal(previous.TestCases, current.TestCases) {
+			changed = append(changed, current.ID)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	return db.Model(&QuestionRevision{}).Where("id IN ?", changed).UpdateColumn("test_cases_changed", true).Error
+}
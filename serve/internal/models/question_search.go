@@ -0,0 +1,40 @@
+package models
+
+import "gorm.io/gorm"
+
+// questionSearchSQL sets up full-text search of questions on PostgreSQL. A
+// trigger keeps questions.search_vector, the weighted words of a question's
+// title, tags and statement, up to date, and a GIN index makes matching it
+// fast. Titles and statements are stemmed as English; tags are matched as is.
+var questionSearchSQL = []string{
+	`ALTER TABLE questions ADD COLUMN IF NOT EXISTS search_vector tsvector`,
+	`CREATE OR REPLACE FUNCTION questions_search_vector_update() RETURNS trigger AS $$
+	BEGIN
+		NEW.search_vector :=
+			setweight(to_tsvector('english', coalesce(NEW.title, '')), 'A') ||
+			setweight(to_tsvector('simple', replace(coalesce(NEW.tags, ''), ',', ' ')), 'B') ||
+			setweight(to_tsvector('english', coalesce(NEW.content, '')), 'C');
+		RETURN NEW;
+	END
+	$$ LANGUAGE plpgsql`,
+	`DROP TRIGGER IF EXISTS questions_search_vector ON questions`,
+	`CREATE TRIGGER questions_search_vector BEFORE INSERT OR UPDATE OF title, tags, content ON questions
+	FOR EACH ROW EXECUTE FUNCTION questions_search_vector_update()`,
+	// Questions from before search existed are indexed by running the trigger
+	`UPDATE questions SET title = title WHERE search_vector IS NULL`,
+	`CREATE INDEX IF NOT EXISTS idx_questions_search_vector ON questions USING GIN (search_vector)`,
+}
+
+// migrateQuestionSearch sets up full-text search on PostgreSQL. Other
+// databases search with LIKE instead and need nothing.
+func migrateQuestionSearch(db *gorm.DB) error {
+	if db.Dialector.Name() != "postgres" {
+		return nil
+	}
+	for _, statement := range questionSearchSQL {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Session is a login on one device. The login token carries the session's
+// TokenID, and the token stops working once the session is revoked.
+type Session struct {
+	gorm.Model
+	UserID     uint       `json:"userId" gorm:"index"`
+	TokenID    string     `json:"-" gorm:"uniqueIndex"` // The jti claim of the session's login token
+	UserAgent  string     `json:"userAgent"`
+	IP         string     `json:"ip"`
+	LastSeenAt time.Time  `json:"lastSeenAt"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	RevokedAt  *time.Time `json:"revokedAt"`
+}
+
+// Active reports whether the session's login token can still be used
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+func MigrateSession(db *gorm.DB) error {
+	err := db.AutoMigrate(&Session{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
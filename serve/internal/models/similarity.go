@@ -0,0 +1,25 @@
+package models
+
+import "gorm.io/gorm"
+
+// SimilarityScore is the similarity between two accepted submissions to the
+// same question by different users. SubmissionAID is always the smaller ID.
+type SimilarityScore struct {
+	gorm.Model
+	QuestionID    uint       `json:"questionId" gorm:"index"`
+	SubmissionAID uint       `json:"submissionAId" gorm:"uniqueIndex:idx_similarity_pair"`
+	SubmissionBID uint       `json:"submissionBId" gorm:"uniqueIndex:idx_similarity_pair"`
+	SubmissionA   Submission `json:"-" gorm:"foreignKey:SubmissionAID"`
+	SubmissionB   Submission `json:"-" gorm:"foreignKey:SubmissionBID"`
+	UserAID       uint       `json:"userAId"`
+	UserBID       uint       `json:"userBId"`
+	Score         float64    `json:"score" gorm:"index"` // Jaccard similarity of the winnowed fingerprints, 0 to 1
+}
+
+func MigrateSimilarityScore(db *gorm.DB) error {
+	err := db.AutoMigrate(&SimilarityScore{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
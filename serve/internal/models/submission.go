@@ -1,46 +1,93 @@
-package models
-
-import (
-	"time"
-
-	"gorm.io/gorm"
-)
-
-// JudgeStatus represents the status of a submission
-type JudgeStatus string
-
-const (
-	Pending             JudgeStatus = "pending"               // Waiting for judgment
-	Judging             JudgeStatus = "judging"               // Currently being judged
-	Accepted            JudgeStatus = "accepted"              // Accepted
-	Rejected            JudgeStatus = "rejected"              // Rejected
-	TimeLimitExceeded   JudgeStatus = "time_limit_exceeded"   // Time limit exceeded
-	MemoryLimitExceeded JudgeStatus = "memory_limit_exceeded" // Memory limit exceeded
-	RuntimeError        JudgeStatus = "runtime_error"         // Runtime error
-	CompilationError    JudgeStatus = "compilation_error"     // Compilation error
-)
-
-type Submission struct {
-	gorm.Model
-	Code           string      `json:"code"`           // Submitted code
-	Language       string      `json:"language"`       // Programming language
-	JudgeStatus    JudgeStatus `json:"judgeStatus"`    // Judgment status
-	Output         string      `json:"output"`         // Code execution output
-	Error          string      `json:"error"`          // Error message if any
-	ExecutionTime  int         `json:"executionTime"`  // Execution time (milliseconds)
-	MemoryUsage    int         `json:"memoryUsage"`    // Memory usage (megabytes)
-	SubmissionTime time.Time   `json:"submissionTime"` // Submission time
-	QuestionID     uint        `json:"questionId"`     // Reference to the question
-	QuestionName   string      `json:"questionName"`   // Name of the question
-	Question       Question    `json:"-" gorm:"foreignKey:QuestionID"`
-	UserID         uint        `json:"userId"` // Reference to the user
-	User           User        `json:"-" gorm:"foreignKey:UserID"`
-}
-
-func MigrateSubmission(db *gorm.DB) error {
-	err := db.AutoMigrate(&Submission{})
-	if err != nil {
-		return err
-	}
-	return nil
-}
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// JudgeStatus represents the status of a submission
+type JudgeStatus string
+
+const (
+	Pending             JudgeStatus = "pending"               // Waiting for judgment
+	Judging             JudgeStatus = "judging"               // Currently being judged
+	Accepted            JudgeStatus = "accepted"              // Accepted
+	Rejected            JudgeStatus = "rejected"              // Rejected
+	TimeLimitExceeded   JudgeStatus = "time_limit_exceeded"   // Time limit exceeded
+	MemoryLimitExceeded JudgeStatus = "memory_limit_exceeded" // Memory limit exceeded
+	OutputLimitExceeded JudgeStatus = "output_limit_exceeded" // Output limit exceeded
+	RuntimeError        JudgeStatus = "runtime_error"         // Runtime error
+	CompilationError    JudgeStatus = "compilation_error"     // Compilation error
+	SystemError         JudgeStatus = "system_error"          // Judging failed for reasons outside the submission
+)
+
+type Submission struct {
+	gorm.Model
+	Code           string             `json:"code"`           // Submitted code
+	Language       string             `json:"language"`       // Programming language
+	JudgeStatus    JudgeStatus        `json:"judgeStatus"`    // Judgment status
+	Output         string             `json:"output"`         // Code execution output
+	Error          string             `json:"error"`          // Error message if any
+	ErrorRef       string             `json:"-"`              // Reference to the judge log when it is kept in file storage, leaving Error empty
+	ExecutionTime  int                `json:"executionTime"`  // Execution time (milliseconds)
+	MemoryUsage    int                `json:"memoryUsage"`    // Memory usage (megabytes)
+	SubmissionTime time.Time          `json:"submissionTime"` // Submission time
+	QuestionID     uint               `json:"questionId"`     // Reference to the question
+	QuestionName   string             `json:"questionName"`   // Name of the question
+	Question       Question           `json:"-" gorm:"foreignKey:QuestionID"`
+	UserID         uint               `json:"userId"` // Reference to the user
+	User           User               `json:"-" gorm:"foreignKey:UserID"`
+	JudgeAttempts  int                `json:"judgeAttempts"`                                                               // Times the submission was sent to the judge
+	TestsDone      int                `json:"testsDone"`                                                                   // Tests judged so far, updated as the runner reports each one
+	TestsTotal     int                `json:"testsTotal"`                                                                  // Tests the submission is judged on
+	ContestID      *uint              `json:"contestId" gorm:"index"`                                                      // Contest the submission was made in (null for practice)
+	Files          map[string]string  `json:"files,omitempty" gorm:"serializer:json"`                                      // Files by path of a multi-file submission, built as a Go module; Code then lists them all
+	Stderr         []SubmissionStderr `json:"stderr,omitempty" gorm:"foreignKey:SubmissionID;constraint:OnDelete:CASCADE"` // Only loaded for the submission's author
+	TestResults    []TestResult       `json:"testResults,omitempty" gorm:"-"`                                              // Per-test verdicts, only set on the detail endpoint
+
+	// Per-test resource usage, served through TestResults
+	TestUsage []SubmissionTestUsage `json:"-" gorm:"foreignKey:SubmissionID;constraint:OnDelete:CASCADE"`
+}
+
+// TestResult is the verdict of a single test case, read from the runner's
+// test_verdict events. Judging stops at the first failing test, so later
+// tests have no result.
+type TestResult struct {
+	TestCase int    `json:"testCase"` // 1-based
+	Total    int    `json:"total"`
+	Verdict  string `json:"verdict"`
+	// Usage is what the program used on the test, when the runner measured it
+	Usage *SubmissionTestUsage `json:"usage,omitempty"`
+}
+
+// SubmissionStderr is the truncated stderr of a failing test case, as captured by the code-runner
+type SubmissionStderr struct {
+	gorm.Model
+	SubmissionID uint   `json:"-" gorm:"index"`
+	TestCase     int    `json:"testCase"` // 1-based
+	Output       string `json:"output"`
+	Truncated    bool   `json:"truncated"`
+}
+
+// SubmissionTestUsage is what a submission used on a test case, as measured by
+// the code-runner. Docker samples memory and IO about once a second, so tests
+// that finish sooner can report less than they used; wall time is exact.
+type SubmissionTestUsage struct {
+	gorm.Model
+	SubmissionID    uint   `json:"-" gorm:"index"`
+	TestCase        int    `json:"testCase"` // 1-based
+	WallTimeMs      int64  `json:"wallTimeMs"`
+	CPUTimeMs       int64  `json:"cpuTimeMs"`
+	PeakMemoryBytes uint64 `json:"peakMemoryBytes"`
+	IOReadBytes     uint64 `json:"ioReadBytes"`
+	IOWriteBytes    uint64 `json:"ioWriteBytes"`
+}
+
+func MigrateSubmission(db *gorm.DB) error {
+	err := db.AutoMigrate(&Submission{}, &SubmissionStderr{}, &SubmissionTestUsage{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SubmissionEventType identifies a step in a submission's judging lifecycle
+type SubmissionEventType string
+
+const (
+	EventCreated          SubmissionEventType = "created"           // Submission stored by serve
+	EventQueued           SubmissionEventType = "queued"            // Judge queued the submission
+	EventDispatched       SubmissionEventType = "dispatched"        // Judge sent the submission to a runner
+	EventCompileFinished  SubmissionEventType = "compile_finished"  // Runner finished compiling
+	EventTestVerdict      SubmissionEventType = "test_verdict"      // Runner judged a single test case
+	EventCallbackReceived SubmissionEventType = "callback_received" // Serve received the final verdict
+	EventError            SubmissionEventType = "error"             // Something went wrong along the way
+	EventReaped           SubmissionEventType = "reaped"            // Stuck submission was requeued or failed by the reaper
+	EventDeferred         SubmissionEventType = "deferred"          // Judge unavailable, delivery will be retried from the outbox
+)
+
+// SubmissionEvent is a single entry in a submission's judging timeline
+type SubmissionEvent struct {
+	gorm.Model
+	SubmissionID uint                `json:"submissionId" gorm:"index"`
+	Source       string              `json:"source"`  // Which service emitted the event (serve, judge, runner)
+	Type         SubmissionEventType `json:"type"`    // Event type
+	Message      string              `json:"message"` // Human readable details
+	OccurredAt   time.Time           `json:"occurredAt" gorm:"index"`
+}
+
+func MigrateSubmissionEvent(db *gorm.DB) error {
+	err := db.AutoMigrate(&SubmissionEvent{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
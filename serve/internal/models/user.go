@@ -1,28 +1,39 @@
-package models
-
-import "gorm.io/gorm"
-
-// UserRole represents the role type of a user
-type UserRole string
-
-const (
-	AdminRole   UserRole = "ADMIN" // Administrator role
-	RegularRole UserRole = "USER"  // Regular user role
-)
-
-// User represents a user in the system
-type User struct {
-	gorm.Model
-	Username string   `json:"username"` // User's username
-	Password string   `json:"password"` // User's password (hashed)
-	Role     UserRole `json:"role"`     // User's role (ADMIN or USER)
-}
-
-func MigrateUser(db *gorm.DB) error {
-	err := db.AutoMigrate(&User{})
-	if err != nil {
-		return err
-	}
-	db.Model(&User{}).Where("role = ''").Update("role", RegularRole)
-	return nil
-}
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UserRole represents the role type of a user
+type UserRole string
+
+const (
+	AdminRole   UserRole = "ADMIN" // Administrator role
+	RegularRole UserRole = "USER"  // Regular user role
+)
+
+// User represents a user in the system
+type User struct {
+	gorm.Model
+	Username string   `json:"username"`           // User's username
+	Password string   `json:"password"`           // User's password (hashed)
+	Role     UserRole `json:"role"`               // User's role (ADMIN or USER)
+	Email    string   `json:"email" gorm:"index"` // Verified email from a linked OAuth account
+
+	// DeletionScheduledAt is when the user asked to delete their account, which
+	// is anonymized once the grace period after it has passed. AnonymizedAt is
+	// when that happened.
+	DeletionScheduledAt *time.Time `json:"-" gorm:"index"`
+	AnonymizedAt        *time.Time `json:"-"`
+}
+
+func MigrateUser(db *gorm.DB) error {
+	err := db.AutoMigrate(&User{})
+	if err != nil {
+		return err
+	}
+	db.Model(&User{}).Where("role = ''").Update("role", RegularRole)
+	return nil
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// Editor themes a user can pick
+const (
+	EditorThemeLight = "light"
+	EditorThemeDark  = "dark"
+)
+
+// UserPreferences are a user's settings for the pages: the language new
+// submissions default to, how code is shown, the timezone and locale pages are
+// rendered in, and whether to be emailed about logins from new devices. Users
+// without a row get DefaultUserPreferences.
+type UserPreferences struct {
+	gorm.Model     `json:"-"`
+	UserID         uint   `json:"userId" gorm:"uniqueIndex"`
+	Language       string `json:"language"`    // Default submission language, "" for the first one offered
+	EditorTheme    string `json:"editorTheme"` // dark or light
+	EditorFontSize int    `json:"editorFontSize"`
+	EditorTabSize  int    `json:"editorTabSize"`
+	Timezone       string `json:"timezone"`                                  // IANA name such as Asia/Tehran
+	Locale         string `json:"locale"`                                    // "" follows the browser
+	LoginAlerts    bool   `json:"loginAlerts" gorm:"not null;default:false"` // Email the user when they sign in from a new device
+}
+
+// DefaultUserPreferences returns the preferences of a user who never changed them
+func DefaultUserPreferences(userID uint) UserPreferences {
+	return UserPreferences{
+		UserID:         userID,
+		EditorTheme:    EditorThemeDark,
+		EditorFontSize: 14,
+		EditorTabSize:  4,
+		Timezone:       "UTC",
+	}
+}
+
+func MigrateUserPreferences(db *gorm.DB) error {
+	err := db.AutoMigrate(&UserPreferences{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
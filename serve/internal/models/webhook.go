@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Webhook is a URL that receives a signed POST whenever a submission it
+// watches gets a final verdict. A user's webhook watches their own
+// submissions; a group's webhook, registered by the group's owner, watches
+// its members' submissions to the questions of its assignments.
+type Webhook struct {
+	gorm.Model
+	UserID          uint       `json:"userId" gorm:"index"`  // Who registered the webhook
+	GroupID         *uint      `json:"groupId" gorm:"index"` // Null for a webhook of the user's own submissions
+	URL             string     `json:"url"`
+	Secret          string     `json:"-"`          // Signs deliveries; only returned when the webhook is created
+	LastStatus      int        `json:"lastStatus"` // HTTP status of the last delivery, 0 if it got no response
+	LastError       string     `json:"lastError"`
+	LastDeliveredAt *time.Time `json:"lastDeliveredAt"`
+}
+
+// WebhookDelivery records one verdict sent to a webhook
+type WebhookDelivery struct {
+	ID           uint      `json:"id" gorm:"primarykey"`
+	CreatedAt    time.Time `json:"createdAt" gorm:"index"`
+	WebhookID    uint      `json:"webhookId" gorm:"index"`
+	SubmissionID uint      `json:"submissionId"`
+	DeliveryID   string    `json:"deliveryId"` // Sent as X-Goera-Delivery, the same for every attempt
+	Attempts     int       `json:"attempts"`
+	Status       int       `json:"status"` // HTTP status of the last attempt, 0 if it got no response
+	Error        string    `json:"error"`
+	Delivered    bool      `json:"delivered"`
+}
+
+func MigrateWebhook(db *gorm.DB) error {
+	err := db.AutoMigrate(&Webhook{}, &WebhookDelivery{})
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"goera/serve/internal/config"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/endpoints"
+)
+
+// Identity is the account information returned by an OAuth provider
+type Identity struct {
+	ProviderUserID string
+	Username       string
+	Email          string // Only set when the provider reports it as verified
+}
+
+// Provider is an OAuth2 login provider
+type Provider struct {
+	Name          string
+	DisplayName   string
+	Config        *oauth2.Config
+	fetchIdentity func(ctx context.Context, client *http.Client) (*Identity, error)
+}
+
+// FetchIdentity exchanges the authorization code and looks up the user's account
+func (p *Provider) FetchIdentity(ctx context.Context, code string) (*Identity, error) {
+	token, err := p.Config.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("code exchange failed: %w", err)
+	}
+	return p.fetchIdentity(ctx, p.Config.Client(ctx, token))
+}
+
+var providers = map[string]*Provider{}
+
+// Init registers the providers that have client credentials configured
+func Init() {
+	providers = map[string]*Provider{}
+
+	if config.GitHubClientID != "" && config.GitHubClientSecret != "" {
+		providers["github"] = &Provider{
+			Name:        "github",
+			DisplayName: "GitHub",
+			Config: &oauth2.Config{
+				ClientID:     config.GitHubClientID,
+				ClientSecret: config.GitHubClientSecret,
+				Endpoint:     endpoints.GitHub,
+				RedirectURL:  config.OAuthRedirectBaseURL + "/auth/github/callback",
+				Scopes:       []string{"read:user", "user:email"},
+			},
+			fetchIdentity: fetchGitHubIdentity,
+		}
+	}
+
+	if config.GoogleClientID != "" && config.GoogleClientSecret != "" {
+		providers["google"] = &Provider{
+			Name:        "google",
+			DisplayName: "Google",
+			Config: &oauth2.Config{
+				ClientID:     config.GoogleClientID,
+				ClientSecret: config.GoogleClientSecret,
+				Endpoint:     endpoints.Google,
+				RedirectURL:  config.OAuthRedirectBaseURL + "/auth/google/callback",
+				Scopes:       []string{"openid", "email", "profile"},
+			},
+			fetchIdentity: fetchGoogleIdentity,
+		}
+	}
+}
+
+// Get returns a configured provider by name
+func Get(name string) (*Provider, bool) {
+	p, ok := providers[name]
+	return p, ok
+}
+
+// Enabled returns the configured providers in a stable order for display
+func Enabled() []*Provider {
+	var enabled []*Provider
+	for _, name := range []string{"github", "google"} {
+		if p, ok := providers[name]; ok {
+			enabled = append(enabled, p)
+		}
+	}
+	return enabled
+}
+
+func getJSON(client *http.Client, url string, target interface{}) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+func fetchGitHubIdentity(ctx context.Context, client *http.Client) (*Identity, error) {
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+	}
+	if err := getJSON(client, "https://api.github.com/user", &user); err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return nil, errors.New("github returned no user ID")
+	}
+
+	identity := &Identity{
+		ProviderUserID: strconv.FormatInt(user.ID, 10),
+		Username:       user.Login,
+	}
+
+	// The profile email may be unverified, so look for the verified primary address
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(client, "https://api.github.com/user/emails", &emails); err == nil {
+		for _, e := range emails {
+			if e.Primary && e.Verified {
+				identity.Email = e.Email
+				break
+			}
+		}
+	}
+
+	return identity, nil
+}
+
+func fetchGoogleIdentity(ctx context.Context, client *http.Client) (*Identity, error) {
+	var user struct {
+		Sub           string `json:"sub"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name"`
+	}
+	if err := getJSON(client, "https://openidconnect.googleapis.com/v1/userinfo", &user); err != nil {
+		return nil, err
+	}
+	if user.Sub == "" {
+		return nil, errors.New("google returned no subject")
+	}
+
+	identity := &Identity{
+		ProviderUserID: user.Sub,
+		Username:       user.Name,
+	}
+	if user.EmailVerified {
+		identity.Email = user.Email
+	}
+	return identity, nil
+}
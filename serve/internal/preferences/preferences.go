@@ -0,0 +1,99 @@
+// Package preferences loads the preferences of the signed in user for each
+// request, so pages can be rendered with them.
+package preferences
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"goera/serve/internal/auth"
+	"goera/serve/internal/cache"
+	"goera/serve/internal/database"
+	"goera/serve/internal/i18n"
+	"goera/serve/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type contextKey string
+
+const preferencesKey contextKey = "preferences"
+
+// cacheKey is the cache key of a user's preferences
+func cacheKey(userID uint) string {
+	return fmt.Sprintf("preferences:%d", userID)
+}
+
+// Load returns the preferences of userID, the defaults if they never saved any
+func Load(ctx context.Context, db *gorm.DB, userID uint) (models.UserPreferences, error) {
+	var prefs models.UserPreferences
+	if cache.GetJSON(ctx, cacheKey(userID), &prefs) {
+		return prefs, nil
+	}
+
+	err := db.Where("user_id = ?", userID).First(&prefs).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		prefs = models.DefaultUserPreferences(userID)
+	} else if err != nil {
+		return models.DefaultUserPreferences(userID), err
+	}
+	cache.SetJSON(ctx, cacheKey(userID), prefs)
+	return prefs, nil
+}
+
+// Invalidate drops the cached preferences of userID after they changed
+func Invalidate(ctx context.Context, userID uint) {
+	cache.Delete(ctx, cacheKey(userID))
+}
+
+// Middleware stores the signed in user's preferences in the request context.
+// Their locale replaces the one from the Accept-Language header, but not one
+// picked with the lang parameter or cookie. It belongs after the auth
+// middleware.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := auth.UserIDFromContext(r.Context())
+		db := database.GetDB()
+		if !ok || db == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		prefs, err := Load(r.Context(), db, userID)
+		if err != nil {
+			// Preferences only change how pages look, so carry on with the defaults
+			log.Printf("Database error loading preferences: %v", err)
+		}
+		ctx := context.WithValue(r.Context(), preferencesKey, prefs)
+		if prefs.Locale != "" && i18n.IsSupported(prefs.Locale) && !i18n.Chosen(r) {
+			ctx = i18n.WithLocale(ctx, prefs.Locale)
+			w.Header().Set("Content-Language", prefs.Locale)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// FromContext returns the preferences of the request, the defaults for
+// visitors who are not signed in
+func FromContext(ctx context.Context) models.UserPreferences {
+	if prefs, ok := ctx.Value(preferencesKey).(models.UserPreferences); ok {
+		return prefs
+	}
+	return models.DefaultUserPreferences(0)
+}
+
+// Location returns the timezone of prefs, UTC if it is not a known zone
+func Location(prefs models.UserPreferences) *time.Location {
+	if prefs.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(prefs.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
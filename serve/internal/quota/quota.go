@@ -0,0 +1,173 @@
+// Package quota counts each user's API calls per UTC day and enforces the
+// daily quotas of the expensive ones in config.DailyQuotas. Unlike rate
+// limits, which smooth out bursts, quotas cap how much judging a user may ask
+// for in a day, and the counts are kept in the database so users and admins
+// can see them.
+package quota
+
+import (
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/config"
+	"goera/serve/internal/database"
+	"goera/serve/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// KindOther counts the API calls that have no quota
+const KindOther = "other"
+
+// Kind returns the kind of API call r is
+func Kind(r *http.Request) string {
+	if r.Method != http.MethodPost {
+		return KindOther
+	}
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	switch {
+	case path == "/api/submissions":
+		return "submissions"
+	case path == "/api/run", path == "/api/compile-check":
+		return "runs"
+	case strings.HasPrefix(path, "/api/questions/") && strings.HasSuffix(path, "/stress"):
+		return "stress_tests"
+	default:
+		return KindOther
+	}
+}
+
+// Day returns the UTC day t falls on, as stored with usage counts
+func Day(t time.Time) string {
+	return t.UTC().Format(time.DateOnly)
+}
+
+// ResetAt returns when the quotas of the day t falls on are renewed
+func ResetAt(t time.Time) time.Time {
+	year, month, day := t.UTC().Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, time.UTC)
+}
+
+// Limits returns the daily quotas of userID, with the ones an admin set for
+// them in place of the defaults
+func Limits(db *gorm.DB, userID uint) (map[string]int, error) {
+	limits := make(map[string]int, len(config.DailyQuotas))
+	for kind, limit := range config.DailyQuotas {
+		limits[kind] = limit
+	}
+
+	var overrides []models.UserQuota
+	if err := db.Where("user_id = ?", userID).Find(&overrides).Error; err != nil {
+		return nil, err
+	}
+	for _, override := range overrides {
+		if _, ok := limits[override.Kind]; ok {
+			limits[override.Kind] = override.DailyLimit
+		}
+	}
+	return limits, nil
+}
+
+// Used returns how many calls of kind userID made on day, with every token
+func Used(db *gorm.DB, userID uint, kind, day string) (int64, error) {
+	var used int64
+	err := db.Model(&models.APIUsage{}).
+		Where("user_id = ? AND day = ? AND kind = ?", userID, day, kind).
+		Select("COALESCE(SUM(calls), 0)").Scan(&used).Error
+	return used, err
+}
+
+// Record counts a call of kind by userID, made with tokenID or with a login
+// session when it is 0
+func Record(db *gorm.DB, userID, tokenID uint, kind string, now time.Time) error {
+	usage := models.APIUsage{UserID: userID, TokenID: tokenID, Day: Day(now), Kind: kind, Calls: 1, UpdatedAt: now}
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "token_id"}, {Name: "day"}, {Name: "kind"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"calls":      gorm.Expr("api_usages.calls + 1"),
+			"updated_at": now,
+		}),
+	}).Create(&usage).Error
+}
+
+// errExhausted is returned by take when the quota of the day is used up
+var errExhausted = errors.New("daily quota exhausted")
+
+// take checks the quota of kind for userID and counts the call when it is
+// allowed. It returns the quota, 0 when there is none, and how many calls
+// remain after this one.
+func take(db *gorm.DB, userID, tokenID uint, kind string, now time.Time) (int, int64, error) {
+	limit := 0
+	if _, limited := config.DailyQuotas[kind]; limited {
+		limits, err := Limits(db, userID)
+		if err != nil {
+			return 0, 0, err
+		}
+		limit = limits[kind]
+	}
+
+	var remaining int64
+	if limit > 0 {
+		used, err := Used(db, userID, kind, Day(now))
+		if err != nil {
+			return 0, 0, err
+		}
+		if used >= int64(limit) {
+			return limit, 0, errExhausted
+		}
+		remaining = int64(limit) - used - 1
+	}
+
+	if err := Record(db, userID, tokenID, kind, now); err != nil {
+		return 0, 0, err
+	}
+	return limit, remaining, nil
+}
+
+// Middleware counts the API calls of signed in users and refuses the ones
+// over their daily quota. It must run after auth.Middleware. Anonymous
+// requests are left to the rate limits, and when the database fails requests
+// are let through.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := auth.UserIDFromContext(r.Context())
+		db := database.GetDB()
+		if !ok || db == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		tokenID, _ := auth.APITokenIDFromContext(r.Context())
+
+		now := time.Now()
+		kind := Kind(r)
+		limit, remaining, err := take(db, userID, tokenID, kind, now)
+		if err != nil && !errors.Is(err, errExhausted) {
+			log.Printf("Quota check failed, allowing request: %v", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if limit > 0 {
+			reset := ResetAt(now)
+			w.Header().Set("X-Quota-Limit", strconv.Itoa(limit))
+			w.Header().Set("X-Quota-Remaining", strconv.FormatInt(remaining, 10))
+			w.Header().Set("X-Quota-Reset", strconv.FormatInt(reset.Unix(), 10))
+			if err != nil {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(reset.Sub(now).Seconds()))))
+				apierror.WriteDetails(w, r, "Daily quota used up, it renews at midnight UTC", http.StatusTooManyRequests,
+					map[string]any{"kind": kind, "limit": limit, "resetAt": reset})
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"goera/serve/internal/config"
+)
+
+// sweepInterval is how often full buckets are dropped from a MemoryStore
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // When the bucket refills completely and can be dropped
+}
+
+// MemoryStore keeps token buckets in the memory of this process
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*bucket{}, lastSweep: time.Now()}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, rule config.RateLimit) (Result, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		for k, b := range s.buckets {
+			if !now.Before(b.full) {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	limit := float64(rule.Limit)
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: limit, updated: now}
+		s.buckets[key] = b
+	}
+	elapsed := now.Sub(b.updated)
+	b.tokens = math.Min(limit, b.tokens+limit*float64(elapsed)/float64(rule.Window))
+	b.updated = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	res := bucketResult(allowed, b.tokens, rule, now)
+	b.full = res.Reset
+	return res, nil
+}
@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/config"
+	"goera/serve/internal/utils"
+)
+
+// routeGroup returns the rate limit group of an API request, or "" for
+// requests that are not rate limited
+func routeGroup(r *http.Request) string {
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/api/login":
+		return "login"
+	case r.Method == http.MethodPost && r.URL.Path == "/api/register":
+		return "register"
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		return "api_read"
+	default:
+		return ""
+	}
+}
+
+// Middleware limits requests of each route group in config.RateLimits per
+// user, or per IP address for anonymous requests. It must run after
+// auth.Middleware. When the store fails, requests are let through.
+func Middleware(store Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			group := routeGroup(r)
+			rule, ok := config.RateLimits[group]
+			if !ok || rule.Limit == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := "ip:" + utils.ClientIP(r)
+			if userID, ok := auth.UserIDFromContext(r.Context()); ok {
+				key = fmt.Sprintf("user:%d", userID)
+			}
+
+			res, err := store.Take(r.Context(), group+":"+key, rule)
+			if err != nil {
+				log.Printf("Rate limit check failed, allowing request: %v", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(rule.Limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(res.Reset.Unix(), 10))
+			if !res.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(res.RetryAfter.Seconds()))))
+				apierror.Write(w, r, "Too many requests, try again later", http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
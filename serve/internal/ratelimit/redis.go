@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"goera/serve/internal/config"
+	"goera/serve/internal/redis"
+)
+
+// redisKeyPrefix namespaces the buckets in a shared Redis
+const redisKeyPrefix = "goera:ratelimit:"
+
+// takeScript is the token bucket of MemoryStore, run atomically in Redis. The
+// time comes from the caller so all instances refill buckets the same way
+// regardless of the Redis version. Tokens are returned as a string because
+// Lua numbers are truncated to integers in replies.
+const takeScript = `
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(state[1])
+local updated = tonumber(state[2])
+if tokens == nil or updated == nil then
+	tokens = limit
+	updated = now
+end
+tokens = math.min(limit, tokens + limit * math.max(0, now - updated) / window)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', tostring(now))
+redis.call('PEXPIRE', KEYS[1], window)
+return {allowed, tostring(tokens)}
+`
+
+// RedisStore keeps token buckets in Redis, so they are shared by every serve
+// instance
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore returns a RedisStore for the Redis server at addr. Connections
+// are opened on first use.
+func NewRedisStore(addr, password string, db int) *RedisStore {
+	return &RedisStore{client: redis.NewClient(addr, password, db)}
+}
+
+func (s *RedisStore) Take(ctx context.Context, key string, rule config.RateLimit) (Result, error) {
+	now := time.Now()
+	reply, err := s.client.Do(ctx, "EVAL", takeScript, "1", redisKeyPrefix+key,
+		strconv.Itoa(rule.Limit),
+		strconv.FormatInt(rule.Window.Milliseconds(), 10),
+		strconv.FormatInt(now.UnixMilli(), 10))
+	if err != nil {
+		return Result{}, err
+	}
+
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 2 {
+		return Result{}, fmt.Errorf("unexpected rate limit script reply %v", reply)
+	}
+	allowed, _ := values[0].(int64)
+	tokensStr, _ := values[1].(string)
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("unexpected token count %q: %w", tokensStr, err)
+	}
+	return bucketResult(allowed == 1, tokens, rule, now), nil
+}
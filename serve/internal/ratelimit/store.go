@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"goera/serve/internal/config"
+)
+
+// Result is the state of a token bucket after taking a token from it
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	Reset      time.Time     // When the bucket is full again
+	RetryAfter time.Duration // How long until a token is available, when not allowed
+}
+
+// Store keeps the token buckets. Take removes one token from the bucket of
+// key, which holds up to rule.Limit tokens and refills completely over
+// rule.Window.
+type Store interface {
+	Take(ctx context.Context, key string, rule config.RateLimit) (Result, error)
+}
+
+// NewStore returns the store for config.RateLimitBackend. A Redis store falls
+// back to memory while Redis cannot be reached.
+func NewStore() Store {
+	memory := NewMemoryStore()
+	if config.RateLimitBackend != "redis" {
+		return memory
+	}
+	return &fallbackStore{
+		primary:  NewRedisStore(config.RedisAddr, config.RedisPassword, config.RedisDB),
+		fallback: memory,
+	}
+}
+
+// bucketResult computes the Result for a bucket holding tokens after a take
+func bucketResult(allowed bool, tokens float64, rule config.RateLimit, now time.Time) Result {
+	perToken := rule.Window / time.Duration(rule.Limit)
+	res := Result{
+		Allowed:   allowed,
+		Remaining: int(tokens),
+		Reset:     now.Add(time.Duration((float64(rule.Limit) - tokens) * float64(perToken))),
+	}
+	if !allowed {
+		res.RetryAfter = time.Duration((1 - tokens) * float64(perToken))
+	}
+	return res
+}
+
+// fallbackStore uses primary, and fallback for as long as primary fails
+type fallbackStore struct {
+	primary  Store
+	fallback Store
+
+	mu      sync.Mutex
+	failing bool
+}
+
+func (s *fallbackStore) Take(ctx context.Context, key string, rule config.RateLimit) (Result, error) {
+	res, err := s.primary.Take(ctx, key, rule)
+
+	s.mu.Lock()
+	if err != nil && !s.failing {
+		log.Printf("Rate limit store failed, counting in memory until it recovers: %v", err)
+	} else if err == nil && s.failing {
+		log.Println("Rate limit store recovered")
+	}
+	s.failing = err != nil
+	s.mu.Unlock()
+
+	if err != nil {
+		return s.fallback.Take(ctx, key, rule)
+	}
+	return res, nil
+}
@@ -0,0 +1,187 @@
+// Package recovery turns panics in HTTP handlers into 500 responses, logs them
+// with their stack trace and request ID, and hands them to an optional
+// Reporter such as Sentry.
+package recovery
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"log"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/config"
+	"goera/serve/internal/i18n"
+)
+
+// Event describes a recovered panic
+type Event struct {
+	Time      time.Time
+	RequestID string
+	Method    string
+	URL       string
+	Value     interface{} // The value passed to panic
+	Stack     []byte
+}
+
+// Message returns the panic value as text
+func (e Event) Message() string {
+	if err, ok := e.Value.(error); ok {
+		return err.Error()
+	}
+	return fmt.Sprint(e.Value)
+}
+
+// Reporter forwards recovered panics to an error tracking service. Report is
+// called on its own goroutine, after the response was written.
+type Reporter interface {
+	Report(event Event)
+}
+
+var (
+	reporterMu sync.RWMutex
+	reporter   Reporter
+)
+
+// SetReporter sets where panics are reported, or turns reporting off with nil
+func SetReporter(r Reporter) {
+	reporterMu.Lock()
+	defer reporterMu.Unlock()
+	reporter = r
+}
+
+// Init sets up the reporter from the configuration: Sentry when SentryDSN is
+// set, nothing otherwise
+func Init() error {
+	if config.SentryDSN == "" {
+		SetReporter(nil)
+		return nil
+	}
+	sentry, err := NewSentryReporter(config.SentryDSN, config.SentryEnvironment)
+	if err != nil {
+		return err
+	}
+	SetReporter(sentry)
+	return nil
+}
+
+// Middleware recovers panics in the handlers it wraps. API routes answer with
+// the usual JSON error and pages with a plain error page, both carrying the
+// request ID so the logs can be found. It belongs after the request ID
+// middleware and the locale middleware.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tracked := &trackingWriter{ResponseWriter: w}
+		defer func() {
+			value := recover()
+			if value == nil {
+				return
+			}
+			// ErrAbortHandler aborts the response on purpose and is not a crash
+			if value == http.ErrAbortHandler {
+				panic(value)
+			}
+
+			event := Event{
+				Time:      time.Now(),
+				RequestID: apierror.RequestIDFromContext(r.Context()),
+				Method:    r.Method,
+				URL:       r.URL.String(),
+				Value:     value,
+				Stack:     debug.Stack(),
+			}
+			log.Printf("Panic serving %s %s [request %s]: %s\n%s", event.Method, event.URL, event.RequestID, event.Message(), event.Stack)
+
+			reporterMu.RLock()
+			current := reporter
+			reporterMu.RUnlock()
+			if current != nil {
+				go current.Report(event)
+			}
+
+			// Part of a response may have gone out already, then there is no
+			// way to change the status
+			if tracked.wroteHeader {
+				return
+			}
+			if strings.HasPrefix(r.URL.Path, "/api/") {
+				apierror.Write(w, r, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			writeErrorPage(w, r, event.RequestID)
+		}()
+		next.ServeHTTP(tracked, r)
+	})
+}
+
+// writeErrorPage answers a page request with a minimal HTML error page. It does
+// not use the templates, which may be what failed.
+func writeErrorPage(w http.ResponseWriter, r *http.Request, requestID string) {
+	locale := i18n.FromContext(r.Context())
+	title := i18n.T(locale, "Something went wrong")
+
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<html lang="%s" dir="%s">
+<head><meta charset="UTF-8"><title>%s</title></head>
+<body>
+<h1>%s</h1>
+<p>%s</p>
+<p>%s</p>
+<p><a href="/">%s</a></p>
+</body>
+</html>
+`,
+		html.EscapeString(locale), i18n.Dir(locale), html.EscapeString(title), html.EscapeString(title),
+		html.EscapeString(i18n.T(locale, "The error was logged. Please try again later.")),
+		html.EscapeString(i18n.T(locale, "Request ID: %s", requestID)),
+		html.EscapeString(i18n.T(locale, "Home")))
+}
+
+// trackingWriter records whether the response has started
+type trackingWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *trackingWriter) WriteHeader(status int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *trackingWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush lets streaming handlers, such as server-sent events, flush through
+func (w *trackingWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.wroteHeader = true
+		flusher.Flush()
+	}
+}
+
+// Hijack lets WebSocket handlers take over the connection
+func (w *trackingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.wroteHeader = true
+	}
+	return conn, rw, err
+}
+
+// Unwrap gives http.ResponseController access to the underlying writer
+func (w *trackingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
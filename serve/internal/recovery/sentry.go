@@ -0,0 +1,114 @@
+package recovery
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// sentryTimeout bounds each report, so a slow Sentry cannot pile up goroutines
+const sentryTimeout = 5 * time.Second
+
+// SentryReporter sends panics to Sentry through its HTTP store endpoint
+type SentryReporter struct {
+	endpoint    string
+	publicKey   string
+	environment string
+	client      *http.Client
+}
+
+// NewSentryReporter returns a reporter for the project named by dsn, in the
+// form https://<public key>@<host>/<project ID>
+func NewSentryReporter(dsn, environment string) (*SentryReporter, error) {
+	u, err := url.Parse(dsn)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User == nil {
+		return nil, fmt.Errorf("invalid Sentry DSN")
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	slash := strings.LastIndex(path, "/")
+	projectID := path[slash+1:]
+	if projectID == "" {
+		return nil, fmt.Errorf("invalid Sentry DSN: no project ID")
+	}
+
+	return &SentryReporter{
+		endpoint:    fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, path[:slash], projectID),
+		publicKey:   u.User.Username(),
+		environment: environment,
+		client:      &http.Client{Timeout: sentryTimeout},
+	}, nil
+}
+
+// sentryEvent is the subset of the Sentry event payload that is filled in
+type sentryEvent struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Platform    string            `json:"platform"`
+	Level       string            `json:"level"`
+	Logger      string            `json:"logger"`
+	Environment string            `json:"environment,omitempty"`
+	Message     string            `json:"message"`
+	Tags        map[string]string `json:"tags"`
+	Request     struct {
+		Method string `json:"method"`
+		URL    string `json:"url"`
+	} `json:"request"`
+	Extra map[string]string `json:"extra"`
+}
+
+// Report sends event to Sentry. Failures are logged, the panic itself is
+// already in the server log.
+func (s *SentryReporter) Report(event Event) {
+	payload := sentryEvent{
+		EventID:     newEventID(),
+		Timestamp:   event.Time.UTC().Format(time.RFC3339),
+		Platform:    "go",
+		Level:       "error",
+		Logger:      "goera.serve",
+		Environment: s.environment,
+		Message:     event.Message(),
+		Tags:        map[string]string{"request_id": event.RequestID},
+		Extra:       map[string]string{"stack": string(event.Stack)},
+	}
+	payload.Request.Method = event.Method
+	payload.Request.URL = event.URL
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Failed to encode Sentry event: %v", err)
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Failed to report panic to Sentry: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", fmt.Sprintf("Sentry sentry_version=7, sentry_client=goera-serve/1.0, sentry_key=%s", s.publicKey))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		log.Printf("Failed to report panic to Sentry: %v", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Sentry rejected the panic report: %s", resp.Status)
+	}
+}
+
+// newEventID returns a random Sentry event ID, 32 hex digits
+func newEventID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,181 @@
+// Package redis is a small Redis client, speaking just enough of the Redis
+// protocol for the rate limiter and the cache
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	// Timeout bounds each Redis command, so a hung Redis only delays requests
+	// until the callers' fallbacks take over
+	Timeout = 500 * time.Millisecond
+	// poolSize is the number of idle connections kept open
+	poolSize = 8
+)
+
+// Client runs commands on a pool of connections to one Redis server
+type Client struct {
+	addr     string
+	password string
+	db       int
+	pool     chan *conn
+}
+
+// NewClient returns a Client for the Redis server at addr. Connections are
+// opened on first use.
+func NewClient(addr, password string, db int) *Client {
+	return &Client{
+		addr:     addr,
+		password: password,
+		db:       db,
+		pool:     make(chan *conn, poolSize),
+	}
+}
+
+// Do runs a command on a pooled connection and returns its reply. Bulk
+// strings are returned as string, integers as int64, arrays as []interface{}
+// and a missing value as nil. Connections that fail are closed rather than
+// returned to the pool.
+func (c *Client) Do(ctx context.Context, args ...string) (interface{}, error) {
+	cn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := cn.do(ctx, args...)
+	var replyErr Error
+	if err != nil && !errors.As(err, &replyErr) {
+		cn.Close()
+		return nil, err
+	}
+
+	select {
+	case c.pool <- cn:
+	default:
+		cn.Close()
+	}
+	return reply, err
+}
+
+// get returns an idle connection or dials a new one
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	select {
+	case cn := <-c.pool:
+		return cn, nil
+	default:
+	}
+
+	dialer := net.Dialer{Timeout: Timeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Redis at %s: %w", c.addr, err)
+	}
+	cn := &conn{Conn: netConn, reader: bufio.NewReader(netConn)}
+
+	if c.password != "" {
+		if _, err := cn.do(ctx, "AUTH", c.password); err != nil {
+			cn.Close()
+			return nil, fmt.Errorf("failed to authenticate to Redis: %w", err)
+		}
+	}
+	if c.db != 0 {
+		if _, err := cn.do(ctx, "SELECT", strconv.Itoa(c.db)); err != nil {
+			cn.Close()
+			return nil, fmt.Errorf("failed to select Redis database %d: %w", c.db, err)
+		}
+	}
+	return cn, nil
+}
+
+// Error is an error reply from Redis. The connection is still usable.
+type Error string
+
+func (e Error) Error() string {
+	return "redis: " + string(e)
+}
+
+type conn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// do sends a command and reads its reply
+func (c *conn) do(ctx context.Context, args ...string) (interface{}, error) {
+	deadline := time.Now().Add(Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	c.SetDeadline(deadline)
+
+	cmd := fmt.Sprintf("*%d\r\n", len(args))
+	for _, arg := range args {
+		cmd += fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := c.Write([]byte(cmd)); err != nil {
+		return nil, err
+	}
+	return c.readReply()
+}
+
+// readReply reads one reply
+func (c *conn) readReply() (interface{}, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("malformed Redis reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, Error(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.reader, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		// Read every element even past an error reply, to keep the
+		// connection in sync
+		values := make([]interface{}, n)
+		var replyErr error
+		for i := range values {
+			values[i], err = c.readReply()
+			var elemErr Error
+			if errors.As(err, &elemErr) {
+				replyErr = err
+			} else if err != nil {
+				return nil, err
+			}
+		}
+		return values, replyErr
+	default:
+		return nil, fmt.Errorf("unknown Redis reply type %q", kind)
+	}
+}
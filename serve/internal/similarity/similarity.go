@@ -0,0 +1,119 @@
+// Package similarity fingerprints source code with winnowing so submissions
+// can be compared for plagiarism regardless of renamed identifiers, changed
+// literals, comments or formatting.
+package similarity
+
+import (
+	"go/scanner"
+	"go/token"
+	"hash/fnv"
+)
+
+const (
+	// kgramSize is the number of consecutive tokens hashed together. Shorter
+	// matches are treated as noise.
+	kgramSize = 5
+	// windowSize is the winnowing window. Any match of at least
+	// windowSize+kgramSize-1 tokens is guaranteed to be detected.
+	windowSize = 4
+)
+
+// Fingerprint is the set of winnowed k-gram hashes of a program
+type Fingerprint map[uint64]struct{}
+
+// Tokenize splits source code into normalized tokens. Identifiers and literals
+// are replaced by their kind so renaming variables or changing constants does
+// not hide copied code. Comments and whitespace are dropped.
+func Tokenize(code string) []string {
+	src := []byte(code)
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+
+	var s scanner.Scanner
+	// Syntax errors are ignored, a best effort token stream is enough here
+	s.Init(file, src, nil, 0)
+
+	var tokens []string
+	for {
+		_, tok, _ := s.Scan()
+		switch {
+		case tok == token.EOF:
+			return tokens
+		case tok == token.SEMICOLON:
+			// Automatically inserted semicolons only mirror line breaks
+			continue
+		case tok == token.IDENT:
+			tokens = append(tokens, "ID")
+		default:
+			// Literals collapse to their kind, e.g. INT or STRING
+			tokens = append(tokens, tok.String())
+		}
+	}
+}
+
+// Compute returns the winnowed fingerprint of source code
+func Compute(code string) Fingerprint {
+	tokens := Tokenize(code)
+	fingerprint := Fingerprint{}
+	if len(tokens) < kgramSize {
+		if len(tokens) > 0 {
+			fingerprint[hashTokens(tokens)] = struct{}{}
+		}
+		return fingerprint
+	}
+
+	hashes := make([]uint64, len(tokens)-kgramSize+1)
+	for i := range hashes {
+		hashes[i] = hashTokens(tokens[i : i+kgramSize])
+	}
+
+	if len(hashes) <= windowSize {
+		fingerprint[minHash(hashes)] = struct{}{}
+		return fingerprint
+	}
+
+	// Keep the minimum hash of every window, preferring the rightmost on ties,
+	// so identical regions select identical fingerprints
+	for start := 0; start+windowSize <= len(hashes); start++ {
+		fingerprint[minHash(hashes[start:start+windowSize])] = struct{}{}
+	}
+	return fingerprint
+}
+
+// Score returns the Jaccard similarity of two fingerprints, from 0 for
+// unrelated code to 1 for code that is identical after normalization
+func Score(a, b Fingerprint) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+
+	shared := 0
+	for h := range a {
+		if _, ok := b[h]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+func hashTokens(tokens []string) uint64 {
+	h := fnv.New64a()
+	for _, t := range tokens {
+		h.Write([]byte(t))
+		h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
+
+func minHash(hashes []uint64) uint64 {
+	m := hashes[0]
+	for _, h := range hashes[1:] {
+		if h <= m {
+			m = h
+		}
+	}
+	return m
+}
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"goera/serve/internal/config"
+)
+
+// URLPrefix is the path serve hands out the files of a LocalStore under
+const URLPrefix = "/storage/"
+
+// LocalStore keeps files in a directory on serve's disk. Its links point
+// at Handler on serve and are signed with the internal HMAC key.
+type LocalStore struct {
+	dir     string
+	urlBase string
+}
+
+// NewLocalStore returns a store keeping files under dir, linked to through urlBase
+func NewLocalStore(dir, urlBase string) *LocalStore {
+	return &LocalStore{dir: dir, urlBase: strings.TrimSuffix(urlBase, "/")}
+}
+
+// path returns the file of key, refusing keys that would leave the directory
+func (s *LocalStore) path(key string) (string, error) {
+	if key == "" || path.Clean("/"+key) != "/"+key {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+// Put implements Store. The file is written aside and renamed, so a reader
+// never sees half of it.
+func (s *LocalStore) Put(ctx context.Context, key string, data []byte) error {
+	file, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// Get implements Store
+func (s *LocalStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	file, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// URL implements Store
+func (s *LocalStore) URL(key string, ttl time.Duration) (string, error) {
+	secret, ok := config.InternalKeys[config.InternalKeyID]
+	if !ok {
+		return "", errors.New("no internal signing key configured")
+	}
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	query := url.Values{
+		"key_id":    {config.InternalKeyID},
+		"expires":   {expires},
+		"signature": {linkSignature(secret, key, expires)},
+	}
+	return s.urlBase + URLPrefix + key + "?" + query.Encode(), nil
+}
+
+// linkSignature signs the key and expiry of a download link
+func linkSignature(secret, key, expires string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "GET\n%s%s\n%s", URLPrefix, key, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Handler serves the files of the local store to holders of a link from
+// URL. Anything else, including expired links, is not found.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	local, ok := store.(*LocalStore)
+	if !ok || r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, URLPrefix)
+	query := r.URL.Query()
+	secret, known := config.InternalKeys[query.Get("key_id")]
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if !known || err != nil || time.Now().Unix() > expires ||
+		!hmac.Equal([]byte(linkSignature(secret, key, query.Get("expires"))), []byte(query.Get("signature"))) {
+		http.NotFound(w, r)
+		return
+	}
+
+	file, err := local.path(key)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeFile(w, r, file)
+}
@@ -0,0 +1,96 @@
+// Package storage keeps large payloads, such as test case data and judge
+// logs, in files instead of database rows. Rows only hold a reference to the
+// file, and code-runners download test data themselves over links that
+// expire, so it never has to pass through serve and the judge.
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"goera/serve/internal/config"
+)
+
+// ErrNotFound is returned for a key that has no file
+var ErrNotFound = errors.New("file not found")
+
+// Store keeps files by slash separated key. Files are never removed: they
+// are shared by every row with the same content, including soft deleted ones.
+type Store interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// URL returns a link anyone holding it can download the file from
+	// until ttl has passed
+	URL(key string, ttl time.Duration) (string, error)
+}
+
+// store is the Store of config.StorageBackend, set up by Init. It is nil for
+// the database backend.
+var store Store
+
+// Init sets up the store for config.StorageBackend
+func Init() error {
+	switch config.StorageBackend {
+	case "local":
+		store = NewLocalStore(config.StorageDir, config.StorageURLBase)
+	case "s3":
+		s3, err := NewS3Store(config.S3Endpoint, config.S3Bucket, config.S3Region, config.S3AccessKey, config.S3SecretKey)
+		if err != nil {
+			return err
+		}
+		store = s3
+	default:
+		store = nil
+	}
+	return nil
+}
+
+// Offload moves data of the given kind, e.g. testcases, to file storage when
+// it is over the inline limit, returning its reference. It returns "" when
+// data stays in the database. Files are named after the SHA-256 of their
+// content, so the same data is only stored once and rows copying a
+// reference, such as revisions and clones, never need a copy of the file.
+func Offload(ctx context.Context, kind string, data string) (string, error) {
+	if store == nil || len(data) <= config.StorageInlineLimit {
+		return "", nil
+	}
+	sum := sha256.Sum256([]byte(data))
+	ref := kind + "/" + hex.EncodeToString(sum[:])
+	if err := store.Put(ctx, ref, []byte(data)); err != nil {
+		return "", fmt.Errorf("failed to store %s: %w", ref, err)
+	}
+	return ref, nil
+}
+
+// Load reads the data of a reference returned by Offload
+func Load(ctx context.Context, ref string) (string, error) {
+	if store == nil {
+		return "", fmt.Errorf("failed to load %s: file storage is not configured", ref)
+	}
+	body, err := store.Get(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("failed to load %s: %w", ref, err)
+	}
+	defer body.Close()
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, body); err != nil {
+		return "", fmt.Errorf("failed to load %s: %w", ref, err)
+	}
+	return buf.String(), nil
+}
+
+// URL returns a download link for a reference returned by Offload, valid for
+// config.StorageURLTTL
+func URL(ref string) (string, error) {
+	if store == nil {
+		return "", fmt.Errorf("failed to link %s: file storage is not configured", ref)
+	}
+	return store.URL(ref, config.StorageURLTTL)
+}
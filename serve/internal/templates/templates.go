@@ -0,0 +1,245 @@
+package templates
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"goera/serve/internal/banners"
+	"goera/serve/internal/i18n"
+	"goera/serve/internal/models"
+	"goera/serve/internal/preferences"
+	"goera/serve/web"
+)
+
+// pages lists every page template. Each page is a standalone HTML document.
+var pages = []string{
+	"index.html",
+	"login.html",
+	"signup.html",
+	"questions.html",
+	"question.html",
+	"questionPrint.html",
+	"questionCreatorForm.html",
+	"questionEditForm.html",
+	"submissionPage.html",
+	"submissionDetail.html",
+	"profile.html",
+	"adminDashboard.html",
+	"notifications.html",
+	"collections.html",
+	"collection.html",
+	"maintenance.html",
+}
+
+// printPages are pages meant for paper, which get no banners
+var printPages = map[string]bool{
+	"questionPrint.html": true,
+}
+
+// funcs are the helper functions available to every template
+var funcs = template.FuncMap{
+	"sub": func(a, b int) int { return a - b },
+	"add": func(a, b int) int { return a + b },
+	"mul": func(a, b int) int { return a * b },
+	"min": func(a int, b int64) int64 {
+		if int64(a) < b {
+			return int64(a)
+		}
+		return b
+	},
+	"bytes": func(n uint64) string {
+		switch {
+		case n >= 1024*1024:
+			return fmt.Sprintf("%.1f MB", float64(n)/(1024*1024))
+		case n >= 1024:
+			return fmt.Sprintf("%.1f KB", float64(n)/1024)
+		default:
+			return fmt.Sprintf("%d B", n)
+		}
+	},
+	"markdown": banners.Markdown,
+	"statusToString": func(s models.JudgeStatus) string {
+		return string(s)
+	},
+	"statusToClass": func(s models.JudgeStatus) string {
+		switch s {
+		case models.Pending:
+			return "pending"
+		case models.Accepted:
+			return "Accepted"
+		case models.CompilationError:
+			return "compile-error"
+		case models.Rejected:
+			return "wrong-answer"
+		case models.MemoryLimitExceeded:
+			return "memory-limit"
+		case models.OutputLimitExceeded:
+			return "output-limit"
+		case models.TimeLimitExceeded:
+			return "time-limit"
+		case models.RuntimeError:
+			return "runtime-error"
+		default:
+			return "unknown"
+		}
+	},
+}
+
+// localeFuncs are the helper functions bound to the locale a template is
+// rendered in: t translates a message, lang and dir fill the lang and dir
+// attributes of the page, rtl reports a right to left locale, and locales and
+// localeName list the locales for a language switch
+func localeFuncs(locale string) template.FuncMap {
+	return template.FuncMap{
+		"t": func(message string, args ...interface{}) string {
+			return i18n.T(locale, message, args...)
+		},
+		"lang":       func() string { return locale },
+		"dir":        func() string { return i18n.Dir(locale) },
+		"rtl":        func() bool { return i18n.Dir(locale) == "rtl" },
+		"locales":    i18n.Locales,
+		"localeName": i18n.Name,
+	}
+}
+
+// preferenceFuncs are the helper functions bound to the preferences of the
+// user a page is rendered for: prefs returns them, for the forms, editorClass
+// and editorStyle style code blocks with the editor settings, and localTime
+// formats a time.Time or *time.Time in their timezone
+func preferenceFuncs(prefs models.UserPreferences) template.FuncMap {
+	loc := preferences.Location(prefs)
+	return template.FuncMap{
+		"prefs":       func() models.UserPreferences { return prefs },
+		"editorClass": func() string { return "editor_" + prefs.EditorTheme },
+		"editorStyle": func() template.CSS {
+			return template.CSS(fmt.Sprintf("font-size: %dpx; tab-size: %d", prefs.EditorFontSize, prefs.EditorTabSize))
+		},
+		"localTime": func(value interface{}, layout string) string {
+			switch t := value.(type) {
+			case time.Time:
+				return t.In(loc).Format(layout)
+			case *time.Time:
+				if t != nil {
+					return t.In(loc).Format(layout)
+				}
+			}
+			return ""
+		},
+	}
+}
+
+var (
+	mu        sync.RWMutex
+	registry  map[string]map[string]*template.Template
+	source    fs.FS = web.Templates
+	devReload bool
+)
+
+// Init parses all page templates once. With reload enabled the templates are
+// read from web/templates on disk and re-parsed on every render, so edits show
+// up without restarting the server.
+func Init(reload bool) error {
+	devReload = reload
+	if reload {
+		source = os.DirFS(web.TemplatesDir)
+	} else {
+		sub, err := fs.Sub(web.Templates, "templates")
+		if err != nil {
+			return err
+		}
+		source = sub
+	}
+
+	parsed, err := parseAll(source)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	registry = parsed
+	mu.Unlock()
+	return nil
+}
+
+// parseAll parses every page once per locale, with the locale's helper
+// functions bound
+func parseAll(fsys fs.FS) (map[string]map[string]*template.Template, error) {
+	parsed := make(map[string]map[string]*template.Template)
+	for _, locale := range i18n.Locales() {
+		parsed[locale] = make(map[string]*template.Template, len(pages))
+		for _, page := range pages {
+			tmpl, err := template.New(page).Funcs(funcs).Funcs(localeFuncs(locale)).
+				Funcs(preferenceFuncs(models.DefaultUserPreferences(0))).ParseFS(fsys, page)
+			if err != nil {
+				return nil, fmt.Errorf("parsing template %s: %w", page, err)
+			}
+			parsed[locale][page] = tmpl
+		}
+	}
+	return parsed, nil
+}
+
+// Render executes a page template in the locale of the request, with the
+// preferences of the signed in user, and puts the banners that are up at the
+// start of its body. The parsed templates are never executed themselves; each
+// render clones one to bind the preferences.
+func Render(w io.Writer, r *http.Request, page string, data interface{}) error {
+	if devReload {
+		parsed, err := parseAll(source)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		registry = parsed
+		mu.Unlock()
+	}
+
+	mu.RLock()
+	parsed, ok := registry[i18n.FromContext(r.Context())][page]
+	mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("template %s is not registered", page)
+	}
+	tmpl, err := parsed.Clone()
+	if err != nil {
+		return err
+	}
+	tmpl.Funcs(preferenceFuncs(preferences.FromContext(r.Context())))
+
+	var out bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&out, page, data); err != nil {
+		return err
+	}
+	if printPages[page] {
+		_, err = w.Write(out.Bytes())
+		return err
+	}
+	_, err = w.Write(withBanners(out.Bytes()))
+	return err
+}
+
+// withBanners inserts the banners that are up right after the <body> tag of
+// a page
+func withBanners(page []byte) []byte {
+	bannerHTML := banners.HTML(time.Now())
+	if bannerHTML == nil {
+		return page
+	}
+	body := bytes.Index(page, []byte("<body"))
+	if body < 0 {
+		return page
+	}
+	end := bytes.IndexByte(page[body:], '>')
+	if end < 0 {
+		return page
+	}
+	at := body + end + 1
+	return append(page[:at:at], append(bannerHTML, page[at:]...)...)
+}
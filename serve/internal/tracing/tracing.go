@@ -0,0 +1,127 @@
+// Package tracing exports OpenTelemetry traces of HTTP requests, database
+// queries and calls to the judge. Trace context travels with the calls to the
+// judge and on to the code-runners, so a submission shows up as one trace from
+// the HTTP request to the sandbox.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/config"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// serviceName identifies serve's spans among those of the judge and code-runners
+const serviceName = "goera-serve"
+
+var tracer = otel.Tracer("goera/serve")
+
+// Init sets up the export of spans to config.TracingEndpoint. The trace
+// context propagator is installed even without an endpoint, so the traces of
+// callers that do export still reach the judge. The returned function flushes
+// the spans not exported yet.
+func Init() (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if config.TracingEndpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	endpoint, err := tracesURL(config.TracingEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(serviceName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.TracingSampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// tracesURL returns the OTLP/HTTP traces endpoint of a collector, which like
+// OTEL_EXPORTER_OTLP_ENDPOINT is given as its base URL
+func tracesURL(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid tracing endpoint: %w", err)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/v1/traces"
+	return u.String(), nil
+}
+
+// Handler starts a span for every request to next, continuing the trace of
+// the caller when the request carries one. Spans are named after the route by
+// Middleware once the router matched one.
+func Handler(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, "serve", otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+		return r.Method
+	}))
+}
+
+// Middleware names the request's span after the matched route and tags it
+// with the request ID. It belongs after the request ID middleware.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span := trace.SpanFromContext(r.Context())
+		if route := mux.CurrentRoute(r); route != nil {
+			if template, err := route.GetPathTemplate(); err == nil {
+				span.SetName(r.Method + " " + template)
+				span.SetAttributes(semconv.HTTPRoute(template))
+			}
+		}
+		span.SetAttributes(attribute.String("goera.request_id", apierror.RequestIDFromContext(r.Context())))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Transport wraps base so requests made with it carry the trace context of
+// their request's context
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(base)
+}
+
+// Start starts a span as a child of the span in ctx, if any
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// Inject returns the trace context of ctx in traceparent form, for storing
+// with work that is picked up later. It is empty outside a sampled trace.
+func Inject(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}
+
+// Extract returns ctx continuing the trace of a traceparent stored by Inject
+func Extract(ctx context.Context, traceparent string) context.Context {
+	if traceparent == "" {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier{"traceparent": traceparent})
+}
@@ -1,108 +1,128 @@
-package utils
-
-import (
-	"encoding/json"
-	"fmt"
-	"io"
-	"log"
-	"net/http"
-	"sync"
-)
-
-type APIClient struct {
-	Client *http.Client
-}
-
-var (
-	instance *APIClient
-	once     sync.Once
-)
-
-func GetAPIClient() *APIClient {
-	once.Do(func() {
-		instance = &APIClient{
-			Client: &http.Client{},
-		}
-	})
-	return instance
-}
-
-func NewAPIClient() *APIClient {
-	return &APIClient{
-		Client: &http.Client{},
-	}
-}
-
-func (a *APIClient) SendRequest(originalRequest *http.Request, path string, method string, body io.Reader, result interface{}) error {
-	scheme := "http"
-	if originalRequest.TLS != nil {
-		scheme = "https"
-	}
-	host := originalRequest.Host
-	url := fmt.Sprintf("%s://%s%s", scheme, host, path)
-
-	req, err := http.NewRequest(method, url, body)
-	if err != nil {
-		log.Printf("Error creating request: %v", err)
-		return fmt.Errorf("error creating request: %v", err)
-	}
-
-	for _, cookie := range originalRequest.Cookies() {
-		req.AddCookie(cookie)
-	}
-
-	if authHeader := originalRequest.Header.Get("Authorization"); authHeader != "" {
-		req.Header.Set("Authorization", authHeader)
-	}
-
-	if method == http.MethodPost || method == http.MethodPut {
-		req.Header.Set("Content-Type", "application/json")
-	}
-
-	resp, err := a.Client.Do(req)
-	if err != nil {
-		log.Printf("Error making API request: %v", err)
-		return fmt.Errorf("error making API request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		log.Printf("API returned non-success status: %d", resp.StatusCode)
-		return fmt.Errorf("API returned status %d", resp.StatusCode)
-	}
-
-	if result != nil {
-		respBody, err := io.ReadAll(resp.Body)
-		if err != nil {
-			log.Printf("Error reading response body: %v", err)
-			return fmt.Errorf("error reading response body: %v", err)
-		}
-
-		if err := json.Unmarshal(respBody, result); err != nil {
-			log.Printf("Error parsing API response: %v", err)
-			return fmt.Errorf("error parsing API response: %v", err)
-		}
-	}
-
-	return nil
-}
-
-// Get sends a GET request to the API
-func (a *APIClient) Get(originalRequest *http.Request, path string, result interface{}) error {
-	return a.SendRequest(originalRequest, path, http.MethodGet, nil, result)
-}
-
-// Post sends a POST request to the API
-func (a *APIClient) Post(originalRequest *http.Request, path string, body io.Reader, result interface{}) error {
-	return a.SendRequest(originalRequest, path, http.MethodPost, body, result)
-}
-
-// Put sends a PUT request to the API
-func (a *APIClient) Put(originalRequest *http.Request, path string, body io.Reader, result interface{}) error {
-	return a.SendRequest(originalRequest, path, http.MethodPut, body, result)
-}
-
-// Delete sends a DELETE request to the API
-func (a *APIClient) Delete(originalRequest *http.Request, path string) error {
-	return a.SendRequest(originalRequest, path, http.MethodDelete, nil, nil)
-}
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+
+	"goera/serve/internal/config"
+	"goera/serve/internal/tracing"
+)
+
+type APIClient struct {
+	Client *http.Client
+}
+
+var (
+	instance *APIClient
+	once     sync.Once
+)
+
+func GetAPIClient() *APIClient {
+	once.Do(func() {
+		instance = &APIClient{
+			Client: &http.Client{Transport: tracing.Transport(http.DefaultTransport)},
+		}
+	})
+	return instance
+}
+
+func NewAPIClient() *APIClient {
+	return &APIClient{
+		Client: &http.Client{Transport: tracing.Transport(http.DefaultTransport)},
+	}
+}
+
+// apiBaseURL is where the page handlers reach the API: this serve's own
+// listener, so that their requests do not pass a reverse proxy that would add
+// to X-Forwarded-For
+func apiBaseURL() string {
+	host, port, err := net.SplitHostPort(config.ServerPort)
+	if err != nil {
+		return "http://localhost" + config.ServerPort
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+func (a *APIClient) SendRequest(originalRequest *http.Request, path string, method string, body io.Reader, result interface{}) error {
+	url := apiBaseURL() + path
+
+	// The request's context carries its trace on to the API
+	req, err := http.NewRequestWithContext(originalRequest.Context(), method, url, body)
+	if err != nil {
+		log.Printf("Error creating request: %v", err)
+		return fmt.Errorf("error creating request: %v", err)
+	}
+	req.Host = originalRequest.Host
+
+	for _, cookie := range originalRequest.Cookies() {
+		req.AddCookie(cookie)
+	}
+
+	if authHeader := originalRequest.Header.Get("Authorization"); authHeader != "" {
+		req.Header.Set("Authorization", authHeader)
+	}
+
+	// Requests are rate limited by IP, which would otherwise be our own. The
+	// header is replaced rather than added to, so the API sees only the
+	// address found for the page request.
+	req.Header.Set("X-Forwarded-For", ClientIP(originalRequest))
+
+	if method == http.MethodPost || method == http.MethodPut {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		log.Printf("Error making API request: %v", err)
+		return fmt.Errorf("error making API request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		log.Printf("API returned non-success status: %d", resp.StatusCode)
+		return fmt.Errorf("API returned status %d", resp.StatusCode)
+	}
+
+	if result != nil {
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			log.Printf("Error reading response body: %v", err)
+			return fmt.Errorf("error reading response body: %v", err)
+		}
+
+		if err := json.Unmarshal(respBody, result); err != nil {
+			log.Printf("Error parsing API response: %v", err)
+			return fmt.Errorf("error parsing API response: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// Get sends a GET request to the API
+func (a *APIClient) Get(originalRequest *http.Request, path string, result interface{}) error {
+	return a.SendRequest(originalRequest, path, http.MethodGet, nil, result)
+}
+
+// Post sends a POST request to the API
+func (a *APIClient) Post(originalRequest *http.Request, path string, body io.Reader, result interface{}) error {
+	return a.SendRequest(originalRequest, path, http.MethodPost, body, result)
+}
+
+// Put sends a PUT request to the API
+func (a *APIClient) Put(originalRequest *http.Request, path string, body io.Reader, result interface{}) error {
+	return a.SendRequest(originalRequest, path, http.MethodPut, body, result)
+}
+
+// Delete sends a DELETE request to the API
+func (a *APIClient) Delete(originalRequest *http.Request, path string) error {
+	return a.SendRequest(originalRequest, path, http.MethodDelete, nil, nil)
+}
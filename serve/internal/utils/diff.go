@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DiffOp is the kind of change of a diff line
+type DiffOp string
+
+const (
+	DiffEqual  DiffOp = "equal"
+	DiffInsert DiffOp = "insert"
+	DiffDelete DiffOp = "delete"
+)
+
+// DiffLine is a single line of a line based diff
+type DiffLine struct {
+	Op   DiffOp `json:"op"`
+	Text string `json:"text"`
+}
+
+// maxDiffCells bounds the LCS table so huge inputs cannot exhaust memory
+const maxDiffCells = 4_000_000
+
+// LineDiff returns the line based diff turning a into b, computed from the
+// longest common subsequence of their lines. Inputs too large to compare line
+// by line are reported as fully replaced.
+func LineDiff(a, b string) []DiffLine {
+	oldLines := splitLines(a)
+	newLines := splitLines(b)
+
+	if (len(oldLines)+1)*(len(newLines)+1) > maxDiffCells {
+		diff := make([]DiffLine, 0, len(oldLines)+len(newLines))
+		for _, line := range oldLines {
+			diff = append(diff, DiffLine{Op: DiffDelete, Text: line})
+		}
+		for _, line := range newLines {
+			diff = append(diff, DiffLine{Op: DiffInsert, Text: line})
+		}
+		return diff
+	}
+
+	// lcs[i][j] is the LCS length of oldLines[i:] and newLines[j:]
+	lcs := make([][]int, len(oldLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(newLines)+1)
+	}
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var diff []DiffLine
+	i, j := 0, 0
+	for i < len(oldLines) && j < len(newLines) {
+		switch {
+		case oldLines[i] == newLines[j]:
+			diff = append(diff, DiffLine{Op: DiffEqual, Text: oldLines[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, DiffLine{Op: DiffDelete, Text: oldLines[i]})
+			i++
+		default:
+			diff = append(diff, DiffLine{Op: DiffInsert, Text: newLines[j]})
+			j++
+		}
+	}
+	for ; i < len(oldLines); i++ {
+		diff = append(diff, DiffLine{Op: DiffDelete, Text: oldLines[i]})
+	}
+	for ; j < len(newLines); j++ {
+		diff = append(diff, DiffLine{Op: DiffInsert, Text: newLines[j]})
+	}
+	return diff
+}
+
+// DiffHunk is a run of changed lines with the unchanged lines around them,
+// as shown in a unified diff. Starts are 1-based line numbers.
+type DiffHunk struct {
+	OldStart int        `json:"old_start"`
+	OldLines int        `json:"old_lines"`
+	NewStart int        `json:"new_start"`
+	NewLines int        `json:"new_lines"`
+	Lines    []DiffLine `json:"lines"`
+}
+
+// Header returns the @@ line that starts the hunk in a unified diff
+func (h DiffHunk) Header() string {
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+}
+
+// DiffHunks groups the changes of a LineDiff into hunks with up to context
+// unchanged lines before and after each change. Changes closer together than
+// twice the context share a hunk. Equal inputs have no hunks.
+func DiffHunks(diff []DiffLine, context int) []DiffHunk {
+	var hunks []DiffHunk
+	oldLine, newLine := 0, 0 // Lines consumed before diff[i]
+	for i := 0; i < len(diff); {
+		if diff[i].Op == DiffEqual {
+			oldLine++
+			newLine++
+			i++
+			continue
+		}
+
+		// Back up over the leading context
+		start := max(0, i-context)
+		hunk := DiffHunk{OldStart: oldLine - (i - start), NewStart: newLine - (i - start)}
+
+		// Take in the following changes with at most twice the context between them
+		last := i
+		for j := i + 1; j < len(diff) && j-last <= 2*context+1; j++ {
+			if diff[j].Op != DiffEqual {
+				last = j
+			}
+		}
+		end := min(len(diff), last+1+context)
+
+		hunk.Lines = diff[start:end]
+		for _, line := range hunk.Lines {
+			if line.Op != DiffInsert {
+				hunk.OldLines++
+			}
+			if line.Op != DiffDelete {
+				hunk.NewLines++
+			}
+		}
+		for _, line := range diff[i:end] {
+			if line.Op != DiffInsert {
+				oldLine++
+			}
+			if line.Op != DiffDelete {
+				newLine++
+			}
+		}
+		// An empty side starts at the line before the hunk, as in diff -u
+		if hunk.OldLines > 0 {
+			hunk.OldStart++
+		}
+		if hunk.NewLines > 0 {
+			hunk.NewStart++
+		}
+		hunks = append(hunks, hunk)
+		i = end
+	}
+	return hunks
+}
+
+// UnifiedDiff formats hunks as a unified diff between the files named from
+// and to
+func UnifiedDiff(from, to string, hunks []DiffHunk) string {
+	if len(hunks) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", from, to)
+	for _, hunk := range hunks {
+		b.WriteString(hunk.Header())
+		b.WriteString("\n")
+		for _, line := range hunk.Lines {
+			switch line.Op {
+			case DiffInsert:
+				b.WriteString("+")
+			case DiffDelete:
+				b.WriteString("-")
+			default:
+				b.WriteString(" ")
+			}
+			b.WriteString(line.Text)
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+}
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"goera/serve/internal/config"
+)
+
+// Pagination is the page requested from a paginated resource. Requests either
+// use offset mode (?page=&page_size=) or, where a resource supports it, cursor
+// mode (?after=<id>&page_size=) which returns the items following an ID.
+type Pagination struct {
+	Page     int
+	PageSize int
+	After    uint // Last ID seen by the client in cursor mode
+	Cursor   bool // Whether the request uses cursor mode
+}
+
+// ParsePagination reads the pagination parameters of a request, applying the
+// page size limits registered for the resource in config.PageSizes. Invalid
+// values fall back to the defaults.
+func ParsePagination(r *http.Request, resource string) Pagination {
+	limits, ok := config.PageSizes[resource]
+	if !ok {
+		limits = config.PageSize{Default: 10, Max: 100}
+	}
+
+	p := Pagination{Page: 1, PageSize: limits.Default}
+
+	if pageParam := r.URL.Query().Get("page"); pageParam != "" {
+		if parsedPage, err := strconv.Atoi(pageParam); err == nil && parsedPage > 0 {
+			p.Page = parsedPage
+		}
+	}
+
+	if pageSizeParam := r.URL.Query().Get("page_size"); pageSizeParam != "" {
+		if parsedPageSize, err := strconv.Atoi(pageSizeParam); err == nil && parsedPageSize > 0 && parsedPageSize <= limits.Max {
+			p.PageSize = parsedPageSize
+		}
+	}
+
+	if afterParam := r.URL.Query().Get("after"); afterParam != "" {
+		if parsedAfter, err := strconv.ParseUint(afterParam, 10, 64); err == nil {
+			p.After = uint(parsedAfter)
+			p.Cursor = true
+		}
+	}
+
+	return p
+}
+
+// Offset returns the number of items to skip in offset mode
+func (p Pagination) Offset() int {
+	return (p.Page - 1) * p.PageSize
+}
+
+// TotalPages returns the number of pages needed for totalItems
+func TotalPages(totalItems int64, pageSize int) int {
+	return int((totalItems + int64(pageSize) - 1) / int64(pageSize))
+}
+
+// SetPageLinks sets an RFC 8288 Link header with the first, prev, next and
+// last pages of an offset paginated response
+func SetPageLinks(w http.ResponseWriter, r *http.Request, p Pagination, totalPages int) {
+	var links []string
+	addLink := func(rel string, page int) {
+		links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, pageURL(r, p.PageSize, "page", strconv.Itoa(page)), rel))
+	}
+
+	if totalPages > 0 {
+		addLink("first", 1)
+	}
+	if p.Page > 1 {
+		addLink("prev", min(p.Page-1, max(totalPages, 1)))
+	}
+	if p.Page < totalPages {
+		addLink("next", p.Page+1)
+	}
+	if totalPages > 0 {
+		addLink("last", totalPages)
+	}
+
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
+
+// SetCursorLink sets a Link header pointing at the page after lastID of a
+// cursor paginated response. Nothing is set when there are no more items.
+func SetCursorLink(w http.ResponseWriter, r *http.Request, p Pagination, lastID uint, hasMore bool) {
+	if !hasMore {
+		return
+	}
+	next := pageURL(r, p.PageSize, "after", strconv.FormatUint(uint64(lastID), 10))
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next))
+}
+
+// pageURL returns the request URL with the page parameter replaced, keeping
+// every other query parameter such as filters
+func pageURL(r *http.Request, pageSize int, param, value string) string {
+	query := r.URL.Query()
+	query.Del("page")
+	query.Del("after")
+	query.Set(param, value)
+	query.Set("page_size", strconv.Itoa(pageSize))
+	return r.URL.Path + "?" + query.Encode()
+}
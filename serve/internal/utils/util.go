@@ -1,58 +1,99 @@
-package utils
-
-import (
-	"encoding/json"
-	"fmt"
-	"net/http"
-	"strings"
-	"time"
-)
-
-func SetCookie(w http.ResponseWriter, tokenString string, cookieName string, expirationTime time.Time) {
-	http.SetCookie(w, &http.Cookie{
-		Name:     cookieName,
-		Value:    tokenString,
-		Expires:  expirationTime,
-		HttpOnly: true,
-		Secure:   true,
-		Path:     "/",
-		SameSite: http.SameSiteStrictMode,
-	})
-}
-
-func IsJSONRequest(r *http.Request) bool {
-	contentType := r.Header.Get("Content-Type")
-	return contentType == "application/json" || contentType == "application/json; charset=UTF-8"
-}
-
-func IsFormRequest(r *http.Request) bool {
-	contentType := r.Header.Get("Content-Type")
-	return contentType == "application/x-www-form-urlencoded" ||
-		strings.HasPrefix(contentType, "multipart/form-data")
-}
-
-func ProcessRequestData(r *http.Request, jsonTarget interface{}, formProcessor func(*http.Request) (interface{}, error)) (interface{}, error) {
-	if IsJSONRequest(r) {
-		if err := json.NewDecoder(r.Body).Decode(jsonTarget); err != nil {
-			return nil, err
-		}
-		return jsonTarget, nil
-	} else if IsFormRequest(r) {
-		if err := r.ParseForm(); err != nil {
-			return nil, err
-		}
-
-		return formProcessor(r)
-	}
-
-	return nil, fmt.Errorf("unsupported content type: %s", r.Header.Get("Content-Type"))
-}
-
-func GetContentType(r *http.Request) string {
-	if IsJSONRequest(r) {
-		return "json"
-	} else if IsFormRequest(r) {
-		return "form"
-	}
-	return "unknown"
-}
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"goera/serve/internal/config"
+)
+
+func SetCookie(w http.ResponseWriter, tokenString string, cookieName string, expirationTime time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieName,
+		Value:    tokenString,
+		Expires:  expirationTime,
+		HttpOnly: true,
+		Secure:   true,
+		Path:     "/",
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+func IsJSONRequest(r *http.Request) bool {
+	contentType := r.Header.Get("Content-Type")
+	return contentType == "application/json" || contentType == "application/json; charset=UTF-8"
+}
+
+func IsFormRequest(r *http.Request) bool {
+	contentType := r.Header.Get("Content-Type")
+	return contentType == "application/x-www-form-urlencoded" ||
+		strings.HasPrefix(contentType, "multipart/form-data")
+}
+
+func ProcessRequestData(r *http.Request, jsonTarget interface{}, formProcessor func(*http.Request) (interface{}, error)) (interface{}, error) {
+	if IsJSONRequest(r) {
+		if err := json.NewDecoder(r.Body).Decode(jsonTarget); err != nil {
+			return nil, err
+		}
+		return jsonTarget, nil
+	} else if IsFormRequest(r) {
+		if err := r.ParseForm(); err != nil {
+			return nil, err
+		}
+
+		return formProcessor(r)
+	}
+
+	return nil, fmt.Errorf("unsupported content type: %s", r.Header.Get("Content-Type"))
+}
+
+func GetContentType(r *http.Request) string {
+	if IsJSONRequest(r) {
+		return "json"
+	} else if IsFormRequest(r) {
+		return "form"
+	}
+	return "unknown"
+}
+
+// ClientIP returns the IP address a request came from. On requests from a
+// trusted proxy it is the right-most X-Forwarded-For entry not added by a
+// trusted proxy, as proxies append to the header and everything left of their
+// entries is up to the client.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if ip := net.ParseIP(host); ip == nil || !trustedProxy(ip) {
+		return host
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			// Whatever is left of a malformed entry cannot be believed
+			break
+		}
+		host = ip.String()
+		if !trustedProxy(ip) {
+			break
+		}
+	}
+	return host
+}
+
+// trustedProxy reports whether ip is one of config.TrustedProxies
+func trustedProxy(ip net.IP) bool {
+	for _, entry := range strings.Split(config.TrustedProxies, ",") {
+		if network := config.ParseProxy(entry); network != nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,281 @@
+// Package validation checks decoded API requests against the rules in their
+// struct tags and reports every invalid field, so clients can point at the
+// fields to fix instead of showing a generic error.
+//
+// Rules are listed comma separated in a validate tag:
+//
+//	Title     string `json:"title" validate:"required,max=200"`
+//	TimeLimit int    `json:"time_limit_ms" validate:"min=100,max=10000"`
+//	Action    string `json:"action" validate:"oneof=publish unpublish"`
+//
+// required rejects zero values and blank strings. min and max bound numbers
+// by value and strings, slices and maps by length, counting characters rather
+// than bytes. oneof takes space separated values. unique rejects slices
+// holding a value more than once. omitempty skips the other
+// rules of a zero value, and nil pointers are only checked by required.
+// Fields are named by their json tag. Rules that depend on configuration or
+// on several fields go in a Validate method, which Struct calls after the
+// tags.
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"goera/serve/internal/i18n"
+)
+
+// FieldError is one invalid field of a request
+type FieldError struct {
+	Field   string `json:"field"`   // Name of the field in the request body
+	Rule    string `json:"rule"`    // Rule that failed, e.g. required or max
+	Message string `json:"message"` // Human readable description
+
+	format string
+	args   []interface{}
+}
+
+// Errors lists the invalid fields of a request
+type Errors []FieldError
+
+// Error joins the messages of all fields
+func (errs Errors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Add records that field broke rule. format describes the problem and is
+// given the field name followed by args.
+func (errs *Errors) Add(field, rule, format string, args ...interface{}) {
+	args = append([]interface{}{field}, args...)
+	*errs = append(*errs, FieldError{
+		Field:   field,
+		Rule:    rule,
+		Message: fmt.Sprintf(format, args...),
+		format:  format,
+		args:    args,
+	})
+}
+
+// Range records an error on field unless min <= value <= max, for bounds
+// that are only known at run time
+func (errs *Errors) Range(field string, value, min, max int) {
+	if value < min || value > max {
+		errs.Add(field, "range", "%s must be between %d and %d", min, max)
+	}
+}
+
+// Translate returns errs with their messages in locale
+func (errs Errors) Translate(locale string) Errors {
+	translated := make(Errors, len(errs))
+	for i, err := range errs {
+		err.Message = i18n.T(locale, err.format, err.args...)
+		translated[i] = err
+	}
+	return translated
+}
+
+// Validator is implemented by requests with rules that tags cannot express
+type Validator interface {
+	Validate(errs *Errors)
+}
+
+// Struct checks v, a struct or a pointer to one, and returns its invalid
+// fields, or nil when it is valid. A malformed tag is a programming error and
+// panics.
+func Struct(v interface{}) Errors {
+	var errs Errors
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validation: %T is not a struct", v))
+	}
+	checkStruct(&errs, value)
+	if validator, ok := v.(Validator); ok {
+		validator.Validate(&errs)
+	} else if value.CanAddr() {
+		if validator, ok := value.Addr().Interface().(Validator); ok {
+			validator.Validate(&errs)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// DecodeError returns the field of a JSON request body that held a value of
+// the wrong type, when that is why decoding it failed with err, and nil
+// otherwise
+func DecodeError(err error) Errors {
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &typeErr) || typeErr.Field == "" {
+		return nil
+	}
+	var errs Errors
+	switch typeErr.Type.Kind() {
+	case reflect.String:
+		errs.Add(typeErr.Field, "type", "%s must be a string")
+	case reflect.Bool:
+		errs.Add(typeErr.Field, "type", "%s must be true or false")
+	case reflect.Slice, reflect.Array:
+		errs.Add(typeErr.Field, "type", "%s must be a list")
+	case reflect.Map, reflect.Struct:
+		errs.Add(typeErr.Field, "type", "%s must be an object")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		errs.Add(typeErr.Field, "type", "%s must be a whole number")
+	default:
+		errs.Add(typeErr.Field, "type", "%s has the wrong type")
+	}
+	return errs
+}
+
+func checkStruct(errs *Errors, value reflect.Value) {
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, ok := field.Tag.Lookup("validate")
+		if !ok || !field.IsExported() {
+			continue
+		}
+		checkField(errs, fieldName(field), value.Field(i), tag)
+	}
+}
+
+// fieldName is the name of field in a JSON request body
+func fieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+func checkField(errs *Errors, name string, value reflect.Value, tag string) {
+	rules := map[string]string{}
+	for _, rule := range strings.Split(tag, ",") {
+		key, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch key {
+		case "":
+			continue
+		case "required", "omitempty", "min", "max", "oneof", "unique":
+		default:
+			panic(fmt.Sprintf("validation: unknown rule %q on %s", key, name))
+		}
+		rules[key] = param
+	}
+
+	if isZero(value) {
+		if _, ok := rules["required"]; ok {
+			errs.Add(name, "required", "%s is required")
+			return
+		}
+		if _, ok := rules["omitempty"]; ok || value.Kind() == reflect.Pointer {
+			return
+		}
+	}
+	if value.Kind() == reflect.Pointer {
+		value = value.Elem()
+	}
+
+	minParam, hasMin := rules["min"]
+	maxParam, hasMax := rules["max"]
+	if hasMin || hasMax {
+		checkBounds(errs, name, value, minParam, hasMin, maxParam, hasMax)
+	}
+	if param, ok := rules["oneof"]; ok {
+		allowed := strings.Fields(param)
+		actual := fmt.Sprint(value.Interface())
+		found := false
+		for _, candidate := range allowed {
+			found = found || candidate == actual
+		}
+		if !found {
+			errs.Add(name, "oneof", "%s must be one of %s", strings.Join(allowed, ", "))
+		}
+	}
+	if _, ok := rules["unique"]; ok {
+		if value.Kind() != reflect.Slice {
+			panic(fmt.Sprintf("validation: unique does not apply to %s of kind %s", name, value.Kind()))
+		}
+		seen := make(map[interface{}]bool, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			item := value.Index(i).Interface()
+			if seen[item] {
+				errs.Add(name, "unique", "%s must not contain duplicates")
+				break
+			}
+			seen[item] = true
+		}
+	}
+}
+
+// isZero reports whether value is unset, counting blank strings and empty
+// slices and maps as unset
+func isZero(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(value.String()) == ""
+	case reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	}
+	return value.IsZero()
+}
+
+func checkBounds(errs *Errors, name string, value reflect.Value, minParam string, hasMin bool, maxParam string, hasMax bool) {
+	var size int64
+	var unit string
+	switch value.Kind() {
+	case reflect.String:
+		size, unit = int64(utf8.RuneCountInString(value.String())), " characters"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		size, unit = int64(value.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size = value.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		size = int64(value.Uint())
+	default:
+		panic(fmt.Sprintf("validation: min and max do not apply to %s of kind %s", name, value.Kind()))
+	}
+	min, max := parseBound(name, minParam, hasMin), parseBound(name, maxParam, hasMax)
+
+	switch {
+	case hasMin && hasMax && (size < min || size > max):
+		if unit == "" {
+			errs.Add(name, "range", "%s must be between %d and %d", min, max)
+		} else {
+			errs.Add(name, "range", "%s must have between %d and %d"+unit, min, max)
+		}
+	case hasMin && size < min:
+		if unit == "" {
+			errs.Add(name, "min", "%s must be at least %d", min)
+		} else {
+			errs.Add(name, "min", "%s must have at least %d"+unit, min)
+		}
+	case hasMax && size > max:
+		if unit == "" {
+			errs.Add(name, "max", "%s must be at most %d", max)
+		} else {
+			errs.Add(name, "max", "%s must have at most %d"+unit, max)
+		}
+	}
+}
+
+func parseBound(name, param string, present bool) int64 {
+	if !present {
+		return 0
+	}
+	bound, err := strconv.ParseInt(param, 10, 64)
+	if err != nil {
+		panic(fmt.Sprintf("validation: invalid bound %q on %s", param, name))
+	}
+	return bound
+}
@@ -0,0 +1,141 @@
+// Package webhooks sends signed event notifications to URLs users registered.
+// Every request carries the event name, a delivery ID that stays the same
+// across retries, a Unix timestamp and an HMAC-SHA256 signature of
+// "<timestamp>.<body>" under the webhook's secret, so receivers can check
+// that a request came from Goera and is not a replay.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"goera/serve/internal/config"
+)
+
+// Headers of a delivery
+const (
+	EventHeader     = "X-Goera-Event"
+	DeliveryHeader  = "X-Goera-Delivery"
+	TimestampHeader = "X-Goera-Timestamp"
+	SignatureHeader = "X-Goera-Signature" // "sha256=" and the hex encoded signature
+)
+
+// errPrivateAddress is returned for webhooks that resolve to an address
+// serve's own network uses
+var errPrivateAddress = errors.New("webhook address is not public")
+
+// GenerateSecret returns a random secret for a webhook registered without one
+func GenerateSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// NewDeliveryID returns a random ID for a delivery
+func NewDeliveryID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Sign returns the signature of body sent at timestamp under secret
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidateURL checks that rawURL can be registered as a webhook
+func ValidateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("webhook URL must be an http or https URL")
+	}
+	if u.User != nil {
+		return errors.New("webhook URL cannot contain credentials")
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !publicIP(ip) && !config.WebhookAllowPrivate {
+		return errPrivateAddress
+	}
+	return nil
+}
+
+// publicIP reports whether ip is an address on the internet, rather than on
+// the host or network serve runs in
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast())
+}
+
+// client sends deliveries. Its dialer checks the address a host name resolved
+// to, so a public name pointing at a private address is refused as well.
+var client = &http.Client{
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip != nil && !publicIP(ip) && !config.WebhookAllowPrivate {
+					return errPrivateAddress
+				}
+				return nil
+			},
+		}).DialContext,
+		MaxIdleConnsPerHost: 2,
+	},
+	// Redirects would let a webhook send the signed request somewhere else
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// Send POSTs body as event to the webhook at rawURL, signed with secret. It
+// returns the response status, 0 when there was no response, and an error
+// unless the status was 2xx.
+func Send(ctx context.Context, rawURL, secret, event, deliveryID string, body []byte) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.WebhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Goera-Webhooks/1")
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(DeliveryHeader, deliveryID)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(secret, timestamp, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
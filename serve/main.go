@@ -1,92 +1,309 @@
-package main
-
-import (
-	"flag"
-	"fmt"
-	"goera/serve/internal/api"
-	"goera/serve/internal/auth"
-	"goera/serve/internal/config"
-	"goera/serve/internal/database"
-	handler "goera/serve/internal/handlers"
-	"log"
-	"net/http"
-	"os"
-	"strings"
-
-	"github.com/gorilla/mux"
-)
-
-func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: serve <command> [options]")
-		fmt.Println("Commands:")
-		fmt.Println("  serve    Start the server")
-		os.Exit(1)
-	}
-
-	switch os.Args[1] {
-	case "serve":
-		serveCmd := flag.NewFlagSet("serve", flag.ExitOnError)
-		listenAddr := serveCmd.String("listen", "5000", "Port to listen on (e.g., 5000 or :5000)")
-		serveCmd.Parse(os.Args[2:])
-
-		addr := *listenAddr
-		if !strings.Contains(addr, ":") {
-			addr = ":" + addr
-		}
-
-		runServer(addr)
-
-	default:
-		fmt.Printf("Unknown command: %s\n", os.Args[1])
-		os.Exit(1)
-	}
-}
-
-func runServer(port string) {
-	config.Init()
-	
-	// Update the configured port after config initialization
-	config.ServerPort = port
-	
-	err := database.InitDB()
-	if err != nil {
-		log.Fatal(err)
-		return
-	}
-	defer database.CloseDB()
-
-	r := mux.NewRouter()
-	r.Use(auth.Middleware)
-	fs := http.FileServer(http.Dir(config.StaticRouterDir))
-	r.PathPrefix(config.StaticRouter).Handler(http.StripPrefix(config.StaticRouter, fs))
-	r.HandleFunc("/internalapi/judge/{id:[0-9]+}", api.ServerJudgeHandler)
-	r.HandleFunc("/", handler.WelcomeHandler)
-	r.HandleFunc("/login", handler.LoginHandler)
-	r.HandleFunc("/signUp", handler.SignUpHandler)
-	r.HandleFunc("/questions", handler.QuestionsHandler)
-	r.HandleFunc("/question/{id:[0-9]+}", handler.QuestionHandler)
-	r.HandleFunc("/edit/{id:[0-9]+}", handler.QuestionEditHandler)
-	r.HandleFunc("/submissions", handler.SubmissionPageHandler)
-	r.HandleFunc("/createQuestion", handler.QuestionCreateHandler)
-	r.HandleFunc("/profile/{id:[0-9]+}", handler.ProfileHandler)
-
-	s := r.PathPrefix("/api").Subrouter()
-	s.HandleFunc("/login", api.LoginHandler).Methods("GET", "POST")
-	s.HandleFunc("/register", api.RegisterHandler).Methods("GET", "POST")
-	s.HandleFunc("/logout", api.LogoutHandler).Methods("GET", "POST")
-	s.HandleFunc("/user/{id:[0-9]+}/promote", api.PromoteUserHandler).Methods("PUT", "POST")
-	s.HandleFunc("/user/{id:[0-9]+}", api.UsersHandler).Methods("GET")
-
-	s.HandleFunc("/questions", api.QuestionsHandler).Methods("GET", "POST")
-	s.HandleFunc("/questions/{id}", api.QuestionHandler).Methods("GET", "PUT", "DELETE", "POST")
-	s.HandleFunc("/questions/{id}/publish", api.PublishQuestionHandler).Methods("PUT", "POST")
-	s.HandleFunc("/questions/{id}/testcase", api.TestCaseHandler).Methods("GET")
-
-	s.HandleFunc("/submissions", api.SubmissionsHandler).Methods("GET", "POST")
-	s.HandleFunc("/submissions/{id}", api.SubmissionHandler).Methods("GET")
-
-	http.Handle("/", r)
-	fmt.Printf("Server is running on http://localhost%s\n", config.ServerPort)
-	http.ListenAndServe(config.ServerPort, nil)
-}
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"goera/serve/internal/api"
+	"goera/serve/internal/apierror"
+	"goera/serve/internal/auth"
+	"goera/serve/internal/cache"
+	"goera/serve/internal/config"
+	"goera/serve/internal/database"
+	handler "goera/serve/internal/handlers"
+	"goera/serve/internal/i18n"
+	"goera/serve/internal/maintenance"
+	"goera/serve/internal/metrics"
+	"goera/serve/internal/oauth"
+	"goera/serve/internal/preferences"
+	"goera/serve/internal/quota"
+	"goera/serve/internal/ratelimit"
+	"goera/serve/internal/recovery"
+	"goera/serve/internal/storage"
+	"goera/serve/internal/templates"
+	"goera/serve/internal/tracing"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Println("Usage: serve <command> [options]")
+		fmt.Println("Commands:")
+		fmt.Println("  serve    Start the server")
+		fmt.Println("  seed     Fill the database with development data")
+		os.Exit(1)
+	}
+
+	switch os.Args[1] {
+	case "serve":
+		serveCmd := flag.NewFlagSet("serve", flag.ExitOnError)
+		listenAddr := serveCmd.String("listen", "", "Port to listen on (e.g., 5000 or :5000), overrides the config file")
+		devMode := serveCmd.Bool("dev", false, "Reload templates from web/templates on every request")
+		serveCmd.Parse(os.Args[2:])
+
+		addr := *listenAddr
+		if addr != "" && !strings.Contains(addr, ":") {
+			addr = ":" + addr
+		}
+
+		runServer(addr, *devMode)
+
+	case "seed":
+		seedCmd := flag.NewFlagSet("seed", flag.ExitOnError)
+		opts := api.SeedOptions{}
+		seedCmd.StringVar(&opts.AdminUsername, "admin-username", "admin", "Username of the admin account")
+		seedCmd.StringVar(&opts.AdminPassword, "admin-password", "admin", "Password of the admin account")
+		seedCmd.IntVar(&opts.Users, "users", 3, "Number of regular users to create")
+		seedCmd.StringVar(&opts.UserPassword, "user-password", "password", "Password of the regular users")
+		seedCmd.IntVar(&opts.Submissions, "submissions", 40, "Number of fake submissions to create")
+		seedCmd.Uint64Var(&opts.RandomSeed, "random-seed", 1, "Seed for the fake submissions; the same seed gives the same data")
+		seedCmd.Parse(os.Args[2:])
+
+		runSeed(opts)
+
+	default:
+		fmt.Printf("Unknown command: %s\n", os.Args[1])
+		os.Exit(1)
+	}
+}
+
+// runSeed fills the configured database with development data
+func runSeed(opts api.SeedOptions) {
+	if err := config.Init(); err != nil {
+		log.Fatal(err)
+	}
+	if opts.Users < 0 || opts.Submissions < 0 {
+		log.Fatal("users and submissions cannot be negative")
+	}
+	if err := storage.Init(); err != nil {
+		log.Fatal(err)
+	}
+	if err := database.InitDB(); err != nil {
+		log.Fatal(err)
+	}
+	defer database.CloseDB()
+
+	summary, err := api.Seed(database.GetDB(), opts)
+	if err != nil {
+		log.Fatalf("Seeding failed: %v", err)
+	}
+	fmt.Printf("Created %d users, %d questions with %d test cases and %d submissions\n",
+		summary.Users, summary.Questions, summary.TestCases, summary.Submissions)
+	fmt.Printf("Sign in as %s with the password %q\n", opts.AdminUsername, opts.AdminPassword)
+}
+
+func runServer(port string, devMode bool) {
+	if err := config.Init(); err != nil {
+		log.Fatal(err)
+	}
+
+	// The --listen flag overrides the configured port
+	if port != "" {
+		config.ServerPort = port
+	}
+
+	if err := auth.LoadJWTKeys(); err != nil {
+		log.Fatal(err)
+	}
+	go auth.WatchJWTKeys()
+
+	oauth.Init()
+	cache.Init()
+	if err := recovery.Init(); err != nil {
+		log.Fatal(err)
+	}
+	if err := storage.Init(); err != nil {
+		log.Fatal(err)
+	}
+	shutdownTracing, err := tracing.Init()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer shutdownTracing(context.Background())
+
+	if err := templates.Init(devMode); err != nil {
+		log.Fatal(err)
+	}
+
+	err = database.InitDB()
+	if err != nil {
+		log.Fatal(err)
+		return
+	}
+	defer database.CloseDB()
+
+	go api.StartSubmissionReaper()
+	go api.StartJudgeDispatcher()
+	go api.StartContestPublisher()
+	go api.StartAchievementWorker()
+	go api.StartAccountDeletionWorker()
+
+	// The judge reports results over gRPC on a listener of its own
+	grpcListener, err := net.Listen("tcp", config.GRPCListen)
+	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", config.GRPCListen, err)
+	}
+	go func() {
+		if err := api.NewInternalServer().Serve(grpcListener); err != nil {
+			log.Fatalf("Internal gRPC server failed: %v", err)
+		}
+	}()
+
+	r := mux.NewRouter()
+	r.Use(apierror.RequestIDMiddleware)
+	r.Use(tracing.Middleware)
+	r.Use(i18n.Middleware)
+	r.Use(recovery.Middleware)
+	r.Use(auth.Middleware)
+	r.Use(preferences.Middleware)
+	r.Use(maintenance.Middleware)
+	fs := http.FileServer(http.Dir(config.StaticRouterDir))
+	r.PathPrefix(config.StaticRouter).Handler(http.StripPrefix(config.StaticRouter, fs))
+	// Code-runners download test data of the local store through signed links
+	r.PathPrefix(storage.URLPrefix).HandlerFunc(storage.Handler)
+	r.HandleFunc("/.well-known/jwks.json", api.JWKSHandler).Methods("GET")
+	r.HandleFunc("/feed/questions.atom", api.QuestionAtomFeedHandler).Methods("GET")
+	r.HandleFunc("/metrics", metrics.Handler).Methods("GET")
+	r.HandleFunc("/", handler.WelcomeHandler)
+	r.HandleFunc("/login", handler.LoginHandler)
+	r.HandleFunc("/signUp", handler.SignUpHandler)
+	r.HandleFunc("/auth/{provider}/login", api.OAuthLoginHandler)
+	r.HandleFunc("/auth/{provider}/callback", api.OAuthCallbackHandler)
+	r.HandleFunc("/questions", handler.QuestionsHandler)
+	r.HandleFunc("/question/{id:[0-9]+}", handler.QuestionHandler)
+	r.HandleFunc("/question/{id:[0-9]+}/print", handler.QuestionPrintHandler)
+	r.HandleFunc("/edit/{id:[0-9]+}", handler.QuestionEditHandler)
+	r.HandleFunc("/submissions", handler.SubmissionPageHandler)
+	r.HandleFunc("/submission/{id:[0-9]+}", handler.SubmissionDetailHandler)
+	r.HandleFunc("/createQuestion", handler.QuestionCreateHandler)
+	r.HandleFunc("/profile/{id:[0-9]+}", handler.ProfileHandler)
+	r.HandleFunc("/admin", handler.AdminDashboardHandler)
+	r.HandleFunc("/notifications", handler.NotificationsHandler)
+	r.HandleFunc("/collections", handler.CollectionsHandler)
+	r.HandleFunc("/collection/{id:[0-9]+}", handler.CollectionHandler)
+
+	s := r.PathPrefix("/api").Subrouter()
+	s.NotFoundHandler = apierror.NotFoundHandler()
+	s.MethodNotAllowedHandler = apierror.MethodNotAllowedHandler()
+	s.Use(ratelimit.Middleware(ratelimit.NewStore()))
+	s.Use(quota.Middleware)
+	s.HandleFunc("/login", api.LoginHandler).Methods("GET", "POST")
+	s.HandleFunc("/login/unlock", api.UnlockAccountHandler).Methods("GET")
+	s.HandleFunc("/register", api.RegisterHandler).Methods("GET", "POST")
+	s.HandleFunc("/logout", api.LogoutHandler).Methods("GET", "POST")
+	s.HandleFunc("/user/{id:[0-9]+}/promote", api.PromoteUserHandler).Methods("PUT", "POST")
+	s.HandleFunc("/user/{id:[0-9]+}", api.UsersHandler).Methods("GET")
+	s.HandleFunc("/user/preferences", api.UserPreferencesHandler).Methods("GET", "PUT", "POST")
+	s.HandleFunc("/user/usage", api.UserUsageHandler).Methods("GET")
+	s.HandleFunc("/user/self/export", api.AccountExportHandler).Methods("GET")
+	s.HandleFunc("/user/self/delete", api.AccountDeletionHandler).Methods("GET", "POST", "DELETE")
+	s.HandleFunc("/user/{id:[0-9]+}/submissions", api.UserSubmissionsHandler).Methods("GET")
+	s.HandleFunc("/user/{id:[0-9]+}/solved", api.UserSolvedHandler).Methods("GET")
+	s.HandleFunc("/user/{id:[0-9]+}/achievements", api.UserAchievementsHandler).Methods("GET")
+	s.HandleFunc("/user/{id:[0-9]+}/setter", api.UserSetterStatsHandler).Methods("GET")
+	s.HandleFunc("/logins", api.LoginEventsHandler).Methods("GET")
+	s.HandleFunc("/leaderboard/setters", api.SetterLeaderboardHandler).Methods("GET")
+
+	s.HandleFunc("/questions", api.QuestionsHandler).Methods("GET", "POST")
+	s.HandleFunc("/questions/trash", api.QuestionTrashHandler).Methods("GET")
+	s.HandleFunc("/questions/search", api.QuestionSearchHandler).Methods("GET")
+	s.HandleFunc("/questions/bulk", api.QuestionBulkHandler).Methods("POST")
+	s.HandleFunc("/questions/{id}", api.QuestionHandler).Methods("GET", "PUT", "DELETE", "POST")
+	s.HandleFunc("/questions/{id}/publish", api.PublishQuestionHandler).Methods("PUT", "POST")
+	s.HandleFunc("/questions/{id}/status", api.QuestionStatusHandler).Methods("PUT", "POST")
+	s.HandleFunc("/questions/{id}/reviews", api.QuestionReviewsHandler).Methods("GET")
+	s.HandleFunc("/questions/{id}/clone", api.CloneQuestionHandler).Methods("POST")
+	s.HandleFunc("/questions/{id}/transfer", api.TransferQuestionHandler).Methods("POST")
+	s.HandleFunc("/questions/{id}/contributors", api.QuestionContributorsHandler).Methods("GET", "POST")
+	s.HandleFunc("/questions/{id}/contributors/{userId}", api.QuestionContributorHandler).Methods("DELETE")
+	s.HandleFunc("/questions/{id}/restore", api.RestoreQuestionHandler).Methods("PUT", "POST")
+	s.HandleFunc("/questions/{id}/testcase", api.TestCaseHandler).Methods("GET")
+	s.HandleFunc("/questions/{id}/stats", api.QuestionStatsHandler).Methods("GET")
+	s.HandleFunc("/questions/{id}/revisions", api.QuestionRevisionsHandler).Methods("GET")
+	s.HandleFunc("/questions/{id}/revisions/{rev}", api.QuestionRevisionHandler).Methods("GET")
+	s.HandleFunc("/questions/{id}/revisions/{rev}/diff", api.QuestionRevisionDiffHandler).Methods("GET")
+	s.HandleFunc("/questions/{id}/revisions/{rev}/rollback", api.QuestionRollbackHandler).Methods("POST")
+	s.HandleFunc("/questions/{id}/editorial", api.EditorialHandler).Methods("GET", "PUT", "POST")
+	s.HandleFunc("/questions/{id}/draft", api.QuestionDraftHandler).Methods("GET", "PATCH", "DELETE")
+	s.HandleFunc("/questions/{id}/reference-solution", api.ReferenceSolutionHandler).Methods("GET", "PUT", "POST")
+	s.HandleFunc("/questions/{id}/publish-checklist", api.PublishChecklistHandler).Methods("GET")
+	s.HandleFunc("/questions/{id}/generator", api.QuestionGeneratorHandler).Methods("GET", "PUT", "DELETE")
+	s.HandleFunc("/questions/{id}/generator/run", api.GenerateTestCasesHandler).Methods("POST")
+	s.HandleFunc("/questions/{id}/stress", api.QuestionStressHandler).Methods("POST")
+	s.HandleFunc("/questions/{id}/difficulty-vote", api.DifficultyVoteHandler).Methods("POST")
+	s.HandleFunc("/questions/{id}/attempts", api.AttemptsHandler).Methods("GET")
+	s.HandleFunc("/questions/{id}/pdf", api.QuestionPDFHandler).Methods("GET")
+	s.HandleFunc("/questions/{id}/clarifications", api.ClarificationsHandler).Methods("GET", "POST")
+	s.HandleFunc("/clarifications/{id:[0-9]+}/answer", api.ClarificationAnswerHandler).Methods("PUT", "POST")
+
+	s.HandleFunc("/run", api.RunHandler).Methods("POST")
+	s.HandleFunc("/compile-check", api.CompileCheckHandler).Methods("POST")
+
+	s.HandleFunc("/contests", api.ContestsHandler).Methods("GET", "POST")
+	s.HandleFunc("/contests/{id:[0-9]+}", api.ContestHandler).Methods("GET")
+	s.HandleFunc("/contests/{id:[0-9]+}/scoreboard", api.ContestScoreboardHandler).Methods("GET")
+	s.HandleFunc("/contests/{id:[0-9]+}/scoreboard/ws", api.ContestScoreboardStreamHandler).Methods("GET")
+	s.HandleFunc("/contests/{id:[0-9]+}/unfreeze", api.ContestUnfreezeHandler).Methods("POST")
+	s.HandleFunc("/contests/{id:[0-9]+}/register", api.ContestRegistrationHandler).Methods("POST", "DELETE")
+	s.HandleFunc("/contests/{id:[0-9]+}/participants", api.ContestParticipantsHandler).Methods("GET")
+	s.HandleFunc("/contests/{id:[0-9]+}/announcements", api.ContestAnnouncementsHandler).Methods("GET", "POST")
+	s.HandleFunc("/contests/{id:[0-9]+}/clarifications", api.ContestClarificationsHandler).Methods("GET", "POST")
+	s.HandleFunc("/contests/{id:[0-9]+}/clarifications/{clarificationId:[0-9]+}/answer", api.ContestClarificationAnswerHandler).Methods("PUT", "POST")
+	s.HandleFunc("/contests/{id:[0-9]+}/messages/stream", api.ContestMessageStreamHandler).Methods("GET")
+
+	s.HandleFunc("/collections", api.CollectionsHandler).Methods("GET", "POST")
+	s.HandleFunc("/collections/{id:[0-9]+}", api.CollectionHandler).Methods("GET", "PUT", "POST", "DELETE")
+	s.HandleFunc("/groups", api.GroupsHandler).Methods("GET", "POST")
+	s.HandleFunc("/groups/join", api.GroupJoinHandler).Methods("POST")
+	s.HandleFunc("/groups/{id:[0-9]+}", api.GroupHandler).Methods("GET", "DELETE")
+	s.HandleFunc("/groups/{id:[0-9]+}/invite-code", api.GroupInviteCodeHandler).Methods("POST")
+	s.HandleFunc("/groups/{id:[0-9]+}/members/{userId:[0-9]+}", api.GroupMemberHandler).Methods("DELETE")
+	s.HandleFunc("/groups/{id:[0-9]+}/assignments", api.GroupAssignmentsHandler).Methods("GET", "POST")
+	s.HandleFunc("/groups/{id:[0-9]+}/progress", api.GroupProgressHandler).Methods("GET")
+
+	s.HandleFunc("/feed", api.FeedHandler).Methods("GET")
+	s.HandleFunc("/feed/questions.json", api.QuestionJSONFeedHandler).Methods("GET")
+	s.HandleFunc("/announcements", api.AnnouncementsHandler).Methods("GET", "POST")
+	s.HandleFunc("/announcements/{id:[0-9]+}", api.AnnouncementHandler).Methods("GET", "PUT", "DELETE", "POST")
+
+	s.HandleFunc("/notifications", api.NotificationsHandler).Methods("GET")
+	s.HandleFunc("/notifications/unread", api.UnreadNotificationsHandler).Methods("GET")
+	s.HandleFunc("/notifications/read", api.NotificationsReadHandler).Methods("POST")
+	s.HandleFunc("/notifications/{id:[0-9]+}/read", api.NotificationReadHandler).Methods("POST")
+
+	s.HandleFunc("/tokens", api.TokensHandler).Methods("GET", "POST")
+	s.HandleFunc("/tokens/{id:[0-9]+}", api.TokenHandler).Methods("DELETE")
+
+	s.HandleFunc("/webhooks", api.WebhooksHandler).Methods("GET", "POST")
+	s.HandleFunc("/webhooks/{id:[0-9]+}", api.WebhookHandler).Methods("DELETE")
+	s.HandleFunc("/webhooks/{id:[0-9]+}/deliveries", api.WebhookDeliveriesHandler).Methods("GET")
+	s.HandleFunc("/webhooks/{id:[0-9]+}/ping", api.WebhookPingHandler).Methods("POST")
+
+	s.HandleFunc("/sessions", api.SessionsHandler).Methods("GET", "DELETE")
+	s.HandleFunc("/sessions/{id:[0-9]+}", api.SessionHandler).Methods("DELETE")
+
+	s.HandleFunc("/submissions", api.SubmissionsHandler).Methods("GET", "POST")
+	s.HandleFunc("/submissions/stream", api.SubmissionStreamHandler).Methods("GET")
+	s.HandleFunc("/submissions/{id}", api.SubmissionHandler).Methods("GET")
+	s.HandleFunc("/submissions/{id}/events", api.SubmissionEventsHandler).Methods("GET")
+	s.HandleFunc("/submissions/{id}/diff", api.SubmissionDiffHandler).Methods("GET")
+
+	s.HandleFunc("/admin/overview", api.AdminOverviewHandler).Methods("GET")
+	s.HandleFunc("/admin/audit", api.AuditLogHandler).Methods("GET")
+	s.HandleFunc("/admin/dead-letters", api.DeadLettersHandler).Methods("GET")
+	s.HandleFunc("/admin/plagiarism", api.PlagiarismReportHandler).Methods("GET")
+	s.HandleFunc("/admin/plagiarism/scan", api.PlagiarismScanHandler).Methods("POST")
+	s.HandleFunc("/admin/dead-letters/{id}/replay", api.ReplayDeadLetterHandler).Methods("POST")
+	s.HandleFunc("/admin/maintenance", api.MaintenanceHandler).Methods("GET", "PUT", "POST")
+	s.HandleFunc("/admin/users/{id:[0-9]+}/quotas", api.UserQuotasHandler).Methods("GET", "PUT")
+
+	http.Handle("/", tracing.Handler(r))
+	fmt.Printf("Server is running on http://localhost%s\n", config.ServerPort)
+	http.ListenAndServe(config.ServerPort, nil)
+}
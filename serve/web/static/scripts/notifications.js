@@ -0,0 +1,21 @@
+// Shows the number of unread notifications next to the sidebar link
+(function () {
+  const badge = document.getElementById("notificationBadge");
+  if (!badge) {
+    return;
+  }
+
+  fetch("/api/notifications/unread")
+    .then(function (response) {
+      return response.ok ? response.json() : null;
+    })
+    .then(function (result) {
+      if (result && result.unread > 0) {
+        badge.textContent = result.unread > 99 ? "99+" : result.unread;
+        badge.hidden = false;
+      }
+    })
+    .catch(function (error) {
+      console.error("Error fetching notifications:", error);
+    });
+})();
@@ -0,0 +1,10 @@
+// Package web holds the HTML templates compiled into the serve binary.
+package web
+
+import "embed"
+
+//go:embed templates/*.html
+var Templates embed.FS
+
+// TemplatesDir is the on-disk location of the templates, used for live reload in dev mode
+const TemplatesDir = "web/templates"